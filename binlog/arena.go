@@ -0,0 +1,115 @@
+package binlog
+
+import (
+	"sync"
+)
+
+// Arena is a bump allocator for decoded rows. Rows events decoded with an arena
+// keep their rows, values and string data in large blocks shared with other
+// events, all of which are released at once. An arena is meant to be scoped to
+// a transaction and released once the transaction is processed, which saves
+// the garbage collector from tracking every decoded value separately.
+// An arena must not be used concurrently.
+type Arena struct {
+	rows   [][][]interface{}
+	values [][]interface{}
+	data   [][]byte
+	// cur* are indexes of blocks currently allocated from
+	curRows   int
+	curValues int
+	curData   int
+	scratch   []byte
+}
+
+const (
+	arenaBlockRows   = 256
+	arenaBlockValues = 4096
+	arenaBlockData   = 64 << 10
+)
+
+var arenaPool = sync.Pool{
+	New: func() interface{} { return &Arena{} },
+}
+
+// NewArena returns an empty arena.
+func NewArena() *Arena {
+	return arenaPool.Get().(*Arena)
+}
+
+// Release frees all memory allocated from the arena and returns it to a pool.
+// Rows decoded using the arena must not be used after it is released.
+func (a *Arena) Release() {
+	for _, blk := range a.rows {
+		for i := range blk {
+			blk[i] = nil
+		}
+	}
+	for _, blk := range a.values {
+		for i := range blk {
+			blk[i] = nil
+		}
+	}
+	for i := range a.rows {
+		a.rows[i] = a.rows[i][:0]
+	}
+	for i := range a.values {
+		a.values[i] = a.values[i][:0]
+	}
+	for i := range a.data {
+		a.data[i] = a.data[i][:0]
+	}
+	a.curRows, a.curValues, a.curData = 0, 0, 0
+	arenaPool.Put(a)
+}
+
+// newRows returns an empty slice of rows with capacity n.
+func (a *Arena) newRows(n int) [][]interface{} {
+	for ; a.curRows < len(a.rows); a.curRows++ {
+		blk := a.rows[a.curRows]
+		if len(blk)+n <= cap(blk) {
+			a.rows[a.curRows] = blk[:len(blk)+n]
+			return blk[len(blk) : len(blk) : len(blk)+n]
+		}
+	}
+	size := arenaBlockRows
+	if n > size {
+		size = n
+	}
+	a.rows = append(a.rows, make([][]interface{}, n, size))
+	return a.rows[a.curRows][:0:n]
+}
+
+// newRow returns a slice of n values.
+func (a *Arena) newRow(n int) []interface{} {
+	for ; a.curValues < len(a.values); a.curValues++ {
+		blk := a.values[a.curValues]
+		if len(blk)+n <= cap(blk) {
+			a.values[a.curValues] = blk[:len(blk)+n]
+			return blk[len(blk) : len(blk)+n : len(blk)+n]
+		}
+	}
+	size := arenaBlockValues
+	if n > size {
+		size = n
+	}
+	a.values = append(a.values, make([]interface{}, n, size))
+	return a.values[a.curValues][:n:n]
+}
+
+// copy returns a copy of b allocated from the arena.
+func (a *Arena) copy(b []byte) []byte {
+	n := len(b)
+	for ; a.curData < len(a.data); a.curData++ {
+		blk := a.data[a.curData]
+		if len(blk)+n <= cap(blk) {
+			a.data[a.curData] = append(blk, b...)
+			return a.data[a.curData][len(blk) : len(blk)+n : len(blk)+n]
+		}
+	}
+	size := arenaBlockData
+	if n > size {
+		size = n
+	}
+	a.data = append(a.data, append(make([]byte, 0, size), b...))
+	return a.data[a.curData][:n:n]
+}
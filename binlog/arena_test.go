@@ -0,0 +1,35 @@
+package binlog
+
+import (
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	a := NewArena()
+	row1 := a.newRow(3)
+	row2 := a.newRow(2)
+	if len(row1) != 3 || cap(row1) != 3 || len(row2) != 2 {
+		t.Fatalf("Unexpected row sizes: %d/%d, %d", len(row1), cap(row1), len(row2))
+	}
+	row1 = append(row1, "x")
+	row1[0] = "a"
+	if row2[0] != nil {
+		t.Errorf("Expected rows not to share values, got %v", row2[0])
+	}
+
+	str := []byte("hello")
+	cp := a.copy(str)
+	str[0] = 'j'
+	if string(cp) != "hello" {
+		t.Errorf("Expected a copy, got %q", cp)
+	}
+	large := a.copy(make([]byte, 2*arenaBlockData))
+	if len(large) != 2*arenaBlockData {
+		t.Errorf("Expected %d bytes, got %d", 2*arenaBlockData, len(large))
+	}
+
+	a.Release()
+	if a.curValues != 0 || len(a.values[0]) != 0 {
+		t.Errorf("Expected values to be released")
+	}
+}
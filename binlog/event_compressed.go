@@ -0,0 +1,113 @@
+package binlog
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// ErrInvalidCompressedEvent is returned when a compressed event could not be
+// decompressed.
+var ErrInvalidCompressedEvent = errors.New("Compressed event is invalid")
+
+// queryPostHeaderLen is the length of the fixed part of a query event.
+const queryPostHeaderLen = 13
+
+// IsCompressed returns true if given event is one of the MariaDB compressed
+// events.
+func IsCompressed(et EventType) bool {
+	switch et {
+	case EventTypeMariaDBQueryCompressed,
+		EventTypeMariaDBWriteRowsCompressedV1,
+		EventTypeMariaDBUpdateRowsCompressedV1,
+		EventTypeMariaDBDeleteRowsCompressedV1:
+		return true
+	default:
+		return false
+	}
+}
+
+// Decompress converts a MariaDB compressed event into the equivalent regular
+// event, so that it could be decoded with QueryEvent or RowsEvent. The body
+// must not contain the checksum. Events of other types are returned as is.
+// Spec: https://mariadb.com/kb/en/compressed-binary-log/
+func Decompress(et EventType, connBuff []byte, fd FormatDescription) (EventType, []byte, error) {
+	var headerLen int
+	switch et {
+	case EventTypeMariaDBQueryCompressed:
+		// Status variables and the schema name terminated with a zero byte
+		// precede the query
+		if len(connBuff) < queryPostHeaderLen {
+			return et, nil, ErrInvalidCompressedEvent
+		}
+		schemaLen := int(connBuff[8])
+		statusVarLen := int(connBuff[11]) | int(connBuff[12])<<8
+		headerLen = queryPostHeaderLen + statusVarLen + schemaLen + 1
+	case EventTypeMariaDBWriteRowsCompressedV1,
+		EventTypeMariaDBUpdateRowsCompressedV1,
+		EventTypeMariaDBDeleteRowsCompressedV1:
+		// Table ID and flags precede rows
+		headerLen = fd.TableIDSize(et) + 2
+	default:
+		return et, connBuff, nil
+	}
+	if len(connBuff) < headerLen {
+		return et, nil, ErrInvalidCompressedEvent
+	}
+	data, err := decompress(connBuff[headerLen:])
+	if err != nil {
+		return et, nil, err
+	}
+	buf := make([]byte, 0, headerLen+len(data))
+	buf = append(buf, connBuff[:headerLen]...)
+	buf = append(buf, data...)
+	return uncompressedType(et), buf, nil
+}
+
+// uncompressedType returns the regular event type of a compressed event.
+func uncompressedType(et EventType) EventType {
+	switch et {
+	case EventTypeMariaDBQueryCompressed:
+		return EventTypeQuery
+	case EventTypeMariaDBWriteRowsCompressedV1:
+		return EventTypeWriteRowsV1
+	case EventTypeMariaDBUpdateRowsCompressedV1:
+		return EventTypeUpdateRowsV1
+	case EventTypeMariaDBDeleteRowsCompressedV1:
+		return EventTypeDeleteRowsV1
+	default:
+		return et
+	}
+}
+
+// decompress decodes a compressed value. The first byte has the high bit set,
+// the algorithm in bits 4-6, which is always zlib, and the number of bytes the
+// uncompressed length takes in bits 0-2. The big endian uncompressed length
+// and zlib compressed data follow.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 1 || data[0]&0x80 == 0 || data[0]&0x70 != 0 {
+		return nil, ErrInvalidCompressedEvent
+	}
+	lenLen := int(data[0] & 0x07)
+	if lenLen < 1 || lenLen > 4 || len(data) < 1+lenLen {
+		return nil, ErrInvalidCompressedEvent
+	}
+	var size int
+	for _, b := range data[1 : 1+lenLen] {
+		size = size<<8 | int(b)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[1+lenLen:]))
+	if err != nil {
+		return nil, ErrInvalidCompressedEvent
+	}
+	defer zr.Close()
+	// The declared length is not trusted for allocating the output, it could
+	// take up to 4GB while compressed data is short
+	out, err := ioutil.ReadAll(io.LimitReader(zr, int64(size)))
+	if err != nil || len(out) != size {
+		return nil, ErrInvalidCompressedEvent
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package binlog
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	compress := func(data []byte) []byte {
+		var b bytes.Buffer
+		b.Write([]byte{0x82, byte(len(data) >> 8), byte(len(data))})
+		zw := zlib.NewWriter(&b)
+		zw.Write(data)
+		zw.Close()
+		return b.Bytes()
+	}
+	query := []byte("INSERT INTO orders (id) VALUES (1)")
+	queryHeader := []byte{
+		1, 0, 0, 0, // Slave proxy ID
+		0, 0, 0, 0, // Execution time
+		4,    // Schema length
+		0, 0, // Error code
+		2, 0, // Status variables length
+		0x03, 0x00, // Status variables
+		's', 'h', 'o', 'p', 0x00, // Schema
+	}
+	rows := []byte{0x01, 0x01, 0xFF, 0xFE, 0x07, 0x00, 0x00, 0x00}
+	rowsHeader := []byte{1, 0, 0, 0, 0, 0, 0x01, 0x00}
+
+	tests := []struct {
+		et     EventType
+		in     []byte
+		expEt  EventType
+		expBuf []byte
+	}{
+		{EventTypeMariaDBQueryCompressed, append(append([]byte{}, queryHeader...), compress(query)...),
+			EventTypeQuery, append(append([]byte{}, queryHeader...), query...)},
+		{EventTypeMariaDBWriteRowsCompressedV1, append(append([]byte{}, rowsHeader...), compress(rows)...),
+			EventTypeWriteRowsV1, append(append([]byte{}, rowsHeader...), rows...)},
+		{EventTypeXID, []byte{1, 2, 3}, EventTypeXID, []byte{1, 2, 3}},
+	}
+	for _, test := range tests {
+		et, buf, err := Decompress(test.et, test.in, FormatDescription{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.et, err)
+			continue
+		}
+		if et != test.expEt || !bytes.Equal(buf, test.expBuf) {
+			t.Errorf("%s: expected %s %x, got %s %x", test.et, test.expEt, test.expBuf, et, buf)
+		}
+	}
+
+	var qe QueryEvent
+	_, buf, _ := Decompress(tests[0].et, tests[0].in, FormatDescription{})
+	if err := qe.Decode(buf); err != nil || string(qe.Schema) != "shop" || !bytes.Equal(qe.Query, query) {
+		t.Errorf("Unexpected query event %q %q, error %v", qe.Schema, qe.Query, err)
+	}
+
+	corrupt := append(append([]byte{}, rowsHeader...), 0x82, 0x00, 0x08, 0x01)
+	if _, _, err := Decompress(EventTypeMariaDBDeleteRowsCompressedV1, corrupt, FormatDescription{}); err != ErrInvalidCompressedEvent {
+		t.Errorf("Expected ErrInvalidCompressedEvent, got %v", err)
+	}
+}
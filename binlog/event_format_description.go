@@ -39,7 +39,9 @@ type ChecksumAlgorithm byte
 
 const (
 	// FlavorMySQL is the MySQL db flavor.
-	FlavorMySQL = "MySQL"
+	FlavorMySQL Flavor = "MySQL"
+	// FlavorMariaDB is the MariaDB db flavor.
+	FlavorMariaDB Flavor = "MariaDB"
 
 	// ChecksumAlgorithmNone means no checksum appened.
 	ChecksumAlgorithmNone ChecksumAlgorithm = 0x00
@@ -52,18 +54,20 @@ const (
 // Decode decodes given buffer into a format description event.
 // Spec: https://dev.mysql.com/doc/internals/en/format-description-event.html
 func (e *FormatDescriptionEvent) Decode(data []byte) error {
-	buf := buffer.New(data)
+	buf := buffer.NewChecked(data)
 	e.Version = buf.ReadUint16()
 	e.ServerVersion = trimStringEOF(buf.ReadStringVarLen(50))
 	e.CreateTimestamp = buf.ReadUint32()
 	e.EventHeaderLength = buf.ReadUint8()
 	e.EventTypeHeaderLengths = buf.ReadStringEOF()
-	e.ServerDetails = ServerDetails{
-		Flavor:            FlavorMySQL,
-		Version:           parseVersionNumber(e.ServerVersion),
-		ChecksumAlgorithm: ChecksumAlgorithmUndefined,
+	if buf.Err() != nil {
+		return buf.Err()
 	}
-	if e.ServerDetails.Version > 50601 {
+	e.ServerDetails = ParseServerDetails(e.ServerVersion)
+	if e.ServerDetails.hasChecksumAlgorithm() {
+		if len(e.EventTypeHeaderLengths) < 5 {
+			return buffer.ErrShortBuffer
+		}
 		e.ServerDetails.ChecksumAlgorithm = ChecksumAlgorithm(data[len(data)-5])
 		e.EventTypeHeaderLengths = e.EventTypeHeaderLengths[:len(e.EventTypeHeaderLengths)-5]
 	}
@@ -82,6 +86,9 @@ func (fd FormatDescription) HeaderLen() int {
 
 // PostHeaderLen returns length of a post-header for a given event type.
 func (fd FormatDescription) PostHeaderLen(et EventType) int {
+	if et == 0 || int(et) > len(fd.EventTypeHeaderLengths) {
+		return 0
+	}
 	return int(fd.EventTypeHeaderLengths[et-1])
 }
 
@@ -93,6 +100,14 @@ func (fd FormatDescription) TableIDSize(et EventType) int {
 	return 6
 }
 
+// String describes the format in a single line suitable for logs, e.g.
+// "8.0.32 (MySQL 80032), binlog v4, checksum CRC32, header 19 bytes".
+func (fd FormatDescription) String() string {
+	return fmt.Sprintf("%s (%s %d), binlog v%d, checksum %s, header %d bytes",
+		fd.ServerVersion, fd.ServerDetails.Flavor, fd.ServerDetails.Version,
+		fd.Version, fd.ServerDetails.ChecksumAlgorithm, fd.HeaderLen())
+}
+
 func (ca ChecksumAlgorithm) String() string {
 	switch ca {
 	case ChecksumAlgorithmNone:
@@ -106,22 +121,50 @@ func (ca ChecksumAlgorithm) String() string {
 	}
 }
 
+// ParseServerDetails detects server flavor and version number from a version
+// string reported by the server. Checksum algorithm is left undefined.
+func ParseServerDetails(v string) ServerDetails {
+	sd := ServerDetails{
+		Flavor:            FlavorMySQL,
+		ChecksumAlgorithm: ChecksumAlgorithmUndefined,
+	}
+	if strings.Contains(v, "MariaDB") {
+		sd.Flavor = FlavorMariaDB
+		// MariaDB 10 prefixes its version with 5.5.5- in the handshake packet
+		// to stay compatible with older clients
+		v = strings.TrimPrefix(v, "5.5.5-")
+	}
+	sd.Version = parseVersionNumber(v)
+	return sd
+}
+
+// hasChecksumAlgorithm returns true if format description events written by
+// the server end with a checksum algorithm.
+func (sd ServerDetails) hasChecksumAlgorithm() bool {
+	if sd.Flavor == FlavorMariaDB {
+		return sd.Version >= 50300
+	}
+	return sd.Version > 50601
+}
+
 // parseVersionNumber turns string version into a number just like the library
 // mysql_get_server_version function does.
 // Example: 5.7.19-log gets represented as 50719
 // Spec: https://dev.mysql.com/doc/refman/8.0/en/mysql-get-server-version.html
 func parseVersionNumber(v string) int {
-	tokens := strings.Split(v, ".")
-	major, _ := strconv.Atoi(tokens[0])
-	minor, _ := strconv.Atoi(tokens[1])
-	var patch int
-	for i, c := range tokens[2] {
-		if c < '0' || c > '9' {
-			patch, _ = strconv.Atoi(tokens[2][:i])
-			break
+	tokens := strings.SplitN(v, ".", 3)
+	var nums [3]int
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		for j, c := range tok {
+			if c < '0' || c > '9' {
+				tok = tok[:j]
+				break
+			}
 		}
+		nums[i], _ = strconv.Atoi(tok)
 	}
-	return major*10000 + minor*100 + patch
+	return nums[0]*10000 + nums[1]*100 + nums[2]
 }
 
 func trimStringEOF(str []byte) string {
@@ -0,0 +1,25 @@
+package binlog
+
+import (
+	"testing"
+)
+
+func TestParseServerDetails(t *testing.T) {
+	inputs := []struct {
+		in      string
+		flavor  Flavor
+		version int
+	}{
+		{"5.7.19-log", FlavorMySQL, 50719},
+		{"8.0.22", FlavorMySQL, 80022},
+		{"5.5.5-10.3.9-MariaDB-log", FlavorMariaDB, 100309},
+		{"10.4.12-MariaDB", FlavorMariaDB, 100412},
+		{"8.0", FlavorMySQL, 80000},
+	}
+	for _, in := range inputs {
+		sd := ParseServerDetails(in.in)
+		if sd.Flavor != in.flavor || sd.Version != in.version {
+			t.Errorf("Expected %q to be parsed as %s %d, got %s %d", in.in, in.flavor, in.version, sd.Flavor, sd.Version)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package binlog
+
+import (
+	"errors"
+
+	"github.com/Vivino/bocadillo/buffer"
+)
+
+// GTIDEvent is written before each transaction and contains its global
+// transaction identifier.
+type GTIDEvent struct {
+	Flags uint8
+	GTID  GTID
+	// LastCommitted and SequenceNumber form the logical clock of the
+	// transaction (MySQL 5.7+). A transaction doesn't conflict with the
+	// transactions that have sequence numbers greater than its last
+	// committed value, so they could be applied in parallel. Both are zero if
+	// the clock is not logged.
+	LastCommitted  int64
+	SequenceNumber int64
+	// ImmediateCommitTimestamp is the time the transaction was committed on
+	// the server that wrote the event, OriginalCommitTimestamp is the time it
+	// was committed on its original source. Both are in microseconds since
+	// Unix epoch (MySQL 8.0.1+), they're equal on the original source and
+	// zero if not logged. Their difference is the replication delay of the
+	// transaction across all hops.
+	ImmediateCommitTimestamp uint64
+	OriginalCommitTimestamp  uint64
+}
+
+// logicalTimestampTypeCode marks a GTID event that contains a logical clock.
+const logicalTimestampTypeCode = 2
+
+// originalCommitTimestampFlag is set in the immediate commit timestamp when
+// the original commit timestamp follows it.
+const originalCommitTimestampFlag = 1 << 55
+
+// ErrInvalidGTIDEvent is returned when GTID event is too short.
+var ErrInvalidGTIDEvent = errors.New("GTID event is invalid")
+
+// Decode decodes given buffer into a GTID event.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/classbinary__log_1_1Gtid__event.html
+func (e *GTIDEvent) Decode(connBuff []byte) error {
+	if len(connBuff) < 1+16+8 {
+		return ErrInvalidGTIDEvent
+	}
+	buf := buffer.New(connBuff)
+	e.Flags = buf.ReadUint8()
+	copy(e.GTID.SID[:], buf.Read(16))
+	e.GTID.GNO = buf.ReadUint64()
+	e.GTID.Tag = ""
+	e.LastCommitted, e.SequenceNumber = 0, 0
+	e.ImmediateCommitTimestamp, e.OriginalCommitTimestamp = 0, 0
+	if len(connBuff) < 1+16+8+1+8+8 || buf.ReadUint8() != logicalTimestampTypeCode {
+		return nil
+	}
+	e.LastCommitted = int64(buf.ReadUint64())
+	e.SequenceNumber = int64(buf.ReadUint64())
+	// Commit timestamps follow the clock since MySQL 8.0.1
+	if len(connBuff) < 1+16+8+1+8+8+7 {
+		return nil
+	}
+	e.ImmediateCommitTimestamp = buf.ReadVarLen64(7)
+	e.OriginalCommitTimestamp = e.ImmediateCommitTimestamp
+	if e.ImmediateCommitTimestamp&originalCommitTimestampFlag != 0 {
+		e.ImmediateCommitTimestamp &^= originalCommitTimestampFlag
+		if len(connBuff) < 1+16+8+1+8+8+7+7 {
+			return ErrInvalidGTIDEvent
+		}
+		e.OriginalCommitTimestamp = buf.ReadVarLen64(7)
+	}
+	return nil
+}
+
+// Fields of tagged GTID events.
+const (
+	gtidFieldFlags = iota
+	gtidFieldUUID
+	gtidFieldGNO
+	gtidFieldTag
+	gtidFieldLastCommitted
+	gtidFieldSequenceNumber
+	gtidFieldImmediateCommitTimestamp
+	gtidFieldOriginalCommitTimestamp
+)
+
+// DecodeTagged decodes given buffer into a tagged GTID event (MySQL 8.3+).
+// Such events are written for transactions with tagged GTIDs and are
+// serialized as messages of numbered fields, see serialization.go. Fields
+// this decoder doesn't know about are ignored.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/classmysql_1_1binlog_1_1event_1_1Gtid__event.html
+func (e *GTIDEvent) DecodeTagged(connBuff []byte) error {
+	*e = GTIDEvent{}
+	buf := buffer.NewChecked(connBuff)
+	readVarLen(buf) // Format version
+	readVarLen(buf) // Message size
+	readVarLen(buf) // Last non-ignorable field
+fields:
+	for len(buf.Cur()) > 0 && buf.Err() == nil {
+		switch readVarLen(buf) {
+		case gtidFieldFlags:
+			e.Flags = buf.ReadUint8()
+		case gtidFieldUUID:
+			copy(e.GTID.SID[:], buf.Read(16))
+		case gtidFieldGNO:
+			e.GTID.GNO = uint64(readVarLenSigned(buf))
+		case gtidFieldTag:
+			n := readVarLen(buf)
+			if n > maxTagLen {
+				return ErrInvalidGTIDEvent
+			}
+			e.GTID.Tag = string(buf.Read(int(n)))
+		case gtidFieldLastCommitted:
+			e.LastCommitted = readVarLenSigned(buf)
+		case gtidFieldSequenceNumber:
+			e.SequenceNumber = readVarLenSigned(buf)
+		case gtidFieldImmediateCommitTimestamp:
+			e.ImmediateCommitTimestamp = readVarLen(buf)
+		case gtidFieldOriginalCommitTimestamp:
+			e.OriginalCommitTimestamp = readVarLen(buf)
+		default:
+			// Fields are ordered, the rest are not needed
+			break fields
+		}
+	}
+	if buf.Err() != nil || e.GTID.GNO == 0 {
+		return ErrInvalidGTIDEvent
+	}
+	// Original commit timestamp is omitted on the original source
+	if e.OriginalCommitTimestamp == 0 {
+		e.OriginalCommitTimestamp = e.ImmediateCommitTimestamp
+	}
+	return nil
+}
+
+// DecodeGTIDEvent decodes a GTID event of given type, EventTypeGTID or
+// EventTypeGTIDTagged.
+func DecodeGTIDEvent(et EventType, connBuff []byte) (GTIDEvent, error) {
+	var e GTIDEvent
+	if et == EventTypeGTIDTagged {
+		return e, e.DecodeTagged(connBuff)
+	}
+	return e, e.Decode(connBuff)
+}
+
+// MariaDBGTIDEvent is written by MariaDB before each transaction and contains
+// its global transaction identifier. Server ID part of the identifier is
+// stored in the event header.
+type MariaDBGTIDEvent struct {
+	SequenceNumber uint64
+	DomainID       uint32
+	Flags          uint8
+}
+
+// MariaDBGTIDFlagStandalone is set when the event group is not a transaction
+// and is not terminated with a commit, e.g. a DDL statement.
+const MariaDBGTIDFlagStandalone uint8 = 0x01
+
+// Decode decodes given buffer into a MariaDB GTID event.
+// Spec: https://mariadb.com/kb/en/gtid_event/
+func (e *MariaDBGTIDEvent) Decode(connBuff []byte) error {
+	if len(connBuff) < 8+4+1 {
+		return ErrInvalidGTIDEvent
+	}
+	buf := buffer.New(connBuff)
+	e.SequenceNumber = buf.ReadUint64()
+	e.DomainID = buf.ReadUint32()
+	e.Flags = buf.ReadUint8()
+	return nil
+}
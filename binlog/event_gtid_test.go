@@ -0,0 +1,54 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestGTIDEventCommitTimestamps(t *testing.T) {
+	body := make([]byte, 1+16+8+1+8+8, 1+16+8+1+8+8+7+7)
+	body[17] = 9
+	body[25] = logicalTimestampTypeCode
+	ts := func(v uint64) []byte {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], v)
+		return b[:7]
+	}
+
+	var e GTIDEvent
+	if err := e.Decode(body); err != nil || e.GTID.GNO != 9 || e.ImmediateCommitTimestamp != 0 {
+		t.Errorf("Unexpected MySQL 5.7 event %+v: %v", e, err)
+	}
+	// Original timestamp is omitted on the original source
+	if err := e.Decode(append(body, ts(1000)...)); err != nil ||
+		e.ImmediateCommitTimestamp != 1000 || e.OriginalCommitTimestamp != 1000 {
+		t.Errorf("Unexpected event %+v: %v", e, err)
+	}
+	withOriginal := append(append(body, ts(1000|originalCommitTimestampFlag)...), ts(900)...)
+	if err := e.Decode(withOriginal); err != nil ||
+		e.ImmediateCommitTimestamp != 1000 || e.OriginalCommitTimestamp != 900 {
+		t.Errorf("Unexpected event %+v: %v", e, err)
+	}
+	if err := e.Decode(withOriginal[:len(withOriginal)-1]); err != ErrInvalidGTIDEvent {
+		t.Errorf("Expected truncated event to fail decoding, got %v", err)
+	}
+
+	// Tagged events are serialized as fields
+	var msg []byte
+	msg = appendVarLen(msg, 1) // Format version
+	msg = appendVarLen(msg, 0) // Message size
+	msg = appendVarLen(msg, 0) // Last non-ignorable field
+	msg = append(appendVarLen(msg, gtidFieldUUID), make([]byte, 16)...)
+	msg = appendVarLen(appendVarLen(msg, gtidFieldGNO), 9<<1)
+	msg = append(appendVarLen(appendVarLen(msg, gtidFieldTag), 3), "tag"...)
+	msg = appendVarLen(appendVarLen(msg, gtidFieldSequenceNumber), 2<<1)
+	msg = appendVarLen(appendVarLen(msg, gtidFieldImmediateCommitTimestamp), 1700000000000000)
+	if err := e.DecodeTagged(msg); err != nil || e.GTID.String() != "00000000-0000-0000-0000-000000000000:tag:9" ||
+		e.SequenceNumber != 2 || e.ImmediateCommitTimestamp != 1700000000000000 || e.OriginalCommitTimestamp != 1700000000000000 {
+		t.Errorf("Unexpected tagged event %+v: %v", e, err)
+	}
+	msg = appendVarLen(appendVarLen(msg, gtidFieldOriginalCommitTimestamp), 1600000000000000)
+	if err := e.DecodeTagged(msg); err != nil || e.OriginalCommitTimestamp != 1600000000000000 {
+		t.Errorf("Unexpected tagged event %+v: %v", e, err)
+	}
+}
@@ -11,6 +11,18 @@ var (
 	ErrInvalidHeader = errors.New("Header is invalid")
 )
 
+// FileMagic is the header of every binary log file, the first event follows
+// it.
+var FileMagic = []byte{0xFE, 'b', 'i', 'n'}
+
+// EventFlagIgnorable is set in headers of events that could be safely ignored
+// by readers that don't recognize them.
+const EventFlagIgnorable uint16 = 0x80
+
+// EventFlagArtificial is set in headers of events generated by the server for
+// replicas that are not part of the binary log.
+const EventFlagArtificial uint16 = 0x20
+
 // EventHeader represents binlog event header.
 type EventHeader struct {
 	Timestamp    uint32
@@ -30,7 +42,7 @@ func (h *EventHeader) Decode(connBuff []byte, fd FormatDescription) error {
 		return ErrInvalidHeader
 	}
 
-	buf := buffer.New(connBuff)
+	buf := buffer.NewChecked(connBuff)
 	h.Timestamp = buf.ReadUint32()
 	h.Type = EventType(buf.ReadUint8())
 	h.ServerID = buf.ReadUint32()
@@ -44,5 +56,5 @@ func (h *EventHeader) Decode(connBuff []byte, fd FormatDescription) error {
 		h.ExtraHeaders = buf.ReadStringVarLen(headerLen - 19)
 	}
 
-	return nil
+	return buf.Err()
 }
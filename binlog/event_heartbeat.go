@@ -0,0 +1,66 @@
+package binlog
+
+import (
+	"errors"
+
+	"github.com/Vivino/bocadillo/buffer"
+)
+
+// HeartbeatEvent is sent by the master when there are no new events for the
+// duration of the heartbeat period. It is never written to the binary log and
+// contains the position the dump has reached.
+type HeartbeatEvent struct {
+	Position Position
+}
+
+// Heartbeat v2 field types.
+const (
+	heartbeatFieldEnd      = 0
+	heartbeatFieldFile     = 1
+	heartbeatFieldPosition = 2
+)
+
+// ErrInvalidHeartbeatEvent is returned when a heartbeat event is malformed.
+var ErrInvalidHeartbeatEvent = errors.New("Heartbeat event is invalid")
+
+// Decode decodes given buffer into a heartbeat event. The body contains the
+// file name, the offset comes from the header and is truncated to 32 bits.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/classmysql_1_1binlog_1_1event_1_1Heartbeat__event.html
+func (e *HeartbeatEvent) Decode(connBuff []byte, h EventHeader) error {
+	e.Position = Position{File: string(connBuff), Offset: uint64(h.NextOffset)}
+	return nil
+}
+
+// DecodeV2 decodes given buffer into a heartbeat v2 event (MySQL 8.0.26+),
+// which supports positions beyond 4GB. Fields are encoded as a type, a length
+// and a value, fields of unknown types are skipped.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/classmysql_1_1binlog_1_1event_1_1Heartbeat__event__v2.html
+func (e *HeartbeatEvent) DecodeV2(connBuff []byte) error {
+	e.Position = Position{}
+	buf := buffer.NewChecked(connBuff)
+	for len(buf.Cur()) > 0 && buf.Err() == nil {
+		typ, _, _ := buf.ReadUintLenEnc()
+		if typ == heartbeatFieldEnd {
+			break
+		}
+		n, _, _ := buf.ReadUintLenEnc()
+		if n > uint64(len(buf.Cur())) {
+			return ErrInvalidHeartbeatEvent
+		}
+		val := buf.Read(int(n))
+		switch typ {
+		case heartbeatFieldFile:
+			e.Position.File = string(val)
+		case heartbeatFieldPosition:
+			vbuf := buffer.NewChecked(val)
+			e.Position.Offset, _, _ = vbuf.ReadUintLenEnc()
+			if vbuf.Err() != nil {
+				return ErrInvalidHeartbeatEvent
+			}
+		}
+	}
+	if buf.Err() != nil {
+		return ErrInvalidHeartbeatEvent
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package binlog
+
+import (
+	"errors"
+
+	"github.com/Vivino/bocadillo/buffer"
+)
+
+// ErrEncryptedBinlog is returned when a binary log is encrypted. Events that
+// follow the start encryption event can't be decoded without the key.
+var ErrEncryptedBinlog = errors.New("Encrypted binary logs are not supported, the encryption key must be provided")
+
+// MariaDBBinlogCheckpointEvent is written by MariaDB once all transactions of
+// the given binary log file are committed to storage engines, the file is no
+// longer needed for crash recovery afterwards.
+type MariaDBBinlogCheckpointEvent struct {
+	File string
+}
+
+// Decode decodes given buffer into a binlog checkpoint event.
+// Spec: https://mariadb.com/kb/en/binlog_checkpoint_event/
+func (e *MariaDBBinlogCheckpointEvent) Decode(connBuff []byte) error {
+	buf := buffer.NewChecked(connBuff)
+	n := int(buf.ReadUint32())
+	e.File = string(buf.ReadStringVarLen(n))
+	return buf.Err()
+}
+
+// MariaDBStartEncryptionEvent is written by MariaDB at the beginning of a
+// binary log when encrypt_binlog is enabled. All events that follow it are
+// encrypted.
+type MariaDBStartEncryptionEvent struct {
+	Scheme     uint8
+	KeyVersion uint32
+	Nonce      []byte
+}
+
+// Decode decodes given buffer into a start encryption event.
+// Spec: https://mariadb.com/kb/en/start_encryption_event/
+func (e *MariaDBStartEncryptionEvent) Decode(connBuff []byte) error {
+	buf := buffer.NewChecked(connBuff)
+	e.Scheme = buf.ReadUint8()
+	e.KeyVersion = buf.ReadUint32()
+	e.Nonce = buf.ReadStringVarLen(12)
+	return buf.Err()
+}
@@ -16,8 +16,8 @@ type QueryEvent struct {
 
 // Decode given buffer into a qeury event.
 // Spec: https://dev.mysql.com/doc/internals/en/query-event.html
-func (e *QueryEvent) Decode(connBuff []byte) {
-	buf := buffer.New(connBuff)
+func (e *QueryEvent) Decode(connBuff []byte) error {
+	buf := buffer.NewChecked(connBuff)
 
 	e.SlaveProxyID = buf.ReadUint32()
 	e.ExecutionTime = buf.ReadUint32()
@@ -36,4 +36,5 @@ func (e *QueryEvent) Decode(connBuff []byte) {
 
 	buf.Skip(1) // Always 0x00
 	e.Query = buf.Cur()
+	return buf.Err()
 }
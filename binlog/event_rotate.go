@@ -18,12 +18,14 @@ type RotateEvent struct {
 // Decode decodes given buffer into a rotate event.
 // Spec: https://dev.mysql.com/doc/internals/en/rotate-event.html
 func (e *RotateEvent) Decode(connBuff []byte, fd FormatDescription) error {
-	buf := buffer.New(connBuff)
-	if fd.Version > 1 {
+	buf := buffer.NewChecked(connBuff)
+	// Format version is unknown for the artificial rotate event that is sent
+	// before the format description event
+	if fd.Version == 0 || fd.Version > 1 {
 		e.NextFile.Offset = buf.ReadUint64()
 	} else {
 		e.NextFile.Offset = 4
 	}
 	e.NextFile.File = string(buf.ReadStringEOF())
-	return nil
+	return buf.Err()
 }
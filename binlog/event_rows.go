@@ -1,10 +1,10 @@
 package binlog
 
 import (
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"unsafe"
 
 	"github.com/Vivino/bocadillo/buffer"
 	"github.com/Vivino/bocadillo/mysql"
@@ -20,8 +20,96 @@ type RowsEvent struct {
 	ColumnBitmap1 []byte
 	ColumnBitmap2 []byte
 	Rows          [][]interface{}
+	// DescribedColumns is the number of columns of the table description
+	// the event was decoded with. It differs from ColumnCount if the table
+	// changed between the two, rows only contain the columns they have in
+	// common then.
+	DescribedColumns uint64
+	// ZeroCopy makes Decode return string and blob values that point into the
+	// decoded buffer instead of copies. Such values are only valid for as long
+	// as the buffer is not modified or reused.
+	ZeroCopy bool
+	// Arena makes Decode allocate rows and values from the given arena. Such
+	// rows are valid until the arena is released, releasing the event has no
+	// effect.
+	Arena *Arena
+	// CorruptionHandler is called when Decode fails.
+	CorruptionHandler CorruptionHandler
+
+	storage *rowStorage
 }
 
+// ErrColumnCountMismatch is matched by ColumnCountMismatchError.
+var ErrColumnCountMismatch = errors.New("Column count doesn't match table description")
+
+// ColumnCountMismatchError is returned when a rows event has values of columns
+// missing from its table description. Such values could not be skipped
+// without knowing their types. Columns that are absent from row images or
+// NULL are not a problem, rows are decoded without them.
+type ColumnCountMismatchError struct {
+	// ColumnCount is the number of columns of the rows event, Described is
+	// the number of columns of the table description.
+	ColumnCount uint64
+	Described   uint64
+	// Column is the index of the first column with a value that could not
+	// be decoded.
+	Column int
+}
+
+func (e *ColumnCountMismatchError) Error() string {
+	return fmt.Sprintf("Rows event has %d columns, table description has %d, column %d has a value",
+		e.ColumnCount, e.Described, e.Column)
+}
+
+// Is makes the error match ErrColumnCountMismatch.
+func (e *ColumnCountMismatchError) Is(target error) bool {
+	return target == ErrColumnCountMismatch
+}
+
+// ErrUnsupportedColumnType is matched by UnsupportedColumnTypeError.
+var ErrUnsupportedColumnType = errors.New("Unsupported column type")
+
+// UnsupportedColumnTypeError is returned when a rows event has values of a
+// column type that could not be decoded, such as decimals of servers older
+// than 5.0.
+type UnsupportedColumnTypeError struct {
+	Type mysql.ColumnType
+	Meta uint16
+}
+
+func (e *UnsupportedColumnTypeError) Error() string {
+	return fmt.Sprintf("Unsupported column type %d (%s) with metadata %x", e.Type, e.Type, e.Meta)
+}
+
+// Is makes the error match ErrUnsupportedColumnType.
+func (e *UnsupportedColumnTypeError) Is(target error) bool {
+	return target == ErrUnsupportedColumnType
+}
+
+// CorruptionHandler receives rows events that failed to decode along with
+// everything required to decode them again offline: the event body, the
+// format and table description it was decoded with and the error. The buffer
+// is only valid for the duration of the call.
+type CorruptionHandler func(buf []byte, fd FormatDescription, td TableDescription, err error)
+
+// PanicError is returned when decoding panics. Decoders are not supposed to
+// panic on any input, so it indicates a bug rather than corrupted data.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("Decoder panicked: %v", e.Value)
+}
+
+// ErrEmptyRowImage is returned when a rows event has row images that take no
+// space, which would otherwise make decoding loop forever.
+var ErrEmptyRowImage = errors.New("Row image is empty")
+
+// maxEstimatedRows limits the number of rows storage is preallocated for.
+const maxEstimatedRows = 1 << 14
+
 // RowsFlag is bitmask of flags.
 type RowsFlag uint16
 
@@ -32,6 +120,9 @@ const (
 
 // PeekTableIDAndFlags returns table ID and flags without decoding whole event.
 func (e *RowsEvent) PeekTableIDAndFlags(connBuff []byte, fd FormatDescription) (uint64, RowsFlag) {
+	if len(connBuff) < 8 {
+		return 0, 0
+	}
 	if fd.TableIDSize(e.Type) == 6 {
 		return mysql.DecodeUint48(connBuff), RowsFlag(mysql.DecodeUint16(connBuff[6:]))
 	}
@@ -41,24 +132,18 @@ func (e *RowsEvent) PeekTableIDAndFlags(connBuff []byte, fd FormatDescription) (
 // Decode decodes given buffer into a rows event event.
 func (e *RowsEvent) Decode(connBuff []byte, fd FormatDescription, td TableDescription) (err error) {
 	defer func() {
-		if errv := recover(); errv != nil {
-			fmt.Println("Recovered from panic in RowsEvent.Decode")
-			fmt.Println("Error:", errv)
-			fmt.Println("Format:", fd)
-			fmt.Println("Table:", td)
-			fmt.Println("Columns:")
-			for _, ctb := range td.ColumnTypes {
-				fmt.Println(" ", mysql.ColumnType(ctb).String())
-			}
-			fmt.Println("\nBuffer:")
-			fmt.Println(hex.Dump(connBuff))
-			fmt.Println("Stacktrace:")
-			debug.PrintStack()
-			err = errors.New(fmt.Sprint(errv))
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+		if err != nil && e.CorruptionHandler != nil {
+			e.CorruptionHandler(connBuff, fd, td, err)
 		}
 	}()
+	return e.decode(connBuff, fd, td)
+}
 
-	buf := buffer.New(connBuff)
+func (e *RowsEvent) decode(connBuff []byte, fd FormatDescription, td TableDescription) error {
+	buf := buffer.NewChecked(connBuff)
 	idSize := fd.TableIDSize(e.Type)
 	if idSize == 6 {
 		e.TableID = buf.ReadUint48()
@@ -76,13 +161,40 @@ func (e *RowsEvent) Decode(connBuff []byte, fd FormatDescription, td TableDescri
 	}
 
 	e.ColumnCount, _, _ = buf.ReadUintLenEnc()
+	e.DescribedColumns = uint64(len(td.ColumnTypes))
+	if n := uint64(len(td.ColumnMeta)); n < e.DescribedColumns {
+		e.DescribedColumns = n
+	}
 	e.ColumnBitmap1 = buf.ReadStringVarLen(int(e.ColumnCount+7) / 8)
 	if RowsEventHasSecondBitmap(e.Type) {
 		e.ColumnBitmap2 = buf.ReadStringVarLen(int(e.ColumnCount+7) / 8)
 	}
+	if buf.Err() != nil {
+		return buf.Err()
+	}
 
-	e.Rows = make([][]interface{}, 0)
+	ncols := int(e.columns())
+	size := estimateRowSize(td, e.ColumnBitmap1, ncols)
+	if RowsEventHasSecondBitmap(e.Type) {
+		size = (size + estimateRowSize(td, e.ColumnBitmap2, ncols)) / 2
+	}
+	rows := len(buf.Cur())/size + 1
+	if rows > maxEstimatedRows {
+		rows = maxEstimatedRows
+	}
+	if e.Arena != nil {
+		e.Rows = e.Arena.newRows(rows)
+	} else {
+		if e.storage == nil {
+			e.storage = rowStoragePool.Get().(*rowStorage)
+		}
+		e.storage.reset()
+		e.storage.grow(rows, ncols)
+		e.Rows = e.storage.rows
+		defer func() { e.storage.rows = e.Rows }()
+	}
 	for {
+		left := len(buf.Cur())
 		row, err := e.decodeRows(buf, td, e.ColumnBitmap1)
 		if err != nil {
 			return err
@@ -96,25 +208,25 @@ func (e *RowsEvent) Decode(connBuff []byte, fd FormatDescription, td TableDescri
 			}
 			e.Rows = append(e.Rows, row)
 		}
+		if buf.Err() != nil {
+			return buf.Err()
+		}
+		if len(buf.Cur()) == left {
+			return ErrEmptyRowImage
+		}
 		if !buf.More() {
 			break
 		}
 	}
-	return nil
+	return buf.Err()
 }
 
 func (e *RowsEvent) decodeRows(buf *buffer.Buffer, td TableDescription, bm []byte) ([]interface{}, error) {
-	count := 0
-	for i := 0; i < int(e.ColumnCount); i++ {
-		if isBitSet(bm, i) {
-			count++
-		}
-	}
-	count = (count + 7) / 8
-
-	nullBM := buf.ReadStringVarLen(count)
+	count := (countBits(bm, int(e.ColumnCount)) + 7) / 8
+	nullBM := e.readNullBitmap(buf, count)
 	nullIdx := 0
-	row := make([]interface{}, e.ColumnCount)
+	ncols := int(e.columns())
+	row := e.newRow(ncols)
 	for i := 0; i < int(e.ColumnCount); i++ {
 		if !isBitSet(bm, i) {
 			continue
@@ -122,16 +234,56 @@ func (e *RowsEvent) decodeRows(buf *buffer.Buffer, td TableDescription, bm []byt
 
 		isNull := (uint32(nullBM[nullIdx/8]) >> uint32(nullIdx%8)) & 1
 		nullIdx++
+		if i >= ncols {
+			// Values of columns missing from the description could not be
+			// skipped
+			if isNull == 0 {
+				return nil, &ColumnCountMismatchError{ColumnCount: e.ColumnCount, Described: e.DescribedColumns, Column: i}
+			}
+			continue
+		}
 		if isNull > 0 {
 			row[i] = nil
 			continue
 		}
 
 		row[i] = e.decodeValue(buf, mysql.ColumnType(td.ColumnTypes[i]), td.ColumnMeta[i])
+		if err, ok := row[i].(*UnsupportedColumnTypeError); ok {
+			return nil, err
+		}
 	}
 	return row, nil
 }
 
+// columns returns the number of columns rows are decoded with, which are the
+// columns the event and the table description have in common.
+func (e *RowsEvent) columns() uint64 {
+	if e.DescribedColumns < e.ColumnCount {
+		return e.DescribedColumns
+	}
+	return e.ColumnCount
+}
+
+// newRow returns a slice for a row of n values.
+func (e *RowsEvent) newRow(n int) []interface{} {
+	if e.Arena != nil {
+		return e.Arena.newRow(n)
+	}
+	return e.storage.newRow(n)
+}
+
+// readNullBitmap reads a null bitmap of n bytes into a scratch buffer.
+func (e *RowsEvent) readNullBitmap(buf *buffer.Buffer, n int) []byte {
+	var scratch *[]byte
+	if e.Arena != nil {
+		scratch = &e.Arena.scratch
+	} else {
+		scratch = &e.storage.nullBitmap
+	}
+	*scratch = append((*scratch)[:0], buf.Read(n)...)
+	return *scratch
+}
+
 func (e *RowsEvent) decodeValue(buf *buffer.Buffer, ct mysql.ColumnType, meta uint16) interface{} {
 	var length int
 	if ct == mysql.ColumnTypeString {
@@ -184,54 +336,54 @@ func (e *RowsEvent) decodeValue(buf *buffer.Buffer, ct mysql.ColumnType, meta ui
 	case mysql.ColumnTypeTime:
 		return mysql.DecodeTime(buf.ReadUint24())
 	case mysql.ColumnTypeTime2:
-		v, n := mysql.DecodeTime2(buf.Cur(), meta)
+		v, n := mysql.DecodeTime2(buf.Peek(temporalSize(meta)), meta)
 		buf.Skip(n)
 		return v
 	case mysql.ColumnTypeTimestamp:
-		v, n := mysql.DecodeTimestamp(buf.Cur(), meta)
+		v, n := mysql.DecodeTimestamp(buf.Peek(temporalSize(meta)), meta)
 		buf.Skip(n)
 		return v
 	case mysql.ColumnTypeTimestamp2:
-		v, n := mysql.DecodeTimestamp2(buf.Cur(), meta)
+		v, n := mysql.DecodeTimestamp2(buf.Peek(temporalSize(meta)), meta)
 		buf.Skip(n)
 		return v
 	case mysql.ColumnTypeDatetime:
 		return mysql.DecodeDatetime(buf.ReadUint64())
 	case mysql.ColumnTypeDatetime2:
-		v, n := mysql.DecodeDatetime2(buf.Cur(), meta)
+		v, n := mysql.DecodeDatetime2(buf.Peek(temporalSize(meta)), meta)
 		buf.Skip(n)
 		return v
 
 	// Strings
 	case mysql.ColumnTypeString:
-		return readString(buf, length)
+		return e.readString(buf, length)
 	case mysql.ColumnTypeVarchar, mysql.ColumnTypeVarstring:
-		return readString(buf, int(meta))
+		return e.readString(buf, int(meta))
 
 	// Blobs
 	case mysql.ColumnTypeBlob, mysql.ColumnTypeGeometry:
-		return buf.ReadStringVarEnc(int(meta))
+		return e.readBlob(buf, int(meta))
 	case mysql.ColumnTypeJSON:
 		jdata := buf.ReadStringVarEnc(int(meta))
 		rawj, _ := mysql.DecodeJSON(jdata)
 		return rawj
 	case mysql.ColumnTypeTinyblob:
-		return buf.ReadStringVarEnc(1)
+		return e.readBlob(buf, 1)
 	case mysql.ColumnTypeMediumblob:
-		return buf.ReadStringVarEnc(3)
+		return e.readBlob(buf, 3)
 	case mysql.ColumnTypeLongblob:
-		return buf.ReadStringVarEnc(4)
+		return e.readBlob(buf, 4)
 
 	// Other
 	case mysql.ColumnTypeBit:
 		nbits := int(((meta >> 8) * 8) + (meta & 0xFF))
 		length = int(nbits+7) / 8
-		v, n := mysql.DecodeBit(buf.Cur(), nbits, length)
+		v, n := mysql.DecodeBit(buf.Peek(length), nbits, length)
 		buf.Skip(n)
 		return v
 	case mysql.ColumnTypeSet:
 		nbits := length * 8
-		v, n := mysql.DecodeBit(buf.Cur(), nbits, length)
+		v, n := mysql.DecodeBit(buf.Peek(length), nbits, length)
 		buf.Skip(n)
 		return v
 	case mysql.ColumnTypeEnum:
@@ -245,17 +397,93 @@ func (e *RowsEvent) decodeValue(buf *buffer.Buffer, ct mysql.ColumnType, meta ui
 		// Too new
 		fallthrough
 	default:
-		return fmt.Errorf("unsupported type: %d (%s) %x %x", ct, ct.String(), meta, buf.Cur())
+		return &UnsupportedColumnTypeError{Type: ct, Meta: meta}
 	}
 }
 
-func readString(buf *buffer.Buffer, length int) string {
+func (e *RowsEvent) readString(buf *buffer.Buffer, length int) string {
 	// Length is encoded in 1 byte
-	if length < 256 {
-		return string(buf.ReadStringVarEnc(1))
+	n := 1
+	if length >= 256 {
+		// Length is encoded in 2 bytes
+		n = 2
+	}
+	switch {
+	case e.ZeroCopy:
+		return unsafeString(buf.ReadStringVarEncNoCopy(n))
+	case e.Arena != nil:
+		return unsafeString(e.Arena.copy(buf.ReadStringVarEncNoCopy(n)))
+	default:
+		return string(buf.ReadStringVarEncNoCopy(n))
+	}
+}
+
+func (e *RowsEvent) readBlob(buf *buffer.Buffer, n int) []byte {
+	switch {
+	case e.ZeroCopy:
+		return buf.ReadStringVarEncNoCopy(n)
+	case e.Arena != nil:
+		return e.Arena.copy(buf.ReadStringVarEncNoCopy(n))
+	default:
+		return buf.ReadStringVarEnc(n)
+	}
+}
+
+// unsafeString returns a string that shares memory with the given slice.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// temporalSize returns the upper bound of a binary encoded temporal value size
+// with given fractional seconds precision.
+func temporalSize(dec uint16) int {
+	return 5 + (int(dec)+1)/2
+}
+
+// estimateRowSize returns an approximate size of an encoded row image. It's
+// used to estimate the number of rows in an event. Variable length values are
+// assumed to be short.
+func estimateRowSize(td TableDescription, bm []byte, ncols int) int {
+	size := (countBits(bm, ncols) + 7) / 8
+	for i := 0; i < ncols; i++ {
+		if !isBitSet(bm, i) {
+			continue
+		}
+		switch mysql.ColumnType(td.ColumnTypes[i]) {
+		case mysql.ColumnTypeTiny, mysql.ColumnTypeYear:
+			size++
+		case mysql.ColumnTypeShort:
+			size += 2
+		case mysql.ColumnTypeInt24, mysql.ColumnTypeDate, mysql.ColumnTypeTime:
+			size += 3
+		case mysql.ColumnTypeLong, mysql.ColumnTypeFloat:
+			size += 4
+		case mysql.ColumnTypeLonglong, mysql.ColumnTypeDouble, mysql.ColumnTypeDatetime:
+			size += 8
+		case mysql.ColumnTypeTime2, mysql.ColumnTypeTimestamp2, mysql.ColumnTypeDatetime2,
+			mysql.ColumnTypeTimestamp:
+			size += temporalSize(td.ColumnMeta[i])
+		default:
+			size += 8
+		}
+	}
+	if size == 0 {
+		return 1
+	}
+	return size
+}
+
+func countBits(bm []byte, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if isBitSet(bm, i) {
+			count++
+		}
 	}
-	// Length is encoded in 2 bytes
-	return string(buf.ReadStringVarEnc(2))
+	return count
 }
 
 func isBitSet(bm []byte, i int) bool {
@@ -0,0 +1,134 @@
+package binlog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestRowsEventCorruptionHandler(t *testing.T) {
+	data := []byte{
+		0x2A, 0, 0, 0, 0, 0, // Table ID
+		0x01, 0x00, // Flags
+		0x02, 0x00, // Extra data length
+		0x02,       // Column count
+		0x03,       // Columns present
+		0x00,       // Null bitmap
+		1, 0, 0, 0, // id
+		2, 0, 0, 0, // user
+	}
+	td := TableDescription{
+		TableName:   "orders",
+		ColumnCount: 1,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0},
+	}
+
+	var calls int
+	e := RowsEvent{Type: EventTypeWriteRowsV2}
+	e.CorruptionHandler = func(buf []byte, fd FormatDescription, gotTD TableDescription, err error) {
+		calls++
+		if !bytes.Equal(buf, data) || gotTD.TableName != td.TableName || !errors.Is(err, ErrColumnCountMismatch) {
+			t.Errorf("Unexpected corruption report: %x %+v %v", buf, gotTD, err)
+		}
+	}
+	if err := e.Decode(data, FormatDescription{}, td); !errors.Is(err, ErrColumnCountMismatch) {
+		t.Errorf("Expected column count mismatch, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to be called once, got %d calls", calls)
+	}
+
+	td.ColumnCount = 2
+	td.ColumnTypes = append(td.ColumnTypes, byte(mysql.ColumnTypeLong))
+	td.ColumnMeta = append(td.ColumnMeta, 0)
+	if err := e.Decode(data, FormatDescription{}, td); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if calls != 1 || len(e.Rows) != 1 {
+		t.Errorf("Expected a single row and no corruption reports, got %v and %d calls", e.Rows, calls)
+	}
+}
+
+func TestRowsEventColumnDrift(t *testing.T) {
+	long := func(n int) TableDescription {
+		td := TableDescription{TableName: "orders", ColumnCount: uint64(n)}
+		for i := 0; i < n; i++ {
+			td.ColumnTypes = append(td.ColumnTypes, byte(mysql.ColumnTypeLong))
+			td.ColumnMeta = append(td.ColumnMeta, 0)
+		}
+		return td
+	}
+	// Column added to the table, its value is NULL
+	added := []byte{
+		0x2A, 0, 0, 0, 0, 0, // Table ID
+		0x01, 0x00, // Flags
+		0x02, 0x00, // Extra data length
+		0x02,       // Column count
+		0x03,       // Columns present
+		0x02,       // Null bitmap
+		1, 0, 0, 0, // id
+	}
+	var e RowsEvent
+	e.Type = EventTypeWriteRowsV2
+	if err := e.Decode(added, FormatDescription{}, long(1)); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if e.ColumnCount != 2 || e.DescribedColumns != 1 || len(e.Rows) != 1 || len(e.Rows[0]) != 1 || e.Rows[0][0] != uint32(1) {
+		t.Errorf("Expected a row with the first column, got %d/%d columns %v", e.ColumnCount, e.DescribedColumns, e.Rows)
+	}
+
+	// Column dropped from the table
+	dropped := []byte{
+		0x2A, 0, 0, 0, 0, 0, // Table ID
+		0x01, 0x00, // Flags
+		0x02, 0x00, // Extra data length
+		0x01,       // Column count
+		0x01,       // Columns present
+		0x00,       // Null bitmap
+		1, 0, 0, 0, // id
+	}
+	if err := e.Decode(dropped, FormatDescription{}, long(2)); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if e.ColumnCount != 1 || e.DescribedColumns != 2 || len(e.Rows) != 1 || len(e.Rows[0]) != 1 {
+		t.Errorf("Expected a row with the first column, got %d/%d columns %v", e.ColumnCount, e.DescribedColumns, e.Rows)
+	}
+}
+
+func TestRowsEventChanges(t *testing.T) {
+	re := RowsEvent{
+		Type:          EventTypeUpdateRowsV2,
+		ColumnBitmap1: []byte{0x03},
+		ColumnBitmap2: []byte{0x02},
+		Rows: [][]interface{}{
+			{int32(1), "old"}, {nil, "new"},
+			{int32(2), "old"}, {nil, "newer"},
+		},
+	}
+	changes := re.Changes()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+	c := changes[1]
+	if c.Type != ChangeUpdate || c.Before[0] != int32(2) || c.After[1] != "newer" {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+	if !c.BeforeHas(0) || c.AfterHas(0) || !c.AfterHas(1) || c.AfterHas(8) {
+		t.Errorf("Unexpected column bitmaps: %+v", c)
+	}
+
+	re.Type = EventTypeDeleteRowsV2
+	changes = re.Changes()
+	if len(changes) != 4 || changes[0].Type != ChangeDelete || changes[0].After != nil {
+		t.Errorf("Expected 4 deletes, got %+v", changes)
+	}
+	if (&RowsEvent{Type: EventTypeQuery}).Changes() != nil {
+		t.Error("Expected no changes for a query event")
+	}
+	if !(RowChange{}).AfterHas(5) {
+		t.Error("Expected all columns to be present without a bitmap")
+	}
+}
@@ -14,8 +14,56 @@ type TableDescription struct {
 	ColumnTypes []byte
 	ColumnMeta  []uint16
 	NullBitmask []byte
+
+	// Following fields are only set when the server is configured to log
+	// full table metadata with binlog_row_metadata=FULL (MySQL 8.0.1+).
+
+	// ColumnNames contains names of the columns.
+	ColumnNames []string
+	// Unsigned is set for unsigned numeric columns.
+	Unsigned []bool
+	// PrimaryKey contains indexes of primary key columns.
+	PrimaryKey []int
+	// EnumValues contains members of enum and set columns, it's nil for
+	// columns of other types.
+	EnumValues [][]string
+
+	// Defaults contains default values of columns as reported by
+	// INFORMATION_SCHEMA, nil for columns without a default. The server never
+	// logs defaults, they are only set by schema sources of the reader.
+	Defaults []*string
+}
+
+// ColumnType returns the type of the i-th column. Unlike ColumnTypes it
+// resolves the real type of enum and set columns, which are logged as strings.
+func (td TableDescription) ColumnType(i int) mysql.ColumnType {
+	ct := mysql.ColumnType(td.ColumnTypes[i])
+	if ct == mysql.ColumnTypeString && td.ColumnMeta[i] > 0xFF {
+		typeByte := uint8(td.ColumnMeta[i] >> 8)
+		if typeByte&0x30 != 0x30 {
+			return mysql.ColumnType(typeByte | 0x30)
+		}
+		return mysql.ColumnType(typeByte)
+	}
+	return ct
+}
+
+// Nullable returns true if the i-th column accepts NULL values.
+func (td TableDescription) Nullable(i int) bool {
+	return i/8 < len(td.NullBitmask) && td.NullBitmask[i/8]&(1<<uint(i%8)) != 0
 }
 
+// Optional metadata types.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/classmysql_1_1binlog_1_1event_1_1Table__map__event.html
+const (
+	tableMetaSignedness        = 1
+	tableMetaColumnName        = 4
+	tableMetaSetStrValue       = 5
+	tableMetaEnumStrValue      = 6
+	tableMetaSimplePrimaryKey  = 8
+	tableMetaPrimaryKeyWithPfx = 9
+)
+
 // TableMapEvent contains table description alongside an ID that would be used
 // to reference the table in the following rows events.
 type TableMapEvent struct {
@@ -26,7 +74,7 @@ type TableMapEvent struct {
 // Decode decodes given buffer into a table map event.
 // Spec: https://dev.mysql.com/doc/internals/en/table-map-event.html
 func (e *TableMapEvent) Decode(connBuff []byte, fd FormatDescription) error {
-	buf := buffer.New(connBuff)
+	buf := buffer.NewChecked(connBuff)
 	idSize := fd.TableIDSize(EventTypeTableMap)
 	if idSize == 6 {
 		e.TableID = buf.ReadUint48()
@@ -36,42 +84,141 @@ func (e *TableMapEvent) Decode(connBuff []byte, fd FormatDescription) error {
 
 	e.Flags = buf.ReadUint16()
 	schemaName, _ := buf.ReadStringLenEnc()
-	e.SchemaName = string(schemaName)
+	e.SchemaName = internName(schemaName)
 	buf.Skip(1) // Always 0x00
 	tableName, _ := buf.ReadStringLenEnc()
-	e.TableName = string(tableName)
+	e.TableName = internName(tableName)
 	buf.Skip(1) // Always 0x00
 	e.ColumnCount, _, _ = buf.ReadUintLenEnc()
 	e.ColumnTypes = buf.ReadStringVarLen(int(e.ColumnCount))
 	colMeta, _ := buf.ReadStringLenEnc()
-	e.ColumnMeta = decodeColumnMeta(colMeta, e.ColumnTypes)
-	e.NullBitmask = buf.ReadStringVarLen(int(e.ColumnCount+8) / 7)
+	if buf.Err() != nil {
+		return buf.Err()
+	}
+	meta, err := decodeColumnMeta(colMeta, e.ColumnTypes)
+	if err != nil {
+		return err
+	}
+	e.ColumnMeta = meta
+	e.NullBitmask = buf.ReadStringVarLen(int(e.ColumnCount+7) / 8)
+	if buf.Err() != nil {
+		return buf.Err()
+	}
+
+	// Remaining buffer contains optional metadata
+	return e.decodeOptionalMeta(buf.Cur())
+}
+
+// decodeOptionalMeta decodes optional metadata fields. Each field consists of
+// a type, a length and a value. Unknown types are skipped.
+func (e *TableMapEvent) decodeOptionalMeta(data []byte) error {
+	e.ColumnNames, e.Unsigned, e.PrimaryKey, e.EnumValues = nil, nil, nil, nil
+	buf := buffer.NewChecked(data)
+	for len(buf.Cur()) > 0 {
+		typ := buf.ReadUint8()
+		length, _, _ := buf.ReadUintLenEnc()
+		if length > uint64(len(buf.Cur())) {
+			return buffer.ErrShortBuffer
+		}
+		val := buffer.NewChecked(buf.Read(int(length)))
 
-	return nil
+		switch typ {
+		case tableMetaSignedness:
+			e.Unsigned = make([]bool, e.ColumnCount)
+			bits := val.Cur()
+			n := 0
+			for i, ct := range e.ColumnTypes {
+				if !isNumericType(mysql.ColumnType(ct)) {
+					continue
+				}
+				// Most significant bit first
+				if n/8 < len(bits) && bits[n/8]&(0x80>>uint(n%8)) > 0 {
+					e.Unsigned[i] = true
+				}
+				n++
+			}
+		case tableMetaColumnName:
+			e.ColumnNames = make([]string, 0, e.ColumnCount)
+			for len(val.Cur()) > 0 {
+				name, _ := val.ReadStringLenEnc()
+				e.ColumnNames = append(e.ColumnNames, internName(name))
+			}
+		case tableMetaSetStrValue, tableMetaEnumStrValue:
+			ct := mysql.ColumnTypeSet
+			if typ == tableMetaEnumStrValue {
+				ct = mysql.ColumnTypeEnum
+			}
+			if e.EnumValues == nil {
+				e.EnumValues = make([][]string, e.ColumnCount)
+			}
+			// Members are listed for every column of the type in column order
+			for i := range e.ColumnTypes {
+				if len(val.Cur()) == 0 {
+					break
+				}
+				if e.ColumnType(i) != ct {
+					continue
+				}
+				n, _, _ := val.ReadUintLenEnc()
+				for j := uint64(0); j < n && val.Err() == nil; j++ {
+					member, _ := val.ReadStringLenEnc()
+					e.EnumValues[i] = append(e.EnumValues[i], string(member))
+				}
+			}
+		case tableMetaSimplePrimaryKey, tableMetaPrimaryKeyWithPfx:
+			for len(val.Cur()) > 0 {
+				idx, _, _ := val.ReadUintLenEnc()
+				e.PrimaryKey = append(e.PrimaryKey, int(idx))
+				if typ == tableMetaPrimaryKeyWithPfx {
+					// Prefix length
+					val.ReadUintLenEnc()
+				}
+			}
+		}
+		if val.Err() != nil {
+			return val.Err()
+		}
+	}
+	return buf.Err()
+}
+
+// isNumericType returns true for column types which signedness is described in
+// optional metadata.
+func isNumericType(ct mysql.ColumnType) bool {
+	switch ct {
+	case mysql.ColumnTypeTiny,
+		mysql.ColumnTypeShort,
+		mysql.ColumnTypeInt24,
+		mysql.ColumnTypeLong,
+		mysql.ColumnTypeLonglong,
+		mysql.ColumnTypeFloat,
+		mysql.ColumnTypeDouble,
+		mysql.ColumnTypeNewDecimal:
+		return true
+	default:
+		return false
+	}
 }
 
-func decodeColumnMeta(data []byte, cols []byte) []uint16 {
-	pos := 0
+func decodeColumnMeta(data []byte, cols []byte) ([]uint16, error) {
+	buf := buffer.NewChecked(data)
 	meta := make([]uint16, len(cols))
 	for i, typ := range cols {
 		switch mysql.ColumnType(typ) {
 		case mysql.ColumnTypeString:
 			// 1st: Type
 			// 2nd: Length
-			meta[i] = uint16(data[pos])<<8 | uint16(data[pos+1])
-			pos += 2
+			meta[i] = uint16(buf.ReadUint8())<<8 | uint16(buf.ReadUint8())
 		case mysql.ColumnTypeNewDecimal:
 			// 1st: Precision
 			// 2nd: Decimal places
-			meta[i] = uint16(data[pos])<<8 | uint16(data[pos+1])
-			pos += 2
+			meta[i] = uint16(buf.ReadUint8())<<8 | uint16(buf.ReadUint8())
 		case mysql.ColumnTypeVarchar,
 			mysql.ColumnTypeVarstring,
 			mysql.ColumnTypeBit:
 
 			// Likely it's length
-			meta[i] = mysql.DecodeUint16(data[pos:])
-			pos += 2
+			meta[i] = buf.ReadUint16()
 		case mysql.ColumnTypeFloat,
 			mysql.ColumnTypeDouble,
 			mysql.ColumnTypeBlob,
@@ -81,9 +228,8 @@ func decodeColumnMeta(data []byte, cols []byte) []uint16 {
 			mysql.ColumnTypeDatetime2,
 			mysql.ColumnTypeTimestamp2:
 
-			meta[i] = uint16(data[pos])
-			pos++
+			meta[i] = uint16(buf.ReadUint8())
 		}
 	}
-	return meta
+	return meta, buf.Err()
 }
@@ -0,0 +1,52 @@
+package binlog
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTableMapEventDecode(t *testing.T) {
+	data := []byte{
+		0x2A, 0, 0, 0, 0, 0, // Table ID
+		0x01, 0x00, // Flags
+		0x04, 's', 'h', 'o', 'p', 0x00, // Schema name
+		0x06, 'o', 'r', 'd', 'e', 'r', 's', 0x00, // Table name
+		0x03,                                                      // Column count
+		byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar), // Column types
+		byte(mysql.ColumnTypeLonglong),
+		0x02, 0x40, 0x00, // Column metadata
+		0x06, // Null bitmask
+		// Signedness
+		0x01, 0x01, 0x40,
+		// Column names
+		0x04, 0x0F, 0x02, 'i', 'd', 0x06, 's', 't', 'a', 't', 'u', 's', 0x04, 'u', 's', 'e', 'r',
+		// Simple primary key
+		0x08, 0x01, 0x00,
+	}
+
+	var e TableMapEvent
+	var fd FormatDescription
+	if err := e.Decode(data, fd); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := TableMapEvent{
+		TableID: 42,
+		TableDescription: TableDescription{
+			Flags:       1,
+			SchemaName:  "shop",
+			TableName:   "orders",
+			ColumnCount: 3,
+			ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar), byte(mysql.ColumnTypeLonglong)},
+			ColumnMeta:  []uint16{0, 64, 0},
+			NullBitmask: []byte{0x06},
+			ColumnNames: []string{"id", "status", "user"},
+			Unsigned:    []bool{false, false, true},
+			PrimaryKey:  []int{0},
+		},
+	}
+	if diff := cmp.Diff(exp, e); diff != "" {
+		t.Errorf("Unexpected event (-exp +got):\n%s", diff)
+	}
+}
@@ -127,6 +127,60 @@ const (
 	EventTypeAnonymousGTID EventType = 34
 	// EventTypePreviousGTIDs is a subclass of GTIDEvent.
 	EventTypePreviousGTIDs EventType = 35
+	// EventTypeTransactionContext carries group replication certification
+	// details, it's never written to the binary log.
+	EventTypeTransactionContext EventType = 36
+	// EventTypeViewChange is written when group replication membership
+	// changes.
+	EventTypeViewChange EventType = 37
+	// EventTypeXAPrepare ends the first phase of an XA transaction. Used
+	// starting from MySQL 5.7.7.
+	EventTypeXAPrepare EventType = 38
+	// EventTypePartialUpdateRows is an UpdateRowsEventV2 with partial JSON
+	// updates, written when binlog_row_value_options is PARTIAL_JSON. Used
+	// starting from MySQL 8.0.3.
+	EventTypePartialUpdateRows EventType = 39
+	// EventTypeTransactionPayload contains compressed events of a whole
+	// transaction, written when binlog_transaction_compression is enabled.
+	// Used starting from MySQL 8.0.20.
+	EventTypeTransactionPayload EventType = 40
+	// EventTypeHeartbeatV2 is a heartbeat that supports positions beyond
+	// 4GB. Used starting from MySQL 8.0.26.
+	EventTypeHeartbeatV2 EventType = 41
+	// EventTypeGTIDTagged is a GTID event of a transaction with a tagged
+	// GTID. Used starting from MySQL 8.3.
+	EventTypeGTIDTagged EventType = 42
+
+	// MariaDB specific events
+	// Spec: https://mariadb.com/kb/en/replication-protocol/
+
+	// EventTypeMariaDBAnnotateRows contains the original query for the rows
+	// events that follow. Sent only when requested with a dump flag.
+	EventTypeMariaDBAnnotateRows EventType = 160
+	// EventTypeMariaDBBinlogCheckpoint is written when the binary log could be
+	// safely purged up to the given file.
+	EventTypeMariaDBBinlogCheckpoint EventType = 161
+	// EventTypeMariaDBGTID is written before each transaction and contains its
+	// MariaDB global transaction identifier.
+	EventTypeMariaDBGTID EventType = 162
+	// EventTypeMariaDBGTIDList is written at the beginning of each binary log
+	// and lists the last GTID of each replication domain.
+	EventTypeMariaDBGTIDList EventType = 163
+	// EventTypeMariaDBStartEncryption marks the beginning of an encrypted
+	// binary log.
+	EventTypeMariaDBStartEncryption EventType = 164
+	// EventTypeMariaDBQueryCompressed is a query event with compressed query,
+	// written when log_bin_compress is enabled.
+	EventTypeMariaDBQueryCompressed EventType = 165
+	// EventTypeMariaDBWriteRowsCompressedV1 is a WriteRowsEventV1 with
+	// compressed rows.
+	EventTypeMariaDBWriteRowsCompressedV1 EventType = 166
+	// EventTypeMariaDBUpdateRowsCompressedV1 is an UpdateRowsEventV1 with
+	// compressed rows.
+	EventTypeMariaDBUpdateRowsCompressedV1 EventType = 167
+	// EventTypeMariaDBDeleteRowsCompressedV1 is a DeleteRowsEventV1 with
+	// compressed rows.
+	EventTypeMariaDBDeleteRowsCompressedV1 EventType = 168
 )
 
 func (et EventType) String() string {
@@ -203,7 +257,45 @@ func (et EventType) String() string {
 		return "AnonymousGTIDEvent"
 	case EventTypePreviousGTIDs:
 		return "PreviousGTIDsEvent"
+	case EventTypeTransactionContext:
+		return "TransactionContextEvent"
+	case EventTypeViewChange:
+		return "ViewChangeEvent"
+	case EventTypeXAPrepare:
+		return "XAPrepareEvent"
+	case EventTypePartialUpdateRows:
+		return "PartialUpdateRowsEvent"
+	case EventTypeTransactionPayload:
+		return "TransactionPayloadEvent"
+	case EventTypeHeartbeatV2:
+		return "HeartbeatEventV2"
+	case EventTypeGTIDTagged:
+		return "GTIDTaggedEvent"
+	case EventTypeMariaDBAnnotateRows:
+		return "MariaDBAnnotateRowsEvent"
+	case EventTypeMariaDBBinlogCheckpoint:
+		return "MariaDBBinlogCheckpointEvent"
+	case EventTypeMariaDBGTID:
+		return "MariaDBGTIDEvent"
+	case EventTypeMariaDBGTIDList:
+		return "MariaDBGTIDListEvent"
+	case EventTypeMariaDBStartEncryption:
+		return "MariaDBStartEncryptionEvent"
+	case EventTypeMariaDBQueryCompressed:
+		return "MariaDBQueryCompressedEvent"
+	case EventTypeMariaDBWriteRowsCompressedV1:
+		return "MariaDBWriteRowsCompressedEventV1"
+	case EventTypeMariaDBUpdateRowsCompressedV1:
+		return "MariaDBUpdateRowsCompressedEventV1"
+	case EventTypeMariaDBDeleteRowsCompressedV1:
+		return "MariaDBDeleteRowsCompressedEventV1"
 	default:
 		return fmt.Sprintf("Unknown(%d)", et)
 	}
 }
+
+// Known returns true if the event type is defined in this package.
+func (et EventType) Known() bool {
+	return et > EventTypeUnknown && et <= EventTypeGTIDTagged ||
+		et >= EventTypeMariaDBAnnotateRows && et <= EventTypeMariaDBDeleteRowsCompressedV1
+}
@@ -1,6 +1,9 @@
 package binlog
 
-import "github.com/Vivino/bocadillo/mysql"
+import (
+	"github.com/Vivino/bocadillo/buffer"
+	"github.com/Vivino/bocadillo/mysql"
+)
 
 // XIDEvent contains an XID (XA transaction identifier)
 // https://dev.mysql.com/doc/refman/5.7/en/xa.html
@@ -10,6 +13,10 @@ type XIDEvent struct {
 
 // Decode decodes given buffer into an XID event.
 // Spec: https://dev.mysql.com/doc/internals/en/xid-event.html
-func (e *XIDEvent) Decode(connBuff []byte) {
+func (e *XIDEvent) Decode(connBuff []byte) error {
+	if len(connBuff) < 8 {
+		return buffer.ErrShortBuffer
+	}
 	e.XID = mysql.DecodeUint64(connBuff)
+	return nil
 }
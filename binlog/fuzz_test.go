@@ -0,0 +1,129 @@
+package binlog
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// Decoders must return errors for arbitrary input, never panic. Seed corpora
+// run as part of regular tests, run with -fuzz to explore further.
+
+var fuzzFormat = FormatDescription{Version: 4, ServerVersion: "8.0.36", ServerDetails: ServerDetails{Flavor: FlavorMySQL, Version: 80036}}
+
+var fuzzTableMap = []byte{
+	0x2A, 0, 0, 0, 0, 0, // Table ID
+	0x01, 0x00, // Flags
+	0x04, 's', 'h', 'o', 'p', 0x00, // Schema name
+	0x06, 'o', 'r', 'd', 'e', 'r', 's', 0x00, // Table name
+	0x03,                                                      // Column count
+	byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar), // Column types
+	byte(mysql.ColumnTypeLonglong),
+	0x02, 0x40, 0x00, // Column metadata
+	0x06,             // Null bitmask
+	0x01, 0x01, 0x40, // Signedness
+}
+
+func FuzzEventHeader(f *testing.F) {
+	f.Add(make([]byte, 19))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var h EventHeader
+		h.Decode(data, fuzzFormat)
+	})
+}
+
+func FuzzFormatDescriptionEvent(f *testing.F) {
+	f.Add(append([]byte{4, 0, '8', '.', '0', '.', '3', '6'}, make([]byte, 60)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e FormatDescriptionEvent
+		e.Decode(data)
+	})
+}
+
+func FuzzQueryEvent(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0, 's', 'h', 'o', 'p', 0, 'B', 'E', 'G', 'I', 'N'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e QueryEvent
+		e.Decode(data)
+	})
+}
+
+func FuzzRotateEvent(f *testing.F) {
+	f.Add([]byte{4, 0, 0, 0, 0, 0, 0, 0, 'm', 'y', 's', 'q', 'l', '-', 'b', 'i', 'n', '.', '0', '0', '0', '0', '0', '2'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e RotateEvent
+		e.Decode(data, fuzzFormat)
+	})
+}
+
+func FuzzXIDEvent(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e XIDEvent
+		e.Decode(data)
+	})
+}
+
+func FuzzGTIDEvent(f *testing.F) {
+	f.Add(append([]byte{1}, make([]byte, 41)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e GTIDEvent
+		e.Decode(data)
+		var me MariaDBGTIDEvent
+		me.Decode(data)
+	})
+}
+
+func FuzzMariaDBEvents(f *testing.F) {
+	f.Add([]byte{16, 0, 0, 0, 'm', 'y', 's', 'q', 'l', '-', 'b', 'i', 'n', '.', '0', '0', '0', '0', '0', '1'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var ce MariaDBBinlogCheckpointEvent
+		ce.Decode(data)
+		var ee MariaDBStartEncryptionEvent
+		ee.Decode(data)
+	})
+}
+
+func FuzzTableMapEvent(f *testing.F) {
+	f.Add(fuzzTableMap)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var e TableMapEvent
+		e.Decode(data, fuzzFormat)
+	})
+}
+
+func FuzzRowsEvent(f *testing.F) {
+	f.Add(fuzzTableMap, []byte{
+		0x2A, 0, 0, 0, 0, 0, // Table ID
+		0x01, 0x00, // Flags
+		0x02, 0x00, // Extra data length
+		0x03,       // Column count
+		0x07,       // Columns present
+		0x00,       // Null bitmap
+		1, 0, 0, 0, // id
+		2, 'o', 'k', // status
+		3, 0, 0, 0, 0, 0, 0, 0, // user
+	}, uint8(EventTypeWriteRowsV2))
+	f.Fuzz(func(t *testing.T, tableMap, data []byte, et uint8) {
+		var tme TableMapEvent
+		if err := tme.Decode(tableMap, fuzzFormat); err != nil {
+			return
+		}
+		e := RowsEvent{Type: EventType(et)}
+		e.Decode(data, fuzzFormat, tme.TableDescription)
+	})
+}
+
+func FuzzDecompress(f *testing.F) {
+	f.Add(uint8(EventTypeMariaDBQueryCompressed), []byte{0x01, 0x00})
+	f.Fuzz(func(t *testing.T, et uint8, data []byte) {
+		Decompress(EventType(et), data, fuzzFormat)
+	})
+}
+
+func FuzzParseGTIDSet(f *testing.F) {
+	f.Add("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,3e11fa47-71ca-11e1-9e33-c80aa9429563:1")
+	f.Fuzz(func(t *testing.T, s string) {
+		ParseGTIDSet(s)
+	})
+}
@@ -0,0 +1,403 @@
+package binlog
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Vivino/bocadillo/buffer"
+)
+
+// SID is a source identifier, the UUID of a server where transaction was
+// originally executed.
+type SID [16]byte
+
+// GTID is a global transaction identifier.
+type GTID struct {
+	SID SID
+	GNO uint64
+	// Tag is empty unless the transaction has a tagged GTID (MySQL 8.3+),
+	// e.g. "3e11fa47-71ca-11e1-9e33-c80aa9429562:domain:23".
+	Tag string
+}
+
+// TSID is a tagged source identifier. Transaction numbers of each tag of a
+// source are independent. Untagged transactions have an empty tag.
+type TSID struct {
+	SID SID
+	Tag string
+}
+
+// maxTagLen is the maximum length of a GTID tag.
+const maxTagLen = 32
+
+// GTIDInterval is a closed range of transaction numbers.
+type GTIDInterval struct {
+	Start uint64
+	End   uint64
+}
+
+// GTIDSet is a set of global transaction identifiers. Transaction numbers are
+// stored as sorted non-overlapping intervals grouped by tagged source
+// identifier.
+// Spec: https://dev.mysql.com/doc/refman/8.4/en/replication-gtids-concepts.html
+type GTIDSet map[TSID][]GTIDInterval
+
+var (
+	// ErrInvalidSID is returned when a source identifier cannot be parsed.
+	ErrInvalidSID = errors.New("Invalid source identifier")
+	// ErrInvalidGTIDSet is returned when a GTID set cannot be parsed.
+	ErrInvalidGTIDSet = errors.New("Invalid GTID set")
+	// ErrInvalidGTIDTag is returned when a GTID tag cannot be parsed.
+	ErrInvalidGTIDTag = errors.New("Invalid GTID tag")
+)
+
+// ParseSID parses a source identifier from its UUID representation.
+func ParseSID(s string) (SID, error) {
+	var sid SID
+	s = strings.Replace(s, "-", "", -1)
+	if len(s) != 32 {
+		return sid, ErrInvalidSID
+	}
+	if _, err := hex.Decode(sid[:], []byte(s)); err != nil {
+		return sid, ErrInvalidSID
+	}
+	return sid, nil
+}
+
+func (sid SID) String() string {
+	h := hex.EncodeToString(sid[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// ParseTag parses a GTID tag: a letter or an underscore followed by up to 31
+// letters, digits or underscores. Tags are case insensitive, they are returned
+// in lower case.
+func ParseTag(s string) (string, error) {
+	if len(s) == 0 || len(s) > maxTagLen {
+		return "", ErrInvalidGTIDTag
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return "", ErrInvalidGTIDTag
+		}
+	}
+	return strings.ToLower(s), nil
+}
+
+func (t TSID) String() string {
+	if t.Tag == "" {
+		return t.SID.String()
+	}
+	return t.SID.String() + ":" + t.Tag
+}
+
+// ParseGTID parses a global transaction identifier from its text
+// representation, e.g. "3e11fa47-71ca-11e1-9e33-c80aa9429562:23" or
+// "3e11fa47-71ca-11e1-9e33-c80aa9429562:domain:23".
+func ParseGTID(s string) (GTID, error) {
+	var gtid GTID
+	tokens := strings.Split(s, ":")
+	if len(tokens) != 2 && len(tokens) != 3 {
+		return gtid, ErrInvalidGTIDSet
+	}
+	sid, err := ParseSID(tokens[0])
+	if err != nil {
+		return gtid, err
+	}
+	var tag string
+	if len(tokens) == 3 {
+		if tag, err = ParseTag(tokens[1]); err != nil {
+			return gtid, err
+		}
+	}
+	gno, err := strconv.ParseUint(tokens[len(tokens)-1], 10, 64)
+	if err != nil || gno == 0 {
+		return gtid, ErrInvalidGTIDSet
+	}
+	return GTID{SID: sid, GNO: gno, Tag: tag}, nil
+}
+
+// TSID returns the tagged source identifier of the transaction.
+func (g GTID) TSID() TSID {
+	return TSID{SID: g.SID, Tag: g.Tag}
+}
+
+func (g GTID) String() string {
+	return g.TSID().String() + ":" + strconv.FormatUint(g.GNO, 10)
+}
+
+// NewGTIDSet creates a new empty GTID set.
+func NewGTIDSet() GTIDSet {
+	return make(GTIDSet)
+}
+
+// ParseGTIDSet parses a GTID set from its text representation, e.g.
+// "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11,
+// 4e11fa47-71ca-11e1-9e33-c80aa9429562:23". Intervals that follow a tag belong
+// to that tag: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:domain:1-3".
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := NewGTIDSet()
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return set, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		tokens := strings.Split(strings.TrimSpace(part), ":")
+		if len(tokens) < 2 {
+			return nil, ErrInvalidGTIDSet
+		}
+		sid, err := ParseSID(tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		tsid := TSID{SID: sid}
+		// Every tag must be followed by intervals
+		tagged := false
+		for _, tok := range tokens[1:] {
+			if tok != "" && (tok[0] < '0' || tok[0] > '9') {
+				if tagged {
+					return nil, ErrInvalidGTIDSet
+				}
+				if tsid.Tag, err = ParseTag(tok); err != nil {
+					return nil, ErrInvalidGTIDSet
+				}
+				tagged = true
+				continue
+			}
+			tagged = false
+			var iv GTIDInterval
+			bounds := strings.SplitN(tok, "-", 2)
+			if iv.Start, err = strconv.ParseUint(bounds[0], 10, 64); err != nil {
+				return nil, ErrInvalidGTIDSet
+			}
+			iv.End = iv.Start
+			if len(bounds) == 2 {
+				if iv.End, err = strconv.ParseUint(bounds[1], 10, 64); err != nil {
+					return nil, ErrInvalidGTIDSet
+				}
+			}
+			if iv.Start == 0 || iv.End < iv.Start {
+				return nil, ErrInvalidGTIDSet
+			}
+			set.AddTSIDInterval(tsid, iv)
+		}
+		if tagged {
+			return nil, ErrInvalidGTIDSet
+		}
+	}
+	return set, nil
+}
+
+// Contains returns true if the set contains given untagged transaction.
+func (s GTIDSet) Contains(sid SID, gno uint64) bool {
+	return s.ContainsGTID(GTID{SID: sid, GNO: gno})
+}
+
+// ContainsGTID returns true if the set contains given transaction.
+func (s GTIDSet) ContainsGTID(gtid GTID) bool {
+	ivs := s[gtid.TSID()]
+	i := sort.Search(len(ivs), func(i int) bool { return ivs[i].End >= gtid.GNO })
+	return i < len(ivs) && ivs[i].Start <= gtid.GNO
+}
+
+// Add adds given untagged transaction to the set.
+func (s GTIDSet) Add(sid SID, gno uint64) {
+	s.AddGTID(GTID{SID: sid, GNO: gno})
+}
+
+// AddGTID adds given transaction to the set.
+func (s GTIDSet) AddGTID(gtid GTID) {
+	s.AddTSIDInterval(gtid.TSID(), GTIDInterval{Start: gtid.GNO, End: gtid.GNO})
+}
+
+// AddInterval adds given range of untagged transactions to the set.
+func (s GTIDSet) AddInterval(sid SID, iv GTIDInterval) {
+	s.AddTSIDInterval(TSID{SID: sid}, iv)
+}
+
+// AddTSIDInterval adds given range of transactions to the set.
+func (s GTIDSet) AddTSIDInterval(tsid TSID, iv GTIDInterval) {
+	ivs := s[tsid]
+	i := sort.Search(len(ivs), func(i int) bool { return ivs[i].End+1 >= iv.Start })
+	// Merge all intervals that overlap or are adjacent to the new one
+	j := i
+	for j < len(ivs) && ivs[j].Start <= iv.End+1 {
+		if ivs[j].Start < iv.Start {
+			iv.Start = ivs[j].Start
+		}
+		if ivs[j].End > iv.End {
+			iv.End = ivs[j].End
+		}
+		j++
+	}
+	merged := make([]GTIDInterval, 0, len(ivs)-(j-i)+1)
+	merged = append(merged, ivs[:i]...)
+	merged = append(merged, iv)
+	merged = append(merged, ivs[j:]...)
+	s[tsid] = merged
+}
+
+// Gaps returns transactions missing between the lowest and the highest
+// transaction of every source, which usually indicate filtered or lost
+// transactions. Transactions preceding the lowest one are not reported.
+func (s GTIDSet) Gaps() GTIDSet {
+	gaps := NewGTIDSet()
+	for tsid, ivs := range s {
+		for i := 1; i < len(ivs); i++ {
+			gaps[tsid] = append(gaps[tsid], GTIDInterval{Start: ivs[i-1].End + 1, End: ivs[i].Start - 1})
+		}
+	}
+	return gaps
+}
+
+// Clone returns a copy of the set.
+func (s GTIDSet) Clone() GTIDSet {
+	c := make(GTIDSet, len(s))
+	for tsid, ivs := range s {
+		c[tsid] = append([]GTIDInterval(nil), ivs...)
+	}
+	return c
+}
+
+// String returns a text representation of the set. Source identifiers and
+// tags are sorted to make the output stable, untagged transactions of a
+// source come first.
+func (s GTIDSet) String() string {
+	var parts []string
+	var b strings.Builder
+	var last SID
+	for i, tsid := range s.tsids() {
+		if i == 0 || tsid.SID != last {
+			if i > 0 {
+				parts = append(parts, b.String())
+				b.Reset()
+			}
+			b.WriteString(tsid.SID.String())
+			last = tsid.SID
+		}
+		if tsid.Tag != "" {
+			b.WriteString(":" + tsid.Tag)
+		}
+		for _, iv := range s[tsid] {
+			if iv.Start == iv.End {
+				fmt.Fprintf(&b, ":%d", iv.Start)
+			} else {
+				fmt.Fprintf(&b, ":%d-%d", iv.Start, iv.End)
+			}
+		}
+	}
+	if b.Len() > 0 {
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// gtidFormatTagged marks the binary representation of sets with tagged GTIDs.
+const gtidFormatTagged = 1
+
+// Encode returns a binary representation of the set as it is used in
+// COM_BINLOG_DUMP_GTID command. Sets with tagged GTIDs are encoded in the
+// format introduced with tags, which older servers don't understand: the
+// number of sources is shifted by a byte and surrounded by format markers,
+// and every source identifier is followed by its tag.
+// Spec: https://dev.mysql.com/doc/internals/en/com-binlog-dump-gtid.html
+func (s GTIDSet) Encode() []byte {
+	tsids := s.tsids()
+	tagged := false
+	size := 8
+	for _, tsid := range tsids {
+		size += 16 + 8 + len(s[tsid])*16
+		if tsid.Tag != "" {
+			tagged = true
+		}
+	}
+	if tagged {
+		for _, tsid := range tsids {
+			size += varLenSize(uint64(len(tsid.Tag))) + len(tsid.Tag)
+		}
+	}
+
+	buf := buffer.New(make([]byte, size))
+	if tagged {
+		buf.WriteUint64(gtidFormatTagged<<56 | uint64(len(tsids))<<8 | gtidFormatTagged)
+	} else {
+		buf.WriteUint64(uint64(len(tsids)))
+	}
+	for _, tsid := range tsids {
+		buf.WriteBytes(tsid.SID[:])
+		if tagged {
+			buf.WriteBytes(appendVarLen(nil, uint64(len(tsid.Tag))))
+			buf.WriteBytes([]byte(tsid.Tag))
+		}
+		buf.WriteUint64(uint64(len(s[tsid])))
+		for _, iv := range s[tsid] {
+			// Interval end is exclusive in binary representation
+			buf.WriteUint64(iv.Start)
+			buf.WriteUint64(iv.End + 1)
+		}
+	}
+	return buf.Bytes()
+}
+
+// DecodeGTIDSet decodes a GTID set from its binary representation produced by
+// Encode.
+func DecodeGTIDSet(data []byte) (GTIDSet, error) {
+	set := NewGTIDSet()
+	buf := buffer.NewChecked(data)
+	n := buf.ReadUint64()
+	tagged := n&0xFF == gtidFormatTagged && n>>56 == gtidFormatTagged
+	if tagged {
+		n = n >> 8 & (1<<48 - 1)
+	}
+	for i := uint64(0); i < n && buf.Err() == nil; i++ {
+		var tsid TSID
+		copy(tsid.SID[:], buf.Read(16))
+		if tagged {
+			l := readVarLen(buf)
+			if l > maxTagLen {
+				return nil, ErrInvalidGTIDSet
+			}
+			tsid.Tag = string(buf.Read(int(l)))
+		}
+		nivs := buf.ReadUint64()
+		for j := uint64(0); j < nivs && buf.Err() == nil; j++ {
+			// Interval end is exclusive
+			iv := GTIDInterval{Start: buf.ReadUint64(), End: buf.ReadUint64() - 1}
+			if buf.Err() == nil && (iv.Start == 0 || iv.End < iv.Start) {
+				return nil, ErrInvalidGTIDSet
+			}
+			set.AddTSIDInterval(tsid, iv)
+		}
+	}
+	if buf.Err() != nil {
+		return nil, ErrInvalidGTIDSet
+	}
+	return set, nil
+}
+
+// tsids returns tagged source identifiers of the set that have transactions,
+// sorted by source identifier and tag.
+func (s GTIDSet) tsids() []TSID {
+	tsids := make([]TSID, 0, len(s))
+	for tsid, ivs := range s {
+		if len(ivs) > 0 {
+			tsids = append(tsids, tsid)
+		}
+	}
+	sort.Slice(tsids, func(i, j int) bool {
+		if tsids[i].SID != tsids[j].SID {
+			return string(tsids[i].SID[:]) < string(tsids[j].SID[:])
+		}
+		return tsids[i].Tag < tsids[j].Tag
+	})
+	return tsids
+}
@@ -0,0 +1,162 @@
+package binlog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Vivino/bocadillo/buffer"
+)
+
+func TestGTIDSetParse(t *testing.T) {
+	inputs := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:6-7:11", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7:11"},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:11:1-3:2-12", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-12"},
+		{
+			"4e11fa47-71ca-11e1-9e33-c80aa9429562:23,\n3e11fa47-71ca-11e1-9e33-c80aa9429562:1",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1,4e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+		},
+		// Tagged transactions follow untagged ones, tags are sorted
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:Domain_2:1-3:domain_1:7:5,3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:domain_1:5:7:domain_2:1-3",
+		},
+	}
+	for _, in := range inputs {
+		set, err := ParseGTIDSet(in.in)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", in.in, err)
+			continue
+		}
+		if out := set.String(); out != in.out {
+			t.Errorf("Expected %q to be formatted as %q, got %q", in.in, in.out, out)
+		}
+	}
+
+	for _, in := range []string{
+		"foo",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1",
+		"3e11fa47:1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:tag",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:tag:other:1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:tag-1:1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:a23456789012345678901234567890123:1",
+	} {
+		if _, err := ParseGTIDSet(in); err == nil {
+			t.Errorf("Expected %q to fail parsing", in)
+		}
+	}
+}
+
+func TestGTIDSetContains(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, _ := ParseSID("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	other, _ := ParseSID("4e11fa47-71ca-11e1-9e33-c80aa9429562")
+	for gno, exp := range map[uint64]bool{1: true, 5: true, 6: false, 10: false, 11: true, 12: false} {
+		if set.Contains(sid, gno) != exp {
+			t.Errorf("Expected Contains(%d) to be %v", gno, exp)
+		}
+	}
+	if set.Contains(other, 1) {
+		t.Error("Expected other source to not be contained")
+	}
+
+	set.Add(sid, 6)
+	set.Add(other, 1)
+	if exp := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6:11,4e11fa47-71ca-11e1-9e33-c80aa9429562:1"; set.String() != exp {
+		t.Errorf("Expected %q, got %q", exp, set.String())
+	}
+	if exp := "3e11fa47-71ca-11e1-9e33-c80aa9429562:7-10"; set.Gaps().String() != exp {
+		t.Errorf("Expected gaps %q, got %q", exp, set.Gaps().String())
+	}
+}
+
+func TestGTIDSetEncode(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{
+		1, 0, 0, 0, 0, 0, 0, 0, // Number of SIDs
+		0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62,
+		1, 0, 0, 0, 0, 0, 0, 0, // Number of intervals
+		1, 0, 0, 0, 0, 0, 0, 0, // Start
+		6, 0, 0, 0, 0, 0, 0, 0, // End (exclusive)
+	}
+	if out := set.Encode(); !bytes.Equal(exp, out) {
+		t.Errorf("Expected %x, got %x", exp, out)
+	}
+}
+
+func TestParseGTID(t *testing.T) {
+	for in, out := range map[string]string{
+		"3E11FA47-71CA-11E1-9E33-C80AA9429562:23":     "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:_Tag:1": "3e11fa47-71ca-11e1-9e33-c80aa9429562:_tag:1",
+	} {
+		gtid, err := ParseGTID(in)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", in, err)
+			continue
+		}
+		if gtid.String() != out {
+			t.Errorf("Expected %q to be formatted as %q, got %q", in, out, gtid.String())
+		}
+	}
+	for _, in := range []string{"3e11fa47-71ca-11e1-9e33-c80aa9429562", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1tag:1", "3e11fa47-71ca-11e1-9e33-c80aa9429562:a:b:1"} {
+		if _, err := ParseGTID(in); err == nil {
+			t.Errorf("Expected %q to fail parsing", in)
+		}
+	}
+}
+
+func TestGTIDSetTagged(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:tag:1-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, _ := ParseGTID("3e11fa47-71ca-11e1-9e33-c80aa9429562:tag:3")
+	if set.ContainsGTID(tagged) {
+		t.Errorf("Expected %s to not be contained", tagged)
+	}
+	set.AddGTID(tagged)
+	if !set.ContainsGTID(tagged) || !set.Contains(tagged.SID, 5) || set.Contains(tagged.SID, 6) {
+		t.Errorf("Unexpected set %s", set)
+	}
+
+	// Tagged sets use the new binary format, untagged sets are encoded as
+	// before, see TestGTIDSetEncode
+	enc := set.Encode()
+	if enc[0] != 1 || enc[1] != 2 || enc[7] != 1 {
+		t.Errorf("Expected tagged format with two sources, got %x", enc[:8])
+	}
+	dec, err := DecodeGTIDSet(enc)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if dec.String() != set.String() {
+		t.Errorf("Expected %q, got %q", set.String(), dec.String())
+	}
+	if _, err := DecodeGTIDSet(enc[:len(enc)-1]); err == nil {
+		t.Error("Expected truncated set to fail decoding")
+	}
+}
+
+func TestVarLen(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 1<<56 - 1, 1 << 56, 1<<64 - 1} {
+		b := appendVarLen(nil, v)
+		if len(b) != varLenSize(v) {
+			t.Errorf("Expected %d to take %d bytes, got %d", v, varLenSize(v), len(b))
+		}
+		buf := buffer.NewChecked(b)
+		if out := readVarLen(buf); out != v || buf.Err() != nil || len(buf.Cur()) != 0 {
+			t.Errorf("Expected %d, got %d (%v)", v, out, buf.Err())
+		}
+	}
+}
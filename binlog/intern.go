@@ -0,0 +1,36 @@
+package binlog
+
+import (
+	"sync"
+)
+
+// maxInternedNames limits the number of interned schema and table names.
+// Names are allocated as usual once the limit is reached.
+const maxInternedNames = 100000
+
+// names contains interned schema and table names, so that table descriptions
+// of the same table share strings instead of allocating new ones for every
+// table map event.
+var names = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// internName returns a string equal to b, reusing a previously interned one
+// when possible.
+func internName(b []byte) string {
+	names.RLock()
+	s, ok := names.m[string(b)]
+	names.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = string(b)
+	names.Lock()
+	if len(names.m) < maxInternedNames {
+		names.m[s] = s
+	}
+	names.Unlock()
+	return s
+}
@@ -0,0 +1,22 @@
+package binlog
+
+import (
+	"testing"
+)
+
+func TestInternName(t *testing.T) {
+	s1 := internName([]byte("users"))
+	s2 := internName([]byte("users"))
+	if s1 != "users" {
+		t.Fatalf("Expected %q, got %q", "users", s1)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		internName([]byte("users"))
+	})
+	if allocs != 0 {
+		t.Errorf("Expected interned name lookup not to allocate, got %v allocations", allocs)
+	}
+	if s1 != s2 {
+		t.Errorf("Expected equal names, got %q and %q", s1, s2)
+	}
+}
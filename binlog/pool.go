@@ -0,0 +1,74 @@
+package binlog
+
+import (
+	"sync"
+)
+
+// rowStorage holds memory used by decoded rows. It could be reused once the
+// rows event is released.
+type rowStorage struct {
+	rows   [][]interface{}
+	values []interface{}
+	// nullBitmap is a scratch buffer for the null bitmap of a row
+	nullBitmap []byte
+}
+
+var rowStoragePool = sync.Pool{
+	New: func() interface{} { return &rowStorage{} },
+}
+
+// minRowStorageRows is the number of rows the storage is allocated for
+// initially.
+const minRowStorageRows = 16
+
+// newRow returns a slice of n values. Rows share a single backing array which
+// is replaced with a larger one once it is exhausted.
+func (s *rowStorage) newRow(n int) []interface{} {
+	if len(s.values)+n > cap(s.values) {
+		size := 2 * cap(s.values)
+		if size < n*minRowStorageRows {
+			size = n * minRowStorageRows
+		}
+		s.values = make([]interface{}, 0, size)
+	}
+	off := len(s.values)
+	s.values = s.values[:off+n]
+	return s.values[off : off+n : off+n]
+}
+
+// grow makes sure the storage has enough capacity for the given number of
+// rows of n values.
+func (s *rowStorage) grow(rows, n int) {
+	if cap(s.rows) < rows {
+		s.rows = make([][]interface{}, 0, rows)
+	}
+	if cap(s.values) < rows*n {
+		s.values = make([]interface{}, 0, rows*n)
+	}
+}
+
+// reset clears references to decoded values so that they could be collected
+// and the storage could be reused.
+func (s *rowStorage) reset() {
+	for i := range s.values {
+		s.values[i] = nil
+	}
+	for i := range s.rows {
+		s.rows[i] = nil
+	}
+	s.values = s.values[:0]
+	s.rows = s.rows[:0]
+}
+
+// Release returns memory used by decoded rows to a pool so that it could be
+// reused by subsequent events. Rows must not be used after the event is
+// released. Calling Release is optional.
+func (e *RowsEvent) Release() {
+	if e.storage == nil {
+		return
+	}
+	e.storage.reset()
+	rowStoragePool.Put(e.storage)
+	e.storage = nil
+	e.Rows = nil
+}
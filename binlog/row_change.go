@@ -0,0 +1,93 @@
+package binlog
+
+// ChangeType is the type of a row change.
+type ChangeType byte
+
+// Row change types.
+const (
+	ChangeInsert ChangeType = iota + 1
+	ChangeUpdate
+	ChangeDelete
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// RowChange is a change of a single row of a rows event.
+type RowChange struct {
+	Type ChangeType
+	// Before is the row image before the change, it's nil for inserts.
+	Before []interface{}
+	// After is the row image after the change, it's nil for deletes.
+	After []interface{}
+	// BeforeColumns and AfterColumns are bitmaps of columns present in the
+	// row images. Images only contain some of the columns unless the server
+	// logs full row images (binlog_row_image=FULL), values of absent columns
+	// are nil just like NULL values.
+	BeforeColumns []byte
+	AfterColumns  []byte
+}
+
+// BeforeHas returns true if the i-th column is present in the image before
+// the change. All columns are present if the bitmap is not set.
+func (c RowChange) BeforeHas(i int) bool {
+	return hasColumn(c.BeforeColumns, i)
+}
+
+// AfterHas returns true if the i-th column is present in the image after the
+// change. All columns are present if the bitmap is not set.
+func (c RowChange) AfterHas(i int) bool {
+	return hasColumn(c.AfterColumns, i)
+}
+
+func hasColumn(bm []byte, i int) bool {
+	if bm == nil {
+		return true
+	}
+	return i>>3 < len(bm) && isBitSet(bm, i)
+}
+
+// Changes splits a decoded rows event into changes of individual rows. Rows
+// of update events go in pairs of images before and after the update. Nil is
+// returned for events of other types. Bitmaps reference the event buffer, so
+// they are only valid as long as the rows are.
+func (e *RowsEvent) Changes() []RowChange {
+	switch e.Type {
+	case EventTypeWriteRowsV0, EventTypeWriteRowsV1, EventTypeWriteRowsV2:
+		changes := make([]RowChange, len(e.Rows))
+		for i, row := range e.Rows {
+			changes[i] = RowChange{Type: ChangeInsert, After: row, AfterColumns: e.ColumnBitmap1}
+		}
+		return changes
+	case EventTypeUpdateRowsV0, EventTypeUpdateRowsV1, EventTypeUpdateRowsV2:
+		changes := make([]RowChange, 0, len(e.Rows)/2)
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			changes = append(changes, RowChange{
+				Type:          ChangeUpdate,
+				Before:        e.Rows[i],
+				After:         e.Rows[i+1],
+				BeforeColumns: e.ColumnBitmap1,
+				AfterColumns:  e.ColumnBitmap2,
+			})
+		}
+		return changes
+	case EventTypeDeleteRowsV0, EventTypeDeleteRowsV1, EventTypeDeleteRowsV2:
+		changes := make([]RowChange, len(e.Rows))
+		for i, row := range e.Rows {
+			changes[i] = RowChange{Type: ChangeDelete, Before: row, BeforeColumns: e.ColumnBitmap1}
+		}
+		return changes
+	default:
+		return nil
+	}
+}
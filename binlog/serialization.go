@@ -0,0 +1,61 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/Vivino/bocadillo/buffer"
+)
+
+// Events introduced in MySQL 8.3, such as tagged GTID events, are serialized
+// as messages of numbered fields. Integers are encoded with a variable length:
+// the number of trailing one bits of the first byte is the number of bytes
+// that follow, the value is stored in the remaining bits in little endian
+// order. Values that need more than 8 bytes start with 0xFF and occupy the
+// next 8 bytes. Signed values are zigzag encoded.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/PageLibsMysqlSerialization.html
+
+// varLenSize returns the number of bytes a variable length integer takes.
+func varLenSize(v uint64) int {
+	n := (bits.Len64(v) + 6) / 7
+	switch {
+	case n == 0:
+		return 1
+	case n > 8:
+		return 9
+	default:
+		return n
+	}
+}
+
+// appendVarLen appends a variable length unsigned integer to the slice.
+func appendVarLen(b []byte, v uint64) []byte {
+	var data [8]byte
+	n := varLenSize(v)
+	if n == 9 {
+		binary.LittleEndian.PutUint64(data[:], v)
+		return append(append(b, 0xFF), data[:]...)
+	}
+	binary.LittleEndian.PutUint64(data[:], v<<uint(n)|(1<<uint(n-1)-1))
+	return append(b, data[:n]...)
+}
+
+// readVarLen reads a variable length unsigned integer. Errors are retained by
+// the buffer, which must be checked.
+func readVarLen(buf *buffer.Buffer) uint64 {
+	first := buf.Peek(1)[0]
+	n := bits.TrailingZeros8(^first) + 1
+	if n == 9 {
+		buf.Skip(1)
+		return binary.LittleEndian.Uint64(buf.Read(8))
+	}
+	var data [8]byte
+	copy(data[:], buf.Read(n))
+	return binary.LittleEndian.Uint64(data[:]) >> uint(n)
+}
+
+// readVarLenSigned reads a variable length signed integer.
+func readVarLenSigned(buf *buffer.Buffer) int64 {
+	v := readVarLen(buf)
+	return int64(v>>1) ^ -int64(v&1)
+}
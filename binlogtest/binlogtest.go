@@ -0,0 +1,468 @@
+// Package binlogtest builds binary log events for tests, so that code
+// consuming the binary log could be tested without a running MySQL server.
+// Events are encoded the way MySQL 5.7 writes them: v4 headers, row events of
+// version 2 and CRC32 checksums unless disabled.
+//
+// Example:
+//
+//	g := binlogtest.New()
+//	t := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+//		{Name: "id", Type: mysql.ColumnTypeLong},
+//		{Name: "status", Type: mysql.ColumnTypeVarchar},
+//	}}
+//	g.FormatDescription()
+//	g.TableMap(t)
+//	g.Insert(t, []interface{}{1, "new"})
+//	g.XID(1)
+//	ioutil.WriteFile("mysql-bin.000001", g.Bytes(), 0644)
+package binlogtest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// headerLen is the length of a v4 event header.
+const headerLen = 19
+
+// postHeaderLens contains post-header lengths of event types written by MySQL
+// 5.7, indexed by event type minus one.
+var postHeaderLens = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 0, 0, 0,
+}
+
+// Generator builds a sequence of events. Every event starts at the position
+// the previous one ended at. Events are returned by the methods that build
+// them and are also collected so that a whole binary log file could be
+// produced with Bytes.
+type Generator struct {
+	// ServerID is set in headers of following events.
+	ServerID uint32
+	// Timestamp is set in headers of following events.
+	Timestamp uint32
+	// ServerVersion is reported by format description events.
+	ServerVersion string
+	// Checksum makes events end with a CRC32 checksum. It should be changed
+	// before the format description event is built.
+	Checksum bool
+
+	file   string
+	offset uint32
+	events []byte
+}
+
+// New creates a generator of events for the mysql-bin.000001 file.
+func New() *Generator {
+	return &Generator{
+		ServerID:      1,
+		ServerVersion: "5.7.19-log",
+		Checksum:      true,
+		file:          "mysql-bin.000001",
+		offset:        uint32(len(binlog.FileMagic)),
+	}
+}
+
+// Position returns the position of the next event.
+func (g *Generator) Position() binlog.Position {
+	return binlog.Position{File: g.file, Offset: uint64(g.offset)}
+}
+
+// Format returns the format description of generated events.
+func (g *Generator) Format() binlog.FormatDescription {
+	fd := binlog.FormatDescription{
+		Version:                4,
+		ServerVersion:          g.ServerVersion,
+		EventHeaderLength:      headerLen,
+		EventTypeHeaderLengths: postHeaderLens,
+		ServerDetails:          binlog.ParseServerDetails(g.ServerVersion),
+	}
+	fd.ServerDetails.ChecksumAlgorithm = binlog.ChecksumAlgorithmNone
+	if g.Checksum {
+		fd.ServerDetails.ChecksumAlgorithm = binlog.ChecksumAlgorithmCRC32
+	}
+	return fd
+}
+
+// Bytes returns contents of the current binary log file: the magic header
+// followed by events built since the generator was created or rotated.
+func (g *Generator) Bytes() []byte {
+	return append(append([]byte(nil), binlog.FileMagic...), g.events...)
+}
+
+// FormatDescription builds a format description event.
+func (g *Generator) FormatDescription() []byte {
+	body := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:52], g.ServerVersion)
+	binary.LittleEndian.PutUint32(body[52:], g.Timestamp)
+	body[56] = headerLen
+	body = append(body, postHeaderLens...)
+	if g.Checksum {
+		body = append(body, byte(binlog.ChecksumAlgorithmCRC32))
+	} else {
+		body = append(body, byte(binlog.ChecksumAlgorithmNone))
+	}
+	return g.event(binlog.EventTypeFormatDescription, body)
+}
+
+// Rotate builds a rotate event pointing to the beginning of the next file.
+// Following events belong to the next file, Bytes only returns them.
+func (g *Generator) Rotate(next string) []byte {
+	body := make([]byte, 8, 8+len(next))
+	binary.LittleEndian.PutUint64(body, uint64(len(binlog.FileMagic)))
+	body = append(body, next...)
+	evt := g.event(binlog.EventTypeRotate, body)
+	g.file = next
+	g.offset = uint32(len(binlog.FileMagic))
+	g.events = nil
+	return evt
+}
+
+// Query builds a query event.
+func (g *Generator) Query(schema, query string) []byte {
+	body := make([]byte, 13, 13+len(schema)+1+len(query))
+	body[8] = byte(len(schema))
+	body = append(body, schema...)
+	body = append(body, 0)
+	body = append(body, query...)
+	return g.event(binlog.EventTypeQuery, body)
+}
+
+// MariaDBQueryCompressed builds a MariaDB query event with the query
+// compressed with zlib.
+func (g *Generator) MariaDBQueryCompressed(schema, query string) []byte {
+	body := make([]byte, 13, 13+len(schema)+1+len(query))
+	body[8] = byte(len(schema))
+	body = append(body, schema...)
+	body = append(body, 0)
+	// Compressed values start with the length of the big endian uncompressed
+	// length in the low bits of the first byte
+	var b bytes.Buffer
+	b.Write([]byte{0x84, 0, 0, 0, 0})
+	binary.BigEndian.PutUint32(b.Bytes()[1:], uint32(len(query)))
+	zw := zlib.NewWriter(&b)
+	zw.Write([]byte(query))
+	zw.Close()
+	body = append(body, b.Bytes()...)
+	return g.event(binlog.EventTypeMariaDBQueryCompressed, body)
+}
+
+// MariaDBBinlogCheckpoint builds a MariaDB binlog checkpoint event.
+func (g *Generator) MariaDBBinlogCheckpoint(file string) []byte {
+	body := make([]byte, 4, 4+len(file))
+	binary.LittleEndian.PutUint32(body, uint32(len(file)))
+	body = append(body, file...)
+	return g.event(binlog.EventTypeMariaDBBinlogCheckpoint, body)
+}
+
+// MariaDBStartEncryption builds a MariaDB start encryption event.
+func (g *Generator) MariaDBStartEncryption(keyVersion uint32, nonce [12]byte) []byte {
+	body := make([]byte, 5, 5+len(nonce))
+	body[0] = 1
+	binary.LittleEndian.PutUint32(body[1:], keyVersion)
+	body = append(body, nonce[:]...)
+	return g.event(binlog.EventTypeMariaDBStartEncryption, body)
+}
+
+// GTID builds a GTID event of a transaction.
+func (g *Generator) GTID(gtid binlog.GTID) []byte {
+	return g.GTIDClock(gtid, 0, 0)
+}
+
+// GTIDClock builds a GTID event with the given logical clock.
+func (g *Generator) GTIDClock(gtid binlog.GTID, lastCommitted, sequenceNumber int64) []byte {
+	body := make([]byte, 1+16+8+1+8+8)
+	body[0] = 1 // Commit flag
+	copy(body[1:], gtid.SID[:])
+	binary.LittleEndian.PutUint64(body[17:], gtid.GNO)
+	body[25] = 2 // Logical timestamp type code
+	binary.LittleEndian.PutUint64(body[26:], uint64(lastCommitted))
+	binary.LittleEndian.PutUint64(body[34:], uint64(sequenceNumber))
+	return g.event(binlog.EventTypeGTID, body)
+}
+
+// GTIDCommit builds a GTID event the way MySQL 8.0 writes it, with commit
+// timestamps in microseconds since Unix epoch. The original timestamp is
+// omitted if it's equal to the immediate one.
+func (g *Generator) GTIDCommit(gtid binlog.GTID, immediate, original uint64) []byte {
+	body := make([]byte, 1+16+8+1+8+8, 1+16+8+1+8+8+7+7)
+	body[0] = 1 // Commit flag
+	copy(body[1:], gtid.SID[:])
+	binary.LittleEndian.PutUint64(body[17:], gtid.GNO)
+	body[25] = 2 // Logical timestamp type code
+	if original == immediate {
+		body = appendUint56(body, immediate)
+	} else {
+		body = appendUint56(body, immediate|1<<55)
+		body = appendUint56(body, original)
+	}
+	body = appendUintLenEnc(body, 0) // Transaction length
+	return g.event(binlog.EventTypeGTID, body)
+}
+
+// GTIDTagged builds a tagged GTID event (MySQL 8.3+) with the given logical
+// clock. Tagged events are written for all transactions once a server has
+// seen a tagged GTID, so the tag may be empty.
+func (g *Generator) GTIDTagged(gtid binlog.GTID, lastCommitted, sequenceNumber int64) []byte {
+	var fields []byte
+	fields = append(appendVarLen(fields, 0), 1) // Commit flag
+	fields = append(appendVarLen(fields, 1), gtid.SID[:]...)
+	fields = appendVarLen(appendVarLen(fields, 2), zigzag(int64(gtid.GNO)))
+	fields = appendVarLen(appendVarLen(fields, 3), uint64(len(gtid.Tag)))
+	fields = append(fields, gtid.Tag...)
+	fields = appendVarLen(appendVarLen(fields, 4), zigzag(lastCommitted))
+	fields = appendVarLen(appendVarLen(fields, 5), zigzag(sequenceNumber))
+	body := appendVarLen(nil, 1) // Format version
+	body = appendVarLen(body, uint64(len(fields)+3))
+	body = appendVarLen(body, 0) // Last non-ignorable field
+	body = append(body, fields...)
+	return g.event(binlog.EventTypeGTIDTagged, body)
+}
+
+// XID builds an XID event that commits a transaction.
+func (g *Generator) XID(xid uint64) []byte {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint64(body, xid)
+	return g.event(binlog.EventTypeXID, body)
+}
+
+// TableMap builds a table map event.
+func (g *Generator) TableMap(t Table) []byte {
+	body := appendUint48(nil, t.ID)
+	body = append(body, 0, 0) // Flags
+	body = append(body, byte(len(t.Schema)))
+	body = append(body, t.Schema...)
+	body = append(body, 0, byte(len(t.Name)))
+	body = append(body, t.Name...)
+	body = append(body, 0)
+	body = appendUintLenEnc(body, uint64(len(t.Columns)))
+	td := t.Description()
+	body = append(body, td.ColumnTypes...)
+	meta := appendColumnMeta(nil, td.ColumnTypes, td.ColumnMeta)
+	body = appendUintLenEnc(body, uint64(len(meta)))
+	body = append(body, meta...)
+	body = append(body, td.NullBitmask...)
+	if t.FullMetadata {
+		body = appendOptionalMeta(body, t)
+	}
+	return g.event(binlog.EventTypeTableMap, body)
+}
+
+// Insert builds a write rows event.
+func (g *Generator) Insert(t Table, rows ...[]interface{}) ([]byte, error) {
+	return g.rows(binlog.EventTypeWriteRowsV2, t, rows)
+}
+
+// Update builds an update rows event. Rows go in pairs of images before and
+// after the update.
+func (g *Generator) Update(t Table, rows ...[]interface{}) ([]byte, error) {
+	return g.rows(binlog.EventTypeUpdateRowsV2, t, rows)
+}
+
+// Delete builds a delete rows event.
+func (g *Generator) Delete(t Table, rows ...[]interface{}) ([]byte, error) {
+	return g.rows(binlog.EventTypeDeleteRowsV2, t, rows)
+}
+
+func (g *Generator) rows(et binlog.EventType, t Table, rows [][]interface{}) ([]byte, error) {
+	n := len(t.Columns)
+	body := appendUint48(nil, t.ID)
+	body = append(body, byte(binlog.RowsFlagEndOfStatement), 0)
+	body = append(body, 2, 0) // Extra data length, including itself
+	body = appendUintLenEnc(body, uint64(n))
+	bitmap := make([]byte, (n+7)/8)
+	for i := range bitmap {
+		bitmap[i] = 0xFF
+	}
+	body = append(body, bitmap...)
+	if binlog.RowsEventHasSecondBitmap(et) {
+		body = append(body, bitmap...)
+	}
+
+	for _, row := range rows {
+		var err error
+		if body, err = t.appendRow(body, row); err != nil {
+			return nil, err
+		}
+	}
+	return g.event(et, body), nil
+}
+
+// event builds an event with given body and advances the position.
+func (g *Generator) event(et binlog.EventType, body []byte) []byte {
+	// Format description events are checksummed even when checksums are
+	// disabled
+	checksum := g.Checksum || et == binlog.EventTypeFormatDescription
+	n := headerLen + len(body)
+	if checksum {
+		n += 4
+	}
+	evt := make([]byte, headerLen, n)
+	binary.LittleEndian.PutUint32(evt, g.Timestamp)
+	evt[4] = byte(et)
+	binary.LittleEndian.PutUint32(evt[5:], g.ServerID)
+	binary.LittleEndian.PutUint32(evt[9:], uint32(n))
+	binary.LittleEndian.PutUint32(evt[13:], g.offset+uint32(n))
+	evt = append(evt, body...)
+	if checksum {
+		var sum [4]byte
+		binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(evt))
+		evt = append(evt, sum[:]...)
+	}
+	g.offset += uint32(n)
+	g.events = append(g.events, evt...)
+	return evt
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:6]...)
+}
+
+func appendUint56(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:7]...)
+}
+
+// appendUintLenEnc appends a length encoded integer.
+func appendUintLenEnc(b []byte, v uint64) []byte {
+	var buf [9]byte
+	n := mysql.EncodeUintLenEnc(buf[:], v, false)
+	return append(b, buf[:n]...)
+}
+
+// appendVarLen appends a variable length integer of the serialization format
+// of events introduced in MySQL 8.3.
+func appendVarLen(b []byte, v uint64) []byte {
+	n := (bits.Len64(v) + 6) / 7
+	if n == 0 {
+		n = 1
+	}
+	var buf [8]byte
+	if n > 8 {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		return append(append(b, 0xFF), buf[:]...)
+	}
+	binary.LittleEndian.PutUint64(buf[:], v<<uint(n)|(1<<uint(n-1)-1))
+	return append(b, buf[:n]...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// appendColumnMeta appends column metadata of a table map event.
+func appendColumnMeta(b []byte, types []byte, meta []uint16) []byte {
+	for i, typ := range types {
+		switch mysql.ColumnType(typ) {
+		case mysql.ColumnTypeString, mysql.ColumnTypeNewDecimal:
+			b = append(b, byte(meta[i]>>8), byte(meta[i]))
+		case mysql.ColumnTypeVarchar, mysql.ColumnTypeVarstring, mysql.ColumnTypeBit:
+			b = append(b, byte(meta[i]), byte(meta[i]>>8))
+		case mysql.ColumnTypeFloat, mysql.ColumnTypeDouble, mysql.ColumnTypeBlob,
+			mysql.ColumnTypeGeometry, mysql.ColumnTypeJSON, mysql.ColumnTypeTime2,
+			mysql.ColumnTypeDatetime2, mysql.ColumnTypeTimestamp2:
+			b = append(b, byte(meta[i]))
+		}
+	}
+	return b
+}
+
+// Optional metadata types.
+const (
+	tableMetaSignedness       = 1
+	tableMetaColumnName       = 4
+	tableMetaSetStrValue      = 5
+	tableMetaEnumStrValue     = 6
+	tableMetaSimplePrimaryKey = 8
+)
+
+// appendOptionalMeta appends optional metadata logged with
+// binlog_row_metadata=FULL: signedness, column names, enum and set members
+// and the primary key.
+func appendOptionalMeta(b []byte, t Table) []byte {
+	var signedness []byte
+	n := 0
+	for _, c := range t.Columns {
+		if !isNumeric(c.Type) {
+			continue
+		}
+		if n%8 == 0 {
+			signedness = append(signedness, 0)
+		}
+		if c.Unsigned {
+			// Most significant bit first
+			signedness[n/8] |= 0x80 >> uint(n%8)
+		}
+		n++
+	}
+	if len(signedness) > 0 {
+		b = appendMetaField(b, tableMetaSignedness, signedness)
+	}
+
+	var names []byte
+	for _, c := range t.Columns {
+		names = appendUintLenEnc(names, uint64(len(c.Name)))
+		names = append(names, c.Name...)
+	}
+	b = appendMetaField(b, tableMetaColumnName, names)
+	b = appendMembers(b, tableMetaSetStrValue, mysql.ColumnTypeSet, t.Columns)
+	b = appendMembers(b, tableMetaEnumStrValue, mysql.ColumnTypeEnum, t.Columns)
+
+	if len(t.PrimaryKey) > 0 {
+		var pk []byte
+		for _, idx := range t.PrimaryKey {
+			pk = appendUintLenEnc(pk, uint64(idx))
+		}
+		b = appendMetaField(b, tableMetaSimplePrimaryKey, pk)
+	}
+	return b
+}
+
+// appendMembers appends members of all columns of the given type, the field is
+// omitted if there are no such columns.
+func appendMembers(b []byte, typ byte, ct mysql.ColumnType, cols []Column) []byte {
+	var val []byte
+	found := false
+	for _, c := range cols {
+		if c.Type != ct {
+			continue
+		}
+		found = true
+		val = appendUintLenEnc(val, uint64(len(c.Values)))
+		for _, v := range c.Values {
+			val = appendUintLenEnc(val, uint64(len(v)))
+			val = append(val, v...)
+		}
+	}
+	if !found {
+		return b
+	}
+	return appendMetaField(b, typ, val)
+}
+
+func appendMetaField(b []byte, typ byte, val []byte) []byte {
+	b = append(b, typ)
+	b = appendUintLenEnc(b, uint64(len(val)))
+	return append(b, val...)
+}
+
+func isNumeric(ct mysql.ColumnType) bool {
+	switch ct {
+	case mysql.ColumnTypeTiny, mysql.ColumnTypeShort, mysql.ColumnTypeInt24,
+		mysql.ColumnTypeLong, mysql.ColumnTypeLonglong, mysql.ColumnTypeFloat,
+		mysql.ColumnTypeDouble, mysql.ColumnTypeNewDecimal:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,144 @@
+package binlogtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRowsRoundTrip(t *testing.T) {
+	ts := time.Date(2019, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	tests := []struct {
+		col Column
+		in  interface{}
+		out interface{}
+	}{
+		{Column{Type: mysql.ColumnTypeTiny}, -1, uint8(0xFF)},
+		{Column{Type: mysql.ColumnTypeShort}, 1000, uint16(1000)},
+		{Column{Type: mysql.ColumnTypeInt24}, -2, uint32(0xFFFFFE)},
+		{Column{Type: mysql.ColumnTypeLong}, uint32(7), uint32(7)},
+		{Column{Type: mysql.ColumnTypeLonglong}, int64(-1), uint64(0xFFFFFFFFFFFFFFFF)},
+		{Column{Type: mysql.ColumnTypeFloat}, float32(1.5), float32(1.5)},
+		{Column{Type: mysql.ColumnTypeDouble}, 2.25, 2.25},
+		{Column{Type: mysql.ColumnTypeNewDecimal}, "-123.45", mysql.NewDecimal("-123.45")},
+		{Column{Type: mysql.ColumnTypeNewDecimal, Meta: 30<<8 | 12}, "1234567890.123456789012", mysql.NewDecimal("1234567890.123456789012")},
+		{Column{Type: mysql.ColumnTypeYear}, 2019, uint16(2019)},
+		{Column{Type: mysql.ColumnTypeDate}, "2019-01-02", "2019-01-02"},
+		{Column{Type: mysql.ColumnTypeTime2}, "12:34:56", "12:34:56"},
+		{Column{Type: mysql.ColumnTypeTimestamp2, Meta: 3}, ts, time.Unix(ts.Unix(), 678000000)},
+		{Column{Type: mysql.ColumnTypeDatetime}, ts.Truncate(time.Second), ts.Truncate(time.Second)},
+		{Column{Type: mysql.ColumnTypeDatetime2, Meta: 6}, ts, ts},
+		{Column{Type: mysql.ColumnTypeVarchar}, "hello", "hello"},
+		{Column{Type: mysql.ColumnTypeVarchar, Meta: 1000}, "wide", "wide"},
+		{Column{Type: mysql.ColumnTypeString, Meta: 300}, "char", "char"},
+		{Column{Type: mysql.ColumnTypeBlob}, []byte{0, 1, 2}, []byte{0, 1, 2}},
+		{Column{Type: mysql.ColumnTypeJSON}, `{"b":[1,true,null,"x"],"a":1.5}`, []byte(`{"a":1.5,"b":[1,true,null,"x"]}`)},
+		{Column{Type: mysql.ColumnTypeBit, Meta: 1 << 8}, 5, uint64(5)},
+		{Column{Type: mysql.ColumnTypeEnum, Values: []string{"a", "b"}}, 2, uint64(2)},
+		{Column{Type: mysql.ColumnTypeSet, Meta: 2}, 0x101, uint64(0x101)},
+		{Column{Type: mysql.ColumnTypeEnum}, 1, uint64(1)},
+		{Column{Type: mysql.ColumnTypeLong, Nullable: true}, nil, nil},
+	}
+
+	table := Table{ID: 42, Schema: "test", Name: "types", FullMetadata: true, PrimaryKey: []int{0}}
+	row := make([]interface{}, len(tests))
+	for i, test := range tests {
+		test.col.Name = "c" + string(rune('a'+i))
+		table.Columns = append(table.Columns, test.col)
+		row[i] = test.in
+	}
+
+	g := New()
+	fde := g.FormatDescription()
+	tme := g.TableMap(table)
+	re, err := g.Update(table, row, row)
+	if err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+
+	var fd binlog.FormatDescriptionEvent
+	if err := fd.Decode(body(t, fde, binlog.FormatDescription{})); err != nil {
+		t.Fatalf("Failed to decode format description: %v", err)
+	}
+	if fd.ServerDetails.ChecksumAlgorithm != binlog.ChecksumAlgorithmCRC32 {
+		t.Errorf("Unexpected checksum algorithm %s", fd.ServerDetails.ChecksumAlgorithm.String())
+	}
+	format := fd.FormatDescription
+	if !cmp.Equal(format, g.Format()) {
+		t.Errorf("Unexpected format description: %s", cmp.Diff(g.Format(), format))
+	}
+
+	var tm binlog.TableMapEvent
+	if err := tm.Decode(body(t, tme, format)[:len(tme)-headerLen-4], format); err != nil {
+		t.Fatalf("Failed to decode table map: %v", err)
+	}
+	if tm.TableID != table.ID {
+		t.Errorf("Expected table ID %d, got %d", table.ID, tm.TableID)
+	}
+	if !cmp.Equal(tm.TableDescription, table.Description()) {
+		t.Errorf("Unexpected table description: %s", cmp.Diff(table.Description(), tm.TableDescription))
+	}
+
+	rows := binlog.RowsEvent{Type: binlog.EventTypeUpdateRowsV2}
+	if err := rows.Decode(body(t, re, format)[:len(re)-headerLen-4], format, tm.TableDescription); err != nil {
+		t.Fatalf("Failed to decode rows: %v", err)
+	}
+	if len(rows.Rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows.Rows))
+	}
+	for i, test := range tests {
+		for _, r := range rows.Rows {
+			if !reflect.DeepEqual(r[i], test.out) {
+				t.Errorf("Column %s (%s): expected %v (%T), got %v (%T)",
+					table.Columns[i].Name, test.col.Type.String(), test.out, test.out, r[i], r[i])
+			}
+		}
+	}
+}
+
+// body checks the header and the checksum of an event and returns its body,
+// including the checksum.
+func body(t *testing.T, evt []byte, fd binlog.FormatDescription) []byte {
+	var h binlog.EventHeader
+	if err := h.Decode(evt, fd); err != nil {
+		t.Fatalf("Failed to decode header: %v", err)
+	}
+	if int(h.EventLen) != len(evt) {
+		t.Errorf("Expected event length %d, got %d", len(evt), h.EventLen)
+	}
+	if sum := binary.LittleEndian.Uint32(evt[len(evt)-4:]); sum != crc32.ChecksumIEEE(evt[:len(evt)-4]) {
+		t.Errorf("Invalid checksum %08x", sum)
+	}
+	return evt[headerLen:]
+}
+
+func TestGeneratorPositions(t *testing.T) {
+	g := New()
+	g.FormatDescription()
+	g.Query("test", "BEGIN")
+	xid := g.XID(1)
+	if pos := g.Position(); pos.Offset != uint64(len(g.Bytes())) {
+		t.Errorf("Expected offset %d, got %d", len(g.Bytes()), pos.Offset)
+	}
+	if !bytes.HasPrefix(g.Bytes(), binlog.FileMagic) || !bytes.HasSuffix(g.Bytes(), xid) {
+		t.Error("Expected file to contain generated events")
+	}
+	if next := binary.LittleEndian.Uint32(xid[13:]); uint64(next) != g.Position().Offset {
+		t.Errorf("Expected next position %d, got %d", g.Position().Offset, next)
+	}
+
+	g.Rotate("mysql-bin.000002")
+	if pos := g.Position(); pos.File != "mysql-bin.000002" || pos.Offset != 4 {
+		t.Errorf("Unexpected position after rotation %v", pos)
+	}
+	if len(g.Bytes()) != len(binlog.FileMagic) {
+		t.Errorf("Expected new file to be empty")
+	}
+}
@@ -0,0 +1,214 @@
+// Package golden verifies the decoder against a corpus of binary log fixtures.
+// Every fixture is a binary log file captured from a server, decoded events
+// are compared to a golden file stored next to it, which has the same name
+// with a .json extension. Golden files contain a JSON array of events encoded
+// with reader.Event.MarshalJSON, one element per event.
+//
+// Fixtures are usually grouped in directories by server version:
+//
+//	testdata/
+//	  mysql-5.7/mysql-bin.000001
+//	  mysql-5.7/mysql-bin.000001.json
+//	  mariadb-10.3/mysql-bin.000001
+//	  mariadb-10.3/mysql-bin.000001.json
+//
+// Golden files are regenerated by verifying with update enabled, changes are
+// then reviewed with a regular diff.
+package golden
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+// Ext is the extension of golden files.
+const Ext = ".json"
+
+// Mismatch describes a fixture that doesn't match its golden file.
+type Mismatch struct {
+	// Fixture is the path of the fixture.
+	Fixture string
+	// Event is the index of the first event that differs, or -1 when the
+	// fixture couldn't be compared at all.
+	Event int
+	// Expected and Actual are JSON encodings of the differing event, either
+	// is empty when the number of events differs.
+	Expected string
+	Actual   string
+	// Err is set when the fixture couldn't be decoded or the golden file
+	// couldn't be read.
+	Err error
+}
+
+func (m Mismatch) Error() string {
+	switch {
+	case m.Err != nil:
+		return fmt.Sprintf("%s: %v", m.Fixture, m.Err)
+	case m.Expected == "":
+		return fmt.Sprintf("%s: event %d: unexpected %s", m.Fixture, m.Event, m.Actual)
+	case m.Actual == "":
+		return fmt.Sprintf("%s: event %d: missing %s", m.Fixture, m.Event, m.Expected)
+	default:
+		return fmt.Sprintf("%s: event %d:\n\texpected %s\n\tgot      %s", m.Fixture, m.Event, m.Expected, m.Actual)
+	}
+}
+
+// Decode reads all events of a binary log file and returns their JSON
+// encodings.
+func Decode(path string) ([]json.RawMessage, error) {
+	r, err := reader.NewFile(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close(context.Background())
+
+	var events []json.RawMessage
+	for {
+		evt, err := r.ReadEvent(context.Background())
+		if err == reader.ErrEndOfLog {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %v", len(events), err)
+		}
+		b, err := json.Marshal(evt)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %v", len(events), err)
+		}
+		events = append(events, b)
+	}
+}
+
+// Fixtures returns paths of all binary log files in a directory and its
+// subdirectories.
+func Fixtures(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || strings.HasSuffix(path, Ext) {
+			return err
+		}
+		ok, err := isBinlog(path)
+		if ok {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	return paths, err
+}
+
+func isBinlog(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, len(binlog.FileMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(magic, binlog.FileMagic), nil
+}
+
+// Verify decodes every fixture in a directory and compares the result to its
+// golden file. When update is set golden files are written instead, fixtures
+// that fail to decode are still reported. An error is returned if the
+// directory can't be read or golden files can't be written.
+func Verify(dir string, update bool) ([]Mismatch, error) {
+	paths, err := Fixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []Mismatch
+	for _, path := range paths {
+		events, err := Decode(path)
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{Fixture: path, Event: -1, Err: err})
+			continue
+		}
+		if update {
+			if err := writeGolden(path+Ext, events); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if m, ok := compare(path, events); !ok {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return mismatches, nil
+}
+
+// compare compares decoded events to the golden file of a fixture.
+func compare(path string, events []json.RawMessage) (Mismatch, bool) {
+	m := Mismatch{Fixture: path, Event: -1}
+	b, err := ioutil.ReadFile(path + Ext)
+	if err != nil {
+		m.Err = err
+		return m, false
+	}
+	var golden []json.RawMessage
+	if err := json.Unmarshal(b, &golden); err != nil {
+		m.Err = fmt.Errorf("invalid golden file: %v", err)
+		return m, false
+	}
+
+	for i := 0; i < len(events) || i < len(golden); i++ {
+		m.Event = i
+		if i >= len(golden) {
+			m.Actual = string(events[i])
+			return m, false
+		}
+		if i >= len(events) {
+			m.Expected = compact(golden[i])
+			return m, false
+		}
+		// Values are compared rather than encodings, formatting of golden
+		// files doesn't matter
+		var exp, act interface{}
+		if err := json.Unmarshal(golden[i], &exp); err != nil {
+			m.Err = fmt.Errorf("invalid golden file: %v", err)
+			return m, false
+		}
+		json.Unmarshal(events[i], &act)
+		if !reflect.DeepEqual(exp, act) {
+			m.Expected, m.Actual = compact(golden[i]), string(events[i])
+			return m, false
+		}
+	}
+	return Mismatch{}, true
+}
+
+// writeGolden writes events as an array with one event per line, which keeps
+// diffs of golden files readable.
+func writeGolden(path string, events []json.RawMessage) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, evt := range events {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		buf.Write(evt)
+	}
+	buf.WriteString("\n]\n")
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func compact(b []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return string(b)
+	}
+	return buf.String()
+}
@@ -0,0 +1,73 @@
+package golden
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bocadillo")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+		{Name: "status", Type: mysql.ColumnTypeVarchar},
+	}}
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.Query("shop", "BEGIN")
+	g.TableMap(table)
+	if _, err := g.Insert(table, []interface{}{1, "pending"}); err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+	g.XID(1)
+
+	sub := filepath.Join(dir, "mysql-5.7")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	path := filepath.Join(sub, "mysql-bin.000001")
+	if err := ioutil.WriteFile(path, g.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+
+	mismatches, err := Verify(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Err == nil {
+		t.Errorf("Expected missing golden file to be reported, got %v", mismatches)
+	}
+
+	if mismatches, err = Verify(dir, true); err != nil || len(mismatches) > 0 {
+		t.Fatalf("Failed to update golden files: %v %v", err, mismatches)
+	}
+	if mismatches, err = Verify(dir, false); err != nil || len(mismatches) > 0 {
+		t.Fatalf("Expected fixture to match, got %v %v", err, mismatches)
+	}
+
+	b, err := ioutil.ReadFile(path + Ext)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	b = bytes.Replace(b, []byte(`"pending"`), []byte(`"shipped"`), 1)
+	if err := ioutil.WriteFile(path+Ext, b, 0644); err != nil {
+		t.Fatalf("Failed to write golden file: %v", err)
+	}
+	mismatches, err = Verify(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Event != 3 {
+		t.Errorf("Expected rows event mismatch, got %v", mismatches)
+	}
+}
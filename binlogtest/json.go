@@ -0,0 +1,197 @@
+package binlogtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+// Binary JSON value types.
+// Spec: https://dev.mysql.com/doc/dev/mysql-server/latest/json__binary_8h.html
+const (
+	jsonSmallObject byte = iota
+	jsonLargeObject
+	jsonSmallArray
+	jsonLargeArray
+	jsonLiteral
+	jsonInt16
+	jsonUint16
+	jsonInt32
+	jsonUint32
+	jsonInt64
+	jsonUint64
+	jsonFloat64
+	jsonString
+
+	jsonNull  byte = 0x00
+	jsonTrue  byte = 0x01
+	jsonFalse byte = 0x02
+)
+
+// errJSONTooLarge is returned when a value doesn't fit the small storage
+// format.
+var errJSONTooLarge = errors.New("json value is too large")
+
+// encodeJSON converts a JSON document into the binary format the server
+// stores JSON columns in.
+func encodeJSON(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	typ, data, err := encodeJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{typ}, data...), nil
+}
+
+// encodeJSONValue returns the type and the binary representation of a value.
+// Containers use the small storage format unless they don't fit.
+func encodeJSONValue(v interface{}) (byte, []byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return jsonLiteral, []byte{jsonNull}, nil
+	case bool:
+		if val {
+			return jsonLiteral, []byte{jsonTrue}, nil
+		}
+		return jsonLiteral, []byte{jsonFalse}, nil
+	case json.Number:
+		var buf [8]byte
+		if i, err := val.Int64(); err == nil {
+			switch {
+			case i >= math.MinInt16 && i <= math.MaxInt16:
+				binary.LittleEndian.PutUint16(buf[:], uint16(i))
+				return jsonInt16, buf[:2], nil
+			case i >= math.MinInt32 && i <= math.MaxInt32:
+				binary.LittleEndian.PutUint32(buf[:], uint32(i))
+				return jsonInt32, buf[:4], nil
+			default:
+				binary.LittleEndian.PutUint64(buf[:], uint64(i))
+				return jsonInt64, buf[:], nil
+			}
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return 0, nil, err
+		}
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+		return jsonFloat64, buf[:], nil
+	case string:
+		data := appendJSONVarLen(nil, len(val))
+		return jsonString, append(data, val...), nil
+	case []interface{}:
+		data, err := encodeJSONContainer(nil, val, true)
+		if err == errJSONTooLarge {
+			data, err = encodeJSONContainer(nil, val, false)
+			return jsonLargeArray, data, err
+		}
+		return jsonSmallArray, data, err
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		// Keys are sorted by length first, just like the server does
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = val[k]
+		}
+		data, err := encodeJSONContainer(keys, values, true)
+		if err == errJSONTooLarge {
+			data, err = encodeJSONContainer(keys, values, false)
+			return jsonLargeObject, data, err
+		}
+		return jsonSmallObject, data, err
+	default:
+		return 0, nil, errors.New("unexpected json value")
+	}
+}
+
+// encodeJSONContainer encodes an array, or an object when keys are given.
+// Small containers use 2 byte offsets, large ones use 4 byte offsets.
+func encodeJSONContainer(keys []string, values []interface{}, small bool) ([]byte, error) {
+	offsetSize := 4
+	if small {
+		offsetSize = 2
+	}
+	putOffset := func(b []byte, v int) {
+		if small {
+			binary.LittleEndian.PutUint16(b, uint16(v))
+		} else {
+			binary.LittleEndian.PutUint32(b, uint32(v))
+		}
+	}
+
+	n := len(values)
+	headerLen := 2*offsetSize + n*(1+offsetSize)
+	if keys != nil {
+		headerLen += n * (offsetSize + 2)
+	}
+	data := make([]byte, headerLen)
+	putOffset(data, n)
+
+	keyEntry := 2 * offsetSize
+	for _, k := range keys {
+		putOffset(data[keyEntry:], len(data))
+		binary.LittleEndian.PutUint16(data[keyEntry+offsetSize:], uint16(len(k)))
+		data = append(data, k...)
+		keyEntry += offsetSize + 2
+	}
+
+	valueEntry := keyEntry
+	for _, v := range values {
+		typ, val, err := encodeJSONValue(v)
+		if err != nil {
+			return nil, err
+		}
+		data[valueEntry] = typ
+		if isInlineJSONValue(typ, small) {
+			copy(data[valueEntry+1:valueEntry+1+offsetSize], val)
+		} else {
+			putOffset(data[valueEntry+1:], len(data))
+			data = append(data, val...)
+		}
+		valueEntry += 1 + offsetSize
+	}
+
+	if small && len(data) > math.MaxUint16 {
+		return nil, errJSONTooLarge
+	}
+	putOffset(data[offsetSize:], len(data))
+	return data, nil
+}
+
+// isInlineJSONValue returns true for values stored in value entries of
+// containers instead of being referenced by offset.
+func isInlineJSONValue(typ byte, small bool) bool {
+	switch typ {
+	case jsonLiteral, jsonInt16, jsonUint16:
+		return true
+	case jsonInt32, jsonUint32:
+		return !small
+	default:
+		return false
+	}
+}
+
+// appendJSONVarLen appends a length encoded in 7 bit groups.
+func appendJSONVarLen(b []byte, n int) []byte {
+	for n >= 0x80 {
+		b = append(b, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(b, byte(n))
+}
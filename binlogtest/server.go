@@ -0,0 +1,691 @@
+package binlogtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/server/protocol"
+)
+
+// Commands.
+const (
+	comQuit           byte = 1
+	comQuery          byte = 3
+	comPing           byte = 14
+	comBinlogDump     byte = 18
+	comRegisterSlave  byte = 21
+	comBinlogDumpGTID byte = 30
+)
+
+// Capability flags advertised in the handshake.
+const (
+	clientLongPassword               uint32 = 1 << 0
+	clientLongFlag                   uint32 = 1 << 2
+	clientConnectWithDB              uint32 = 1 << 3
+	clientProtocol41                 uint32 = 1 << 9
+	clientTransactions               uint32 = 1 << 13
+	clientSecureConn                 uint32 = 1 << 15
+	clientPluginAuth                 uint32 = 1 << 19
+	clientConnectAttrs               uint32 = 1 << 20
+	clientPluginAuthLenEncClientData uint32 = 1 << 21
+
+	serverCapabilities = clientLongPassword | clientLongFlag | clientConnectWithDB |
+		clientProtocol41 | clientTransactions | clientSecureConn | clientPluginAuth |
+		clientConnectAttrs | clientPluginAuthLenEncClientData
+)
+
+// Error codes sent by the server.
+const (
+	// ErrCodeMasterFatalReadingBinlog is sent when a binary log can't be
+	// read, e.g. the requested file doesn't exist.
+	ErrCodeMasterFatalReadingBinlog uint16 = 1236
+	errCodeUnknownCommand           uint16 = 1047
+	errCodeParse                    uint16 = 1064
+	errCodeUnknownSystemVariable    uint16 = 1193
+)
+
+// logEventArtificial is set in headers of events that are not written to the
+// binary log.
+const logEventArtificial = 0x20
+
+// Server is a fake master server for end-to-end tests of replicas. It speaks
+// just enough of the protocol for a replica to connect, configure its session,
+// register and start a binary log dump. Binary log files are kept in memory,
+// see AddFile and Append. Authentication always succeeds.
+//
+// The zero value is ready to be configured and started:
+//
+//	srv := &binlogtest.Server{}
+//	srv.AddFile("mysql-bin.000001", g.Bytes())
+//	if err := srv.Start(); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//	r, err := reader.New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001"})
+type Server struct {
+	// Version is reported in the handshake, 5.7.19-log by default.
+	Version string
+	// Vars contains global variables returned by SELECT @@name and SHOW
+	// VARIABLES queries, in addition to a few defaults describing a server
+	// with row based binary logging.
+	Vars map[string]string
+	// Dump serves binary log dumps. The connection is closed once it returns,
+	// which allows scripting disconnects. By default dumps are served with
+	// Dump.Stream.
+	Dump func(d *Dump) error
+
+	ln      net.Listener
+	mu      sync.Mutex
+	files   []logFile
+	conns   map[net.Conn]struct{}
+	lastID  uint32
+	changed chan struct{}
+	closed  chan struct{}
+	wg      sync.WaitGroup
+}
+
+type logFile struct {
+	name string
+	data []byte
+}
+
+// Start starts listening on a loopback address and serving connections.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.conns = make(map[net.Conn]struct{})
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+	s.closed = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.accept()
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// DSN returns a data source name for connecting to the server.
+func (s *Server) DSN() string {
+	return "repl@tcp(" + s.Addr() + ")/"
+}
+
+// AddFile adds a binary log file with given contents, which must start with
+// the magic header. An existing file with the same name is replaced.
+func (s *Server) AddFile(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data = append([]byte(nil), data...)
+	for i := range s.files {
+		if s.files[i].name == name {
+			s.files[i].data = data
+			s.notify()
+			return
+		}
+	}
+	s.files = append(s.files, logFile{name: name, data: data})
+	s.notify()
+}
+
+// Append appends events to a binary log file, creating it if necessary. Dumps
+// waiting at the end of the file receive the events right away.
+func (s *Server) Append(name string, events ...[]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.fileIndex(name)
+	if i < 0 {
+		s.files = append(s.files, logFile{name: name, data: append([]byte(nil), binlog.FileMagic...)})
+		i = len(s.files) - 1
+	}
+	for _, evt := range events {
+		s.files[i].data = append(s.files[i].data, evt...)
+	}
+	s.notify()
+}
+
+// Disconnect closes all client connections without notice, as if the network
+// failed.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nc := range s.conns {
+		nc.Close()
+	}
+}
+
+// Close stops the server and closes all connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	close(s.closed)
+	err := s.ln.Close()
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// notify wakes up dumps waiting for new events. Must be called with the lock
+// held.
+func (s *Server) notify() {
+	if s.changed != nil {
+		close(s.changed)
+	}
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) fileIndex(name string) int {
+	for i, f := range s.files {
+		if f.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// file returns current contents of a file and a channel that is closed once
+// any file changes.
+func (s *Server) file(name string) ([]byte, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.fileIndex(name)
+	if i < 0 {
+		return nil, s.changed, false
+	}
+	return s.files[i].data, s.changed, true
+}
+
+func (s *Server) firstFile() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) == 0 {
+		return ""
+	}
+	return s.files[0].name
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[nc] = struct{}{}
+		s.lastID++
+		id := s.lastID
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, nc)
+				s.mu.Unlock()
+				nc.Close()
+			}()
+			s.serve(protocol.NewConn(nc), id)
+		}()
+	}
+}
+
+// session contains the state of a client session.
+type session struct {
+	checksum  bool
+	heartbeat time.Duration
+}
+
+var heartbeatPeriodRe = regexp.MustCompile(`@MASTER_HEARTBEAT_PERIOD\s*=\s*(\d+)`)
+
+func (s *Server) serve(c *protocol.Conn, id uint32) {
+	if err := s.handshake(c, id); err != nil {
+		return
+	}
+	var sess session
+	for {
+		pkt, err := c.ReadPacket()
+		if err != nil || len(pkt) == 0 {
+			return
+		}
+		switch pkt[0] {
+		case comQuit:
+			return
+		case comPing, comRegisterSlave:
+			err = c.WriteOK()
+		case comQuery:
+			err = s.query(c, &sess, string(pkt[1:]))
+		case comBinlogDump, comBinlogDumpGTID:
+			d, err := s.newDump(c, sess, pkt)
+			if err != nil {
+				c.WriteError(errCodeParse, err.Error())
+				return
+			}
+			go func() {
+				// Replicas don't send anything during a dump but a QUIT
+				// command before closing the connection
+				c.Discard()
+				close(d.done)
+			}()
+			if s.Dump != nil {
+				s.Dump(d)
+			} else {
+				d.Stream()
+			}
+			return
+		default:
+			err = c.WriteError(errCodeUnknownCommand, "Unknown command")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handshake sends the initial handshake packet and accepts any credentials.
+func (s *Server) handshake(c *protocol.Conn, id uint32) error {
+	scramble := []byte("01234567890123456789")
+	hs := []byte{10}
+	hs = append(append(hs, s.version()...), 0)
+	hs = append(hs, byte(id), byte(id>>8), byte(id>>16), byte(id>>24))
+	hs = append(hs, scramble[:8]...)
+	hs = append(hs, 0)
+	caps := serverCapabilities
+	hs = append(hs, byte(caps), byte(caps>>8), 33, 2, 0, byte(caps>>16), byte(caps>>24), byte(len(scramble)+1))
+	hs = append(hs, make([]byte, 10)...)
+	hs = append(append(hs, scramble[8:]...), 0)
+	hs = append(append(hs, "mysql_native_password"...), 0)
+	if err := c.WritePacket(hs); err != nil {
+		return err
+	}
+	if _, err := c.ReadPacket(); err != nil {
+		return err
+	}
+	return c.WriteOK()
+}
+
+func (s *Server) version() string {
+	if s.Version != "" {
+		return s.Version
+	}
+	return "5.7.19-log"
+}
+
+// vars returns global variables.
+func (s *Server) vars() map[string]string {
+	vars := map[string]string{
+		"version":          s.version(),
+		"log_bin":          "ON",
+		"binlog_format":    "ROW",
+		"binlog_row_image": "FULL",
+		"binlog_checksum":  "CRC32",
+		"server_id":        "1",
+		"gtid_mode":        "OFF",
+	}
+	for k, v := range s.Vars {
+		vars[strings.ToLower(k)] = v
+	}
+	return vars
+}
+
+var quotedRe = regexp.MustCompile(`'([^']*)'`)
+
+// query responds to queries replicas run.
+func (s *Server) query(c *protocol.Conn, sess *session, q string) error {
+	q = strings.TrimSpace(q)
+	uq := strings.ToUpper(q)
+	switch {
+	case strings.HasPrefix(uq, "SET "):
+		if strings.Contains(uq, "@MASTER_BINLOG_CHECKSUM") {
+			sess.checksum = !strings.Contains(uq, "NONE") &&
+				(!strings.Contains(uq, "@@GLOBAL.BINLOG_CHECKSUM") || s.vars()["binlog_checksum"] != "NONE")
+		}
+		if m := heartbeatPeriodRe.FindStringSubmatch(uq); m != nil {
+			ns, _ := strconv.ParseInt(m[1], 10, 64)
+			sess.heartbeat = time.Duration(ns)
+		}
+		return c.WriteOK()
+
+	case strings.HasPrefix(uq, "SELECT "):
+		vars := s.vars()
+		var cols, row []string
+		for _, expr := range strings.Split(q[len("SELECT "):], ",") {
+			expr = strings.TrimSpace(expr)
+			name := strings.ToLower(expr)
+			if !strings.HasPrefix(name, "@@") {
+				// User variables are not tracked
+				cols, row = append(cols, expr), append(row, "")
+				continue
+			}
+			name = strings.TrimPrefix(name, "@@")
+			name = strings.TrimPrefix(strings.TrimPrefix(name, "global."), "session.")
+			val, ok := vars[name]
+			if !ok {
+				return c.WriteError(errCodeUnknownSystemVariable, "Unknown system variable '"+name+"'")
+			}
+			cols, row = append(cols, expr), append(row, val)
+		}
+		return c.WriteResult(cols, [][]string{row})
+
+	case strings.HasPrefix(uq, "SHOW GLOBAL VARIABLES"), strings.HasPrefix(uq, "SHOW VARIABLES"):
+		vars := s.vars()
+		var rows [][]string
+		for _, m := range quotedRe.FindAllStringSubmatch(q, -1) {
+			if val, ok := vars[strings.ToLower(m[1])]; ok {
+				rows = append(rows, []string{m[1], val})
+			}
+		}
+		return c.WriteResult([]string{"Variable_name", "Value"}, rows)
+
+	case uq == "SHOW BINARY LOGS", uq == "SHOW MASTER LOGS":
+		s.mu.Lock()
+		var rows [][]string
+		for _, f := range s.files {
+			rows = append(rows, []string{f.name, strconv.Itoa(len(f.data))})
+		}
+		s.mu.Unlock()
+		return c.WriteResult([]string{"Log_name", "File_size"}, rows)
+
+	case uq == "SHOW MASTER STATUS", uq == "SHOW BINARY LOG STATUS":
+		s.mu.Lock()
+		var rows [][]string
+		if n := len(s.files); n > 0 {
+			f := s.files[n-1]
+			rows = append(rows, []string{f.name, strconv.Itoa(len(f.data)), "", "", s.vars()["gtid_executed"]})
+		}
+		s.mu.Unlock()
+		return c.WriteResult([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, rows)
+
+	default:
+		return c.WriteError(errCodeParse, "Unsupported query: "+q)
+	}
+}
+
+// Dump is a binary log dump requested by a replica.
+type Dump struct {
+	// File and Offset is the requested position.
+	File   string
+	Offset uint64
+	Flags  driver.DumpFlags
+	// ServerID is the server ID of the replica.
+	ServerID uint32
+	// GTIDSet is the set of transactions the replica has, it is only set for
+	// GTID based dumps.
+	GTIDSet binlog.GTIDSet
+	// Checksum is set if the replica accepts events with checksums. Checksums
+	// are removed from events sent to replicas that don't.
+	Checksum bool
+	// HeartbeatPeriod is the heartbeat period requested by the replica.
+	HeartbeatPeriod time.Duration
+
+	srv  *Server
+	conn *protocol.Conn
+	// eventChecksum is set if events being sent have checksums
+	eventChecksum bool
+	done          chan struct{}
+}
+
+// ErrDumpClosed is returned when the replica closes the connection during a
+// dump.
+var ErrDumpClosed = errors.New("Connection closed by replica")
+
+func (s *Server) newDump(c *protocol.Conn, sess session, pkt []byte) (*Dump, error) {
+	d := &Dump{
+		Checksum:        sess.checksum,
+		HeartbeatPeriod: sess.heartbeat,
+		srv:             s,
+		conn:            c,
+		done:            make(chan struct{}),
+	}
+	le := binary.LittleEndian
+	if pkt[0] == comBinlogDump {
+		if len(pkt) < 11 {
+			return nil, errors.New("malformed binlog dump command")
+		}
+		d.Offset = uint64(le.Uint32(pkt[1:]))
+		d.Flags = driver.DumpFlags(le.Uint16(pkt[5:]))
+		d.ServerID = le.Uint32(pkt[7:])
+		d.File = string(pkt[11:])
+		return d, nil
+	}
+
+	if len(pkt) < 11 {
+		return nil, errors.New("malformed binlog dump command")
+	}
+	d.Flags = driver.DumpFlags(le.Uint16(pkt[1:]))
+	d.ServerID = le.Uint32(pkt[3:])
+	n := int(le.Uint32(pkt[7:]))
+	rest := pkt[11:]
+	if len(rest) < n+8+4 {
+		return nil, errors.New("malformed binlog dump command")
+	}
+	d.File = string(rest[:n])
+	d.Offset = le.Uint64(rest[n:])
+	set, err := binlog.DecodeGTIDSet(rest[n+12:])
+	if err != nil {
+		return nil, err
+	}
+	d.GTIDSet = set
+	return d, nil
+}
+
+// Send sends an event. Checksums are removed if the replica doesn't accept
+// them, which is detected from the last format description event sent.
+func (d *Dump) Send(evt []byte) error {
+	if len(evt) >= headerLen && binlog.EventType(evt[4]) == binlog.EventTypeFormatDescription {
+		d.eventChecksum = binlog.ChecksumAlgorithm(evt[len(evt)-5]) == binlog.ChecksumAlgorithmCRC32
+		if d.eventChecksum && !d.Checksum {
+			evt = append([]byte(nil), evt...)
+			evt[len(evt)-5] = byte(binlog.ChecksumAlgorithmNone)
+		}
+	} else if d.eventChecksum && !d.Checksum && len(evt) >= headerLen+4 {
+		evt = append([]byte(nil), evt[:len(evt)-4]...)
+		binary.LittleEndian.PutUint32(evt[9:], uint32(len(evt)))
+	}
+	return d.conn.WritePacket(append([]byte{0}, evt...))
+}
+
+// SendRotate sends an artificial rotate event, which tells the replica the
+// position of the following events. Dumps start with one.
+func (d *Dump) SendRotate(file string, offset uint64) error {
+	body := make([]byte, 8, 8+len(file))
+	binary.LittleEndian.PutUint64(body, offset)
+	body = append(body, file...)
+	return d.conn.WritePacket(append([]byte{0}, d.artificialEvent(binlog.EventTypeRotate, 0, body, false)...))
+}
+
+// SendHeartbeat sends a heartbeat event with given position.
+func (d *Dump) SendHeartbeat(file string, offset uint64) error {
+	evt := d.artificialEvent(binlog.EventTypeHeartbeet, uint32(offset), []byte(file), d.Checksum)
+	return d.conn.WritePacket(append([]byte{0}, evt...))
+}
+
+// SendHeartbeatV2 sends a heartbeat v2 event with given position, which is
+// not truncated to 32 bits.
+func (d *Dump) SendHeartbeatV2(file string, offset uint64) error {
+	body := appendUintLenEnc(nil, 1)
+	body = protocol.AppendStrLenEnc(body, file)
+	pos := appendUintLenEnc(nil, offset)
+	body = appendUintLenEnc(append(body, 2), uint64(len(pos)))
+	body = append(body, pos...)
+	body = append(body, 0) // End mark
+	evt := d.artificialEvent(binlog.EventTypeHeartbeatV2, 0, body, d.Checksum)
+	return d.conn.WritePacket(append([]byte{0}, evt...))
+}
+
+func (d *Dump) artificialEvent(et binlog.EventType, nextPos uint32, body []byte, checksum bool) []byte {
+	n := headerLen + len(body)
+	if checksum {
+		n += 4
+	}
+	evt := make([]byte, headerLen, n)
+	evt[4] = byte(et)
+	binary.LittleEndian.PutUint32(evt[5:], 1)
+	binary.LittleEndian.PutUint32(evt[9:], uint32(n))
+	binary.LittleEndian.PutUint32(evt[13:], nextPos)
+	binary.LittleEndian.PutUint16(evt[17:], logEventArtificial)
+	evt = append(evt, body...)
+	if checksum {
+		var sum [4]byte
+		binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(evt))
+		evt = append(evt, sum[:]...)
+	}
+	return evt
+}
+
+// SendError sends an error, which ends the dump.
+func (d *Dump) SendError(code uint16, message string) error {
+	return d.conn.WriteError(code, message)
+}
+
+// SendEOF sends an EOF packet, which is how the end of the binary log is
+// reported in non-blocking mode.
+func (d *Dump) SendEOF() error {
+	return d.conn.WriteEOF()
+}
+
+// Closed returns a channel that is closed once the replica closes the
+// connection.
+func (d *Dump) Closed() <-chan struct{} {
+	return d.done
+}
+
+// Stream sends events of the server's binary log files starting at the
+// requested position, just like a real server does. An artificial rotate event
+// goes first, followed by the format description event of the file. Rotate
+// events make it continue with the next file. GTID based dumps start with the
+// first file and skip transactions the replica has. Once the end of the
+// binary log is reached Stream waits for more events, sending heartbeats if
+// requested, or sends an EOF packet if the replica asked not to block. It
+// returns when either side closes the connection.
+func (d *Dump) Stream() error {
+	file, offset := d.File, d.Offset
+	if d.GTIDSet != nil || file == "" {
+		file, offset = d.srv.firstFile(), 4
+	}
+	if offset < uint64(len(binlog.FileMagic)) {
+		offset = uint64(len(binlog.FileMagic))
+	}
+	if err := d.SendRotate(file, offset); err != nil {
+		return err
+	}
+
+	pos := uint64(len(binlog.FileMagic))
+	formatSent, skip := false, false
+	for {
+		data, changed, ok := d.srv.file(file)
+		if !ok {
+			return d.SendError(ErrCodeMasterFatalReadingBinlog,
+				"Could not find first log file name in binary log index file")
+		}
+
+		rotated := false
+		for !rotated && pos+headerLen <= uint64(len(data)) {
+			n := uint64(binary.LittleEndian.Uint32(data[pos+9:]))
+			if n < headerLen || pos+n > uint64(len(data)) {
+				break
+			}
+			evt := data[pos : pos+n]
+			pos += n
+			et := binlog.EventType(evt[4])
+
+			if et == binlog.EventTypeFormatDescription {
+				if formatSent {
+					continue
+				}
+				formatSent = true
+				if offset > uint64(len(binlog.FileMagic)) {
+					// Events are skipped up to the offset, the format
+					// description is sent as an artificial event
+					evt = append([]byte(nil), evt...)
+					binary.LittleEndian.PutUint32(evt[13:], 0)
+					if offset > pos {
+						pos = offset
+					}
+				}
+				if err := d.Send(evt); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if d.GTIDSet != nil && (et == binlog.EventTypeGTID || et == binlog.EventTypeGTIDTagged) {
+				body := evt[headerLen:]
+				if d.eventChecksum {
+					body = body[:len(body)-4]
+				}
+				if ge, err := binlog.DecodeGTIDEvent(et, body); err == nil {
+					skip = d.GTIDSet.ContainsGTID(ge.GTID)
+				}
+			}
+			if skip && et != binlog.EventTypeRotate {
+				continue
+			}
+			if err := d.Send(evt); err != nil {
+				return err
+			}
+
+			if et == binlog.EventTypeRotate {
+				body := evt[headerLen:]
+				if d.eventChecksum {
+					body = body[:len(body)-4]
+				}
+				if len(body) < 8 {
+					return errors.New("malformed rotate event")
+				}
+				file, offset = string(body[8:]), uint64(len(binlog.FileMagic))
+				pos, formatSent, rotated = uint64(len(binlog.FileMagic)), false, true
+			}
+		}
+		if rotated {
+			continue
+		}
+
+		if d.Flags&driver.DumpFlagNonBlock != 0 {
+			return d.SendEOF()
+		}
+		var heartbeat <-chan time.Time
+		if d.HeartbeatPeriod > 0 {
+			t := time.NewTimer(d.HeartbeatPeriod)
+			defer t.Stop()
+			heartbeat = t.C
+		}
+		select {
+		case <-changed:
+		case <-heartbeat:
+			if err := d.SendHeartbeat(file, pos); err != nil {
+				return err
+			}
+		case <-d.done:
+			return ErrDumpClosed
+		case <-d.srv.closed:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package binlogtest
+
+import (
+	"fmt"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// Table describes a table rows events are built for.
+type Table struct {
+	ID      uint64
+	Schema  string
+	Name    string
+	Columns []Column
+	// PrimaryKey contains indexes of primary key columns.
+	PrimaryKey []int
+	// FullMetadata makes table map events contain column names, signedness,
+	// enum and set members and the primary key, just like
+	// binlog_row_metadata=FULL does.
+	FullMetadata bool
+}
+
+// Column describes a table column.
+type Column struct {
+	Name string
+	// Type is the type of the column. Enum and set columns are logged as
+	// strings, just like the server does.
+	Type mysql.ColumnType
+	// Meta is the column metadata, its meaning depends on the type:
+	//
+	//	Varchar, String         maximum length in bytes, 255 by default
+	//	NewDecimal              precision<<8 | decimals, 10<<8 | 2 by default
+	//	Float, Double           size in bytes, 4 and 8 by default
+	//	Blob, Geometry, JSON    size of the length in bytes, 2 and 4 by default
+	//	Time2, Datetime2,
+	//	Timestamp2              fractional seconds precision
+	//	Bit                     bytes<<8 | bits, 1 bit by default
+	//	Enum, Set               size in bytes, 1 by default
+	Meta     uint16
+	Unsigned bool
+	Nullable bool
+	// Values contains members of enum and set columns, they are only logged
+	// with full metadata.
+	Values []string
+}
+
+// meta returns column metadata with defaults applied.
+func (c Column) meta() uint16 {
+	if c.Meta > 0 {
+		return c.Meta
+	}
+	switch c.Type {
+	case mysql.ColumnTypeVarchar, mysql.ColumnTypeVarstring, mysql.ColumnTypeString:
+		return 255
+	case mysql.ColumnTypeNewDecimal:
+		return 10<<8 | 2
+	case mysql.ColumnTypeFloat:
+		return 4
+	case mysql.ColumnTypeDouble:
+		return 8
+	case mysql.ColumnTypeBlob, mysql.ColumnTypeGeometry:
+		return 2
+	case mysql.ColumnTypeJSON:
+		return 4
+	case mysql.ColumnTypeBit, mysql.ColumnTypeEnum, mysql.ColumnTypeSet:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// logged returns the column type and metadata as they are logged in table map
+// events.
+func (c Column) logged() (mysql.ColumnType, uint16) {
+	meta := c.meta()
+	switch c.Type {
+	case mysql.ColumnTypeEnum, mysql.ColumnTypeSet:
+		return mysql.ColumnTypeString, uint16(c.Type)<<8 | meta
+	case mysql.ColumnTypeString:
+		// Two high bits of lengths above 255 are stored inverted in the type
+		// byte
+		typeByte := uint16(mysql.ColumnTypeString) &^ 0x30
+		typeByte |= (meta>>4)&0x30 ^ 0x30
+		return mysql.ColumnTypeString, typeByte<<8 | meta&0xFF
+	default:
+		return c.Type, meta
+	}
+}
+
+// Description returns the description of the table as it is decoded from its
+// table map event.
+func (t Table) Description() binlog.TableDescription {
+	td := binlog.TableDescription{
+		SchemaName:  t.Schema,
+		TableName:   t.Name,
+		ColumnCount: uint64(len(t.Columns)),
+		ColumnTypes: make([]byte, len(t.Columns)),
+		ColumnMeta:  make([]uint16, len(t.Columns)),
+		NullBitmask: make([]byte, (len(t.Columns)+7)/8),
+	}
+	for i, c := range t.Columns {
+		ct, meta := c.logged()
+		td.ColumnTypes[i] = byte(ct)
+		td.ColumnMeta[i] = meta
+		if c.Nullable {
+			td.NullBitmask[i/8] |= 1 << uint(i%8)
+		}
+	}
+	if t.FullMetadata {
+		td.ColumnNames = make([]string, len(t.Columns))
+		td.Unsigned = make([]bool, len(t.Columns))
+		for i, c := range t.Columns {
+			td.ColumnNames[i] = c.Name
+			td.Unsigned[i] = c.Unsigned && isNumeric(c.Type)
+		}
+		td.PrimaryKey = t.PrimaryKey
+		for i, c := range t.Columns {
+			if c.Type != mysql.ColumnTypeEnum && c.Type != mysql.ColumnTypeSet {
+				continue
+			}
+			if td.EnumValues == nil {
+				td.EnumValues = make([][]string, len(t.Columns))
+			}
+			td.EnumValues[i] = c.Values
+		}
+	}
+	return td
+}
+
+// appendRow appends a row image with all columns present.
+func (t Table) appendRow(b []byte, row []interface{}) ([]byte, error) {
+	if len(row) != len(t.Columns) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(t.Columns), len(row))
+	}
+	nulls := len(b)
+	b = append(b, make([]byte, (len(row)+7)/8)...)
+	for i, val := range row {
+		if val == nil {
+			b[nulls+i/8] |= 1 << uint(i%8)
+			continue
+		}
+		var err error
+		c := t.Columns[i]
+		if b, err = appendValue(b, c.Type, c.meta(), val); err != nil {
+			return nil, fmt.Errorf("column %s: %v", c.Name, err)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,418 @@
+package binlogtest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// appendValue appends a binary encoded value of a column. Values could be of
+// the same types rows events are decoded into, as well as:
+//
+//	Integers                any integer type
+//	Float, Double           float32 or float64
+//	NewDecimal              mysql.Decimal or string
+//	Year                    any integer type
+//	Date                    string formatted as 2006-01-02 or time.Time
+//	Time, Time2             string formatted as 15:04:05.999999
+//	Timestamp, Datetime     time.Time
+//	Strings, blobs          string or []byte
+//	JSON                    string or []byte containing a JSON document
+//	Bit, Enum, Set          any integer type
+func appendValue(b []byte, ct mysql.ColumnType, meta uint16, val interface{}) ([]byte, error) {
+	switch ct {
+	case mysql.ColumnTypeTiny:
+		return appendInt(b, val, 1)
+	case mysql.ColumnTypeShort:
+		return appendInt(b, val, 2)
+	case mysql.ColumnTypeInt24:
+		return appendInt(b, val, 3)
+	case mysql.ColumnTypeLong:
+		return appendInt(b, val, 4)
+	case mysql.ColumnTypeLonglong:
+		return appendInt(b, val, 8)
+	case mysql.ColumnTypeEnum:
+		return appendInt(b, val, int(meta))
+	case mysql.ColumnTypeSet:
+		return appendInt(b, val, int(meta))
+
+	case mysql.ColumnTypeFloat:
+		f, ok := toFloat(val)
+		if !ok {
+			break
+		}
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(f)))
+		return append(b, buf[:]...), nil
+	case mysql.ColumnTypeDouble:
+		f, ok := toFloat(val)
+		if !ok {
+			break
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+		return append(b, buf[:]...), nil
+
+	case mysql.ColumnTypeNewDecimal:
+		switch v := val.(type) {
+		case mysql.Decimal:
+			return appendDecimal(b, v.String(), int(meta>>8), int(meta&0xFF))
+		case string:
+			return appendDecimal(b, v, int(meta>>8), int(meta&0xFF))
+		}
+
+	case mysql.ColumnTypeYear:
+		v, ok := toUint64(val)
+		if !ok {
+			break
+		}
+		if v > 0 {
+			v -= 1900
+		}
+		return append(b, byte(v)), nil
+	case mysql.ColumnTypeDate:
+		var y, m, d int
+		switch v := val.(type) {
+		case string:
+			if _, err := fmt.Sscanf(v, "%d-%d-%d", &y, &m, &d); err != nil {
+				return nil, fmt.Errorf("invalid date %q", v)
+			}
+		case time.Time:
+			y, m, d = v.Year(), int(v.Month()), v.Day()
+		default:
+			return nil, fmt.Errorf("unexpected value type %T", val)
+		}
+		return appendUint(b, uint64(y*16*32+m*32+d), 3), nil
+	case mysql.ColumnTypeTime:
+		s, ok := val.(string)
+		if !ok {
+			break
+		}
+		neg, h, m, sec, _, err := parseTime(s)
+		if err != nil {
+			return nil, err
+		}
+		v := int32(h*10000 + m*100 + sec)
+		if neg {
+			v = -v
+		}
+		return appendUint(b, uint64(uint32(v)), 3), nil
+	case mysql.ColumnTypeTime2:
+		s, ok := val.(string)
+		if !ok {
+			break
+		}
+		neg, h, m, sec, usec, err := parseTime(s)
+		if err != nil {
+			return nil, err
+		}
+		return appendTime2(b, neg, h, m, sec, usec, meta), nil
+	case mysql.ColumnTypeTimestamp:
+		t, ok := val.(time.Time)
+		if !ok {
+			break
+		}
+		return appendUint(b, uint64(unixOrZero(t)), 4), nil
+	case mysql.ColumnTypeTimestamp2:
+		t, ok := val.(time.Time)
+		if !ok {
+			break
+		}
+		b = appendUintBigEndian(b, uint64(unixOrZero(t)), 4)
+		return appendFrac(b, t.Nanosecond()/1000, meta), nil
+	case mysql.ColumnTypeDatetime:
+		t, ok := val.(time.Time)
+		if !ok {
+			break
+		}
+		var v uint64
+		if !t.IsZero() {
+			t = t.In(mysql.Timezone)
+			v = uint64(t.Year())*1e10 + uint64(t.Month())*1e8 + uint64(t.Day())*1e6 +
+				uint64(t.Hour())*1e4 + uint64(t.Minute())*1e2 + uint64(t.Second())
+		}
+		return appendUint(b, v, 8), nil
+	case mysql.ColumnTypeDatetime2:
+		t, ok := val.(time.Time)
+		if !ok {
+			break
+		}
+		var v uint64
+		if !t.IsZero() {
+			t = t.In(mysql.Timezone)
+			ymd := uint64(t.Year()*13+int(t.Month()))<<5 | uint64(t.Day())
+			hms := uint64(t.Hour())<<12 | uint64(t.Minute())<<6 | uint64(t.Second())
+			v = ymd<<17 | hms
+		}
+		b = appendUintBigEndian(b, v+0x8000000000, 5)
+		return appendFrac(b, t.Nanosecond()/1000, meta), nil
+
+	case mysql.ColumnTypeString, mysql.ColumnTypeVarchar, mysql.ColumnTypeVarstring:
+		n := 1
+		if meta >= 256 {
+			n = 2
+		}
+		return appendBytes(b, val, n)
+	case mysql.ColumnTypeBlob, mysql.ColumnTypeGeometry:
+		return appendBytes(b, val, int(meta))
+	case mysql.ColumnTypeTinyblob:
+		return appendBytes(b, val, 1)
+	case mysql.ColumnTypeMediumblob:
+		return appendBytes(b, val, 3)
+	case mysql.ColumnTypeLongblob:
+		return appendBytes(b, val, 4)
+	case mysql.ColumnTypeJSON:
+		var doc []byte
+		switch v := val.(type) {
+		case string:
+			doc = []byte(v)
+		case []byte:
+			doc = v
+		default:
+			return nil, fmt.Errorf("unexpected value type %T", val)
+		}
+		jb, err := encodeJSON(doc)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(b, jb, int(meta))
+
+	case mysql.ColumnTypeBit:
+		v, ok := toUint64(val)
+		if !ok {
+			break
+		}
+		nbits := int(meta>>8)*8 + int(meta&0xFF)
+		return appendUintBigEndian(b, v, (nbits+7)/8), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported type %s", ct.String())
+	}
+	return nil, fmt.Errorf("unexpected value type %T", val)
+}
+
+func appendInt(b []byte, val interface{}, size int) ([]byte, error) {
+	v, ok := toUint64(val)
+	if !ok {
+		return nil, fmt.Errorf("unexpected value type %T", val)
+	}
+	return appendUint(b, v, size), nil
+}
+
+// appendUint appends a little endian integer of given size.
+func appendUint(b []byte, v uint64, size int) []byte {
+	for i := 0; i < size; i++ {
+		b = append(b, byte(v>>uint(i*8)))
+	}
+	return b
+}
+
+// appendUintBigEndian appends a big endian integer of given size.
+func appendUintBigEndian(b []byte, v uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(v>>uint(i*8)))
+	}
+	return b
+}
+
+// appendBytes appends a string prefixed with its length of given size.
+func appendBytes(b []byte, val interface{}, size int) ([]byte, error) {
+	var str []byte
+	switch v := val.(type) {
+	case string:
+		str = []byte(v)
+	case []byte:
+		str = v
+	default:
+		return nil, fmt.Errorf("unexpected value type %T", val)
+	}
+	if size < 8 && uint64(len(str)) >= 1<<uint(size*8) {
+		return nil, fmt.Errorf("value of %d bytes is too long", len(str))
+	}
+	b = appendUint(b, uint64(len(str)), size)
+	return append(b, str...), nil
+}
+
+// toUint64 converts an integer of any type, negative values are converted to
+// their two's complement representation.
+func toUint64(val interface{}) (uint64, bool) {
+	switch v := val.(type) {
+	case int:
+		return uint64(v), true
+	case int8:
+		return uint64(v), true
+	case int16:
+		return uint64(v), true
+	case int32:
+		return uint64(v), true
+	case int64:
+		return uint64(v), true
+	case uint:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// parseTime parses a time value formatted as [-]15:04:05[.999999].
+func parseTime(s string) (neg bool, h, m, sec, usec int, err error) {
+	str := s
+	if strings.HasPrefix(str, "-") {
+		neg = true
+		str = str[1:]
+	}
+	frac := ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		str, frac = str[:i], str[i+1:]
+	}
+	if _, err := fmt.Sscanf(str, "%d:%d:%d", &h, &m, &sec); err != nil {
+		return false, 0, 0, 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+	if len(frac) > 6 {
+		return false, 0, 0, 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+	for i := 0; i < 6; i++ {
+		usec *= 10
+		if i < len(frac) {
+			if frac[i] < '0' || frac[i] > '9' {
+				return false, 0, 0, 0, 0, fmt.Errorf("invalid time %q", s)
+			}
+			usec += int(frac[i] - '0')
+		}
+	}
+	return neg, h, m, sec, usec, nil
+}
+
+// appendTime2 appends a TIME v2 value with given fractional seconds
+// precision. Values are packed the way the server does it, see
+// my_time_packed_to_binary.
+func appendTime2(b []byte, neg bool, h, m, sec, usec int, dec uint16) []byte {
+	const intOffset = 0x800000
+	const offset = 0x800000000000
+	packed := int64(h<<12|m<<6|sec)<<24 + int64(usec)
+	if neg {
+		packed = -packed
+	}
+	intPart := packed >> 24
+	fracPart := packed % (1 << 24)
+	switch dec {
+	case 1, 2:
+		b = appendUintBigEndian(b, uint64(intPart+intOffset), 3)
+		return append(b, byte(int8(fracPart/10000)))
+	case 3, 4:
+		b = appendUintBigEndian(b, uint64(intPart+intOffset), 3)
+		return appendUintBigEndian(b, uint64(uint16(int16(fracPart/100))), 2)
+	case 5, 6:
+		return appendUintBigEndian(b, uint64(packed+offset), 6)
+	default:
+		return appendUintBigEndian(b, uint64(intPart+intOffset), 3)
+	}
+}
+
+// appendFrac appends fractional seconds of temporal values with given
+// precision.
+func appendFrac(b []byte, usec int, dec uint16) []byte {
+	switch dec {
+	case 1, 2:
+		return append(b, byte(usec/10000))
+	case 3, 4:
+		return appendUintBigEndian(b, uint64(usec/100), 2)
+	case 5, 6:
+		return appendUintBigEndian(b, uint64(usec), 3)
+	default:
+		return b
+	}
+}
+
+// Decimals are stored in groups of 9 digits that take 4 bytes, leftover
+// digits take fewer bytes.
+var compressedBytes = [...]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// appendDecimal appends a binary encoded decimal with given precision and
+// number of decimals. Extra fractional digits are truncated.
+// Spec: https://dev.mysql.com/doc/refman/8.0/en/precision-math-decimal-characteristics.html
+func appendDecimal(b []byte, str string, precision, decimals int) ([]byte, error) {
+	neg := strings.HasPrefix(str, "-")
+	digits := strings.TrimPrefix(str, "-")
+	intg, frac := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intg, frac = digits[:i], digits[i+1:]
+	}
+	intg = strings.TrimLeft(intg, "0")
+	integral := precision - decimals
+	if integral < 0 || len(intg) > integral {
+		return nil, fmt.Errorf("decimal %s doesn't fit (%d,%d)", str, precision, decimals)
+	}
+	for _, c := range intg + frac {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid decimal %q", str)
+		}
+	}
+	intg = strings.Repeat("0", integral-len(intg)) + intg
+	if len(frac) > decimals {
+		frac = frac[:decimals]
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+
+	var mask byte
+	if neg {
+		mask = 0xFF
+	}
+	start := len(b)
+	appendWord := func(digits string) {
+		if digits == "" {
+			return
+		}
+		var v uint64
+		for _, c := range digits {
+			v = v*10 + uint64(c-'0')
+		}
+		size := compressedBytes[len(digits)]
+		for i := size - 1; i >= 0; i-- {
+			b = append(b, byte(v>>uint(i*8))^mask)
+		}
+	}
+	lead := integral % 9
+	appendWord(intg[:lead])
+	for i := lead; i < len(intg); i += 9 {
+		appendWord(intg[i : i+9])
+	}
+	for i := 0; i+9 <= len(frac); i += 9 {
+		appendWord(frac[i : i+9])
+	}
+	appendWord(frac[decimals-decimals%9:])
+	if len(b) > start {
+		b[start] ^= 0x80
+	}
+	return b, nil
+}
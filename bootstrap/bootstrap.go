@@ -0,0 +1,264 @@
+// Package bootstrap takes a consistent snapshot of tables and then streams
+// changes from the binary log position the snapshot corresponds to, so that a
+// consumer could build a full copy of the data without gaps or overlaps.
+// Snapshot rows are delivered just like rows of write rows events, with values
+// of the same types as values decoded from the binary log.
+package bootstrap
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader"
+	"github.com/juju/errors"
+)
+
+// Mode defines how the snapshot is made consistent with the binary log
+// position.
+type Mode int
+
+const (
+	// ModeConsistentRead takes a global read lock just long enough to start a
+	// transaction with a consistent snapshot and capture the position. Tables
+	// are then read within the transaction without blocking writes. It
+	// requires the RELOAD privilege.
+	ModeConsistentRead Mode = iota
+	// ModeLockTables locks snapshotted tables for reading until the snapshot
+	// is complete. Writes to those tables are blocked in the meantime. It's
+	// meant for servers where global read lock is not permitted.
+	ModeLockTables
+)
+
+// Table identifies a table to take a snapshot of.
+type Table struct {
+	Schema string
+	Name   string
+}
+
+// Config configures the bootstrap.
+type Config struct {
+	// Tables to take a snapshot of.
+	Tables []Table
+	// Mode of the snapshot, ModeConsistentRead by default.
+	Mode Mode
+	// ChunkSize is the number of rows selected at once. Default is 1000.
+	ChunkSize int
+	// UseGTID makes streaming start from the executed GTID set captured with
+	// the snapshot instead of the file position.
+	UseGTID bool
+	// Options are passed to the reader once the snapshot is complete.
+	Options []reader.Option
+}
+
+// Event is either a chunk of snapshot rows or an event read from the binary
+// log.
+type Event struct {
+	// Snapshot is set for snapshot rows.
+	Snapshot bool
+	// Table describes the table rows belong to. Column names, unsigned flags
+	// and primary key are always set for snapshot rows.
+	Table *binlog.TableDescription
+	// Rows contains snapshot rows in a form of a write rows event.
+	Rows binlog.RowsEvent
+	// Binlog is the event read from the binary log, it's set once the
+	// snapshot is complete.
+	Binlog *reader.Event
+}
+
+// DecodeRows returns snapshot rows or decodes rows of a binary log event.
+func (e *Event) DecodeRows() (binlog.RowsEvent, error) {
+	if e.Snapshot {
+		return e.Rows, nil
+	}
+	return e.Binlog.DecodeRows()
+}
+
+// Bootstrap delivers snapshot rows followed by events from the binary log.
+type Bootstrap struct {
+	dsn  string
+	sc   driver.Config
+	conf Config
+
+	db     *sql.DB
+	conn   *sql.Conn
+	status *driver.MasterStatus
+	tables []*tableSnapshot
+	reader *reader.Reader
+}
+
+// New takes a lock to capture the binary log position and prepares the
+// snapshot. Rows are read lazily by ReadEvent.
+func New(ctx context.Context, dsn string, sc driver.Config, conf Config) (*Bootstrap, error) {
+	if conf.ChunkSize <= 0 {
+		conf.ChunkSize = 1000
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, errors.Annotate(err, "open database")
+	}
+	b := &Bootstrap{dsn: dsn, sc: sc, conf: conf, db: db}
+	if err := b.begin(ctx); err != nil {
+		b.closeSnapshot()
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// begin locks tables, captures the position and loads table metadata.
+func (b *Bootstrap) begin(ctx context.Context) error {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return errors.Annotate(err, "establish connection")
+	}
+	b.conn = conn
+	// Timestamps are converted to the same values the binary log contains
+	if _, err := conn.ExecContext(ctx, "SET SESSION time_zone = '+00:00'"); err != nil {
+		return errors.Annotate(err, "set time zone")
+	}
+
+	switch b.conf.Mode {
+	case ModeConsistentRead:
+		if _, err := conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+			return errors.Annotate(err, "lock tables")
+		}
+		if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return errors.Annotate(err, "start transaction")
+		}
+		if err := b.captureStatus(ctx); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, "UNLOCK TABLES"); err != nil {
+			return errors.Annotate(err, "unlock tables")
+		}
+	case ModeLockTables:
+		if _, err := conn.ExecContext(ctx, lockTablesQuery(b.conf.Tables)); err != nil {
+			return errors.Annotate(err, "lock tables")
+		}
+		if err := b.captureStatus(ctx); err != nil {
+			return err
+		}
+	default:
+		return errors.New("Unknown snapshot mode")
+	}
+
+	for _, t := range b.conf.Tables {
+		ts, err := loadTable(ctx, conn, t, b.conf.ChunkSize)
+		if err != nil {
+			return errors.Annotatef(err, "load table %s.%s", t.Schema, t.Name)
+		}
+		b.tables = append(b.tables, ts)
+	}
+	return nil
+}
+
+// captureStatus reads the binary log position using a separate connection,
+// it's stable while tables are locked.
+func (b *Bootstrap) captureStatus(ctx context.Context) error {
+	conn, err := driver.ConnectContext(ctx, b.dsn, b.sc)
+	if err != nil {
+		return errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+	if b.status, err = conn.MasterStatus(ctx); err != nil {
+		return errors.Annotate(err, "read master status")
+	}
+	return nil
+}
+
+// Position returns the binary log position the snapshot corresponds to.
+func (b *Bootstrap) Position() binlog.Position {
+	return b.status.Position
+}
+
+// GTIDSet returns the set of transactions the snapshot contains.
+func (b *Bootstrap) GTIDSet() binlog.GTIDSet {
+	return b.status.ExecutedGTIDSet.Clone()
+}
+
+// ReadEvent returns the next chunk of snapshot rows. Once all tables are read
+// the snapshot is released and events are read from the binary log, starting
+// at the captured position.
+func (b *Bootstrap) ReadEvent(ctx context.Context) (*Event, error) {
+	for len(b.tables) > 0 {
+		ts := b.tables[0]
+		rows, err := ts.next(ctx, b.conn)
+		if err != nil {
+			return nil, errors.Annotatef(err, "read table %s.%s", ts.td.SchemaName, ts.td.TableName)
+		}
+		if len(rows) == 0 {
+			b.tables = b.tables[1:]
+			continue
+		}
+		return &Event{
+			Snapshot: true,
+			Table:    &ts.td,
+			Rows:     binlog.RowsEvent{Type: binlog.EventTypeWriteRowsV2, Rows: rows},
+		}, nil
+	}
+
+	if b.reader == nil {
+		if err := b.closeSnapshot(); err != nil {
+			return nil, err
+		}
+		if err := b.startStream(); err != nil {
+			return nil, err
+		}
+	}
+	evt, err := b.reader.ReadEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Table: evt.Table, Binlog: evt}, nil
+}
+
+func (b *Bootstrap) startStream() error {
+	sc := b.sc
+	opts := b.conf.Options
+	if b.conf.UseGTID {
+		opts = append(opts[:len(opts):len(opts)], reader.WithGTIDSet(b.status.ExecutedGTIDSet))
+	} else {
+		sc.File = b.status.Position.File
+		sc.Offset = uint32(b.status.Position.Offset)
+	}
+	r, err := reader.New(b.dsn, sc, opts...)
+	if err != nil {
+		return err
+	}
+	b.reader = r
+	return nil
+}
+
+// closeSnapshot ends the snapshot transaction or releases table locks.
+func (b *Bootstrap) closeSnapshot() error {
+	if b.conn == nil {
+		return nil
+	}
+	conn := b.conn
+	b.conn = nil
+	defer conn.Close()
+	q := "COMMIT"
+	if b.conf.Mode == ModeLockTables {
+		q = "UNLOCK TABLES"
+	}
+	_, err := conn.ExecContext(context.Background(), q)
+	return errors.Annotate(err, "release snapshot")
+}
+
+// Close releases the snapshot and closes the reader.
+func (b *Bootstrap) Close(ctx context.Context) error {
+	err := b.closeSnapshot()
+	if b.reader != nil {
+		if rerr := b.reader.Close(ctx); err == nil {
+			err = rerr
+		}
+	}
+	if derr := b.db.Close(); err == nil {
+		err = derr
+	}
+	return err
+}
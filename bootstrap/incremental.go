@@ -0,0 +1,276 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader"
+	"github.com/Vivino/bocadillo/sqlgen"
+	"github.com/juju/errors"
+)
+
+// Watermark kinds written before and after a chunk is selected.
+const (
+	WatermarkLow  = "low"
+	WatermarkHigh = "high"
+)
+
+// IncrementalConfig configures an incremental snapshot.
+type IncrementalConfig struct {
+	// Tables to take a snapshot of.
+	Tables []Table
+	// Watermark is the table watermarks are written to, it's created if it
+	// doesn't exist. Changes of this table must be replicated.
+	Watermark Table
+	// ChunkSize is the number of rows selected at once. Default is 1000.
+	ChunkSize int
+}
+
+// Incremental takes a snapshot of tables in chunks interleaved with the live
+// stream, without locking tables. Every chunk is selected between a low and a
+// high watermark written to the watermark table. Stream changes between the
+// watermarks that touch rows of the chunk make those rows stale, so they are
+// removed from the chunk. Remaining rows are delivered once the high watermark
+// is read from the stream. Rows are therefore never delivered older than the
+// stream changes preceding them. This is the approach described in "DBLog: A
+// Watermark Based Change-Data-Capture Framework".
+//
+// Tables are paginated by primary key, tables without primary keys are not
+// supported.
+type Incremental struct {
+	db        *sql.DB
+	conf      IncrementalConfig
+	tables    []*tableSnapshot
+	watermark string
+
+	// pending is the chunk waiting for its high watermark
+	pending *chunk
+}
+
+// chunk contains rows selected between watermarks keyed by primary key.
+type chunk struct {
+	id     string
+	td     *binlog.TableDescription
+	keys   []string
+	rows   map[string][]interface{}
+	opened bool
+}
+
+// NewIncremental creates an incremental snapshot and the watermark table.
+func NewIncremental(ctx context.Context, dsn string, conf IncrementalConfig) (*Incremental, error) {
+	if conf.ChunkSize <= 0 {
+		conf.ChunkSize = 1000
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, errors.Annotate(err, "open database")
+	}
+	s := &Incremental{
+		db:        db,
+		conf:      conf,
+		watermark: sqlgen.QuoteIdent(conf.Watermark.Schema) + "." + sqlgen.QuoteIdent(conf.Watermark.Name),
+	}
+	if err := s.init(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Incremental) init(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+s.watermark+
+		" (id INT NOT NULL PRIMARY KEY, value VARCHAR(64) NOT NULL)")
+	if err != nil {
+		return errors.Annotate(err, "create watermark table")
+	}
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+	for _, t := range s.conf.Tables {
+		ts, err := loadTable(ctx, conn, t, s.conf.ChunkSize)
+		if err != nil {
+			return errors.Annotatef(err, "load table %s.%s", t.Schema, t.Name)
+		}
+		if len(ts.td.PrimaryKey) == 0 {
+			return errors.Errorf("Table %s.%s has no primary key", t.Schema, t.Name)
+		}
+		s.tables = append(s.tables, ts)
+	}
+	return nil
+}
+
+// Done returns true once all tables are read and delivered.
+func (s *Incremental) Done() bool {
+	return len(s.tables) == 0 && s.pending == nil
+}
+
+// Pending returns true while a selected chunk waits for its high watermark to
+// be read from the stream. The next chunk could only be selected afterwards.
+func (s *Incremental) Pending() bool {
+	return s.pending != nil
+}
+
+// NextChunk writes a low watermark, selects the next chunk of rows and writes
+// a high watermark. Rows are delivered by Process once the watermarks are read
+// from the stream. False is returned if there are no more rows to select.
+// NextChunk must not be called concurrently with Process.
+func (s *Incremental) NextChunk(ctx context.Context) (bool, error) {
+	if s.pending != nil {
+		return false, errors.New("Previous chunk is pending")
+	}
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return false, errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+	// Timestamps are converted to the same values the binary log contains
+	if _, err := conn.ExecContext(ctx, "SET SESSION time_zone = '+00:00'"); err != nil {
+		return false, errors.Annotate(err, "set time zone")
+	}
+
+	for len(s.tables) > 0 {
+		ts := s.tables[0]
+		id, err := newWatermarkID()
+		if err != nil {
+			return false, err
+		}
+		if err := s.writeWatermark(ctx, conn, WatermarkLow, id); err != nil {
+			return false, err
+		}
+		rows, err := ts.next(ctx, conn)
+		if err != nil {
+			return false, errors.Annotatef(err, "read table %s.%s", ts.td.SchemaName, ts.td.TableName)
+		}
+		if len(rows) == 0 {
+			s.tables = s.tables[1:]
+			continue
+		}
+		if err := s.writeWatermark(ctx, conn, WatermarkHigh, id); err != nil {
+			return false, err
+		}
+		c := &chunk{id: id, td: &ts.td, rows: make(map[string][]interface{}, len(rows))}
+		for _, row := range rows {
+			key := rowKey(ts.td, row)
+			c.keys = append(c.keys, key)
+			c.rows[key] = row
+		}
+		s.pending = c
+		if ts.done {
+			s.tables = s.tables[1:]
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *Incremental) writeWatermark(ctx context.Context, conn *sql.Conn, kind, id string) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO "+s.watermark+" (id, value) VALUES (1, ?) "+
+		"ON DUPLICATE KEY UPDATE value = VALUES(value)", kind+":"+id)
+	return errors.Annotatef(err, "write %s watermark", kind)
+}
+
+// Process handles a transaction read from the stream. Changes of the watermark
+// table are removed from the transaction. Changes of rows of the pending chunk
+// made between its watermarks remove the rows from the chunk. Once the high
+// watermark is read the remaining rows of the chunk are returned as inserts,
+// they should be delivered after the changes of the transaction.
+func (s *Incremental) Process(txn *reader.Transaction) ([]reader.RowChange, error) {
+	var watermarks []reader.RowChange
+	changes := txn.Changes[:0]
+	for _, c := range txn.Changes {
+		if c.Table.SchemaName == s.conf.Watermark.Schema && c.Table.TableName == s.conf.Watermark.Name {
+			watermarks = append(watermarks, c)
+		} else {
+			changes = append(changes, c)
+		}
+	}
+	txn.Changes = changes
+
+	// Watermarks are written in transactions of their own, so changes of
+	// other tables are always read between watermarks
+	if p := s.pending; p != nil && p.opened {
+		err := txn.ForEachChange(func(c reader.RowChange) error {
+			if c.Table.SchemaName != p.td.SchemaName || c.Table.TableName != p.td.TableName {
+				return nil
+			}
+			for _, row := range [][]interface{}{c.Before, c.After} {
+				if row != nil {
+					delete(p.rows, rowKey(*p.td, row))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []reader.RowChange
+	for _, c := range watermarks {
+		rows = append(rows, s.watermarkRead(c)...)
+	}
+	return rows, nil
+}
+
+// watermarkRead handles a change of the watermark table and returns rows of
+// the pending chunk once its high watermark is read.
+func (s *Incremental) watermarkRead(c reader.RowChange) []reader.RowChange {
+	p := s.pending
+	if p == nil || len(c.After) < 2 {
+		return nil
+	}
+	val, _ := c.After[1].(string)
+	parts := strings.SplitN(val, ":", 2)
+	if len(parts) != 2 || parts[1] != p.id {
+		// Watermark of another process sharing the table
+		return nil
+	}
+	switch parts[0] {
+	case WatermarkLow:
+		p.opened = true
+	case WatermarkHigh:
+		if !p.opened {
+			return nil
+		}
+		rows := make([]reader.RowChange, 0, len(p.rows))
+		for _, key := range p.keys {
+			if row, ok := p.rows[key]; ok {
+				rows = append(rows, reader.RowChange{Type: reader.ChangeInsert, Table: *p.td, After: row})
+			}
+		}
+		s.pending = nil
+		return rows
+	}
+	return nil
+}
+
+// Close closes the database connection.
+func (s *Incremental) Close() error {
+	return s.db.Close()
+}
+
+// rowKey returns a string representation of primary key values of a row.
+func rowKey(td binlog.TableDescription, row []interface{}) string {
+	var b strings.Builder
+	for _, i := range td.PrimaryKey {
+		if i < len(row) {
+			fmt.Fprintf(&b, "%v\x00", row[i])
+		}
+	}
+	return b.String()
+}
+
+func newWatermarkID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Annotate(err, "generate watermark")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,64 @@
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIncrementalProcess(t *testing.T) {
+	wm := binlog.TableDescription{SchemaName: "meta", TableName: "watermarks", PrimaryKey: []int{0}}
+	td := binlog.TableDescription{SchemaName: "shop", TableName: "orders", PrimaryKey: []int{0}}
+	watermark := func(value string) *reader.Transaction {
+		return &reader.Transaction{Changes: []reader.RowChange{
+			{Type: reader.ChangeUpdate, Table: wm, Before: []interface{}{int32(1), "x"}, After: []interface{}{int32(1), value}},
+		}}
+	}
+	row := func(id int32, val string) []interface{} { return []interface{}{id, val} }
+
+	s := &Incremental{conf: IncrementalConfig{Watermark: Table{Schema: "meta", Name: "watermarks"}}}
+	s.pending = &chunk{id: "abc", td: &td, rows: map[string][]interface{}{}}
+	for _, r := range [][]interface{}{row(1, "a"), row(2, "b"), row(3, "c")} {
+		key := rowKey(td, r)
+		s.pending.keys = append(s.pending.keys, key)
+		s.pending.rows[key] = r
+	}
+
+	steps := []struct {
+		txn *reader.Transaction
+		exp []reader.RowChange
+	}{
+		// Changes before the low watermark don't affect the chunk
+		{txn: &reader.Transaction{Changes: []reader.RowChange{
+			{Type: reader.ChangeDelete, Table: td, Before: row(1, "a")},
+		}}},
+		{txn: watermark("low:other")},
+		{txn: watermark("low:abc")},
+		{txn: &reader.Transaction{Changes: []reader.RowChange{
+			{Type: reader.ChangeUpdate, Table: td, Before: row(2, "b"), After: row(2, "B")},
+		}}},
+		{txn: watermark("high:abc"), exp: []reader.RowChange{
+			{Type: reader.ChangeInsert, Table: td, After: row(1, "a")},
+			{Type: reader.ChangeInsert, Table: td, After: row(3, "c")},
+		}},
+	}
+	for i, step := range steps {
+		rows, err := s.Process(step.txn)
+		if err != nil {
+			t.Fatalf("Step %d: unexpected error: %v", i, err)
+		}
+		if diff := cmp.Diff(step.exp, rows); diff != "" {
+			t.Errorf("Step %d: rows mismatch (-want +got):\n%s", i, diff)
+		}
+		for _, c := range step.txn.Changes {
+			if c.Table.TableName == wm.TableName {
+				t.Errorf("Step %d: expected watermark changes to be removed", i)
+			}
+		}
+	}
+	if s.Pending() {
+		t.Errorf("Expected chunk to be delivered")
+	}
+}
@@ -0,0 +1,298 @@
+package bootstrap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/sqlgen"
+)
+
+// tableSnapshot reads rows of a table in chunks. Tables with a primary key are
+// paginated by the key, others by offset.
+type tableSnapshot struct {
+	td        binlog.TableDescription
+	chunkSize int
+	query     string
+	// after contains primary key values of the last row read
+	after  []interface{}
+	offset int
+	done   bool
+}
+
+// columnTypes maps data types reported by information schema to column types
+// used in the binary log.
+var columnTypes = map[string]mysql.ColumnType{
+	"tinyint":    mysql.ColumnTypeTiny,
+	"smallint":   mysql.ColumnTypeShort,
+	"mediumint":  mysql.ColumnTypeInt24,
+	"int":        mysql.ColumnTypeLong,
+	"bigint":     mysql.ColumnTypeLonglong,
+	"float":      mysql.ColumnTypeFloat,
+	"double":     mysql.ColumnTypeDouble,
+	"decimal":    mysql.ColumnTypeNewDecimal,
+	"year":       mysql.ColumnTypeYear,
+	"date":       mysql.ColumnTypeDate,
+	"time":       mysql.ColumnTypeTime2,
+	"timestamp":  mysql.ColumnTypeTimestamp2,
+	"datetime":   mysql.ColumnTypeDatetime2,
+	"char":       mysql.ColumnTypeVarchar,
+	"varchar":    mysql.ColumnTypeVarchar,
+	"binary":     mysql.ColumnTypeVarchar,
+	"varbinary":  mysql.ColumnTypeVarchar,
+	"tinytext":   mysql.ColumnTypeBlob,
+	"text":       mysql.ColumnTypeBlob,
+	"mediumtext": mysql.ColumnTypeBlob,
+	"longtext":   mysql.ColumnTypeBlob,
+	"tinyblob":   mysql.ColumnTypeBlob,
+	"blob":       mysql.ColumnTypeBlob,
+	"mediumblob": mysql.ColumnTypeBlob,
+	"longblob":   mysql.ColumnTypeBlob,
+	"json":       mysql.ColumnTypeJSON,
+	"enum":       mysql.ColumnTypeEnum,
+	"set":        mysql.ColumnTypeSet,
+	"bit":        mysql.ColumnTypeBit,
+	"geometry":   mysql.ColumnTypeGeometry,
+}
+
+// loadTable reads table metadata from information schema.
+func loadTable(ctx context.Context, conn *sql.Conn, t Table, chunkSize int) (*tableSnapshot, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE,
+			COALESCE(NUMERIC_PRECISION, 0), COALESCE(NUMERIC_SCALE, 0),
+			COALESCE(DATETIME_PRECISION, 0)
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION ASC
+	`, t.Schema, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	td := binlog.TableDescription{SchemaName: t.Schema, TableName: t.Name}
+	for rows.Next() {
+		var name, dataType, columnType string
+		var precision, scale, fsp uint16
+		if err := rows.Scan(&name, &dataType, &columnType, &precision, &scale, &fsp); err != nil {
+			return nil, err
+		}
+		ct, ok := columnTypes[strings.ToLower(dataType)]
+		if !ok {
+			// Spatial types other than geometry
+			ct = mysql.ColumnTypeGeometry
+		}
+		var meta uint16
+		switch ct {
+		case mysql.ColumnTypeNewDecimal:
+			meta = precision<<8 | scale
+		case mysql.ColumnTypeTime2, mysql.ColumnTypeTimestamp2, mysql.ColumnTypeDatetime2:
+			meta = fsp
+		}
+		td.ColumnNames = append(td.ColumnNames, name)
+		td.ColumnTypes = append(td.ColumnTypes, byte(ct))
+		td.ColumnMeta = append(td.ColumnMeta, meta)
+		td.Unsigned = append(td.Unsigned, strings.Contains(strings.ToLower(columnType), "unsigned"))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(td.ColumnNames) == 0 {
+		return nil, fmt.Errorf("table not found")
+	}
+	td.ColumnCount = uint64(len(td.ColumnNames))
+
+	pkRows, err := conn.QueryContext(ctx, `
+		SELECT COLUMN_NAME
+		FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+		ORDER BY ORDINAL_POSITION ASC
+	`, t.Schema, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer pkRows.Close()
+	for pkRows.Next() {
+		var name string
+		if err := pkRows.Scan(&name); err != nil {
+			return nil, err
+		}
+		for i, col := range td.ColumnNames {
+			if col == name {
+				td.PrimaryKey = append(td.PrimaryKey, i)
+			}
+		}
+	}
+	if err := pkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &tableSnapshot{td: td, chunkSize: chunkSize, query: selectQuery(td)}, nil
+}
+
+// selectQuery returns a query that selects all columns. Enum, set and bit
+// columns are selected as numbers, which is how they're logged.
+func selectQuery(td binlog.TableDescription) string {
+	cols := make([]string, len(td.ColumnNames))
+	for i, name := range td.ColumnNames {
+		cols[i] = sqlgen.QuoteIdent(name)
+		switch mysql.ColumnType(td.ColumnTypes[i]) {
+		case mysql.ColumnTypeEnum, mysql.ColumnTypeSet, mysql.ColumnTypeBit:
+			cols[i] += "+0"
+		}
+	}
+	return "SELECT " + strings.Join(cols, ", ") + " FROM " +
+		sqlgen.QuoteIdent(td.SchemaName) + "." + sqlgen.QuoteIdent(td.TableName)
+}
+
+// next returns the next chunk of rows, an empty chunk is returned once all
+// rows are read.
+func (ts *tableSnapshot) next(ctx context.Context, conn *sql.Conn) ([][]interface{}, error) {
+	if ts.done {
+		return nil, nil
+	}
+
+	query := ts.query
+	var args []interface{}
+	if pk := ts.td.PrimaryKey; len(pk) > 0 {
+		keys := make([]string, len(pk))
+		for i, idx := range pk {
+			keys[i] = sqlgen.QuoteIdent(ts.td.ColumnNames[idx])
+		}
+		if ts.after != nil {
+			query += " WHERE (" + strings.Join(keys, ", ") + ") > (" +
+				strings.TrimSuffix(strings.Repeat("?, ", len(pk)), ", ") + ")"
+			args = ts.after
+		}
+		query += " ORDER BY " + strings.Join(keys, ", ") + " LIMIT " + strconv.Itoa(ts.chunkSize)
+	} else {
+		query += " LIMIT " + strconv.Itoa(ts.offset) + ", " + strconv.Itoa(ts.chunkSize)
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res [][]interface{}
+	raw := make([]sql.RawBytes, len(ts.td.ColumnNames))
+	dest := make([]interface{}, len(raw))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(raw))
+		for i, b := range raw {
+			if row[i], err = convertValue(ts.td, i, b); err != nil {
+				return nil, fmt.Errorf("column %s: %v", ts.td.ColumnNames[i], err)
+			}
+		}
+		res = append(res, row)
+
+		if len(ts.td.PrimaryKey) > 0 {
+			ts.after = make([]interface{}, len(ts.td.PrimaryKey))
+			for i, idx := range ts.td.PrimaryKey {
+				ts.after[i] = string(raw[idx])
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ts.offset += len(res)
+	ts.done = len(res) < ts.chunkSize
+	return res, nil
+}
+
+// convertValue converts a value in text protocol representation into a value
+// of the same type as the one decoded from the binary log would have.
+func convertValue(td binlog.TableDescription, i int, b []byte) (interface{}, error) {
+	if b == nil {
+		return nil, nil
+	}
+	s := string(b)
+	ct := mysql.ColumnType(td.ColumnTypes[i])
+	unsigned := i < len(td.Unsigned) && td.Unsigned[i]
+
+	switch ct {
+	case mysql.ColumnTypeTiny, mysql.ColumnTypeShort, mysql.ColumnTypeInt24,
+		mysql.ColumnTypeLong, mysql.ColumnTypeLonglong:
+
+		// Integers are logged as unsigned values of the column size
+		var v uint64
+		if unsigned {
+			u, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			v = u
+		} else {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			v = uint64(n)
+		}
+		switch ct {
+		case mysql.ColumnTypeTiny:
+			return uint8(v), nil
+		case mysql.ColumnTypeShort:
+			return uint16(v), nil
+		case mysql.ColumnTypeInt24:
+			return uint32(v) & 0xFFFFFF, nil
+		case mysql.ColumnTypeLong:
+			return uint32(v), nil
+		default:
+			return v, nil
+		}
+	case mysql.ColumnTypeFloat:
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	case mysql.ColumnTypeDouble:
+		return strconv.ParseFloat(s, 64)
+	case mysql.ColumnTypeNewDecimal:
+		return mysql.NewDecimal(s), nil
+	case mysql.ColumnTypeYear:
+		v, err := strconv.ParseUint(s, 10, 16)
+		return uint16(v), err
+	case mysql.ColumnTypeDate, mysql.ColumnTypeTime2:
+		return s, nil
+	case mysql.ColumnTypeTimestamp2:
+		if strings.HasPrefix(s, "0000-00-00") {
+			return time.Time{}, nil
+		}
+		// Session time zone is UTC
+		t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", s, time.UTC)
+		return t.Local(), err
+	case mysql.ColumnTypeDatetime2:
+		if strings.HasPrefix(s, "0000-00-00") {
+			return time.Time{}, nil
+		}
+		return time.ParseInLocation("2006-01-02 15:04:05.999999", s, mysql.Timezone)
+	case mysql.ColumnTypeVarchar:
+		return s, nil
+	case mysql.ColumnTypeEnum, mysql.ColumnTypeSet, mysql.ColumnTypeBit:
+		return strconv.ParseUint(s, 10, 64)
+	default:
+		// Blobs, text, JSON and spatial values
+		return append([]byte(nil), b...), nil
+	}
+}
+
+// lockTablesQuery returns a query that locks tables for reading.
+func lockTablesQuery(tables []Table) string {
+	locks := make([]string, len(tables))
+	for i, t := range tables {
+		locks[i] = sqlgen.QuoteIdent(t.Schema) + "." + sqlgen.QuoteIdent(t.Name) + " READ"
+	}
+	return "LOCK TABLES " + strings.Join(locks, ", ")
+}
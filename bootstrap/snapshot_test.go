@@ -0,0 +1,67 @@
+package bootstrap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConvertValue(t *testing.T) {
+	td := binlog.TableDescription{
+		ColumnTypes: []byte{
+			byte(mysql.ColumnTypeTiny),
+			byte(mysql.ColumnTypeInt24),
+			byte(mysql.ColumnTypeLonglong),
+			byte(mysql.ColumnTypeNewDecimal),
+			byte(mysql.ColumnTypeDatetime2),
+			byte(mysql.ColumnTypeTimestamp2),
+			byte(mysql.ColumnTypeEnum),
+			byte(mysql.ColumnTypeVarchar),
+			byte(mysql.ColumnTypeBlob),
+		},
+		Unsigned: []bool{false, false, true},
+	}
+	tests := []struct {
+		in  string
+		exp interface{}
+	}{
+		{"-1", uint8(0xFF)},
+		{"-1", uint32(0xFFFFFF)},
+		{"18446744073709551615", uint64(18446744073709551615)},
+		{"100", mysql.NewDecimal("100.0")},
+		{"2019-01-02 03:04:05.5", time.Date(2019, 1, 2, 3, 4, 5, 5e8, mysql.Timezone)},
+		{"1970-01-01 00:00:01", time.Unix(1, 0)},
+		{"2", uint64(2)},
+		{"foo", "foo"},
+		{"bar", []byte("bar")},
+	}
+	for i, test := range tests {
+		out, err := convertValue(td, i, []byte(test.in))
+		if err != nil {
+			t.Fatalf("Unexpected error converting %q: %v", test.in, err)
+		}
+		if diff := cmp.Diff(test.exp, out, cmp.AllowUnexported(mysql.Decimal{})); diff != "" {
+			t.Errorf("Value mismatch for %q (-want +got):\n%s", test.in, diff)
+		}
+	}
+
+	if out, err := convertValue(td, 0, nil); out != nil || err != nil {
+		t.Errorf("Expected NULL to be converted to nil, got %v, %v", out, err)
+	}
+}
+
+func TestSelectQuery(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "orders",
+		ColumnNames: []string{"id", "status"},
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeEnum)},
+	}
+	exp := "SELECT `id`, `status`+0 FROM `shop`.`orders`"
+	if q := selectQuery(td); q != exp {
+		t.Errorf("Expected query %q, got %q", exp, q)
+	}
+}
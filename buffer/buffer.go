@@ -2,23 +2,38 @@ package buffer
 
 import (
 	"encoding/binary"
+	"errors"
 
 	"github.com/Vivino/bocadillo/mysql"
 )
 
+// ErrShortBuffer is returned by a checked buffer when a read goes past the end
+// of the buffer.
+var ErrShortBuffer = errors.New("Buffer is too short")
+
 // Buffer is a simple wrapper over a slice of bytes with a cursor. It allows for
 // easy command building and results parsing.
 type Buffer struct {
 	data []byte
 	pos  int
+
+	checked bool
+	err     error
 }
 
 // New creates a new buffer from a given slice of bytes and sets the cursor to
-// the beginning.
+// the beginning. Reads past the end of the buffer panic.
 func New(data []byte) *Buffer {
 	return &Buffer{data: data}
 }
 
+// NewChecked creates a new buffer just like New does, but reads past the end
+// of the buffer don't panic. Instead such reads return zero values and the
+// error is retained, it could be checked with Err once decoding is done.
+func NewChecked(data []byte) *Buffer {
+	return &Buffer{data: data, checked: true}
+}
+
 // NewCommandBuffer pre-allocates a buffer of a given size and reserves 4 bytes
 // at the beginning for the driver, these would be used to set command length
 // and sequence number.
@@ -26,13 +41,47 @@ func NewCommandBuffer(size int) *Buffer {
 	return &Buffer{data: make([]byte, size+4), pos: 4}
 }
 
+// Err returns the first error that occurred while reading from a checked
+// buffer.
+func (b *Buffer) Err() error {
+	return b.err
+}
+
+// fits returns true if n more bytes could be read. If the buffer is checked
+// and they can't, the error is recorded and the cursor is moved to the end.
+func (b *Buffer) fits(n int) bool {
+	if !b.checked || n >= 0 && b.pos+n <= len(b.data) {
+		return true
+	}
+	b.fail()
+	return false
+}
+
+// fail records a short buffer error and moves the cursor to the end.
+func (b *Buffer) fail() {
+	if b.err == nil {
+		b.err = ErrShortBuffer
+	}
+	b.pos = len(b.data)
+}
+
 // Skip advances the cursor by N bytes.
 func (b *Buffer) Skip(n int) {
+	if !b.fits(n) {
+		return
+	}
 	b.pos += n
 }
 
-// Read returns next N bytes and advances the cursor.
+// Read returns next N bytes and advances the cursor. Checked buffer returns N
+// zero bytes if there's not enough data.
 func (b *Buffer) Read(n int) []byte {
+	if !b.fits(n) {
+		if n < 0 {
+			return nil
+		}
+		return make([]byte, n)
+	}
 	b.pos += n
 	return b.data[b.pos-n : b.pos]
 }
@@ -42,6 +91,20 @@ func (b *Buffer) Cur() []byte {
 	return b.data[b.pos:]
 }
 
+// Peek returns remaining unread buffer without advancing the cursor. Checked
+// buffer returns at least N bytes, padding remaining data with zeroes, so that
+// decoders could read up to N bytes and then advance the cursor with Skip
+// which checks the bounds.
+func (b *Buffer) Peek(n int) []byte {
+	cur := b.data[b.pos:]
+	if !b.checked || len(cur) >= n {
+		return cur
+	}
+	padded := make([]byte, n)
+	copy(padded, cur)
+	return padded
+}
+
 // More returns true if there's more to read.
 func (b *Buffer) More() bool {
 	return b.pos < len(b.data)-1
@@ -84,7 +147,7 @@ func (b *Buffer) ReadUint64() uint64 {
 
 // ReadUintLenEnc reads a length-encoded integer and advances cursor accordingly.
 func (b *Buffer) ReadUintLenEnc() (val uint64, isNull bool, size int) {
-	val, isNull, size = mysql.DecodeUintLenEnc(b.Cur())
+	val, isNull, size = mysql.DecodeUintLenEnc(b.Peek(9))
 	b.Skip(size)
 	return
 }
@@ -114,8 +177,12 @@ func (b *Buffer) ReadStringNullTerm() []byte {
 }
 
 // ReadStringVarLen reads a variable-length string and advances cursor by the
-// same number of bytes.
+// same number of bytes. Checked buffer returns an empty string if there's not
+// enough data.
 func (b *Buffer) ReadStringVarLen(n int) []byte {
+	if !b.fits(n) {
+		return []byte{}
+	}
 	return mysql.DecodeStringVarLen(b.Read(n), n)
 }
 
@@ -123,15 +190,39 @@ func (b *Buffer) ReadStringVarLen(n int) []byte {
 // itself, then advances cursor by the same number of bytes.
 func (b *Buffer) ReadStringVarEnc(n int) []byte {
 	length := int(mysql.DecodeVarLen64(b.Read(n), n))
+	if !b.fits(length) {
+		return []byte{}
+	}
 	return mysql.DecodeStringVarLen(b.Read(length), length)
 }
 
+// ReadStringVarEncNoCopy is like ReadStringVarEnc but returns a slice of the
+// underlying buffer instead of a copy.
+func (b *Buffer) ReadStringVarEncNoCopy(n int) []byte {
+	length := int(mysql.DecodeVarLen64(b.Read(n), n))
+	if !b.fits(length) {
+		return []byte{}
+	}
+	return b.Read(length)
+}
+
 // ReadStringLenEnc reads a length-encoded string and advances cursor
 // accordingly.
 func (b *Buffer) ReadStringLenEnc() (str []byte, size int) {
-	str, size = mysql.DecodeStringLenEnc(b.Cur())
-	b.Skip(size)
-	return
+	if !b.checked {
+		str, size = mysql.DecodeStringLenEnc(b.Cur())
+		b.Skip(size)
+		return
+	}
+	length, _, n := b.ReadUintLenEnc()
+	if length > uint64(len(b.data)) {
+		b.fail()
+		return nil, n
+	}
+	if !b.fits(int(length)) {
+		return nil, n
+	}
+	return mysql.DecodeStringVarLen(b.Read(int(length)), int(length)), n + int(length)
 }
 
 // ReadStringEOF reads remaining contents of the buffer as a new string.
@@ -157,6 +248,18 @@ func (b *Buffer) WriteUint32(v uint32) {
 	b.pos += 4
 }
 
+// WriteUint64 writes given uint64 value to the buffer and advances cursor by 8.
+func (b *Buffer) WriteUint64(v uint64) {
+	binary.LittleEndian.PutUint64(b.data[b.pos:], v)
+	b.pos += 8
+}
+
+// WriteBytes writes given slice of bytes to the buffer and advances cursor by
+// its length.
+func (b *Buffer) WriteBytes(p []byte) {
+	b.pos += copy(b.data[b.pos:], p)
+}
+
 // WriteStringLenEnc writes a length-encoded string to the buffer and advances
 // cursor accordingly.
 func (b *Buffer) WriteStringLenEnc(s string) {
@@ -178,6 +281,16 @@ func (b *Buffer) WriteStringEOF(s string) {
 // ReadDecimal decodes a decimal value from the buffer anf then advances cursor
 // accordingly.
 func (b *Buffer) ReadDecimal(precision, decimals int) mysql.Decimal {
+	if b.checked {
+		size := mysql.DecimalBinarySize(precision, decimals)
+		if size <= 0 {
+			b.fail()
+			return mysql.Decimal{}
+		}
+		if !b.fits(size) {
+			return mysql.Decimal{}
+		}
+	}
 	dec, n := mysql.DecodeDecimal(b.Cur(), precision, decimals)
 	b.Skip(n)
 	return dec
@@ -0,0 +1,55 @@
+package buffer
+
+import (
+	"testing"
+)
+
+func TestCheckedBuffer(t *testing.T) {
+	buf := NewChecked([]byte{1, 2, 3})
+	if v := buf.ReadUint16(); v != 0x0201 {
+		t.Errorf("Expected 0x0201, got %#x", v)
+	}
+	if err := buf.Err(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v := buf.ReadUint32(); v != 0 {
+		t.Errorf("Expected short read to return zero, got %d", v)
+	}
+	if err := buf.Err(); err != ErrShortBuffer {
+		t.Errorf("Expected short buffer error, got %v", err)
+	}
+
+	buf = NewChecked([]byte{0xFC, 0xFF, 0xFF, 'a'})
+	if str, _ := buf.ReadStringLenEnc(); len(str) != 0 {
+		t.Errorf("Expected empty string, got %q", str)
+	}
+	if err := buf.Err(); err != ErrShortBuffer {
+		t.Errorf("Expected short buffer error, got %v", err)
+	}
+}
+
+func TestReadStringVarEncNoCopy(t *testing.T) {
+	data := []byte{3, 'a', 'b', 'c'}
+	str := NewChecked(data).ReadStringVarEncNoCopy(1)
+	if string(str) != "abc" {
+		t.Fatalf("Expected %q, got %q", "abc", str)
+	}
+	data[1] = 'x'
+	if str[0] != 'x' {
+		t.Errorf("Expected string to reference the buffer")
+	}
+}
+
+func BenchmarkReadIntegers(b *testing.B) {
+	data := make([]byte, 1+2+3+4+6+8)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		buf := NewChecked(data)
+		buf.ReadUint8()
+		buf.ReadUint16()
+		buf.ReadUint24()
+		buf.ReadUint32()
+		buf.ReadUint48()
+		buf.ReadUint64()
+	}
+}
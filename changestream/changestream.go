@@ -0,0 +1,200 @@
+// Package changestream serves committed transactions over gRPC, so that
+// services written in any language could consume changes without speaking the
+// MySQL protocol. The ChangeStream service is defined in
+// encode/pb/bocadillo.proto: Subscribe streams Transaction messages starting
+// at a cursor, and every transaction carries the cursor to resume at once the
+// stream breaks.
+//
+// Handler implements the gRPC protocol on top of net/http, which requires
+// HTTP/2: either TLS, or unencrypted HTTP/2 enabled on the server. Messages are
+// never compressed.
+//
+//	h := &changestream.Handler{
+//		NewReader: func(ctx context.Context, pos binlog.Position) (*reader.Reader, error) {
+//			return reader.New(dsn, driver.Config{ServerID: 1000, File: pos.File, Offset: uint32(pos.Offset)})
+//		},
+//	}
+//	log.Fatal(http.ListenAndServeTLS(":8443", "cert.pem", "key.pem", h))
+package changestream
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/encode/pb"
+	"github.com/Vivino/bocadillo/reader"
+	"github.com/juju/errors"
+)
+
+// SubscribeMethod is the path of the Subscribe method.
+const SubscribeMethod = "/bocadillo.ChangeStream/Subscribe"
+
+// maxRequestSize limits the size of request messages.
+const maxRequestSize = 1 << 20
+
+// Status codes.
+// Spec: https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	codeOK              = 0
+	codeInvalidArgument = 3
+	codeInternal        = 13
+	codeUnimplemented   = 12
+	codeUnavailable     = 14
+)
+
+// Handler serves the ChangeStream service. Every subscription gets a reader of
+// its own.
+type Handler struct {
+	// NewReader creates a reader for a subscription starting at the given
+	// position, which is empty if the client didn't send a cursor. The
+	// reader is closed once the subscription ends. It must be set.
+	NewReader func(ctx context.Context, pos binlog.Position) (*reader.Reader, error)
+	// ErrorLog is called with errors that end subscriptions, except for
+	// clients cancelling them.
+	ErrorLog func(err error)
+}
+
+// ServeHTTP serves a gRPC call.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "Not a gRPC call", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	if r.URL.Path != SubscribeMethod {
+		h.finish(w, codeUnimplemented, "Unknown method "+r.URL.Path)
+		return
+	}
+
+	var req pb.SubscribeRequest
+	msg, err := readMessage(r.Body)
+	if err == nil {
+		err = req.Unmarshal(msg)
+	}
+	if err != nil {
+		h.finish(w, codeInvalidArgument, err.Error())
+		return
+	}
+	var pos binlog.Position
+	if req.Cursor != nil {
+		pos = binlog.Position{File: req.Cursor.File, Offset: req.Cursor.Offset}
+	}
+
+	ctx := r.Context()
+	rd, err := h.NewReader(ctx, pos)
+	if err != nil {
+		h.logError(errors.Annotate(err, "create reader"))
+		h.finish(w, codeUnavailable, err.Error())
+		return
+	}
+	defer rd.Close(context.Background())
+
+	w.WriteHeader(http.StatusOK)
+	code, err := stream(ctx, w, rd)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		h.logError(err)
+		h.finish(w, code, err.Error())
+		return
+	}
+	h.finish(w, codeOK, "")
+}
+
+// stream writes transactions read from the reader until reading fails. The
+// end of the log of readers of files ends the stream successfully.
+func stream(ctx context.Context, w http.ResponseWriter, rd *reader.Reader) (int, error) {
+	flusher, _ := w.(http.Flusher)
+	txn := &pb.Transaction{}
+	for {
+		evt, err := rd.ReadEvent(ctx)
+		if errors.Cause(err) == reader.ErrEndOfLog {
+			return codeOK, nil
+		}
+		if err != nil {
+			return codeUnavailable, errors.Annotate(err, "read event")
+		}
+		done, err := txn.Add(evt)
+		if err != nil {
+			return codeInternal, errors.Annotatef(err, "decode %s at %s:%d", evt.Header.Type, evt.EndPosition.File, evt.Offset)
+		}
+		if !done {
+			continue
+		}
+		if _, err := w.Write(frame(txn.Marshal())); err != nil {
+			return codeUnavailable, errors.Annotate(err, "write message")
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		txn = &pb.Transaction{}
+	}
+}
+
+// finish sets the status of the call, which is sent in trailers.
+func (h *Handler) finish(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", encodeMessage(message))
+	}
+}
+
+func (h *Handler) logError(err error) {
+	if h.ErrorLog != nil {
+		h.ErrorLog(err)
+	}
+}
+
+// readMessage reads a length-prefixed message. Compressed messages are not
+// supported.
+// Spec: https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, errors.Annotate(err, "read message")
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("Compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxRequestSize {
+		return nil, errors.Errorf("Message of %d bytes is too large", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errors.Annotate(err, "read message")
+	}
+	return msg, nil
+}
+
+// frame prefixes a message with the compression flag and its length.
+func frame(msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// encodeMessage percent-encodes a status message.
+func encodeMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
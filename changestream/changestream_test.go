@@ -0,0 +1,89 @@
+package changestream
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/encode/pb"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+func TestSubscribe(t *testing.T) {
+	g := binlogtest.New()
+	g.FormatDescription()
+	var exp [][]byte
+	var cursors []pb.Position
+	for i := 0; i < 3; i++ {
+		g.Query("shop", "BEGIN")
+		g.XID(uint64(i))
+		txn := pb.Transaction{Position: pb.Position{File: g.Position().File, Offset: g.Position().Offset}}
+		exp = append(exp, txn.Marshal())
+		cursors = append(cursors, txn.Position)
+	}
+	dir, err := ioutil.TempDir("", "bocadillo")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, g.Position().File)
+	if err := ioutil.WriteFile(path, g.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(&Handler{
+		NewReader: func(ctx context.Context, pos binlog.Position) (*reader.Reader, error) {
+			return reader.NewFile(path, pos.Offset)
+		},
+	})
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	subscribe := func(req pb.SubscribeRequest) [][]byte {
+		httpReq, err := http.NewRequest(http.MethodPost, srv.URL+SubscribeMethod, bytes.NewReader(frame(req.Marshal())))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/grpc")
+		resp, err := srv.Client().Do(httpReq)
+		if err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+		defer resp.Body.Close()
+		var msgs [][]byte
+		for {
+			msg, err := readMessage(resp.Body)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, msg)
+		}
+		if st := resp.Trailer.Get("Grpc-Status"); st != "0" {
+			t.Errorf("Expected status 0, got %q: %s", st, resp.Trailer.Get("Grpc-Message"))
+		}
+		return msgs
+	}
+
+	if msgs := subscribe(pb.SubscribeRequest{}); len(msgs) != len(exp) {
+		t.Errorf("Expected %d transactions, got %d", len(exp), len(msgs))
+	}
+	// Streaming resumes after the transaction the cursor comes from
+	msgs := subscribe(pb.SubscribeRequest{Cursor: &cursors[0]})
+	if len(msgs) != 2 || !bytes.Equal(msgs[0], exp[1]) || !bytes.Equal(msgs[1], exp[2]) {
+		t.Errorf("Expected transactions %x, got %x", exp[1:], msgs)
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	if s := encodeMessage("100% done\n"); s != "100%25 done%0A" {
+		t.Errorf("Unexpected encoded message %q", s)
+	}
+}
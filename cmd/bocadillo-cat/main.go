@@ -0,0 +1,298 @@
+// Command bocadillo-cat prints events of a binary log, either streamed from a
+// server or read from a file, in a format similar to mysqlbinlog or as JSON
+// lines.
+//
+// Examples:
+//
+//	bocadillo-cat -dsn 'root@(127.0.0.1:3306)/' -file mysql-bin.000035 -tables shop.orders
+//	bocadillo-cat -path /var/lib/mysql/mysql-bin.000035 -format json -types write,update
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func main() {
+	dsn := flag.String("dsn", "", "Database source name")
+	id := flag.Uint("id", 1000, "Server ID (arbitrary, unique)")
+	file := flag.String("file", "", "Binary log file name on the server")
+	path := flag.String("path", "", "Path to a local binary log file, used instead of a server")
+	offset := flag.Uint("start-position", 4, "Log offset to start reading from")
+	stopPos := flag.Uint("stop-position", 0, "Log offset to stop reading at")
+	startTime := flag.String("start-datetime", "", "Skip events before this time ("+timeLayout+")")
+	stopTime := flag.String("stop-datetime", "", "Stop at the first event after this time ("+timeLayout+")")
+	follow := flag.Bool("follow", false, "Wait for new events once the end of the log is reached")
+	format := flag.String("format", "text", "Output format: text or json")
+	tables := flag.String("tables", "", "Comma separated list of tables to print rows events of (schema.table, schema.*)")
+	types := flag.String("types", "", "Comma separated list of event types to print (query, xid, write, update, delete, ...)")
+	flag.Parse()
+
+	validate(*dsn != "" || *path != "", "Either database source name or file path must be set")
+	validate(*path != "" || *file != "", "Binary log file is not set")
+	validate(*format == "text" || *format == "json", "Unknown output format")
+
+	var opts []reader.Option
+	var start time.Time
+	if *startTime != "" {
+		t, err := time.ParseInLocation(timeLayout, *startTime, time.Local)
+		validate(err == nil, "Invalid start time")
+		start = t
+	}
+	if *stopTime != "" {
+		t, err := time.ParseInLocation(timeLayout, *stopTime, time.Local)
+		validate(err == nil, "Invalid stop time")
+		opts = append(opts, reader.WithStopAtTime(t))
+	}
+	f := newFilter(*tables, *types)
+
+	var r *reader.Reader
+	var err error
+	if *path != "" {
+		if *stopPos > 0 {
+			opts = append(opts, reader.WithStopAtPosition(binlog.Position{File: filepath.Base(*path), Offset: uint64(*stopPos)}))
+		}
+		r, err = reader.NewFile(*path, uint64(*offset), opts...)
+	} else {
+		if *stopPos > 0 {
+			opts = append(opts, reader.WithStopAtPosition(binlog.Position{File: *file, Offset: uint64(*stopPos)}))
+		}
+		conf := driver.Config{
+			ServerID: uint32(*id),
+			File:     *file,
+			Offset:   uint32(*offset),
+		}
+		if !*follow {
+			conf.DumpFlags = driver.DumpFlagNonBlock
+		}
+		r, err = reader.New(*dsn, conf, opts...)
+	}
+	if err != nil {
+		log.Fatalf("Failed to create reader: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		cancel()
+	}()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	p := printer{w: out, json: *format == "json"}
+	for {
+		evt, err := r.ReadEvent(ctx)
+		if err == reader.ErrEndOfLog || err == reader.ErrStopReached || ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			log.Fatalf("Failed to read event: %v", err)
+		}
+		if !start.IsZero() && int64(evt.Header.Timestamp) < start.Unix() {
+			continue
+		}
+		if !f.match(evt) {
+			continue
+		}
+		if err := p.print(evt); err != nil {
+			out.Flush()
+			log.Fatalf("Failed to print event: %v", err)
+		}
+	}
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer closeCancel()
+	if err := r.Close(closeCtx); err != nil {
+		log.Printf("Failed to close reader: %v", err)
+	}
+}
+
+// filter selects events to print.
+type filter struct {
+	tables map[string]bool
+	types  map[binlog.EventType]bool
+}
+
+// typeAliases are short names for groups of event types.
+var typeAliases = map[string][]binlog.EventType{
+	"query":    {binlog.EventTypeQuery},
+	"xid":      {binlog.EventTypeXID},
+	"gtid":     {binlog.EventTypeGTID, binlog.EventTypeGTIDTagged},
+	"rotate":   {binlog.EventTypeRotate},
+	"tablemap": {binlog.EventTypeTableMap},
+	"write":    {binlog.EventTypeWriteRowsV0, binlog.EventTypeWriteRowsV1, binlog.EventTypeWriteRowsV2},
+	"update":   {binlog.EventTypeUpdateRowsV0, binlog.EventTypeUpdateRowsV1, binlog.EventTypeUpdateRowsV2},
+	"delete":   {binlog.EventTypeDeleteRowsV0, binlog.EventTypeDeleteRowsV1, binlog.EventTypeDeleteRowsV2},
+}
+
+func newFilter(tables, types string) filter {
+	var f filter
+	if tables != "" {
+		f.tables = make(map[string]bool)
+		for _, t := range strings.Split(tables, ",") {
+			f.tables[strings.TrimSpace(t)] = true
+		}
+	}
+	if types != "" {
+		f.types = make(map[binlog.EventType]bool)
+		for _, t := range strings.Split(types, ",") {
+			name := strings.ToLower(strings.TrimSpace(t))
+			if ets, ok := typeAliases[name]; ok {
+				for _, et := range ets {
+					f.types[et] = true
+				}
+				continue
+			}
+			// Full type names as printed, e.g. WriteRowsEventV2
+			found := false
+			for et := binlog.EventTypeUnknown; et < 0xFF; et++ {
+				if strings.ToLower(et.String()) == name {
+					f.types[et] = true
+					found = true
+				}
+			}
+			validate(found, "Unknown event type: "+t)
+		}
+	}
+	return f
+}
+
+// match returns true if the event should be printed. Table filter only applies
+// to rows events.
+func (f filter) match(evt *reader.Event) bool {
+	if f.types != nil && !f.types[evt.Header.Type] {
+		return false
+	}
+	if f.tables != nil && evt.Table != nil {
+		td := evt.Table
+		return f.tables[td.SchemaName+"."+td.TableName] || f.tables[td.SchemaName+".*"]
+	}
+	return true
+}
+
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p printer) print(evt *reader.Event) error {
+	if p.json {
+		b, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", b)
+		return err
+	}
+
+	ts := time.Unix(int64(evt.Header.Timestamp), 0).Format("060102 15:04:05")
+	fmt.Fprintf(p.w, "# at %d\n", evt.Offset)
+	fmt.Fprintf(p.w, "#%s server id %d  end_log_pos %d  %s\n",
+		ts, evt.Header.ServerID, evt.Header.NextOffset, evt.Header.Type.String())
+
+	switch evt.Header.Type {
+	case binlog.EventTypeQuery:
+		var qe binlog.QueryEvent
+		if err := qe.Decode(evt.Body()); err != nil {
+			return err
+		}
+		if len(qe.Schema) > 0 {
+			fmt.Fprintf(p.w, "use `%s`;\n", qe.Schema)
+		}
+		fmt.Fprintf(p.w, "%s;\n", qe.Query)
+	case binlog.EventTypeXID:
+		var xe binlog.XIDEvent
+		if err := xe.Decode(evt.Body()); err != nil {
+			return err
+		}
+		fmt.Fprintf(p.w, "COMMIT /* xid=%d */;\n", xe.XID)
+	case binlog.EventTypeGTID, binlog.EventTypeGTIDTagged:
+		fmt.Fprintf(p.w, "SET @@SESSION.GTID_NEXT= '%s';\n", evt.GTID.String())
+	case binlog.EventTypeRotate:
+		fmt.Fprintf(p.w, "# Rotate to %s  pos: %d\n", evt.EndPosition.File, evt.EndPosition.Offset)
+	default:
+		if evt.Table != nil && binlog.RowsEventVersion(evt.Header.Type) >= 0 {
+			return p.printRows(evt)
+		}
+	}
+	return nil
+}
+
+// printRows prints row images the way mysqlbinlog does in verbose mode.
+func (p printer) printRows(evt *reader.Event) error {
+	re, err := evt.DecodeRows()
+	if err != nil {
+		return err
+	}
+	td := evt.Table
+	name := fmt.Sprintf("`%s`.`%s`", td.SchemaName, td.TableName)
+	printRow := func(clause string, row []interface{}) {
+		fmt.Fprintf(p.w, "### %s\n", clause)
+		for i, val := range row {
+			fmt.Fprintf(p.w, "###   @%d=%s\n", i+1, formatValue(val, td.ColumnType(i)))
+		}
+	}
+	for _, c := range re.Changes() {
+		switch c.Type {
+		case binlog.ChangeInsert:
+			fmt.Fprintf(p.w, "### INSERT INTO %s\n", name)
+			printRow("SET", c.After)
+		case binlog.ChangeUpdate:
+			fmt.Fprintf(p.w, "### UPDATE %s\n", name)
+			printRow("WHERE", c.Before)
+			printRow("SET", c.After)
+		case binlog.ChangeDelete:
+			fmt.Fprintf(p.w, "### DELETE FROM %s\n", name)
+			printRow("WHERE", c.Before)
+		}
+	}
+	return nil
+}
+
+func formatValue(val interface{}, ct mysql.ColumnType) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return fmt.Sprintf("'%s'", strings.Replace(v, "'", "\\'", -1))
+	case []byte:
+		if ct == mysql.ColumnTypeJSON {
+			return fmt.Sprintf("'%s'", v)
+		}
+		return "base64:" + base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return fmt.Sprintf("'%s'", v.Format("2006-01-02 15:04:05.999999"))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func validate(cond bool, msg string) {
+	if !cond {
+		fmt.Println(msg)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,46 @@
+// Command bocadillo-golden verifies the decoder against a corpus of binary log
+// fixtures, see package golden for the corpus layout. Mismatches are printed
+// and the command exits with a non-zero status if there are any. Golden files
+// are regenerated with -update.
+//
+// Examples:
+//
+//	bocadillo-golden -dir testdata
+//	bocadillo-golden -dir testdata/mariadb-10.3 -update
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Vivino/bocadillo/binlogtest/golden"
+)
+
+func main() {
+	dir := flag.String("dir", "", "Directory containing binary log fixtures")
+	update := flag.Bool("update", false, "Write golden files instead of verifying them")
+	flag.Parse()
+
+	validate(*dir != "", "Fixture directory is not set")
+
+	mismatches, err := golden.Verify(*dir, *update)
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+	for _, m := range mismatches {
+		fmt.Println(m.Error())
+	}
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+func validate(cond bool, msg string) {
+	if !cond {
+		fmt.Println(msg)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,265 @@
+// Command bocadillo-lag monitors replication lag of one or more masters. It
+// streams the binary log of every master from its current position and
+// measures the time elapsed since the last received event was written. Lag is
+// reset to zero by heartbeats, which masters only send when there are no more
+// events to send. Lag is logged periodically and exposed in Prometheus text
+// format.
+//
+// Example:
+//
+//	bocadillo-lag -dsn 'repl@(db1:3306)/' -dsn 'repl@(db2:3306)/' -listen :9104
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string     { return strings.Join(*f, ",") }
+func (f *stringsFlag) Set(v string) error { *f = append(*f, v); return nil }
+
+func main() {
+	var dsns stringsFlag
+	flag.Var(&dsns, "dsn", "Database source name of a master, could be repeated")
+	id := flag.Uint("id", 1000, "Server ID (arbitrary, unique), masters are assigned consecutive IDs")
+	heartbeat := flag.Duration("heartbeat", time.Second, "Heartbeat period")
+	listen := flag.String("listen", ":9104", "Address to serve metrics on")
+	interval := flag.Duration("log-interval", 10*time.Second, "Interval between lag reports, zero disables logging")
+	flag.Parse()
+
+	validate(len(dsns) > 0, "Database source name is not set")
+	validate(*id != 0, "Server ID is not set")
+	validate(*heartbeat > 0, "Heartbeat period must be positive")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		log.Println("Shutdown requested")
+		cancel()
+	}()
+
+	monitors := make([]*monitor, len(dsns))
+	var wg sync.WaitGroup
+	for i, dsn := range dsns {
+		m, err := newMonitor(dsn, uint32(*id)+uint32(i), *heartbeat)
+		if err != nil {
+			log.Fatalf("Invalid database source name: %v", err)
+		}
+		monitors[i] = m
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.run(ctx)
+		}()
+	}
+
+	http.Handle("/metrics", metricsHandler(monitors))
+	srv := &http.Server{Addr: *listen}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+	}()
+
+	if *interval > 0 {
+		go func() {
+			t := time.NewTicker(*interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					for _, m := range monitors {
+						s := m.status()
+						log.Printf("Master %s: up=%t lag=%s position=%s:%d",
+							m.name, s.up, s.stats.Lag.Truncate(time.Millisecond),
+							s.stats.Position.File, s.stats.Position.Offset)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	srv.Shutdown(shutdownCtx)
+}
+
+// monitor follows the binary log of a single master.
+type monitor struct {
+	name      string
+	dsn       string
+	serverID  uint32
+	heartbeat time.Duration
+
+	mu sync.Mutex
+	r  *reader.Reader
+	up bool
+}
+
+type monitorStatus struct {
+	up    bool
+	stats reader.Stats
+}
+
+func newMonitor(dsn string, serverID uint32, heartbeat time.Duration) (*monitor, error) {
+	d, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	name := d.Socket
+	if name == "" {
+		name = d.Host + ":" + strconv.Itoa(d.Port)
+	}
+	return &monitor{name: name, dsn: dsn, serverID: serverID, heartbeat: heartbeat}, nil
+}
+
+// run streams the binary log until the context is done, reconnecting on
+// errors. Reading is always resumed from the current position of the master
+// since only fresh events matter for lag.
+func (m *monitor) run(ctx context.Context) {
+	const backoff = 5 * time.Second
+	for {
+		err := m.stream(ctx)
+		m.setReader(nil, false)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Master %s: %v", m.name, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *monitor) stream(ctx context.Context) error {
+	conf := driver.Config{ServerID: m.serverID}
+	conn, err := driver.ConnectContext(ctx, m.dsn, conf)
+	if err != nil {
+		return err
+	}
+	ms, err := conn.MasterStatus(ctx)
+	conn.Close()
+	if err != nil {
+		return err
+	}
+
+	conf.File = ms.Position.File
+	conf.Offset = uint32(ms.Position.Offset)
+	conf.ReadTimeout = 3 * m.heartbeat
+	r, err := reader.New(m.dsn, conf, reader.WithHeartbeatPeriod(m.heartbeat))
+	if err != nil {
+		return err
+	}
+	m.setReader(r, true)
+	// Reader is closed when streaming fails or to unblock it on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		r.Close(closeCtx)
+	}()
+
+	for {
+		if _, err := r.ReadEvent(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *monitor) setReader(r *reader.Reader, up bool) {
+	m.mu.Lock()
+	if r != nil {
+		m.r = r
+	}
+	m.up = up
+	m.mu.Unlock()
+}
+
+func (m *monitor) status() monitorStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := monitorStatus{up: m.up}
+	if m.r != nil {
+		s.stats = m.r.Stats()
+	}
+	return s
+}
+
+// metricsHandler serves metrics in Prometheus text exposition format.
+// Spec: https://prometheus.io/docs/instrumenting/exposition_formats/
+func metricsHandler(monitors []*monitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		statuses := make([]monitorStatus, len(monitors))
+		for i, m := range monitors {
+			statuses[i] = m.status()
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		writeMetric(w, "bocadillo_replication_lag_seconds", "gauge",
+			"Time elapsed since the last received event was written to the binary log.")
+		for i, m := range monitors {
+			fmt.Fprintf(w, "bocadillo_replication_lag_seconds{master=%q} %g\n", m.name, statuses[i].stats.Lag.Seconds())
+		}
+		writeMetric(w, "bocadillo_up", "gauge", "Whether the binary log stream is established.")
+		for i, m := range monitors {
+			up := 0
+			if statuses[i].up {
+				up = 1
+			}
+			fmt.Fprintf(w, "bocadillo_up{master=%q} %d\n", m.name, up)
+		}
+		writeMetric(w, "bocadillo_events_total", "counter", "Number of events received, by type.")
+		for i, m := range monitors {
+			types := make([]string, 0, len(statuses[i].stats.Events))
+			counts := make(map[string]uint64, len(statuses[i].stats.Events))
+			for et, n := range statuses[i].stats.Events {
+				types = append(types, et.String())
+				counts[et.String()] = n
+			}
+			sort.Strings(types)
+			for _, t := range types {
+				fmt.Fprintf(w, "bocadillo_events_total{master=%q,type=%q} %d\n", m.name, t, counts[t])
+			}
+		}
+	})
+}
+
+func writeMetric(w http.ResponseWriter, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func validate(cond bool, msg string) {
+	if !cond {
+		fmt.Println(msg)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
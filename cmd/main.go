@@ -44,7 +44,9 @@ func main() {
 		select {
 		case <-done:
 			log.Println("Closing reader")
-			err := reader.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := reader.Close(ctx)
 			if err != nil {
 				log.Fatalf("Failed to close reader: %v", err)
 			}
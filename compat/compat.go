@@ -0,0 +1,264 @@
+// Package compat is a compatibility harness that runs the type test matrix
+// against several servers and reports which column types are decoded
+// correctly by each server version. Every target must have binary logging
+// enabled in row format and a DSN with a database tables are created in:
+//
+//	report, err := compat.Run(ctx, []compat.Target{
+//		{Name: "mysql-5.7", DSN: "root@(127.0.0.1:3307)/compat"},
+//		{Name: "mariadb-10.3", DSN: "root@(127.0.0.1:3308)/compat"},
+//	}, compat.Matrix())
+//	report.WriteText(os.Stdout)
+package compat
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+// Target is a server the matrix is run against.
+type Target struct {
+	// Name identifies the target in the report, the server version is used
+	// if it's empty.
+	Name string
+	DSN  string
+	// ServerID is the replica server ID, 1000 by default.
+	ServerID uint32
+}
+
+// Status is the outcome of a single value test.
+type Status string
+
+// Statuses in order of severity.
+const (
+	// StatusSupported means the value was decoded correctly.
+	StatusSupported Status = "supported"
+	// StatusUnsupported means the server rejected the column or the value.
+	StatusUnsupported Status = "unsupported"
+	// StatusMismatch means the decoded value differs from the expected one.
+	StatusMismatch Status = "mismatch"
+	// StatusError means the value couldn't be read from the binary log.
+	StatusError Status = "error"
+)
+
+func (s Status) severity() int {
+	switch s {
+	case StatusSupported:
+		return 0
+	case StatusUnsupported:
+		return 1
+	case StatusMismatch:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// valueTimeout is how long a value is waited for in the binary log.
+const valueTimeout = 5 * time.Second
+
+// Run runs test cases against all targets sequentially. Failures are recorded
+// in the report, an error is only returned if the context is canceled.
+func Run(ctx context.Context, targets []Target, cases []Case) (*Report, error) {
+	rep := &Report{}
+	for _, t := range targets {
+		info, results := runTarget(ctx, t, cases)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rep.Targets = append(rep.Targets, info)
+		rep.Results = append(rep.Results, results...)
+	}
+	return rep, nil
+}
+
+// runner runs cases against a single target.
+type runner struct {
+	name string
+	db   *sql.DB
+	r    *reader.Reader
+}
+
+func runTarget(ctx context.Context, t Target, cases []Case) (TargetInfo, []Result) {
+	info := TargetInfo{Name: t.Name}
+	fail := func(err error) (TargetInfo, []Result) {
+		info.Error = err.Error()
+		return info, nil
+	}
+
+	db, err := sql.Open("mysql", t.DSN)
+	if err != nil {
+		return fail(err)
+	}
+	defer db.Close()
+	if err := db.QueryRowContext(ctx, "SELECT @@version").Scan(&info.Version); err != nil {
+		return fail(err)
+	}
+	if info.Name == "" {
+		info.Name = info.Version
+	}
+
+	q := "SHOW MASTER STATUS"
+	if driver.FeaturesOf(info.Version).Has(driver.FeatureBinaryLogStatus) {
+		q = "SHOW BINARY LOG STATUS"
+	}
+	var pos binlog.Position
+	var discard interface{}
+	if err := db.QueryRowContext(ctx, q).Scan(&pos.File, &pos.Offset, &discard, &discard, &discard); err != nil {
+		return fail(fmt.Errorf("get master status: %v", err))
+	}
+	conf := driver.Config{ServerID: t.ServerID, File: pos.File, Offset: uint32(pos.Offset)}
+	if conf.ServerID == 0 {
+		conf.ServerID = 1000
+	}
+	r, err := reader.New(t.DSN, conf)
+	if err != nil {
+		return fail(err)
+	}
+	defer r.Close(context.Background())
+
+	run := &runner{name: info.Name, db: db, r: r}
+	var results []Result
+	for i, c := range cases {
+		results = append(results, run.runCase(ctx, i, c)...)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return info, results
+}
+
+// runCase creates a table for the case, inserts values one by one and
+// compares them to values decoded from rows events.
+func (run *runner) runCase(ctx context.Context, n int, c Case) []Result {
+	results := make([]Result, len(c.Values))
+	for i, v := range c.Values {
+		results[i] = Result{Target: run.name, Column: c.Column.String(), Value: formatValue(v)}
+	}
+	setAll := func(s Status, err error) []Result {
+		for i := range results {
+			results[i].Status, results[i].Detail = s, err.Error()
+		}
+		return results
+	}
+
+	table := fmt.Sprintf("compat_%d_%d", time.Now().UnixNano(), n)
+	if _, err := run.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+		table, c.Column.definition())); err != nil {
+		return setAll(StatusUnsupported, err)
+	}
+	defer run.db.ExecContext(context.Background(), "DROP TABLE "+table)
+
+	for i, v := range c.Values {
+		exp := v
+		if c.Expected != nil {
+			exp = c.Expected[i]
+		}
+		res := &results[i]
+		if _, err := run.db.ExecContext(ctx, "INSERT INTO "+table+" VALUES (?)", v); err != nil {
+			res.Status, res.Detail = StatusUnsupported, err.Error()
+			continue
+		}
+		got, err := run.readValue(ctx, table, c.Column)
+		if err != nil {
+			// The stream is out of sync after a read failure, values of this
+			// case can't be attributed to their inserts anymore
+			for j := i; j < len(results); j++ {
+				results[j].Status, results[j].Detail = StatusError, err.Error()
+			}
+			break
+		}
+		if equal(exp, got, c.Column.Type) {
+			res.Status = StatusSupported
+		} else {
+			res.Status = StatusMismatch
+			res.Detail = "got " + formatValue(got)
+		}
+	}
+	return results
+}
+
+// readValue reads events until a rows event of the table and returns the
+// value of its only column.
+func (run *runner) readValue(ctx context.Context, table string, col Column) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, valueTimeout)
+	defer cancel()
+	for {
+		evt, err := run.r.ReadEvent(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if evt.Table == nil || evt.Table.TableName != table {
+			continue
+		}
+		re, err := evt.DecodeRows()
+		if err != nil {
+			return nil, err
+		}
+		if len(re.Rows) != 1 || len(re.Rows[0]) != 1 {
+			return nil, fmt.Errorf("unexpected rows event with %d rows", len(re.Rows))
+		}
+		val := re.Rows[0][0]
+		if !col.Unsigned {
+			val = signNumber(val, col.Type)
+		}
+		return val, nil
+	}
+}
+
+// equal compares an expected value to a decoded one. JSON documents are
+// compared by value and times are compared by instant.
+func equal(exp, got interface{}, ct mysql.ColumnType) bool {
+	switch texp := exp.(type) {
+	case []byte:
+		b, ok := got.([]byte)
+		if !ok {
+			return false
+		}
+		if ct == mysql.ColumnTypeJSON {
+			var jexp, jgot interface{}
+			if json.Unmarshal(texp, &jexp) != nil || json.Unmarshal(b, &jgot) != nil {
+				return false
+			}
+			return reflect.DeepEqual(jexp, jgot)
+		}
+		return bytes.Equal(texp, b)
+	case time.Time:
+		t, ok := got.(time.Time)
+		return ok && texp.Equal(t)
+	default:
+		return reflect.DeepEqual(exp, got)
+	}
+}
+
+func signNumber(val interface{}, ct mysql.ColumnType) interface{} {
+	switch tval := val.(type) {
+	case uint8:
+		return mysql.SignUint8(tval)
+	case uint16:
+		return mysql.SignUint16(tval)
+	case uint32:
+		if ct == mysql.ColumnTypeInt24 {
+			return mysql.SignUint24(tval)
+		}
+		return mysql.SignUint32(tval)
+	case uint64:
+		if ct == mysql.ColumnTypeSet || ct == mysql.ColumnTypeEnum || ct == mysql.ColumnTypeBit {
+			return val
+		}
+		return mysql.SignUint64(tval)
+	default:
+		return val
+	}
+}
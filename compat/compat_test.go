@@ -0,0 +1,64 @@
+package compat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		col Column
+		exp interface{}
+		got interface{}
+		eq  bool
+	}{
+		{Column{Type: mysql.ColumnTypeTiny}, int8(-1), uint8(0xFF), true},
+		{Column{Type: mysql.ColumnTypeTiny, Unsigned: true}, uint8(255), uint8(0xFF), true},
+		{Column{Type: mysql.ColumnTypeInt24}, int32(-1), uint32(0xFFFFFF), true},
+		{Column{Type: mysql.ColumnTypeLong}, int32(-1), uint32(0xFFFFFF), false},
+		{Column{Type: mysql.ColumnTypeSet}, uint64(5), uint64(5), true},
+		{Column{Type: mysql.ColumnTypeJSON}, []byte(`{"a": [1, 2]}`), []byte(`{"a":[1,2]}`), true},
+		{Column{Type: mysql.ColumnTypeBlob}, []byte(`{"a": [1, 2]}`), []byte(`{"a":[1,2]}`), false},
+		{Column{Type: mysql.ColumnTypeDecimal}, mysql.NewDecimal("1.0"), mysql.NewDecimal("1.0"), true},
+		{Column{Type: mysql.ColumnTypeTiny, Nullable: true}, nil, nil, true},
+	}
+	for _, test := range tests {
+		got := test.got
+		if !test.col.Unsigned {
+			got = signNumber(got, test.col.Type)
+		}
+		if eq := equal(test.exp, got, test.col.Type); eq != test.eq {
+			t.Errorf("%s: expected %v == %v to be %t", test.col.String(), test.exp, test.got, test.eq)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	rep := Report{
+		Targets: []TargetInfo{{Name: "mysql-5.7"}, {Name: "mariadb-10.3"}},
+		Results: []Result{
+			{Target: "mysql-5.7", Column: "JSON", Value: "{}", Status: StatusSupported},
+			{Target: "mariadb-10.3", Column: "JSON", Value: "{}", Status: StatusMismatch, Detail: `got "{}"`},
+			{Target: "mariadb-10.3", Column: "JSON", Value: "[]", Status: StatusUnsupported},
+		},
+	}
+	sum := rep.Summary()
+	if s := sum["JSON"]["mysql-5.7"]; s != StatusSupported {
+		t.Errorf("Expected JSON to be supported by MySQL, got %s", s)
+	}
+	if s := sum["JSON"]["mariadb-10.3"]; s != StatusMismatch {
+		t.Errorf("Expected mismatch to be reported for MariaDB, got %s", s)
+	}
+
+	var buf bytes.Buffer
+	if err := rep.WriteText(&buf); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if fields := strings.Fields(lines[1]); len(fields) != 3 || fields[1] != "supported" || fields[2] != "mismatch" {
+		t.Errorf("Unexpected summary row %q", lines[1])
+	}
+}
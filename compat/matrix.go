@@ -0,0 +1,190 @@
+package compat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// Column describes a column values of a case are stored in.
+type Column struct {
+	Type mysql.ColumnType
+	// Length is put in parentheses after the type name, e.g. "10,4" for
+	// decimals or "'a','b'" for enums.
+	Length   string
+	Unsigned bool
+	Nullable bool
+}
+
+// Case is a set of values stored in a column. Every value is inserted in its
+// own row and compared to the value decoded from the binary log.
+type Case struct {
+	Column Column
+	Values []interface{}
+	// Expected contains decoded values, if set it must have the same length
+	// as Values. Values are expected to be decoded unchanged otherwise.
+	Expected []interface{}
+}
+
+// String returns the column type as it's written in a table definition.
+func (c Column) String() string {
+	s := typeName(c.Type)
+	if c.Length != "" {
+		s += "(" + c.Length + ")"
+	}
+	if c.Unsigned {
+		s += " UNSIGNED"
+	}
+	if c.Nullable {
+		s += " NULL"
+	}
+	return s
+}
+
+// definition returns the column definition used in CREATE TABLE statements.
+func (c Column) definition() string {
+	def := "val " + typeName(c.Type)
+	if c.Length != "" {
+		def += "(" + c.Length + ")"
+	}
+	switch c.Type {
+	case mysql.ColumnTypeString, mysql.ColumnTypeVarchar:
+		def += " CHARACTER SET utf8mb4"
+	}
+	if c.Unsigned {
+		def += " UNSIGNED"
+	}
+	if c.Nullable {
+		return def + " NULL"
+	}
+	return def + " NOT NULL"
+}
+
+func typeName(ct mysql.ColumnType) string {
+	switch ct {
+	case mysql.ColumnTypeTiny:
+		return "TINYINT"
+	case mysql.ColumnTypeShort:
+		return "SMALLINT"
+	case mysql.ColumnTypeInt24:
+		return "MEDIUMINT"
+	case mysql.ColumnTypeLong:
+		return "INT"
+	case mysql.ColumnTypeLonglong:
+		return "BIGINT"
+	case mysql.ColumnTypeFloat:
+		return "FLOAT"
+	case mysql.ColumnTypeDouble:
+		return "DOUBLE"
+	case mysql.ColumnTypeDecimal, mysql.ColumnTypeNewDecimal:
+		return "DECIMAL"
+	case mysql.ColumnTypeYear:
+		return "YEAR"
+	case mysql.ColumnTypeDate:
+		return "DATE"
+	case mysql.ColumnTypeTime, mysql.ColumnTypeTime2:
+		return "TIME"
+	case mysql.ColumnTypeTimestamp, mysql.ColumnTypeTimestamp2:
+		return "TIMESTAMP"
+	case mysql.ColumnTypeDatetime, mysql.ColumnTypeDatetime2:
+		return "DATETIME"
+	case mysql.ColumnTypeString:
+		return "CHAR"
+	case mysql.ColumnTypeVarchar:
+		return "VARCHAR"
+	case mysql.ColumnTypeTinyblob:
+		return "TINYBLOB"
+	case mysql.ColumnTypeBlob:
+		return "BLOB"
+	case mysql.ColumnTypeMediumblob:
+		return "MEDIUMBLOB"
+	case mysql.ColumnTypeLongblob:
+		return "LONGBLOB"
+	case mysql.ColumnTypeSet:
+		return "SET"
+	case mysql.ColumnTypeEnum:
+		return "ENUM"
+	case mysql.ColumnTypeJSON:
+		return "JSON"
+	case mysql.ColumnTypeGeometry:
+		return "GEOMETRY"
+	case mysql.ColumnTypeBit:
+		return "BIT"
+	default:
+		return strings.ToUpper(ct.String())
+	}
+}
+
+// Matrix returns the type test matrix, which covers the same types and values
+// as the integration tests.
+func Matrix() []Case {
+	utc := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04:05.999999", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	values := func(vals ...interface{}) []interface{} { return vals }
+
+	cases := []Case{
+		{Column: Column{Type: mysql.ColumnTypeTiny}, Values: values(int8(-128), int8(-1), int8(0), int8(127))},
+		{Column: Column{Type: mysql.ColumnTypeTiny, Unsigned: true}, Values: values(uint8(0), uint8(200), uint8(255))},
+		{Column: Column{Type: mysql.ColumnTypeTiny, Unsigned: true, Nullable: true}, Values: values(uint8(1), nil)},
+		{Column: Column{Type: mysql.ColumnTypeShort}, Values: values(int16(-32768), int16(-1), int16(32767))},
+		{Column: Column{Type: mysql.ColumnTypeShort, Unsigned: true}, Values: values(uint16(0), uint16(65535))},
+		{Column: Column{Type: mysql.ColumnTypeInt24}, Values: values(int32(-8388608), int32(-1), int32(8388607))},
+		{Column: Column{Type: mysql.ColumnTypeInt24, Unsigned: true}, Values: values(uint32(0), uint32(16777215))},
+		{Column: Column{Type: mysql.ColumnTypeLong}, Values: values(int32(-2147483648), int32(-1), int32(2147483647))},
+		{Column: Column{Type: mysql.ColumnTypeLong, Unsigned: true}, Values: values(uint32(0), uint32(4294967295))},
+		{Column: Column{Type: mysql.ColumnTypeLonglong}, Values: values(int64(-9223372036854775808), int64(-1), int64(9223372036854775807))},
+		{Column: Column{Type: mysql.ColumnTypeLonglong, Unsigned: true}, Values: values(uint64(0), uint64(18446744073709551615))},
+		{Column: Column{Type: mysql.ColumnTypeFloat}, Values: values(float32(0), float32(-0.1), float32(1000.0001))},
+		{Column: Column{Type: mysql.ColumnTypeDouble}, Values: values(float64(0), -0.1, 123456789012345678901.123456789012345678901)},
+		{Column: Column{Type: mysql.ColumnTypeYear}, Values: values(int16(1901), int16(2155))},
+		{Column: Column{Type: mysql.ColumnTypeDate}, Values: values("1000-01-01", "2016-09-08", "9999-12-31")},
+		{Column: Column{Type: mysql.ColumnTypeTime}, Values: values("00:00:00", "12:34:56", "23:59:59")},
+		{Column: Column{Type: mysql.ColumnTypeTimestamp}, Values: values(utc("1975-01-01 00:00:01"), utc("2038-01-19 03:14:07"))},
+		{Column: Column{Type: mysql.ColumnTypeJSON}, Values: values([]byte(`{"hello": "world", "foo": [1, 2, 3.75]}`))},
+		{Column: Column{Type: mysql.ColumnTypeTinyblob}, Values: values([]byte{}, []byte{0, 1, 0xFF})},
+		{Column: Column{Type: mysql.ColumnTypeBlob}, Values: values([]byte(strings.Repeat("\x01", 65535)))},
+		{Column: Column{Type: mysql.ColumnTypeSet, Length: "'a','b','c'"}, Values: values("", "a", "a,c"), Expected: values(uint64(0), uint64(1), uint64(5))},
+		{Column: Column{Type: mysql.ColumnTypeEnum, Length: "'a','b','c'"}, Values: values("a", "c"), Expected: values(uint64(1), uint64(3))},
+	}
+	for _, length := range []string{"3,1", "10,4", "30,10"} {
+		c := Case{Column: Column{Type: mysql.ColumnTypeDecimal, Length: length}}
+		for _, v := range []string{"0.0", "1.0", "-1.0"} {
+			c.Values = append(c.Values, mysql.NewDecimal(v))
+		}
+		cases = append(cases, c)
+	}
+	for _, fsp := range []int{0, 3, 6} {
+		c := Case{Column: Column{Type: mysql.ColumnTypeDatetime, Length: fmt.Sprint(fsp)}}
+		for _, v := range []string{"1000-01-01 00:00:00", "2018-11-08 19:26:00.123456", "9999-12-31 23:59:59.999999"} {
+			c.Values = append(c.Values, utc(v).Truncate(fspUnit(fsp)))
+		}
+		cases = append(cases, c)
+	}
+	for _, ct := range []mysql.ColumnType{mysql.ColumnTypeString, mysql.ColumnTypeVarchar} {
+		for _, length := range []int{1, 255} {
+			c := Case{Column: Column{Type: ct, Length: fmt.Sprint(length)}}
+			for _, ch := range []string{"a", "1", "!"} {
+				c.Values = append(c.Values, strings.Repeat(ch, length))
+			}
+			cases = append(cases, c)
+		}
+	}
+	return cases
+}
+
+// fspUnit returns the smallest unit of time with the given fractional seconds
+// precision.
+func fspUnit(fsp int) time.Duration {
+	d := time.Second
+	for i := 0; i < fsp; i++ {
+		d /= 10
+	}
+	return d
+}
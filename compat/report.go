@@ -0,0 +1,129 @@
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report contains results of all values tested against all targets. It's
+// meant to be stored as JSON and compared across runs.
+type Report struct {
+	Targets []TargetInfo `json:"targets"`
+	Results []Result     `json:"results"`
+}
+
+// TargetInfo describes a target the matrix was run against.
+type TargetInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Error is set if the matrix couldn't be run against the target at all.
+	Error string `json:"error,omitempty"`
+}
+
+// Result is the outcome of a single value test.
+type Result struct {
+	Target string `json:"target"`
+	Column string `json:"column"`
+	Value  string `json:"value"`
+	Status Status `json:"status"`
+	// Detail contains the error or the decoded value of a mismatch.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Summary returns the status of every column type on every target, which is
+// the most severe status of its values. Column types are keyed by their
+// definition, targets by their names.
+func (r *Report) Summary() map[string]map[string]Status {
+	sum := make(map[string]map[string]Status)
+	for _, res := range r.Results {
+		byTarget, ok := sum[res.Column]
+		if !ok {
+			byTarget = make(map[string]Status)
+			sum[res.Column] = byTarget
+		}
+		if s, ok := byTarget[res.Target]; !ok || res.Status.severity() > s.severity() {
+			byTarget[res.Target] = res.Status
+		}
+	}
+	return sum
+}
+
+// WriteJSON writes the report as an indented JSON document.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the summary as a table with a row per column type and a
+// column per target, followed by details of failed values.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	header := []string{"TYPE"}
+	for _, t := range r.Targets {
+		header = append(header, t.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	sum := r.Summary()
+	seen := make(map[string]bool)
+	for _, res := range r.Results {
+		if seen[res.Column] {
+			continue
+		}
+		seen[res.Column] = true
+		row := []string{res.Column}
+		for _, t := range r.Targets {
+			s, ok := sum[res.Column][t.Name]
+			if !ok {
+				s = "-"
+			}
+			row = append(row, string(s))
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var details []string
+	for _, t := range r.Targets {
+		if t.Error != "" {
+			details = append(details, t.Name+": "+t.Error)
+		}
+	}
+	for _, res := range r.Results {
+		if res.Status != StatusSupported {
+			details = append(details, fmt.Sprintf("%s %s %s: %s %s", res.Target, res.Column, res.Value, res.Status, res.Detail))
+		}
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", strings.Join(details, "\n"))
+	return err
+}
+
+// formatValue returns a short representation of a value for the report.
+func formatValue(v interface{}) string {
+	const maxLen = 32
+	switch tv := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return tv.Format("2006-01-02 15:04:05.999999")
+	case []byte:
+		return formatValue(string(tv))
+	case string:
+		if len(tv) > maxLen {
+			return fmt.Sprintf("%q... (%d bytes)", tv[:maxLen], len(tv))
+		}
+		return fmt.Sprintf("%q", tv)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,87 @@
+package avro
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+var testTable = binlog.TableDescription{
+	SchemaName:  "shop",
+	TableName:   "orders",
+	ColumnCount: 4,
+	ColumnTypes: []byte{
+		byte(mysql.ColumnTypeLong),
+		byte(mysql.ColumnTypeVarchar),
+		byte(mysql.ColumnTypeNewDecimal),
+		byte(mysql.ColumnTypeDatetime2),
+	},
+	ColumnMeta:  []uint16{0, 64, 10<<8 | 2, 0},
+	ColumnNames: []string{"id", "status", "total", "created-at"},
+}
+
+func TestSchema(t *testing.T) {
+	s, err := NewSchema(testTable)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := `{"type":"record","name":"orders","namespace":"shop","fields":[` +
+		`{"name":"id","type":["null","int"],"default":null},` +
+		`{"name":"status","type":["null","string"],"default":null},` +
+		`{"name":"total","type":["null",{"type":"bytes","logicalType":"decimal","precision":10,"scale":2}],"default":null},` +
+		`{"name":"created_at","type":["null",{"type":"long","logicalType":"timestamp-micros"}],"default":null}]}`
+	if s.Row() != exp {
+		t.Errorf("Expected schema:\n%s\ngot:\n%s", exp, s.Row())
+	}
+}
+
+func TestAppendChange(t *testing.T) {
+	s, err := NewSchema(testTable)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	row := []interface{}{uint32(0xFFFFFFFF), "ok", mysql.NewDecimal("-1.5"), time.Unix(1, 0)}
+	b, err := s.AppendChange(nil, OpInsert, nil, row)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := []byte{
+		0x00,       // Op: insert
+		0x00,       // Before: null
+		0x02,       // After: row
+		0x02, 0x01, // id: -1
+		0x02, 0x04, 'o', 'k', // status: "ok"
+		0x02, 0x04, 0xFF, 0x6A, // total: -150
+		0x02, 0x80, 0x89, 0x7A, // created_at: 1000000
+	}
+	if !bytes.Equal(exp, b) {
+		t.Errorf("Expected %x, got %x", exp, b)
+	}
+}
+
+func TestAppendDecimal(t *testing.T) {
+	testCases := []struct {
+		str   string
+		scale int
+		exp   []byte
+	}{
+		{"0.0", 2, []byte{0x00}},
+		{"1.5", 2, []byte{0x00, 0x96}},
+		{"-1.5", 2, []byte{0xFF, 0x6A}},
+		{"12345.0", 0, []byte{0x30, 0x39}},
+		{"-128.0", 0, []byte{0xFF, 0x80}},
+	}
+	for _, tc := range testCases {
+		b, err := appendDecimal(nil, tc.str, tc.scale)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		exp := append([]byte{byte(len(tc.exp) * 2)}, tc.exp...)
+		if !bytes.Equal(exp, b) {
+			t.Errorf("%s: expected %x, got %x", tc.str, exp, b)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// AppendRow appends binary encoding of the row record to dst.
+func (s *Schema) AppendRow(dst []byte, row []interface{}) ([]byte, error) {
+	if len(row) != len(s.fields) {
+		return nil, fmt.Errorf("expected %d columns, got %d", len(s.fields), len(row))
+	}
+	for i, f := range s.fields {
+		if row[i] == nil {
+			dst = appendLong(dst, 0)
+			continue
+		}
+		dst = appendLong(dst, 1)
+		var err error
+		if dst, err = f.appendValue(dst, row[i]); err != nil {
+			return nil, fmt.Errorf("column %s: %v", f.name, err)
+		}
+	}
+	return dst, nil
+}
+
+// AppendChange appends binary encoding of the change record to dst. Before
+// image is nil for inserts, after image is nil for deletes.
+func (s *Schema) AppendChange(dst []byte, op Op, before, after []interface{}) ([]byte, error) {
+	dst = appendLong(dst, int64(op))
+	for _, row := range [][]interface{}{before, after} {
+		if row == nil {
+			dst = appendLong(dst, 0)
+			continue
+		}
+		dst = appendLong(dst, 1)
+		var err error
+		if dst, err = s.AppendRow(dst, row); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// EncodeRowsEvent encodes every row change of a rows event as a change
+// record.
+func (s *Schema) EncodeRowsEvent(re binlog.RowsEvent) ([][]byte, error) {
+	if binlog.RowsEventVersion(re.Type) < 0 {
+		return nil, fmt.Errorf("not a rows event: %s", re.Type.String())
+	}
+
+	rcs := re.Changes()
+	res := make([][]byte, 0, len(rcs))
+	for _, rc := range rcs {
+		var op Op
+		switch rc.Type {
+		case binlog.ChangeInsert:
+			op = OpInsert
+		case binlog.ChangeUpdate:
+			op = OpUpdate
+		case binlog.ChangeDelete:
+			op = OpDelete
+		}
+		b, err := s.AppendChange(nil, op, rc.Before, rc.After)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func (f field) appendValue(dst []byte, val interface{}) ([]byte, error) {
+	switch f.ct {
+	case mysql.ColumnTypeTiny, mysql.ColumnTypeShort, mysql.ColumnTypeInt24,
+		mysql.ColumnTypeLong, mysql.ColumnTypeLonglong, mysql.ColumnTypeYear,
+		mysql.ColumnTypeBit, mysql.ColumnTypeEnum, mysql.ColumnTypeSet:
+
+		v, ok := f.toInt64(val)
+		if !ok {
+			break
+		}
+		return appendLong(dst, v), nil
+	case mysql.ColumnTypeFloat:
+		v, ok := val.(float32)
+		if !ok {
+			break
+		}
+		return appendUint32(dst, math.Float32bits(v)), nil
+	case mysql.ColumnTypeDouble:
+		v, ok := val.(float64)
+		if !ok {
+			break
+		}
+		return appendUint64(dst, math.Float64bits(v)), nil
+	case mysql.ColumnTypeNewDecimal:
+		v, ok := val.(mysql.Decimal)
+		if !ok {
+			break
+		}
+		return appendDecimal(dst, v.String(), int(f.meta&0xFF))
+	case mysql.ColumnTypeTimestamp, mysql.ColumnTypeTimestamp2,
+		mysql.ColumnTypeDatetime, mysql.ColumnTypeDatetime2:
+
+		v, ok := val.(time.Time)
+		if !ok {
+			break
+		}
+		return appendLong(dst, v.Unix()*1e6+int64(v.Nanosecond()/1e3)), nil
+	default:
+		switch v := val.(type) {
+		case string:
+			return appendBytes(dst, []byte(v)), nil
+		case []byte:
+			return appendBytes(dst, v), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value type %T", val)
+}
+
+// toInt64 converts an integer value to int64. Unsigned integers decoded from
+// the binary log are signed unless the column is known to be unsigned.
+func (f field) toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case uint8:
+		if f.unsigned || f.ct != mysql.ColumnTypeTiny {
+			return int64(v), true
+		}
+		return int64(mysql.SignUint8(v)), true
+	case uint16:
+		if f.unsigned || f.ct != mysql.ColumnTypeShort {
+			return int64(v), true
+		}
+		return int64(mysql.SignUint16(v)), true
+	case uint32:
+		switch {
+		case f.unsigned:
+			return int64(v), true
+		case f.ct == mysql.ColumnTypeInt24:
+			return int64(mysql.SignUint24(v)), true
+		default:
+			return int64(mysql.SignUint32(v)), true
+		}
+	case uint64:
+		// Unsigned values larger than the maximum long wrap around
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// appendLong appends a zig-zag encoded variable length integer.
+func appendLong(dst []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(dst, buf[:n]...)
+}
+
+func appendBytes(dst, b []byte) []byte {
+	dst = appendLong(dst, int64(len(b)))
+	return append(dst, b...)
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+// appendDecimal appends a decimal as a two's complement big endian unscaled
+// integer with given scale.
+func appendDecimal(dst []byte, str string, scale int) ([]byte, error) {
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(str, "-")
+	integral, frac := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		integral, frac = str[:i], str[i+1:]
+	}
+	if len(frac) > scale {
+		frac = frac[:scale]
+	}
+	frac += strings.Repeat("0", scale-len(frac))
+
+	v, ok := new(big.Int).SetString(integral+frac, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal: %q", str)
+	}
+	var b []byte
+	if neg && v.Sign() != 0 {
+		n := v.BitLen()/8 + 1
+		v.Sub(new(big.Int).Lsh(big.NewInt(1), uint(n*8)), v)
+		b = v.Bytes()
+	} else {
+		b = v.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+	}
+	return appendBytes(dst, b), nil
+}
@@ -0,0 +1,193 @@
+// Package avro encodes row changes using Apache Avro binary encoding. Schemas
+// are derived from table descriptions, which makes the encoding suitable for
+// Kafka pipelines backed by a schema registry.
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// Schema describes rows of a table and changes to them.
+type Schema struct {
+	name      string
+	namespace string
+	fields    []field
+	row       string
+	change    string
+}
+
+type field struct {
+	name     string
+	ct       mysql.ColumnType
+	meta     uint16
+	unsigned bool
+	typ      interface{}
+}
+
+// Op is a type of row change.
+type Op int
+
+// Row change types. Values are indexes of the op enum symbols.
+const (
+	OpInsert Op = iota
+	OpUpdate
+	OpDelete
+)
+
+// Avro schema definitions, see https://avro.apache.org/docs/current/spec.html
+type (
+	recordSchema struct {
+		Type      string        `json:"type"`
+		Name      string        `json:"name"`
+		Namespace string        `json:"namespace,omitempty"`
+		Fields    []fieldSchema `json:"fields"`
+	}
+	fieldSchema struct {
+		Name    string      `json:"name"`
+		Type    interface{} `json:"type"`
+		Default interface{} `json:"default"`
+	}
+	enumSchema struct {
+		Type    string   `json:"type"`
+		Name    string   `json:"name"`
+		Symbols []string `json:"symbols"`
+	}
+	logicalSchema struct {
+		Type        string `json:"type"`
+		LogicalType string `json:"logicalType"`
+		Precision   int    `json:"precision,omitempty"`
+		Scale       int    `json:"scale,omitempty"`
+	}
+)
+
+// NewSchema derives a schema from a table description. Column names and
+// signedness are taken from full table metadata when available, otherwise
+// columns are named col_N and integers are considered signed.
+func NewSchema(td binlog.TableDescription) (*Schema, error) {
+	s := &Schema{
+		name:      avroName(td.TableName),
+		namespace: avroName(td.SchemaName),
+		fields:    make([]field, td.ColumnCount),
+	}
+	row := recordSchema{
+		Type:      "record",
+		Name:      s.name,
+		Namespace: s.namespace,
+		Fields:    make([]fieldSchema, td.ColumnCount),
+	}
+	for i := range s.fields {
+		f := field{
+			name: "col_" + strconv.Itoa(i),
+			ct:   td.ColumnType(i),
+			meta: td.ColumnMeta[i],
+		}
+		if i < len(td.ColumnNames) {
+			f.name = avroName(td.ColumnNames[i])
+		}
+		if i < len(td.Unsigned) {
+			f.unsigned = td.Unsigned[i]
+		}
+		typ, err := f.avroType()
+		if err != nil {
+			return nil, err
+		}
+		f.typ = typ
+		s.fields[i] = f
+		row.Fields[i] = fieldSchema{Name: f.name, Type: []interface{}{"null", typ}}
+	}
+
+	b, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	s.row = string(b)
+
+	fullName := s.name
+	if s.namespace != "" {
+		fullName = s.namespace + "." + s.name
+	}
+	change := recordSchema{
+		Type:      "record",
+		Name:      s.name + "_change",
+		Namespace: s.namespace,
+		Fields: []fieldSchema{
+			{Name: "op", Type: enumSchema{Type: "enum", Name: "op", Symbols: []string{"insert", "update", "delete"}}, Default: "insert"},
+			{Name: "before", Type: []interface{}{"null", row}},
+			{Name: "after", Type: []interface{}{"null", fullName}},
+		},
+	}
+	if b, err = json.Marshal(change); err != nil {
+		return nil, err
+	}
+	s.change = string(b)
+
+	return s, nil
+}
+
+// Row returns the JSON definition of the row record.
+func (s *Schema) Row() string {
+	return s.row
+}
+
+// Change returns the JSON definition of the change record, which contains the
+// change type and row images before and after the change.
+func (s *Schema) Change() string {
+	return s.change
+}
+
+func (f field) avroType() (interface{}, error) {
+	switch f.ct {
+	case mysql.ColumnTypeTiny, mysql.ColumnTypeShort, mysql.ColumnTypeInt24, mysql.ColumnTypeYear:
+		return "int", nil
+	case mysql.ColumnTypeLong:
+		if f.unsigned {
+			return "long", nil
+		}
+		return "int", nil
+	case mysql.ColumnTypeLonglong, mysql.ColumnTypeBit, mysql.ColumnTypeEnum, mysql.ColumnTypeSet:
+		return "long", nil
+	case mysql.ColumnTypeFloat:
+		return "float", nil
+	case mysql.ColumnTypeDouble:
+		return "double", nil
+	case mysql.ColumnTypeNewDecimal:
+		return logicalSchema{
+			Type:        "bytes",
+			LogicalType: "decimal",
+			Precision:   int(f.meta >> 8),
+			Scale:       int(f.meta & 0xFF),
+		}, nil
+	case mysql.ColumnTypeTimestamp, mysql.ColumnTypeTimestamp2,
+		mysql.ColumnTypeDatetime, mysql.ColumnTypeDatetime2:
+		return logicalSchema{Type: "long", LogicalType: "timestamp-micros"}, nil
+	case mysql.ColumnTypeDate, mysql.ColumnTypeTime, mysql.ColumnTypeTime2,
+		mysql.ColumnTypeVarchar, mysql.ColumnTypeVarstring, mysql.ColumnTypeString,
+		mysql.ColumnTypeJSON:
+		return "string", nil
+	case mysql.ColumnTypeBlob, mysql.ColumnTypeTinyblob, mysql.ColumnTypeMediumblob,
+		mysql.ColumnTypeLongblob, mysql.ColumnTypeGeometry:
+		return "bytes", nil
+	default:
+		return nil, fmt.Errorf("unsupported column type: %s", f.ct.String())
+	}
+}
+
+// avroName replaces characters that are not allowed in Avro names with
+// underscores.
+func avroName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
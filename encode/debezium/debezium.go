@@ -0,0 +1,243 @@
+// Package debezium renders row changes as Debezium change event envelopes, so
+// that bocadillo output could be consumed by existing Debezium sink
+// connectors. Envelopes are rendered the way Debezium's JSON converter does it
+// with schemas disabled and decimal.handling.mode set to string.
+// Format: https://debezium.io/documentation/reference/connectors/mysql.html
+package debezium
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+// Debezium operation types.
+const (
+	OpCreate = "c"
+	OpUpdate = "u"
+	OpDelete = "d"
+)
+
+// Envelope is a Debezium change event.
+type Envelope struct {
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+	Source Source                 `json:"source"`
+	Op     string                 `json:"op"`
+	TSMS   int64                  `json:"ts_ms"`
+}
+
+// Source describes the origin of a change event.
+type Source struct {
+	Version   string  `json:"version"`
+	Connector string  `json:"connector"`
+	Name      string  `json:"name"`
+	TSMS      int64   `json:"ts_ms"`
+	Snapshot  string  `json:"snapshot"`
+	DB        string  `json:"db"`
+	Table     string  `json:"table"`
+	ServerID  uint32  `json:"server_id"`
+	GTID      *string `json:"gtid"`
+	File      string  `json:"file"`
+	Pos       uint64  `json:"pos"`
+	Row       int     `json:"row"`
+}
+
+// Message is a rendered change event.
+type Message struct {
+	// Key contains primary key columns of the row, it's nil when the primary
+	// key is unknown.
+	Key   []byte
+	Value []byte
+}
+
+// Formatter renders change events.
+type Formatter struct {
+	// ServerName is the logical name of the server, it's used as the source
+	// name.
+	ServerName string
+	// Version is reported as the connector version.
+	Version string
+}
+
+// Format renders change events for every row change of a rows event. Other
+// events produce no messages. Column names and primary keys are only known
+// when the server logs full table metadata, otherwise columns are named col_N.
+func (f Formatter) Format(evt *reader.Event) ([]Message, error) {
+	if evt.Table == nil || binlog.RowsEventVersion(evt.Header.Type) < 0 {
+		return nil, nil
+	}
+	re, err := evt.DecodeRows()
+	if err != nil {
+		return nil, err
+	}
+	td := *evt.Table
+
+	src := Source{
+		Version:   f.Version,
+		Connector: "mysql",
+		Name:      f.ServerName,
+		TSMS:      int64(evt.Header.Timestamp) * 1000,
+		Snapshot:  "false",
+		DB:        td.SchemaName,
+		Table:     td.TableName,
+		ServerID:  evt.Header.ServerID,
+		File:      evt.EndPosition.File,
+		Pos:       evt.Offset,
+	}
+	if evt.GTID.GNO > 0 {
+		gtid := evt.GTID.String()
+		src.GTID = &gtid
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	rcs := re.Changes()
+	msgs := make([]Message, 0, len(rcs))
+	for i, rc := range rcs {
+		env := Envelope{Source: src, TSMS: now}
+		env.Source.Row = i
+		keyRow := rc.After
+		switch rc.Type {
+		case binlog.ChangeInsert:
+			env.Op = OpCreate
+			env.After = rowData(td, rc.After)
+		case binlog.ChangeUpdate:
+			env.Op = OpUpdate
+			env.Before = rowData(td, rc.Before)
+			env.After = rowData(td, rc.After)
+		case binlog.ChangeDelete:
+			env.Op = OpDelete
+			env.Before = rowData(td, rc.Before)
+			keyRow = rc.Before
+		}
+
+		var msg Message
+		if len(td.PrimaryKey) > 0 {
+			key := make(map[string]interface{}, len(td.PrimaryKey))
+			for _, idx := range td.PrimaryKey {
+				if idx < len(keyRow) {
+					key[columnName(td, idx)] = columnValue(td, idx, keyRow[idx])
+				}
+			}
+			if msg.Key, err = json.Marshal(key); err != nil {
+				return nil, err
+			}
+		}
+		if msg.Value, err = json.Marshal(env); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func rowData(td binlog.TableDescription, row []interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(row))
+	for i, val := range row {
+		data[columnName(td, i)] = columnValue(td, i, val)
+	}
+	return data
+}
+
+func columnName(td binlog.TableDescription, i int) string {
+	if i < len(td.ColumnNames) {
+		return td.ColumnNames[i]
+	}
+	return "col_" + strconv.Itoa(i)
+}
+
+// columnValue converts a decoded value using Debezium's default temporal
+// precision mode. Binary strings are base64 encoded, enum and set columns are
+// represented by their numeric values.
+func columnValue(td binlog.TableDescription, i int, val interface{}) interface{} {
+	ct := td.ColumnType(i)
+	unsigned := i < len(td.Unsigned) && td.Unsigned[i]
+	switch v := val.(type) {
+	case uint8:
+		if !unsigned && ct == mysql.ColumnTypeTiny {
+			return mysql.SignUint8(v)
+		}
+	case uint16:
+		if !unsigned && ct == mysql.ColumnTypeShort {
+			return mysql.SignUint16(v)
+		}
+	case uint32:
+		if !unsigned && ct == mysql.ColumnTypeInt24 {
+			return mysql.SignUint24(v)
+		}
+		if !unsigned && ct == mysql.ColumnTypeLong {
+			return mysql.SignUint32(v)
+		}
+	case uint64:
+		if !unsigned && ct == mysql.ColumnTypeLonglong {
+			return mysql.SignUint64(v)
+		}
+	case mysql.Decimal:
+		return v.String()
+	case []byte:
+		if ct == mysql.ColumnTypeJSON {
+			return string(v)
+		}
+	case string:
+		switch ct {
+		case mysql.ColumnTypeDate:
+			// Days since epoch
+			d, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return nil
+			}
+			return d.Unix() / 86400
+		case mysql.ColumnTypeTime, mysql.ColumnTypeTime2:
+			return timeMicros(v)
+		}
+	case time.Time:
+		switch ct {
+		case mysql.ColumnTypeTimestamp, mysql.ColumnTypeTimestamp2:
+			return v.UTC().Format(time.RFC3339Nano)
+		case mysql.ColumnTypeDatetime2:
+			if td.ColumnMeta[i] > 3 {
+				return v.Unix()*1e6 + int64(v.Nanosecond()/1e3)
+			}
+		}
+		return v.Unix()*1e3 + int64(v.Nanosecond()/1e6)
+	}
+	return val
+}
+
+// timeMicros converts a time value formatted as [-]HH:MM:SS[.ffffff] into
+// microseconds. Nil is returned for invalid values.
+func timeMicros(s string) interface{} {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	var frac int64
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits := (s[i+1:] + "000000")[:6]
+		f, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return nil
+		}
+		frac, s = f, s[:i]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil
+	}
+	var secs int64
+	for _, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return nil
+		}
+		secs = secs*60 + n
+	}
+	v := secs*1e6 + frac
+	if neg {
+		v = -v
+	}
+	return v
+}
@@ -0,0 +1,84 @@
+package debezium
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+func TestFormat(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "orders",
+		ColumnCount: 1,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0},
+		ColumnNames: []string{"id"},
+		PrimaryKey:  []int{0},
+	}
+	evt := &reader.Event{
+		Header: binlog.EventHeader{Type: binlog.EventTypeWriteRowsV1, Timestamp: 1465491411, ServerID: 223344},
+		Buffer: []byte{
+			0x2A, 0, 0, 0, 0, 0, // Table ID
+			0x00, 0x00, // Flags
+			0x01,                         // Column count
+			0x01,                         // Columns present
+			0x00, 0x07, 0x00, 0x00, 0x00, // Row
+		},
+		Offset:      154,
+		EndPosition: binlog.Position{File: "mysql-bin.000003", Offset: 200},
+		Table:       &td,
+	}
+
+	msgs, err := Formatter{ServerName: "dbserver1", Version: "1.0"}.Format(evt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+	if exp := `{"id":7}`; string(msgs[0].Key) != exp {
+		t.Errorf("Expected key %s, got %s", exp, msgs[0].Key)
+	}
+	// Processing time varies, compare the rest of the message
+	exp := `{"before":null,"after":{"id":7},"source":{"version":"1.0","connector":"mysql",` +
+		`"name":"dbserver1","ts_ms":1465491411000,"snapshot":"false","db":"shop","table":"orders",` +
+		`"server_id":223344,"gtid":null,"file":"mysql-bin.000003","pos":154,"row":0},"op":"c","ts_ms":`
+	if val := string(msgs[0].Value); len(val) < len(exp) || val[:len(exp)] != exp {
+		t.Errorf("Expected value to start with:\n%s\ngot:\n%s", exp, val)
+	}
+}
+
+func TestColumnValue(t *testing.T) {
+	td := binlog.TableDescription{
+		ColumnTypes: []byte{
+			byte(mysql.ColumnTypeTiny),
+			byte(mysql.ColumnTypeDate),
+			byte(mysql.ColumnTypeTime2),
+			byte(mysql.ColumnTypeDatetime2),
+			byte(mysql.ColumnTypeTimestamp2),
+			byte(mysql.ColumnTypeNewDecimal),
+		},
+		ColumnMeta: []uint16{0, 0, 0, 6, 0, 10<<8 | 2},
+	}
+	ts := time.Date(2018, 1, 2, 3, 4, 5, 6000, time.UTC)
+	testCases := []struct {
+		val interface{}
+		exp interface{}
+	}{
+		{uint8(0xFF), int8(-1)},
+		{"1970-01-11", int64(10)},
+		{"-01:00:00.5", int64(-3600500000)},
+		{ts, ts.Unix()*1e6 + 6},
+		{ts, "2018-01-02T03:04:05.000006Z"},
+		{mysql.NewDecimal("1.50"), "1.5"},
+	}
+	for i, tc := range testCases {
+		if v := columnValue(td, i, tc.val); v != tc.exp {
+			t.Errorf("Column %d: expected %v (%T), got %v (%T)", i, tc.exp, tc.exp, v, v)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Package maxwell renders row changes in the JSON format of Maxwell's daemon,
+// so that bocadillo could feed pipelines built for Maxwell.
+// Format: https://maxwells-daemon.io/dataformat/
+package maxwell
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+// Message is a single row change in Maxwell's format.
+type Message struct {
+	Database string                 `json:"database"`
+	Table    string                 `json:"table"`
+	Type     string                 `json:"type"`
+	TS       uint32                 `json:"ts"`
+	XID      uint64                 `json:"xid,omitempty"`
+	Commit   bool                   `json:"commit,omitempty"`
+	Position string                 `json:"position,omitempty"`
+	GTID     string                 `json:"gtid,omitempty"`
+	Data     map[string]interface{} `json:"data"`
+	Old      map[string]interface{} `json:"old,omitempty"`
+}
+
+// Formatter collects row changes of a transaction and renders them once the
+// transaction is committed, because every message carries the transaction ID
+// and the last one is marked with a commit flag.
+type Formatter struct {
+	msgs []*Message
+}
+
+// Add adds an event read from the binary log. Once the event that commits a
+// transaction is added, messages for all row changes of the transaction are
+// returned.
+func (f *Formatter) Add(evt *reader.Event) ([][]byte, error) {
+	var xid uint64
+	switch evt.Header.Type {
+	case binlog.EventTypeXID:
+		var xe binlog.XIDEvent
+		if err := xe.Decode(evt.Body()); err != nil {
+			return nil, err
+		}
+		xid = xe.XID
+	case binlog.EventTypeQuery:
+		var qe binlog.QueryEvent
+		if err := qe.Decode(evt.Body()); err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(strings.TrimSpace(string(qe.Query)), "COMMIT") {
+			return nil, nil
+		}
+	default:
+		if evt.Table == nil || binlog.RowsEventVersion(evt.Header.Type) < 0 {
+			return nil, nil
+		}
+		re, err := evt.DecodeRows()
+		if err != nil {
+			return nil, err
+		}
+		f.addRows(evt, re)
+		return nil, nil
+	}
+
+	msgs := f.msgs
+	f.msgs = nil
+	res := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		msg.XID = xid
+		msg.Commit = i == len(msgs)-1
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = b
+	}
+	return res, nil
+}
+
+func (f *Formatter) addRows(evt *reader.Event, re binlog.RowsEvent) {
+	td := *evt.Table
+	newMsg := func(typ string) *Message {
+		msg := &Message{
+			Database: td.SchemaName,
+			Table:    td.TableName,
+			Type:     typ,
+			TS:       evt.Header.Timestamp,
+			Position: evt.EndPosition.File + ":" + strconv.FormatUint(evt.EndPosition.Offset, 10),
+		}
+		if evt.GTID.GNO > 0 {
+			msg.GTID = evt.GTID.String()
+		}
+		return msg
+	}
+
+	for _, c := range re.Changes() {
+		switch c.Type {
+		case binlog.ChangeInsert:
+			msg := newMsg("insert")
+			msg.Data = rowData(td, c.After)
+			f.msgs = append(f.msgs, msg)
+		case binlog.ChangeUpdate:
+			msg := newMsg("update")
+			before := rowData(td, c.Before)
+			msg.Data = rowData(td, c.After)
+			// Old data only contains changed columns
+			msg.Old = make(map[string]interface{})
+			for col, val := range before {
+				if !equal(val, msg.Data[col]) {
+					msg.Old[col] = val
+				}
+			}
+			f.msgs = append(f.msgs, msg)
+		case binlog.ChangeDelete:
+			msg := newMsg("delete")
+			msg.Data = rowData(td, c.Before)
+			f.msgs = append(f.msgs, msg)
+		}
+	}
+}
+
+// rowData returns row values keyed by column names. Columns are named col_N
+// unless the server logs full table metadata.
+func rowData(td binlog.TableDescription, row []interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(row))
+	for i, val := range row {
+		name := "col_" + strconv.Itoa(i)
+		if i < len(td.ColumnNames) {
+			name = td.ColumnNames[i]
+		}
+		unsigned := i < len(td.Unsigned) && td.Unsigned[i]
+		data[name] = value(val, td.ColumnType(i), td.ColumnMeta[i], unsigned)
+	}
+	return data
+}
+
+// value converts a decoded value into one that is marshaled the way Maxwell
+// does it. Binary strings are base64 encoded.
+func value(val interface{}, ct mysql.ColumnType, meta uint16, unsigned bool) interface{} {
+	switch v := val.(type) {
+	case uint8:
+		if !unsigned && ct == mysql.ColumnTypeTiny {
+			return mysql.SignUint8(v)
+		}
+	case uint16:
+		if !unsigned && ct == mysql.ColumnTypeShort {
+			return mysql.SignUint16(v)
+		}
+	case uint32:
+		if !unsigned && ct == mysql.ColumnTypeInt24 {
+			return mysql.SignUint24(v)
+		}
+		if !unsigned && ct == mysql.ColumnTypeLong {
+			return mysql.SignUint32(v)
+		}
+	case uint64:
+		if !unsigned && ct == mysql.ColumnTypeLonglong {
+			return mysql.SignUint64(v)
+		}
+	case []byte:
+		if ct == mysql.ColumnTypeJSON {
+			if len(v) == 0 {
+				return nil
+			}
+			return json.RawMessage(v)
+		}
+	case time.Time:
+		layout := "2006-01-02 15:04:05"
+		if (ct == mysql.ColumnTypeDatetime2 || ct == mysql.ColumnTypeTimestamp2) && meta > 0 {
+			layout += "." + strings.Repeat("0", int(meta))
+		}
+		return v.Format(layout)
+	}
+	return val
+}
+
+// equal compares converted values.
+func equal(a, b interface{}) bool {
+	switch av := a.(type) {
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && string(av) == string(bv)
+	case json.RawMessage:
+		bv, ok := b.(json.RawMessage)
+		return ok && string(av) == string(bv)
+	default:
+		return a == b
+	}
+}
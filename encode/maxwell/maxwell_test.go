@@ -0,0 +1,59 @@
+package maxwell
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+func TestFormatter(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "orders",
+		ColumnCount: 2,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar)},
+		ColumnMeta:  []uint16{0, 64},
+		ColumnNames: []string{"id", "status"},
+	}
+	evt := &reader.Event{
+		Header:      binlog.EventHeader{Type: binlog.EventTypeUpdateRowsV2, Timestamp: 1449786310},
+		EndPosition: binlog.Position{File: "master.000006", Offset: 800911},
+		Table:       &td,
+	}
+	var f Formatter
+	f.addRows(evt, binlog.RowsEvent{
+		Type: binlog.EventTypeUpdateRowsV2,
+		Rows: [][]interface{}{
+			{uint32(1), "new"},
+			{uint32(1), "paid"},
+		},
+	})
+	f.addRows(evt, binlog.RowsEvent{
+		Type: binlog.EventTypeDeleteRowsV2,
+		Rows: [][]interface{}{{uint32(0xFFFFFFFF), nil}},
+	})
+
+	msgs, err := f.Add(&reader.Event{
+		Header: binlog.EventHeader{Type: binlog.EventTypeXID},
+		Buffer: []byte{0x10, 0x5A, 0x0E, 0, 0, 0, 0, 0},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := []string{
+		`{"database":"shop","table":"orders","type":"update","ts":1449786310,"xid":940560,` +
+			`"position":"master.000006:800911","data":{"id":1,"status":"paid"},"old":{"status":"new"}}`,
+		`{"database":"shop","table":"orders","type":"delete","ts":1449786310,"xid":940560,"commit":true,` +
+			`"position":"master.000006:800911","data":{"id":-1,"status":null}}`,
+	}
+	if len(msgs) != len(exp) {
+		t.Fatalf("Expected %d messages, got %d", len(exp), len(msgs))
+	}
+	for i := range exp {
+		if string(msgs[i]) != exp[i] {
+			t.Errorf("Expected message:\n%s\ngot:\n%s", exp[i], msgs[i])
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package pb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+// NewValue converts a decoded column value. Integers decoded from the binary
+// log are unsigned, they're converted to signed integers unless the column is
+// unsigned.
+func NewValue(val interface{}, ct mysql.ColumnType, unsigned bool) (Value, error) {
+	switch v := val.(type) {
+	case nil:
+		return Value{Kind: KindNull}, nil
+	case uint8:
+		if unsigned || ct != mysql.ColumnTypeTiny {
+			return Value{Kind: KindUint, Uint: uint64(v)}, nil
+		}
+		return Value{Kind: KindInt, Int: int64(mysql.SignUint8(v))}, nil
+	case uint16:
+		if unsigned || ct != mysql.ColumnTypeShort {
+			return Value{Kind: KindUint, Uint: uint64(v)}, nil
+		}
+		return Value{Kind: KindInt, Int: int64(mysql.SignUint16(v))}, nil
+	case uint32:
+		switch {
+		case unsigned:
+			return Value{Kind: KindUint, Uint: uint64(v)}, nil
+		case ct == mysql.ColumnTypeInt24:
+			return Value{Kind: KindInt, Int: int64(mysql.SignUint24(v))}, nil
+		default:
+			return Value{Kind: KindInt, Int: int64(mysql.SignUint32(v))}, nil
+		}
+	case uint64:
+		if unsigned || ct != mysql.ColumnTypeLonglong {
+			return Value{Kind: KindUint, Uint: v}, nil
+		}
+		return Value{Kind: KindInt, Int: mysql.SignUint64(v)}, nil
+	case int8:
+		return Value{Kind: KindInt, Int: int64(v)}, nil
+	case int16:
+		return Value{Kind: KindInt, Int: int64(v)}, nil
+	case int32:
+		return Value{Kind: KindInt, Int: int64(v)}, nil
+	case int64:
+		return Value{Kind: KindInt, Int: v}, nil
+	case float32:
+		return Value{Kind: KindFloat, Float: v}, nil
+	case float64:
+		return Value{Kind: KindDouble, Double: v}, nil
+	case string:
+		return Value{Kind: KindString, String: v}, nil
+	case []byte:
+		if ct == mysql.ColumnTypeJSON {
+			return Value{Kind: KindString, String: string(v)}, nil
+		}
+		return Value{Kind: KindBytes, Bytes: v}, nil
+	case mysql.Decimal:
+		return Value{Kind: KindDecimal, String: v.String()}, nil
+	case time.Time:
+		return Value{Kind: KindTimestampMicros, Int: v.Unix()*1e6 + int64(v.Nanosecond()/1e3)}, nil
+	default:
+		return Value{}, fmt.Errorf("unsupported value type %T", val)
+	}
+}
+
+// NewRow converts a decoded row.
+func NewRow(td binlog.TableDescription, row []interface{}) (*Row, error) {
+	r := &Row{Values: make([]Value, len(row))}
+	for i, val := range row {
+		unsigned := i < len(td.Unsigned) && td.Unsigned[i]
+		v, err := NewValue(val, td.ColumnType(i), unsigned)
+		if err != nil {
+			return nil, err
+		}
+		r.Values[i] = v
+	}
+	return r, nil
+}
+
+// NewRowChanges converts a decoded rows event into row changes.
+func NewRowChanges(td binlog.TableDescription, re binlog.RowsEvent) ([]*RowChange, error) {
+	if binlog.RowsEventVersion(re.Type) < 0 {
+		return nil, fmt.Errorf("not a rows event: %s", re.Type.String())
+	}
+
+	rcs := re.Changes()
+	changes := make([]*RowChange, 0, len(rcs))
+	for _, rc := range rcs {
+		c := &RowChange{
+			Op:      changeOp(rc.Type),
+			Schema:  td.SchemaName,
+			Table:   td.TableName,
+			Columns: td.ColumnNames,
+		}
+		var err error
+		if rc.Before != nil {
+			if c.Before, err = NewRow(td, rc.Before); err != nil {
+				return nil, err
+			}
+		}
+		if rc.After != nil {
+			if c.After, err = NewRow(td, rc.After); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// changeOp returns the operation of a row change type.
+func changeOp(ct binlog.ChangeType) Op {
+	switch ct {
+	case binlog.ChangeInsert:
+		return OpInsert
+	case binlog.ChangeUpdate:
+		return OpUpdate
+	default:
+		return OpDelete
+	}
+}
+
+// Add adds an event read from the binary log to the transaction. It returns
+// true once the event that ends the transaction is added, at which point the
+// transaction is complete. Events of skipped tables are ignored.
+func (m *Transaction) Add(evt *reader.Event) (bool, error) {
+	m.GTID = ""
+	if evt.GTID.GNO > 0 {
+		m.GTID = evt.GTID.String()
+	}
+
+	switch evt.Header.Type {
+	case binlog.EventTypeGTID, binlog.EventTypeGTIDTagged:
+		ge, err := binlog.DecodeGTIDEvent(evt.Header.Type, evt.Body())
+		if err != nil {
+			return false, err
+		}
+		m.LastCommitted, m.SequenceNumber = ge.LastCommitted, ge.SequenceNumber
+		m.ImmediateCommitTimestamp, m.OriginalCommitTimestamp = ge.ImmediateCommitTimestamp, ge.OriginalCommitTimestamp
+		return false, nil
+	case binlog.EventTypeXID:
+	case binlog.EventTypeQuery:
+		var qe binlog.QueryEvent
+		if err := qe.Decode(evt.Body()); err != nil {
+			return false, err
+		}
+		switch q := strings.TrimSpace(string(qe.Query)); {
+		case strings.EqualFold(q, "BEGIN"):
+			return false, nil
+		case strings.EqualFold(q, "COMMIT"):
+		default:
+			m.DDL = append(m.DDL, &DDL{Schema: string(qe.Schema), Query: q})
+		}
+	default:
+		if evt.Table == nil || binlog.RowsEventVersion(evt.Header.Type) < 0 {
+			return false, nil
+		}
+		re, err := evt.DecodeRows()
+		if err != nil {
+			return false, err
+		}
+		changes, err := NewRowChanges(*evt.Table, re)
+		if err != nil {
+			return false, err
+		}
+		m.Changes = append(m.Changes, changes...)
+		return false, nil
+	}
+
+	// XID and query events end transactions
+	m.Position = Position{File: evt.EndPosition.File, Offset: evt.EndPosition.Offset}
+	m.Timestamp = evt.Header.Timestamp
+	return true, nil
+}
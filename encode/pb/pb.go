@@ -0,0 +1,337 @@
+// Package pb implements protobuf messages for change events defined in
+// bocadillo.proto, so that events could be consumed by programs written in
+// any language. Messages only support marshaling, except for requests which are
+// also unmarshaled, both implemented by hand to avoid depending on a protobuf
+// runtime.
+package pb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrMalformed is returned when unmarshaling invalid protobuf encoding.
+var ErrMalformed = errors.New("Malformed protobuf message")
+
+// Position in the binary log.
+type Position struct {
+	File   string
+	Offset uint64
+}
+
+// ValueKind identifies which field of a value is set. Values match field
+// numbers of the kind oneof.
+type ValueKind int
+
+// Value kinds.
+const (
+	KindNull ValueKind = iota
+	KindInt
+	KindUint
+	KindFloat
+	KindDouble
+	KindString
+	KindBytes
+	KindDecimal
+	KindTimestampMicros
+)
+
+// Value of a column. String is used for string and decimal kinds, Int is used
+// for int and timestamp kinds.
+type Value struct {
+	Kind   ValueKind
+	Int    int64
+	Uint   uint64
+	Float  float32
+	Double float64
+	String string
+	Bytes  []byte
+}
+
+// Row is a row image.
+type Row struct {
+	Values []Value
+}
+
+// Op is a type of row change.
+type Op int
+
+// Row change types.
+const (
+	OpInsert Op = iota
+	OpUpdate
+	OpDelete
+)
+
+// RowChange is a change of a single row.
+type RowChange struct {
+	Op      Op
+	Schema  string
+	Table   string
+	Columns []string
+	Before  *Row
+	After   *Row
+}
+
+// DDL is a schema change statement.
+type DDL struct {
+	Schema string
+	Query  string
+}
+
+// Transaction contains changes committed together.
+type Transaction struct {
+	GTID      string
+	Position  Position
+	Timestamp uint32
+	Changes   []*RowChange
+	DDL       []*DDL
+	// LastCommitted and SequenceNumber form the logical clock of the
+	// transaction, see reader.Transaction.
+	LastCommitted  int64
+	SequenceNumber int64
+	// ImmediateCommitTimestamp and OriginalCommitTimestamp are commit times
+	// in microseconds since Unix epoch, see reader.Transaction.
+	ImmediateCommitTimestamp uint64
+	OriginalCommitTimestamp  uint64
+}
+
+// SubscribeRequest is a request to stream transactions.
+type SubscribeRequest struct {
+	// Cursor is the position to resume streaming at, nil if unset.
+	Cursor *Position
+}
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Marshal returns the protobuf encoding of the position.
+func (m *Position) Marshal() []byte {
+	return m.appendTo(nil)
+}
+
+func (m *Position) appendTo(b []byte) []byte {
+	b = appendString(b, 1, m.File)
+	b = appendUint(b, 2, m.Offset)
+	return b
+}
+
+// Marshal returns the protobuf encoding of the value.
+func (m *Value) Marshal() []byte {
+	return m.appendTo(nil)
+}
+
+func (m *Value) appendTo(b []byte) []byte {
+	field := int(m.Kind)
+	switch m.Kind {
+	case KindInt:
+		b = appendTag(b, field, wireVarint)
+		b = appendVarint(b, uint64(m.Int<<1^m.Int>>63))
+	case KindUint:
+		b = appendTag(b, field, wireVarint)
+		b = appendVarint(b, m.Uint)
+	case KindFloat:
+		b = appendTag(b, field, wireFixed32)
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(m.Float))
+		b = append(b, buf[:]...)
+	case KindDouble:
+		b = appendTag(b, field, wireFixed64)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(m.Double))
+		b = append(b, buf[:]...)
+	case KindString, KindDecimal:
+		b = appendTag(b, field, wireBytes)
+		b = appendVarint(b, uint64(len(m.String)))
+		b = append(b, m.String...)
+	case KindBytes:
+		b = appendTag(b, field, wireBytes)
+		b = appendVarint(b, uint64(len(m.Bytes)))
+		b = append(b, m.Bytes...)
+	case KindTimestampMicros:
+		b = appendTag(b, field, wireVarint)
+		b = appendVarint(b, uint64(m.Int))
+	}
+	return b
+}
+
+// Marshal returns the protobuf encoding of the row.
+func (m *Row) Marshal() []byte {
+	return m.appendTo(nil)
+}
+
+func (m *Row) appendTo(b []byte) []byte {
+	for i := range m.Values {
+		b = appendMessage(b, 1, m.Values[i].appendTo(nil))
+	}
+	return b
+}
+
+// Marshal returns the protobuf encoding of the row change.
+func (m *RowChange) Marshal() []byte {
+	return m.appendTo(nil)
+}
+
+func (m *RowChange) appendTo(b []byte) []byte {
+	b = appendUint(b, 1, uint64(m.Op))
+	b = appendString(b, 2, m.Schema)
+	b = appendString(b, 3, m.Table)
+	for _, col := range m.Columns {
+		b = appendTag(b, 4, wireBytes)
+		b = appendVarint(b, uint64(len(col)))
+		b = append(b, col...)
+	}
+	if m.Before != nil {
+		b = appendMessage(b, 5, m.Before.appendTo(nil))
+	}
+	if m.After != nil {
+		b = appendMessage(b, 6, m.After.appendTo(nil))
+	}
+	return b
+}
+
+// Marshal returns the protobuf encoding of the statement.
+func (m *DDL) Marshal() []byte {
+	return m.appendTo(nil)
+}
+
+func (m *DDL) appendTo(b []byte) []byte {
+	b = appendString(b, 1, m.Schema)
+	b = appendString(b, 2, m.Query)
+	return b
+}
+
+// Marshal returns the protobuf encoding of the transaction.
+func (m *Transaction) Marshal() []byte {
+	b := appendString(nil, 1, m.GTID)
+	b = appendMessage(b, 2, m.Position.appendTo(nil))
+	b = appendUint(b, 3, uint64(m.Timestamp))
+	for _, c := range m.Changes {
+		b = appendMessage(b, 4, c.appendTo(nil))
+	}
+	for _, d := range m.DDL {
+		b = appendMessage(b, 5, d.appendTo(nil))
+	}
+	b = appendUint(b, 6, uint64(m.LastCommitted))
+	b = appendUint(b, 7, uint64(m.SequenceNumber))
+	b = appendUint(b, 8, m.ImmediateCommitTimestamp)
+	b = appendUint(b, 9, m.OriginalCommitTimestamp)
+	return b
+}
+
+// Marshal returns the protobuf encoding of the request.
+func (m *SubscribeRequest) Marshal() []byte {
+	if m.Cursor == nil {
+		return nil
+	}
+	return appendMessage(nil, 1, m.Cursor.appendTo(nil))
+}
+
+// Unmarshal decodes the protobuf encoding of the request. Unknown fields are
+// skipped.
+func (m *SubscribeRequest) Unmarshal(b []byte) error {
+	*m = SubscribeRequest{}
+	return readFields(b, func(field, wire int, v uint64, data []byte) error {
+		if field == 1 && wire == wireBytes {
+			m.Cursor = &Position{}
+			return m.Cursor.unmarshal(data)
+		}
+		return nil
+	})
+}
+
+func (m *Position) unmarshal(b []byte) error {
+	return readFields(b, func(field, wire int, v uint64, data []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.File = string(data)
+		case field == 2 && wire == wireVarint:
+			m.Offset = v
+		}
+		return nil
+	})
+}
+
+// readFields calls fn with every field of an encoded message. Varint values
+// are passed as v, length delimited ones as data.
+func readFields(b []byte, fn func(field, wire int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+		field, wire := int(tag>>3), int(tag&7)
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return ErrMalformed
+			}
+			b = b[n:]
+		case wireFixed64, wireFixed32:
+			n = 8
+			if wire == wireFixed32 {
+				n = 4
+			}
+			if len(b) < n {
+				return ErrMalformed
+			}
+			b = b[n:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return ErrMalformed
+			}
+			data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return ErrMalformed
+		}
+		if err := fn(field, wire, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field<<3|wire))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// appendUint appends a varint field, zero values are omitted.
+func appendUint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return appendVarint(b, v)
+}
+
+// appendString appends a string field, empty strings are omitted.
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendMessage(b []byte, field int, msg []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
@@ -0,0 +1,114 @@
+package pb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+func TestMarshal(t *testing.T) {
+	txn := Transaction{
+		Position: Position{File: "b.1", Offset: 300},
+		Changes: []*RowChange{{
+			Op:    OpUpdate,
+			Table: "t",
+			Before: &Row{Values: []Value{
+				{Kind: KindInt, Int: -1},
+				{Kind: KindNull},
+			}},
+		}},
+		LastCommitted:  4,
+		SequenceNumber: 5,
+	}
+	exp := []byte{
+		0x12, 0x08, // Position
+		0x0A, 0x03, 'b', '.', '1', // File
+		0x10, 0xAC, 0x02, // Offset
+		0x22, 0x0D, // Change
+		0x08, 0x01, // Op
+		0x1A, 0x01, 't', // Table
+		0x2A, 0x06, // Before
+		0x0A, 0x02, 0x08, 0x01, // Int value
+		0x0A, 0x00, // Null value
+		0x30, 0x04, // Last committed
+		0x38, 0x05, // Sequence number
+	}
+	if b := txn.Marshal(); !bytes.Equal(exp, b) {
+		t.Errorf("Expected %x, got %x", exp, b)
+	}
+}
+
+func TestNewRowChanges(t *testing.T) {
+	td := binlog.TableDescription{
+		TableName:   "t",
+		ColumnCount: 2,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeTiny), byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0, 0},
+		Unsigned:    []bool{false, true},
+	}
+	re := binlog.RowsEvent{
+		Type: binlog.EventTypeUpdateRowsV2,
+		Rows: [][]interface{}{
+			{uint8(0xFF), uint32(0xFFFFFFFF)},
+			{uint8(1), nil},
+		},
+	}
+	changes, err := NewRowChanges(td, re)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(changes))
+	}
+	c := changes[0]
+	if c.Op != OpUpdate || c.Before == nil || c.After == nil {
+		t.Fatalf("Unexpected change: %+v", c)
+	}
+	if v := c.Before.Values[0]; v.Kind != KindInt || v.Int != -1 {
+		t.Errorf("Expected signed -1, got %+v", v)
+	}
+	if v := c.Before.Values[1]; v.Kind != KindUint || v.Uint != 0xFFFFFFFF {
+		t.Errorf("Expected unsigned value, got %+v", v)
+	}
+	if v := c.After.Values[1]; v.Kind != KindNull {
+		t.Errorf("Expected null, got %+v", v)
+	}
+}
+
+func TestSubscribeRequest(t *testing.T) {
+	req := SubscribeRequest{Cursor: &Position{File: "b.1", Offset: 300}}
+	// Unknown fields are skipped
+	b := append([]byte{0x10, 0x01, 0x1A, 0x01, 'x'}, req.Marshal()...)
+	var got SubscribeRequest
+	if err := got.Unmarshal(b); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if got.Cursor == nil || *got.Cursor != *req.Cursor {
+		t.Errorf("Expected cursor %v, got %v", req.Cursor, got.Cursor)
+	}
+	if err := got.Unmarshal(b[:len(b)-1]); err != ErrMalformed {
+		t.Errorf("Expected malformed message error, got %v", err)
+	}
+}
+
+func TestTransactionAdd(t *testing.T) {
+	g := binlogtest.New()
+	g.Checksum = false
+	gtid := binlog.GTID{SID: binlog.SID{1}, GNO: 7, Tag: "tag"}
+	var txn Transaction
+	for _, evt := range []*reader.Event{
+		{Header: binlog.EventHeader{Type: binlog.EventTypeGTIDTagged}, Buffer: g.GTIDTagged(gtid, 4, 5)[19:], GTID: gtid},
+		{Header: binlog.EventHeader{Type: binlog.EventTypeXID}, GTID: gtid},
+	} {
+		if _, err := txn.Add(evt); err != nil {
+			t.Fatalf("Failed to add %s: %v", evt.Header.Type, err)
+		}
+	}
+	if txn.GTID != gtid.String() || txn.LastCommitted != 4 || txn.SequenceNumber != 5 {
+		t.Errorf("Unexpected transaction %+v", txn)
+	}
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// csvWriter writes rows as CSV with a header. NULL values are written as
+// empty fields, binary values are base64 encoded and timestamps are formatted
+// as RFC 3339 in UTC.
+type csvWriter struct {
+	f   *os.File
+	buf *bufio.Writer
+	w   *csv.Writer
+	rec []string
+}
+
+func newCSVWriter(f *os.File, cols []column) (*csvWriter, error) {
+	buf := bufio.NewWriter(f)
+	w := &csvWriter{f: f, buf: buf, w: csv.NewWriter(buf), rec: make([]string, len(cols))}
+	for i, c := range cols {
+		w.rec[i] = c.name
+	}
+	if err := w.w.Write(w.rec); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *csvWriter) write(row []interface{}) error {
+	for i, val := range row {
+		switch v := val.(type) {
+		case nil:
+			w.rec[i] = ""
+		case string:
+			w.rec[i] = v
+		case []byte:
+			w.rec[i] = base64.StdEncoding.EncodeToString(v)
+		case int64:
+			w.rec[i] = strconv.FormatInt(v, 10)
+		case float32:
+			w.rec[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+		case float64:
+			w.rec[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		case time.Time:
+			w.rec[i] = v.UTC().Format(time.RFC3339Nano)
+		default:
+			w.rec[i] = fmt.Sprint(v)
+		}
+	}
+	return w.w.Write(w.rec)
+}
+
+func (w *csvWriter) close() error {
+	w.w.Flush()
+	err := w.w.Error()
+	if err == nil {
+		err = w.buf.Flush()
+	}
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,332 @@
+// Package export writes row changes to files partitioned by table and time
+// window, so that changes could be ingested into a data lake straight from the
+// binary log. Every row change becomes a row that contains the operation, the
+// event timestamp and the row image: the new image for inserts and updates and
+// the old one for deletes.
+//
+// Files are laid out as DIR/SCHEMA/TABLE/WINDOW/part-N.EXT where WINDOW is the
+// UTC start time of the window formatted as 20060102T150405Z. Files are
+// written under a temporary name and renamed once complete, so readers never
+// see partial files.
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader"
+	"github.com/Vivino/bocadillo/sink"
+)
+
+// Format is a file format.
+type Format int
+
+// Supported formats.
+const (
+	FormatParquet Format = iota
+	FormatCSV
+)
+
+// Config configures the exporter.
+type Config struct {
+	// Dir is the root directory of exported files.
+	Dir string
+	// Format of the files, Parquet by default.
+	Format Format
+	// Window is the length of time windows rows are partitioned by. Default
+	// is one hour.
+	Window time.Duration
+}
+
+// Names of columns added to every row.
+const (
+	ColumnOp        = "_op"
+	ColumnTimestamp = "_ts"
+)
+
+// Operation names.
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Exporter writes row changes to files. It implements sink.Sink: files are
+// completed on Flush, so every flush starts new files.
+type Exporter struct {
+	conf  Config
+	files map[partition]*openFile
+	seq   int
+}
+
+var _ sink.Sink = &Exporter{}
+
+// partition identifies a file being written.
+type partition struct {
+	schema string
+	table  string
+	window int64
+}
+
+type openFile struct {
+	w       fileWriter
+	tmpPath string
+	path    string
+	// sig identifies table metadata rows were written with
+	sig string
+}
+
+// fileWriter writes rows of a single file.
+type fileWriter interface {
+	write(row []interface{}) error
+	close() error
+}
+
+// column describes an exported column.
+type column struct {
+	name string
+	kind kind
+}
+
+// kind is a type of exported values.
+type kind int
+
+const (
+	kindInt64 kind = iota
+	kindFloat
+	kindDouble
+	kindString
+	kindBytes
+	kindTimestamp
+)
+
+// New creates a new exporter.
+func New(conf Config) *Exporter {
+	if conf.Window <= 0 {
+		conf.Window = time.Hour
+	}
+	return &Exporter{conf: conf, files: make(map[partition]*openFile)}
+}
+
+// Write writes row changes of rows events, other events are ignored.
+func (e *Exporter) Write(ctx context.Context, batch []*reader.Event) error {
+	for _, evt := range batch {
+		if evt.Table == nil || binlog.RowsEventVersion(evt.Header.Type) < 0 {
+			continue
+		}
+		re, err := evt.DecodeRows()
+		if err != nil {
+			return err
+		}
+		if err := e.WriteRows(*evt.Table, evt.Header.Timestamp, re); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRows writes row changes of a decoded rows event with given timestamp.
+func (e *Exporter) WriteRows(td binlog.TableDescription, ts uint32, re binlog.RowsEvent) error {
+	f, err := e.file(td, ts)
+	if err != nil {
+		return err
+	}
+
+	write := func(op string, row []interface{}) error {
+		out := make([]interface{}, 0, len(row)+2)
+		out = append(out, op, time.Unix(int64(ts), 0))
+		for i, val := range row {
+			out = append(out, exportValue(td, i, val))
+		}
+		return f.w.write(out)
+	}
+	if binlog.RowsEventVersion(re.Type) < 0 {
+		return fmt.Errorf("not a rows event: %s", re.Type.String())
+	}
+	for _, c := range re.Changes() {
+		var err error
+		switch c.Type {
+		case binlog.ChangeInsert:
+			err = write(OpInsert, c.After)
+		case binlog.ChangeUpdate:
+			err = write(OpUpdate, c.After)
+		case binlog.ChangeDelete:
+			err = write(OpDelete, c.Before)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file returns the file rows of the table with given timestamp go to. A new
+// file is started when table metadata changes.
+func (e *Exporter) file(td binlog.TableDescription, ts uint32) (*openFile, error) {
+	window := time.Unix(int64(ts), 0).Truncate(e.conf.Window).Unix()
+	p := partition{schema: td.SchemaName, table: td.TableName, window: window}
+	cols := columns(td)
+	sig := signature(cols)
+	if f, ok := e.files[p]; ok {
+		if f.sig == sig {
+			return f, nil
+		}
+		if err := e.closeFile(p, f); err != nil {
+			return nil, err
+		}
+	}
+
+	dir := filepath.Join(e.conf.Dir, p.schema, p.table, time.Unix(window, 0).UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	e.seq++
+	name := "part-" + strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.Itoa(e.seq)
+	switch e.conf.Format {
+	case FormatCSV:
+		name += ".csv"
+	default:
+		name += ".parquet"
+	}
+	path := filepath.Join(dir, name)
+	tmpPath := filepath.Join(dir, "."+name+".tmp")
+	fd, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &openFile{tmpPath: tmpPath, path: path, sig: sig}
+	switch e.conf.Format {
+	case FormatCSV:
+		f.w, err = newCSVWriter(fd, cols)
+	default:
+		f.w = newParquetWriter(fd, cols)
+	}
+	if err != nil {
+		fd.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	e.files[p] = f
+	return f, nil
+}
+
+func (e *Exporter) closeFile(p partition, f *openFile) error {
+	delete(e.files, p)
+	if err := f.w.close(); err != nil {
+		os.Remove(f.tmpPath)
+		return err
+	}
+	return os.Rename(f.tmpPath, f.path)
+}
+
+// Flush completes all files being written.
+func (e *Exporter) Flush(ctx context.Context) error {
+	for p, f := range e.files {
+		if err := e.closeFile(p, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close completes all files being written.
+func (e *Exporter) Close() error {
+	return e.Flush(context.Background())
+}
+
+// columns returns exported columns of the table.
+func columns(td binlog.TableDescription) []column {
+	cols := []column{
+		{name: ColumnOp, kind: kindString},
+		{name: ColumnTimestamp, kind: kindTimestamp},
+	}
+	for i := range td.ColumnTypes {
+		name := "col_" + strconv.Itoa(i)
+		if i < len(td.ColumnNames) {
+			name = td.ColumnNames[i]
+		}
+		cols = append(cols, column{name: name, kind: columnKind(td.ColumnType(i))})
+	}
+	return cols
+}
+
+func signature(cols []column) string {
+	var sig []byte
+	for _, c := range cols {
+		sig = append(sig, c.name...)
+		sig = append(sig, byte(c.kind), 0)
+	}
+	return string(sig)
+}
+
+func columnKind(ct mysql.ColumnType) kind {
+	switch ct {
+	case mysql.ColumnTypeTiny, mysql.ColumnTypeShort, mysql.ColumnTypeInt24,
+		mysql.ColumnTypeLong, mysql.ColumnTypeLonglong, mysql.ColumnTypeYear,
+		mysql.ColumnTypeBit, mysql.ColumnTypeEnum, mysql.ColumnTypeSet:
+		return kindInt64
+	case mysql.ColumnTypeFloat:
+		return kindFloat
+	case mysql.ColumnTypeDouble:
+		return kindDouble
+	case mysql.ColumnTypeTimestamp, mysql.ColumnTypeTimestamp2,
+		mysql.ColumnTypeDatetime, mysql.ColumnTypeDatetime2:
+		return kindTimestamp
+	case mysql.ColumnTypeBlob, mysql.ColumnTypeTinyblob, mysql.ColumnTypeMediumblob,
+		mysql.ColumnTypeLongblob, mysql.ColumnTypeGeometry:
+		return kindBytes
+	default:
+		// Strings, decimals, JSON, dates and times
+		return kindString
+	}
+}
+
+// exportValue converts a decoded value to the type of its column kind.
+// Integers decoded from the binary log are unsigned, they're converted to
+// signed integers unless the column is unsigned. Unsigned values larger than
+// the maximum int64 wrap around.
+func exportValue(td binlog.TableDescription, i int, val interface{}) interface{} {
+	ct := td.ColumnType(i)
+	unsigned := i < len(td.Unsigned) && td.Unsigned[i]
+	switch v := val.(type) {
+	case uint8:
+		if !unsigned && ct == mysql.ColumnTypeTiny {
+			return int64(mysql.SignUint8(v))
+		}
+		return int64(v)
+	case uint16:
+		if !unsigned && ct == mysql.ColumnTypeShort {
+			return int64(mysql.SignUint16(v))
+		}
+		return int64(v)
+	case uint32:
+		if !unsigned && ct == mysql.ColumnTypeInt24 {
+			return int64(mysql.SignUint24(v))
+		}
+		if !unsigned && ct == mysql.ColumnTypeLong {
+			return int64(mysql.SignUint32(v))
+		}
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case mysql.Decimal:
+		return v.String()
+	case []byte:
+		if columnKind(ct) == kindString {
+			// JSON documents
+			return string(v)
+		}
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+	}
+	return val
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+var testTable = binlog.TableDescription{
+	SchemaName:  "shop",
+	TableName:   "orders",
+	ColumnCount: 3,
+	ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar), byte(mysql.ColumnTypeNewDecimal)},
+	ColumnMeta:  []uint16{0, 64, 10<<8 | 2},
+	ColumnNames: []string{"id", "status", "total"},
+}
+
+var testRows = binlog.RowsEvent{
+	Type: binlog.EventTypeUpdateRowsV2,
+	Rows: [][]interface{}{
+		{uint32(0xFFFFFFFF), "new", mysql.NewDecimal("1.5")},
+		{uint32(0xFFFFFFFF), "paid", nil},
+	},
+}
+
+func exportTestRows(t *testing.T, f Format) []byte {
+	dir, err := ioutil.TempDir("", "bocadillo")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := New(Config{Dir: dir, Format: f})
+	if err := e.WriteRows(testTable, 1546304461, testRows); err != nil {
+		t.Fatalf("Failed to write rows: %v", err)
+	}
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "shop", "orders", "20190101T010000Z", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected a single file in the partition, got %v", files)
+	}
+	b, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	return b
+}
+
+func TestExportCSV(t *testing.T) {
+	b := exportTestRows(t, FormatCSV)
+	exp := "_op,_ts,id,status,total\nupdate,2019-01-01T01:01:01Z,-1,paid,\n"
+	if string(b) != exp {
+		t.Errorf("Expected file contents %q, got %q", exp, b)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	b := exportTestRows(t, FormatParquet)
+	if !bytes.HasPrefix(b, parquetMagic) || !bytes.HasSuffix(b, parquetMagic) {
+		t.Fatal("Expected file to start and end with magic")
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta := b[len(b)-8-n : len(b)-8]
+	for _, col := range []string{"_op", "_ts", "id", "status", "total"} {
+		if !bytes.Contains(meta, []byte(col)) {
+			t.Errorf("Expected metadata to contain column %s", col)
+		}
+	}
+}
+
+func TestAppendDefinitionLevels(t *testing.T) {
+	defs := []bool{true, false, true, true, false, false, false, false, true}
+	exp := []byte{3, 0, 0, 0, 0x05, 0x0D, 0x01}
+	if b := appendDefinitionLevels(nil, defs); !bytes.Equal(b, exp) {
+		t.Errorf("Expected %x, got %x", exp, b)
+	}
+}
@@ -0,0 +1,338 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// parquetWriter writes rows as a Parquet file with a single row group. Rows
+// are buffered in columnar form until the file is closed. All columns are
+// optional and written as uncompressed PLAIN encoded data pages, which is the
+// baseline every Parquet reader supports.
+// Spec: https://github.com/apache/parquet-format
+type parquetWriter struct {
+	f    *os.File
+	cols []column
+	// defs contains definition levels of every column, values contains
+	// PLAIN encoded non-null values
+	defs   [][]bool
+	values [][]byte
+	rows   int
+}
+
+// Parquet physical types.
+const (
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet converted types.
+const (
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+)
+
+// Parquet enums used in metadata.
+const (
+	repetitionOptional = 1
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+func newParquetWriter(f *os.File, cols []column) *parquetWriter {
+	return &parquetWriter{
+		f:      f,
+		cols:   cols,
+		defs:   make([][]bool, len(cols)),
+		values: make([][]byte, len(cols)),
+	}
+}
+
+func (w *parquetWriter) write(row []interface{}) error {
+	if len(row) != len(w.cols) {
+		return fmt.Errorf("expected %d columns, got %d", len(w.cols), len(row))
+	}
+	for i, val := range row {
+		if val == nil {
+			w.defs[i] = append(w.defs[i], false)
+			continue
+		}
+		b, err := appendPlain(w.values[i], w.cols[i].kind, val)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", w.cols[i].name, err)
+		}
+		w.values[i] = b
+		w.defs[i] = append(w.defs[i], true)
+	}
+	w.rows++
+	return nil
+}
+
+func appendPlain(b []byte, k kind, val interface{}) ([]byte, error) {
+	var buf [8]byte
+	switch v := val.(type) {
+	case int64:
+		if k == kindInt64 {
+			binary.LittleEndian.PutUint64(buf[:], uint64(v))
+			return append(b, buf[:8]...), nil
+		}
+	case float32:
+		if k == kindFloat {
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+			return append(b, buf[:4]...), nil
+		}
+	case float64:
+		if k == kindDouble {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			return append(b, buf[:8]...), nil
+		}
+	case time.Time:
+		if k == kindTimestamp {
+			binary.LittleEndian.PutUint64(buf[:], uint64(v.Unix()*1e6+int64(v.Nanosecond()/1e3)))
+			return append(b, buf[:8]...), nil
+		}
+	case string:
+		if k == kindString || k == kindBytes {
+			binary.LittleEndian.PutUint32(buf[:], uint32(len(v)))
+			return append(append(b, buf[:4]...), v...), nil
+		}
+	case []byte:
+		if k == kindString || k == kindBytes {
+			binary.LittleEndian.PutUint32(buf[:], uint32(len(v)))
+			return append(append(b, buf[:4]...), v...), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value type %T", val)
+}
+
+func (w *parquetWriter) close() error {
+	bw := bufio.NewWriter(w.f)
+	offset := int64(0)
+	write := func(b []byte) {
+		bw.Write(b)
+		offset += int64(len(b))
+	}
+
+	write(parquetMagic)
+	chunks := make([][]byte, len(w.cols))
+	var total int64
+	for i, c := range w.cols {
+		page := appendDefinitionLevels(nil, w.defs[i])
+		page = append(page, w.values[i]...)
+
+		var ph thriftStruct
+		ph.i32(1, pageTypeData)
+		ph.i32(2, int32(len(page)))
+		ph.i32(3, int32(len(page)))
+		var dph thriftStruct
+		dph.i32(1, int32(w.rows))
+		dph.i32(2, encodingPlain)
+		dph.i32(3, encodingRLE)
+		dph.i32(4, encodingRLE)
+		ph.structField(5, dph.end())
+		header := ph.end()
+
+		pageOffset := offset
+		write(header)
+		write(page)
+		size := int64(len(header) + len(page))
+		total += size
+
+		var md thriftStruct
+		md.i32(1, physicalType(c.kind))
+		md.i32List(2, []int32{encodingPlain, encodingRLE})
+		md.stringList(3, []string{c.name})
+		md.i32(4, codecUncompressed)
+		md.i64(5, int64(w.rows))
+		md.i64(6, size)
+		md.i64(7, size)
+		md.i64(9, pageOffset)
+		var cc thriftStruct
+		cc.i64(2, pageOffset)
+		cc.structField(3, md.end())
+		chunks[i] = cc.end()
+	}
+
+	schema := make([][]byte, 0, len(w.cols)+1)
+	var root thriftStruct
+	root.str(4, "schema")
+	root.i32(5, int32(len(w.cols)))
+	schema = append(schema, root.end())
+	for _, c := range w.cols {
+		var se thriftStruct
+		se.i32(1, physicalType(c.kind))
+		se.i32(3, repetitionOptional)
+		se.str(4, c.name)
+		switch c.kind {
+		case kindString:
+			se.i32(6, convertedUTF8)
+		case kindTimestamp:
+			se.i32(6, convertedTimestampMicros)
+		}
+		schema = append(schema, se.end())
+	}
+
+	var rg thriftStruct
+	rg.structList(1, chunks)
+	rg.i64(2, total)
+	rg.i64(3, int64(w.rows))
+
+	var fmd thriftStruct
+	fmd.i32(1, 1)
+	fmd.structList(2, schema)
+	fmd.i64(3, int64(w.rows))
+	fmd.structList(4, [][]byte{rg.end()})
+	fmd.str(6, "bocadillo")
+	meta := fmd.end()
+	write(meta)
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(meta)))
+	write(n[:])
+	write(parquetMagic)
+
+	err := bw.Flush()
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func physicalType(k kind) int32 {
+	switch k {
+	case kindInt64, kindTimestamp:
+		return parquetInt64
+	case kindFloat:
+		return parquetFloat
+	case kindDouble:
+		return parquetDouble
+	default:
+		return parquetByteArray
+	}
+}
+
+// appendDefinitionLevels appends definition levels of an optional column
+// using the RLE/bit-packing hybrid encoding with bit width of 1, prefixed by
+// the length of encoded data. Levels are bit-packed in groups of 8.
+func appendDefinitionLevels(b []byte, defs []bool) []byte {
+	groups := (len(defs) + 7) / 8
+	data := appendUvarint(nil, uint64(groups)<<1|1)
+	for g := 0; g < groups; g++ {
+		var v byte
+		for i := 0; i < 8 && g*8+i < len(defs); i++ {
+			if defs[g*8+i] {
+				v |= 1 << uint(i)
+			}
+		}
+		data = append(data, v)
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(data)))
+	return append(append(b, n[:]...), data...)
+}
+
+// Thrift compact protocol field types.
+const (
+	thriftI32        = 5
+	thriftI64        = 6
+	thriftBinary     = 8
+	thriftList       = 9
+	thriftStructType = 12
+)
+
+// thriftStruct encodes a struct using Thrift compact protocol, which is used
+// for Parquet metadata. Fields must be added in ascending order.
+type thriftStruct struct {
+	b    []byte
+	last int16
+}
+
+func (s *thriftStruct) field(id int16, typ byte) {
+	if d := id - s.last; d > 0 && d <= 15 {
+		s.b = append(s.b, byte(d)<<4|typ)
+	} else {
+		s.b = append(s.b, typ)
+		s.b = appendUvarint(s.b, zigzag(int64(id)))
+	}
+	s.last = id
+}
+
+func (s *thriftStruct) i32(id int16, v int32) {
+	s.field(id, thriftI32)
+	s.b = appendUvarint(s.b, zigzag(int64(v)))
+}
+
+func (s *thriftStruct) i64(id int16, v int64) {
+	s.field(id, thriftI64)
+	s.b = appendUvarint(s.b, zigzag(v))
+}
+
+func (s *thriftStruct) str(id int16, v string) {
+	s.field(id, thriftBinary)
+	s.b = appendUvarint(s.b, uint64(len(v)))
+	s.b = append(s.b, v...)
+}
+
+func (s *thriftStruct) structField(id int16, v []byte) {
+	s.field(id, thriftStructType)
+	s.b = append(s.b, v...)
+}
+
+func (s *thriftStruct) listHeader(id int16, n int, typ byte) {
+	s.field(id, thriftList)
+	if n < 15 {
+		s.b = append(s.b, byte(n)<<4|typ)
+	} else {
+		s.b = append(s.b, 0xF0|typ)
+		s.b = appendUvarint(s.b, uint64(n))
+	}
+}
+
+func (s *thriftStruct) i32List(id int16, v []int32) {
+	s.listHeader(id, len(v), thriftI32)
+	for _, x := range v {
+		s.b = appendUvarint(s.b, zigzag(int64(x)))
+	}
+}
+
+func (s *thriftStruct) stringList(id int16, v []string) {
+	s.listHeader(id, len(v), thriftBinary)
+	for _, x := range v {
+		s.b = appendUvarint(s.b, uint64(len(x)))
+		s.b = append(s.b, x...)
+	}
+}
+
+func (s *thriftStruct) structList(id int16, v [][]byte) {
+	s.listHeader(id, len(v), thriftStructType)
+	for _, x := range v {
+		s.b = append(s.b, x...)
+	}
+}
+
+// end returns the encoded struct terminated by a stop field.
+func (s *thriftStruct) end() []byte {
+	return append(s.b, 0)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1 ^ v>>63)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
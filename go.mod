@@ -6,4 +6,4 @@ require (
 	github.com/juju/errors v0.0.0-20190930114154-d42613fe1ab9
 )
 
-go 1.13
+go 1.18
@@ -44,7 +44,8 @@ func EncodeUint24(data []byte, v uint32) {
 
 // DecodeUint24 decodes 3 bytes as uint32 value from a given slice of bytes.
 func DecodeUint24(data []byte) uint32 {
-	return uint32(DecodeVarLen64(data, 3))
+	_ = data[2] // Bounds check hint to compiler
+	return uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
 }
 
 // int<4>
@@ -69,7 +70,9 @@ func EncodeUint48(data []byte, v uint64) {
 
 // DecodeUint48 decodes 6 bytes as uint64 value from a given slice of bytes.
 func DecodeUint48(data []byte) uint64 {
-	return DecodeVarLen64(data, 6)
+	_ = data[5] // Bounds check hint to compiler
+	return uint64(binary.LittleEndian.Uint32(data)) |
+		uint64(data[4])<<32 | uint64(data[5])<<40
 }
 
 // int<8>
@@ -163,10 +166,25 @@ func encodeVarLen64(data []byte, v uint64, s int) {
 
 // DecodeVarLen64 decodes a number of given size in bytes using Little Endian.
 func DecodeVarLen64(data []byte, s int) uint64 {
-	if s > len(data) {
+	if s > len(data) || s <= 0 {
 		return 0
 	}
 
+	switch s {
+	case 1:
+		return uint64(data[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(data))
+	case 3:
+		return uint64(DecodeUint24(data))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(data))
+	case 6:
+		return DecodeUint48(data)
+	case 8:
+		return binary.LittleEndian.Uint64(data)
+	}
+
 	v := uint64(data[0])
 	for i := 1; i < s; i++ {
 		v |= uint64(data[i]) << uint(i*8)
@@ -258,10 +276,14 @@ func DecodeFloat64(data []byte) float64 {
 	return math.Float64frombits(DecodeUint64(data))
 }
 
-// DecodeBit decodes a bit into not less than 8 bytes.
+// DecodeBit decodes a bit into not less than 8 bytes. Zero is returned if data
+// is too short.
 func DecodeBit(data []byte, nbits int, length int) (v uint64, n int) {
 	if nbits > 1 {
 		return DecodeVarLen64(data, length), length
 	}
+	if len(data) == 0 {
+		return 0, 1
+	}
 	return uint64(data[0]), 1
 }
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestDecodeVarLen64(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
+	for s := 1; s <= 8; s++ {
+		var exp uint64
+		for i := 0; i < s; i++ {
+			exp |= uint64(data[i]) << uint(i*8)
+		}
+		if v := DecodeVarLen64(data, s); v != exp {
+			t.Errorf("Expected %#x for size %d, got %#x", exp, s, v)
+		}
+	}
+	if v := DecodeUint24(data); v != 0x030201 {
+		t.Errorf("Expected 0x030201, got %#x", v)
+	}
+	if v := DecodeUint48(data); v != 0x060504030201 {
+		t.Errorf("Expected 0x060504030201, got %#x", v)
+	}
+	if v := DecodeVarLen64(data[:2], 3); v != 0 {
+		t.Errorf("Expected zero for a short buffer, got %#x", v)
+	}
+}
+
+// sink keeps benchmarked calls from being optimized away
+var sink uint64
+
+func BenchmarkDecodeUint24(b *testing.B) {
+	data := []byte{0x01, 0x02, 0x03}
+	for i := 0; i < b.N; i++ {
+		sink = uint64(DecodeUint24(data))
+	}
+}
+
+func BenchmarkDecodeUint48(b *testing.B) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	for i := 0; i < b.N; i++ {
+		sink = DecodeUint48(data)
+	}
+}
+
+func BenchmarkDecodeVarLen64(b *testing.B) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	for i := 0; i < b.N; i++ {
+		sink = DecodeVarLen64(data, 8)
+	}
+}
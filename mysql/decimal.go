@@ -1,9 +1,7 @@
 package mysql
 
 import (
-	"bytes"
 	"database/sql/driver"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -16,75 +14,128 @@ type Decimal struct {
 	str string
 }
 
+// maxDecimalLen is the maximum length of a textual representation of a
+// decimal: 65 digits, a sign, a decimal point and a leading zero.
+const maxDecimalLen = 68
+
+const digitsPerInteger int = 9
+
+var compressedBytes = [...]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
 // DecodeDecimal decodes a decimal value.
-// Implementation borrowed from https://github.com/siddontang/go-mysql/
 func DecodeDecimal(data []byte, precision int, decimals int) (Decimal, int) {
-	const digitsPerInteger int = 9
-	var compressedBytes = [...]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
-
-	decodeDecimalDecompressValue := func(compIndx int, data []byte, mask uint8) (size int, value uint32) {
-		size = compressedBytes[compIndx]
-		databuff := make([]byte, size)
-		for i := 0; i < size; i++ {
-			databuff[i] = data[i] ^ mask
-		}
-		value = uint32(DecodeVarLen64BigEndian(databuff))
-		return
-	}
+	var buf [maxDecimalLen]byte
+	str, n := AppendDecimal(buf[:0], data, precision, decimals)
+	return Decimal{string(str)}, n
+}
 
-	// See python mysql replication and https://github.com/jeremycole/mysql_binlog
+// AppendDecimal appends a textual representation of a binary encoded decimal
+// to dst and returns the extended buffer along with the number of bytes
+// decoded. It allows decoding decimals without allocations by reusing dst.
+// Nothing is appended if data is too short or the arguments are invalid.
+// Implementation borrowed from https://github.com/siddontang/go-mysql/
+// See python mysql replication and https://github.com/jeremycole/mysql_binlog
+func AppendDecimal(dst, data []byte, precision int, decimals int) ([]byte, int) {
+	if size := DecimalBinarySize(precision, decimals); size == 0 || len(data) < size {
+		return dst, size
+	}
 	integral := (precision - decimals)
 	uncompIntegral := int(integral / digitsPerInteger)
 	uncompFractional := int(decimals / digitsPerInteger)
 	compIntegral := integral - (uncompIntegral * digitsPerInteger)
 	compFractional := decimals - (uncompFractional * digitsPerInteger)
 
-	binSize := uncompIntegral*4 + compressedBytes[compIntegral] +
-		uncompFractional*4 + compressedBytes[compFractional]
-
-	buf := make([]byte, binSize)
-	copy(buf, data[:binSize])
-
-	// Must copy the data for later change
-	data = buf
-
-	// Support negative
-	// The sign is encoded in the high bit of the the byte
-	// But this bit can also be used in the value
-	value := uint32(data[0])
-	var res bytes.Buffer
+	// The sign is encoded in the high bit of the first byte, negative values
+	// have all of their bits inverted
 	var mask uint32
-	if value&0x80 == 0 {
-		mask = uint32((1 << 32) - 1)
-		res.WriteString("-")
+	if data[0]&0x80 == 0 {
+		mask = (1 << 32) - 1
+		dst = append(dst, '-')
 	}
+	digits := len(dst)
 
-	// Clear sign
-	data[0] ^= 0x80
-
-	pos, value := decodeDecimalDecompressValue(compIntegral, data, uint8(mask))
-	res.WriteString(fmt.Sprintf("%d", value))
-
+	pos := 0
+	size := compressedBytes[compIntegral]
+	dst = strconv.AppendUint(dst, uint64(decimalWord(data, pos, size, mask)), 10)
+	pos += size
 	for i := 0; i < uncompIntegral; i++ {
-		value = binary.BigEndian.Uint32(data[pos:]) ^ mask
+		dst = appendPadded(dst, decimalWord(data, pos, 4, mask), digitsPerInteger)
 		pos += 4
-		res.WriteString(fmt.Sprintf("%09d", value))
 	}
 
-	res.WriteString(".")
+	dst = append(dst, '.')
 
 	for i := 0; i < uncompFractional; i++ {
-		value = binary.BigEndian.Uint32(data[pos:]) ^ mask
+		dst = appendPadded(dst, decimalWord(data, pos, 4, mask), digitsPerInteger)
 		pos += 4
-		res.WriteString(fmt.Sprintf("%09d", value))
 	}
-
-	if size, value := decodeDecimalDecompressValue(compFractional, data[pos:], uint8(mask)); size > 0 {
-		res.WriteString(fmt.Sprintf("%0*d", compFractional, value))
+	if size := compressedBytes[compFractional]; size > 0 {
+		dst = appendPadded(dst, decimalWord(data, pos, size, mask), compFractional)
 		pos += size
 	}
 
-	return NewDecimal(res.String()), pos
+	return trimDecimal(dst, digits), pos
+}
+
+// decimalWord decodes a big endian value of given size at given position.
+func decimalWord(data []byte, pos, size int, mask uint32) uint32 {
+	var v uint32
+	for i := pos; i < pos+size; i++ {
+		b := data[i]
+		if i == 0 {
+			// Clear sign
+			b ^= 0x80
+		}
+		v = v<<8 | uint32(b^uint8(mask))
+	}
+	return v
+}
+
+// appendPadded appends a decimal representation of v padded with zeroes to
+// given width.
+func appendPadded(dst []byte, v uint32, width int) []byte {
+	var buf [10]byte
+	i := len(buf)
+	for v > 0 || len(buf)-i < width {
+		i--
+		buf[i] = byte('0' + v%10)
+		v /= 10
+	}
+	return append(dst, buf[i:]...)
+}
+
+// trimDecimal removes leading and trailing zeroes from the digits starting at
+// given position, keeping a single zero on either side of the decimal point.
+func trimDecimal(dst []byte, digits int) []byte {
+	str := dst[digits:]
+	i, j := 0, len(str)
+	for str[i] == '0' {
+		i++
+	}
+	for str[j-1] == '0' {
+		j--
+	}
+	if str[i] == '.' {
+		// Integral part is never empty so there is room for a zero
+		i--
+		str[i] = '0'
+	}
+	dst = append(dst[:digits], str[i:j]...)
+	if dst[len(dst)-1] == '.' {
+		dst = append(dst, '0')
+	}
+	return dst
+}
+
+// DecimalBinarySize returns the size of a decimal value of given precision and
+// number of decimals in binary form. Zero is returned for invalid arguments.
+func DecimalBinarySize(precision, decimals int) int {
+	integral := precision - decimals
+	if integral < 0 || decimals < 0 {
+		return 0
+	}
+	return integral/digitsPerInteger*4 + compressedBytes[integral%digitsPerInteger] +
+		decimals/digitsPerInteger*4 + compressedBytes[decimals%digitsPerInteger]
 }
 
 // NewDecimal creates a new decimal with given value.
@@ -94,6 +145,10 @@ func NewDecimal(str string) Decimal {
 		str = str[1:]
 		sign = "-"
 	}
+	if !strings.Contains(str, ".") {
+		// Decoded decimals always have a fractional part
+		str += ".0"
+	}
 	str = strings.Trim(str, "0")
 	if str[0] == '.' {
 		str = "0" + str
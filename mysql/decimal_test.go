@@ -16,6 +16,30 @@ func BenchmarkDecodeDecimal(b *testing.B) {
 	}
 }
 
+func BenchmarkAppendDecimal(b *testing.B) {
+	data := []byte{129, 134, 159, 59, 154, 201, 255, 59, 154, 201, 255, 0, 152, 150, 127, 10, 0}
+	buf := make([]byte, 0, maxDecimalLen)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendDecimal(buf[:0], data, 30, 25)
+	}
+}
+
+func TestAppendDecimal(t *testing.T) {
+	data := []byte{127, 255, 132, 229, 45, 139, 127, 255, 255, 255, 255, 255, 255, 255, 255, 20, 0}
+	buf := make([]byte, 0, maxDecimalLen)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf, _ = AppendDecimal(buf[:0], data, 30, 25)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+	if exp := "-123.45"; string(buf) != exp {
+		t.Errorf("Expected %s, got %s", exp, buf)
+	}
+}
+
 func TestDecodeDecimalOrig(t *testing.T) {
 	testcases := []struct {
 		Data        []byte
@@ -229,3 +253,18 @@ func TestDecodeDecimalOrig(t *testing.T) {
 		}
 	}
 }
+
+func TestNewDecimal(t *testing.T) {
+	testcases := map[string]string{
+		"0":       "0.0",
+		"100":     "100.0",
+		"-1948":   "-1948.0",
+		"0.50":    "0.5",
+		"0010.10": "10.1",
+	}
+	for in, exp := range testcases {
+		if out := NewDecimal(in).String(); out != exp {
+			t.Errorf("Expected %q to be parsed as %s, got %s", in, exp, out)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+)
+
+const (
+	clientName = "bocadillo"
+	modulePath = "github.com/Vivino/bocadillo"
+)
+
+// connectAttrs returns client connection attributes sent to the server during
+// the handshake. They could be found in the
+// performance_schema.session_connect_attrs table. Custom attributes take
+// precedence over the default ones.
+// Spec: https://dev.mysql.com/doc/refman/8.0/en/performance-schema-connection-attribute-tables.html
+func connectAttrs(custom map[string]string) map[string]string {
+	attrs := map[string]string{
+		"_client_name":    clientName,
+		"_client_version": clientVersion(),
+		"_os":             runtime.GOOS,
+		"_platform":       runtime.GOARCH,
+		"_pid":            strconv.Itoa(os.Getpid()),
+		"program_name":    filepath.Base(os.Args[0]),
+	}
+	if host, err := os.Hostname(); err == nil {
+		attrs["host"] = host
+	}
+	for k, v := range custom {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// clientVersion returns the version of the module this package belongs to
+// from the build info.
+func clientVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if bi.Main.Path == modulePath {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// appendConnectAttrs appends length encoded connection attributes in a stable
+// order.
+func appendConnectAttrs(b []byte, attrs map[string]string) []byte {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var kv []byte
+	for _, k := range keys {
+		kv = appendStrLenEnc(kv, k)
+		kv = appendStrLenEnc(kv, attrs[k])
+	}
+	b = appendUintLenEnc(b, uint64(len(kv)))
+	return append(b, kv...)
+}
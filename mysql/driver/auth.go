@@ -0,0 +1,282 @@
+package driver
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// Authentication documentation:
+// https://dev.mysql.com/doc/internals/en/authentication-method.html
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
+
+const (
+	authNativePassword      = "mysql_native_password"
+	authCachingSHA2Password = "caching_sha2_password"
+	authSHA256Password      = "sha256_password"
+	authClearPassword       = "mysql_clear_password"
+
+	// caching_sha2_password exchange
+	cachingSHA2RequestPublicKey = 2
+	cachingSHA2FastAuthSuccess  = 3
+	cachingSHA2FullAuth         = 4
+
+	// sha256_password exchange
+	sha256RequestPublicKey = 1
+)
+
+// AuthPlugin computes an authentication response for the given server
+// challenge and password. If addNUL is true the response is sent terminated
+// with a NUL byte.
+type AuthPlugin func(authData []byte, password string) (resp []byte, addNUL bool, err error)
+
+var (
+	authPluginsMu sync.RWMutex
+	authPlugins   = make(map[string]AuthPlugin)
+)
+
+// RegisterAuthPlugin registers a custom authentication plugin with the given
+// name. Plugins supported out of the box are mysql_native_password,
+// caching_sha2_password, sha256_password and mysql_clear_password, they can't
+// be overridden. Custom plugins are limited to a single round trip.
+func RegisterAuthPlugin(name string, fn AuthPlugin) {
+	authPluginsMu.Lock()
+	authPlugins[name] = fn
+	authPluginsMu.Unlock()
+}
+
+func lookupAuthPlugin(name string) (AuthPlugin, bool) {
+	authPluginsMu.RLock()
+	defer authPluginsMu.RUnlock()
+	fn, ok := authPlugins[name]
+	return fn, ok
+}
+
+// authResponse returns the initial response for the given plugin.
+func (c *client) authResponse(plugin string, authData []byte) ([]byte, error) {
+	passwd := c.cfg.passwd
+	switch plugin {
+	case authNativePassword:
+		return scrambleNativePassword(authData, passwd), nil
+	case authCachingSHA2Password:
+		if passwd == "" {
+			return []byte{0}, nil
+		}
+		return scrambleSHA256Password(authData, passwd), nil
+	case authSHA256Password:
+		if passwd == "" {
+			return []byte{0}, nil
+		}
+		if c.secure() {
+			return append([]byte(passwd), 0), nil
+		}
+		return []byte{sha256RequestPublicKey}, nil
+	case authClearPassword:
+		if !c.secure() && !c.cfg.allowCleartext {
+			return nil, errors.Errorf("Auth plugin %s requires a secure connection", authClearPassword)
+		}
+		return append([]byte(passwd), 0), nil
+	default:
+		fn, ok := lookupAuthPlugin(plugin)
+		if !ok {
+			return nil, errors.Errorf("Unknown auth plugin: %s", plugin)
+		}
+		resp, addNUL, err := fn(authData, passwd)
+		if addNUL {
+			resp = append(resp, 0)
+		}
+		return resp, err
+	}
+}
+
+// handleAuthResult processes server responses until authentication succeeds
+// or fails. Server can switch the authentication method once.
+func (c *client) handleAuthResult(plugin string, authData []byte) error {
+	switched := false
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return ErrMalformedPacket
+		}
+
+		switch data[0] {
+		case resultOK:
+			return nil
+		case resultERR:
+			return parseError(data)
+		case resultEOF:
+			// Authentication method switch request
+			if switched {
+				return ErrMalformedPacket
+			}
+			switched = true
+			name, rest, ok := readNullStr(data[1:])
+			if !ok {
+				return ErrMalformedPacket
+			}
+			plugin = name
+			// Challenge is NUL terminated
+			if n := len(rest); n > 0 && rest[n-1] == 0 {
+				rest = rest[:n-1]
+			}
+			authData = append([]byte(nil), rest...)
+			resp, err := c.authResponse(plugin, authData)
+			if err != nil {
+				return err
+			}
+			if err := c.writeAuthData(resp); err != nil {
+				return err
+			}
+		case authMoreData:
+			if err := c.authMoreData(plugin, authData, data[1:]); err != nil {
+				return err
+			}
+		default:
+			return ErrMalformedPacket
+		}
+	}
+}
+
+// authMoreData handles extra authentication data sent by the server.
+func (c *client) authMoreData(plugin string, authData, data []byte) error {
+	switch plugin {
+	case authCachingSHA2Password:
+		if len(data) != 1 {
+			return ErrMalformedPacket
+		}
+		switch data[0] {
+		case cachingSHA2FastAuthSuccess:
+			// Followed by an OK packet
+			return nil
+		case cachingSHA2FullAuth:
+			if c.secure() {
+				return c.writeAuthData(append([]byte(c.cfg.passwd), 0))
+			}
+			if err := c.writeAuthData([]byte{cachingSHA2RequestPublicKey}); err != nil {
+				return err
+			}
+			resp, err := c.readPacket()
+			if err != nil {
+				return err
+			}
+			if len(resp) == 0 || resp[0] != authMoreData {
+				return ErrMalformedPacket
+			}
+			pub, err := parsePublicKey(resp[1:])
+			if err != nil {
+				return err
+			}
+			return c.sendEncryptedPassword(authData, pub)
+		default:
+			return ErrMalformedPacket
+		}
+	case authSHA256Password:
+		// Server has sent its public key
+		pub, err := parsePublicKey(data)
+		if err != nil {
+			return err
+		}
+		return c.sendEncryptedPassword(authData, pub)
+	default:
+		return ErrMalformedPacket
+	}
+}
+
+func (c *client) writeAuthData(data []byte) error {
+	pkt := make([]byte, 4+len(data))
+	copy(pkt[4:], data)
+	return c.writePacket(pkt)
+}
+
+func (c *client) sendEncryptedPassword(seed []byte, pub *rsa.PublicKey) error {
+	enc, err := encryptPassword(c.cfg.passwd, seed, pub)
+	if err != nil {
+		return err
+	}
+	return c.writeAuthData(enc)
+}
+
+// secure returns true if the password could be sent in clear text.
+func (c *client) secure() bool {
+	return c.tls || c.cfg.net == "unix"
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrMalformedPacket
+	}
+	pkix, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := pkix.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrMalformedPacket
+	}
+	return pub, nil
+}
+
+// scrambleNativePassword computes a mysql_native_password response:
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+func scrambleNativePassword(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	if len(scramble) > 20 {
+		scramble = scramble[:20]
+	}
+
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	resp := h.Sum(nil)
+	for i := range resp {
+		resp[i] ^= stage1[i]
+	}
+	return resp
+}
+
+// scrambleSHA256Password computes a caching_sha2_password response:
+// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+func scrambleSHA256Password(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+	h := sha256.New()
+	h.Write(stage2[:])
+	h.Write(scramble)
+	resp := h.Sum(nil)
+	for i := range resp {
+		resp[i] ^= stage1[i]
+	}
+	return resp
+}
+
+// encryptPassword encrypts NUL terminated password XORed with the scramble
+// using server's public key.
+func encryptPassword(password string, seed []byte, pub *rsa.PublicKey) ([]byte, error) {
+	if len(seed) == 0 {
+		return nil, ErrMalformedPacket
+	}
+	plain := make([]byte, len(password)+1)
+	copy(plain, password)
+	for i := range plain {
+		plain[i] ^= seed[i%len(seed)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+}
@@ -0,0 +1,646 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/juju/errors"
+)
+
+// Protocol documentation:
+// https://dev.mysql.com/doc/internals/en/connection-phase.html
+// https://dev.mysql.com/doc/internals/en/text-protocol.html
+
+const (
+	// Commands
+	comQuit  byte = 1
+	comQuery byte = 3
+	comPing  byte = 14
+
+	// Capability flags
+	clientLongPassword               uint32 = 1 << 0
+	clientLongFlag                   uint32 = 1 << 2
+	clientConnectWithDB              uint32 = 1 << 3
+	clientProtocol41                 uint32 = 1 << 9
+	clientSSL                        uint32 = 1 << 11
+	clientTransactions               uint32 = 1 << 13
+	clientSecureConn                 uint32 = 1 << 15
+	clientPluginAuth                 uint32 = 1 << 19
+	clientConnectAttrs               uint32 = 1 << 20
+	clientPluginAuthLenEncClientData uint32 = 1 << 21
+
+	// Packet headers
+	authMoreData byte = 0x01
+	nullValue    byte = 0xFB
+	localInfile  byte = 0xFB
+
+	// utf8_general_ci
+	defaultCollation byte = 33
+)
+
+// Error is an error returned by the server.
+// Spec: https://dev.mysql.com/doc/internals/en/packet-ERR_Packet.html
+type Error struct {
+	Code    uint16
+	State   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.State != "" {
+		return fmt.Sprintf("Error %d (%s): %s", e.Code, e.State, e.Message)
+	}
+	return fmt.Sprintf("Error %d: %s", e.Code, e.Message)
+}
+
+// Is makes server errors match ErrAuthFailed, ErrPositionPurged,
+// ErrChecksumMismatch and ErrServerIDConflict. Errors reading the binary log
+// share a single code, so they are told apart by their messages. Annotated
+// errors should be matched with their cause:
+// errors.Is(errors.Cause(err), ErrAuthFailed).
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrAuthFailed:
+		return e.Code == errAccessDenied || e.Code == errAccessDeniedNoPassword ||
+			e.Code == errMustChangePassword
+	case ErrPositionPurged:
+		return e.Code == errMasterHasPurgedGTIDs || e.Code == errMasterFatalReadingBinlog &&
+			(strings.Contains(e.Message, "Could not find first log file name") ||
+				strings.Contains(e.Message, "purged") ||
+				strings.Contains(e.Message, "Could not find GTID state"))
+	case ErrChecksumMismatch:
+		return e.Code == errNetworkChecksumFailure || e.Code == errBinlogChecksumFailure ||
+			e.Code == errMasterFatalReadingBinlog && (strings.Contains(e.Message, "checksum") ||
+				strings.Contains(e.Message, "crc check"))
+	case ErrServerIDConflict:
+		return e.Code == errMasterFatalReadingBinlog && strings.Contains(e.Message, "same server_uuid/server_id")
+	}
+	return false
+}
+
+// Temporary returns true if the error is caused by a condition expected to
+// clear up on its own: the server shutting down or running out of
+// connections, the connection being killed, lock waits and deadlocks.
+func (e *Error) Temporary() bool {
+	switch e.Code {
+	case errConCount, errServerShutdown, errNetReadError, errNetReadInterrupted,
+		errNetErrorOnWrite, errNetWriteInterrupted, errLockWaitTimeout, errLockDeadlock,
+		errQueryInterrupted, errConnectionKilled:
+		return true
+	}
+	return false
+}
+
+// Server error codes.
+// Spec: https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	errAccessDenied             = 1045
+	errAccessDeniedNoPassword   = 1698
+	errMustChangePassword       = 1862
+	errMasterFatalReadingBinlog = 1236
+	errMasterHasPurgedGTIDs     = 1789
+	errNetworkChecksumFailure   = 1743
+	errBinlogChecksumFailure    = 1744
+	errConCount                 = 1040
+	errServerShutdown           = 1053
+	errNetReadError             = 1158
+	errNetReadInterrupted       = 1159
+	errNetErrorOnWrite          = 1160
+	errNetWriteInterrupted      = 1161
+	errLockWaitTimeout          = 1205
+	errLockDeadlock             = 1213
+	errQueryInterrupted         = 1317
+	errConnectionKilled         = 1927
+)
+
+var (
+	// ErrServerTooOld is returned when the server doesn't support protocol
+	// 4.1.
+	ErrServerTooOld = errors.New("Server doesn't support protocol 4.1")
+	// ErrNoRows is returned by QueryRow when the query returns no rows.
+	ErrNoRows = errors.New("No rows in result set")
+	// ErrAuthFailed is matched by server errors rejecting credentials.
+	ErrAuthFailed = errors.New("Authentication failed")
+	// ErrPositionPurged is matched by server errors reporting that the
+	// requested binary log file or GTIDs were purged.
+	ErrPositionPurged = errors.New("Binary log position purged")
+	// ErrChecksumMismatch is matched by server errors reporting events that
+	// failed checksum verification.
+	ErrChecksumMismatch = errors.New("Event checksum mismatch")
+	// ErrServerIDConflict is matched by server errors ending the dump because
+	// another replica connected with the same server ID.
+	ErrServerIDConflict = errors.New("Another replica connected with the same server ID")
+)
+
+// client is a minimal MySQL client that implements the connection phase and
+// a few commands of the text protocol, just enough to start a binlog dump.
+type client struct {
+	*packetConn
+	cfg *dsnConfig
+	tls bool
+
+	serverVersion      string
+	serverCapabilities uint32
+	connectionID       uint32
+	capabilities       uint32
+	readTimeout        time.Duration
+	watcher            *watcher
+}
+
+// handshake is the initial handshake packet sent by the server.
+// Spec: https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::Handshake
+type handshake struct {
+	serverVersion string
+	connectionID  uint32
+	authData      []byte
+	capabilities  uint32
+	authPlugin    string
+}
+
+// dial establishes a network connection and authenticates. Handshake timeout
+// limits each read and write during the connection phase, context deadline
+// limits the whole process.
+func dial(ctx context.Context, cfg *dsnConfig, dialTimeout, handshakeTimeout time.Duration) (*client, error) {
+	nd := net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	nc, err := nd.DialContext(ctx, cfg.net, cfg.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &client{packetConn: newPacketConn(nc, cfg.readBufferSize), cfg: cfg, watcher: newWatcher()}
+	c.writeTimeout = handshakeTimeout
+	c.readTimeout = handshakeTimeout
+	if err := c.handshake(ctx); err != nil {
+		c.nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *client) handshake(ctx context.Context) error {
+	if err := c.setDeadlines(ctx); err != nil {
+		return err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[0] == resultERR {
+		return parseError(data)
+	}
+	hs, err := parseHandshake(data)
+	if err != nil {
+		return err
+	}
+	c.serverVersion = hs.serverVersion
+	c.serverCapabilities = hs.capabilities
+	c.connectionID = hs.connectionID
+
+	caps := clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions |
+		clientSecureConn | clientPluginAuth | clientPluginAuthLenEncClientData | clientConnectAttrs
+	if c.cfg.dbName != "" {
+		caps |= clientConnectWithDB
+	}
+	if hs.capabilities&clientProtocol41 == 0 {
+		return ErrServerTooOld
+	}
+	if c.cfg.tlsConfig != nil {
+		if hs.capabilities&clientSSL != 0 {
+			caps |= clientSSL
+		} else if !c.cfg.tlsPreferred {
+			return errors.New("Server doesn't support TLS")
+		}
+	}
+	c.capabilities = caps & hs.capabilities
+
+	if c.capabilities&clientSSL != 0 {
+		if err := c.startTLS(); err != nil {
+			return err
+		}
+	}
+
+	plugin := hs.authPlugin
+	if plugin == "" {
+		plugin = authNativePassword
+	}
+	resp, err := c.authResponse(plugin, hs.authData)
+	if err != nil {
+		return err
+	}
+	if err := c.writeHandshakeResponse(plugin, resp); err != nil {
+		return err
+	}
+	return c.handleAuthResult(plugin, hs.authData)
+}
+
+func parseHandshake(data []byte) (hs handshake, err error) {
+	if len(data) < 1 || data[0] != 10 {
+		return hs, errors.Errorf("Unsupported protocol version")
+	}
+	version, rest, ok := readNullStr(data[1:])
+	if !ok || len(rest) < 4+8+1+2 {
+		return hs, ErrMalformedPacket
+	}
+	hs.serverVersion = version
+	hs.connectionID = binary.LittleEndian.Uint32(rest)
+	hs.authData = append([]byte(nil), rest[4:12]...)
+	hs.capabilities = uint32(binary.LittleEndian.Uint16(rest[13:]))
+	rest = rest[15:]
+	if len(rest) < 1+2+2+1+10 {
+		// Older servers stop here
+		return hs, nil
+	}
+	hs.capabilities |= uint32(binary.LittleEndian.Uint16(rest[3:])) << 16
+	authLen := int(rest[5])
+	rest = rest[16:]
+	if hs.capabilities&clientSecureConn != 0 {
+		n := authLen - 8
+		if n < 13 {
+			n = 13
+		}
+		if len(rest) < n {
+			return hs, ErrMalformedPacket
+		}
+		// Second part of the challenge is NUL terminated
+		hs.authData = append(hs.authData, rest[:n-1]...)
+		rest = rest[n:]
+	}
+	if hs.capabilities&clientPluginAuth != 0 {
+		hs.authPlugin, _, _ = readNullStr(rest)
+	}
+	return hs, nil
+}
+
+// startTLS sends an SSL request and upgrades the connection.
+// Spec: https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::SSLRequest
+func (c *client) startTLS() error {
+	pkt := make([]byte, 4+4+4+1+23)
+	binary.LittleEndian.PutUint32(pkt[4:], c.capabilities)
+	pkt[12] = c.cfg.collation
+	if err := c.writePacket(pkt); err != nil {
+		return err
+	}
+
+	tc := tls.Client(c.nc, c.cfg.tlsConfig)
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	c.nc = tc
+	c.tls = true
+	return nil
+}
+
+// Spec: https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeResponse
+func (c *client) writeHandshakeResponse(plugin string, authResp []byte) error {
+	size := 4 + 4 + 4 + 1 + 23 + len(c.cfg.user) + 1 + 9 + len(authResp) + len(c.cfg.dbName) + 1 + len(plugin) + 1
+	pkt := make([]byte, 4, size)
+	pkt = appendUint32(pkt, c.capabilities)
+	pkt = appendUint32(pkt, 0) // Max packet size
+	pkt = append(pkt, c.cfg.collation)
+	pkt = append(pkt, make([]byte, 23)...)
+	pkt = append(append(pkt, c.cfg.user...), 0)
+	if c.capabilities&clientPluginAuthLenEncClientData != 0 {
+		pkt = appendUintLenEnc(pkt, uint64(len(authResp)))
+	} else {
+		pkt = append(pkt, byte(len(authResp)))
+	}
+	pkt = append(pkt, authResp...)
+	if c.capabilities&clientConnectWithDB != 0 {
+		pkt = append(append(pkt, c.cfg.dbName...), 0)
+	}
+	if c.capabilities&clientPluginAuth != 0 {
+		pkt = append(append(pkt, plugin...), 0)
+	}
+	if c.capabilities&clientConnectAttrs != 0 {
+		pkt = appendConnectAttrs(pkt, connectAttrs(c.cfg.connectAttrs))
+	}
+	return c.writePacket(pkt)
+}
+
+// result is the result of a text protocol query.
+type result struct {
+	columns []string
+	rows    [][]sql.NullString
+}
+
+// query executes a query using the text protocol and returns its result.
+func (c *client) query(ctx context.Context, q string) (res *result, err error) {
+	if err := c.setDeadlines(ctx); err != nil {
+		return nil, err
+	}
+	c.watch(ctx)
+	defer c.unwatch(ctx, &err)
+
+	pkt := make([]byte, 4+1+len(q))
+	pkt[4] = comQuery
+	copy(pkt[5:], q)
+	if err := c.writeCommand(pkt); err != nil {
+		return nil, err
+	}
+
+	data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(data) == 0:
+		return nil, ErrMalformedPacket
+	case data[0] == resultOK:
+		return &result{}, nil
+	case data[0] == resultERR:
+		return nil, parseError(data)
+	case data[0] == localInfile:
+		return nil, errors.New("LOAD DATA LOCAL INFILE is not supported")
+	}
+
+	// Result set
+	n, _, _ := mysql.DecodeUintLenEnc(data)
+	res = &result{columns: make([]string, n)}
+	for i := range res.columns {
+		if data, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		if res.columns[i], err = parseColumnName(data); err != nil {
+			return nil, err
+		}
+	}
+	if data, err = c.readPacket(); err != nil {
+		return nil, err
+	}
+	if !isEOF(data) {
+		return nil, ErrMalformedPacket
+	}
+
+	for {
+		if data, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		if isEOF(data) {
+			return res, nil
+		}
+		if len(data) == 0 {
+			return nil, ErrMalformedPacket
+		}
+		if data[0] == resultERR {
+			return nil, parseError(data)
+		}
+		row, err := parseRow(data, len(res.columns))
+		if err != nil {
+			return nil, err
+		}
+		res.rows = append(res.rows, row)
+	}
+}
+
+// exec executes a query and discards its result.
+func (c *client) exec(ctx context.Context, q string) error {
+	_, err := c.query(ctx, q)
+	return err
+}
+
+// ping sends a PING command.
+func (c *client) ping(ctx context.Context) (err error) {
+	if err := c.setDeadlines(ctx); err != nil {
+		return err
+	}
+	c.watch(ctx)
+	defer c.unwatch(ctx, &err)
+
+	if err := c.writeCommand([]byte{0, 0, 0, 0, comPing}); err != nil {
+		return err
+	}
+	return c.readResultOK()
+}
+
+// quit sends a QUIT command and closes the connection. It doesn't modify the
+// connection state and therefore could be called concurrently with a read.
+func (c *client) quit() error {
+	if c.writeTimeout > 0 {
+		c.nc.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err := c.nc.Write([]byte{1, 0, 0, 0, comQuit})
+	c.watcher.close()
+	if cerr := c.nc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *client) readResultOK() error {
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(data) == 0:
+		return ErrMalformedPacket
+	case data[0] == resultOK:
+		return nil
+	case data[0] == resultERR:
+		return parseError(data)
+	default:
+		return ErrMalformedPacket
+	}
+}
+
+// watcher interrupts pending reads and writes of a connection once the
+// context of the current command is done. A single goroutine, started with the
+// first cancellable command, serves all commands of the connection.
+type watcher struct {
+	start sync.Once
+	stop  sync.Once
+	// arm passes the context of a command, disarm is sent when the command
+	// completes and quit is closed with the connection
+	arm    chan context.Context
+	disarm chan struct{}
+	quit   chan struct{}
+}
+
+func newWatcher() *watcher {
+	return &watcher{
+		arm:    make(chan context.Context),
+		disarm: make(chan struct{}),
+		quit:   make(chan struct{}),
+	}
+}
+
+func (w *watcher) run(nc net.Conn) {
+	for {
+		var ctx context.Context
+		select {
+		case ctx = <-w.arm:
+		case <-w.quit:
+			return
+		}
+		select {
+		case <-ctx.Done():
+			nc.SetDeadline(time.Unix(1, 0))
+			select {
+			case <-w.disarm:
+			case <-w.quit:
+				return
+			}
+		case <-w.disarm:
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// close stops the watcher, it's safe to call concurrently with commands.
+func (w *watcher) close() {
+	w.stop.Do(func() { close(w.quit) })
+}
+
+// watch makes the watcher interrupt the command once the context is done.
+// Unwatch must be called when the command completes.
+func (c *client) watch(ctx context.Context) {
+	if ctx.Done() == nil {
+		return
+	}
+	c.watcher.start.Do(func() { go c.watcher.run(c.nc) })
+	select {
+	case c.watcher.arm <- ctx:
+	case <-c.watcher.quit:
+	}
+}
+
+// unwatch replaces the error the command has failed with by the context error.
+// It waits for the watcher to let go of the context, so that cancelling the
+// context afterwards doesn't interrupt the next command.
+func (c *client) unwatch(ctx context.Context, errp *error) {
+	if ctx.Done() == nil {
+		return
+	}
+	select {
+	case c.watcher.disarm <- struct{}{}:
+	case <-c.watcher.quit:
+	}
+	if *errp != nil && ctx.Err() != nil {
+		*errp = ctx.Err()
+	}
+}
+
+// setDeadlines sets read and write deadlines for a command from the context
+// deadline and connection timeouts, whichever is sooner.
+func (c *client) setDeadlines(ctx context.Context) error {
+	dl, ok := ctx.Deadline()
+	if c.readTimeout > 0 {
+		if t := time.Now().Add(c.readTimeout); !ok || t.Before(dl) {
+			dl, ok = t, true
+		}
+	}
+	if !ok {
+		dl = time.Time{}
+	}
+	return c.nc.SetDeadline(dl)
+}
+
+// parseError parses an ERR packet.
+func parseError(data []byte) error {
+	if len(data) < 3 {
+		return ErrMalformedPacket
+	}
+	e := &Error{Code: binary.LittleEndian.Uint16(data[1:])}
+	msg := data[3:]
+	if len(msg) >= 6 && msg[0] == '#' {
+		e.State = string(msg[1:6])
+		msg = msg[6:]
+	}
+	e.Message = string(msg)
+	return e
+}
+
+// parseColumnName returns the name of a column from a column definition.
+// Spec: https://dev.mysql.com/doc/internals/en/com-query-response.html#packet-Protocol::ColumnDefinition41
+func parseColumnName(data []byte) (string, error) {
+	// Skip catalog, schema, table and original table
+	for i := 0; i < 4; i++ {
+		_, rest, ok := readStrLenEnc(data)
+		if !ok {
+			return "", ErrMalformedPacket
+		}
+		data = rest
+	}
+	name, _, ok := readStrLenEnc(data)
+	if !ok {
+		return "", ErrMalformedPacket
+	}
+	return string(name), nil
+}
+
+func parseRow(data []byte, n int) ([]sql.NullString, error) {
+	row := make([]sql.NullString, n)
+	for i := range row {
+		if len(data) == 0 {
+			return nil, ErrMalformedPacket
+		}
+		if data[0] == nullValue {
+			data = data[1:]
+			continue
+		}
+		val, rest, ok := readStrLenEnc(data)
+		if !ok {
+			return nil, ErrMalformedPacket
+		}
+		row[i] = sql.NullString{String: string(val), Valid: true}
+		data = rest
+	}
+	return row, nil
+}
+
+func isEOF(data []byte) bool {
+	return len(data) > 0 && len(data) < 9 && data[0] == resultEOF
+}
+
+func readNullStr(data []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return "", nil, false
+	}
+	return string(data[:i]), data[i+1:], true
+}
+
+func readStrLenEnc(data []byte) ([]byte, []byte, bool) {
+	if len(data) == 0 {
+		return nil, nil, false
+	}
+	n, _, size := mysql.DecodeUintLenEnc(data)
+	if size > len(data) || uint64(len(data)-size) < n {
+		return nil, nil, false
+	}
+	end := size + int(n)
+	return data[size:end], data[end:], true
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUintLenEnc(b []byte, v uint64) []byte {
+	switch {
+	case v < 251:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return append(b, 0xFC, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(b, 0xFD, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		return append(b, 0xFE, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+			byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+	}
+}
+
+func appendStrLenEnc(b []byte, s string) []byte {
+	b = appendUintLenEnc(b, uint64(len(s)))
+	return append(b, s...)
+}
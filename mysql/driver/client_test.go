@@ -0,0 +1,336 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDSN(t *testing.T) {
+	inputs := []struct {
+		dsn string
+		exp dsnConfig
+	}{
+		{
+			dsn: "root:secret@tcp(db.local:3307)/test?readTimeout=5s",
+			exp: dsnConfig{user: "root", passwd: "secret", net: "tcp", addr: "db.local:3307", dbName: "test", collation: defaultCollation, readTimeout: 5 * time.Second},
+		},
+		{
+			dsn: "repl@tcp(10.0.0.1)/",
+			exp: dsnConfig{user: "repl", net: "tcp", addr: "10.0.0.1:3306", collation: defaultCollation},
+		},
+		{
+			dsn: "u:p@a:ss@unix(/var/run/mysqld.sock)/?parseTime=true&charset=utf8mb4,utf8",
+			exp: dsnConfig{user: "u", passwd: "p@a:ss", net: "unix", addr: "/var/run/mysqld.sock", collation: 45},
+		},
+		{
+			dsn: "/",
+			exp: dsnConfig{net: "tcp", addr: "127.0.0.1:3306", collation: defaultCollation},
+		},
+	}
+
+	for _, in := range inputs {
+		cfg, err := parseDSN(in.dsn)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", in.dsn, err)
+			continue
+		}
+		if !cmp.Equal(in.exp, *cfg, cmp.AllowUnexported(dsnConfig{})) {
+			t.Errorf("Config mismatch for %q: %s", in.dsn, cmp.Diff(in.exp, *cfg, cmp.AllowUnexported(dsnConfig{})))
+		}
+	}
+}
+
+func TestDSNString(t *testing.T) {
+	inputs := []struct {
+		dsn DSN
+		exp string
+	}{
+		{DSN{}, "/"},
+		{DSN{User: "repl", Password: "p@ss", Host: "db.local"}, "repl:p@ss@tcp(db.local:3306)/"},
+		{DSN{User: "root", Socket: "/tmp/mysql.sock", DBName: "test"}, "root@unix(/tmp/mysql.sock)/test"},
+		{
+			DSN{Host: "::1", Port: 3307, TLS: "skip-verify", ReadTimeout: 5 * time.Second, Params: map[string]string{"parseTime": "true"}},
+			"tcp([::1]:3307)/?parseTime=true&readTimeout=5s&tls=skip-verify",
+		},
+	}
+	for _, in := range inputs {
+		str := in.dsn.String()
+		if str != in.exp {
+			t.Errorf("Expected %+v to be formatted as %q, got %q", in.dsn, in.exp, str)
+			continue
+		}
+		d, err := ParseDSN(str)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", str, err)
+			continue
+		}
+		if d.String() != str {
+			t.Errorf("Round trip mismatch: %q != %q", d.String(), str)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	cnc, snc := net.Pipe()
+	srv := newPacketConn(snc, 0)
+	scramble := []byte("abcdefghijklmnopqrst")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- func() error {
+			// Handshake
+			hs := []byte{10}
+			hs = append(hs, "8.0.22\x00"...)
+			hs = appendUint32(hs, 42)
+			hs = append(hs, scramble[:8]...)
+			hs = append(hs, 0)
+			caps := clientProtocol41 | clientSecureConn | clientPluginAuth | clientPluginAuthLenEncClientData | clientLongPassword
+			hs = append(hs, byte(caps), byte(caps>>8), defaultCollation, 2, 0, byte(caps>>16), byte(caps>>24), 21)
+			hs = append(hs, make([]byte, 10)...)
+			hs = append(hs, scramble[8:]...)
+			hs = append(hs, 0)
+			hs = append(hs, authNativePassword+"\x00"...)
+			if err := srv.writePacket(append(make([]byte, 4), hs...)); err != nil {
+				return err
+			}
+
+			resp, err := srv.readPacket()
+			if err != nil {
+				return err
+			}
+			exp := scrambleNativePassword(scramble, "secret")
+			if !bytes.Contains(resp, append([]byte("repl\x00\x14"), exp...)) {
+				t.Errorf("Unexpected handshake response: %x", resp)
+			}
+			if err := srv.writePacket([]byte{0, 0, 0, 0, resultOK, 0, 0, 2, 0, 0, 0}); err != nil {
+				return err
+			}
+
+			// Query
+			srv.seq = 0
+			q, err := srv.readPacket()
+			if err != nil {
+				return err
+			}
+			if string(q) != "\x03SHOW BINARY LOGS" {
+				t.Errorf("Unexpected query: %q", q)
+			}
+			pkts := [][]byte{
+				{2},
+				append([]byte{3, 'd', 'e', 'f', 0, 0, 0, 8}, "Log_name"...),
+				append([]byte{3, 'd', 'e', 'f', 0, 0, 0, 9}, "File_size"...),
+				{resultEOF, 0, 0, 2, 0},
+				append(append([]byte{16}, "mysql-bin.000001"...), 3, '1', '2', '3'),
+				append(append([]byte{16}, "mysql-bin.000002"...), 1, '4'),
+				{resultEOF, 0, 0, 2, 0},
+			}
+			for _, p := range pkts {
+				if err := srv.writePacket(append(make([]byte, 4), p...)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	cfg := &dsnConfig{user: "repl", passwd: "secret", net: "tcp"}
+	c := &client{packetConn: newPacketConn(cnc, 0), cfg: cfg, readTimeout: time.Second, watcher: newWatcher()}
+	if err := c.handshake(context.Background()); err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	if c.serverVersion != "8.0.22" || c.connectionID != 42 {
+		t.Errorf("Unexpected server details: %q %d", c.serverVersion, c.connectionID)
+	}
+
+	conn := &Conn{conn: c}
+	logs, err := conn.BinaryLogs()
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	exp := []BinaryLog{{"mysql-bin.000001", 123}, {"mysql-bin.000002", 4}}
+	if !cmp.Equal(exp, logs) {
+		t.Errorf("Binary logs mismatch: %s", cmp.Diff(exp, logs))
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Server failed: %v", err)
+	}
+}
+
+func TestQueryEmptyRow(t *testing.T) {
+	cnc, snc := net.Pipe()
+	srv := newPacketConn(snc, 0)
+	go func() {
+		if _, err := srv.readPacket(); err != nil {
+			return
+		}
+		pkts := [][]byte{
+			{1},
+			append([]byte{3, 'd', 'e', 'f', 0, 0, 0, 8}, "Log_name"...),
+			{resultEOF, 0, 0, 2, 0},
+			{},
+		}
+		for _, p := range pkts {
+			if err := srv.writePacket(append(make([]byte, 4), p...)); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := &client{packetConn: newPacketConn(cnc, 0), cfg: &dsnConfig{}, readTimeout: time.Second, watcher: newWatcher()}
+	if _, err := c.query(context.Background(), "SHOW BINARY LOGS"); err != ErrMalformedPacket {
+		t.Errorf("Expected malformed packet error, got %v", err)
+	}
+}
+
+func TestReadPacketResume(t *testing.T) {
+	cnc, snc := net.Pipe()
+	c := newPacketConn(cnc, 0)
+
+	payload := []byte("hello")
+	pkt := make([]byte, 4, 4+len(payload))
+	binary.LittleEndian.PutUint32(pkt, uint32(len(payload)))
+	pkt = append(pkt, payload...)
+
+	go func() {
+		// Send the packet in two parts with a pause in between
+		snc.Write(pkt[:6])
+		time.Sleep(50 * time.Millisecond)
+		snc.Write(pkt[6:])
+	}()
+
+	c.setReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := c.readPacket(); err == nil {
+		t.Fatal("Expected read to time out")
+	}
+	c.setReadDeadline(time.Time{})
+	data, err := c.readPacket()
+	if err != nil {
+		t.Fatalf("Failed to resume reading: %v", err)
+	}
+	if !bytes.Equal(payload, data) {
+		t.Errorf("Expected payload %q, got %q", payload, data)
+	}
+}
+
+func TestReadPacketCancel(t *testing.T) {
+	cnc, snc := net.Pipe()
+	defer snc.Close()
+	srv := newPacketConn(snc, 0)
+	conn := &Conn{conn: &client{packetConn: newPacketConn(cnc, 0), cfg: &dsnConfig{}, watcher: newWatcher()}}
+	defer conn.Abort()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := conn.ReadPacket(ctx); err != context.Canceled {
+		t.Fatalf("Expected read to be cancelled, got %v", err)
+	}
+
+	// The watcher is re-armed with the context of the next read
+	go func() {
+		for i := byte(1); i <= 3; i++ {
+			if err := srv.writePacket([]byte{0, 0, 0, 0, resultOK, i}); err != nil {
+				return
+			}
+		}
+	}()
+	for i := byte(1); i <= 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		data, err := conn.ReadPacket(ctx)
+		cancel()
+		if err != nil || !bytes.Equal(data, []byte{i}) {
+			t.Fatalf("Expected packet %d, got %v %v", i, data, err)
+		}
+	}
+}
+
+func TestReadLargePacket(t *testing.T) {
+	testCases := []struct {
+		name string
+		size int
+	}{
+		{"single", 100},
+		{"exact", maxPacketSize},
+		{"split", maxPacketSize + 100},
+		{"double", 2*maxPacketSize + 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cnc, snc := net.Pipe()
+			defer cnc.Close()
+			defer snc.Close()
+
+			payload := make([]byte, tc.size)
+			for i := range payload {
+				payload[i] = byte(i)
+			}
+			go func() {
+				sc := newPacketConn(snc, 0)
+				data := make([]byte, 4, 4+len(payload))
+				sc.writePacket(append(data, payload...))
+				// Followed by a small packet
+				sc.writePacket([]byte{0, 0, 0, 0, 'x'})
+			}()
+
+			c := newPacketConn(cnc, 0)
+			data, err := c.readPacket()
+			if err != nil {
+				t.Fatalf("Failed to read packet: %v", err)
+			}
+			if !bytes.Equal(payload, data) {
+				t.Fatalf("Expected payload of %d bytes, got %d bytes", len(payload), len(data))
+			}
+			data, err = c.readPacket()
+			if err != nil {
+				t.Fatalf("Failed to read next packet: %v", err)
+			}
+			if string(data) != "x" {
+				t.Errorf("Expected next payload %q, got %q", "x", data)
+			}
+			if len(c.buf) != defaultBufSize {
+				t.Errorf("Expected buffer to shrink to %d bytes, got %d", defaultBufSize, len(c.buf))
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	for _, c := range []struct {
+		err    *Error
+		target error
+	}{
+		{&Error{Code: 1045, Message: "Access denied for user 'repl'@'localhost'"}, ErrAuthFailed},
+		{&Error{Code: 1236, Message: "Could not find first log file name in binary log index file"}, ErrPositionPurged},
+		{&Error{Code: 1236, Message: "The replica is connecting using CHANGE REPLICATION SOURCE TO SOURCE_AUTO_POSITION = 1, but the source has purged binary logs containing GTIDs that the replica requires."}, ErrPositionPurged},
+		{&Error{Code: 1236, Message: "event read from binlog did not pass crc check"}, ErrChecksumMismatch},
+		{&Error{Code: 1236, Message: "A replica with the same server_uuid/server_id as this replica has connected to the source"}, ErrServerIDConflict},
+	} {
+		if !errors.Is(c.err, c.target) {
+			t.Errorf("Expected %v to match %v", c.err, c.target)
+		}
+	}
+	if err := (&Error{Code: 1236, Message: "binlog truncated in the middle of event"}); errors.Is(err, ErrPositionPurged) || errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected %v to match neither purged position nor checksum mismatch", err)
+	}
+}
+
+func TestEncryptPasswordEmptySeed(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := encryptPassword("secret", nil, &key.PublicKey); err != ErrMalformedPacket {
+		t.Errorf("Expected malformed packet error, got %v", err)
+	}
+	if _, err := encryptPassword("secret", []byte("abcdefghijklmnopqrst"), &key.PublicKey); err != nil {
+		t.Errorf("Failed to encrypt password: %v", err)
+	}
+}
@@ -1,17 +1,23 @@
+// Package driver implements a replication connection to a MySQL server. It is
+// the only package that talks to the server directly, features like GTID based
+// dumps and heartbeats belong here.
 package driver
 
 import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Vivino/bocadillo/buffer"
-	"github.com/Vivino/bocadillo/mysql/driver/internal/mysql"
+	"github.com/juju/errors"
 )
 
 // Conn is a connection used to issue a binlog dump command.
 type Conn struct {
-	conn *mysql.ExtendedConn
+	conn *client
 	conf Config
 }
 
@@ -28,12 +34,73 @@ type Config struct {
 	// Hostname along with server ID is used to identify the replica server
 	// connection.
 	Hostname string
+
+	// DialTimeout limits the time spent establishing a network connection.
+	DialTimeout time.Duration
+	// HandshakeTimeout limits the time spent handshaking and authenticating
+	// with the server.
+	HandshakeTimeout time.Duration
+	// ReadTimeout limits the time spent waiting for each packet once the
+	// connection is established. It should be longer than the heartbeat
+	// period, otherwise reads would time out on an idle binary log.
+	ReadTimeout time.Duration
+	// ReadBufferSize is the size of the connection read buffer. Packets that
+	// are received together are read with a single system call, so a larger
+	// buffer reduces the number of reads on busy streams at the cost of memory.
+	// The buffer grows temporarily to fit larger packets. Default size is
+	// 16KB.
+	ReadBufferSize int
+
+	// ConnectAttrs are additional connection attributes sent to the server
+	// during the handshake. Client name and version, program name, host and
+	// process ID are always sent.
+	ConnectAttrs map[string]string
+
+	// DumpFlags are sent with the binlog dump command.
+	DumpFlags DumpFlags
+
+	// Credentials, when set, is invoked on every connection attempt and the
+	// credentials it returns override the ones from the data source name.
+	Credentials CredentialsProvider
 }
 
+// DumpFlags is a bitmask of binlog dump command flags.
+type DumpFlags uint16
+
+const (
+	// DumpFlagNonBlock makes the server send an EOF packet and stop once it
+	// reaches the end of the binary log instead of waiting for new events.
+	DumpFlagNonBlock DumpFlags = 0x01
+	// DumpFlagThroughPosition makes a GTID based dump start at the given
+	// file and offset. Only applies to MySQL.
+	DumpFlagThroughPosition DumpFlags = 0x02
+	// DumpFlagThroughGTID makes a GTID based dump use the GTID set. It is
+	// always set for GTID based dumps.
+	DumpFlagThroughGTID DumpFlags = 0x04
+	// DumpFlagMariaDBSendAnnotateRows makes MariaDB send annotate rows events
+	// containing the original queries. It shares the value with
+	// DumpFlagThroughPosition and only applies to MariaDB.
+	DumpFlagMariaDBSendAnnotateRows DumpFlags = 0x02
+)
+
+// Credentials is a user name and a password used to authenticate. Password
+// could also be an authentication token, e.g. one issued by a cloud provider,
+// such tokens are usually sent with the mysql_clear_password plugin which
+// requires TLS.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// CredentialsProvider returns credentials used to authenticate a connection.
+// It allows rotating secrets without restarting the reader.
+type CredentialsProvider func(ctx context.Context) (Credentials, error)
+
 const (
 	// Commands
-	comRegisterSlave byte = 21
-	comBinlogDump    byte = 18
+	comRegisterSlave  byte = 21
+	comBinlogDump     byte = 18
+	comBinlogDumpGTID byte = 30
 
 	// Result codes
 	resultOK  byte = 0x00
@@ -41,11 +108,15 @@ const (
 	resultERR byte = 0xFF
 )
 
-// Connect esablishes a new database connection. It is a go-sql-driver
-// connection with a few low level functions exposed and with a high level
-// wrapper that allows to execute just a few commands that are required for
-// operation.
+// Connect esablishes a new database connection. It is a minimal native client
+// that allows to execute just a few commands that are required for operation.
+// Data source name format is compatible with go-sql-driver/mysql.
 func Connect(dsn string, conf Config) (*Conn, error) {
+	return ConnectContext(context.Background(), dsn, conf)
+}
+
+// ConnectContext is like Connect but gives up once the context is done.
+func ConnectContext(ctx context.Context, dsn string, conf Config) (*Conn, error) {
 	if conf.Hostname == "" {
 		name, err := os.Hostname()
 		if err != nil {
@@ -58,43 +129,82 @@ func Connect(dsn string, conf Config) (*Conn, error) {
 		conf.Offset = 4
 	}
 
-	conn, err := (mysql.MySQLDriver{}).Open(dsn)
+	cfg, err := parseDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
+	cfg.connectAttrs = conf.ConnectAttrs
+	cfg.readBufferSize = conf.ReadBufferSize
+	if conf.Credentials != nil {
+		cred, err := conf.Credentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cfg.user, cfg.passwd = cred.User, cred.Password
+	}
+	dialTimeout := cfg.timeout
+	if conf.DialTimeout > 0 {
+		dialTimeout = conf.DialTimeout
+	}
+	handshakeTimeout := cfg.readTimeout
+	if conf.HandshakeTimeout > 0 {
+		handshakeTimeout = conf.HandshakeTimeout
+	}
 
-	extconn, err := mysql.ExtendConn(conn)
+	conn, err := dial(ctx, cfg, dialTimeout, handshakeTimeout)
 	if err != nil {
 		return nil, err
 	}
+	conn.readTimeout = cfg.readTimeout
+	if conf.ReadTimeout > 0 {
+		conn.readTimeout = conf.ReadTimeout
+	}
+	conn.writeTimeout = cfg.writeTimeout
 
-	return &Conn{conn: extconn, conf: conf}, nil
+	return &Conn{conn: conn, conf: conf}, nil
 }
 
 // ReadPacket reads next packet from the server and peeks at the status byte.
-func (c *Conn) ReadPacket(ctx context.Context) ([]byte, error) {
-	data, err := c.conn.ReadPacket(ctx)
+// Nil slice is returned for an EOF packet which is sent in non-blocking mode
+// once the end of the binary log is reached. Returned slice is only valid
+// until the next read. A read that times out could be retried, partially
+// received data is retained. Cancelling the context interrupts the read.
+func (c *Conn) ReadPacket(ctx context.Context) (_ []byte, err error) {
+	if err := c.conn.setDeadlines(ctx); err != nil {
+		return nil, err
+	}
+	c.conn.watch(ctx)
+	defer c.conn.unwatch(ctx, &err)
+
+	data, err := c.conn.readPacket()
 	if err != nil {
 		return nil, err
 	}
+	if len(data) == 0 {
+		return nil, ErrMalformedPacket
+	}
 
 	switch data[0] {
 	case resultOK:
 		return data[1:], nil
 	case resultERR:
-		return nil, c.conn.HandleErrorPacket(data)
+		return nil, parseError(data)
 	case resultEOF:
 		return nil, nil
 	default:
-		return nil, fmt.Errorf("unexpected header: %x", data[0])
+		return nil, errors.Errorf("Unexpected header: %x", data[0])
 	}
 }
 
 // RegisterSlave issues a REGISTER_SLAVE command to master.
 // Spec: https://dev.mysql.com/doc/internals/en/com-register-slave.html
 func (c *Conn) RegisterSlave() error {
-	c.conn.ResetSequence()
+	return c.RegisterSlaveContext(context.Background())
+}
 
+// RegisterSlaveContext is like RegisterSlave but gives up once the context is
+// done.
+func (c *Conn) RegisterSlaveContext(ctx context.Context) error {
 	buf := buffer.NewCommandBuffer(1 + 4 + 1 + len(c.conf.Hostname) + 1 + 1 + 2 + 4 + 4)
 	buf.WriteByte(comRegisterSlave)
 	buf.WriteUint32(c.conf.ServerID)
@@ -108,44 +218,309 @@ func (c *Conn) RegisterSlave() error {
 	// buf.WriteUint32(replicationRank)
 	// buf.WriteUint32(masterID)
 
-	return c.runCmd(buf.Bytes())
+	return c.runCmd(ctx, buf.Bytes())
 }
 
-// StartBinlogDump issues a BINLOG_DUMP command to master.
+// StartBinlogDump issues a BINLOG_DUMP command to master. The server doesn't
+// acknowledge the command, it starts sending events right away beginning with
+// an artificial rotate event. Errors are returned with the first packet.
+// MariaDB servers are asked to send GTID events as is.
 // Spec: https://dev.mysql.com/doc/internals/en/com-binlog-dump.html
-// TODO: https://dev.mysql.com/doc/internals/en/com-binlog-dump-gtid.html
 func (c *Conn) StartBinlogDump() error {
-	c.conn.ResetSequence()
+	return c.StartBinlogDumpContext(context.Background())
+}
+
+// StartBinlogDumpContext is like StartBinlogDump but gives up once the context
+// is done.
+func (c *Conn) StartBinlogDumpContext(ctx context.Context) error {
+	if c.ServerInfo().IsMariaDB() {
+		if err := c.SetVarContext(ctx, "@mariadb_slave_capability", mariadbSlaveCapabilityGTID); err != nil {
+			return err
+		}
+	}
 
 	buf := buffer.NewCommandBuffer(1 + 4 + 2 + 4 + len(c.conf.File))
 	buf.WriteByte(comBinlogDump)
 	buf.WriteUint32(uint32(c.conf.Offset))
-	buf.Skip(2) // Flags
+	buf.WriteUint16(uint16(c.conf.DumpFlags))
 	buf.WriteUint32(c.conf.ServerID)
 	buf.WriteStringEOF(c.conf.File)
 
-	return c.runCmd(buf.Bytes())
+	return c.writeCmd(ctx, buf.Bytes())
+}
+
+// StartBinlogDumpGTID issues a BINLOG_DUMP_GTID command to master. Given GTID
+// set must be encoded in binary form. Server sends all transactions that are
+// not contained in the set. Just like with StartBinlogDump errors are returned
+// with the first packet. MariaDB doesn't support this command.
+// Spec: https://dev.mysql.com/doc/internals/en/com-binlog-dump-gtid.html
+func (c *Conn) StartBinlogDumpGTID(gtidSet []byte) error {
+	return c.StartBinlogDumpGTIDContext(context.Background(), gtidSet)
+}
+
+// StartBinlogDumpGTIDContext is like StartBinlogDumpGTID but gives up once the
+// context is done.
+func (c *Conn) StartBinlogDumpGTIDContext(ctx context.Context, gtidSet []byte) error {
+	if c.ServerInfo().IsMariaDB() {
+		return ErrGTIDDumpUnsupported
+	}
+
+	buf := buffer.NewCommandBuffer(1 + 2 + 4 + 4 + len(c.conf.File) + 8 + 4 + len(gtidSet))
+	buf.WriteByte(comBinlogDumpGTID)
+	buf.WriteUint16(uint16(c.conf.DumpFlags | DumpFlagThroughGTID))
+	buf.WriteUint32(c.conf.ServerID)
+	buf.WriteUint32(uint32(len(c.conf.File)))
+	buf.WriteStringEOF(c.conf.File)
+	buf.WriteUint64(uint64(c.conf.Offset))
+	buf.WriteUint32(uint32(len(gtidSet)))
+	buf.WriteBytes(gtidSet)
+
+	return c.writeCmd(ctx, buf.Bytes())
 }
 
 // DisableChecksum disables CRC32 checksums for this connection.
 func (c *Conn) DisableChecksum() error {
-	return c.SetVar("@master_binlog_checksum", "NONE")
+	return c.DisableChecksumContext(context.Background())
+}
+
+// DisableChecksumContext is like DisableChecksum but gives up once the context
+// is done.
+func (c *Conn) DisableChecksumContext(ctx context.Context) error {
+	return c.setSourceVar(ctx, "binlog_checksum", "'NONE'")
+}
+
+// EnableChecksum makes the server send events with checksums if they are
+// enabled for the binary log.
+func (c *Conn) EnableChecksum() error {
+	return c.EnableChecksumContext(context.Background())
+}
+
+// EnableChecksumContext is like EnableChecksum but gives up once the context
+// is done.
+func (c *Conn) EnableChecksumContext(ctx context.Context) error {
+	err := c.setSourceVar(ctx, "binlog_checksum", "@@global.binlog_checksum")
+	if e, ok := err.(*Error); ok && e.Code == errUnknownSystemVariable {
+		// Some managed servers hide the variable, awareness of checksums
+		// is declared explicitly then and the server uses its own setting
+		return c.setSourceVar(ctx, "binlog_checksum", "'CRC32'")
+	}
+	return err
+}
+
+// SetHeartbeatPeriod makes the server send heartbeat events when there are no
+// other events to send for the given period of time.
+func (c *Conn) SetHeartbeatPeriod(d time.Duration) error {
+	return c.SetHeartbeatPeriodContext(context.Background(), d)
+}
+
+// SetHeartbeatPeriodContext is like SetHeartbeatPeriod but gives up once the
+// context is done.
+func (c *Conn) SetHeartbeatPeriodContext(ctx context.Context, d time.Duration) error {
+	return c.setSourceVar(ctx, "heartbeat_period", strconv.FormatInt(d.Nanoseconds(), 10))
+}
+
+// setSourceVar sets a user variable the server reads when a binlog dump
+// starts. Servers that renamed @master_ variables to @source_ ones get both,
+// just like MySQL replicas do, as older names are still read by some of them.
+func (c *Conn) setSourceVar(ctx context.Context, name, expr string) error {
+	q := fmt.Sprintf("SET @master_%s = %s", name, expr)
+	if c.ServerInfo().Features.Has(FeatureSourceVariables) {
+		q = fmt.Sprintf("SET @source_%s = %s, @master_%s = %s", name, expr, name, expr)
+	}
+	return c.conn.exec(ctx, q)
+}
+
+// SessionTimeouts are network timeouts the server applies to the session.
+// Zero values keep server defaults.
+type SessionTimeouts struct {
+	// NetRead is the net_read_timeout, the time the server waits for more
+	// data from the client.
+	NetRead time.Duration
+	// NetWrite is the net_write_timeout, the time the server waits for a
+	// write to the client to complete.
+	NetWrite time.Duration
+	// Wait is the wait_timeout, the time the server waits for activity on an
+	// idle connection before closing it.
+	Wait time.Duration
+}
+
+// SetSessionTimeouts sets server side network timeouts for this connection.
+// Default timeouts are tuned for interactive clients and could make the server
+// close a replication connection of a slow consumer.
+func (c *Conn) SetSessionTimeouts(ctx context.Context, t SessionTimeouts) error {
+	var vars []string
+	for _, v := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"net_read_timeout", t.NetRead},
+		{"net_write_timeout", t.NetWrite},
+		{"wait_timeout", t.Wait},
+	} {
+		if v.d > 0 {
+			vars = append(vars, fmt.Sprintf("SESSION %s = %d", v.name, int64(v.d/time.Second)))
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	return c.conn.exec(ctx, "SET "+strings.Join(vars, ", "))
 }
 
 // SetVar assigns a new value to the given variable.
 func (c *Conn) SetVar(name, val string) error {
-	return c.conn.Exec(fmt.Sprintf("SET %s=%q", name, val))
+	return c.SetVarContext(context.Background(), name, val)
 }
 
-// Close the connection.
-func (c *Conn) Close() error {
-	return c.conn.Close()
+// SetVarContext is like SetVar but gives up once the context is done.
+func (c *Conn) SetVarContext(ctx context.Context, name, val string) error {
+	return c.conn.exec(ctx, fmt.Sprintf("SET %s=%q", name, val))
+}
+
+// GetVar returns the value of the given variable, e.g. "@@global.log_bin".
+// NULL values are returned as empty strings.
+func (c *Conn) GetVar(name string) (string, error) {
+	return c.GetVarContext(context.Background(), name)
 }
 
-func (c *Conn) runCmd(data []byte) error {
-	err := c.conn.WritePacket(data)
+// GetVarContext is like GetVar but gives up once the context is done.
+func (c *Conn) GetVarContext(ctx context.Context, name string) (string, error) {
+	row, err := c.QueryRow(ctx, "SELECT "+name)
+	if err == ErrNoRows || err == nil && len(row) == 0 {
+		return "", errors.Errorf("No value returned for %s", name)
+	}
 	if err != nil {
+		return "", err
+	}
+	return row[0], nil
+}
+
+// GetVars returns values of the given global variables, e.g. "log_bin", in a
+// single round trip. Variables unknown to the server are missing from the
+// returned map.
+func (c *Conn) GetVars(names ...string) (map[string]string, error) {
+	return c.GetVarsContext(context.Background(), names...)
+}
+
+// GetVarsContext is like GetVars but gives up once the context is done.
+func (c *Conn) GetVarsContext(ctx context.Context, names ...string) (map[string]string, error) {
+	vars := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return vars, nil
+	}
+
+	var q strings.Builder
+	q.WriteString("SHOW GLOBAL VARIABLES WHERE Variable_name IN (")
+	for i, name := range names {
+		if i > 0 {
+			q.WriteByte(',')
+		}
+		q.WriteString("'" + strings.Replace(name, "'", "''", -1) + "'")
+	}
+	q.WriteByte(')')
+
+	res, err := c.conn.query(ctx, q.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range res.rows {
+		if len(row) < 2 {
+			return nil, ErrMalformedPacket
+		}
+		vars[row[0].String] = row[1].String
+	}
+	return vars, nil
+}
+
+// QueryRow executes a query and returns values of the first row of its result.
+// NULL values are returned as empty strings. ErrNoRows is returned if the
+// result is empty.
+func (c *Conn) QueryRow(ctx context.Context, query string) ([]string, error) {
+	res, err := c.conn.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.rows) == 0 {
+		return nil, ErrNoRows
+	}
+	row := make([]string, len(res.rows[0]))
+	for i, v := range res.rows[0] {
+		row[i] = v.String
+	}
+	return row, nil
+}
+
+// BinaryLog describes a binary log file on the server.
+type BinaryLog struct {
+	Name string
+	Size uint64
+}
+
+// BinaryLogs returns the list of binary log files on the server, oldest first.
+func (c *Conn) BinaryLogs() ([]BinaryLog, error) {
+	return c.BinaryLogsContext(context.Background())
+}
+
+// BinaryLogsContext is like BinaryLogs but gives up once the context is done.
+func (c *Conn) BinaryLogsContext(ctx context.Context) ([]BinaryLog, error) {
+	res, err := c.conn.query(ctx, "SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+
+	// Newer server versions return additional columns
+	logs := make([]BinaryLog, 0, len(res.rows))
+	for _, row := range res.rows {
+		if len(row) < 2 {
+			return nil, ErrMalformedPacket
+		}
+		bl := BinaryLog{Name: row[0].String}
+		if bl.Size, err = strconv.ParseUint(row[1].String, 10, 64); err != nil {
+			return nil, errors.Errorf("Invalid binary log size: %q", row[1].String)
+		}
+		logs = append(logs, bl)
+	}
+	return logs, nil
+}
+
+// Ping checks that the connection is alive. It must not be called while a
+// binlog dump is in progress.
+func (c *Conn) Ping(ctx context.Context) error {
+	return c.conn.ping(ctx)
+}
+
+// Close the connection gracefully by sending a QUIT command first. It could be
+// called concurrently with ReadPacket.
+func (c *Conn) Close() error {
+	return c.conn.quit()
+}
+
+// Abort closes the network connection without notifying the server.
+func (c *Conn) Abort() {
+	c.conn.watcher.close()
+	c.conn.nc.Close()
+}
+
+func (c *Conn) runCmd(ctx context.Context, data []byte) (err error) {
+	if err := c.conn.setDeadlines(ctx); err != nil {
+		return err
+	}
+	c.conn.watch(ctx)
+	defer c.conn.unwatch(ctx, &err)
+
+	if err := c.conn.writeCommand(data); err != nil {
 		return err
 	}
-	return c.conn.ReadResultOK()
+	return c.conn.readResultOK()
+}
+
+// writeCmd sends a command the server doesn't acknowledge.
+func (c *Conn) writeCmd(ctx context.Context, data []byte) (err error) {
+	if err := c.conn.setDeadlines(ctx); err != nil {
+		return err
+	}
+	c.conn.watch(ctx)
+	defer c.conn.unwatch(ctx, &err)
+
+	return c.conn.writeCommand(data)
 }
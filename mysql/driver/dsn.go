@@ -0,0 +1,328 @@
+package driver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// ErrInvalidDSN is returned when a data source name can't be parsed.
+var ErrInvalidDSN = errors.New("Invalid data source name")
+
+// DSN is a structured data source name. The string format is compatible with
+// the one used by go-sql-driver/mysql:
+//
+//	[user[:password]@][net[(addr)]]/dbname[?param1=value1&paramN=valueN]
+//
+// Supported parameters are tls, timeout, readTimeout, writeTimeout, charset
+// and allowCleartextPasswords, other parameters are kept in Params but have no
+// effect on the replication connection.
+type DSN struct {
+	User     string
+	Password string
+	// Host and Port of the server, Port defaults to 3306.
+	Host string
+	Port int
+	// Socket is a path to a unix socket, it is used instead of Host and Port
+	// when set.
+	Socket string
+	DBName string
+
+	// TLS is either true, false, skip-verify, preferred or a name of a
+	// configuration registered with RegisterTLSConfig.
+	TLS string
+	// AllowCleartextPasswords allows to send passwords in clear text over an
+	// insecure connection.
+	AllowCleartextPasswords bool
+	// Charset is the connection character set, utf8 is used by default.
+	Charset string
+
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Params contains parameters unknown to this package.
+	Params map[string]string
+}
+
+// dsnConfig contains connection details resolved from a data source name.
+type dsnConfig struct {
+	user   string
+	passwd string
+	net    string
+	addr   string
+	dbName string
+
+	tlsConfig      *tls.Config
+	tlsPreferred   bool
+	allowCleartext bool
+	collation      byte
+
+	timeout      time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	readBufferSize int
+	connectAttrs   map[string]string
+}
+
+const defaultPort = 3306
+
+// collations maps supported character sets to their default collations.
+var collations = map[string]byte{
+	"utf8":    defaultCollation,
+	"utf8mb4": 45,
+	"latin1":  8,
+	"ascii":   11,
+	"binary":  63,
+}
+
+var (
+	tlsConfigsMu sync.RWMutex
+	tlsConfigs   = make(map[string]*tls.Config)
+)
+
+// RegisterTLSConfig registers a custom TLS configuration that could be
+// referenced in a data source name with the tls parameter.
+func RegisterTLSConfig(key string, config *tls.Config) {
+	tlsConfigsMu.Lock()
+	tlsConfigs[key] = config
+	tlsConfigsMu.Unlock()
+}
+
+// ParseDSN parses a data source name.
+func ParseDSN(dsn string) (*DSN, error) {
+	d := &DSN{}
+
+	// Database name and parameters follow the last slash
+	slash := strings.LastIndexByte(dsn, '/')
+	if slash < 0 {
+		return nil, ErrInvalidDSN
+	}
+	rest := dsn[slash+1:]
+	if q := strings.IndexByte(rest, '?'); q >= 0 {
+		if err := d.parseParams(rest[q+1:]); err != nil {
+			return nil, err
+		}
+		rest = rest[:q]
+	}
+	d.DBName = rest
+
+	// Credentials precede the last @ sign
+	addr := dsn[:slash]
+	if at := strings.LastIndexByte(addr, '@'); at >= 0 {
+		cred := addr[:at]
+		addr = addr[at+1:]
+		if colon := strings.IndexByte(cred, ':'); colon >= 0 {
+			d.User, d.Password = cred[:colon], cred[colon+1:]
+		} else {
+			d.User = cred
+		}
+	}
+
+	// Network and address
+	netw := "tcp"
+	if open := strings.IndexByte(addr, '('); open >= 0 {
+		if !strings.HasSuffix(addr, ")") {
+			return nil, ErrInvalidDSN
+		}
+		netw = addr[:open]
+		addr = addr[open+1 : len(addr)-1]
+	} else if addr != "" {
+		netw, addr = addr, ""
+	}
+	switch netw {
+	case "tcp":
+		if addr == "" {
+			break
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			d.Host = addr
+			break
+		}
+		d.Host = host
+		if d.Port, err = strconv.Atoi(port); err != nil {
+			return nil, ErrInvalidDSN
+		}
+	case "unix":
+		d.Socket = addr
+		if d.Socket == "" {
+			d.Socket = "/tmp/mysql.sock"
+		}
+	default:
+		return nil, errors.Errorf("Unsupported network: %s", netw)
+	}
+	return d, nil
+}
+
+func (d *DSN) parseParams(params string) error {
+	vals, err := url.ParseQuery(params)
+	if err != nil {
+		return ErrInvalidDSN
+	}
+	for key := range vals {
+		val := vals.Get(key)
+		switch key {
+		case "tls":
+			d.TLS = val
+		case "timeout":
+			if d.Timeout, err = time.ParseDuration(val); err != nil {
+				return errors.Errorf("Invalid timeout: %s", val)
+			}
+		case "readTimeout":
+			if d.ReadTimeout, err = time.ParseDuration(val); err != nil {
+				return errors.Errorf("Invalid read timeout: %s", val)
+			}
+		case "writeTimeout":
+			if d.WriteTimeout, err = time.ParseDuration(val); err != nil {
+				return errors.Errorf("Invalid write timeout: %s", val)
+			}
+		case "allowCleartextPasswords":
+			if d.AllowCleartextPasswords, err = strconv.ParseBool(val); err != nil {
+				return errors.Errorf("Invalid allowCleartextPasswords: %s", val)
+			}
+		case "charset":
+			d.Charset = val
+		default:
+			if d.Params == nil {
+				d.Params = make(map[string]string)
+			}
+			d.Params[key] = val
+		}
+	}
+	return nil
+}
+
+// String formats a data source name. Parameters are sorted by name.
+func (d DSN) String() string {
+	var b strings.Builder
+	if d.User != "" || d.Password != "" {
+		b.WriteString(d.User)
+		if d.Password != "" {
+			b.WriteByte(':')
+			b.WriteString(d.Password)
+		}
+		b.WriteByte('@')
+	}
+	if d.Socket != "" {
+		b.WriteString("unix(" + d.Socket + ")")
+	} else if d.Host != "" || d.Port != 0 {
+		b.WriteString("tcp(" + d.addr() + ")")
+	}
+	b.WriteByte('/')
+	b.WriteString(d.DBName)
+
+	params := make(url.Values)
+	for k, v := range d.Params {
+		params.Set(k, v)
+	}
+	if d.TLS != "" {
+		params.Set("tls", d.TLS)
+	}
+	if d.AllowCleartextPasswords {
+		params.Set("allowCleartextPasswords", "true")
+	}
+	if d.Charset != "" {
+		params.Set("charset", d.Charset)
+	}
+	if d.Timeout > 0 {
+		params.Set("timeout", d.Timeout.String())
+	}
+	if d.ReadTimeout > 0 {
+		params.Set("readTimeout", d.ReadTimeout.String())
+	}
+	if d.WriteTimeout > 0 {
+		params.Set("writeTimeout", d.WriteTimeout.String())
+	}
+	if len(params) > 0 {
+		// Encode sorts parameters by name
+		b.WriteByte('?')
+		b.WriteString(params.Encode())
+	}
+	return b.String()
+}
+
+// addr returns host and port of a TCP connection.
+func (d DSN) addr() string {
+	host, port := d.Host, d.Port
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if port == 0 {
+		port = defaultPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// config resolves connection details.
+func (d DSN) config() (*dsnConfig, error) {
+	cfg := &dsnConfig{
+		user:           d.User,
+		passwd:         d.Password,
+		net:            "tcp",
+		addr:           d.addr(),
+		dbName:         d.DBName,
+		allowCleartext: d.AllowCleartextPasswords,
+		collation:      defaultCollation,
+		timeout:        d.Timeout,
+		readTimeout:    d.ReadTimeout,
+		writeTimeout:   d.WriteTimeout,
+	}
+	if d.Socket != "" {
+		cfg.net, cfg.addr = "unix", d.Socket
+	}
+
+	if d.Charset != "" {
+		// Multiple character sets could be listed, first supported one is used
+		found := false
+		for _, cs := range strings.Split(d.Charset, ",") {
+			if coll, ok := collations[strings.TrimSpace(cs)]; ok {
+				cfg.collation, found = coll, true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("Unsupported charset: %s", d.Charset)
+		}
+	}
+
+	switch strings.ToLower(d.TLS) {
+	case "false", "0", "":
+	case "true", "1":
+		cfg.tlsConfig = &tls.Config{}
+	case "skip-verify":
+		cfg.tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	case "preferred":
+		cfg.tlsConfig = &tls.Config{InsecureSkipVerify: true}
+		cfg.tlsPreferred = true
+	default:
+		tlsConfigsMu.RLock()
+		tc, ok := tlsConfigs[d.TLS]
+		tlsConfigsMu.RUnlock()
+		if !ok {
+			return nil, errors.Errorf("Unknown TLS config: %s", d.TLS)
+		}
+		cfg.tlsConfig = tc
+	}
+	if cfg.tlsConfig != nil && cfg.tlsConfig.ServerName == "" && !cfg.tlsConfig.InsecureSkipVerify {
+		host, _, _ := net.SplitHostPort(cfg.addr)
+		cfg.tlsConfig = cfg.tlsConfig.Clone()
+		cfg.tlsConfig.ServerName = host
+	}
+	return cfg, nil
+}
+
+func parseDSN(dsn string) (*dsnConfig, error) {
+	d, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return d.config()
+}
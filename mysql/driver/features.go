@@ -0,0 +1,96 @@
+package driver
+
+import (
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// Features is a set of protocol and binary log changes introduced by MySQL
+// after 8.0 that affect replication clients. Commands, variables and events
+// are picked based on the features of the server instead of its version, so
+// that upgrades of the server are handled in one place.
+type Features uint32
+
+// Feature flags.
+const (
+	// FeaturePartialJSON is support of partial JSON updates in rows events,
+	// MySQL 8.0.3.
+	FeaturePartialJSON Features = 1 << iota
+	// FeatureTransactionCompression is support of compressed transaction
+	// payload events, MySQL 8.0.20.
+	FeatureTransactionCompression
+	// FeatureReplicaCommands is support of SHOW REPLICAS and other commands
+	// that replaced the "slave" terminology, MySQL 8.0.22.
+	FeatureReplicaCommands
+	// FeatureSourceVariables is support of @source_binlog_checksum and
+	// @source_heartbeat_period user variables that replaced the "master"
+	// ones, MySQL 8.0.26.
+	FeatureSourceVariables
+	// FeatureHeartbeatV2 is support of heartbeat events with positions
+	// beyond 4GB, MySQL 8.0.26.
+	FeatureHeartbeatV2
+	// FeatureBinaryLogStatus is support of SHOW BINARY LOG STATUS, MySQL 8.2.
+	// SHOW MASTER STATUS was removed in MySQL 8.4.
+	FeatureBinaryLogStatus
+	// FeatureTaggedGTIDs is support of tagged GTIDs, MySQL 8.3.
+	FeatureTaggedGTIDs
+)
+
+// featureVersions contains the oldest MySQL versions features appeared in.
+// None of the features apply to MariaDB.
+var featureVersions = []struct {
+	feature Features
+	name    string
+	version int
+}{
+	{FeaturePartialJSON, "partial_json", 80003},
+	{FeatureTransactionCompression, "transaction_compression", 80020},
+	{FeatureReplicaCommands, "replica_commands", 80022},
+	{FeatureSourceVariables, "source_variables", 80026},
+	{FeatureHeartbeatV2, "heartbeat_v2", 80026},
+	{FeatureBinaryLogStatus, "binary_log_status", 80200},
+	{FeatureTaggedGTIDs, "tagged_gtids", 80300},
+}
+
+// FeaturesOf returns features supported by the server of given version, as
+// reported by SELECT @@version.
+func FeaturesOf(version string) Features {
+	sd := binlog.ParseServerDetails(version)
+	return featuresOf(sd.Flavor, sd.Version)
+}
+
+// featuresOf returns features supported by the server of given flavor and
+// version.
+func featuresOf(flavor binlog.Flavor, version int) Features {
+	var f Features
+	if flavor == binlog.FlavorMariaDB {
+		return f
+	}
+	for _, fv := range featureVersions {
+		if version >= fv.version {
+			f |= fv.feature
+		}
+	}
+	return f
+}
+
+// Has returns true if all given features are in the set.
+func (f Features) Has(feature Features) bool {
+	return f&feature == feature
+}
+
+// Names returns names of the features in the set.
+func (f Features) Names() []string {
+	var names []string
+	for _, fv := range featureVersions {
+		if f.Has(fv.feature) {
+			names = append(names, fv.name)
+		}
+	}
+	return names
+}
+
+func (f Features) String() string {
+	return strings.Join(f.Names(), ",")
+}
@@ -0,0 +1,21 @@
+package driver
+
+import "testing"
+
+func TestFeaturesOf(t *testing.T) {
+	tests := []struct {
+		version string
+		exp     string
+	}{
+		{"5.7.19-log", ""},
+		{"8.0.21", "partial_json,transaction_compression"},
+		{"8.0.36", "partial_json,transaction_compression,replica_commands,source_variables,heartbeat_v2"},
+		{"8.4.0", "partial_json,transaction_compression,replica_commands,source_variables,heartbeat_v2,binary_log_status,tagged_gtids"},
+		{"5.5.5-10.11.6-MariaDB", ""},
+	}
+	for _, test := range tests {
+		if f := FeaturesOf(test.version); f.String() != test.exp {
+			t.Errorf("Expected features %q for %s, got %q", test.exp, test.version, f)
+		}
+	}
+}
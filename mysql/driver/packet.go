@@ -0,0 +1,165 @@
+package driver
+
+import (
+	"net"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Packets documentation:
+// https://dev.mysql.com/doc/internals/en/mysql-packet.html
+
+const (
+	// maxPacketSize is the largest payload of a single packet, larger payloads
+	// are split into multiple packets.
+	maxPacketSize = 1<<24 - 1
+	// defaultBufSize is the default size of the read buffer.
+	defaultBufSize = 16 * 1024
+)
+
+var (
+	// ErrMalformedPacket is returned when a packet can't be parsed.
+	ErrMalformedPacket = errors.New("Malformed packet")
+	// ErrPacketSequence is returned when a packet arrives out of order.
+	ErrPacketSequence = errors.New("Packet out of sequence")
+)
+
+// packetConn reads and writes protocol packets. Partially received packets are
+// kept in the buffer when a read times out so that reading could be resumed.
+type packetConn struct {
+	nc  net.Conn
+	seq uint8
+
+	// buf[start:end] contains data received but not yet consumed
+	buf   []byte
+	start int
+	end   int
+	// bufSize is the configured size of the buffer, it grows temporarily to
+	// fit larger packets
+	bufSize int
+	// partial contains payload of a split packet received so far
+	partial []byte
+
+	writeTimeout time.Duration
+}
+
+func newPacketConn(nc net.Conn, bufSize int) *packetConn {
+	if bufSize <= 0 {
+		bufSize = defaultBufSize
+	}
+	return &packetConn{nc: nc, buf: make([]byte, bufSize), bufSize: bufSize}
+}
+
+// readPacket reads next packet payload. Payloads of packets that are split
+// because of their size are joined. Returned slice is only valid until the
+// next read.
+func (c *packetConn) readPacket() ([]byte, error) {
+	c.shrink()
+	for {
+		hdr, err := c.peek(4)
+		if err != nil {
+			return nil, err
+		}
+		size := int(uint32(hdr[0]) | uint32(hdr[1])<<8 | uint32(hdr[2])<<16)
+		if hdr[3] != c.seq {
+			return nil, ErrPacketSequence
+		}
+		pkt, err := c.peek(4 + size)
+		if err != nil {
+			return nil, err
+		}
+		c.start += 4 + size
+		c.seq++
+
+		payload := pkt[4:]
+		if size < maxPacketSize {
+			if c.partial == nil {
+				return payload, nil
+			}
+			payload = append(c.partial, payload...)
+			c.partial = nil
+			return payload, nil
+		}
+		c.partial = append(c.partial, payload...)
+	}
+}
+
+// peek makes sure the buffer contains at least n unread bytes and returns them
+// without consuming.
+func (c *packetConn) peek(n int) ([]byte, error) {
+	for c.end-c.start < n {
+		if len(c.buf)-c.start < n {
+			buf := c.buf
+			if len(buf) < n {
+				// Round up to the next multiple of the buffer size
+				buf = make([]byte, (n/c.bufSize+1)*c.bufSize)
+			}
+			c.end = copy(buf, c.buf[c.start:c.end])
+			c.start = 0
+			c.buf = buf
+		}
+
+		nn, err := c.nc.Read(c.buf[c.end:])
+		c.end += nn
+		if err != nil && c.end-c.start < n {
+			return nil, err
+		}
+	}
+	return c.buf[c.start : c.start+n], nil
+}
+
+// shrink returns the buffer to its configured size once it's no longer needed
+// to fit a large packet.
+func (c *packetConn) shrink() {
+	if len(c.buf) <= c.bufSize || c.partial != nil || c.end-c.start > c.bufSize {
+		return
+	}
+	buf := make([]byte, c.bufSize)
+	c.end = copy(buf, c.buf[c.start:c.end])
+	c.start = 0
+	c.buf = buf
+}
+
+// writePacket writes a packet. First 4 bytes of data are reserved for the
+// packet header, see buffer.NewCommandBuffer. Large payloads are split into
+// multiple packets.
+func (c *packetConn) writePacket(data []byte) error {
+	if c.writeTimeout > 0 {
+		if err := c.nc.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return err
+		}
+	}
+
+	payload := data[4:]
+	for {
+		n := len(payload)
+		if n > maxPacketSize {
+			n = maxPacketSize
+		}
+		hdr := []byte{byte(n), byte(n >> 8), byte(n >> 16), c.seq}
+		bufs := net.Buffers{hdr, payload[:n]}
+		if _, err := bufs.WriteTo(c.nc); err != nil {
+			return err
+		}
+		c.seq++
+		payload = payload[n:]
+		// A payload of exactly maximum size is followed by an empty packet
+		if n < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// writeCommand starts a new command by resetting the sequence and writes the
+// command packet.
+func (c *packetConn) writeCommand(data []byte) error {
+	c.seq = 0
+	return c.writePacket(data)
+}
+
+// setReadDeadline sets the deadline for the next read. Zero value means no
+// deadline.
+func (c *packetConn) setReadDeadline(t time.Time) error {
+	return c.nc.SetReadDeadline(t)
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Platform is the kind of deployment the server runs on. Managed platforms
+// restrict access to some variables and commands and manage binary log
+// retention on their own.
+type Platform string
+
+const (
+	// PlatformSelfManaged is a server that is not run by a cloud provider, or
+	// one that could not be recognized.
+	PlatformSelfManaged Platform = "self-managed"
+	// PlatformRDS is Amazon RDS for MySQL or MariaDB.
+	PlatformRDS Platform = "RDS"
+	// PlatformAurora is Amazon Aurora MySQL.
+	PlatformAurora Platform = "Aurora"
+)
+
+// Managed returns true for platforms run by a cloud provider.
+func (p Platform) Managed() bool {
+	return p == PlatformRDS || p == PlatformAurora
+}
+
+// errUnknownSystemVariable is the code of the error returned when a system
+// variable doesn't exist.
+const errUnknownSystemVariable = 1193
+
+// Platform detects the platform the server runs on. Aurora reports versions
+// such as 8.0.mysql_aurora.3.02.0 and has the aurora_version variable, RDS
+// installs servers into /rdsdbbin.
+func (c *Conn) Platform(ctx context.Context) (Platform, error) {
+	if strings.Contains(c.conn.serverVersion, "mysql_aurora") {
+		return PlatformAurora, nil
+	}
+	vars, err := c.GetVarsContext(ctx, "aurora_version", "basedir")
+	if err != nil {
+		return "", err
+	}
+	return detectPlatform(c.conn.serverVersion, vars), nil
+}
+
+func detectPlatform(version string, vars map[string]string) Platform {
+	if _, ok := vars["aurora_version"]; ok || strings.Contains(version, "mysql_aurora") {
+		return PlatformAurora
+	}
+	if strings.HasPrefix(vars["basedir"], "/rdsdbbin/") {
+		return PlatformRDS
+	}
+	return PlatformSelfManaged
+}
+
+// BinlogRetention returns the period binary logs are kept for. On RDS and
+// Aurora it's the "binlog retention hours" setting, zero means binary logs are
+// purged as soon as possible, which could happen before they are read. On
+// other servers it's binlog_expire_logs_seconds or expire_logs_days, zero
+// means binary logs are never purged automatically.
+func (c *Conn) BinlogRetention(ctx context.Context, p Platform) (time.Duration, error) {
+	if p.Managed() {
+		row, err := c.QueryRow(ctx, "SELECT value FROM mysql.rds_configuration WHERE name = 'binlog retention hours'")
+		if err == ErrNoRows {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return parseRetention(row[0], time.Hour), nil
+	}
+	vars, err := c.GetVarsContext(ctx, "binlog_expire_logs_seconds", "expire_logs_days")
+	if err != nil {
+		return 0, err
+	}
+	if d := parseRetention(vars["binlog_expire_logs_seconds"], time.Second); d > 0 {
+		return d, nil
+	}
+	return parseRetention(vars["expire_logs_days"], 24*time.Hour), nil
+}
+
+// parseRetention parses a number of units, empty and NULL values are zero.
+func parseRetention(val string, unit time.Duration) time.Duration {
+	n, err := strconv.ParseFloat(val, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n * float64(unit))
+}
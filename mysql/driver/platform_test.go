@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		version string
+		vars    map[string]string
+		exp     Platform
+	}{
+		{"8.0.mysql_aurora.3.02.0", map[string]string{}, PlatformAurora},
+		{"5.7.12-log", map[string]string{"aurora_version": "2.11.2"}, PlatformAurora},
+		{"8.0.28", map[string]string{"basedir": "/rdsdbbin/mysql-8.0.28.R4/"}, PlatformRDS},
+		{"8.0.28", map[string]string{"basedir": "/usr/"}, PlatformSelfManaged},
+	}
+	for _, test := range tests {
+		if p := detectPlatform(test.version, test.vars); p != test.exp {
+			t.Errorf("Expected %s for %s %v, got %s", test.exp, test.version, test.vars, p)
+		}
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		val  string
+		unit time.Duration
+		exp  time.Duration
+	}{
+		{"24", time.Hour, 24 * time.Hour},
+		{"2592000", time.Second, 720 * time.Hour},
+		{"0.5", 24 * time.Hour, 12 * time.Hour},
+		{"", time.Hour, 0},
+		{"NULL", time.Hour, 0},
+	}
+	for _, test := range tests {
+		if d := parseRetention(test.val, test.unit); d != test.exp {
+			t.Errorf("Expected %s for %q, got %s", test.exp, test.val, d)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"errors"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// ServerInfo contains details about the server reported during the handshake.
+type ServerInfo struct {
+	// Flavor is the kind of the server, MySQL or MariaDB.
+	Flavor binlog.Flavor
+	// Version is the version string reported by the server.
+	Version string
+	// VersionNumber is the version represented as a number, e.g. 50719 for
+	// 5.7.19-log.
+	VersionNumber int
+	// Capabilities is a set of capability flags supported by the server.
+	// Spec: https://dev.mysql.com/doc/internals/en/capability-flags.html
+	Capabilities uint32
+	// ConnectionID is the identifier of the server thread serving the
+	// connection.
+	ConnectionID uint32
+	// Features is the set of post 8.0 changes supported by the server.
+	Features Features
+}
+
+// mariadbSlaveCapabilityGTID makes MariaDB send GTID events instead of
+// rewriting them into BEGIN queries.
+const mariadbSlaveCapabilityGTID = "4"
+
+// ErrGTIDDumpUnsupported is returned when a GTID based binlog dump is requested
+// from a server that doesn't support MySQL GTIDs.
+var ErrGTIDDumpUnsupported = errors.New("Server doesn't support GTID based binlog dump")
+
+// ServerInfo returns details about the server.
+func (c *Conn) ServerInfo() ServerInfo {
+	sd := binlog.ParseServerDetails(c.conn.serverVersion)
+	return ServerInfo{
+		Flavor:        sd.Flavor,
+		Version:       c.conn.serverVersion,
+		VersionNumber: sd.Version,
+		Capabilities:  c.conn.serverCapabilities,
+		ConnectionID:  c.conn.connectionID,
+		Features:      featuresOf(sd.Flavor, sd.Version),
+	}
+}
+
+// IsMariaDB returns true if the server is MariaDB.
+func (si ServerInfo) IsMariaDB() bool {
+	return si.Flavor == binlog.FlavorMariaDB
+}
@@ -0,0 +1,113 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// MasterStatus describes the current state of the binary log on the server.
+type MasterStatus struct {
+	// Position is the position the next event will be written at.
+	Position binlog.Position
+	// ExecutedGTIDSet contains all transactions written to the binary log. It
+	// is empty on servers without GTIDs.
+	ExecutedGTIDSet binlog.GTIDSet
+}
+
+// ReplicaHost describes a replica registered with the server.
+type ReplicaHost struct {
+	ServerID uint32
+	Host     string
+	Port     uint16
+	MasterID uint32
+	// UUID is the server UUID of the replica, it is only reported by MySQL.
+	UUID string
+}
+
+// MasterStatus returns the current binary log position of the server.
+func (c *Conn) MasterStatus(ctx context.Context) (*MasterStatus, error) {
+	q := "SHOW MASTER STATUS"
+	if c.ServerInfo().Features.Has(FeatureBinaryLogStatus) {
+		q = "SHOW BINARY LOG STATUS"
+	}
+	res, err := c.conn.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.rows) == 0 {
+		return nil, fmt.Errorf("binary logging is disabled")
+	}
+
+	row := res.row(0)
+	ms := &MasterStatus{Position: binlog.Position{File: row["File"]}}
+	if ms.Position.Offset, err = strconv.ParseUint(row["Position"], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid binary log position: %q", row["Position"])
+	}
+	if ms.ExecutedGTIDSet, err = binlog.ParseGTIDSet(row["Executed_Gtid_Set"]); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// ReplicaHosts returns the list of replicas registered with the server.
+// Replicas only appear in the list if they were started with the
+// report_host option or registered with RegisterSlave.
+func (c *Conn) ReplicaHosts(ctx context.Context) ([]ReplicaHost, error) {
+	q := "SHOW SLAVE HOSTS"
+	if c.ServerInfo().Features.Has(FeatureReplicaCommands) {
+		q = "SHOW REPLICAS"
+	}
+	res, err := c.conn.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]ReplicaHost, 0, len(res.rows))
+	for i := range res.rows {
+		row := res.row(i)
+		serverID, err := strconv.ParseUint(row["Server_id"], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server ID: %q", row["Server_id"])
+		}
+		masterID, err := strconv.ParseUint(firstOf(row, "Source_id", "Master_id"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master ID: %q", firstOf(row, "Source_id", "Master_id"))
+		}
+		// Port is reported as zero when unknown
+		port, _ := strconv.ParseUint(row["Port"], 10, 16)
+		hosts = append(hosts, ReplicaHost{
+			ServerID: uint32(serverID),
+			Host:     row["Host"],
+			Port:     uint16(port),
+			MasterID: uint32(masterID),
+			UUID:     firstOf(row, "Replica_UUID", "Slave_UUID"),
+		})
+	}
+	return hosts, nil
+}
+
+// row returns the i-th row of the result as a map of column names to values.
+// NULL values are returned as empty strings.
+func (r *result) row(i int) map[string]string {
+	row := make(map[string]string, len(r.columns))
+	for j, col := range r.columns {
+		if j < len(r.rows[i]) {
+			row[col] = r.rows[i][j].String
+		}
+	}
+	return row
+}
+
+// firstOf returns the value of the first column present in the row. Column
+// names differ between server versions.
+func firstOf(row map[string]string, cols ...string) string {
+	for _, col := range cols {
+		if v, ok := row[col]; ok {
+			return v
+		}
+	}
+	return ""
+}
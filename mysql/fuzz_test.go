@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"testing"
+)
+
+// Decoders must return errors or zero values for arbitrary input, never
+// panic. Seed corpora run as part of regular tests, run with -fuzz to explore
+// further.
+
+func FuzzDecodeJSON(f *testing.F) {
+	f.Add([]byte{0x00, 0x01, 0x00, 0x0e, 0x00, 0x0b, 0x00, 0x01, 0x00, 0x05, 0x02, 0x00, 'i', 'd'})
+	f.Add([]byte{0x0c, 0x03, 'f', 'o', 'o'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		DecodeJSON(data)
+	})
+}
+
+func FuzzDecodeDecimal(f *testing.F) {
+	f.Add([]byte{127, 255, 132, 229, 45, 139, 127, 255, 255, 255, 255, 255, 255, 255, 255, 20, 0}, 30, 25)
+	f.Fuzz(func(t *testing.T, data []byte, precision, decimals int) {
+		DecodeDecimal(data, precision, decimals)
+		AppendDecimal(nil, data, precision, decimals)
+	})
+}
+
+func FuzzDecodeBit(f *testing.F) {
+	f.Add([]byte{0x01, 0x02}, 12, 2)
+	f.Fuzz(func(t *testing.T, data []byte, nbits, length int) {
+		DecodeBit(data, nbits, length)
+	})
+}
+
+func FuzzDecodeTemporal(f *testing.F) {
+	f.Add([]byte{0x80, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00}, uint16(3))
+	f.Fuzz(func(t *testing.T, data []byte, dec uint16) {
+		DecodeTime2(data, dec)
+		DecodeTimestamp2(data, dec)
+		DecodeDatetime2(data, dec)
+	})
+}
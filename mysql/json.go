@@ -36,6 +36,10 @@ const (
 
 	jsonbValueEntrySizeSmall = 1 + jsonbSmallOffsetSize
 	jsonbValueEntrySizeLarge = 1 + jsonbLargeOffsetSize
+
+	// jsonMaxDepth is the maximum nesting depth of JSON documents allowed by
+	// the server.
+	jsonMaxDepth = 100
 )
 
 // DecodeJSON decodes JSON into raw bytes.
@@ -81,6 +85,7 @@ func jsonbGetValueEntrySize(isSmall bool) int {
 
 type jsonBinaryDecoder struct {
 	useDecimal bool
+	depth      int
 	err        error
 }
 
@@ -137,6 +142,14 @@ func (d *jsonBinaryDecoder) decodeObjectOrArray(data []byte, isSmall bool, isObj
 	if d.isDataShort(data, int(size)) {
 		return nil
 	}
+	data = data[:size]
+
+	if d.depth >= jsonMaxDepth {
+		d.err = errors.Errorf("document is nested deeper than %d levels", jsonMaxDepth)
+		return nil
+	}
+	d.depth++
+	defer func() { d.depth-- }()
 
 	keyEntrySize := jsonbGetKeyEntrySize(isSmall)
 	valueEntrySize := jsonbGetValueEntrySize(isSmall)
@@ -196,6 +209,12 @@ func (d *jsonBinaryDecoder) decodeObjectOrArray(data []byte, isSmall bool, isObj
 
 		valueOffset := d.decodeCount(data[entryOffset+1:], isSmall)
 
+		// Value must start after value entry
+		if valueOffset < headerSize {
+			d.err = errors.Errorf("invalid value offset %d, must > %d", valueOffset, headerSize)
+			return nil
+		}
+
 		if d.isDataShort(data, valueOffset) {
 			return nil
 		}
@@ -374,6 +393,9 @@ func (d *jsonBinaryDecoder) decodeOpaque(data []byte) interface{} {
 }
 
 func (d *jsonBinaryDecoder) decodeDecimal(data []byte) interface{} {
+	if d.isDataShort(data, 2) {
+		return nil
+	}
 	precision := int(data[0])
 	scale := int(data[1])
 
@@ -44,6 +44,9 @@ func DecodeTime2(data []byte, dec uint16) (string, int) {
 	const intOffset int64 = 0x800000
 	// time  binary length
 	n := int(3 + (dec+1)/2)
+	if len(data) < n {
+		return "00:00:00", n
+	}
 
 	tmp := int64(0)
 	intPart := int64(0)
@@ -107,6 +110,9 @@ func DecodeTime2(data []byte, dec uint16) (string, int) {
 // Spec: https://dev.mysql.com/doc/refman/8.0/en/datetime.html
 // Implementation borrowed from https://github.com/siddontang/go-mysql/
 func DecodeTimestamp(data []byte, dec uint16) (time.Time, int) {
+	if len(data) < 4 {
+		return time.Time{}, 4
+	}
 	return time.Unix(int64(DecodeUint32(data)), 0), 4
 }
 
@@ -116,6 +122,9 @@ func DecodeTimestamp(data []byte, dec uint16) (time.Time, int) {
 func DecodeTimestamp2(data []byte, dec uint16) (time.Time, int) {
 	// get timestamp binary length
 	n := int(4 + (dec+1)/2)
+	if len(data) < n {
+		return time.Time{}, n
+	}
 	sec := int64(binary.BigEndian.Uint32(data[0:4]))
 	usec := int64(0)
 	switch dec {
@@ -157,6 +166,9 @@ func DecodeDatetime2(data []byte, dec uint16) (time.Time, int) {
 	const offset int64 = 0x8000000000
 	// get datetime binary length
 	n := int(5 + (dec+1)/2)
+	if len(data) < n {
+		return time.Time{}, n
+	}
 
 	intPart := int64(DecodeVarLen64BigEndian(data[0:5])) - offset
 	var frac int64
@@ -0,0 +1,103 @@
+package reader
+
+import (
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// UnsupportedEvent describes an event that is passed through as is although
+// it could contain changes or affect transaction boundaries. Such events
+// usually appear after the server is upgraded or its configuration changes.
+type UnsupportedEvent struct {
+	Type binlog.EventType
+	// Position is the position the event starts at.
+	Position binlog.Position
+	// Reason explains what is missed and how to avoid it.
+	Reason string
+}
+
+// ErrUnsupportedEvent is returned by StrictEventAudit.
+var ErrUnsupportedEvent = errors.New("Unsupported event")
+
+// unsupportedEvents contains known events the reader doesn't interpret that
+// could contain changes, with reasons reported by the audit.
+var unsupportedEvents = map[binlog.EventType]string{
+	binlog.EventTypeXAPrepare:          "XA transactions are not assembled",
+	binlog.EventTypePartialUpdateRows:  "partial JSON updates are not decoded, set binlog_row_value_options to an empty value",
+	binlog.EventTypeTransactionPayload: "compressed transactions are not decoded, disable binlog_transaction_compression",
+}
+
+// WithEventAudit sets a function that is called from ReadEvent the first time
+// an unsupported event of each type is received: a known event the reader
+// doesn't interpret, or an event of an unknown type not flagged as ignorable.
+// If the function returns an error reading fails with it, see
+// StrictEventAudit. The stream continues otherwise.
+func WithEventAudit(fn func(UnsupportedEvent) error) Option {
+	return func(r *Reader) {
+		r.audit = fn
+		r.audited = make(map[binlog.EventType]bool)
+	}
+}
+
+// StrictEventAudit fails reading on the first unsupported event. It could be
+// passed to WithEventAudit.
+func StrictEventAudit(e UnsupportedEvent) error {
+	return errors.Annotatef(ErrUnsupportedEvent, "%s at %s:%d, %s", e.Type, e.Position.File, e.Position.Offset, e.Reason)
+}
+
+// auditEvent reports the event if it's unsupported.
+func (r *Reader) auditEvent(evt *Event) error {
+	if r.audit == nil || r.audited[evt.Header.Type] {
+		return nil
+	}
+	reason, ok := unsupportedReason(evt.Header.Type)
+	if !ok || !evt.Header.Type.Known() && evt.Header.Flags&binlog.EventFlagIgnorable != 0 {
+		return nil
+	}
+	r.audited[evt.Header.Type] = true
+	return r.audit(UnsupportedEvent{
+		Type:     evt.Header.Type,
+		Position: binlog.Position{File: r.state.File, Offset: evt.Offset},
+		Reason:   reason,
+	})
+}
+
+func unsupportedReason(et binlog.EventType) (string, bool) {
+	if !et.Known() {
+		return "unknown event type", true
+	}
+	reason, ok := unsupportedEvents[et]
+	return reason, ok
+}
+
+// CompatibilityReport describes how well the reader supports the server it
+// reads from. It's meant to be checked after server upgrades.
+type CompatibilityReport struct {
+	// Server contains details about the server, it's empty when reading
+	// from other sources.
+	Server driver.ServerInfo
+	// Features lists post 8.0 changes supported by the server, see
+	// driver.Features.
+	Features []string
+	// UnsupportedEvents contains the number of unsupported events received,
+	// by type. Events of unknown types are counted even when flagged as
+	// ignorable.
+	UnsupportedEvents map[binlog.EventType]uint64
+}
+
+// Compatibility returns a compatibility report of the reader. It is safe to
+// call concurrently with ReadEvent.
+func (r *Reader) Compatibility() CompatibilityReport {
+	rep := CompatibilityReport{UnsupportedEvents: make(map[binlog.EventType]uint64)}
+	if r.conn != nil {
+		rep.Server = r.conn.ServerInfo()
+		rep.Features = rep.Server.Features.Names()
+	}
+	for et, n := range r.stats.snapshot().Events {
+		if _, ok := unsupportedReason(et); ok {
+			rep.UnsupportedEvents[et] = n
+		}
+	}
+	return rep
+}
@@ -0,0 +1,45 @@
+package reader
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+func TestEventAudit(t *testing.T) {
+	var reported []UnsupportedEvent
+	r := &Reader{}
+	WithEventAudit(func(e UnsupportedEvent) error {
+		reported = append(reported, e)
+		return nil
+	})(r)
+	r.state.File = "mysql-bin.000001"
+
+	for i, h := range []binlog.EventHeader{
+		{Type: binlog.EventTypeQuery},
+		{Type: binlog.EventTypeTransactionPayload},
+		{Type: binlog.EventTypeTransactionPayload},
+		{Type: binlog.EventType(99), Flags: binlog.EventFlagIgnorable},
+		{Type: binlog.EventType(98)},
+	} {
+		if err := r.auditEvent(&Event{Header: h, Offset: uint64(i)}); err != nil {
+			t.Fatalf("Unexpected audit error: %v", err)
+		}
+	}
+	if len(reported) != 2 {
+		t.Fatalf("Expected 2 reported events, got %v", reported)
+	}
+	if e := reported[0]; e.Type != binlog.EventTypeTransactionPayload || e.Position.Offset != 1 || e.Position.File != "mysql-bin.000001" {
+		t.Errorf("Unexpected report %+v", e)
+	}
+	if e := reported[1]; e.Type != binlog.EventType(98) || e.Reason != "unknown event type" {
+		t.Errorf("Unexpected report %+v", e)
+	}
+
+	WithEventAudit(StrictEventAudit)(r)
+	err := r.auditEvent(&Event{Header: binlog.EventHeader{Type: binlog.EventTypeXAPrepare}})
+	if errors.Cause(err) != ErrUnsupportedEvent {
+		t.Errorf("Expected unsupported event error, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package reader
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// ReadBatch reads up to maxEvents events from the binary log. It returns once
+// the batch is full or when maxWait has elapsed since the call, whichever
+// happens first. An empty batch is returned if no events were received in
+// time. If the parent context is done or reading fails, events read so far are
+// returned along with the error.
+func (r *Reader) ReadBatch(ctx context.Context, maxEvents int, maxWait time.Duration) ([]*Event, error) {
+	wctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	evts := make([]*Event, 0, maxEvents)
+	for len(evts) < maxEvents {
+		evt, err := r.ReadEvent(wctx)
+		if err != nil {
+			if ctx.Err() == nil && isTimeout(err) {
+				// Wait time has elapsed
+				return evts, nil
+			}
+			return evts, err
+		}
+		// Connection buffer is reused on the next read
+		evt.detach()
+		evts = append(evts, evt)
+	}
+	return evts, nil
+}
+
+func isTimeout(err error) bool {
+	err = errors.Cause(err)
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
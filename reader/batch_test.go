@@ -0,0 +1,85 @@
+package reader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+)
+
+func TestReadBatch(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	srv.Append(g.Position().File, g.XID(1), g.XID(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	// Artificial rotate, format description and the first transaction
+	evts, err := r.ReadBatch(ctx, 3, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read batch: %v", err)
+	}
+	if len(evts) != 3 || evts[2].Header.Type != binlog.EventTypeXID {
+		t.Fatalf("Expected a full batch ending with a transaction, got %d events", len(evts))
+	}
+
+	// Wait time elapses before the batch is full
+	start := time.Now()
+	evts, err = r.ReadBatch(ctx, 10, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected a partial batch, got %v", err)
+	}
+	if len(evts) != 1 || evts[0].Header.Type != binlog.EventTypeXID {
+		t.Errorf("Expected a partial batch of one transaction, got %d events", len(evts))
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("Expected batch to wait, returned after %v", d)
+	}
+	// Detached events remain valid
+	var xe binlog.XIDEvent
+	if err := xe.Decode(evts[0].Buffer); err != nil || xe.XID != 2 {
+		t.Errorf("Expected transaction 2, got %d: %v", xe.XID, err)
+	}
+
+	// Parent context errors are returned
+	cctx, ccancel := context.WithCancel(ctx)
+	ccancel()
+	if _, err := r.ReadBatch(cctx, 10, time.Second); err == nil {
+		t.Error("Expected cancelled batch to fail")
+	}
+}
+
+func TestReadBatchCompressed(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	srv.Append(g.Position().File, g.MariaDBQueryCompressed("shop", "BEGIN"), g.XID(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	// Artificial rotate, format description and the transaction
+	evts, err := r.ReadBatch(ctx, 4, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read batch: %v", err)
+	}
+	if len(evts) != 4 || evts[2].Header.Type != binlog.EventTypeQuery {
+		t.Fatalf("Expected a decompressed query event, got %d events", len(evts))
+	}
+	var qe binlog.QueryEvent
+	if err := qe.Decode(evts[2].Buffer); err != nil || string(qe.Query) != "BEGIN" {
+		t.Errorf("Expected query BEGIN, got %q: %v", qe.Query, err)
+	}
+}
@@ -0,0 +1,186 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// Captures contain events exactly as they were received along with the
+// position each event was read at and the time it was received, so that a
+// stream could be replayed through the reader later. A capture starts with a
+// magic header followed by records:
+//
+//	flags       1 byte, bit 0 is set if the file name follows
+//	file        uvarint length and bytes, only when the file changes
+//	offset      uvarint
+//	received    varint nanoseconds since the previous record
+//	event       uvarint length and bytes
+var captureMagic = []byte{'B', 'C', 'A', 'P', 1}
+
+const captureFlagFile = 0x01
+
+var (
+	// ErrInvalidCapture is returned when replaying something other than a
+	// capture.
+	ErrInvalidCapture = errors.New("Not a capture")
+	// ErrReplaySeek is returned when a replay reader, or a reader of any
+	// other event source, is asked to seek or reconnect.
+	ErrReplaySeek = errors.New("Seeking is not supported when replaying")
+)
+
+// WithCapture makes the reader record every event it receives to w. Captures
+// could be replayed with NewReplay. Writes are not buffered.
+func WithCapture(w io.Writer) Option {
+	return func(r *Reader) {
+		r.capture = &captureWriter{w: w}
+	}
+}
+
+type captureWriter struct {
+	w       io.Writer
+	started bool
+	file    string
+	last    int64
+	buf     []byte
+}
+
+func (c *captureWriter) record(pos binlog.Position, evt []byte, at time.Time) error {
+	b := c.buf[:0]
+	if !c.started {
+		b = append(b, captureMagic...)
+		c.started = true
+	}
+	if pos.File != c.file || len(b) > 0 {
+		b = append(b, captureFlagFile)
+		b = appendUvarint(b, uint64(len(pos.File)))
+		b = append(b, pos.File...)
+		c.file = pos.File
+	} else {
+		b = append(b, 0)
+	}
+	b = appendUvarint(b, pos.Offset)
+	ns := at.UnixNano()
+	b = appendVarint(b, ns-c.last)
+	c.last = ns
+	b = appendUvarint(b, uint64(len(evt)))
+	b = append(b, evt...)
+	c.buf = b
+	_, err := c.w.Write(b)
+	return err
+}
+
+// NewReplay creates a reader that replays events from a capture made with
+// WithCapture. Events are decoded just like they were when received from the
+// server. ErrEndOfLog is returned once all events are replayed. Options that
+// require a server connection have no effect, seeking is not supported.
+func NewReplay(rd io.Reader, opts ...Option) (*Reader, error) {
+	src := &replaySource{rd: bufio.NewReader(rd)}
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(src.rd, magic); err != nil || !bytes.Equal(magic, captureMagic) {
+		return nil, ErrInvalidCapture
+	}
+	// First record contains the starting position
+	if err := src.next(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return NewSource(src, src.pos, opts...)
+}
+
+// replaySource reads events from a capture. It reads one record ahead.
+type replaySource struct {
+	rd *bufio.Reader
+	// pos is the position of the record read ahead, cur is the position
+	// of the last returned event
+	pos  binlog.Position
+	cur  binlog.Position
+	evt  []byte
+	eof  bool
+	last int64
+}
+
+// next reads the next record.
+func (s *replaySource) next() error {
+	flags, err := s.rd.ReadByte()
+	if err == io.EOF {
+		s.eof = true
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if flags&captureFlagFile != 0 {
+		n, err := binary.ReadUvarint(s.rd)
+		if err != nil {
+			return ErrInvalidCapture
+		}
+		file := make([]byte, n)
+		if _, err := io.ReadFull(s.rd, file); err != nil {
+			return ErrInvalidCapture
+		}
+		s.pos.File = string(file)
+	}
+	if s.pos.Offset, err = binary.ReadUvarint(s.rd); err != nil {
+		return ErrInvalidCapture
+	}
+	delta, err := binary.ReadVarint(s.rd)
+	if err != nil {
+		return ErrInvalidCapture
+	}
+	s.last += delta
+	n, err := binary.ReadUvarint(s.rd)
+	if err != nil {
+		return ErrInvalidCapture
+	}
+	if uint64(cap(s.evt)) < n {
+		s.evt = make([]byte, n)
+	}
+	s.evt = s.evt[:n]
+	if _, err := io.ReadFull(s.rd, s.evt); err != nil {
+		return ErrInvalidCapture
+	}
+	return nil
+}
+
+// ReadEvent returns the event read ahead.
+func (s *replaySource) ReadEvent(ctx context.Context) ([]byte, error) {
+	if s.eof {
+		return nil, nil
+	}
+	evt := append([]byte(nil), s.evt...)
+	s.cur = s.pos
+	if err := s.next(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// EventPosition returns the position the last returned event was read at.
+// Captured streams may contain events received again after reconnecting.
+func (s *replaySource) EventPosition() binlog.Position {
+	return s.cur
+}
+
+// Close does nothing, the capture is closed by the caller.
+func (s *replaySource) Close() error {
+	return nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,64 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+func TestCaptureReplay(t *testing.T) {
+	path, offsets := writeTestFile(t)
+
+	readAll := func(r *Reader) (offs []uint64, types []binlog.EventType) {
+		for {
+			evt, err := r.ReadEvent(context.Background())
+			if err == ErrEndOfLog {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to read event: %v", err)
+			}
+			offs = append(offs, evt.Offset)
+			types = append(types, evt.Header.Type)
+		}
+	}
+
+	var buf bytes.Buffer
+	r, err := NewFile(path, 0, WithCapture(&buf))
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	expOffsets, expTypes := readAll(r)
+	r.Close(context.Background())
+	if len(expOffsets) != len(offsets) {
+		t.Fatalf("Expected %d events, got %d", len(offsets), len(expOffsets))
+	}
+
+	r, err = NewReplay(&buf)
+	if err != nil {
+		t.Fatalf("Failed to replay capture: %v", err)
+	}
+	defer r.Close(context.Background())
+	if r.State().File != "mysql-bin.000001" {
+		t.Errorf("Unexpected starting position %v", r.State())
+	}
+	offs, types := readAll(r)
+	if len(offs) != len(expOffsets) {
+		t.Fatalf("Expected %d replayed events, got %d", len(expOffsets), len(offs))
+	}
+	for i := range offs {
+		if offs[i] != expOffsets[i] || types[i] != expTypes[i] {
+			t.Errorf("Expected event %s at %d, got %s at %d",
+				expTypes[i].String(), expOffsets[i], types[i].String(), offs[i])
+		}
+	}
+	if err := r.Seek(binlog.Position{File: "mysql-bin.000001", Offset: 4}); err == nil {
+		t.Error("Expected seeking to fail")
+	}
+
+	if _, err := NewReplay(bytes.NewReader([]byte("PAR1"))); err != ErrInvalidCapture {
+		t.Errorf("Expected invalid capture error, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package reader
+
+import (
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// catchUp detects the transition from replaying historical events to
+// streaming live events.
+type catchUp struct {
+	threshold time.Duration
+	onLive    func()
+	live      bool
+}
+
+// WithCatchUp enables detection of the moment the reader catches up with the
+// master. Reader is considered caught up once it receives an event that is
+// less than threshold old or a heartbeat event, which the master only sends
+// when there are no more events to send. Events received from then on have
+// the Live flag set and the onLive callback, if not nil, is called once. The
+// reader falls back to catching up after Seek or SeekGTID.
+func WithCatchUp(threshold time.Duration, onLive func()) Option {
+	return func(r *Reader) {
+		r.catchUp = &catchUp{threshold: threshold, onLive: onLive}
+	}
+}
+
+// Live returns true once the reader has caught up with the master. It is
+// always false unless catch up detection is enabled with WithCatchUp.
+func (r *Reader) Live() bool {
+	return r.catchUp != nil && r.catchUp.live
+}
+
+func (c *catchUp) check(h binlog.EventHeader) bool {
+	if c.live {
+		return true
+	}
+	switch {
+	case h.Type == binlog.EventTypeHeartbeet, h.Type == binlog.EventTypeHeartbeatV2:
+	case h.Timestamp > 0 && time.Since(time.Unix(int64(h.Timestamp), 0)) <= c.threshold:
+	default:
+		return false
+	}
+	c.live = true
+	if c.onLive != nil {
+		c.onLive()
+	}
+	return true
+}
+
+func (r *Reader) resetCatchUp() {
+	if r.catchUp != nil {
+		r.catchUp.live = false
+	}
+}
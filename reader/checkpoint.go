@@ -0,0 +1,125 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// CheckpointStore persists binary log positions so that processing could be
+// resumed after a restart.
+type CheckpointStore interface {
+	// Load returns the last saved position. If nothing was saved yet ok is
+	// false.
+	Load(ctx context.Context) (pos binlog.Position, ok bool, err error)
+	// Save persists the given position.
+	Save(ctx context.Context, pos binlog.Position) error
+}
+
+// WithCheckpointStore makes the reader resume from the position loaded from
+// the given store and save positions passed to Checkpoint to the store when
+// Flush or Close is called.
+func WithCheckpointStore(s CheckpointStore) Option {
+	return func(r *Reader) {
+		r.checkpoints = &checkpointer{store: s}
+	}
+}
+
+// checkpointer keeps track of a pending checkpoint. It is safe for concurrent
+// use.
+type checkpointer struct {
+	store   CheckpointStore
+	mu      sync.Mutex
+	pending *binlog.Position
+}
+
+func (c *checkpointer) set(pos binlog.Position) {
+	c.mu.Lock()
+	c.pending = &pos
+	c.mu.Unlock()
+}
+
+func (c *checkpointer) flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		return nil
+	}
+	if err := c.store.Save(ctx, *c.pending); err != nil {
+		return err
+	}
+	c.pending = nil
+	return nil
+}
+
+// FileCheckpointStore is a checkpoint store that keeps the position in a JSON
+// file. The file is replaced atomically on save.
+type FileCheckpointStore struct {
+	Path string
+}
+
+// Load reads the position from the file. A missing file is not an error.
+func (s FileCheckpointStore) Load(_ context.Context) (binlog.Position, bool, error) {
+	var pos binlog.Position
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return pos, false, nil
+	}
+	if err != nil {
+		return pos, false, err
+	}
+	if err := json.Unmarshal(b, &pos); err != nil {
+		return pos, false, err
+	}
+	return pos, true, nil
+}
+
+// Save writes the position to the file. The file and its directory are synced
+// so that the position survives a crash once Save returns.
+func (s FileCheckpointStore) Save(_ context.Context, pos binlog.Position) error {
+	b, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.Path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(s.Path))
+}
+
+// syncDir syncs the directory so that a file renamed into it is persisted.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,37 @@
+package reader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	s := FileCheckpointStore{Path: filepath.Join(dir, "pos.json")}
+	if _, ok, err := s.Load(ctx); err != nil || ok {
+		t.Fatalf("Expected no checkpoint, got ok=%t err=%v", ok, err)
+	}
+
+	exp := binlog.Position{File: "mysql-bin.000003", Offset: 1234}
+	if err := s.Save(ctx, exp); err != nil {
+		t.Fatal(err)
+	}
+	pos, ok, err := s.Load(ctx)
+	if err != nil || !ok {
+		t.Fatalf("Expected checkpoint, got ok=%t err=%v", ok, err)
+	}
+	if pos != exp {
+		t.Errorf("Expected position %v, got %v", exp, pos)
+	}
+}
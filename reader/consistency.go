@@ -0,0 +1,99 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// ErrCorruptStream is matched by errors.Is for every CorruptStreamError.
+var ErrCorruptStream = errors.New("Corrupt binary log stream")
+
+// Header fields checked for consistency.
+const (
+	FieldEventLength = "event length"
+	FieldNextOffset  = "next offset"
+)
+
+// CorruptStreamError is returned when an event header doesn't match the
+// stream: the declared length differs from the length of the data received or
+// is too short to hold the checksum, or the event doesn't start where the
+// previous one ended. It means that the
+// binary log file is truncated or the stream got out of sync, reading should
+// be restarted at the last known good position.
+type CorruptStreamError struct {
+	// Position is where the event starts according to the stream.
+	Position binlog.Position
+	Type     binlog.EventType
+	// Field is the header field that doesn't match, FieldEventLength or
+	// FieldNextOffset.
+	Field string
+	// Declared is the value of the field in the header, Actual is the value
+	// derived from the stream.
+	Declared uint64
+	Actual   uint64
+}
+
+func (e *CorruptStreamError) Error() string {
+	return fmt.Sprintf("Corrupt binary log stream: %s at %s:%d has %s %d, expected %d",
+		e.Type, e.Position.File, e.Position.Offset, e.Field, e.Declared, e.Actual)
+}
+
+// Is makes the error match ErrCorruptStream, and ErrTruncatedEvent if the
+// event is shorter than declared.
+func (e *CorruptStreamError) Is(target error) bool {
+	return target == ErrCorruptStream ||
+		target == ErrTruncatedEvent && e.Field == FieldEventLength && e.Actual < e.Declared
+}
+
+// checkConsistency validates the event header against the data received and
+// the position of the stream.
+func (r *Reader) checkConsistency(h binlog.EventHeader, size int) error {
+	if uint64(size) != uint64(h.EventLen) {
+		return &CorruptStreamError{
+			Position: r.state,
+			Type:     h.Type,
+			Field:    FieldEventLength,
+			Declared: uint64(h.EventLen),
+			Actual:   uint64(size),
+		}
+	}
+	minLen := uint32(r.format.HeaderLen())
+	if r.format.ServerDetails.ChecksumAlgorithm == binlog.ChecksumAlgorithmCRC32 {
+		minLen += 4
+	}
+	if h.EventLen < minLen {
+		return &CorruptStreamError{
+			Position: r.state,
+			Type:     h.Type,
+			Field:    FieldEventLength,
+			Declared: uint64(h.EventLen),
+			Actual:   uint64(minLen),
+		}
+	}
+	// Artificial events and heartbeats are not part of the binary log.
+	// Transactions skipped by GTID based dumps leave gaps in positions.
+	if h.NextOffset == 0 || h.Flags&binlog.EventFlagArtificial != 0 || r.gtidMode ||
+		h.Type == binlog.EventTypeHeartbeet || h.Type == binlog.EventTypeHeartbeatV2 {
+		return nil
+	}
+	// Positions are 32-bit, so they wrap in files larger than 4GB
+	start := h.NextOffset - h.EventLen
+	exp := uint32(r.state.Offset)
+	if start == exp {
+		return nil
+	}
+	// Servers remove checksums from events for replicas that don't accept
+	// them, but leave positions as is
+	if start == exp+4 && r.format.ServerDetails.ChecksumAlgorithm != binlog.ChecksumAlgorithmCRC32 {
+		return nil
+	}
+	return &CorruptStreamError{
+		Position: r.state,
+		Type:     h.Type,
+		Field:    FieldNextOffset,
+		Declared: uint64(h.NextOffset),
+		Actual:   r.state.Offset + uint64(h.EventLen),
+	}
+}
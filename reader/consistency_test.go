@@ -0,0 +1,50 @@
+package reader
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	r := &Reader{state: binlog.Position{File: "mysql-bin.000001", Offset: 120}}
+	r.format.ServerDetails.ChecksumAlgorithm = binlog.ChecksumAlgorithmCRC32
+
+	for _, c := range []struct {
+		h     binlog.EventHeader
+		size  int
+		field string
+	}{
+		{binlog.EventHeader{Type: binlog.EventTypeXID, EventLen: 31, NextOffset: 151}, 31, ""},
+		{binlog.EventHeader{Type: binlog.EventTypeXID, EventLen: 31, NextOffset: 151}, 20, FieldEventLength},
+		{binlog.EventHeader{Type: binlog.EventTypeXID, EventLen: 31, NextOffset: 155}, 31, FieldNextOffset},
+		{binlog.EventHeader{Type: binlog.EventTypeXID, EventLen: 27, NextOffset: 151}, 27, FieldNextOffset},
+		{binlog.EventHeader{Type: binlog.EventTypeXID, EventLen: 19, NextOffset: 139}, 19, FieldEventLength},
+		{binlog.EventHeader{Type: binlog.EventTypeRotate, EventLen: 43, Flags: binlog.EventFlagArtificial}, 43, ""},
+		{binlog.EventHeader{Type: binlog.EventTypeHeartbeet, EventLen: 39, NextOffset: 120}, 39, ""},
+	} {
+		err := r.checkConsistency(c.h, c.size)
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for %+v: %v", c.h, err)
+			}
+			continue
+		}
+		cerr, ok := errors.Cause(err).(*CorruptStreamError)
+		if !ok || cerr.Field != c.field || cerr.Position != r.state {
+			t.Errorf("Expected %s mismatch for %+v, got %v", c.field, c.h, err)
+		}
+	}
+
+	// Checksums stripped by the server shorten events but not positions
+	r.format.ServerDetails.ChecksumAlgorithm = binlog.ChecksumAlgorithmNone
+	if err := r.checkConsistency(binlog.EventHeader{Type: binlog.EventTypeXID, EventLen: 27, NextOffset: 151}, 27); err != nil {
+		t.Errorf("Unexpected error for stripped checksum: %v", err)
+	}
+
+	err := &CorruptStreamError{Field: FieldEventLength, Declared: 31, Actual: 20}
+	if !err.Is(ErrCorruptStream) || !err.Is(ErrTruncatedEvent) {
+		t.Errorf("Expected short event to match ErrCorruptStream and ErrTruncatedEvent")
+	}
+}
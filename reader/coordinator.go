@@ -0,0 +1,187 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// Checkpoint identifies the end of the last written transaction.
+type Checkpoint struct {
+	// Position is the position right after the transaction.
+	Position binlog.Position
+	// GTIDSet contains all transactions read so far, including the last one.
+	// It's empty when GTIDs are disabled.
+	GTIDSet binlog.GTIDSet
+}
+
+// Token returns a string that identifies the checkpoint. Sinks that can't
+// write atomically could store it alongside the output as an idempotency token
+// to recognize transactions written again after a crash.
+func (c Checkpoint) Token() string {
+	if len(c.GTIDSet) > 0 {
+		return c.GTIDSet.String()
+	}
+	return fmt.Sprintf("%s:%d", c.Position.File, c.Position.Offset)
+}
+
+// AtomicSink writes transactions together with checkpoints. A transaction and
+// the checkpoint that follows it must be written as one atomic unit, e.g. in
+// the same database transaction, so that after a crash processing resumes
+// right after the last written transaction.
+type AtomicSink interface {
+	// LoadCheckpoint returns the checkpoint written with the last
+	// transaction. If nothing was written yet ok is false.
+	LoadCheckpoint(ctx context.Context) (cp Checkpoint, ok bool, err error)
+	// WriteTransaction writes changes of a transaction and the checkpoint.
+	WriteTransaction(ctx context.Context, txn *Transaction, cp Checkpoint) error
+}
+
+// Coordinator passes transactions read from a reader to an atomic sink, which
+// delivers every transaction exactly once even if processing is interrupted
+// and restarted, unlike checkpoints saved separately from the output.
+type Coordinator struct {
+	reader *TransactionReader
+	sink   AtomicSink
+	cp     Checkpoint
+}
+
+// NewCoordinator creates a coordinator. The reader is positioned right after
+// the transaction written last to the sink, or left as is if the sink is
+// empty.
+func NewCoordinator(ctx context.Context, r *Reader, sink AtomicSink) (*Coordinator, error) {
+	cp, ok, err := sink.LoadCheckpoint(ctx)
+	if err != nil {
+		return nil, errors.Annotate(err, "load checkpoint")
+	}
+	if ok {
+		if len(cp.GTIDSet) > 0 {
+			err = r.SeekGTID(cp.GTIDSet)
+		} else {
+			err = r.Seek(cp.Position)
+		}
+		if err != nil {
+			return nil, errors.Annotate(err, "seek to checkpoint")
+		}
+	} else {
+		cp = Checkpoint{Position: r.State(), GTIDSet: r.GTIDSet()}
+	}
+	cp.GTIDSet = cp.GTIDSet.Clone()
+	return &Coordinator{reader: NewTransactionReader(r), sink: sink, cp: cp}, nil
+}
+
+// Checkpoint returns the checkpoint of the last written transaction.
+func (c *Coordinator) Checkpoint() Checkpoint {
+	return c.cp
+}
+
+// Next reads the next transaction and writes it to the sink. The written
+// transaction is returned.
+func (c *Coordinator) Next(ctx context.Context) (*Transaction, error) {
+	txn, err := c.reader.ReadTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cp := Checkpoint{Position: txn.Position, GTIDSet: c.cp.GTIDSet.Clone()}
+	if txn.GTID != (binlog.GTID{}) {
+		cp.GTIDSet.AddGTID(txn.GTID)
+	}
+	if err := c.sink.WriteTransaction(ctx, txn, cp); err != nil {
+		return nil, errors.Annotate(err, "write transaction")
+	}
+	c.cp = cp
+	return txn, nil
+}
+
+// Run writes transactions until reading or writing fails.
+func (c *Coordinator) Run(ctx context.Context) error {
+	for {
+		if _, err := c.Next(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// SQLSink is an atomic sink that writes transactions to a database and keeps
+// checkpoints in a table of the same database. Every transaction is applied
+// within a database transaction that also updates the checkpoint.
+type SQLSink struct {
+	DB *sql.DB
+	// Table is the name of the checkpoint table, see CreateTable.
+	Table string
+	// Name identifies the pipeline, so that several pipelines could share
+	// the checkpoint table.
+	Name string
+	// Apply writes changes of a transaction using the database transaction.
+	Apply func(ctx context.Context, tx *sql.Tx, txn *Transaction) error
+}
+
+// CreateTable creates the checkpoint table unless it exists.
+func (s *SQLSink) CreateTable(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+quoteName(s.Table)+` (
+		name VARCHAR(255) NOT NULL PRIMARY KEY,
+		file VARCHAR(255) NOT NULL,
+		position BIGINT UNSIGNED NOT NULL,
+		gtid_set TEXT NOT NULL
+	)`)
+	return err
+}
+
+// LoadCheckpoint reads the checkpoint of the pipeline.
+func (s *SQLSink) LoadCheckpoint(ctx context.Context) (Checkpoint, bool, error) {
+	var cp Checkpoint
+	var set string
+	err := s.DB.QueryRowContext(ctx, `SELECT file, position, gtid_set FROM `+quoteName(s.Table)+` WHERE name = ?`, s.Name).
+		Scan(&cp.Position.File, &cp.Position.Offset, &set)
+	if err == sql.ErrNoRows {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, err
+	}
+	if set != "" {
+		if cp.GTIDSet, err = binlog.ParseGTIDSet(set); err != nil {
+			return cp, false, errors.Annotate(err, "parse GTID set")
+		}
+	}
+	return cp, true, nil
+}
+
+// WriteTransaction applies a transaction and updates the checkpoint within a
+// single database transaction.
+func (s *SQLSink) WriteTransaction(ctx context.Context, txn *Transaction, cp Checkpoint) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := s.Apply(ctx, tx, txn); err != nil {
+		tx.Rollback()
+		return err
+	}
+	var set string
+	if len(cp.GTIDSet) > 0 {
+		set = cp.GTIDSet.String()
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO `+quoteName(s.Table)+` (name, file, position, gtid_set)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE file = VALUES(file), position = VALUES(position), gtid_set = VALUES(gtid_set)`,
+		s.Name, cp.Position.File, cp.Position.Offset, set)
+	if err != nil {
+		tx.Rollback()
+		return errors.Annotate(err, "save checkpoint")
+	}
+	return tx.Commit()
+}
+
+// quoteName quotes a possibly qualified table name.
+func quoteName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "`" + strings.Replace(p, "`", "``", -1) + "`"
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,83 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/juju/errors"
+)
+
+// memSink keeps written rows and the checkpoint in memory. It fails once the
+// given number of transactions is written.
+type memSink struct {
+	rows  []interface{}
+	cp    *Checkpoint
+	limit int
+}
+
+func (s *memSink) LoadCheckpoint(_ context.Context) (Checkpoint, bool, error) {
+	if s.cp == nil {
+		return Checkpoint{}, false, nil
+	}
+	return *s.cp, true, nil
+}
+
+func (s *memSink) WriteTransaction(_ context.Context, txn *Transaction, cp Checkpoint) error {
+	if s.limit == 0 {
+		return errors.New("sink is down")
+	}
+	s.limit--
+	for _, c := range txn.Changes {
+		s.rows = append(s.rows, c.After[0])
+	}
+	s.cp = &cp
+	return nil
+}
+
+func TestCoordinator(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+	}}
+	g := binlogtest.New()
+	g.FormatDescription()
+	for i := 1; i <= 3; i++ {
+		g.Query("shop", "BEGIN")
+		g.TableMap(table)
+		if _, err := g.Insert(table, []interface{}{i}); err != nil {
+			t.Fatalf("Failed to build rows event: %v", err)
+		}
+		g.XID(uint64(i))
+	}
+
+	path := writeGenerated(t, g)
+
+	sink := &memSink{limit: 1}
+	run := func() error {
+		r, err := NewFile(path, 0)
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		defer r.Close(context.Background())
+		c, err := NewCoordinator(context.Background(), r, sink)
+		if err != nil {
+			t.Fatalf("Failed to create coordinator: %v", err)
+		}
+		return c.Run(context.Background())
+	}
+	if err := run(); err == nil || errors.Cause(err) == ErrEndOfLog {
+		t.Fatalf("Expected sink to fail, got %v", err)
+	}
+	sink.limit = -1
+	if err := run(); err != ErrEndOfLog {
+		t.Fatalf("Expected end of log, got %v", err)
+	}
+	if exp := []interface{}{uint32(1), uint32(2), uint32(3)}; !reflect.DeepEqual(sink.rows, exp) {
+		t.Errorf("Expected rows %v, got %v", exp, sink.rows)
+	}
+	if sink.cp.Position != g.Position() {
+		t.Errorf("Expected checkpoint at %v, got %v", g.Position(), sink.cp.Position)
+	}
+}
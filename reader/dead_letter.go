@@ -0,0 +1,127 @@
+package reader
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// DeadLetter describes an event that failed to decode. It contains everything
+// required to decode the event again once the cause is fixed.
+type DeadLetter struct {
+	// Position is the position of the event.
+	Position binlog.Position
+	// GTID identifies the transaction of the event, it's empty when GTIDs
+	// are disabled.
+	GTID   binlog.GTID
+	Header binlog.EventHeader
+	// Body is a copy of the event without the header and the checksum.
+	Body   []byte
+	Format binlog.FormatDescription
+	// Table is set for rows events.
+	Table *binlog.TableDescription
+	Err   error
+}
+
+// DeadLetterFunc receives events that failed to decode. Reading continues if
+// it returns nil, otherwise the error is returned to the consumer.
+type DeadLetterFunc func(dl DeadLetter) error
+
+// WithDeadLetter sets a function that receives table map, query, GTID and rows
+// events that failed to decode instead of failing the stream. Such events are
+// skipped by ReadEvent and DecodeRows returns rows events without rows for
+// them. Rows events of a table whose table map event was dead-lettered are
+// handled according to the unknown table strategy. Events that the reader
+// can't do without, such as format description and rotate events, still fail
+// the stream.
+func WithDeadLetter(fn DeadLetterFunc) Option {
+	return func(r *Reader) {
+		r.deadLetter = fn
+	}
+}
+
+// WithCorruptionHandler sets a function that receives rows events that failed
+// to decode, so that they could be kept for offline analysis. Unlike dead
+// letters it doesn't change how failures are handled, DecodeRows still returns
+// the error unless a dead letter function is set too.
+func WithCorruptionHandler(fn binlog.CorruptionHandler) Option {
+	return func(r *Reader) {
+		r.corruption = fn
+	}
+}
+
+// DeadLetterWriter returns a dead letter function that writes dead letters to
+// the writer as JSON, one per line. It's safe for concurrent use.
+func DeadLetterWriter(w io.Writer) DeadLetterFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(dl DeadLetter) error {
+		rec := struct {
+			File      string                   `json:"file"`
+			Offset    uint64                   `json:"offset"`
+			GTID      string                   `json:"gtid,omitempty"`
+			EventType string                   `json:"event_type"`
+			Body      []byte                   `json:"body"`
+			Format    binlog.FormatDescription `json:"format"`
+			Table     *binlog.TableDescription `json:"table,omitempty"`
+			Error     string                   `json:"error"`
+		}{
+			File:      dl.Position.File,
+			Offset:    dl.Position.Offset,
+			EventType: dl.Header.Type.String(),
+			Body:      dl.Body,
+			Format:    dl.Format,
+			Table:     dl.Table,
+			Error:     dl.Err.Error(),
+		}
+		if dl.GTID != (binlog.GTID{}) {
+			rec.GTID = dl.GTID.String()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return errors.Annotate(enc.Encode(rec), "write dead letter")
+	}
+}
+
+// deadLetterEvent passes an event that failed to decode while reading to the
+// dead letter function and marks it to be skipped. The original error is
+// returned if there is no dead letter function.
+func (r *Reader) deadLetterEvent(evt *Event, err error) error {
+	if r.deadLetter == nil {
+		return err
+	}
+	evt.EndPosition.File = r.state.File
+	evt.GTID = r.gtid
+	if err := sendDeadLetter(r.deadLetter, evt, err); err != nil {
+		return err
+	}
+	evt.skip = true
+	return nil
+}
+
+// sendDeadLetter passes an event that failed to decode to the dead letter
+// function. The original error is returned if there is none.
+func sendDeadLetter(fn DeadLetterFunc, e *Event, err error) error {
+	if fn == nil {
+		return err
+	}
+	dl := DeadLetter{
+		Position: binlog.Position{File: e.EndPosition.File, Offset: e.Offset},
+		GTID:     e.GTID,
+		Header:   e.Header,
+		Body:     append([]byte(nil), e.Body()...),
+		Format:   e.Format,
+		Err:      err,
+	}
+	if e.Table != nil {
+		td := *e.Table
+		dl.Table = &td
+	}
+	if derr := fn(dl); derr != nil {
+		return errors.Annotate(derr, "dead letter event")
+	}
+	return nil
+}
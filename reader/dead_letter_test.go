@@ -0,0 +1,63 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestDeadLetterRows(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "orders",
+		ColumnCount: 1,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0},
+	}
+	evt := Event{
+		Header:      binlog.EventHeader{Type: binlog.EventTypeWriteRowsV2},
+		Buffer:      []byte{0x01, 0x00},
+		Offset:      120,
+		EndPosition: binlog.Position{File: "mysql-bin.000001", Offset: 150},
+		Table:       &td,
+	}
+	if _, err := evt.DecodeRows(); err == nil {
+		t.Fatalf("Expected truncated rows event to fail to decode")
+	}
+
+	var buf bytes.Buffer
+	evt.deadLetter = DeadLetterWriter(&buf)
+	re, err := evt.DecodeRows()
+	if err != nil {
+		t.Fatalf("Expected rows event to be dead-lettered, got %v", err)
+	}
+	if len(re.Rows) != 0 {
+		t.Errorf("Expected no rows, got %v", re.Rows)
+	}
+	var rec struct {
+		File   string `json:"file"`
+		Offset uint64 `json:"offset"`
+		Body   []byte `json:"body"`
+		Table  *binlog.TableDescription
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("Failed to decode dead letter: %v", err)
+	}
+	if rec.File != "mysql-bin.000001" || rec.Offset != 120 || !bytes.Equal(rec.Body, evt.Buffer) {
+		t.Errorf("Unexpected dead letter %s", buf.String())
+	}
+	if rec.Table == nil || rec.Table.TableName != "orders" {
+		t.Errorf("Expected dead letter to contain table description, got %s", buf.String())
+	}
+
+	failed := errors.New("sink failed")
+	evt.deadLetter = func(DeadLetter) error { return failed }
+	if _, err := evt.DecodeRows(); err == nil || !strings.Contains(err.Error(), "sink failed") {
+		t.Errorf("Expected dead letter error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package reader
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// RoutedChange is a row change delivered by a demultiplexer.
+type RoutedChange struct {
+	RowChange
+	// GTID and Position identify the transaction of the change, see
+	// Transaction.
+	GTID     binlog.GTID
+	Position binlog.Position
+}
+
+// RouteFunc returns the name of the route of a row change.
+type RouteFunc func(c RowChange) string
+
+// RouteByTable routes changes by qualified table names, e.g. "shop.orders".
+func RouteByTable(c RowChange) string {
+	return c.Table.SchemaName + "." + c.Table.TableName
+}
+
+// RouteByShard returns a route function that splits changes of every table
+// into the given number of shards by primary key, e.g. "shop.orders#3".
+// Changes of the same row are always routed to the same shard. Changes of
+// tables with unknown primary keys go to a single shard.
+func RouteByShard(shards int) RouteFunc {
+	if shards < 1 {
+		shards = 1
+	}
+	return func(c RowChange) string {
+		row := c.After
+		if row == nil {
+			row = c.Before
+		}
+		shard := partitionKey(c, row) % shards
+		return RouteByTable(c) + "#" + strconv.Itoa(shard)
+	}
+}
+
+// Demux routes row changes into per-route channels, so that workers of
+// different tables or shards don't wait for each other. Every channel has its
+// own buffer, sending blocks only once the buffer of the destination channel
+// is full.
+type Demux struct {
+	route   RouteFunc
+	bufSize int
+	onRoute func(name string, ch <-chan RoutedChange)
+
+	mu       sync.Mutex
+	channels map[string]chan RoutedChange
+}
+
+// NewDemux creates a demultiplexer with channels of the given buffer size.
+// The onRoute function is called once for every new route before the first
+// change is sent to its channel, it would usually start a worker.
+func NewDemux(route RouteFunc, bufSize int, onRoute func(name string, ch <-chan RoutedChange)) *Demux {
+	return &Demux{
+		route:    route,
+		bufSize:  bufSize,
+		onRoute:  onRoute,
+		channels: make(map[string]chan RoutedChange),
+	}
+}
+
+// Send routes all changes of a transaction. It blocks while the channel of a
+// change is full, or until the context is done. An update that moves a row to
+// another route, which could happen with RouteByShard, is sent to the route of
+// the image after the update. Send must not be called concurrently with Close.
+func (d *Demux) Send(ctx context.Context, txn *Transaction) error {
+	return txn.ForEachChange(func(c RowChange) error {
+		ch := d.channel(d.route(c))
+		select {
+		case ch <- RoutedChange{RowChange: c, GTID: txn.GTID, Position: txn.Position}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// channel returns the channel of a route, creating it if necessary.
+func (d *Demux) channel(name string) chan RoutedChange {
+	d.mu.Lock()
+	ch, ok := d.channels[name]
+	if !ok {
+		ch = make(chan RoutedChange, d.bufSize)
+		d.channels[name] = ch
+	}
+	d.mu.Unlock()
+	if !ok && d.onRoute != nil {
+		d.onRoute(name, ch)
+	}
+	return ch
+}
+
+// Routes returns names of the routes seen so far.
+func (d *Demux) Routes() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.channels))
+	for name := range d.channels {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Close closes all channels, workers should exit once they drain them.
+func (d *Demux) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, ch := range d.channels {
+		close(ch)
+		delete(d.channels, name)
+	}
+}
@@ -0,0 +1,61 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+func TestDemux(t *testing.T) {
+	orders := binlog.TableDescription{SchemaName: "shop", TableName: "orders", PrimaryKey: []int{0}}
+	users := binlog.TableDescription{SchemaName: "shop", TableName: "users", PrimaryKey: []int{0}}
+	txn := &Transaction{
+		Position: binlog.Position{File: "mysql-bin.000001", Offset: 100},
+		Changes: []RowChange{
+			{Type: ChangeInsert, Table: orders, After: []interface{}{uint32(1)}},
+			{Type: ChangeInsert, Table: users, After: []interface{}{uint32(1)}},
+			{Type: ChangeUpdate, Table: orders, Before: []interface{}{uint32(1)}, After: []interface{}{uint32(2)}},
+		},
+	}
+
+	channels := make(map[string]<-chan RoutedChange)
+	d := NewDemux(RouteByTable, len(txn.Changes), func(name string, ch <-chan RoutedChange) {
+		channels[name] = ch
+	})
+	if err := d.Send(context.Background(), txn); err != nil {
+		t.Fatalf("Failed to send transaction: %v", err)
+	}
+	routes := d.Routes()
+	sort.Strings(routes)
+	if exp := []string{"shop.orders", "shop.users"}; !reflect.DeepEqual(routes, exp) {
+		t.Errorf("Expected routes %v, got %v", exp, routes)
+	}
+	d.Close()
+
+	exp := map[string][]ChangeType{
+		"shop.orders": {ChangeInsert, ChangeUpdate},
+		"shop.users":  {ChangeInsert},
+	}
+	for name, types := range exp {
+		var got []ChangeType
+		for c := range channels[name] {
+			if c.Position != txn.Position {
+				t.Errorf("Unexpected position %v", c.Position)
+			}
+			got = append(got, c.Type)
+		}
+		if !reflect.DeepEqual(got, types) {
+			t.Errorf("Route %s: expected changes %v, got %v", name, types, got)
+		}
+	}
+
+	shard := RouteByShard(8)
+	insert := RowChange{Type: ChangeInsert, Table: orders, After: []interface{}{uint32(7)}}
+	del := RowChange{Type: ChangeDelete, Table: orders, Before: []interface{}{uint32(7)}}
+	if a, b := shard(insert), shard(del); a != b {
+		t.Errorf("Expected changes of the same row to share a route, got %s and %s", a, b)
+	}
+}
@@ -0,0 +1,182 @@
+package reader
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"io"
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// KeyProvider provides keys of the keyring binary logs were encrypted with.
+// Keys are identified as MySQLReplicationKey_<server UUID>_<sequence number>,
+// replication master keys are 32 bytes long.
+type KeyProvider interface {
+	Key(id string) ([]byte, error)
+}
+
+// KeyProviderFunc is a function that implements KeyProvider.
+type KeyProviderFunc func(id string) ([]byte, error)
+
+// Key returns the key with the given ID.
+func (fn KeyProviderFunc) Key(id string) ([]byte, error) {
+	return fn(id)
+}
+
+// WithKeyProvider makes a file reader decrypt binary logs written with
+// binlog_encryption enabled using keys of the provider. Positions of events in
+// encrypted files are the same as in unencrypted files, the encryption header
+// is not accounted for.
+func WithKeyProvider(kp KeyProvider) Option {
+	return func(r *Reader) {
+		r.keys = kp
+	}
+}
+
+// encryptedMagic is the header of every encrypted binary log file.
+var encryptedMagic = []byte{0xFD, 'b', 'i', 'n'}
+
+// ErrNoKeyProvider is returned when a binary log file is encrypted and a key
+// provider is not set.
+var ErrNoKeyProvider = errors.New("Binary log file is encrypted, a key provider is required")
+
+// Encrypted file header details.
+// Spec: https://dev.mysql.com/worklog/task/?id=10957
+const (
+	encryptionHeaderLen      = 512
+	encryptionVersion        = 1
+	encryptionFieldEnd       = 0
+	encryptionFieldKeyID     = 1
+	encryptionFieldPassword  = 2
+	encryptionFieldIV        = 3
+	encryptionPasswordLen    = 32
+	encryptionIVLen          = aes.BlockSize
+	encryptionStreamKeyLen   = 32
+	encryptionStreamIVOffset = encryptionStreamKeyLen
+)
+
+// decryptReader decrypts the contents of an encrypted binary log file which
+// is encrypted with AES-256 in CTR mode, offsets exclude the header.
+type decryptReader struct {
+	f      *os.File
+	block  cipher.Block
+	iv     []byte
+	stream cipher.Stream
+}
+
+// newDecryptReader reads the header of an encrypted file, which follows the
+// magic, and decrypts the file password with the key from the keyring.
+func newDecryptReader(f *os.File, kp KeyProvider) (*decryptReader, error) {
+	if kp == nil {
+		return nil, ErrNoKeyProvider
+	}
+	head := make([]byte, encryptionHeaderLen)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return nil, errors.Annotate(err, "read encryption header")
+	}
+	if head[len(encryptedMagic)] != encryptionVersion {
+		return nil, errors.Errorf("Unsupported binary log encryption version %d", head[len(encryptedMagic)])
+	}
+
+	var keyID string
+	var password, iv []byte
+	for pos := len(encryptedMagic) + 1; pos < len(head); {
+		field := head[pos]
+		pos++
+		var n int
+		switch field {
+		case encryptionFieldEnd:
+			pos = len(head)
+			continue
+		case encryptionFieldKeyID:
+			if pos < len(head) {
+				n = int(head[pos])
+				pos++
+			}
+		case encryptionFieldPassword:
+			n = encryptionPasswordLen
+		case encryptionFieldIV:
+			n = encryptionIVLen
+		default:
+			return nil, errors.Errorf("Unknown encryption header field %d", field)
+		}
+		if pos+n > len(head) {
+			return nil, errors.New("Encryption header is truncated")
+		}
+		switch field {
+		case encryptionFieldKeyID:
+			keyID = string(head[pos : pos+n])
+		case encryptionFieldPassword:
+			password = head[pos : pos+n]
+		case encryptionFieldIV:
+			iv = head[pos : pos+n]
+		}
+		pos += n
+	}
+	if keyID == "" || password == nil || iv == nil {
+		return nil, errors.New("Encryption header is incomplete")
+	}
+
+	key, err := kp.Key(keyID)
+	if err != nil {
+		return nil, errors.Annotatef(err, "get key %s", keyID)
+	}
+	mb, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Annotatef(err, "use key %s", keyID)
+	}
+	// File password is encrypted with AES-256 in CBC mode without padding
+	plain := make([]byte, len(password))
+	cipher.NewCBCDecrypter(mb, iv).CryptBlocks(plain, password)
+
+	// Key and IV of the file are derived from the password like
+	// EVP_BytesToKey with SHA-512 does
+	sum := sha512.Sum512(plain)
+	block, err := aes.NewCipher(sum[:encryptionStreamKeyLen])
+	if err != nil {
+		return nil, errors.Annotate(err, "initialize cipher")
+	}
+	d := &decryptReader{
+		f:     f,
+		block: block,
+		iv:    append([]byte(nil), sum[encryptionStreamIVOffset:encryptionStreamIVOffset+aes.BlockSize]...),
+	}
+	if _, err := d.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	n, err := d.f.Read(p)
+	d.stream.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// Seek moves to the given offset of decrypted contents. Only seeking from the
+// start is supported.
+func (d *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("Unsupported seek mode")
+	}
+	if _, err := d.f.Seek(encryptionHeaderLen+offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	// Counter is advanced to the block at the offset, the key stream is
+	// discarded up to the offset within the block
+	ctr := append([]byte(nil), d.iv...)
+	blocks := uint64(offset / aes.BlockSize)
+	for i := len(ctr) - 1; i >= 0 && blocks > 0; i-- {
+		sum := uint64(ctr[i]) + blocks&0xFF
+		ctr[i] = byte(sum)
+		blocks = blocks>>8 + sum>>8
+	}
+	d.stream = cipher.NewCTR(d.block, ctr)
+	if skip := int(offset % aes.BlockSize); skip > 0 {
+		discard := make([]byte, skip)
+		d.stream.XORKeyStream(discard, discard)
+	}
+	return offset, nil
+}
@@ -18,7 +18,14 @@ import (
 type EnhancedReader struct {
 	reader    *Reader
 	safepoint binlog.Position
-	schemaMgr *schema.Manager
+	schema    schemaSource
+}
+
+// schemaSource provides table schemas and keeps them up to date by processing
+// queries from the binary log.
+type schemaSource interface {
+	Table(database, table string) *schema.Table
+	ProcessQuery(database, query string) error
 }
 
 // EnhancedRowsEvent ...
@@ -42,7 +49,39 @@ func NewEnhanced(dsn string, sc driver.Config) (*EnhancedReader, error) {
 
 	return &EnhancedReader{
 		reader:    r,
-		schemaMgr: schema.NewManager(conn),
+		schema:    schema.NewManager(conn),
+		safepoint: r.state,
+	}, nil
+}
+
+// NewEnhancedWithTracker creates a new enhanced binary log reader that uses
+// given schema tracker instead of querying table schemas from the database.
+// Only the tables known to the tracker are processed.
+func NewEnhancedWithTracker(dsn string, sc driver.Config, t *schema.Tracker) (*EnhancedReader, error) {
+	r, err := New(dsn, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnhancedReader{
+		reader:    r,
+		schema:    t,
+		safepoint: r.state,
+	}, nil
+}
+
+// NewEnhancedWithRegistry creates a new enhanced binary log reader that uses
+// table schemas from the given registry. Only the registered tables are
+// processed.
+func NewEnhancedWithRegistry(dsn string, sc driver.Config, reg *schema.Registry) (*EnhancedReader, error) {
+	r, err := New(dsn, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnhancedReader{
+		reader:    r,
+		schema:    reg,
 		safepoint: r.state,
 	}, nil
 }
@@ -50,8 +89,12 @@ func NewEnhanced(dsn string, sc driver.Config) (*EnhancedReader, error) {
 // WhitelistTables adds given tables of the given database to processing white
 // list.
 func (r *EnhancedReader) WhitelistTables(database string, tables ...string) error {
+	mgr, ok := r.schema.(*schema.Manager)
+	if !ok {
+		return errors.New("whitelisting requires a schema manager")
+	}
 	for _, tbl := range tables {
-		if err := r.schemaMgr.Manage(database, tbl); err != nil {
+		if err := mgr.Manage(database, tbl); err != nil {
 			return err
 		}
 	}
@@ -68,8 +111,10 @@ func (r *EnhancedReader) ReadEvent(ctx context.Context) (*Event, error) {
 	switch evt.Header.Type {
 	case binlog.EventTypeQuery:
 		var qe binlog.QueryEvent
-		qe.Decode(evt.Buffer)
-		err = r.schemaMgr.ProcessQuery(string(qe.Schema), string(qe.Query))
+		if err := qe.Decode(evt.Body()); err != nil {
+			return nil, errors.Annotate(err, "decode query event")
+		}
+		err = r.schema.ProcessQuery(string(qe.Schema), string(qe.Query))
 	}
 
 	return evt, err
@@ -79,7 +124,7 @@ func (r *EnhancedReader) ReadEvent(ctx context.Context) (*Event, error) {
 // until next event is received or context is cancelled.
 func (r *EnhancedReader) NextRowsEvent(ctx context.Context) (*EnhancedRowsEvent, error) {
 	for {
-		evt, err := r.reader.ReadEvent(ctx)
+		evt, err := r.ReadEvent(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -90,7 +135,7 @@ func (r *EnhancedReader) NextRowsEvent(ctx context.Context) (*EnhancedRowsEvent,
 			continue
 		}
 
-		tbl := r.schemaMgr.Schema.Table(evt.Table.SchemaName, evt.Table.TableName)
+		tbl := r.schema.Table(evt.Table.SchemaName, evt.Table.TableName)
 		if tbl == nil {
 			// Not whitelisted
 			continue
@@ -146,9 +191,9 @@ func (r *EnhancedReader) Safepoint() binlog.Position {
 	return r.safepoint
 }
 
-// Close underlying database connection.
-func (r *EnhancedReader) Close() error {
-	return r.reader.Close()
+// Close underlying database connection. See Reader.Close for details.
+func (r *EnhancedReader) Close(ctx context.Context) error {
+	return r.reader.Close(ctx)
 }
 
 func signNumber(val interface{}, ct mysql.ColumnType) interface{} {
@@ -0,0 +1,169 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// Offsets of event header fields.
+const (
+	eventLenOffset  = 9
+	nextPosOffset   = 13
+	minEventHeadLen = 19
+)
+
+// ErrInvalidFile is returned when a file is not a binary log file.
+var ErrInvalidFile = errors.New("Not a binary log file")
+
+// NewFile creates a reader that reads events from a binary log file instead of
+// a server, which is handy for inspecting logs copied from production hosts.
+// Reading starts at the given offset, offsets below 4 start at the beginning
+// of the file. ErrEndOfLog is returned once the end of the file is reached.
+// Options that require a server connection have no effect.
+func NewFile(path string, offset uint64, opts ...Option) (*Reader, error) {
+	if offset < 4 {
+		offset = 4
+	}
+	r := &Reader{
+		dir: filepath.Dir(path),
+		state: binlog.Position{
+			File:   filepath.Base(path),
+			Offset: offset,
+		},
+		stats: newStats(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.tableMap = newTableMap(r.tableMapSize)
+	r.executed = binlog.NewGTIDSet()
+	r.commitPos = r.state
+	r.stats.setPosition(r.state)
+	if err := r.connect(context.Background()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// fileSource reads events from a binary log file.
+type fileSource struct {
+	f *os.File
+	// src is the file itself or a decrypting reader of an encrypted file
+	src    io.ReadSeeker
+	rd     *bufio.Reader
+	buf    []byte
+	offset uint64
+	// formatRead is set once the format description event is read
+	formatRead bool
+}
+
+func openFileSource(path string, offset uint64, kp KeyProvider) (*fileSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Annotate(err, "open binary log file")
+	}
+	s := &fileSource{f: f, src: f, offset: offset}
+	magic := make([]byte, len(binlog.FileMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		f.Close()
+		return nil, ErrInvalidFile
+	}
+	if bytes.Equal(magic, encryptedMagic) {
+		d, err := newDecryptReader(f, kp)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.src = d
+		if _, err := io.ReadFull(d, magic); err != nil {
+			f.Close()
+			return nil, errors.Annotate(err, "read binary log file")
+		}
+	}
+	if !bytes.Equal(magic, binlog.FileMagic) {
+		f.Close()
+		return nil, ErrInvalidFile
+	}
+	s.rd = bufio.NewReader(s.src)
+	return s, nil
+}
+
+// ReadEvent returns the next event. The format description event that opens
+// the file is always returned first, even if reading starts at a later offset.
+// Nil is returned at the end of the file.
+func (s *fileSource) ReadEvent(ctx context.Context) ([]byte, error) {
+	if s.formatRead && s.offset > 4 {
+		if _, err := s.src.Seek(int64(s.offset), io.SeekStart); err != nil {
+			return nil, errors.Annotate(err, "seek binary log file")
+		}
+		s.rd.Reset(s.src)
+		s.offset = 0
+	}
+
+	if cap(s.buf) < minEventHeadLen {
+		s.buf = make([]byte, minEventHeadLen, 4096)
+	}
+	head := s.buf[:minEventHeadLen]
+	if _, err := io.ReadFull(s.rd, head); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncatedEvent
+		}
+		return nil, errors.Annotate(err, "read event header")
+	}
+	n := int(binary.LittleEndian.Uint32(head[eventLenOffset:]))
+	if n < minEventHeadLen {
+		return nil, ErrTruncatedEvent
+	}
+	if cap(s.buf) < n {
+		buf := make([]byte, n)
+		copy(buf, head)
+		s.buf = buf
+	}
+	s.buf = s.buf[:n]
+	if _, err := io.ReadFull(s.rd, s.buf[minEventHeadLen:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncatedEvent
+		}
+		return nil, errors.Annotate(err, "read event")
+	}
+
+	if !s.formatRead {
+		s.formatRead = true
+		if s.offset > 4 {
+			// Events are skipped up to the offset, the position is reported
+			// as zero just like the server does for artificial events
+			binary.LittleEndian.PutUint32(s.buf[nextPosOffset:], 0)
+		}
+	}
+	return s.buf, nil
+}
+
+// Close closes the file.
+func (s *fileSource) Close() error {
+	return s.f.Close()
+}
+
+// openFile opens the binary log file at the current position.
+func (r *Reader) openFile() error {
+	if r.source != nil {
+		r.source.Close()
+		r.source = nil
+	}
+	s, err := openFileSource(filepath.Join(r.dir, r.state.File), r.state.Offset, r.keys)
+	if err != nil {
+		return err
+	}
+	r.source = s
+	return nil
+}
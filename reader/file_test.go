@@ -0,0 +1,240 @@
+package reader
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/juju/errors"
+)
+
+// writeGenerated writes events built by the generator to a binary log file in
+// a temporary directory, which is removed once the test completes.
+func writeGenerated(t *testing.T, g *binlogtest.Generator) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bocadillo")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, g.Position().File)
+	if err := ioutil.WriteFile(path, g.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return path
+}
+
+// openGenerated writes events built by the generator to a binary log file and
+// opens a reader of it, which is closed once the test completes.
+func openGenerated(t *testing.T, g *binlogtest.Generator, opts ...Option) *Reader {
+	t.Helper()
+	r, err := NewFile(writeGenerated(t, g), 0, opts...)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	t.Cleanup(func() { r.Close(context.Background()) })
+	return r
+}
+
+func writeTestFile(t *testing.T) (string, []int) {
+	g := binlogtest.New()
+	offsets := []int{int(g.Position().Offset)}
+	g.FormatDescription()
+	for xid := uint64(1); xid <= 2; xid++ {
+		offsets = append(offsets, int(g.Position().Offset))
+		g.XID(xid)
+	}
+	return writeGenerated(t, g), offsets
+}
+
+func TestNewFile(t *testing.T) {
+	path, offsets := writeTestFile(t)
+
+	tests := []struct {
+		name   string
+		offset uint64
+		xids   []uint64
+	}{
+		{"beginning", 0, []uint64{1, 2}},
+		{"offset", uint64(offsets[2]), []uint64{2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewFile(path, test.offset)
+			if err != nil {
+				t.Fatalf("Failed to open file: %v", err)
+			}
+			defer r.Close(context.Background())
+
+			evt, err := r.ReadEvent(context.Background())
+			if err != nil {
+				t.Fatalf("Failed to read event: %v", err)
+			}
+			if evt.Header.Type != binlog.EventTypeFormatDescription {
+				t.Fatalf("Expected format description event, got %s", evt.Header.Type.String())
+			}
+			if fd := r.Format(); fd.Version != 4 || fd.ServerVersion != "5.7.19-log" || fd.HeaderLen() != 19 {
+				t.Errorf("Unexpected format %s", fd)
+			}
+
+			var xids []uint64
+			for {
+				evt, err := r.ReadEvent(context.Background())
+				if err == ErrEndOfLog {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to read event: %v", err)
+				}
+				if uint64(offsets[len(offsets)-len(test.xids)+len(xids)]) != evt.Offset {
+					t.Errorf("Unexpected event offset %d", evt.Offset)
+				}
+				var xe binlog.XIDEvent
+				if err := xe.Decode(evt.Buffer); err != nil {
+					t.Fatalf("Failed to decode event: %v", err)
+				}
+				xids = append(xids, xe.XID)
+			}
+			if len(xids) != len(test.xids) || xids[0] != test.xids[0] {
+				t.Errorf("Expected transactions %v, got %v", test.xids, xids)
+			}
+			if r.State().File != "mysql-bin.000001" {
+				t.Errorf("Unexpected position %v", r.State())
+			}
+		})
+	}
+}
+
+func TestTruncatedFile(t *testing.T) {
+	path, offsets := writeTestFile(t)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"header", offsets[2] + 5},
+		{"body", len(data) - 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, data[:test.size], 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			r, err := NewFile(path, uint64(offsets[2]))
+			if err != nil {
+				t.Fatalf("Failed to open file: %v", err)
+			}
+			defer r.Close(context.Background())
+
+			// Format description event is read first
+			if _, err := r.ReadEvent(context.Background()); err != nil {
+				t.Fatalf("Failed to read event: %v", err)
+			}
+			if _, err := r.ReadEvent(context.Background()); errors.Cause(err) != ErrTruncatedEvent {
+				t.Errorf("Expected ErrTruncatedEvent, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedFile(t *testing.T) {
+	g := binlogtest.New()
+	g.ServerVersion = "10.4.12-MariaDB"
+	g.FormatDescription()
+	g.MariaDBBinlogCheckpoint("mysql-bin.000001")
+	g.MariaDBStartEncryption(3, [12]byte{1, 2, 3})
+	g.XID(1)
+
+	r := openGenerated(t, g)
+
+	for _, et := range []binlog.EventType{binlog.EventTypeFormatDescription, binlog.EventTypeMariaDBBinlogCheckpoint} {
+		evt, err := r.ReadEvent(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if evt.Header.Type != et {
+			t.Fatalf("Expected %s, got %s", et, evt.Header.Type)
+		}
+		if et == binlog.EventTypeMariaDBBinlogCheckpoint {
+			var ce binlog.MariaDBBinlogCheckpointEvent
+			if err := ce.Decode(evt.Buffer); err != nil || ce.File != "mysql-bin.000001" {
+				t.Errorf("Unexpected checkpoint event %+v, error %v", ce, err)
+			}
+		}
+	}
+	if _, err := r.ReadEvent(context.Background()); errors.Cause(err) != binlog.ErrEncryptedBinlog {
+		t.Errorf("Expected ErrEncryptedBinlog, got %v", err)
+	}
+}
+
+func TestEncryptedMySQLFile(t *testing.T) {
+	path, offsets := writeTestFile(t)
+	plain, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	// Encrypt the file the way the server does
+	masterKey := make([]byte, 32)
+	password := make([]byte, 32)
+	iv := make([]byte, 16)
+	for i := range password {
+		masterKey[i], password[i] = byte(i), byte(255-i)
+	}
+	mb, _ := aes.NewCipher(masterKey)
+	encPassword := make([]byte, len(password))
+	cipher.NewCBCEncrypter(mb, iv).CryptBlocks(encPassword, password)
+	keyID := "MySQLReplicationKey_uuid_1"
+	head := append([]byte{0xFD, 'b', 'i', 'n', 1, 1, byte(len(keyID))}, keyID...)
+	head = append(append(head, 2), encPassword...)
+	head = append(append(head, 3), iv...)
+	head = append(head, make([]byte, 512-len(head))...)
+	sum := sha512.Sum512(password)
+	fb, _ := aes.NewCipher(sum[:32])
+	enc := make([]byte, len(plain))
+	cipher.NewCTR(fb, sum[32:48]).XORKeyStream(enc, plain)
+	if err := ioutil.WriteFile(path, append(head, enc...), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := NewFile(path, 0); errors.Cause(err) != ErrNoKeyProvider {
+		t.Errorf("Expected ErrNoKeyProvider, got %v", err)
+	}
+	keys := KeyProviderFunc(func(id string) ([]byte, error) {
+		if id != keyID {
+			return nil, errors.NotFoundf("key %s", id)
+		}
+		return masterKey, nil
+	})
+	r, err := NewFile(path, uint64(offsets[2]), WithKeyProvider(keys))
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer r.Close(context.Background())
+	for _, et := range []binlog.EventType{binlog.EventTypeFormatDescription, binlog.EventTypeXID} {
+		evt, err := r.ReadEvent(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if evt.Header.Type != et {
+			t.Fatalf("Expected %s, got %s", et, evt.Header.Type)
+		}
+		if et == binlog.EventTypeXID {
+			var xe binlog.XIDEvent
+			if err := xe.Decode(evt.Buffer); err != nil || xe.XID != 2 || evt.Offset != uint64(offsets[2]) {
+				t.Errorf("Unexpected event %d at %d, error %v", xe.XID, evt.Offset, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package reader
+
+import (
+	"path"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// Filter selects tables and columns delivered by the reader. It's described by
+// an expression that consists of rules separated by commas or whitespace:
+//
+//	include db.table          deliver only matching tables
+//	exclude db.table          don't deliver matching tables
+//	drop db.table.column      remove matching columns from decoded rows
+//
+// Names are glob patterns matched against every part of the name separately,
+// see path.Match, e.g. "include shop.* exclude shop.audit_* drop *.*.password".
+// A table is delivered if it matches any include rule, or if there are no
+// include rules, and matches no exclude rules. Table map and rows events of
+// other tables are skipped. Dropped columns are decoded as absent, just like
+// columns missing in minimal row images. Dropping columns requires column
+// names, which are either logged with binlog_row_metadata=FULL or provided by
+// a schema source, see WithSchemaTracker.
+type Filter struct {
+	expr    string
+	include []namePattern
+	exclude []namePattern
+	drop    []namePattern
+}
+
+// namePattern is a dot separated name with a glob pattern for every part.
+type namePattern []string
+
+// Filter rule keywords.
+const (
+	filterInclude = "include"
+	filterExclude = "exclude"
+	filterDrop    = "drop"
+)
+
+// ParseFilter parses a filter expression.
+func ParseFilter(expr string) (*Filter, error) {
+	f := &Filter{expr: expr}
+	words := strings.FieldsFunc(expr, func(c rune) bool {
+		return c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+	})
+	for i := 0; i < len(words); i += 2 {
+		if i+1 == len(words) {
+			return nil, errors.Errorf("Filter rule %q lacks a name", words[i])
+		}
+		keyword, name := strings.ToLower(words[i]), words[i+1]
+		parts := 2
+		if keyword == filterDrop {
+			parts = 3
+		}
+		p, err := parseNamePattern(name, parts)
+		if err != nil {
+			return nil, err
+		}
+		switch keyword {
+		case filterInclude:
+			f.include = append(f.include, p)
+		case filterExclude:
+			f.exclude = append(f.exclude, p)
+		case filterDrop:
+			f.drop = append(f.drop, p)
+		default:
+			return nil, errors.Errorf("Unknown filter rule %q", words[i])
+		}
+	}
+	return f, nil
+}
+
+func parseNamePattern(name string, parts int) (namePattern, error) {
+	p := namePattern(strings.Split(name, "."))
+	if len(p) != parts {
+		return nil, errors.Errorf("Filter name %q should consist of %d parts", name, parts)
+	}
+	for _, part := range p {
+		if _, err := path.Match(part, ""); err != nil {
+			return nil, errors.Annotatef(err, "filter name %q", name)
+		}
+	}
+	return p, nil
+}
+
+// match returns true if every part of the name matches the pattern.
+func (p namePattern) match(name ...string) bool {
+	for i, part := range p {
+		if ok, _ := path.Match(part, name[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter expression.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Table returns true if the table should be delivered.
+func (f *Filter) Table(database, table string) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.include) == 0
+	for _, p := range f.include {
+		if p.match(database, table) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, p := range f.exclude {
+		if p.match(database, table) {
+			return false
+		}
+	}
+	return true
+}
+
+// droppedColumns returns indexes of the columns of a table that should be
+// dropped.
+func (f *Filter) droppedColumns(td binlog.TableDescription) []int {
+	if f == nil || len(f.drop) == 0 {
+		return nil
+	}
+	var cols []int
+	for i, name := range td.ColumnNames {
+		for _, p := range f.drop {
+			if p.match(td.SchemaName, td.TableName, name) {
+				cols = append(cols, i)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+// WithFilter sets the initial filter, see SetFilter.
+func WithFilter(f *Filter) Option {
+	return func(r *Reader) {
+		r.filter.Store(f)
+	}
+}
+
+// SetFilter replaces the filter of a reader. It's safe to call while another
+// goroutine reads events, the new filter applies to events read after the call
+// returns. Passing nil removes the filter.
+func (r *Reader) SetFilter(f *Filter) {
+	r.filter.Store(f)
+}
+
+// Filter returns the current filter of the reader, it's nil if there is none.
+func (r *Reader) Filter() *Filter {
+	f, _ := r.filter.Load().(*Filter)
+	return f
+}
+
+// applyFilter marks events of filtered tables as skipped and sets columns to
+// drop from decoded rows.
+func (r *Reader) applyFilter(evt *Event, td binlog.TableDescription) {
+	f := r.Filter()
+	if f == nil {
+		return
+	}
+	if !f.Table(td.SchemaName, td.TableName) {
+		evt.skip = true
+		return
+	}
+	evt.dropColumns = f.droppedColumns(td)
+}
+
+// dropColumns removes values of the given columns from decoded rows and
+// clears their bits in the column bitmaps.
+func dropColumns(re *binlog.RowsEvent, cols []int) {
+	// Bitmaps reference the event buffer, which must stay intact
+	re.ColumnBitmap1 = copyBytes(re.ColumnBitmap1)
+	re.ColumnBitmap2 = copyBytes(re.ColumnBitmap2)
+	for _, i := range cols {
+		bit := byte(1) << uint(i%8)
+		if i/8 < len(re.ColumnBitmap1) {
+			re.ColumnBitmap1[i/8] &^= bit
+		}
+		if i/8 < len(re.ColumnBitmap2) {
+			re.ColumnBitmap2[i/8] &^= bit
+		}
+		for _, row := range re.Rows {
+			if i < len(row) {
+				row[i] = nil
+			}
+		}
+	}
+}
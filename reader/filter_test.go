@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr   string
+		tables map[string]bool
+		err    bool
+	}{
+		{"", map[string]bool{"shop.orders": true, "crm.users": true}, false},
+		{"include shop.*", map[string]bool{"shop.orders": true, "crm.users": false}, false},
+		{"include shop.*, exclude shop.audit_*", map[string]bool{"shop.orders": true, "shop.audit_log": false}, false},
+		{"exclude *.tmp_*\ninclude crm.users include shop.orders", map[string]bool{
+			"shop.orders": true, "crm.users": true, "crm.tmp_users": false, "crm.accounts": false,
+		}, false},
+		{"drop shop.users.password", map[string]bool{"shop.users": true}, false},
+		{"include shop", nil, true},
+		{"drop shop.users", nil, true},
+		{"include shop.[", nil, true},
+		{"keep shop.orders", nil, true},
+		{"exclude", nil, true},
+	}
+	for _, test := range tests {
+		f, err := ParseFilter(test.expr)
+		if (err != nil) != test.err {
+			t.Errorf("%q: unexpected error %v", test.expr, err)
+			continue
+		}
+		for name, exp := range test.tables {
+			parts := strings.SplitN(name, ".", 2)
+			if ok := f.Table(parts[0], parts[1]); ok != exp {
+				t.Errorf("%q: expected %s to be delivered: %t", test.expr, name, exp)
+			}
+		}
+	}
+}
+
+func TestSetFilter(t *testing.T) {
+	users := binlogtest.Table{ID: 1, Schema: "shop", Name: "users", FullMetadata: true, Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+		{Name: "password", Type: mysql.ColumnTypeVarchar},
+	}}
+	audit := binlogtest.Table{ID: 2, Schema: "shop", Name: "audit", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+	}}
+	g := binlogtest.New()
+	g.FormatDescription()
+	for i := 1; i <= 2; i++ {
+		g.Query("shop", "BEGIN")
+		g.TableMap(audit)
+		if _, err := g.Insert(audit, []interface{}{i}); err != nil {
+			t.Fatalf("Failed to build rows event: %v", err)
+		}
+		g.TableMap(users)
+		if _, err := g.Insert(users, []interface{}{i, "secret"}); err != nil {
+			t.Fatalf("Failed to build rows event: %v", err)
+		}
+		g.XID(uint64(i))
+	}
+
+	f, err := ParseFilter("drop shop.users.password")
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	r := openGenerated(t, g, WithFilter(f))
+
+	tr := NewTransactionReader(r)
+	tests := []struct {
+		filter string
+		rows   [][]interface{}
+	}{
+		{"", [][]interface{}{{uint32(1)}, {uint32(1), nil}}},
+		{"exclude shop.audit", [][]interface{}{{uint32(2), "secret"}}},
+	}
+	for _, test := range tests {
+		if test.filter != "" {
+			f, err := ParseFilter(test.filter)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+			r.SetFilter(f)
+		}
+		txn, err := tr.ReadTransaction(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read transaction: %v", err)
+		}
+		var rows [][]interface{}
+		for _, c := range txn.Changes {
+			rows = append(rows, c.After)
+		}
+		if !reflect.DeepEqual(rows, test.rows) {
+			t.Errorf("Filter %q: expected rows %v, got %v", r.Filter().String(), test.rows, rows)
+		}
+	}
+}
+
+func TestSelectRows(t *testing.T) {
+	tenant := func(_ binlog.TableDescription, row []interface{}) bool {
+		return row[0] == uint32(1)
+	}
+	tests := []struct {
+		typ  binlog.EventType
+		rows [][]interface{}
+		exp  [][]interface{}
+	}{
+		{binlog.EventTypeWriteRowsV2,
+			[][]interface{}{{uint32(1), "a"}, {uint32(2), "b"}, {uint32(1), "c"}},
+			[][]interface{}{{uint32(1), "a"}, {uint32(1), "c"}}},
+		{binlog.EventTypeUpdateRowsV2,
+			[][]interface{}{{uint32(2), "a"}, {uint32(2), "b"}, {uint32(1), "a"}, {uint32(2), "a"}, {uint32(2), "c"}, {uint32(1), "c"}},
+			[][]interface{}{{uint32(1), "a"}, {uint32(2), "a"}, {uint32(2), "c"}, {uint32(1), "c"}}},
+		{binlog.EventTypeDeleteRowsV2,
+			[][]interface{}{{uint32(2), "a"}},
+			[][]interface{}{}},
+	}
+	for _, test := range tests {
+		re := binlog.RowsEvent{Type: test.typ, Rows: test.rows}
+		selectRows(&re, binlog.TableDescription{}, tenant)
+		if !reflect.DeepEqual(re.Rows, test.exp) {
+			t.Errorf("%s: expected rows %v, got %v", test.typ.String(), test.exp, re.Rows)
+		}
+	}
+}
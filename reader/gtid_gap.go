@@ -0,0 +1,55 @@
+package reader
+
+import (
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// GTIDGap describes transactions of a source that were skipped by the binary
+// log, which usually means they were filtered out on the source or lost.
+type GTIDGap struct {
+	SID binlog.SID
+	// Tag is the tag of the transactions, it's empty for untagged ones.
+	Tag string
+	// Missing is the range of missing transaction numbers.
+	Missing binlog.GTIDInterval
+	// GTID is the transaction received after the gap.
+	GTID binlog.GTID
+	// Position is the end position of the transaction received after the
+	// gap.
+	Position binlog.Position
+}
+
+// WithGTIDGapCallback sets a function that is called when a transaction
+// number of a source (and tag) doesn't follow the highest transaction number of that
+// source received so far, or contained in the initial GTID set. Transactions
+// of sources seen for the first time never cause gaps. The function is called
+// from ReadEvent once the transaction after the gap is committed and should
+// return quickly.
+func WithGTIDGapCallback(fn func(gap GTIDGap)) Option {
+	return func(r *Reader) {
+		r.onGTIDGap = fn
+	}
+}
+
+// checkGTIDGap reports a gap preceding the transaction that is about to be
+// added to the executed set.
+func (r *Reader) checkGTIDGap(gtid binlog.GTID) {
+	if r.onGTIDGap == nil {
+		return
+	}
+	ivs := r.executed[gtid.TSID()]
+	if len(ivs) == 0 {
+		return
+	}
+	last := ivs[len(ivs)-1].End
+	if gtid.GNO <= last+1 {
+		return
+	}
+	r.onGTIDGap(GTIDGap{
+		SID:      gtid.SID,
+		Tag:      gtid.Tag,
+		Missing:  binlog.GTIDInterval{Start: last + 1, End: gtid.GNO - 1},
+		GTID:     gtid,
+		Position: r.state,
+	})
+}
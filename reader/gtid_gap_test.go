@@ -0,0 +1,62 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+)
+
+func TestGTIDGapCallback(t *testing.T) {
+	sid, other := binlog.SID{1}, binlog.SID{2}
+	g := binlogtest.New()
+	g.FormatDescription()
+	var ends []binlog.Position
+	for i, gtid := range []binlog.GTID{
+		{SID: sid, GNO: 1}, {SID: sid, GNO: 2}, {SID: other, GNO: 7}, {SID: sid, GNO: 5}, {SID: sid, GNO: 6},
+		// Transactions of each tag are numbered independently
+		{SID: sid, GNO: 1, Tag: "tag"}, {SID: sid, GNO: 3, Tag: "tag"},
+	} {
+		if gtid.Tag != "" {
+			g.GTIDTagged(gtid, 0, 0)
+		} else {
+			g.GTID(gtid)
+		}
+		g.Query("shop", "BEGIN")
+		g.XID(uint64(i))
+		ends = append(ends, g.Position())
+	}
+
+	var gaps []GTIDGap
+	r := openGenerated(t, g, WithGTIDGapCallback(func(gap GTIDGap) {
+		gaps = append(gaps, gap)
+	}))
+	for {
+		if _, err := r.ReadEvent(context.Background()); err == ErrEndOfLog {
+			break
+		} else if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+	}
+
+	exp := []GTIDGap{{
+		SID:      sid,
+		Missing:  binlog.GTIDInterval{Start: 3, End: 4},
+		GTID:     binlog.GTID{SID: sid, GNO: 5},
+		Position: ends[3],
+	}, {
+		SID:      sid,
+		Tag:      "tag",
+		Missing:  binlog.GTIDInterval{Start: 2, End: 2},
+		GTID:     binlog.GTID{SID: sid, GNO: 3, Tag: "tag"},
+		Position: ends[6],
+	}}
+	if !reflect.DeepEqual(gaps, exp) {
+		t.Errorf("Expected gaps %+v, got %+v", exp, gaps)
+	}
+	if set := r.GTIDSet().String(); set != "01000000-0000-0000-0000-000000000000:1-2:5-6:tag:1:3,02000000-0000-0000-0000-000000000000:7" {
+		t.Errorf("Unexpected GTID set %q", set)
+	}
+}
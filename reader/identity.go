@@ -0,0 +1,113 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// ServerIdentity identifies the server writing the binary log being read.
+type ServerIdentity struct {
+	ServerID uint32
+	// UUID is the server_uuid of the server. It's empty when reading files,
+	// from MariaDB servers and servers hiding the variable.
+	UUID string
+}
+
+// matches returns true if both identities belong to the same server. UUIDs are
+// only compared if both are known.
+func (id ServerIdentity) matches(other ServerIdentity) bool {
+	return id.ServerID == other.ServerID && (id.UUID == "" || other.UUID == "" || id.UUID == other.UUID)
+}
+
+// IdentityChange describes a change of the server writing the binary log,
+// which usually means that a virtual IP or a proxy moved to a different master.
+// Positions of different servers don't match, and servers could have applied
+// different sets of transactions unless GTIDs are used.
+type IdentityChange struct {
+	// Position is where the new server's binary log starts being read.
+	Position binlog.Position
+	Previous ServerIdentity
+	Current  ServerIdentity
+}
+
+// ErrIdentityChanged is matched by IdentityChangeError.
+var ErrIdentityChanged = errors.New("Master identity changed")
+
+// IdentityChangeError is returned by StopOnIdentityChange.
+type IdentityChangeError struct {
+	IdentityChange
+}
+
+func (e *IdentityChangeError) Error() string {
+	return fmt.Sprintf("Master identity changed from server %d %s to server %d %s at %s:%d",
+		e.Previous.ServerID, e.Previous.UUID, e.Current.ServerID, e.Current.UUID, e.Position.File, e.Position.Offset)
+}
+
+// Is makes the error match ErrIdentityChanged.
+func (e *IdentityChangeError) Is(target error) bool {
+	return target == ErrIdentityChanged
+}
+
+// WithIdentityCheck sets a function that is called from ReadEvent when the
+// server writing the binary log changes. The server is identified by the
+// server ID of format description events, which every dump and binary log
+// file starts with, and by the server_uuid queried on every connection. If
+// the function returns an error reading fails with it, consumers could then
+// take a new snapshot. Reading continues otherwise.
+func WithIdentityCheck(fn func(IdentityChange) error) Option {
+	return func(r *Reader) {
+		r.identityCheck = fn
+	}
+}
+
+// StopOnIdentityChange fails reading with IdentityChangeError once the server
+// changes. It could be passed to WithIdentityCheck.
+func StopOnIdentityChange(c IdentityChange) error {
+	return &IdentityChangeError{IdentityChange: c}
+}
+
+// Identity returns the identity of the server that wrote the last format
+// description event read. It's only tracked with WithIdentityCheck.
+func (r *Reader) Identity() ServerIdentity {
+	return r.identity
+}
+
+// queryServerUUID remembers the UUID of the server the connection is
+// established to.
+func (r *Reader) queryServerUUID(ctx context.Context, conn *driver.Conn) error {
+	if r.identityCheck == nil {
+		return nil
+	}
+	vars, err := conn.GetVarsContext(ctx, "server_uuid")
+	if err != nil {
+		return errors.Annotate(err, "query server UUID")
+	}
+	r.serverUUID = vars["server_uuid"]
+	return nil
+}
+
+// checkIdentity compares the identity of the server that wrote the format
+// description event with the previously seen one.
+func (r *Reader) checkIdentity(h binlog.EventHeader) error {
+	if r.identityCheck == nil || h.Type != binlog.EventTypeFormatDescription {
+		return nil
+	}
+	id := ServerIdentity{ServerID: h.ServerID, UUID: r.serverUUID}
+	prev := r.identity
+	if prev.matches(id) {
+		if id.UUID == "" {
+			id.UUID = prev.UUID
+		}
+		r.identity = id
+		return nil
+	}
+	r.identity = id
+	if prev == (ServerIdentity{}) {
+		return nil
+	}
+	return r.identityCheck(IdentityChange{Position: r.state, Previous: prev, Current: id})
+}
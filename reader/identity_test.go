@@ -0,0 +1,47 @@
+package reader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/juju/errors"
+)
+
+func TestIdentityCheck(t *testing.T) {
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.XID(1)
+	g.ServerID = 2
+	changeAt := g.Position()
+	g.FormatDescription()
+	g.XID(2)
+
+	var changes []IdentityChange
+	r := openGenerated(t, g, WithIdentityCheck(func(c IdentityChange) error {
+		changes = append(changes, c)
+		return StopOnIdentityChange(c)
+	}))
+
+	var err error
+	for {
+		_, err = r.ReadEvent(context.Background())
+		if err != nil {
+			break
+		}
+	}
+	if errors.Cause(err) == ErrEndOfLog {
+		t.Fatalf("Expected identity change to stop reading")
+	}
+	cerr, ok := err.(*IdentityChangeError)
+	if !ok || !cerr.Is(ErrIdentityChanged) {
+		t.Fatalf("Expected identity change error, got %v", err)
+	}
+	if len(changes) != 1 || changes[0].Position != changeAt ||
+		changes[0].Previous.ServerID != 1 || changes[0].Current.ServerID != 2 {
+		t.Errorf("Unexpected identity changes %+v", changes)
+	}
+	if id := r.Identity(); id.ServerID != 2 {
+		t.Errorf("Expected server 2, got %+v", id)
+	}
+}
@@ -0,0 +1,103 @@
+package reader
+
+import (
+	"encoding/json"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// eventJSON is the JSON representation of an event:
+//
+//	{
+//	  "type": "WriteRowsEventV2",
+//	  "timestamp": 1546300800,
+//	  "server_id": 1,
+//	  "position": {"file": "mysql-bin.000001", "offset": 1234},
+//	  "gtid": "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+//	  "schema": "shop",
+//	  "table": "orders",
+//	  "rows": [[1, "pending"]]
+//	}
+//
+// Position is the position right after the event. GTID is omitted when GTIDs
+// are disabled, schema, table and rows are only set for rows events. Update
+// events contain pairs of rows: a row before the update followed by the same
+// row after the update. Rows are arrays of values in column order, rows of
+// enhanced events are objects keyed by column names. Binary strings are
+// base64 encoded, JSON columns are embedded as is.
+// Field names are part of a stable schema and must not be changed.
+type eventJSON struct {
+	Type      string        `json:"type"`
+	Timestamp uint32        `json:"timestamp"`
+	ServerID  uint32        `json:"server_id"`
+	Position  *positionJSON `json:"position,omitempty"`
+	GTID      string        `json:"gtid,omitempty"`
+	Schema    string        `json:"schema,omitempty"`
+	Table     string        `json:"table,omitempty"`
+	Rows      interface{}   `json:"rows,omitempty"`
+}
+
+type positionJSON struct {
+	File   string `json:"file"`
+	Offset uint64 `json:"offset"`
+}
+
+var _ json.Marshaler = &Event{}
+
+// MarshalJSON returns the JSON encoding of the event. Rows events are decoded
+// in the process.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	ej := eventJSON{
+		Type:      e.Header.Type.String(),
+		Timestamp: e.Header.Timestamp,
+		ServerID:  e.Header.ServerID,
+		Position:  &positionJSON{File: e.EndPosition.File, Offset: e.EndPosition.Offset},
+	}
+	if e.GTID.GNO > 0 {
+		ej.GTID = e.GTID.String()
+	}
+	if e.Table != nil && binlog.RowsEventVersion(e.Header.Type) >= 0 {
+		re, err := e.DecodeRows()
+		if err != nil {
+			return nil, err
+		}
+		ej.Schema = e.Table.SchemaName
+		ej.Table = e.Table.TableName
+		rows := make([][]interface{}, len(re.Rows))
+		for i, row := range re.Rows {
+			rows[i] = make([]interface{}, len(row))
+			for j, val := range row {
+				rows[i][j] = jsonValue(val, mysql.ColumnType(e.Table.ColumnTypes[j]))
+			}
+		}
+		ej.Rows = rows
+	}
+	return json.Marshal(ej)
+}
+
+var _ json.Marshaler = &EnhancedRowsEvent{}
+
+// MarshalJSON returns the JSON encoding of the event. See Event.MarshalJSON
+// for details.
+func (e *EnhancedRowsEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{
+		Type:      e.Header.Type.String(),
+		Timestamp: e.Header.Timestamp,
+		ServerID:  e.Header.ServerID,
+		Schema:    e.Table.SchemaName,
+		Table:     e.Table.TableName,
+		Rows:      e.Rows,
+	})
+}
+
+// jsonValue returns a value that is marshaled according to its column type.
+func jsonValue(val interface{}, ct mysql.ColumnType) interface{} {
+	if b, ok := val.([]byte); ok && ct == mysql.ColumnTypeJSON {
+		if len(b) == 0 {
+			return nil
+		}
+		return json.RawMessage(b)
+	}
+	return val
+}
@@ -0,0 +1,50 @@
+package reader
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestEventMarshalJSON(t *testing.T) {
+	evt := &Event{
+		Header:      binlog.EventHeader{Type: binlog.EventTypeXID, Timestamp: 1546300800, ServerID: 1},
+		EndPosition: binlog.Position{File: "mysql-bin.000001", Offset: 1234},
+		GTID:        binlog.GTID{GNO: 23},
+	}
+	b, err := json.Marshal(evt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exp := `{"type":"XIDEvent","timestamp":1546300800,"server_id":1,` +
+		`"position":{"file":"mysql-bin.000001","offset":1234},` +
+		`"gtid":"00000000-0000-0000-0000-000000000000:23"}`
+	if string(b) != exp {
+		t.Errorf("Expected %s, got %s", exp, b)
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	testCases := []struct {
+		val interface{}
+		ct  mysql.ColumnType
+		exp string
+	}{
+		{[]byte(`{"a":1}`), mysql.ColumnTypeJSON, `{"a":1}`},
+		{[]byte{}, mysql.ColumnTypeJSON, `null`},
+		{[]byte("abc"), mysql.ColumnTypeBlob, `"YWJj"`},
+		{"abc", mysql.ColumnTypeVarchar, `"abc"`},
+		{uint32(5), mysql.ColumnTypeLong, `5`},
+	}
+	for _, tc := range testCases {
+		b, err := json.Marshal(jsonValue(tc.val, tc.ct))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(b) != tc.exp {
+			t.Errorf("Expected %s, got %s", tc.exp, b)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package reader
+
+import (
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+)
+
+// Option is a reader configuration option.
+type Option func(r *Reader)
+
+// WithRawMode enables raw passthrough mode. In this mode the reader only
+// decodes event headers and keeps track of format description and rotate
+// events. Event buffers are delivered untouched: checksums are requested from
+// the server and are not stripped, table maps are not maintained and rows
+// events are not linked to tables. It is meant for building relays and binary
+// log archivers.
+func WithRawMode() Option {
+	return func(r *Reader) {
+		r.rawMode = true
+	}
+}
+
+// WithChecksums makes the reader request event checksums from the server and
+// expose them as Event.Checksum, so that relays and archivers could preserve
+// them and verify events downstream. Checksums are not stripped from
+// Event.Buffer, Event.Body returns the buffer without the checksum for
+// decoders. Checksums of compressed events are dropped along with the
+// compressed payload.
+func WithChecksums() Option {
+	return func(r *Reader) {
+		r.checksums = true
+	}
+}
+
+// WithZeroCopy makes DecodeRows return string and blob values that reference
+// the event buffer instead of copies. Such values are only valid until the next
+// event is read, or until the event is released for events returned by
+// ReadBatch. It's meant for consumers that serialize rows right away.
+func WithZeroCopy() Option {
+	return func(r *Reader) {
+		r.zeroCopy = true
+	}
+}
+
+// WithTransactionArenas makes DecodeRows allocate rows of each transaction from
+// a single arena, which reduces garbage collection overhead for large
+// transactions. The arena is available as Event.Arena on rows events and on
+// the event that ends the transaction. Rows are valid until the consumer
+// releases the arena, which should be done once the transaction is processed.
+// Arenas that are not released are reclaimed by the garbage collector. Arenas
+// are not safe for concurrent use, Pipeline decodes rows without them.
+func WithTransactionArenas() Option {
+	return func(r *Reader) {
+		r.arenas = true
+	}
+}
+
+// WithTableMapSize sets the number of table descriptions kept in the table map
+// cache. Least recently used tables are evicted at the end of a statement when
+// the cache exceeds this size. Default size is 100.
+func WithTableMapSize(n int) Option {
+	return func(r *Reader) {
+		r.tableMapSize = n
+	}
+}
+
+// WithHeartbeatPeriod makes the server send heartbeat events when the binary
+// log is idle for the given period of time. Heartbeat events are delivered to
+// the receiver like any other event. Setting a heartbeat period allows Ping to
+// detect a stalled replication stream.
+func WithHeartbeatPeriod(d time.Duration) Option {
+	return func(r *Reader) {
+		r.heartbeatPeriod = d
+	}
+}
+
+// WithPositionCallback sets a function that is called with the current
+// position whenever the reader switches to another binary log file and on
+// every heartbeat event. The function is called from ReadEvent and should
+// return quickly.
+func WithPositionCallback(fn func(pos binlog.Position)) Option {
+	return func(r *Reader) {
+		r.onPosition = fn
+	}
+}
+
+// WithGTIDSet makes the reader start with the first transaction not contained
+// in the given GTID set. Reader keeps adding received transactions to the set
+// and uses it to resume reading after reconnecting.
+func WithGTIDSet(set binlog.GTIDSet) Option {
+	return func(r *Reader) {
+		r.gtidMode = true
+		r.executed = set.Clone()
+		r.state = binlog.Position{Offset: 4}
+	}
+}
+
+// WithSessionTimeouts overrides server side network timeouts of the replication
+// connection. By default net_read_timeout and net_write_timeout are set to an
+// hour and wait_timeout is set to a day so that the server doesn't close
+// connections of slow consumers or low traffic streams. Passing zero timeouts
+// keeps server defaults.
+func WithSessionTimeouts(t driver.SessionTimeouts) Option {
+	return func(r *Reader) {
+		r.sessionTimeouts = t
+	}
+}
+
+// WithSetupTimeout limits each command run once the connection is
+// established: configuring checksums and session variables, registering as a
+// replica and starting the dump. It's 30 seconds by default, zero disables
+// the limit. Connecting itself is limited by DialTimeout and HandshakeTimeout
+// of the connection config.
+func WithSetupTimeout(d time.Duration) Option {
+	return func(r *Reader) {
+		r.setupTimeout = d
+	}
+}
@@ -0,0 +1,115 @@
+package reader
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// DecodedEvent is an event delivered by a decode pipeline.
+type DecodedEvent struct {
+	*Event
+	// Rows is set for rows events.
+	Rows *binlog.RowsEvent
+	// Err is set if reading or decoding the event has failed.
+	Err error
+
+	done chan struct{}
+}
+
+// Pipeline reads events in the background and decodes rows events on a pool of
+// workers, so that network reads, header parsing and row decoding overlap.
+// Events are delivered in binary log order, bufSize limits the number of
+// events read ahead of the consumer. Events are detached from the
+// connection buffer and could be released once processed, see Event.Release.
+//
+// Rows are decoded concurrently, so transaction arenas are not used even if
+// the reader was created with WithTransactionArenas.
+//
+// Rows events that fail to decode are delivered with Err set. The channel is
+// closed after a read error is delivered or once the context is done. The
+// reader must not be used by the caller until then.
+func (r *Reader) Pipeline(ctx context.Context, workers, bufSize int) <-chan *DecodedEvent {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan *DecodedEvent, bufSize)
+	pending := make(chan *DecodedEvent, bufSize)
+	out := make(chan *DecodedEvent)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for de := range jobs {
+				de.decode()
+			}
+		}()
+	}
+
+	// Arenas are not safe for concurrent use
+	arenas := r.arenas
+	r.arenas = false
+
+	// Read events and queue them for decoding
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		defer func() { r.arenas = arenas }()
+		for {
+			evt, err := r.ReadEvent(ctx)
+			de := &DecodedEvent{Event: evt, Err: err, done: make(chan struct{})}
+			if err != nil {
+				close(de.done)
+			} else {
+				// Connection buffer is reused on the next read
+				evt.detach()
+				if binlog.RowsEventVersion(evt.Header.Type) < 0 {
+					close(de.done)
+				} else {
+					select {
+					case jobs <- de:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case pending <- de:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Deliver events in order once they're decoded
+	go func() {
+		defer close(out)
+		defer wg.Wait()
+		for de := range pending {
+			<-de.done
+			select {
+			case out <- de:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func (de *DecodedEvent) decode() {
+	defer close(de.done)
+	re, err := de.DecodeRows()
+	if err != nil {
+		de.Err = err
+		return
+	}
+	de.Rows = &re
+}
@@ -2,110 +2,548 @@ package reader
 
 import (
 	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Vivino/bocadillo/binlog"
 	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader/schema"
 	"github.com/juju/errors"
 )
 
 // Reader is a binary log reader.
 type Reader struct {
-	conn     *driver.Conn
-	state    binlog.Position
+	// dsns contains the primary host and failover hosts, dsn is the one
+	// currently in use
+	dsns []string
+	host int
+	dsn  string
+	conf driver.Config
+	conn *driver.Conn
+	// connErr is the error of the last failed attempt to connect, it's
+	// returned by reads until the connection is established again
+	connErr error
+	// dir is set when reading from binary log files, source is set when
+	// reading from files, captures or other event sources
+	dir    string
+	source EventSource
+	// capture records received events when set
+	capture *captureWriter
+	// keys decrypt encrypted binary log files
+	keys  KeyProvider
+	state binlog.Position
+	// commitPos is the end position of the last committed transaction
+	commitPos binlog.Position
+	// executed is the set of transactions received so far, it's used to
+	// resume reading when GTID based positioning is enabled and to skip
+	// transactions received again after reconnecting
+	executed binlog.GTIDSet
+	gtidMode bool
+	skipTxn  bool
 	format   binlog.FormatDescription
-	tableMap map[uint64]binlog.TableDescription
+	tableMap *tableMap
+	stats    *stats
+
+	rawMode      bool
+	checksums    bool
+	tableMapSize int
+	validate     bool
+	zeroCopy     bool
+	arenas       bool
+	// arena is used for rows of the current transaction
+	arena *binlog.Arena
+
+	heartbeatPeriod time.Duration
+	sessionTimeouts driver.SessionTimeouts
+	checkpoints     *checkpointer
+	reconnectPolicy reconnectPolicy
+	onPosition      func(binlog.Position)
+	onGTIDGap       func(GTIDGap)
+	catchUp         *catchUp
+	unknownTable    UnknownTableStrategy
+	resolveTable    TableResolver
+	fetchUnknown    descriptionFetcher
+	rewoundAt       *binlog.Position
+	closed          int32
+	// tableNames contains names of the tables which table map events were
+	// received, they are kept after restarts to fetch unknown tables
+	tableNames map[uint64]schema.TableName
+	// tableSchemas complete table descriptions lacking full metadata, in the
+	// order of precedence
+	tableSchemas []schemaSource
+	// filter holds the current *Filter
+	filter        atomic.Value
+	rowPredicates map[schema.TableName]RowPredicate
+	deadLetter    DeadLetterFunc
+	corruption    binlog.CorruptionHandler
+	// audit reports unsupported events, audited contains types reported
+	// already
+	audit   func(UnsupportedEvent) error
+	audited map[binlog.EventType]bool
+	// identityCheck is called when the server writing the binary log
+	// changes, identity is the last one seen and serverUUID is the UUID of
+	// the server of the current connection
+	identityCheck func(IdentityChange) error
+	identity      ServerIdentity
+	serverUUID    string
+	// reallocateServerID makes the reader pick a new server ID if another
+	// replica connects with the same one
+	reallocateServerID bool
+	onServerIDChange   func(prev, next uint32)
+	classifiers        []ErrorClassifier
+	setupTimeout       time.Duration
+
+	stop    stopConditions
+	stopped bool
+	gtid    binlog.GTID
 }
 
-// Event contains binlog event details.
+// Event contains binlog event details. Event buffers reference connection
+// buffer and are only valid until the next event is read.
 type Event struct {
 	Format binlog.FormatDescription
 	Header binlog.EventHeader
+	// Buffer contains the event after the header. The trailing checksum is
+	// only kept by readers created with WithChecksums or WithRawMode, use
+	// Body to pass the buffer to decoders.
 	Buffer []byte
 	Offset uint64
+	// EndPosition is the position right after this event.
+	EndPosition binlog.Position
+	// CommitPosition is the end position of the last transaction committed
+	// at or before this event. Resuming from this position is always safe.
+	CommitPosition binlog.Position
+	// GTID identifies the transaction the event belongs to. It's empty when
+	// GTIDs are disabled.
+	GTID binlog.GTID
+	// Raw contains the whole event including the header and the checksum,
+	// exactly as it was received from the server.
+	Raw []byte
+	// Checksum is the CRC32 checksum the event ends with, HasChecksum is set
+	// if it has one. It isn't verified. Servers only send checksums to
+	// readers created with WithChecksums or WithRawMode.
+	Checksum    uint32
+	HasChecksum bool
+	// Live is set for events received after the reader has caught up with
+	// the master, see WithCatchUp.
+	Live bool
+	// Arena is set when WithTransactionArenas option is used. It holds rows
+	// decoded from all events of the transaction and should be released once
+	// the event that ends the transaction is processed.
+	Arena *binlog.Arena
 
 	// Table is not empty for rows events
 	Table *binlog.TableDescription
+
+	stats    *stats
+	zeroCopy bool
+	// skip is set for events of transactions that were already received and
+	// for events of filtered tables
+	skip bool
+	// dropColumns contains indexes of columns removed from decoded rows
+	dropColumns []int
+	// rowPredicate selects decoded rows
+	rowPredicate RowPredicate
+	// deadLetter receives rows that failed to decode
+	deadLetter DeadLetterFunc
+	// corruption receives rows events that failed to decode
+	corruption binlog.CorruptionHandler
+	// rawBuf is set for detached events, it's returned to the pool on release
+	rawBuf *[]byte
+	// checksumKept is set if Buffer ends with the checksum
+	checksumKept bool
+	// decompressed is set if Buffer holds a decompressed copy of the event
+	// rather than a slice of Raw
+	decompressed bool
+}
+
+// defaultSessionTimeouts keep the server from closing connections of slow
+// consumers and low traffic streams.
+var defaultSessionTimeouts = driver.SessionTimeouts{
+	NetRead:  time.Hour,
+	NetWrite: time.Hour,
+	Wait:     24 * time.Hour,
 }
 
+// defaultSetupTimeout limits each command run after connecting, so that a
+// server that accepts connections but doesn't respond can't block forever.
+const defaultSetupTimeout = 30 * time.Second
+
 var (
-	// ErrUnknownTableID is returned when a table ID from a rows event is
-	// missing in the table map index.
+	// ErrUnknownTableID is matched by UnknownTableIDError.
 	ErrUnknownTableID = errors.New("Unknown table ID")
+	// ErrStreamStalled is matched by StreamStalledError.
+	ErrStreamStalled = errors.New("Replication stream stalled")
+	// ErrClosed is returned when reading from a closed reader.
+	ErrClosed = errors.New("Reader is closed")
+	// ErrEndOfLog is returned when the end of the binary log is reached in
+	// non-blocking mode, see driver.DumpFlagNonBlock.
+	ErrEndOfLog = errors.New("End of binary log reached")
+	// ErrTruncatedEvent is returned when an event is shorter than the length
+	// specified in its header.
+	ErrTruncatedEvent = errors.New("Truncated event")
 )
 
-// New creates a new binary log reader.
-func New(dsn string, sc driver.Config) (*Reader, error) {
-	conn, err := driver.Connect(dsn, sc)
-	if err != nil {
-		return nil, errors.Annotate(err, "establish connection")
-	}
+// UnknownTableIDError is returned when a table ID from a rows event is missing
+// in the table map index.
+type UnknownTableIDError struct {
+	TableID uint64
+}
 
+func (e *UnknownTableIDError) Error() string {
+	return fmt.Sprintf("Unknown table ID %d", e.TableID)
+}
+
+// Is makes the error match ErrUnknownTableID.
+func (e *UnknownTableIDError) Is(target error) bool {
+	return target == ErrUnknownTableID
+}
+
+// StreamStalledError is returned by Ping when no events or heartbeats were
+// received for too long.
+type StreamStalledError struct {
+	// Idle is the time since the last packet was received.
+	Idle time.Duration
+}
+
+func (e *StreamStalledError) Error() string {
+	return fmt.Sprintf("Replication stream stalled, nothing received for %s", e.Idle)
+}
+
+// Is makes the error match ErrStreamStalled.
+func (e *StreamStalledError) Is(target error) bool {
+	return target == ErrStreamStalled
+}
+
+// New creates a new binary log reader.
+func New(dsn string, sc driver.Config, opts ...Option) (*Reader, error) {
 	r := &Reader{
-		conn: conn,
+		dsn:  dsn,
+		dsns: []string{dsn},
+		conf: sc,
 		state: binlog.Position{
 			File:   sc.File,
 			Offset: uint64(sc.Offset),
 		},
+		stats:           newStats(),
+		sessionTimeouts: defaultSessionTimeouts,
+		setupTimeout:    defaultSetupTimeout,
 	}
-	r.initTableMap()
-
-	if err := conn.DisableChecksum(); err != nil {
-		return nil, errors.Annotate(err, "disable binlog checksum")
+	for _, opt := range opts {
+		opt(r)
 	}
-	if err := conn.RegisterSlave(); err != nil {
-		return nil, errors.Annotate(err, "register replica server")
+	r.tableMap = newTableMap(r.tableMapSize)
+	if r.checkpoints != nil {
+		pos, ok, err := r.checkpoints.store.Load(context.Background())
+		if err != nil {
+			return nil, errors.Annotate(err, "load checkpoint")
+		}
+		if ok {
+			r.state = pos
+		}
 	}
-	if err := conn.StartBinlogDump(); err != nil {
-		return nil, errors.Annotate(err, "start binlog dump")
+	if r.executed == nil {
+		r.executed = binlog.NewGTIDSet()
 	}
+	r.commitPos = r.state
+	r.stats.setPosition(r.state)
 
+	if r.validate {
+		var rep *ValidationReport
+		err := r.setup(context.Background(), func(ctx context.Context) (err error) {
+			rep, err = ValidateContext(ctx, r.dsn, r.conf)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Annotate(err, "validate server configuration")
+		}
+		if err := rep.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.connect(context.Background()); err != nil {
+		return nil, err
+	}
 	return r, nil
 }
 
-// ReadEvent reads next event from the binary log.
+// connect establishes a new connection and starts a binary log dump from the
+// current position or GTID set if it's set.
+func (r *Reader) connect(ctx context.Context) error {
+	if r.dir != "" {
+		return r.openFile()
+	}
+	if r.source != nil {
+		return ErrReplaySeek
+	}
+	conf := r.conf
+	conf.File = r.state.File
+	conf.Offset = uint32(r.state.Offset)
+
+	conn, err := driver.ConnectContext(ctx, r.dsn, conf)
+	if err != nil {
+		return errors.Annotate(err, "establish connection")
+	}
+
+	if r.rawMode || r.checksums {
+		err = r.setup(ctx, conn.EnableChecksumContext)
+	} else {
+		err = r.setup(ctx, conn.DisableChecksumContext)
+	}
+	if err != nil {
+		conn.Close()
+		return errors.Annotate(err, "configure binlog checksum")
+	}
+	err = r.setup(ctx, func(ctx context.Context) error {
+		return conn.SetSessionTimeouts(ctx, r.sessionTimeouts)
+	})
+	if err != nil {
+		conn.Close()
+		return errors.Annotate(err, "set session timeouts")
+	}
+	err = r.setup(ctx, func(ctx context.Context) error {
+		return r.queryServerUUID(ctx, conn)
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if r.heartbeatPeriod > 0 {
+		err = r.setup(ctx, func(ctx context.Context) error {
+			return conn.SetHeartbeatPeriodContext(ctx, r.heartbeatPeriod)
+		})
+		if err != nil {
+			conn.Close()
+			return errors.Annotate(err, "set heartbeat period")
+		}
+	}
+	if err := r.setup(ctx, conn.RegisterSlaveContext); err != nil {
+		conn.Close()
+		return errors.Annotate(err, "register replica server")
+	}
+	if r.gtidMode {
+		err = r.setup(ctx, func(ctx context.Context) error {
+			return conn.StartBinlogDumpGTIDContext(ctx, r.executed.Encode())
+		})
+	} else {
+		err = r.setup(ctx, conn.StartBinlogDumpContext)
+	}
+	if err != nil {
+		conn.Close()
+		return errors.Annotate(err, "start binlog dump")
+	}
+
+	r.conn = conn
+	r.stats.connected()
+	return nil
+}
+
+// setup runs a command configuring the connection or starting the dump,
+// giving up once the setup timeout elapses.
+func (r *Reader) setup(ctx context.Context, cmd func(ctx context.Context) error) error {
+	if r.setupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.setupTimeout)
+		defer cancel()
+	}
+	return cmd(ctx)
+}
+
+// Seek restarts the binary log dump at the given position. Reader options and
+// counters are retained. It must not be called concurrently with ReadEvent.
+func (r *Reader) Seek(pos binlog.Position) error {
+	r.gtidMode = false
+	r.executed = binlog.NewGTIDSet()
+	r.resetCatchUp()
+	return r.restart(context.Background(), pos)
+}
+
+// SeekGTID restarts the binary log dump so that it begins with the first
+// transaction not contained in the given GTID set. Reader options and counters
+// are retained. It must not be called concurrently with ReadEvent.
+func (r *Reader) SeekGTID(set binlog.GTIDSet) error {
+	r.gtidMode = true
+	r.executed = set.Clone()
+	r.resetCatchUp()
+	// Position is reported by the server with the first artificial rotate
+	// event
+	return r.restart(context.Background(), binlog.Position{Offset: 4})
+}
+
+func (r *Reader) restart(ctx context.Context, pos binlog.Position) error {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+	r.state = pos
+	r.commitPos = pos
+	r.stopped = false
+	r.skipTxn = false
+	r.format = binlog.FormatDescription{}
+	r.tableMap = newTableMap(r.tableMapSize)
+	r.stats.setPosition(r.state)
+	r.connErr = r.connect(ctx)
+	return r.connErr
+}
+
+// ReadEvent reads next event from the binary log. Transactions that were
+// already received, which could happen after reconnecting, are skipped.
 func (r *Reader) ReadEvent(ctx context.Context) (*Event, error) {
-	connBuff, err := r.conn.ReadPacket(ctx)
+	for {
+		evt, err := r.readEvent(ctx)
+		if err != nil || !evt.skip {
+			return evt, err
+		}
+	}
+}
+
+func (r *Reader) readEvent(ctx context.Context) (*Event, error) {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return nil, ErrClosed
+	}
+	if r.stopped || r.stop.reachedPosition(r.state) {
+		r.stopped = true
+		return nil, ErrStopReached
+	}
+
+	connBuff, err := r.readPacket(ctx)
 	if err != nil {
-		return nil, errors.Annotate(err, "read next event")
+		if atomic.LoadInt32(&r.closed) == 1 {
+			return nil, ErrClosed
+		}
+		if r.shouldReconnect(ctx, err) {
+			if err := r.reconnect(ctx); err != nil {
+				return nil, err
+			}
+			return r.readEvent(ctx)
+		}
+		if err := r.handleServerIDConflict(ctx, err); err != nil {
+			return nil, errors.Annotate(err, "read next event")
+		}
+		return r.readEvent(ctx)
+	}
+	if connBuff == nil {
+		return nil, ErrEndOfLog
+	}
+	if r.capture != nil {
+		if err := r.capture.record(r.state, connBuff, time.Now()); err != nil {
+			return nil, errors.Annotate(err, "capture event")
+		}
 	}
 
-	evt := Event{Format: r.format, Offset: r.state.Offset}
+	evt := Event{Format: r.format, Offset: r.state.Offset, Raw: connBuff, stats: r.stats, zeroCopy: r.zeroCopy}
 	if err := evt.Header.Decode(connBuff, r.format); err != nil {
+		r.stats.decodeError()
 		return nil, errors.Annotate(err, "decode event header")
 	}
+	if err := r.checkConsistency(evt.Header, len(connBuff)); err != nil {
+		r.stats.decodeError()
+		return nil, err
+	}
+	if err := r.checkIdentity(evt.Header); err != nil {
+		return nil, err
+	}
+	r.stats.eventReceived(evt.Header, len(connBuff))
+	if r.catchUp != nil {
+		evt.Live = r.catchUp.check(evt.Header)
+	}
+	if r.stop.reachedTime(evt.Header) {
+		r.stopped = true
+		return nil, ErrStopReached
+	}
 	if evt.Header.NextOffset > 0 {
 		r.state.Offset = uint64(evt.Header.NextOffset)
 	}
+	defer func() { r.stats.setPosition(r.state) }()
+	if err := r.auditEvent(&evt); err != nil {
+		return nil, err
+	}
 
 	evt.Buffer = connBuff[r.format.HeaderLen():]
+	body := evt.Buffer
 	csa := r.format.ServerDetails.ChecksumAlgorithm
 	if evt.Header.Type != binlog.EventTypeFormatDescription && csa == binlog.ChecksumAlgorithmCRC32 {
 		// Remove trailing CRC32 checksum, we're not going to verify it
-		evt.Buffer = evt.Buffer[:len(evt.Buffer)-4]
+		body = body[:len(body)-4]
+		evt.setChecksum(csa)
+	}
+	if r.rawMode {
+		evt.checksumKept = evt.HasChecksum
+		if err := r.trackFormat(&evt, body); err != nil {
+			return nil, err
+		}
+		r.checkStopGTID(evt.Header.Type, body)
+		r.trackCommit(&evt, body)
+		r.notifyPosition(evt.Header.Type)
+		return &evt, nil
+	}
+	evt.Buffer = body
+	// Checksums of compressed events are not kept, since the buffer holds
+	// the decompressed event
+	keepChecksum := r.checksums && evt.HasChecksum && !binlog.IsCompressed(evt.Header.Type)
+	if binlog.IsCompressed(evt.Header.Type) {
+		// Compressed events are delivered as regular query and rows events
+		et, buf, err := binlog.Decompress(evt.Header.Type, evt.Buffer, r.format)
+		if err == nil {
+			evt.Header.Type, evt.Buffer = et, buf
+			evt.decompressed = true
+		} else {
+			r.stats.decodeError()
+			if err := r.deadLetterEvent(&evt, errors.Annotate(err, "decompress event")); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	switch evt.Header.Type {
 	case binlog.EventTypeFormatDescription:
 		var fde binlog.FormatDescriptionEvent
 		if err := fde.Decode(evt.Buffer); err != nil {
+			r.stats.decodeError()
 			return nil, errors.Annotate(err, "decode format description event")
 		}
 		r.format = fde.FormatDescription
 		evt.Format = fde.FormatDescription
+		evt.setChecksum(fde.ServerDetails.ChecksumAlgorithm)
 
 	case binlog.EventTypeRotate:
 		var re binlog.RotateEvent
 		if err := re.Decode(evt.Buffer, r.format); err != nil {
+			r.stats.decodeError()
 			return nil, errors.Annotate(err, "decode rotate event")
 		}
 		r.state = re.NextFile
 
+	case binlog.EventTypeHeartbeatV2:
+		if err := r.trackHeartbeat(evt.Buffer); err != nil {
+			return nil, err
+		}
+
 	case binlog.EventTypeTableMap:
 		var tme binlog.TableMapEvent
 		if err := tme.Decode(evt.Buffer, r.format); err != nil {
-			return nil, errors.Annotate(err, "decode table map event")
+			r.stats.decodeError()
+			if err := r.deadLetterEvent(&evt, errors.Annotate(err, "decode table map event")); err != nil {
+				return nil, err
+			}
+			break
 		}
-		r.tableMap[tme.TableID] = tme.TableDescription
+		if len(r.tableSchemas) > 0 {
+			if err := r.completeTable(&tme.TableDescription); err != nil {
+				return nil, err
+			}
+		}
+		r.tableMap.add(tme.TableID, tme.TableDescription)
+		if r.fetchUnknown != nil {
+			r.tableNames[tme.TableID] = schema.TableName{
+				Database: tme.SchemaName,
+				Table:    tme.TableName,
+			}
+		}
+		r.applyFilter(&evt, tme.TableDescription)
 
 	case binlog.EventTypeWriteRowsV0,
 		binlog.EventTypeWriteRowsV1,
@@ -119,26 +557,73 @@ func (r *Reader) ReadEvent(ctx context.Context) (*Event, error) {
 
 		re := binlog.RowsEvent{Type: evt.Header.Type}
 		tableID, flags := re.PeekTableIDAndFlags(evt.Buffer, r.format)
-		td, ok := r.tableMap[tableID]
-		if !ok {
-			return nil, ErrUnknownTableID
+		if td, ok := r.tableMap.get(tableID); ok {
+			evt.Table = &td
+		} else {
+			td, err := r.unknownTableID(ctx, tableID)
+			if err == errRewound {
+				return r.readEvent(ctx)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if td == nil {
+				evt.skip = true
+			}
+			evt.Table = td
+		}
+		if evt.Table != nil {
+			r.applyFilter(&evt, *evt.Table)
+			evt.rowPredicate = r.rowPredicate(*evt.Table)
 		}
-		evt.Table = &td
+		evt.deadLetter = r.deadLetter
+		evt.corruption = r.corruption
 
-		// Throttle table map clearing. This flag could be part of every single
-		// rows event
-		if binlog.RowsFlagEndOfStatement&flags > 0 && len(r.tableMap) > 100 {
-			// Clear table map
-			r.initTableMap()
+		if binlog.RowsFlagEndOfStatement&flags > 0 {
+			r.tableMap.endStatement()
+		}
+		if r.arenas {
+			if r.arena == nil {
+				r.arena = binlog.NewArena()
+			}
+			evt.Arena = r.arena
 		}
 	case binlog.EventTypeQuery:
-		// Can be decoded by the receiver
+		if len(r.tableSchemas) > 0 {
+			if err := r.processQuery(&evt); err != nil {
+				return nil, err
+			}
+		}
+	case binlog.EventTypeMariaDBStartEncryption:
+		var se binlog.MariaDBStartEncryptionEvent
+		if err := se.Decode(evt.Buffer); err != nil {
+			r.stats.decodeError()
+			return nil, errors.Annotate(err, "decode start encryption event")
+		}
+		return nil, errors.Annotatef(binlog.ErrEncryptedBinlog, "binary log %s is encrypted with key version %d",
+			r.state.File, se.KeyVersion)
 	case binlog.EventTypeXID:
 		// Can be decoded by the receiver
-	case binlog.EventTypeGTID:
-		// TODO: Add support
+	case binlog.EventTypeGTID, binlog.EventTypeGTIDTagged:
+		ge, err := binlog.DecodeGTIDEvent(evt.Header.Type, evt.Buffer)
+		if err != nil {
+			r.stats.decodeError()
+			if err := r.deadLetterEvent(&evt, errors.Annotate(err, "decode gtid event")); err != nil {
+				return nil, err
+			}
+			break
+		}
+		r.beginTransaction(ge.GTID)
 	}
 
+	r.checkStopGTID(evt.Header.Type, evt.Buffer)
+	r.trackCommit(&evt, evt.Buffer)
+	r.notifyPosition(evt.Header.Type)
+
+	if keepChecksum {
+		evt.Buffer = evt.Buffer[:len(evt.Buffer)+4]
+		evt.checksumKept = true
+	}
 	return &evt, err
 }
 
@@ -147,21 +632,302 @@ func (r *Reader) State() binlog.Position {
 	return r.state
 }
 
-// Close underlying database connection.
-func (r *Reader) Close() error {
-	return r.conn.Close()
+// GTIDSet returns a copy of the set of transactions received so far. When GTID
+// based positioning is used it includes the initial set.
+func (r *Reader) GTIDSet() binlog.GTIDSet {
+	return r.executed.Clone()
+}
+
+// Format returns the format of the binary log as described by the last format
+// description event: server version, binary log version, checksum algorithm
+// and header lengths. It's empty until the first format description event is
+// read.
+func (r *Reader) Format() binlog.FormatDescription {
+	fd := r.format
+	fd.EventTypeHeaderLengths = append([]uint8(nil), fd.EventTypeHeaderLengths...)
+	return fd
+}
+
+// TableMap returns a snapshot of the table map: table descriptions indexed by
+// table IDs that are currently known to the reader.
+func (r *Reader) TableMap() map[uint64]binlog.TableDescription {
+	return r.tableMap.snapshot()
+}
+
+// ServerInfo returns details about the server the reader is connected to. It's
+// empty when reading from a file.
+func (r *Reader) ServerInfo() driver.ServerInfo {
+	if r.conn == nil {
+		return driver.ServerInfo{}
+	}
+	return r.conn.ServerInfo()
 }
 
-func (r *Reader) initTableMap() {
-	r.tableMap = make(map[uint64]binlog.TableDescription)
+// Stats returns a snapshot of reader counters. It is safe to call concurrently
+// with ReadEvent.
+func (r *Reader) Stats() Stats {
+	return r.stats.snapshot()
+}
+
+// Ping checks liveness of the replication stream. If a heartbeat period is
+// configured the stream is considered alive as long as events or heartbeats
+// keep arriving, otherwise a separate connection is established to check that
+// the server is reachable. It is safe to call concurrently with ReadEvent.
+func (r *Reader) Ping(ctx context.Context) error {
+	if r.heartbeatPeriod > 0 {
+		// Allow for one missed heartbeat
+		if idle := r.stats.sinceLastPacket(); idle > 2*r.heartbeatPeriod {
+			return &StreamStalledError{Idle: idle}
+		}
+		return nil
+	}
+
+	conn, err := driver.ConnectContext(ctx, r.dsn, r.conf)
+	if err != nil {
+		return errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+	return conn.Ping(ctx)
+}
+
+// Checkpoint marks the given position as processed. The position is saved to
+// the checkpoint store on Flush or Close. It is safe to call concurrently with
+// ReadEvent.
+func (r *Reader) Checkpoint(pos binlog.Position) {
+	if r.checkpoints != nil {
+		r.checkpoints.set(pos)
+	}
+}
+
+// Flush saves pending checkpoint to the checkpoint store.
+func (r *Reader) Flush(ctx context.Context) error {
+	if r.checkpoints == nil {
+		return nil
+	}
+	return errors.Annotate(r.checkpoints.flush(ctx), "flush checkpoint")
+}
+
+// Close flushes pending checkpoint and closes the connection gracefully by
+// sending a QUIT command to the server. It could be called concurrently with
+// ReadEvent in which case ReadEvent is unblocked and returns ErrClosed. If the
+// context expires before the connection is closed it is closed forcefully.
+func (r *Reader) Close(ctx context.Context) error {
+	atomic.StoreInt32(&r.closed, 1)
+	err := r.Flush(ctx)
+	if r.source != nil {
+		if cerr := r.source.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	// Connection is not set if reconnecting failed
+	if r.conn == nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	conn := r.conn
+	go func() { done <- conn.Close() }()
+	select {
+	case cerr := <-done:
+		if err == nil {
+			err = cerr
+		}
+	case <-ctx.Done():
+		conn.Abort()
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// trackFormat processes events that affect the format and the position of the
+// binary log, it is used in raw mode when other events are left untouched.
+func (r *Reader) trackFormat(evt *Event, body []byte) error {
+	switch evt.Header.Type {
+	case binlog.EventTypeFormatDescription:
+		var fde binlog.FormatDescriptionEvent
+		if err := fde.Decode(body); err != nil {
+			r.stats.decodeError()
+			return errors.Annotate(err, "decode format description event")
+		}
+		r.format = fde.FormatDescription
+		evt.Format = fde.FormatDescription
+		evt.setChecksum(fde.ServerDetails.ChecksumAlgorithm)
+	case binlog.EventTypeRotate:
+		var re binlog.RotateEvent
+		if err := re.Decode(body, r.format); err != nil {
+			r.stats.decodeError()
+			return errors.Annotate(err, "decode rotate event")
+		}
+		r.state = re.NextFile
+	case binlog.EventTypeHeartbeatV2:
+		return r.trackHeartbeat(body)
+	case binlog.EventTypeGTID, binlog.EventTypeGTIDTagged:
+		ge, err := binlog.DecodeGTIDEvent(evt.Header.Type, body)
+		if err != nil {
+			r.stats.decodeError()
+			return errors.Annotate(err, "decode gtid event")
+		}
+		r.beginTransaction(ge.GTID)
+	}
+	return nil
+}
+
+// trackHeartbeat sets the position to the one reported by a heartbeat v2
+// event. Unlike other events, these carry positions beyond 4GB.
+func (r *Reader) trackHeartbeat(body []byte) error {
+	var he binlog.HeartbeatEvent
+	if err := he.DecodeV2(body); err != nil {
+		r.stats.decodeError()
+		return errors.Annotate(err, "decode heartbeat event")
+	}
+	if he.Position.File != "" {
+		r.state = he.Position
+	}
+	return nil
+}
+
+// checkStopGTID stops the reader after the last event of the stop transaction
+// is delivered.
+func (r *Reader) checkStopGTID(et binlog.EventType, body []byte) {
+	if r.stop.reachedGTID(et, body, r.gtid) {
+		r.stopped = true
+	}
+}
+
+// beginTransaction is called on GTID events. Transactions that were already
+// received are skipped.
+func (r *Reader) beginTransaction(gtid binlog.GTID) {
+	r.gtid = gtid
+	r.skipTxn = r.executed.ContainsGTID(gtid)
+}
+
+// trackCommit sets event end and commit positions.
+func (r *Reader) trackCommit(evt *Event, body []byte) {
+	evt.skip = evt.skip || r.skipTxn
+	switch {
+	case evt.Header.Type == binlog.EventTypeRotate:
+		r.commitPos = r.state
+	case endsTransaction(evt.Header.Type, body):
+		r.commitPos = r.state
+		evt.Arena = r.arena
+		r.arena = nil
+		if r.gtid.GNO > 0 {
+			r.checkGTIDGap(r.gtid)
+			r.executed.AddGTID(r.gtid)
+		}
+		r.skipTxn = false
+	}
+	evt.EndPosition = r.state
+	evt.CommitPosition = r.commitPos
+	evt.GTID = r.gtid
+}
+
+// notifyPosition calls position callback on events that report the position
+// in the binary log.
+func (r *Reader) notifyPosition(et binlog.EventType) {
+	if r.onPosition == nil {
+		return
+	}
+	switch et {
+	case binlog.EventTypeRotate, binlog.EventTypeHeartbeet, binlog.EventTypeHeartbeatV2:
+		r.onPosition(r.state)
+	}
+}
+
+// rawPool contains buffers of detached events.
+var rawPool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// setChecksum sets the checksum of the event if the algorithm appends one.
+func (e *Event) setChecksum(csa binlog.ChecksumAlgorithm) {
+	if csa == binlog.ChecksumAlgorithmCRC32 && len(e.Raw) >= 4 {
+		e.Checksum = binary.LittleEndian.Uint32(e.Raw[len(e.Raw)-4:])
+		e.HasChecksum = true
+	}
+}
+
+// Body returns the event buffer without the trailing checksum, which is what
+// event decoders expect. It only differs from Buffer for readers created with
+// WithChecksums or WithRawMode.
+func (e Event) Body() []byte {
+	if e.checksumKept {
+		return e.Buffer[:len(e.Buffer)-4]
+	}
+	return e.Buffer
+}
+
+// detach copies event buffers so that the event remains valid after the next
+// event is read.
+func (e *Event) detach() {
+	e.rawBuf = rawPool.Get().(*[]byte)
+	raw := append((*e.rawBuf)[:0], e.Raw...)
+	// Buffer is a slice of the raw event that starts after the header, unless
+	// it was decompressed into a buffer of its own
+	if !e.decompressed {
+		start := cap(e.Raw) - cap(e.Buffer)
+		e.Buffer = raw[start : start+len(e.Buffer)]
+	}
+	e.Raw = raw
+}
+
+// Release returns buffers of an event returned by ReadBatch to a pool so that
+// they could be reused by subsequent batches. The event and values decoded
+// from it without copying must not be used after it is released. Calling
+// Release is optional and has no effect on events returned by ReadEvent.
+func (e *Event) Release() {
+	if e.rawBuf == nil {
+		return
+	}
+	*e.rawBuf = e.Raw[:0]
+	rawPool.Put(e.rawBuf)
+	e.rawBuf = nil
+	e.Raw = nil
+	e.Buffer = nil
 }
 
 // DecodeRows decodes buffer into a rows event.
 func (e Event) DecodeRows() (binlog.RowsEvent, error) {
-	re := binlog.RowsEvent{Type: e.Header.Type}
+	re := binlog.RowsEvent{Type: e.Header.Type, ZeroCopy: e.zeroCopy, Arena: e.Arena, CorruptionHandler: e.corruption}
 	if binlog.RowsEventVersion(e.Header.Type) < 0 {
 		return re, errors.New("invalid rows event")
 	}
-	err := re.Decode(e.Buffer, e.Format, *e.Table)
+	err := re.Decode(e.Body(), e.Format, *e.Table)
+	if err != nil && e.deadLetter != nil {
+		if e.stats != nil {
+			e.stats.decodeError()
+		}
+		return binlog.RowsEvent{Type: e.Header.Type}, sendDeadLetter(e.deadLetter, &e, err)
+	}
+	if err == nil && e.rowPredicate != nil {
+		selectRows(&re, *e.Table, e.rowPredicate)
+	}
+	if err == nil && len(e.dropColumns) > 0 {
+		dropColumns(&re, e.dropColumns)
+	}
+	if e.stats != nil {
+		if err != nil {
+			e.stats.decodeError()
+		} else {
+			e.stats.rowsDecoded(len(re.Rows))
+		}
+	}
 	return re, err
 }
+
+// DecodeDDL decodes buffer into a query event and classifies the query as a
+// table definition statement. If the event is not a query event or the query
+// is not a table definition statement false is returned.
+func (e Event) DecodeDDL() (schema.Statement, bool) {
+	if e.Header.Type != binlog.EventTypeQuery {
+		return nil, false
+	}
+	var qe binlog.QueryEvent
+	if err := qe.Decode(e.Body()); err != nil {
+		return nil, false
+	}
+	return schema.ParseDDL(string(qe.Schema), string(qe.Query))
+}
@@ -0,0 +1,128 @@
+package reader
+
+import (
+	"context"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// reconnectPolicy describes how connection loss is handled.
+type reconnectPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// WithReconnect makes the reader reconnect automatically when the connection
+// is lost. Up to maxAttempts connection attempts are made waiting for backoff
+// between them. Reading is resumed from the end of the last committed
+// transaction, events of a partially read transaction are delivered again. If
+// the binary log was rotated and that position is past the end of the file it
+// refers to, reading is resumed from the beginning of the next file. Only
+// transient errors are retried, see WithErrorClassifier.
+func WithReconnect(maxAttempts int, backoff time.Duration) Option {
+	return func(r *Reader) {
+		r.reconnectPolicy = reconnectPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+// WithFailover adds hosts the reader fails over to when the connection is
+// lost, it must be used together with WithReconnect. Each reconnection attempt
+// is made to the next host in the list, starting with the first failover host.
+// Binary log file names and positions differ between hosts, so failover
+// requires GTID based positioning: the reader must be started with
+// WithGTIDSet or positioned with SeekGTID.
+func WithFailover(dsns ...string) Option {
+	return func(r *Reader) {
+		r.dsns = append(r.dsns, dsns...)
+	}
+}
+
+// reconnect re-establishes the connection and restarts the binary log dump.
+// If GTID based positioning is used the dump is restarted with the set of
+// transactions received so far, otherwise it's restarted from the last commit
+// position. Attempts stop at the first permanent error.
+func (r *Reader) reconnect(ctx context.Context) error {
+	var err error
+	for i := 0; i < r.reconnectPolicy.maxAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(r.reconnectPolicy.backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if len(r.dsns) > 1 && r.gtidMode {
+			r.host = (r.host + 1) % len(r.dsns)
+			r.dsn = r.dsns[r.host]
+		}
+		if err = r.resume(ctx); err == nil {
+			return nil
+		}
+		if r.classify(err) == ErrorPermanent {
+			break
+		}
+	}
+	return errors.Annotate(err, "reconnect")
+}
+
+// resume restarts the dump where reading should continue once the connection
+// is lost.
+func (r *Reader) resume(ctx context.Context) error {
+	if r.gtidMode {
+		// Position is reported by the server with the first artificial
+		// rotate event
+		return r.restart(ctx, binlog.Position{Offset: 4})
+	}
+	pos, err := r.resumePosition(ctx)
+	if err != nil {
+		return err
+	}
+	return r.restart(ctx, pos)
+}
+
+// resumePosition returns the position reading should be resumed from, which is
+// the end of the last committed transaction. If the file of that position has
+// been rotated and the offset is past its end, the beginning of the next file
+// is returned.
+func (r *Reader) resumePosition(ctx context.Context) (binlog.Position, error) {
+	pos := r.commitPos
+	if pos.File == "" {
+		return pos, nil
+	}
+
+	conn, err := driver.ConnectContext(ctx, r.dsn, r.conf)
+	if err != nil {
+		return pos, errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+
+	logs, err := conn.BinaryLogsContext(ctx)
+	if err != nil {
+		return pos, errors.Annotate(err, "list binary logs")
+	}
+	for i, bl := range logs {
+		if bl.Name == pos.File && pos.Offset >= bl.Size && i+1 < len(logs) {
+			return binlog.Position{File: logs[i+1].Name, Offset: 4}, nil
+		}
+	}
+	return pos, nil
+}
+
+// shouldReconnect returns true if reconnecting is enabled and the given read
+// error is transient. Read timeouts configured for the connection are treated
+// as a sign of a hung connection, unlike timeouts caused by the context
+// deadline.
+func (r *Reader) shouldReconnect(ctx context.Context, err error) bool {
+	if r.reconnectPolicy.maxAttempts == 0 {
+		return false
+	}
+	if isTimeout(err) {
+		if dl, ok := ctx.Deadline(); ok && !time.Now().Before(dl) {
+			return false
+		}
+	}
+	return r.classify(err) == ErrorTransient
+}
@@ -0,0 +1,82 @@
+package reader
+
+import (
+	"context"
+
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// ErrorClass tells whether reconnecting could help with an error.
+type ErrorClass int
+
+// Error classes.
+const (
+	// ErrorUnclassified leaves the decision to the next classifier.
+	ErrorUnclassified ErrorClass = iota
+	// ErrorTransient is an error expected to clear up on its own: lost
+	// connections, network timeouts, server restarts, deadlocks.
+	ErrorTransient
+	// ErrorPermanent is an error that reconnecting won't fix: rejected
+	// credentials, invalid configuration, purged positions.
+	ErrorPermanent
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorTransient:
+		return "transient"
+	case ErrorPermanent:
+		return "permanent"
+	default:
+		return "unclassified"
+	}
+}
+
+// ErrorClassifier classifies errors. It returns ErrorUnclassified for errors
+// it knows nothing about.
+type ErrorClassifier func(err error) ErrorClass
+
+// WithErrorClassifier adds a classifier consulted before the default one,
+// ClassifyError, to decide whether the reader should reconnect after an error.
+// Classifiers are consulted in the order they were added, the first one to
+// classify an error wins. Errors that end reading or connection attempts are
+// passed as is, use errors.Cause to get the underlying error.
+func WithErrorClassifier(fn ErrorClassifier) Option {
+	return func(r *Reader) {
+		r.classifiers = append(r.classifiers, fn)
+	}
+}
+
+// ClassifyError is the default classifier. Errors reported by the server are
+// transient if driver.Error.Temporary says so, other errors are permanent.
+// Context cancellation is permanent. Everything else, such as closed
+// connections and network timeouts, is transient.
+func ClassifyError(err error) ErrorClass {
+	switch cause := errors.Cause(err).(type) {
+	case nil:
+		return ErrorUnclassified
+	case *driver.Error:
+		if cause.Temporary() {
+			return ErrorTransient
+		}
+		return ErrorPermanent
+	case *ServerIDConflictError:
+		return ErrorPermanent
+	}
+	if errors.Cause(err) == context.Canceled {
+		return ErrorPermanent
+	}
+	return ErrorTransient
+}
+
+// classify classifies the error with user classifiers, falling back to the
+// default one.
+func (r *Reader) classify(err error) ErrorClass {
+	for _, fn := range r.classifiers {
+		if c := fn(err); c != ErrorUnclassified {
+			return c
+		}
+	}
+	return ClassifyError(err)
+}
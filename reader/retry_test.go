@@ -0,0 +1,47 @@
+package reader
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		err error
+		exp ErrorClass
+	}{
+		{errors.Annotate(io.EOF, "read packet"), ErrorTransient},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, ErrorTransient},
+		{&driver.Error{Code: 1053, Message: "Server shutdown in progress"}, ErrorTransient},
+		{errors.Annotate(&driver.Error{Code: 1213, Message: "Deadlock found when trying to get lock"}, "query"), ErrorTransient},
+		{&driver.Error{Code: 1045, Message: "Access denied for user 'repl'@'localhost'"}, ErrorPermanent},
+		{&driver.Error{Code: 1236, Message: "Could not find first log file name in binary log index file"}, ErrorPermanent},
+		{context.Canceled, ErrorPermanent},
+	} {
+		if cl := ClassifyError(c.err); cl != c.exp {
+			t.Errorf("Expected %v to be %s, got %s", c.err, c.exp, cl)
+		}
+	}
+}
+
+func TestErrorClassifier(t *testing.T) {
+	r := &Reader{}
+	WithErrorClassifier(func(err error) ErrorClass {
+		if errors.Cause(err) == io.EOF {
+			return ErrorPermanent
+		}
+		return ErrorUnclassified
+	})(r)
+	if cl := r.classify(io.EOF); cl != ErrorPermanent {
+		t.Errorf("Expected classifier to make EOF permanent, got %s", cl)
+	}
+	// Unclassified errors fall back to the default classification
+	if cl := r.classify(&driver.Error{Code: 1040, Message: "Too many connections"}); cl != ErrorTransient {
+		t.Errorf("Expected too many connections to be transient, got %s", cl)
+	}
+}
@@ -0,0 +1,54 @@
+package reader
+
+import (
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader/schema"
+)
+
+// RowPredicate returns true if a decoded row should be delivered. Integer
+// values are passed as decoded, unsigned regardless of the column signedness.
+type RowPredicate func(table binlog.TableDescription, row []interface{}) bool
+
+// WithRowPredicate sets a predicate that selects rows of the given table.
+// Predicates are evaluated by DecodeRows, rows they reject are removed from
+// the decoded event. An update is delivered if either of its images is
+// selected, so that consumers notice rows leaving their selection. Predicates
+// see all columns, including the ones dropped by the filter.
+func WithRowPredicate(database, table string, fn RowPredicate) Option {
+	return func(r *Reader) {
+		if r.rowPredicates == nil {
+			r.rowPredicates = make(map[schema.TableName]RowPredicate)
+		}
+		r.rowPredicates[schema.TableName{Database: database, Table: table}] = fn
+	}
+}
+
+// rowPredicate returns the predicate of a table, if any.
+func (r *Reader) rowPredicate(td binlog.TableDescription) RowPredicate {
+	if r.rowPredicates == nil {
+		return nil
+	}
+	return r.rowPredicates[schema.TableName{Database: td.SchemaName, Table: td.TableName}]
+}
+
+// selectRows removes rows rejected by the predicate from a decoded event.
+func selectRows(re *binlog.RowsEvent, td binlog.TableDescription, fn RowPredicate) {
+	rows := re.Rows[:0]
+	for _, c := range re.Changes() {
+		switch c.Type {
+		case binlog.ChangeInsert:
+			if fn(td, c.After) {
+				rows = append(rows, c.After)
+			}
+		case binlog.ChangeUpdate:
+			if fn(td, c.Before) || fn(td, c.After) {
+				rows = append(rows, c.Before, c.After)
+			}
+		case binlog.ChangeDelete:
+			if fn(td, c.Before) {
+				rows = append(rows, c.Before)
+			}
+		}
+	}
+	re.Rows = rows
+}
@@ -0,0 +1,195 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ApplyFunc applies a single row change. It's called concurrently from
+// scheduler workers.
+type ApplyFunc func(ctx context.Context, c RowChange) error
+
+// Scheduler applies row changes on a pool of workers. Changes are partitioned
+// by table and primary key, so that changes of the same row are applied in
+// order by the same worker. Changes of tables with unknown primary keys are
+// partitioned by table only. Changes that move a row to another partition,
+// i.e. updates of the primary key, are applied alone once all previously
+// scheduled changes are applied.
+//
+// Transactions that carry a logical clock (MySQL 5.7+) are only applied
+// concurrently with the transactions they don't depend on according to the
+// clock, which keeps changes that depend on each other across rows, such as
+// foreign keys, in order. Transactions without a clock are ordered by the
+// primary key alone.
+//
+// Transactions are not applied atomically, the last scheduled transaction is
+// fully applied once Wait returns, which is when checkpoints should be saved.
+// Queries are not applied by the scheduler.
+type Scheduler struct {
+	apply   ApplyFunc
+	workers []chan RowChange
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// pending counts changes that are scheduled but not applied yet
+	pending sync.WaitGroup
+	mu      sync.Mutex
+	err     error
+
+	// firstSeq is the sequence number of the first transaction scheduled
+	// since all changes were last applied, zero if there is none
+	firstSeq int64
+	closed   bool
+	done     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler with the given number of workers.
+func NewScheduler(ctx context.Context, workers int, apply ApplyFunc) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Scheduler{
+		apply:   apply,
+		workers: make([]chan RowChange, workers),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	s.done.Add(workers)
+	for i := range s.workers {
+		s.workers[i] = make(chan RowChange, 64)
+		go s.work(s.workers[i])
+	}
+	return s
+}
+
+func (s *Scheduler) work(changes <-chan RowChange) {
+	defer s.done.Done()
+	for c := range changes {
+		if s.Err() == nil {
+			if err := s.apply(s.ctx, c); err != nil {
+				s.fail(err)
+			}
+		}
+		s.pending.Done()
+	}
+}
+
+func (s *Scheduler) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+		s.cancel()
+	}
+	s.mu.Unlock()
+}
+
+// Err returns the first error returned by the apply function. Once it fails
+// remaining changes are discarded.
+func (s *Scheduler) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Schedule queues changes of a transaction. It blocks while the workers are
+// busy and while the transaction depends on transactions that are not applied
+// yet. An error is returned if applying a previously scheduled change failed.
+// Schedule must not be called concurrently.
+func (s *Scheduler) Schedule(txn *Transaction) error {
+	if txn.SequenceNumber > 0 {
+		if s.firstSeq > 0 && txn.LastCommitted >= s.firstSeq {
+			if err := s.drain(); err != nil {
+				return err
+			}
+			s.firstSeq = 0
+		}
+		if s.firstSeq == 0 {
+			s.firstSeq = txn.SequenceNumber
+		}
+	}
+	err := txn.ForEachChange(func(c RowChange) error {
+		w, moved := s.partition(c)
+		if moved {
+			if err := s.drain(); err != nil {
+				return err
+			}
+		}
+		s.pending.Add(1)
+		select {
+		case s.workers[w] <- c:
+		case <-s.ctx.Done():
+			s.pending.Done()
+			if err := s.Err(); err != nil {
+				return err
+			}
+			return s.ctx.Err()
+		}
+		if moved {
+			return s.drain()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.Err()
+}
+
+// Wait blocks until all scheduled changes are applied. It must not be called
+// concurrently with Schedule.
+func (s *Scheduler) Wait() error {
+	err := s.drain()
+	s.firstSeq = 0
+	return err
+}
+
+// drain blocks until all scheduled changes are applied.
+func (s *Scheduler) drain() error {
+	s.pending.Wait()
+	return s.Err()
+}
+
+// Close waits for scheduled changes to be applied and stops the workers.
+func (s *Scheduler) Close() error {
+	err := s.Wait()
+	if !s.closed {
+		s.closed = true
+		for _, w := range s.workers {
+			close(w)
+		}
+		s.done.Wait()
+		s.cancel()
+	}
+	return err
+}
+
+// partition returns the worker for a change. Moved is set if images before
+// and after the change belong to different workers.
+func (s *Scheduler) partition(c RowChange) (w int, moved bool) {
+	n := len(s.workers)
+	switch c.Type {
+	case ChangeInsert:
+		return partitionKey(c, c.After) % n, false
+	case ChangeDelete:
+		return partitionKey(c, c.Before) % n, false
+	default:
+		before, after := partitionKey(c, c.Before)%n, partitionKey(c, c.After)%n
+		return after, before != after
+	}
+}
+
+// partitionKey hashes the table name and primary key values of a row.
+func partitionKey(c RowChange, row []interface{}) int {
+	td := c.Table
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s.%s", td.SchemaName, td.TableName)
+	for _, i := range td.PrimaryKey {
+		if i < len(row) {
+			fmt.Fprintf(h, "\x00%v", row[i])
+		}
+	}
+	return int(h.Sum32() & 0x7FFFFFFF)
+}
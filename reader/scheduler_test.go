@@ -0,0 +1,104 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestScheduler(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "orders",
+		ColumnCount: 2,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0, 0},
+		PrimaryKey:  []int{0},
+	}
+	insert := func(id, ver int) RowChange {
+		return RowChange{Type: ChangeInsert, Table: td, After: []interface{}{uint32(id), uint32(ver)}}
+	}
+	update := func(before, after, ver int) RowChange {
+		return RowChange{Type: ChangeUpdate, Table: td,
+			Before: []interface{}{uint32(before), uint32(ver - 1)}, After: []interface{}{uint32(after), uint32(ver)}}
+	}
+
+	// Find a key that belongs to another worker than key 1
+	moveTo := 2
+	for partitionKey(insert(moveTo, 1), insert(moveTo, 1).After)%4 == partitionKey(insert(1, 1), insert(1, 1).After)%4 {
+		moveTo++
+	}
+
+	tests := []struct {
+		name string
+		txns []*Transaction
+		// exp contains changes that must be applied in the given order
+		exp []RowChange
+	}{
+		{
+			name: "key order",
+			txns: []*Transaction{
+				{Changes: []RowChange{insert(1, 1), insert(2, 1), insert(3, 1)}},
+				{Changes: []RowChange{update(1, 1, 2), update(2, 2, 2)}},
+				{Changes: []RowChange{update(1, 1, 3)}},
+			},
+			exp: []RowChange{insert(1, 1), update(1, 1, 2), update(1, 1, 3)},
+		},
+		{
+			name: "moved row",
+			txns: []*Transaction{
+				{Changes: []RowChange{insert(1, 1), insert(0, 1)}},
+				{Changes: []RowChange{update(1, moveTo, 2)}},
+				{Changes: []RowChange{update(0, 0, 2)}},
+			},
+			exp: []RowChange{insert(0, 1), update(1, moveTo, 2), update(0, 0, 2)},
+		},
+		{
+			name: "logical clock",
+			txns: []*Transaction{
+				{LastCommitted: 0, SequenceNumber: 1, Changes: []RowChange{insert(1, 1)}},
+				{LastCommitted: 1, SequenceNumber: 2, Changes: []RowChange{insert(2, 1)}},
+			},
+			exp: []RowChange{insert(1, 1), insert(2, 1)},
+		},
+	}
+	for _, test := range tests {
+		var mu sync.Mutex
+		var applied []RowChange
+		s := NewScheduler(context.Background(), 4, func(_ context.Context, c RowChange) error {
+			if c.After[0] == uint32(1) {
+				// Give other workers a chance to overtake
+				time.Sleep(10 * time.Millisecond)
+			}
+			mu.Lock()
+			applied = append(applied, c)
+			mu.Unlock()
+			return nil
+		})
+		for _, txn := range test.txns {
+			if err := s.Schedule(txn); err != nil {
+				t.Fatalf("%s: failed to schedule transaction: %v", test.name, err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		var order []RowChange
+		for _, c := range applied {
+			for _, e := range test.exp {
+				if reflect.DeepEqual(c, e) {
+					order = append(order, c)
+				}
+			}
+		}
+		if !reflect.DeepEqual(order, test.exp) {
+			t.Errorf("%s: expected changes to be applied in order %v, got %v", test.name, test.exp, order)
+		}
+	}
+}
@@ -0,0 +1,647 @@
+package schema
+
+import (
+	"strings"
+)
+
+// Statement is a table definition statement. It is one of CreateTable,
+// AlterTable, DropTable, TruncateTable or RenameTable.
+type Statement interface {
+	statement()
+}
+
+// TableName is a database and table name pair.
+type TableName struct {
+	Database string
+	Table    string
+}
+
+// CreateTable is a CREATE TABLE statement.
+type CreateTable struct {
+	Table   TableName
+	Columns []Column
+	// Like is set for CREATE TABLE ... LIKE statements, columns are not
+	// defined in this case.
+	Like *TableName
+}
+
+// AlterTable is an ALTER TABLE statement. Only the changes that affect columns,
+// the primary key or table name are described.
+type AlterTable struct {
+	Table TableName
+	// AddedColumns is a list of new columns.
+	AddedColumns []Column
+	// DroppedColumns is a list of names of the dropped columns.
+	DroppedColumns []string
+	// ModifiedColumns is a list of new definitions of the changed columns.
+	// Column names are the new names if column was also renamed.
+	ModifiedColumns []Column
+	// RenamedColumns is a list of renamed columns.
+	RenamedColumns []ColumnRename
+	// RenamedTo is set if the table was renamed.
+	RenamedTo *TableName
+	// PrimaryKey contains column names of an added primary key.
+	PrimaryKey []string
+	// DroppedPrimaryKey is set if the primary key is dropped. A primary key
+	// could be dropped and added in the same statement.
+	DroppedPrimaryKey bool
+
+	// specs are changes in the order of appearance
+	specs []alterSpec
+}
+
+// ColumnRename describes a renamed column.
+type ColumnRename struct {
+	From string
+	To   string
+}
+
+// DropTable is a DROP TABLE statement.
+type DropTable struct {
+	Tables []TableName
+}
+
+// TruncateTable is a TRUNCATE TABLE statement.
+type TruncateTable struct {
+	Table TableName
+}
+
+// RenameTable is a RENAME TABLE statement.
+type RenameTable struct {
+	Renames []TableRename
+}
+
+// TableRename describes a renamed table.
+type TableRename struct {
+	From TableName
+	To   TableName
+}
+
+type columnPosition struct {
+	first bool
+	after string
+}
+
+type alterOp int
+
+const (
+	alterAddColumn alterOp = iota
+	alterDropColumn
+	alterModifyColumn
+	alterChangeColumn
+	alterRenameColumn
+	alterRenameTable
+	alterAddPrimaryKey
+	alterDropPrimaryKey
+)
+
+type alterSpec struct {
+	op       alterOp
+	column   Column
+	oldName  string
+	position columnPosition
+	newTable TableName
+	// key contains primary key column names
+	key []string
+}
+
+func (CreateTable) statement()   {}
+func (AlterTable) statement()    {}
+func (DropTable) statement()     {}
+func (TruncateTable) statement() {}
+func (RenameTable) statement()   {}
+
+// ParseDDL parses given query executed in the context of a given database and
+// returns a table definition statement. Table names that are not qualified
+// with a database name are assigned the given database. If the query is not a
+// table definition statement false is returned.
+func ParseDDL(database, query string) (Statement, bool) {
+	p := &parser{tokens: tokenize(query), database: database}
+	switch {
+	case p.accept("CREATE"):
+		return p.parseCreateTable()
+	case p.accept("ALTER"):
+		return p.parseAlterTable()
+	case p.accept("DROP"):
+		return p.parseDropTable()
+	case p.accept("TRUNCATE"):
+		return p.parseTruncateTable()
+	case p.accept("RENAME", "TABLE"):
+		return p.parseRenameTable()
+	default:
+		return nil, false
+	}
+}
+
+// alteredTable returns the name of the table altered by an ALTER TABLE query,
+// even if its alter specifications could not be parsed.
+func alteredTable(database, query string) (TableName, bool) {
+	p := &parser{tokens: tokenize(query), database: database}
+	if !p.accept("ALTER") {
+		return TableName{}, false
+	}
+	p.accept("ONLINE")
+	p.accept("IGNORE")
+	if !p.accept("TABLE") {
+		return TableName{}, false
+	}
+	return p.tableName()
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	database string
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokenEOF}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes given sequence of keywords if it matches the input.
+func (p *parser) accept(words ...string) bool {
+	for i, w := range words {
+		if p.pos+i >= len(p.tokens) || !p.tokens[p.pos+i].is(w) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) acceptPunct(c string) bool {
+	if p.peek().isPunct(c) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) name() (string, bool) {
+	if t := p.peek(); t.isName() {
+		p.pos++
+		return t.val, true
+	}
+	return "", false
+}
+
+func (p *parser) tableName() (TableName, bool) {
+	name, ok := p.name()
+	if !ok {
+		return TableName{}, false
+	}
+	if p.acceptPunct(".") {
+		tbl, ok := p.name()
+		return TableName{Database: name, Table: tbl}, ok
+	}
+	return TableName{Database: p.database, Table: name}, true
+}
+
+// skipElement skips tokens until a comma or a closing parenthesis on the
+// current nesting level. The terminating token is not consumed.
+func (p *parser) skipElement() {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenEOF:
+			return
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			if depth == 0 {
+				return
+			}
+			depth--
+		case t.isPunct(",") && depth == 0:
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseCreateTable() (Statement, bool) {
+	if p.accept("TEMPORARY") || !p.accept("TABLE") {
+		// Temporary tables are not replicated in row-based format
+		return nil, false
+	}
+	p.accept("IF", "NOT", "EXISTS")
+	ref, ok := p.tableName()
+	if !ok {
+		return nil, false
+	}
+	stmt := CreateTable{Table: ref}
+
+	parens := p.acceptPunct("(")
+	if p.accept("LIKE") {
+		like, ok := p.tableName()
+		if !ok {
+			return nil, false
+		}
+		stmt.Like = &like
+		return stmt, true
+	}
+	if !parens {
+		// CREATE TABLE ... SELECT without column definitions
+		return nil, false
+	}
+
+	var key []string
+	for {
+		if isIndexKeyword(p.peek()) {
+			if names, ok := p.parsePrimaryKey(); ok {
+				key = names
+			}
+			p.skipElement()
+		} else {
+			col, _, ok := p.parseColumnDef()
+			if !ok {
+				return nil, false
+			}
+			stmt.Columns = append(stmt.Columns, col)
+		}
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+	if !p.acceptPunct(")") {
+		return nil, false
+	}
+	for i := range stmt.Columns {
+		for _, name := range key {
+			if strings.EqualFold(stmt.Columns[i].Name, name) {
+				stmt.Columns[i].PrimaryKey = true
+				stmt.Columns[i].NotNull = true
+			}
+		}
+	}
+	return stmt, true
+}
+
+// parsePrimaryKey parses a primary key definition and returns names of its
+// columns. If the element is not a primary key definition false is returned
+// and only the optional constraint name is consumed.
+func (p *parser) parsePrimaryKey() ([]string, bool) {
+	if p.accept("CONSTRAINT") && !p.peek().is("PRIMARY") {
+		p.name()
+	}
+	if !p.accept("PRIMARY", "KEY") {
+		return nil, false
+	}
+	if p.accept("USING") {
+		p.next()
+	}
+	if !p.acceptPunct("(") {
+		return nil, false
+	}
+	var names []string
+	for {
+		name, ok := p.name()
+		if !ok {
+			return nil, false
+		}
+		names = append(names, name)
+		// Prefix length and order are irrelevant
+		p.skipElement()
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+	return names, p.acceptPunct(")")
+}
+
+func (p *parser) parseDropTable() (Statement, bool) {
+	if p.accept("TEMPORARY") || !p.accept("TABLE") {
+		return nil, false
+	}
+	p.accept("IF", "EXISTS")
+	var stmt DropTable
+	for {
+		ref, ok := p.tableName()
+		if !ok {
+			return nil, false
+		}
+		stmt.Tables = append(stmt.Tables, ref)
+		if !p.acceptPunct(",") {
+			return stmt, true
+		}
+	}
+}
+
+func (p *parser) parseTruncateTable() (Statement, bool) {
+	p.accept("TABLE")
+	ref, ok := p.tableName()
+	if !ok {
+		return nil, false
+	}
+	return TruncateTable{Table: ref}, true
+}
+
+func (p *parser) parseRenameTable() (Statement, bool) {
+	var stmt RenameTable
+	for {
+		from, ok := p.tableName()
+		if !ok || !p.accept("TO") {
+			return nil, false
+		}
+		to, ok := p.tableName()
+		if !ok {
+			return nil, false
+		}
+		stmt.Renames = append(stmt.Renames, TableRename{From: from, To: to})
+		if !p.acceptPunct(",") {
+			return stmt, true
+		}
+	}
+}
+
+func (p *parser) parseAlterTable() (Statement, bool) {
+	p.accept("ONLINE")
+	p.accept("IGNORE")
+	if !p.accept("TABLE") {
+		return nil, false
+	}
+	ref, ok := p.tableName()
+	if !ok {
+		return nil, false
+	}
+	stmt := AlterTable{Table: ref}
+	for {
+		specs, ok := p.parseAlterSpec()
+		if !ok {
+			return nil, false
+		}
+		stmt.addSpecs(specs)
+		if !p.acceptPunct(",") {
+			return stmt, true
+		}
+	}
+}
+
+func (stmt *AlterTable) addSpecs(specs []alterSpec) {
+	for _, spec := range specs {
+		switch spec.op {
+		case alterAddColumn:
+			stmt.AddedColumns = append(stmt.AddedColumns, spec.column)
+		case alterDropColumn:
+			stmt.DroppedColumns = append(stmt.DroppedColumns, spec.oldName)
+		case alterModifyColumn:
+			stmt.ModifiedColumns = append(stmt.ModifiedColumns, spec.column)
+		case alterChangeColumn:
+			stmt.ModifiedColumns = append(stmt.ModifiedColumns, spec.column)
+			if spec.oldName != spec.column.Name {
+				stmt.RenamedColumns = append(stmt.RenamedColumns, ColumnRename{From: spec.oldName, To: spec.column.Name})
+			}
+		case alterRenameColumn:
+			stmt.RenamedColumns = append(stmt.RenamedColumns, ColumnRename{From: spec.oldName, To: spec.column.Name})
+		case alterRenameTable:
+			newTable := spec.newTable
+			stmt.RenamedTo = &newTable
+		case alterAddPrimaryKey:
+			stmt.PrimaryKey = spec.key
+		case alterDropPrimaryKey:
+			stmt.DroppedPrimaryKey = true
+		}
+	}
+	stmt.specs = append(stmt.specs, specs...)
+}
+
+func (p *parser) parseAlterSpec() ([]alterSpec, bool) {
+	switch {
+	case p.accept("ADD"):
+		if isIndexKeyword(p.peek()) {
+			if key, ok := p.parsePrimaryKey(); ok {
+				p.skipElement()
+				return []alterSpec{{op: alterAddPrimaryKey, key: key}}, true
+			}
+			break
+		}
+		if p.peek().is("PARTITION") {
+			break
+		}
+		p.accept("COLUMN")
+		if p.acceptPunct("(") {
+			var specs []alterSpec
+			for {
+				col, _, ok := p.parseColumnDef()
+				if !ok {
+					return nil, false
+				}
+				specs = append(specs, alterSpec{op: alterAddColumn, column: col})
+				if !p.acceptPunct(",") {
+					break
+				}
+			}
+			return specs, p.acceptPunct(")")
+		}
+		col, pos, ok := p.parseColumnDef()
+		return []alterSpec{{op: alterAddColumn, column: col, position: pos}}, ok
+
+	case p.accept("DROP"):
+		if p.accept("PRIMARY", "KEY") {
+			return []alterSpec{{op: alterDropPrimaryKey}}, true
+		}
+		if isIndexKeyword(p.peek()) || p.peek().is("PARTITION") {
+			break
+		}
+		p.accept("COLUMN")
+		name, ok := p.name()
+		return []alterSpec{{op: alterDropColumn, oldName: name}}, ok
+
+	case p.accept("MODIFY"):
+		p.accept("COLUMN")
+		col, pos, ok := p.parseColumnDef()
+		return []alterSpec{{op: alterModifyColumn, column: col, oldName: col.Name, position: pos}}, ok
+
+	case p.accept("CHANGE"):
+		p.accept("COLUMN")
+		oldName, ok := p.name()
+		if !ok {
+			return nil, false
+		}
+		col, pos, ok := p.parseColumnDef()
+		return []alterSpec{{op: alterChangeColumn, column: col, oldName: oldName, position: pos}}, ok
+
+	case p.accept("RENAME", "COLUMN"):
+		oldName, ok := p.name()
+		if !ok || !p.accept("TO") {
+			return nil, false
+		}
+		newName, ok := p.name()
+		return []alterSpec{{op: alterRenameColumn, oldName: oldName, column: Column{Name: newName}}}, ok
+
+	case p.accept("RENAME"):
+		if p.peek().is("INDEX") || p.peek().is("KEY") {
+			break
+		}
+		if !p.accept("TO") {
+			p.accept("AS")
+		}
+		ref, ok := p.tableName()
+		return []alterSpec{{op: alterRenameTable, newTable: ref}}, ok
+	}
+
+	// Anything that doesn't affect columns is skipped
+	p.skipElement()
+	return nil, true
+}
+
+// parseColumnDef parses a column definition along with an optional position
+// clause used by ALTER TABLE.
+func (p *parser) parseColumnDef() (Column, columnPosition, bool) {
+	var col Column
+	var pos columnPosition
+	name, ok := p.name()
+	if !ok {
+		return col, pos, false
+	}
+	col.Name = name
+
+	typ := p.next()
+	if typ.kind != tokenIdent {
+		return col, pos, false
+	}
+	typName := strings.ToLower(typ.val)
+	if typName == "double" {
+		// DOUBLE PRECISION is a synonym for DOUBLE
+		p.accept("PRECISION")
+	}
+	var b strings.Builder
+	b.WriteString(typName)
+	if p.peek().isPunct("(") {
+		b.WriteString(p.typeArgs())
+	}
+	for {
+		if p.accept("UNSIGNED") {
+			col.Unsigned = true
+			b.WriteString(" unsigned")
+		} else if p.accept("ZEROFILL") {
+			col.Unsigned = true
+			b.WriteString(" zerofill")
+		} else if !p.accept("SIGNED") {
+			break
+		}
+	}
+	col.Type = b.String()
+
+	// Scan remaining column attributes looking for position clause
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenEOF:
+			return col, pos, true
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			if depth == 0 {
+				return col, pos, true
+			}
+			depth--
+		case t.isPunct(",") && depth == 0:
+			return col, pos, true
+		case t.is("FIRST") && depth == 0:
+			pos.first = true
+		case t.is("PRIMARY") && depth == 0:
+			col.PrimaryKey = true
+			col.NotNull = true
+		case t.is("NOT") && depth == 0:
+			p.pos++
+			if p.accept("NULL") {
+				col.NotNull = true
+			}
+			continue
+		case t.is("DEFAULT") && depth == 0:
+			p.pos++
+			col.Default = p.defaultValue()
+			continue
+		case t.is("AFTER") && depth == 0:
+			p.pos++
+			pos.after, _ = p.name()
+			continue
+		}
+		p.pos++
+	}
+}
+
+// defaultValue consumes a literal or a function name following the DEFAULT
+// keyword and returns it the way INFORMATION_SCHEMA reports it. Nil is returned
+// for NULL, expressions in parentheses are left to the caller to skip.
+func (p *parser) defaultValue() *string {
+	var val string
+	t := p.peek()
+	switch {
+	case t.is("NULL"):
+		p.pos++
+		return nil
+	case t.kind == tokenString:
+		p.pos++
+		val = t.val
+	case t.kind == tokenIdent:
+		p.pos++
+		val = t.val
+		if p.peek().isPunct("(") {
+			// Function arguments, e.g. CURRENT_TIMESTAMP(6)
+			val += p.typeArgs()
+		}
+	case t.isPunct("-"), t.isPunct("+"), t.kind == tokenNumber:
+		if t.kind == tokenPunct {
+			p.pos++
+			if t.val == "-" {
+				val = "-"
+			}
+		}
+		for n := p.peek(); n.kind == tokenNumber || n.isPunct("."); n = p.peek() {
+			val += n.val
+			p.pos++
+		}
+	default:
+		return nil
+	}
+	return &val
+}
+
+// typeArgs consumes type arguments in parentheses and returns them formatted,
+// e.g. "(10,2)" or "('a','b')".
+func (p *parser) typeArgs() string {
+	var b strings.Builder
+	p.next() // (
+	b.WriteByte('(')
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF, t.isPunct(")"):
+			b.WriteByte(')')
+			return b.String()
+		case t.kind == tokenString:
+			b.WriteByte('\'')
+			b.WriteString(strings.Replace(t.val, "'", "''", -1))
+			b.WriteByte('\'')
+		default:
+			b.WriteString(t.val)
+		}
+	}
+}
+
+func isIndexKeyword(t token) bool {
+	for _, w := range []string{
+		"PRIMARY", "KEY", "INDEX", "UNIQUE", "CONSTRAINT", "FOREIGN",
+		"FULLTEXT", "SPATIAL", "CHECK",
+	} {
+		if t.is(w) {
+			return true
+		}
+	}
+	return false
+}
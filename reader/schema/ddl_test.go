@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDDL(t *testing.T) {
+	inputs := []struct {
+		query string
+		stmt  Statement
+	}{
+		{"TRUNCATE TABLE `foo`", TruncateTable{Table: TableName{"test", "foo"}}},
+		{"truncate other.foo", TruncateTable{Table: TableName{"other", "foo"}}},
+		{"RENAME TABLE foo TO bar, other.a TO other.b", RenameTable{Renames: []TableRename{
+			{From: TableName{"test", "foo"}, To: TableName{"test", "bar"}},
+			{From: TableName{"other", "a"}, To: TableName{"other", "b"}},
+		}}},
+		{"DROP TABLE IF EXISTS `foo`, `bar` /* generated by server */", DropTable{Tables: []TableName{{"test", "foo"}, {"test", "bar"}}}},
+		{"CREATE TABLE IF NOT EXISTS foo LIKE bar", CreateTable{Table: TableName{"test", "foo"}, Like: &TableName{"test", "bar"}}},
+		{"/*!40101 CREATE TABLE foo (id INT) */", CreateTable{Table: TableName{"test", "foo"}, Columns: []Column{{Name: "id", Type: "int"}}}},
+		{"CREATE TEMPORARY TABLE foo (id INT)", nil},
+		{"CREATE INDEX idx ON foo (id)", nil},
+		{"SELECT * FROM foo", nil},
+		{"BEGIN", nil},
+	}
+
+	for _, in := range inputs {
+		stmt, ok := ParseDDL("test", in.query)
+		if ok != (in.stmt != nil) {
+			t.Errorf("Unexpected parse result %v for query %q", ok, in.query)
+			continue
+		}
+		if !cmp.Equal(in.stmt, stmt) {
+			t.Errorf("Statement mismatch for query %q: %s", in.query, cmp.Diff(in.stmt, stmt))
+		}
+	}
+}
+
+func TestParseAlterTable(t *testing.T) {
+	query := "ALTER TABLE foo ADD COLUMN a INT AFTER id, DROP COLUMN b, CHANGE c d TEXT, " +
+		"MODIFY e BIGINT UNSIGNED, RENAME COLUMN f TO g, DROP INDEX idx, RENAME TO bar"
+	stmt, ok := ParseDDL("test", query)
+	if !ok {
+		t.Fatalf("Failed to parse query %q", query)
+	}
+	alt, ok := stmt.(AlterTable)
+	if !ok {
+		t.Fatalf("Expected AlterTable, got %T", stmt)
+	}
+
+	exp := AlterTable{
+		Table:           TableName{"test", "foo"},
+		AddedColumns:    []Column{{Name: "a", Type: "int"}},
+		DroppedColumns:  []string{"b"},
+		ModifiedColumns: []Column{{Name: "d", Type: "text"}, {Name: "e", Type: "bigint unsigned", Unsigned: true}},
+		RenamedColumns:  []ColumnRename{{From: "c", To: "d"}, {From: "f", To: "g"}},
+		RenamedTo:       &TableName{"test", "bar"},
+	}
+	alt.specs = nil
+	if !cmp.Equal(exp, alt, cmp.AllowUnexported(AlterTable{})) {
+		t.Errorf("Statement mismatch: %s", cmp.Diff(exp, alt, cmp.AllowUnexported(AlterTable{})))
+	}
+}
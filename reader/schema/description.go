@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/juju/errors"
+)
+
+// FetchDescription builds the description of a table as the server logs it
+// with table map events from the table definition in INFORMATION_SCHEMA. It
+// makes it possible to decode rows events which table map events were not
+// received. Descriptions are not cached. If the table doesn't exist nil is
+// returned.
+//
+// Like other fetched schemas, the description is only accurate when the
+// table was not altered since the rows event was logged.
+func (f *Fetcher) FetchDescription(database, table string) (*binlog.TableDescription, error) {
+	rows, err := f.db.Query(`
+		SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, COLUMN_KEY, IS_NULLABLE,
+			CHARACTER_OCTET_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE, DATETIME_PRECISION
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION ASC
+	`, database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defs []columnDefinition
+	for rows.Next() {
+		var def columnDefinition
+		var key, nullable string
+		err := rows.Scan(&def.Name, &def.DataType, &def.Type, &key, &nullable,
+			&def.Octets, &def.Precision, &def.Scale, &def.FSP)
+		if err != nil {
+			return nil, err
+		}
+		def.Unsigned = strings.Contains(strings.ToLower(def.Type), "unsigned")
+		def.PrimaryKey = key == "PRI"
+		def.NotNull = nullable == "NO"
+		defs = append(defs, def)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, nil
+	}
+	return describeTable(database, table, defs)
+}
+
+// columnDefinition contains column details needed to describe how the server
+// logs column values.
+type columnDefinition struct {
+	Column
+	// DataType is the name of the column type without attributes, e.g.
+	// "varchar".
+	DataType  string
+	Octets    sql.NullInt64
+	Precision sql.NullInt64
+	Scale     sql.NullInt64
+	FSP       sql.NullInt64
+}
+
+// describeTable builds a table description from column definitions.
+func describeTable(database, table string, defs []columnDefinition) (*binlog.TableDescription, error) {
+	td := &binlog.TableDescription{
+		SchemaName:  database,
+		TableName:   table,
+		ColumnCount: uint64(len(defs)),
+		ColumnTypes: make([]byte, len(defs)),
+		ColumnMeta:  make([]uint16, len(defs)),
+		NullBitmask: make([]byte, (len(defs)+7)/8),
+		ColumnNames: make([]string, len(defs)),
+		Unsigned:    make([]bool, len(defs)),
+	}
+	for i, def := range defs {
+		ct, meta, err := def.format()
+		if err != nil {
+			return nil, errors.Annotatef(err, "describe column %s", def.Name)
+		}
+		td.ColumnTypes[i] = byte(ct)
+		td.ColumnMeta[i] = meta
+		if !def.NotNull {
+			td.NullBitmask[i/8] |= 1 << uint(i%8)
+		}
+		td.ColumnNames[i] = def.Name
+		td.Unsigned[i] = def.Unsigned
+		if def.PrimaryKey {
+			td.PrimaryKey = append(td.PrimaryKey, i)
+		}
+		if vals := def.EnumValues(); vals != nil {
+			if td.EnumValues == nil {
+				td.EnumValues = make([][]string, len(defs))
+			}
+			td.EnumValues[i] = vals
+		}
+	}
+	return td, nil
+}
+
+// format returns the type and the metadata the server logs for the column.
+func (def columnDefinition) format() (mysql.ColumnType, uint16, error) {
+	switch strings.ToLower(def.DataType) {
+	case "tinyint":
+		return mysql.ColumnTypeTiny, 0, nil
+	case "smallint":
+		return mysql.ColumnTypeShort, 0, nil
+	case "mediumint":
+		return mysql.ColumnTypeInt24, 0, nil
+	case "int", "integer":
+		return mysql.ColumnTypeLong, 0, nil
+	case "bigint":
+		return mysql.ColumnTypeLonglong, 0, nil
+	case "float":
+		return mysql.ColumnTypeFloat, 4, nil
+	case "double", "real":
+		return mysql.ColumnTypeDouble, 8, nil
+	case "decimal", "numeric":
+		return mysql.ColumnTypeNewDecimal, uint16(def.Precision.Int64)<<8 | uint16(def.Scale.Int64), nil
+	case "bit":
+		n := uint16(def.Precision.Int64)
+		return mysql.ColumnTypeBit, n/8<<8 | n%8, nil
+	case "year":
+		return mysql.ColumnTypeYear, 0, nil
+	case "date":
+		return mysql.ColumnTypeDate, 0, nil
+	case "time":
+		return mysql.ColumnTypeTime2, uint16(def.FSP.Int64), nil
+	case "datetime":
+		return mysql.ColumnTypeDatetime2, uint16(def.FSP.Int64), nil
+	case "timestamp":
+		return mysql.ColumnTypeTimestamp2, uint16(def.FSP.Int64), nil
+	case "char", "binary":
+		// Lengths over 255 bytes are stored in the unused bits of the real type
+		n := uint16(def.Octets.Int64)
+		typ := uint16(mysql.ColumnTypeString) ^ (n&0x300)>>4
+		return mysql.ColumnTypeString, typ<<8 | n&0xFF, nil
+	case "varchar", "varbinary":
+		return mysql.ColumnTypeVarchar, uint16(def.Octets.Int64), nil
+	case "tinytext", "tinyblob":
+		return mysql.ColumnTypeBlob, 1, nil
+	case "text", "blob":
+		return mysql.ColumnTypeBlob, 2, nil
+	case "mediumtext", "mediumblob":
+		return mysql.ColumnTypeBlob, 3, nil
+	case "longtext", "longblob":
+		return mysql.ColumnTypeBlob, 4, nil
+	case "json":
+		return mysql.ColumnTypeJSON, 4, nil
+	case "geometry", "point", "linestring", "polygon", "multipoint",
+		"multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return mysql.ColumnTypeGeometry, 4, nil
+	case "enum":
+		size := uint16(1)
+		if len(def.EnumValues()) > 0xFF {
+			size = 2
+		}
+		return mysql.ColumnTypeString, uint16(mysql.ColumnTypeEnum)<<8 | size, nil
+	case "set":
+		size := uint16(len(def.EnumValues())+7) / 8
+		if size > 4 {
+			size = 8
+		}
+		return mysql.ColumnTypeString, uint16(mysql.ColumnTypeSet)<<8 | size, nil
+	default:
+		return 0, 0, errors.Errorf("Unsupported column type %q", def.Type)
+	}
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestDescribeTable(t *testing.T) {
+	num := func(n int64) sql.NullInt64 { return sql.NullInt64{Int64: n, Valid: true} }
+	defs := []columnDefinition{
+		{Column: Column{Name: "id", Type: "int(10) unsigned", Unsigned: true, PrimaryKey: true, NotNull: true}, DataType: "int"},
+		{Column: Column{Name: "price", Type: "decimal(10,2)"}, DataType: "decimal", Precision: num(10), Scale: num(2)},
+		{Column: Column{Name: "name", Type: "varchar(100)"}, DataType: "varchar", Octets: num(400)},
+		{Column: Column{Name: "code", Type: "char(100)"}, DataType: "char", Octets: num(300)},
+		{Column: Column{Name: "status", Type: "enum('new','paid')", NotNull: true}, DataType: "enum"},
+		{Column: Column{Name: "flags", Type: "bit(10)"}, DataType: "bit", Precision: num(10)},
+		{Column: Column{Name: "created", Type: "datetime(3)"}, DataType: "datetime", FSP: num(3)},
+		{Column: Column{Name: "body", Type: "mediumtext"}, DataType: "mediumtext"},
+	}
+	td, err := describeTable("shop", "orders", defs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expTypes := []mysql.ColumnType{
+		mysql.ColumnTypeLong,
+		mysql.ColumnTypeNewDecimal,
+		mysql.ColumnTypeVarchar,
+		mysql.ColumnTypeString,
+		mysql.ColumnTypeEnum,
+		mysql.ColumnTypeBit,
+		mysql.ColumnTypeDatetime2,
+		mysql.ColumnTypeBlob,
+	}
+	expMeta := []uint16{0, 10<<8 | 2, 400, 0xEE2C, 0xF701, 1<<8 | 2, 3, 3}
+	for i, ct := range expTypes {
+		if got := td.ColumnType(i); got != ct {
+			t.Errorf("Column %s: expected type %s, got %s", td.ColumnNames[i], ct, got)
+		}
+		if td.ColumnMeta[i] != expMeta[i] {
+			t.Errorf("Column %s: expected meta %#x, got %#x", td.ColumnNames[i], expMeta[i], td.ColumnMeta[i])
+		}
+	}
+	if td.NullBitmask[0] != 0xEE {
+		t.Errorf("Expected null bitmask 0xEE, got %#x", td.NullBitmask[0])
+	}
+	if len(td.PrimaryKey) != 1 || td.PrimaryKey[0] != 0 || !td.Unsigned[0] {
+		t.Errorf("Expected unsigned primary key on column 0, got %v %v", td.PrimaryKey, td.Unsigned)
+	}
+	if vals := td.EnumValues[4]; len(vals) != 2 || vals[1] != "paid" {
+		t.Errorf("Expected enum values of column 4, got %v", vals)
+	}
+
+	defs = append(defs, columnDefinition{Column: Column{Name: "v", Type: "vector(3)"}, DataType: "vector"})
+	if _, err := describeTable("shop", "orders", defs); err == nil {
+		t.Error("Expected unsupported column type error")
+	}
+}
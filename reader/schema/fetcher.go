@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// Fetcher fetches table schemas from INFORMATION_SCHEMA over a side connection
+// the first time a table is requested and caches them. Cached schemas of the
+// tables affected by table definition statements are dropped and fetched
+// again when requested next time.
+//
+// Fetched schemas describe the tables as they are at the time of fetching,
+// which is only accurate when reading close to the end of the binary log.
+// It's safe for concurrent use.
+type Fetcher struct {
+	db *sql.DB
+
+	mu sync.Mutex
+	// tables contains fetched tables, nil values mark tables that don't exist
+	tables map[TableName]*Table
+}
+
+// NewFetcher creates a new schema fetcher that uses the given connection.
+func NewFetcher(db *sql.DB) *Fetcher {
+	return &Fetcher{
+		db:     db,
+		tables: make(map[TableName]*Table),
+	}
+}
+
+// Table returns table details for a given database and table name pair. If the
+// table doesn't exist or fetching fails nil is returned.
+func (f *Fetcher) Table(database, table string) *Table {
+	tbl, _ := f.FetchTable(database, table)
+	return tbl
+}
+
+// FetchTable returns table details for a given database and table name pair,
+// fetching them unless they are cached. If the table doesn't exist nil is
+// returned.
+func (f *Fetcher) FetchTable(database, table string) (*Table, error) {
+	name := TableName{Database: database, Table: table}
+	f.mu.Lock()
+	tbl, ok := f.tables[name]
+	f.mu.Unlock()
+	if ok {
+		return tbl, nil
+	}
+
+	cols, err := queryColumns(f.db, database, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) > 0 {
+		tbl = &Table{columns: cols}
+	}
+	f.mu.Lock()
+	f.tables[name] = tbl
+	f.mu.Unlock()
+	return tbl, nil
+}
+
+// Invalidate drops the cached schema for a given database and table name pair.
+func (f *Fetcher) Invalidate(database, table string) {
+	f.mu.Lock()
+	delete(f.tables, TableName{Database: database, Table: table})
+	f.mu.Unlock()
+}
+
+// ProcessQuery accepts an SQL query executed in the context of a given database
+// and invalidates cached schemas of the tables the query defines.
+func (f *Fetcher) ProcessQuery(database, query string) error {
+	stmt, ok := ParseDDL(database, query)
+	if !ok {
+		return nil
+	}
+	for _, name := range definedTables(stmt) {
+		f.Invalidate(name.Database, name.Table)
+	}
+	return nil
+}
+
+// definedTables returns names of the tables which definitions are changed by a
+// statement.
+func definedTables(stmt Statement) []TableName {
+	switch stmt := stmt.(type) {
+	case CreateTable:
+		return []TableName{stmt.Table}
+	case AlterTable:
+		if stmt.RenamedTo != nil {
+			return []TableName{stmt.Table, *stmt.RenamedTo}
+		}
+		return []TableName{stmt.Table}
+	case DropTable:
+		return stmt.Tables
+	case RenameTable:
+		names := make([]TableName, 0, 2*len(stmt.Renames))
+		for _, rn := range stmt.Renames {
+			names = append(names, rn.From, rn.To)
+		}
+		return names
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,36 @@
+package schema
+
+import "testing"
+
+func TestFetcherProcessQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		dropped []string
+	}{
+		{"ALTER TABLE orders ADD COLUMN note TEXT", []string{"orders"}},
+		{"ALTER TABLE orders RENAME TO archive", []string{"orders", "archive"}},
+		{"RENAME TABLE users TO customers", []string{"users", "customers"}},
+		{"DROP TABLE IF EXISTS orders, users", []string{"orders", "users"}},
+		{"CREATE TABLE archive (id INT)", []string{"archive"}},
+		{"ALTER TABLE other.orders ADD COLUMN note TEXT", nil},
+		{"TRUNCATE TABLE orders", nil},
+		{"INSERT INTO orders VALUES (1)", nil},
+	}
+	for _, test := range tests {
+		f := NewFetcher(nil)
+		for _, name := range []string{"orders", "users", "customers", "archive"} {
+			f.tables[TableName{Database: "shop", Table: name}] = &Table{}
+		}
+		if err := f.ProcessQuery("shop", test.query); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.query, err)
+		}
+		if n := len(f.tables); n != 4-len(test.dropped) {
+			t.Errorf("%s: expected %d cached tables, got %d", test.query, 4-len(test.dropped), n)
+		}
+		for _, name := range test.dropped {
+			if _, ok := f.tables[TableName{Database: "shop", Table: name}]; ok {
+				t.Errorf("%s: expected %s to be invalidated", test.query, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+// is returns true if the token is an unquoted keyword matching given word.
+func (t token) is(word string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.val, word)
+}
+
+// isPunct returns true if the token is given punctuation character.
+func (t token) isPunct(c string) bool {
+	return t.kind == tokenPunct && t.val == c
+}
+
+// isName returns true if the token could be used as an identifier.
+func (t token) isName() bool {
+	return t.kind == tokenIdent || t.kind == tokenQuotedIdent
+}
+
+// tokenize splits an SQL query into tokens. Comments are dropped except for
+// versioned comments (/*!50100 ... */) which contents are tokenized as regular
+// code, just like the server does.
+func tokenize(query string) []token {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			i = skipLine(query, i)
+		case c == '-' && strings.HasPrefix(query[i:], "-- "):
+			i = skipLine(query, i)
+		case c == '/' && strings.HasPrefix(query[i:], "/*!"):
+			// Versioned comment, skip version number and tokenize contents
+			i += 3
+			for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '*' && strings.HasPrefix(query[i:], "*/"):
+			// End of a versioned comment
+			i += 2
+		case c == '`' || c == '"' || c == '\'':
+			val, n := readQuoted(query[i:], c)
+			kind := tokenString
+			if c == '`' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind: kind, val: val})
+			i += n
+		case isIdentChar(c):
+			j := i
+			for j < len(query) && isIdentChar(query[j]) {
+				j++
+			}
+			kind := tokenIdent
+			if isNumeric(query[i:j]) {
+				kind = tokenNumber
+			}
+			tokens = append(tokens, token{kind: kind, val: query[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, token{kind: tokenPunct, val: query[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
+
+func skipLine(query string, i int) int {
+	if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(query)
+}
+
+// readQuoted reads a quoted string or identifier and returns its unquoted value
+// and the number of bytes consumed. Quote characters could be escaped by either
+// doubling them or with a backslash (except for identifiers).
+func readQuoted(s string, q byte) (string, int) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == q && i+1 < len(s) && s[i+1] == q:
+			b.WriteByte(q)
+			i++
+		case c == q:
+			return b.String(), i + 1
+		case c == '\\' && q != '`' && i+1 < len(s):
+			b.WriteByte(s[i+1])
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), len(s)
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' ||
+		c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isNumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
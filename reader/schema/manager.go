@@ -2,7 +2,6 @@ package schema
 
 import (
 	"database/sql"
-	"regexp"
 	"strings"
 )
 
@@ -22,7 +21,7 @@ func NewManager(db *sql.DB) *Manager {
 
 // Manage adds given tables to a list of managed tables and updates its details.
 func (m *Manager) Manage(database, table string) error {
-	cols, err := m.tableColumns(database, table)
+	cols, err := queryColumns(m.db, database, table)
 	if err != nil {
 		return err
 	}
@@ -31,19 +30,35 @@ func (m *Manager) Manage(database, table string) error {
 	return nil
 }
 
-// ProcessQuery accepts an SQL query and updates schema if required.
+// Table returns table details for a given database and table name pair. If the
+// table is not managed nil is returned.
+func (m *Manager) Table(database, table string) *Table {
+	return m.Schema.Table(database, table)
+}
+
+// ProcessQuery accepts an SQL query executed in the context of a given database
+// and refreshes schemas of the managed tables the query alters or renames.
+// Renamed tables remain managed under their new names.
 func (m *Manager) ProcessQuery(database, query string) error {
-	if tableName, ok := changedTable(query); ok {
-		if tbl := m.Schema.Table(database, tableName); tbl != nil {
-			return m.Manage(database, tableName)
+	for _, rn := range changedTables(database, query) {
+		if m.Schema.Table(rn.From.Database, rn.From.Table) == nil {
+			continue
+		}
+		if rn.To != rn.From {
+			m.Schema.Drop(rn.From.Database, rn.From.Table)
+		}
+		if err := m.Manage(rn.To.Database, rn.To.Table); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (m *Manager) tableColumns(database, table string) ([]Column, error) {
-	rows, err := m.db.Query(`
-		SELECT COLUMN_NAME, COLUMN_TYPE 
+// queryColumns returns column definitions of a table from INFORMATION_SCHEMA.
+// An empty list is returned if the table doesn't exist.
+func queryColumns(db *sql.DB, database, table string) ([]Column, error) {
+	rows, err := db.Query(`
+		SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY, IS_NULLABLE, COLUMN_DEFAULT
 		FROM INFORMATION_SCHEMA.COLUMNS 
 		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? 
 		ORDER BY ORDINAL_POSITION ASC
@@ -56,25 +71,48 @@ func (m *Manager) tableColumns(database, table string) ([]Column, error) {
 	cols := make([]Column, 0)
 	for rows.Next() {
 		var col Column
-		var typ string
-		err := rows.Scan(&col.Name, &typ)
+		var typ, key, nullable string
+		var def sql.NullString
+		err := rows.Scan(&col.Name, &typ, &key, &nullable, &def)
 		if err != nil {
 			return nil, err
 		}
+		col.Type = typ
 		if strings.Contains(strings.ToLower(typ), "unsigned") {
 			col.Unsigned = true
 		}
+		col.PrimaryKey = key == "PRI"
+		col.NotNull = nullable == "NO"
+		if def.Valid {
+			col.Default = &def.String
+		}
 		cols = append(cols, col)
 	}
-	return cols, nil
+	return cols, rows.Err()
 }
 
-var alterRegexp = regexp.MustCompile(`(?im)^alter[\s\t\n]+table[\s\t\n]+` + "`" + `?([a-z0-9_]+)`)
-
-func changedTable(query string) (string, bool) {
-	m := alterRegexp.FindAllStringSubmatch(query, -1)
-	if len(m) > 0 {
-		return m[0][1], true
+// changedTables returns tables altered or renamed by a query. Names of the
+// tables that are not renamed stay the same. Tables altered by statements
+// which changes could not be parsed are returned as well, so that they're
+// refreshed anyway.
+func changedTables(database, query string) []TableRename {
+	stmt, ok := ParseDDL(database, query)
+	if !ok {
+		if name, ok := alteredTable(database, query); ok {
+			return []TableRename{{From: name, To: name}}
+		}
+		return nil
+	}
+	switch stmt := stmt.(type) {
+	case AlterTable:
+		rn := TableRename{From: stmt.Table, To: stmt.Table}
+		if stmt.RenamedTo != nil {
+			rn.To = *stmt.RenamedTo
+		}
+		return []TableRename{rn}
+	case RenameTable:
+		return stmt.Renames
+	default:
+		return nil
 	}
-	return "", false
 }
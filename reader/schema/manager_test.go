@@ -1,34 +1,44 @@
 package schema
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
-func TestChangedTable(t *testing.T) {
+func TestChangedTables(t *testing.T) {
+	foobar := TableName{Database: "db", Table: "foobar"}
 	inputs := []struct {
-		query, tbl string
-		ok         bool
+		query string
+		exp   []TableRename
 	}{
-		{"alter\ttable foobar add column", "foobar", true},
-		{"ALTER   TABLE foobar ADD COLUMN", "foobar", true},
-		{"alter table    `foobar` add column", "foobar", true},
-		{"ALTER TABLE `foobar` ADD COLUMN", "foobar", true},
-		{"alter\ntable     \n\tfoobar\nadd column", "foobar", true},
-		{"ALTER TABLE Foo_Bar111 ADD COLUMN", "Foo_Bar111", true},
-		{"SELECT * FROM foobar", "", false},
-		{"SELECT * FROM `foobar`", "", false},
+		{"alter\ttable foobar add column", []TableRename{{foobar, foobar}}},
+		{"ALTER   TABLE foobar ADD COLUMN", []TableRename{{foobar, foobar}}},
+		{"alter table    `foobar` add column", []TableRename{{foobar, foobar}}},
+		{"ALTER TABLE `foobar` ADD COLUMN", []TableRename{{foobar, foobar}}},
+		{"alter\ntable     \n\tfoobar\nadd column", []TableRename{{foobar, foobar}}},
+		{"ALTER TABLE Foo_Bar111 ADD COLUMN", []TableRename{{
+			TableName{Database: "db", Table: "Foo_Bar111"}, TableName{Database: "db", Table: "Foo_Bar111"},
+		}}},
+		{"alter\ttable foobar add column x int", []TableRename{{foobar, foobar}}},
+		{"ALTER   TABLE foobar ADD COLUMN x INT", []TableRename{{foobar, foobar}}},
+		{"alter table    `foobar` add column x int", []TableRename{{foobar, foobar}}},
+		{"alter\ntable     \n\tfoobar\nadd column x int", []TableRename{{foobar, foobar}}},
+		{"ALTER TABLE other.Foo_Bar111 ADD INDEX (a)", []TableRename{{
+			TableName{Database: "other", Table: "Foo_Bar111"}, TableName{Database: "other", Table: "Foo_Bar111"},
+		}}},
+		{"ALTER TABLE foobar RENAME TO baz", []TableRename{{foobar, TableName{Database: "db", Table: "baz"}}}},
+		{"RENAME TABLE foobar TO baz, qux TO other.qux", []TableRename{
+			{foobar, TableName{Database: "db", Table: "baz"}},
+			{TableName{Database: "db", Table: "qux"}, TableName{Database: "other", Table: "qux"}},
+		}},
+		{"DROP TABLE foobar", nil},
+		{"SELECT * FROM foobar", nil},
+		{"SELECT * FROM `foobar`", nil},
 	}
 
 	for _, in := range inputs {
-		out, ok := changedTable(in.query)
-		if ok != in.ok {
-			if ok {
-				t.Errorf("Didn't expect to match table %q in query %q", out, in.query)
-			} else {
-				t.Errorf("Expected to match table %q in query %q", in.tbl, in.query)
-			}
-			continue
-		}
-		if out != in.tbl {
-			t.Errorf("Expected to match table %q, got %q in query %q", in.tbl, out, in.query)
+		if out := changedTables("db", in.query); !reflect.DeepEqual(out, in.exp) {
+			t.Errorf("Expected %v to be changed by query %q, got %v", in.exp, in.query, out)
 		}
 	}
 }
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"strings"
+	"sync"
+)
+
+// Registry contains table schemas supplied by the user. It's meant for servers
+// that don't log full table metadata when table definitions are known upfront,
+// so that neither a database connection nor table definition statements are
+// required. Unlike Tracker the registry ignores queries, tables are only
+// changed by registering them again. It's safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	schema *Schema
+}
+
+// NewRegistry creates an empty schema registry.
+func NewRegistry() *Registry {
+	return &Registry{schema: NewSchema()}
+}
+
+// Register sets column definitions for a given database and table name pair.
+// Columns must be listed in table order. Members of enum and set columns are
+// taken from the column type, e.g. "enum('new','paid')".
+func (r *Registry) Register(database, table string, cols []Column) {
+	cols = append([]Column(nil), cols...)
+	r.mu.Lock()
+	r.schema.Update(database, table, cols)
+	r.mu.Unlock()
+}
+
+// Unregister removes the table definition for a given database and table name
+// pair.
+func (r *Registry) Unregister(database, table string) {
+	r.mu.Lock()
+	r.schema.Drop(database, table)
+	r.mu.Unlock()
+}
+
+// Table returns table details for a given database and table name pair. If the
+// table is not registered nil is returned.
+func (r *Registry) Table(database, table string) *Table {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schema.Table(database, table)
+}
+
+// ProcessQuery does nothing, registered schemas are not affected by queries.
+func (r *Registry) ProcessQuery(database, query string) error {
+	return nil
+}
+
+// EnumValues returns members of an enum or set column parsed from its type.
+// Nil is returned for columns of other types.
+func (c Column) EnumValues() []string {
+	typ := strings.ToLower(c.Type)
+	var args string
+	switch {
+	case strings.HasPrefix(typ, "enum("):
+		args = c.Type[len("enum("):]
+	case strings.HasPrefix(typ, "set("):
+		args = c.Type[len("set("):]
+	default:
+		return nil
+	}
+
+	var vals []string
+	for {
+		args = strings.TrimLeft(args, " ,")
+		if !strings.HasPrefix(args, "'") {
+			return vals
+		}
+		// Quotes within members are doubled
+		var b strings.Builder
+		i := 1
+		for i < len(args) {
+			if args[i] == '\'' {
+				if i+1 < len(args) && args[i+1] == '\'' {
+					b.WriteByte('\'')
+					i += 2
+					continue
+				}
+				break
+			}
+			b.WriteByte(args[i])
+			i++
+		}
+		vals = append(vals, b.String())
+		if i >= len(args) {
+			return vals
+		}
+		args = args[i+1:]
+	}
+}
@@ -0,0 +1,24 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnEnumValues(t *testing.T) {
+	tests := []struct {
+		typ  string
+		vals []string
+	}{
+		{"enum('new','paid')", []string{"new", "paid"}},
+		{"SET('a', 'b,c', 'it''s')", []string{"a", "b,c", "it's"}},
+		{"enum('')", []string{""}},
+		{"varchar(255)", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if vals := (Column{Type: test.typ}).EnumValues(); !reflect.DeepEqual(vals, test.vals) {
+			t.Errorf("%s: expected %q, got %q", test.typ, test.vals, vals)
+		}
+	}
+}
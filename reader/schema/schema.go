@@ -13,10 +13,23 @@ type Table struct {
 // Column carries two key column parameters that are not available in the binary
 // log of older versions of MySQL.
 type Column struct {
-	Name string
+	Name string `json:"name"`
+	// Type is the column type as it appears in the table definition, e.g.
+	// "varchar(255)" or "int(10) unsigned". It could be empty if unknown.
+	Type string `json:"type,omitempty"`
 	// Unsigned is true if the column is of integer or decimal types and is
 	// unsigned.
-	Unsigned bool
+	Unsigned bool `json:"unsigned,omitempty"`
+	// PrimaryKey is true if the column is a part of the primary key.
+	PrimaryKey bool `json:"primary_key,omitempty"`
+	// NotNull is true if the column doesn't accept NULL values, which is
+	// always the case for primary key columns.
+	NotNull bool `json:"not_null,omitempty"`
+	// Default is the default value of the column as INFORMATION_SCHEMA
+	// reports it: literals are unquoted, expressions such as
+	// CURRENT_TIMESTAMP are kept as is. It's nil if the column has no
+	// default or defaults to NULL.
+	Default *string `json:"default,omitempty"`
 }
 
 // NewSchema creates a new managed schema object.
@@ -43,6 +56,38 @@ func (s Schema) Update(database, table string, cols []Column) {
 	s.tables[database][table] = Table{columns: cols}
 }
 
+// Drop removes table definition for a given database and table name pair.
+func (s Schema) Drop(database, table string) {
+	if d, ok := s.tables[database]; ok {
+		delete(d, table)
+	}
+}
+
+// Rename moves table definition to a new database and table name pair.
+func (s Schema) Rename(database, table, newDatabase, newTable string) {
+	if t := s.Table(database, table); t != nil {
+		s.Drop(database, table)
+		s.Update(newDatabase, newTable, t.columns)
+	}
+}
+
+// Columns returns a list of table columns.
+func (t Table) Columns() []Column {
+	return t.columns
+}
+
+// PrimaryKey returns indexes of primary key columns in column order. Nil is
+// returned if the table has no primary key.
+func (t Table) PrimaryKey() []int {
+	var key []int
+	for i, col := range t.columns {
+		if col.PrimaryKey {
+			key = append(key, i)
+		}
+	}
+	return key
+}
+
 // Column returns column details for the given column index. If index is out of
 // range nil is returned.
 func (t Table) Column(i int) *Column {
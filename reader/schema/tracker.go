@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Tracker maintains table schemas by following table definition statements
+// (CREATE, ALTER, DROP and RENAME TABLE) from query events. Unlike Manager it
+// doesn't require a database connection, which makes it suitable for
+// enriching rows events on servers that don't log full table metadata.
+//
+// Tables that were created before the stream has started are unknown to the
+// tracker, they could be added by processing the output of SHOW CREATE TABLE
+// or by loading a previously saved catalog.
+type Tracker struct {
+	Schema *Schema
+}
+
+// NewTracker creates a new schema tracker.
+func NewTracker() *Tracker {
+	return &Tracker{Schema: NewSchema()}
+}
+
+// Table returns table details for a given database and table name pair. If the
+// table is not known to the tracker nil is returned.
+func (t *Tracker) Table(database, table string) *Table {
+	return t.Schema.Table(database, table)
+}
+
+// ProcessQuery accepts an SQL query executed in the context of a given database
+// and updates schema if the query is a table definition statement.
+func (t *Tracker) ProcessQuery(database, query string) error {
+	stmt, ok := ParseDDL(database, query)
+	if !ok {
+		return nil
+	}
+
+	switch stmt := stmt.(type) {
+	case CreateTable:
+		if stmt.Like != nil {
+			if src := t.Schema.Table(stmt.Like.Database, stmt.Like.Table); src != nil {
+				t.Schema.Update(stmt.Table.Database, stmt.Table.Table, copyColumns(src.columns))
+			}
+			return nil
+		}
+		t.Schema.Update(stmt.Table.Database, stmt.Table.Table, stmt.Columns)
+	case DropTable:
+		for _, ref := range stmt.Tables {
+			t.Schema.Drop(ref.Database, ref.Table)
+		}
+	case RenameTable:
+		for _, rn := range stmt.Renames {
+			t.Schema.Rename(rn.From.Database, rn.From.Table, rn.To.Database, rn.To.Table)
+		}
+	case AlterTable:
+		t.alterTable(stmt)
+	}
+	return nil
+}
+
+func (t *Tracker) alterTable(stmt AlterTable) {
+	ref := stmt.Table
+	tbl := t.Schema.Table(ref.Database, ref.Table)
+	if tbl == nil {
+		return
+	}
+	cols := copyColumns(tbl.columns)
+
+	for _, spec := range stmt.specs {
+		switch spec.op {
+		case alterAddColumn:
+			cols = insertColumn(cols, spec.column, spec.position)
+		case alterDropColumn:
+			if i := columnIndex(cols, spec.oldName); i >= 0 {
+				cols = append(cols[:i], cols[i+1:]...)
+			}
+		case alterModifyColumn, alterChangeColumn:
+			i := columnIndex(cols, spec.oldName)
+			if i < 0 {
+				continue
+			}
+			// Primary key is not dropped by redefining its column
+			spec.column.PrimaryKey = spec.column.PrimaryKey || cols[i].PrimaryKey
+			spec.column.NotNull = spec.column.NotNull || spec.column.PrimaryKey
+			if spec.position.first || spec.position.after != "" {
+				cols = append(cols[:i], cols[i+1:]...)
+				cols = insertColumn(cols, spec.column, spec.position)
+			} else {
+				cols[i] = spec.column
+			}
+		case alterRenameColumn:
+			if i := columnIndex(cols, spec.oldName); i >= 0 {
+				cols[i].Name = spec.column.Name
+			}
+		case alterRenameTable:
+			t.Schema.Drop(ref.Database, ref.Table)
+			ref = spec.newTable
+		case alterDropPrimaryKey:
+			for i := range cols {
+				cols[i].PrimaryKey = false
+			}
+		case alterAddPrimaryKey:
+			for _, name := range spec.key {
+				if i := columnIndex(cols, name); i >= 0 {
+					cols[i].PrimaryKey = true
+					cols[i].NotNull = true
+				}
+			}
+		}
+	}
+	t.Schema.Update(ref.Database, ref.Table, cols)
+}
+
+// Save writes tracked schema to the given writer in JSON format.
+func (t *Tracker) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(t.catalog())
+}
+
+// Load reads schema previously written with Save from the given reader. Loaded
+// tables replace tracked tables with the same name.
+func (t *Tracker) Load(r io.Reader) error {
+	var cat map[string]map[string][]Column
+	if err := json.NewDecoder(r).Decode(&cat); err != nil {
+		return err
+	}
+	for database, tables := range cat {
+		for table, cols := range tables {
+			t.Schema.Update(database, table, cols)
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) catalog() map[string]map[string][]Column {
+	cat := make(map[string]map[string][]Column, len(t.Schema.tables))
+	for database, tables := range t.Schema.tables {
+		cat[database] = make(map[string][]Column, len(tables))
+		for table, tbl := range tables {
+			cat[database][table] = tbl.columns
+		}
+	}
+	return cat
+}
+
+func insertColumn(cols []Column, col Column, pos columnPosition) []Column {
+	i := len(cols)
+	if pos.first {
+		i = 0
+	} else if pos.after != "" {
+		if j := columnIndex(cols, pos.after); j >= 0 {
+			i = j + 1
+		}
+	}
+	cols = append(cols, Column{})
+	copy(cols[i+1:], cols[i:])
+	cols[i] = col
+	return cols
+}
+
+// columnIndex returns an index of a column with the given name. Column names
+// are case insensitive. If the column is not found -1 is returned.
+func columnIndex(cols []Column, name string) int {
+	for i, col := range cols {
+		if strings.EqualFold(col.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyColumns(cols []Column) []Column {
+	return append([]Column(nil), cols...)
+}
@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTracker(t *testing.T) {
+	def := func(val string) *string { return &val }
+	inputs := []struct {
+		queries []string
+		table   string
+		cols    []Column
+	}{
+		{
+			queries: []string{"CREATE TABLE `foo` (\n`id` int(10) unsigned NOT NULL AUTO_INCREMENT,\n`name` varchar(255) DEFAULT 'a,b',\nPRIMARY KEY (`id`)\n) ENGINE=InnoDB"},
+			table:   "foo",
+			cols:    []Column{{"id", "int(10) unsigned", true, true, true, nil}, {"name", "varchar(255)", false, false, false, def("a,b")}},
+		},
+		{
+			queries: []string{
+				"create table foo (id int, price decimal(10,2), kind enum('a','b'))",
+				"alter table foo add column bar bigint unsigned after id, drop column kind, change price cost decimal(12,4) first",
+			},
+			table: "foo",
+			cols:  []Column{{"cost", "decimal(12,4)", false, false, false, nil}, {"id", "int", false, false, false, nil}, {"bar", "bigint unsigned", true, false, false, nil}},
+		},
+		{
+			queries: []string{
+				"CREATE TABLE test.foo (a INT)",
+				"ALTER TABLE foo ADD (b TEXT, c DATE), RENAME COLUMN a TO z, ADD INDEX idx (b)",
+				"ALTER TABLE foo MODIFY c DATETIME",
+			},
+			table: "foo",
+			cols:  []Column{{"z", "int", false, false, false, nil}, {"b", "text", false, false, false, nil}, {"c", "datetime", false, false, false, nil}},
+		},
+		{
+			queries: []string{
+				"CREATE TABLE foo (a INT)",
+				"RENAME TABLE foo TO bar",
+				"CREATE TABLE baz LIKE bar",
+				"DROP TABLE IF EXISTS bar /* generated by server */",
+			},
+			table: "baz",
+			cols:  []Column{{"a", "int", false, false, false, nil}},
+		},
+		{
+			queries: []string{
+				"CREATE TABLE foo (a INT PRIMARY KEY, b INT, c INT)",
+				"ALTER TABLE foo MODIFY a BIGINT",
+			},
+			table: "foo",
+			cols:  []Column{{"a", "bigint", false, true, true, nil}, {"b", "int", false, false, false, nil}, {"c", "int", false, false, false, nil}},
+		},
+		{
+			queries: []string{
+				"CREATE TABLE foo (a INT, b INT, c INT, CONSTRAINT pk PRIMARY KEY USING BTREE (a, b(10) DESC))",
+				"ALTER TABLE foo DROP PRIMARY KEY, ADD PRIMARY KEY (`c`)",
+			},
+			table: "foo",
+			cols:  []Column{{"a", "int", false, false, true, nil}, {"b", "int", false, false, true, nil}, {"c", "int", false, true, true, nil}},
+		},
+		{
+			queries: []string{
+				"CREATE TABLE foo (a INT NOT NULL DEFAULT -1, b DECIMAL(4,2) DEFAULT 1.5, c TEXT NULL DEFAULT NULL)",
+				"ALTER TABLE foo ADD d DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6)",
+			},
+			table: "foo",
+			cols: []Column{
+				{"a", "int", false, false, true, def("-1")},
+				{"b", "decimal(4,2)", false, false, false, def("1.5")},
+				{"c", "text", false, false, false, nil},
+				{"d", "datetime(6)", false, false, true, def("CURRENT_TIMESTAMP(6)")},
+			},
+		},
+	}
+
+	for _, in := range inputs {
+		tr := NewTracker()
+		for _, q := range in.queries {
+			if err := tr.ProcessQuery("test", q); err != nil {
+				t.Fatalf("Failed to process query %q: %v", q, err)
+			}
+		}
+		tbl := tr.Table("test", in.table)
+		if tbl == nil {
+			t.Errorf("Table %q not found after queries %q", in.table, in.queries)
+			continue
+		}
+		if !cmp.Equal(in.cols, tbl.Columns()) {
+			t.Errorf("Columns mismatch after queries %q: %s", in.queries, cmp.Diff(in.cols, tbl.Columns()))
+		}
+	}
+}
+
+func TestTrackerSaveLoad(t *testing.T) {
+	tr := NewTracker()
+	if err := tr.ProcessQuery("test", "CREATE TABLE foo (id INT UNSIGNED)"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tr.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tr2 := NewTracker()
+	if err := tr2.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	tbl := tr2.Table("test", "foo")
+	if tbl == nil {
+		t.Fatal("Table not loaded")
+	}
+	if exp := []Column{{"id", "int unsigned", true, false, false, nil}}; !cmp.Equal(exp, tbl.Columns()) {
+		t.Errorf("Columns mismatch: %s", cmp.Diff(exp, tbl.Columns()))
+	}
+}
@@ -0,0 +1,98 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// ServerIDConflictError is returned when the server ends the dump because
+// another replica connected with the same server ID. Server IDs must be unique
+// among all replicas of a master, including readers. Either assign a unique ID
+// to every reader or use WithServerIDReallocation.
+type ServerIDConflictError struct {
+	// ServerID is the conflicting ID the reader was registered with.
+	ServerID uint32
+	// Err is the error reported by the server.
+	Err error
+}
+
+func (e *ServerIDConflictError) Error() string {
+	return fmt.Sprintf("Another replica connected with server ID %d, server IDs must be unique: %v", e.ServerID, e.Err)
+}
+
+// Is makes the error match driver.ErrServerIDConflict.
+func (e *ServerIDConflictError) Is(target error) bool {
+	return target == driver.ErrServerIDConflict
+}
+
+// WithServerIDReallocation makes the reader pick a server ID that is not used
+// by the master or any of its registered replicas and restart the dump once
+// another replica connects with its server ID. The function is called with
+// both IDs, the new one should be persisted and used from then on. It could be
+// nil.
+func WithServerIDReallocation(fn func(prev, next uint32)) Option {
+	return func(r *Reader) {
+		r.reallocateServerID = true
+		r.onServerIDChange = fn
+	}
+}
+
+// handleServerIDConflict returns ServerIDConflictError if the read error
+// reports a server ID conflict. If reallocation is enabled, the dump is
+// restarted with a new server ID instead and nil is returned. Other errors are
+// returned as is.
+func (r *Reader) handleServerIDConflict(ctx context.Context, err error) error {
+	derr, ok := errors.Cause(err).(*driver.Error)
+	if !ok || !derr.Is(driver.ErrServerIDConflict) {
+		return err
+	}
+	cerr := &ServerIDConflictError{ServerID: r.conf.ServerID, Err: derr}
+	if !r.reallocateServerID {
+		return cerr
+	}
+	id, rerr := r.unusedServerID(ctx)
+	if rerr != nil {
+		return errors.Annotatef(rerr, "reallocate server ID after %v", cerr)
+	}
+	prev := r.conf.ServerID
+	r.conf.ServerID = id
+	if r.onServerIDChange != nil {
+		r.onServerIDChange(prev, id)
+	}
+	return errors.Annotate(r.resume(ctx), "restart with new server ID")
+}
+
+// unusedServerID returns the first server ID after the current one that is not
+// used by the master or its registered replicas.
+func (r *Reader) unusedServerID(ctx context.Context) (uint32, error) {
+	conn, err := driver.ConnectContext(ctx, r.dsn, r.conf)
+	if err != nil {
+		return 0, errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+
+	vars, err := conn.GetVarsContext(ctx, "server_id")
+	if err != nil {
+		return 0, errors.Annotate(err, "query server ID")
+	}
+	used := map[uint32]bool{0: true, r.conf.ServerID: true}
+	if id, err := strconv.ParseUint(vars["server_id"], 10, 32); err == nil {
+		used[uint32(id)] = true
+	}
+	hosts, err := conn.ReplicaHosts(ctx)
+	if err != nil {
+		return 0, errors.Annotate(err, "list replicas")
+	}
+	for _, h := range hosts {
+		used[h.ServerID] = true
+	}
+	id := r.conf.ServerID
+	for used[id] {
+		id++
+	}
+	return id, nil
+}
@@ -0,0 +1,27 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Vivino/bocadillo/mysql/driver"
+)
+
+func TestServerIDConflict(t *testing.T) {
+	r := &Reader{conf: driver.Config{ServerID: 1000}}
+	serr := &driver.Error{Code: 1236, Message: "A slave with the same server_uuid/server_id as this slave has connected to the master"}
+	err := r.handleServerIDConflict(context.Background(), serr)
+	var cerr *ServerIDConflictError
+	if !errors.As(err, &cerr) || cerr.ServerID != 1000 {
+		t.Fatalf("Expected conflict of server ID 1000, got %v", err)
+	}
+	if !errors.Is(err, driver.ErrServerIDConflict) {
+		t.Errorf("Expected %v to match %v", err, driver.ErrServerIDConflict)
+	}
+
+	other := &driver.Error{Code: 1236, Message: "binlog truncated in the middle of event"}
+	if err := r.handleServerIDConflict(context.Background(), other); err != other {
+		t.Errorf("Expected other errors to be returned as is, got %v", err)
+	}
+}
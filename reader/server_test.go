@@ -0,0 +1,490 @@
+package reader
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+func startTestServer(t *testing.T) (*binlogtest.Server, *binlogtest.Generator) {
+	g := binlogtest.New()
+	g.FormatDescription()
+	srv := &binlogtest.Server{}
+	srv.AddFile(g.Position().File, g.Bytes())
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	return srv, g
+}
+
+// readXIDs reads events until n transactions are committed and returns their
+// XIDs.
+func readXIDs(ctx context.Context, t *testing.T, r *Reader, n int) []uint64 {
+	var xids []uint64
+	for len(xids) < n {
+		evt, err := r.ReadEvent(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if evt.Header.Type == binlog.EventTypeXID {
+			var xe binlog.XIDEvent
+			if err := xe.Decode(evt.Buffer); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+			xids = append(xids, xe.XID)
+		}
+	}
+	return xids
+}
+
+func TestServerStream(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	srv.Append(g.Position().File, g.XID(1))
+	srv.Append(g.Position().File, g.Rotate("mysql-bin.000002"))
+	srv.AddFile(g.Position().File, g.Bytes())
+	srv.Append(g.Position().File, g.FormatDescription(), g.XID(2))
+
+	if xids := readXIDs(ctx, t, r, 2); xids[0] != 1 || xids[1] != 2 {
+		t.Errorf("Expected transactions [1 2], got %v", xids)
+	}
+	if pos := r.State(); pos != g.Position() {
+		t.Errorf("Expected position %v, got %v", g.Position(), pos)
+	}
+}
+
+func TestServerReconnect(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	srv.Append(g.Position().File, g.XID(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4},
+		WithReconnect(3, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	readXIDs(ctx, t, r, 1)
+	srv.Disconnect()
+	srv.Append(g.Position().File, g.XID(2))
+
+	if xids := readXIDs(ctx, t, r, 1); xids[0] != 2 {
+		t.Errorf("Expected transaction 2, got %v", xids)
+	}
+	if pos := r.State(); pos != g.Position() {
+		t.Errorf("Expected position %v, got %v", g.Position(), pos)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	srv, _ := startTestServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000009", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	_, err = r.ReadEvent(ctx) // Artificial rotate event
+	for err == nil {
+		_, err = r.ReadEvent(ctx)
+	}
+	derr, ok := errors.Cause(err).(*driver.Error)
+	if !ok {
+		t.Fatalf("Expected server error, got %v", err)
+	}
+	if derr.Code != binlogtest.ErrCodeMasterFatalReadingBinlog {
+		t.Errorf("Unexpected error code %d", derr.Code)
+	}
+}
+
+func TestServerCloseAfterFailedSeek(t *testing.T) {
+	srv, g := startTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	srv.Close()
+
+	if err := r.Seek(g.Position()); err == nil {
+		t.Fatal("Expected seek to fail")
+	}
+	if _, err := r.ReadEvent(ctx); err == nil {
+		t.Error("Expected reading to fail without a connection")
+	}
+	if err := r.Close(ctx); err != nil {
+		t.Errorf("Failed to close reader: %v", err)
+	}
+}
+
+func TestServerChecksums(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+	}}
+	tableMap := g.TableMap(table)
+	insert, err := g.Insert(table, []interface{}{42})
+	if err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+	srv.Append(g.Position().File, tableMap, insert, g.XID(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, checksums := range []bool{false, true} {
+		var opts []Option
+		if checksums {
+			opts = append(opts, WithChecksums())
+		}
+		r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4}, opts...)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer r.Close(ctx)
+
+		for {
+			evt, err := r.ReadEvent(ctx)
+			if err != nil {
+				t.Fatalf("Failed to read event: %v", err)
+			}
+			if evt.Header.Type == binlog.EventTypeWriteRowsV2 {
+				re, err := evt.DecodeRows()
+				if err != nil || len(re.Rows) != 1 || re.Rows[0][0] != uint32(42) {
+					t.Errorf("Expected rows event to decode, got %v %v", re.Rows, err)
+				}
+			}
+			if evt.Header.Type != binlog.EventTypeXID {
+				continue
+			}
+			if evt.HasChecksum != checksums {
+				t.Errorf("Expected checksum to be present: %v, got %v", checksums, evt.HasChecksum)
+			}
+			if sum := crc32.ChecksumIEEE(evt.Raw[:len(evt.Raw)-4]); checksums && evt.Checksum != sum {
+				t.Errorf("Expected checksum %08x, got %08x", sum, evt.Checksum)
+			}
+			if n := 8 + 4*len(opts); len(evt.Buffer) != n {
+				t.Errorf("Expected buffer of %d bytes, got %x", n, evt.Buffer)
+			}
+			if sum := binary.LittleEndian.Uint32(evt.Buffer[len(evt.Buffer)-4:]); checksums && sum != evt.Checksum {
+				t.Errorf("Expected buffer to end with checksum %08x, got %08x", evt.Checksum, sum)
+			}
+			var xe binlog.XIDEvent
+			if err := xe.Decode(evt.Body()); err != nil || xe.XID != 1 || len(evt.Body()) != 8 {
+				t.Errorf("Expected body without checksum, got %x", evt.Body())
+			}
+			break
+		}
+	}
+}
+
+func TestServerHeartbeatV2(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	// Heartbeat v2 events report positions beyond 4GB
+	exp := binlog.Position{File: g.Position().File, Offset: 5 << 30}
+	srv.Dump = func(d *binlogtest.Dump) error {
+		if err := d.SendRotate(exp.File, 4); err != nil {
+			return err
+		}
+		if err := d.Send(g.Bytes()[len(binlog.FileMagic):]); err != nil {
+			return err
+		}
+		if err := d.SendHeartbeatV2(exp.File, exp.Offset); err != nil {
+			return err
+		}
+		<-d.Closed()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var positions []binlog.Position
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: exp.File, Offset: 4},
+		WithPositionCallback(func(pos binlog.Position) { positions = append(positions, pos) }))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	for {
+		evt, err := r.ReadEvent(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if evt.Header.Type == binlog.EventTypeHeartbeatV2 {
+			break
+		}
+	}
+	if pos := r.State(); pos != exp {
+		t.Errorf("Expected position %v, got %v", exp, pos)
+	}
+	if len(positions) == 0 || positions[len(positions)-1] != exp {
+		t.Errorf("Expected position callback with %v, got %v", exp, positions)
+	}
+}
+
+func TestServerReconnectRotated(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	srv.Append(g.Position().File, g.XID(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4},
+		WithReconnect(3, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+	readXIDs(ctx, t, r, 1)
+
+	// Server restarts with a new file, the last commit position is the end
+	// of the previous one
+	srv.Disconnect()
+	g.Rotate("mysql-bin.000002") // Never written
+	srv.Append("mysql-bin.000002", g.FormatDescription(), g.XID(2))
+
+	if xids := readXIDs(ctx, t, r, 1); xids[0] != 2 {
+		t.Errorf("Expected transaction 2, got %v", xids)
+	}
+	if pos := r.State(); pos.File != "mysql-bin.000002" {
+		t.Errorf("Expected reading to resume in the next file, got %v", pos)
+	}
+}
+
+// gtidLog builds a binary log of transactions with given GTIDs.
+func gtidLog(sid binlog.SID, gnos ...uint64) *binlogtest.Generator {
+	g := binlogtest.New()
+	g.FormatDescription()
+	for _, gno := range gnos {
+		g.GTID(binlog.GTID{SID: sid, GNO: gno})
+		g.Query("shop", "BEGIN")
+		g.XID(gno)
+	}
+	return g
+}
+
+func TestServerFailover(t *testing.T) {
+	sid := binlog.SID{1}
+	primary, replica := &binlogtest.Server{}, &binlogtest.Server{}
+	g := gtidLog(sid, 1)
+	primary.AddFile(g.Position().File, g.Bytes())
+	// File names and positions differ between hosts
+	g = gtidLog(sid, 1, 2)
+	replica.AddFile("replica-bin.000001", g.Bytes())
+	for _, srv := range []*binlogtest.Server{primary, replica} {
+		if err := srv.Start(); err != nil {
+			t.Fatalf("Failed to start server: %v", err)
+		}
+		defer srv.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(primary.DSN(), driver.Config{ServerID: 1000},
+		WithGTIDSet(binlog.NewGTIDSet()), WithReconnect(3, 10*time.Millisecond), WithFailover(replica.DSN()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+	readXIDs(ctx, t, r, 1)
+
+	primary.Close()
+	if xids := readXIDs(ctx, t, r, 1); xids[0] != 2 {
+		t.Errorf("Expected transaction 2, got %v", xids)
+	}
+	if pos := r.State(); pos.File != "replica-bin.000001" {
+		t.Errorf("Expected reading to continue on the failover host, got %v", pos)
+	}
+	if set := r.GTIDSet().String(); set != "01000000-0000-0000-0000-000000000000:1-2" {
+		t.Errorf("Unexpected GTID set %q", set)
+	}
+}
+
+func TestServerReconnectDedupe(t *testing.T) {
+	sid := binlog.SID{1}
+	g := gtidLog(sid, 1)
+	srv := &binlogtest.Server{}
+	srv.AddFile(g.Position().File, g.Bytes())
+	// The server ignores the transactions the replica has and streams the
+	// whole binary log again
+	srv.Dump = func(d *binlogtest.Dump) error {
+		d.GTIDSet = nil
+		return d.Stream()
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000},
+		WithGTIDSet(binlog.NewGTIDSet()), WithReconnect(3, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+	readXIDs(ctx, t, r, 1)
+
+	srv.Disconnect()
+	g.GTID(binlog.GTID{SID: sid, GNO: 2})
+	g.Query("shop", "BEGIN")
+	g.XID(2)
+	srv.AddFile(g.Position().File, g.Bytes())
+	if xids := readXIDs(ctx, t, r, 1); xids[0] != 2 {
+		t.Errorf("Expected transaction 1 to be skipped, got %v", xids)
+	}
+}
+
+func TestServerCatchUp(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	g.Timestamp = uint32(time.Now().Add(-time.Hour).Unix())
+	srv.Append(g.Position().File, g.XID(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	onLive := 0
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4},
+		WithCatchUp(time.Minute, func() { onLive++ }))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	readXID := func() *Event {
+		for {
+			evt, err := r.ReadEvent(ctx)
+			if err != nil {
+				t.Fatalf("Failed to read event: %v", err)
+			}
+			if evt.Header.Type == binlog.EventTypeXID {
+				return evt
+			}
+		}
+	}
+	if evt := readXID(); evt.Live || r.Live() || onLive != 0 {
+		t.Errorf("Expected old transaction to be caught up on")
+	}
+	g.Timestamp = uint32(time.Now().Unix())
+	srv.Append(g.Position().File, g.XID(2), g.XID(3))
+	for i := 0; i < 2; i++ {
+		if evt := readXID(); !evt.Live || !r.Live() {
+			t.Errorf("Expected recent transaction to be live")
+		}
+	}
+	if onLive != 1 {
+		t.Errorf("Expected live callback to be called once, got %d", onLive)
+	}
+}
+
+func TestServerPipelineCancel(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(context.Background())
+	srv.Append(g.Position().File, g.XID(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := r.Pipeline(ctx, 2, 4)
+	for de := range out {
+		if de.Err != nil {
+			t.Fatalf("Failed to read event: %v", de.Err)
+		}
+		if de.Header.Type == binlog.EventTypeXID {
+			break
+		}
+	}
+
+	// The reader is now waiting for the next event which never arrives
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Expected output channel to be closed after cancelling")
+		}
+	}
+}
+
+func TestServerPipelineArenas(t *testing.T) {
+	srv, g := startTestServer(t)
+	defer srv.Close()
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+	}}
+	srv.Append(g.Position().File, g.TableMap(table))
+	for id := 1; id <= 8; id++ {
+		insert, err := g.Insert(table, []interface{}{id})
+		if err != nil {
+			t.Fatalf("Failed to build rows event: %v", err)
+		}
+		srv.Append(g.Position().File, insert)
+	}
+	srv.Append(g.Position().File, g.XID(1))
+
+	r, err := New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4},
+		WithTransactionArenas())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var ids []interface{}
+	for de := range r.Pipeline(ctx, 4, 8) {
+		if de.Err != nil {
+			t.Fatalf("Failed to read event: %v", de.Err)
+		}
+		if de.Arena != nil {
+			t.Errorf("Expected %s event to be decoded without an arena", de.Header.Type)
+		}
+		if de.Rows != nil {
+			ids = append(ids, de.Rows.Rows[0][0])
+		}
+		if de.Header.Type == binlog.EventTypeXID {
+			break
+		}
+	}
+	if len(ids) != 8 || ids[0] != uint32(1) || ids[7] != uint32(8) {
+		t.Errorf("Expected rows 1 to 8 in order, got %v", ids)
+	}
+}
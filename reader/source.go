@@ -0,0 +1,65 @@
+package reader
+
+import (
+	"context"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// EventSource provides raw events to a reader created with NewSource. Events
+// are decoded, filtered and assembled into transactions exactly like events
+// received from a server, so everything built on top of the reader works the
+// same regardless of where events come from. Binary log files and captures are
+// read with sources too, see NewFile and NewReplay.
+type EventSource interface {
+	// ReadEvent returns the next event including the header and, if the
+	// binary log has them, the checksum. The event is only valid until the
+	// next call. Nil is returned at the end of the stream.
+	ReadEvent(ctx context.Context) ([]byte, error)
+	// Close releases resources held by the source.
+	Close() error
+}
+
+// EventPositioner is implemented by sources that know the position of every
+// event, such as captures of streams that were interrupted by reconnects.
+// Otherwise positions are derived from events just like when reading from a
+// server.
+type EventPositioner interface {
+	// EventPosition returns the position of the last event returned by
+	// ReadEvent.
+	EventPosition() binlog.Position
+}
+
+// NewSource creates a reader that reads events from the given source which
+// starts at the given position. ErrEndOfLog is returned once the source is
+// exhausted. Options that require a server connection have no effect, seeking
+// is not supported.
+func NewSource(src EventSource, pos binlog.Position, opts ...Option) (*Reader, error) {
+	r := &Reader{state: pos, stats: newStats(), source: src}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.tableMap = newTableMap(r.tableMapSize)
+	r.executed = binlog.NewGTIDSet()
+	r.commitPos = r.state
+	r.stats.setPosition(r.state)
+	return r, nil
+}
+
+// readPacket reads the next event from the connection or the event source.
+func (r *Reader) readPacket(ctx context.Context) ([]byte, error) {
+	if r.source == nil {
+		if r.conn == nil {
+			if r.connErr != nil {
+				return nil, r.connErr
+			}
+			return nil, ErrClosed
+		}
+		return r.conn.ReadPacket(ctx)
+	}
+	b, err := r.source.ReadEvent(ctx)
+	if p, ok := r.source.(EventPositioner); ok && b != nil {
+		r.state = p.EventPosition()
+	}
+	return b, err
+}
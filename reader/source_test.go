@@ -0,0 +1,67 @@
+package reader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+type sliceSource struct {
+	events [][]byte
+	closed bool
+}
+
+func (s *sliceSource) ReadEvent(ctx context.Context) ([]byte, error) {
+	if len(s.events) == 0 {
+		return nil, nil
+	}
+	evt := s.events[0]
+	s.events = s.events[1:]
+	return evt, nil
+}
+
+func (s *sliceSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestNewSource(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+	}}
+	g := binlogtest.New()
+	src := &sliceSource{}
+	start := g.Position()
+	src.events = append(src.events, g.FormatDescription(), g.Query("shop", "BEGIN"), g.TableMap(table))
+	rows, err := g.Insert(table, []interface{}{1}, []interface{}{2})
+	if err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+	src.events = append(src.events, rows, g.XID(1))
+
+	r, err := NewSource(src, start)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	tr := NewTransactionReader(r)
+	txn, err := tr.ReadTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read transaction: %v", err)
+	}
+	if len(txn.Changes) != 2 || txn.Position != g.Position() {
+		t.Errorf("Unexpected transaction at %v with changes %v", txn.Position, txn.Changes)
+	}
+	if _, err := tr.ReadTransaction(context.Background()); err != ErrEndOfLog {
+		t.Errorf("Expected ErrEndOfLog, got %v", err)
+	}
+	if err := r.Seek(binlog.Position{File: start.File, Offset: 4}); err != ErrReplaySeek {
+		t.Errorf("Expected ErrReplaySeek, got %v", err)
+	}
+	r.Close(context.Background())
+	if !src.closed {
+		t.Errorf("Expected source to be closed")
+	}
+}
@@ -0,0 +1,129 @@
+package reader
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// TransactionOption is a transaction reader configuration option.
+type TransactionOption func(t *TransactionReader)
+
+// WithSpillToDisk makes the transaction reader keep rows events of a
+// transaction in a temporary file in the given directory once the size of its
+// rows events exceeds the budget in bytes. Spilled events are decoded again
+// when changes are iterated with ForEachChange, so that a huge transaction is
+// still delivered as a whole without holding all of its rows in memory. An
+// empty directory stands for the default directory for temporary files.
+func WithSpillToDisk(budget int, dir string) TransactionOption {
+	return func(t *TransactionReader) {
+		t.spillBudget = budget
+		t.spillDir = dir
+	}
+}
+
+// spill is a temporary file with rows events of a transaction.
+type spill struct {
+	f      *os.File
+	size   int64
+	events []spilledEvent
+}
+
+// spilledEvent is a rows event without the buffer, which is kept in the file.
+type spilledEvent struct {
+	evt    Event
+	offset int64
+	length int
+}
+
+func newSpill(dir string) (*spill, error) {
+	f, err := ioutil.TempFile(dir, "bocadillo-txn-")
+	if err != nil {
+		return nil, errors.Annotate(err, "create spill file")
+	}
+	return &spill{f: f}, nil
+}
+
+// add writes a rows event to the file.
+func (s *spill) add(evt *Event) error {
+	if _, err := s.f.Write(evt.Buffer); err != nil {
+		return errors.Annotate(err, "write spill file")
+	}
+	se := spilledEvent{evt: *evt, offset: s.size, length: len(evt.Buffer)}
+	// Only the details required to decode rows are kept
+	se.evt.Buffer, se.evt.Raw, se.evt.Arena, se.evt.rawBuf, se.evt.stats = nil, nil, nil, nil, nil
+	se.evt.zeroCopy = false
+	s.events = append(s.events, se)
+	s.size += int64(len(evt.Buffer))
+	return nil
+}
+
+// forEach decodes spilled events and passes their changes to the function.
+func (s *spill) forEach(fn func(RowChange) error) error {
+	var buf []byte
+	for _, se := range s.events {
+		if cap(buf) < se.length {
+			buf = make([]byte, se.length)
+		}
+		buf = buf[:se.length]
+		if _, err := s.f.ReadAt(buf, se.offset); err != nil && err != io.EOF {
+			return errors.Annotate(err, "read spill file")
+		}
+		evt := se.evt
+		evt.Buffer = buf
+		re, err := evt.DecodeRows()
+		if err != nil {
+			return errors.Annotate(err, "decode rows event")
+		}
+		for _, c := range rowChanges(*evt.Table, re) {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remove closes and removes the file.
+func (s *spill) remove() error {
+	err := s.f.Close()
+	if rerr := os.Remove(s.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Spilled returns true if changes of the transaction were spilled to disk, in
+// which case Changes only contains changes read before the budget was exceeded.
+func (t *Transaction) Spilled() bool {
+	return t.spill != nil
+}
+
+// ForEachChange passes all changes of the transaction to the function in
+// order, including the changes spilled to disk. Iteration stops at the first
+// error returned by the function.
+func (t *Transaction) ForEachChange(fn func(RowChange) error) error {
+	for _, c := range t.Changes {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	if t.spill == nil {
+		return nil
+	}
+	return t.spill.forEach(fn)
+}
+
+// Close removes the temporary file of a spilled transaction, changes could not
+// be iterated afterwards. It has no effect on transactions that were not
+// spilled.
+func (t *Transaction) Close() error {
+	if t.spill == nil {
+		return nil
+	}
+	err := t.spill.remove()
+	t.spill = nil
+	return err
+}
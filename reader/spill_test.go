@@ -0,0 +1,68 @@
+package reader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+func TestSpillToDisk(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+		{Name: "status", Type: mysql.ColumnTypeVarchar},
+	}}
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.Query("shop", "BEGIN")
+	var exp [][]interface{}
+	for i := 1; i <= 10; i++ {
+		g.TableMap(table)
+		if _, err := g.Insert(table, []interface{}{i, "pending"}); err != nil {
+			t.Fatalf("Failed to build rows event: %v", err)
+		}
+		exp = append(exp, []interface{}{uint32(i), "pending"})
+	}
+	g.XID(1)
+
+	path := writeGenerated(t, g)
+	spillDir := filepath.Join(filepath.Dir(path), "spill")
+	if err := os.Mkdir(spillDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	r, err := NewFile(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer r.Close(context.Background())
+
+	txn, err := NewTransactionReader(r, WithSpillToDisk(64, spillDir)).ReadTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read transaction: %v", err)
+	}
+	if !txn.Spilled() || len(txn.Changes) == 0 || len(txn.Changes) == len(exp) {
+		t.Fatalf("Expected transaction to be partially spilled, got %d changes in memory", len(txn.Changes))
+	}
+	var rows [][]interface{}
+	err = txn.ForEachChange(func(c RowChange) error {
+		rows = append(rows, c.After)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate changes: %v", err)
+	}
+	if !reflect.DeepEqual(rows, exp) {
+		t.Errorf("Expected rows %v, got %v", exp, rows)
+	}
+	if err := txn.Close(); err != nil {
+		t.Fatalf("Failed to close transaction: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(spillDir); len(files) != 0 {
+		t.Errorf("Expected spill file to be removed, found %d files", len(files))
+	}
+}
@@ -0,0 +1,163 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// ErrInvalidBinlogStatement is returned when a BINLOG statement doesn't
+// contain valid base64 encoded events.
+var ErrInvalidBinlogStatement = errors.New("Invalid BINLOG statement")
+
+// Statements produced by mysqlbinlog. Large events are split into fragments
+// assigned to user variables first, which are then passed to BINLOG together.
+//
+//	BINLOG '
+//	fAS3SA8BAAAAZgAAAGoAAAAAAAQANS4xLjI2LWxvZwAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+//	'/*!*/;
+//	SET @binlog_fragment_0='...'/*!*/;
+//	BINLOG @binlog_fragment_0, @binlog_fragment_1/*!*/;
+var (
+	binlogStmtRe     = regexp.MustCompile(`^BINLOG\s+'(.*)$`)
+	fragmentStmtRe   = regexp.MustCompile(`^BINLOG\s+(@binlog_fragment_\d+(?:\s*,\s*@binlog_fragment_\d+)*)`)
+	fragmentSetRe    = regexp.MustCompile(`^SET\s+@(binlog_fragment_\d+)\s*=\s*'(.*)$`)
+	fragmentNameRe   = regexp.MustCompile(`binlog_fragment_\d+`)
+	base64Whitespace = strings.NewReplacer(" ", "", "\t", "", "\r", "", "\n", "")
+)
+
+// maxStatementLine limits the length of lines of mysqlbinlog output. Base64
+// strings are wrapped, but fragments of large events may not be.
+const maxStatementLine = 64 << 20
+
+// NewStatements creates a reader of events contained in BINLOG statements
+// produced by mysqlbinlog with --base64-output, which is handy for analyzing
+// support bundles that come without binary log files. Everything else in the
+// output is ignored, including events printed as SQL statements, such as
+// queries and commits. Events are positioned in the given file at the offsets
+// derived from their headers. ErrEndOfLog is returned once the output is
+// exhausted. Options that require a server connection have no effect, seeking
+// is not supported.
+func NewStatements(rd io.Reader, file string, opts ...Option) (*Reader, error) {
+	src := &statementSource{
+		sc:        bufio.NewScanner(rd),
+		fragments: make(map[string][]byte),
+		pos:       binlog.Position{File: file, Offset: 4},
+	}
+	src.sc.Buffer(make([]byte, 0, 64<<10), maxStatementLine)
+	return NewSource(src, src.pos, opts...)
+}
+
+// statementSource reads events from mysqlbinlog output.
+type statementSource struct {
+	sc        *bufio.Scanner
+	fragments map[string][]byte
+	// pending contains decoded events that were not returned yet
+	pending []byte
+	pos     binlog.Position
+}
+
+// ReadEvent returns the next event of the last BINLOG statement, reading the
+// next statement once all of its events are returned.
+func (s *statementSource) ReadEvent(ctx context.Context) ([]byte, error) {
+	for len(s.pending) == 0 {
+		data, err := s.nextStatement()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.pending = data
+	}
+	if len(s.pending) < minEventHeadLen {
+		return nil, errors.Annotatef(ErrInvalidBinlogStatement, "event of %d bytes", len(s.pending))
+	}
+	n := binary.LittleEndian.Uint32(s.pending[eventLenOffset:])
+	if n < minEventHeadLen || int(n) > len(s.pending) {
+		return nil, errors.Annotatef(ErrInvalidBinlogStatement, "event of %d bytes declares %d bytes", len(s.pending), n)
+	}
+	evt := s.pending[:n]
+	s.pending = s.pending[n:]
+	// Events printed as SQL leave gaps between positions
+	if next := binary.LittleEndian.Uint32(evt[nextPosOffset:]); next >= n {
+		s.pos.Offset = uint64(next - n)
+	}
+	return evt, nil
+}
+
+// EventPosition returns the position of the last event returned by ReadEvent.
+func (s *statementSource) EventPosition() binlog.Position {
+	return s.pos
+}
+
+func (s *statementSource) Close() error {
+	return nil
+}
+
+// nextStatement returns events of the next BINLOG statement. Fragments are
+// collected along the way. io.EOF is returned at the end of the output.
+func (s *statementSource) nextStatement() ([]byte, error) {
+	for s.sc.Scan() {
+		line := strings.TrimSpace(s.sc.Text())
+		if m := binlogStmtRe.FindStringSubmatch(line); m != nil {
+			return s.readBase64(m[1])
+		}
+		if m := fragmentSetRe.FindStringSubmatch(line); m != nil {
+			data, err := s.readBase64(m[2])
+			if err != nil {
+				return nil, err
+			}
+			s.fragments[m[1]] = data
+			continue
+		}
+		if m := fragmentStmtRe.FindStringSubmatch(line); m != nil {
+			var data []byte
+			for _, name := range fragmentNameRe.FindAllString(m[1], -1) {
+				frag, ok := s.fragments[name]
+				if !ok {
+					return nil, errors.Annotatef(ErrInvalidBinlogStatement, "fragment %s is not set", name)
+				}
+				data = append(data, frag...)
+				delete(s.fragments, name)
+			}
+			return data, nil
+		}
+	}
+	if err := s.sc.Err(); err != nil {
+		return nil, errors.Annotate(err, "read statements")
+	}
+	return nil, io.EOF
+}
+
+// readBase64 reads a quoted base64 string that starts with the given part of
+// the current line and decodes it.
+func (s *statementSource) readBase64(line string) ([]byte, error) {
+	var b strings.Builder
+	for {
+		if i := strings.IndexByte(line, '\''); i >= 0 {
+			b.WriteString(line[:i])
+			break
+		}
+		b.WriteString(line)
+		if !s.sc.Scan() {
+			if err := s.sc.Err(); err != nil {
+				return nil, errors.Annotate(err, "read statements")
+			}
+			return nil, errors.Annotate(ErrInvalidBinlogStatement, "unterminated string")
+		}
+		line = s.sc.Text()
+	}
+	data, err := base64.StdEncoding.DecodeString(base64Whitespace.Replace(b.String()))
+	if err != nil {
+		return nil, errors.Annotatef(ErrInvalidBinlogStatement, "decode base64: %v", err)
+	}
+	return data, nil
+}
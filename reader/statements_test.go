@@ -0,0 +1,105 @@
+package reader
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// binlogStatement formats events the way mysqlbinlog does.
+func binlogStatement(events ...[]byte) string {
+	var data []byte
+	for _, evt := range events {
+		data = append(data, evt...)
+	}
+	s := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	b.WriteString("BINLOG '\n")
+	for len(s) > 76 {
+		b.WriteString(s[:76] + "\n")
+		s = s[76:]
+	}
+	b.WriteString(s + "\n'/*!*/;\n")
+	return b.String()
+}
+
+func TestStatements(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+	}}
+	g := binlogtest.New()
+	var out strings.Builder
+	out.WriteString("# at 4\n#201017  7:00:00 server id 1  end_log_pos 120\n")
+	out.WriteString(binlogStatement(g.FormatDescription()))
+	g.Query("shop", "BEGIN")
+	out.WriteString("BEGIN\n/*!*/;\n")
+	rowsPos := g.Position().Offset
+	tm := g.TableMap(table)
+	insert, err := g.Insert(table, []interface{}{7})
+	if err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+	out.WriteString(binlogStatement(tm, insert))
+	g.XID(1)
+	out.WriteString("COMMIT/*!*/;\n")
+	// Large events are split into fragments
+	g.Query("shop", "BEGIN")
+	fragPos := g.Position().Offset
+	tm = g.TableMap(table)
+	insert, _ = g.Insert(table, []interface{}{8})
+	frag := base64.StdEncoding.EncodeToString(append(tm, insert...))
+	out.WriteString("SET @binlog_fragment_0='" + frag[:40] + "'/*!*/;\n")
+	out.WriteString("SET @binlog_fragment_1='\n" + frag[40:] + "\n'/*!*/;\n")
+	out.WriteString("BINLOG @binlog_fragment_0, @binlog_fragment_1/*!*/;\n")
+
+	r, err := NewStatements(strings.NewReader(out.String()), "mysql-bin.000001")
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	defer r.Close(context.Background())
+
+	var offsets []uint64
+	var ids []interface{}
+	for {
+		evt, err := r.ReadEvent(context.Background())
+		if err == ErrEndOfLog {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		offsets = append(offsets, evt.Offset)
+		if binlog.RowsEventVersion(evt.Header.Type) >= 0 {
+			re, err := evt.DecodeRows()
+			if err != nil {
+				t.Fatalf("Failed to decode rows: %v", err)
+			}
+			ids = append(ids, re.Rows[0][0])
+		}
+	}
+	if len(offsets) != 5 || offsets[0] != 4 || offsets[1] != rowsPos || offsets[3] != fragPos {
+		t.Errorf("Unexpected event offsets %v", offsets)
+	}
+	if len(ids) != 2 || ids[0] != uint32(7) || ids[1] != uint32(8) {
+		t.Errorf("Expected rows [7 8], got %v", ids)
+	}
+	if r.State().File != "mysql-bin.000001" {
+		t.Errorf("Unexpected position %v", r.State())
+	}
+}
+
+func TestStatementsInvalid(t *testing.T) {
+	r, err := NewStatements(strings.NewReader("BINLOG '\nnot base64!\n'/*!*/;\n"), "mysql-bin.000001")
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	defer r.Close(context.Background())
+	if _, err := r.ReadEvent(context.Background()); err == nil {
+		t.Errorf("Expected invalid statement to fail")
+	}
+}
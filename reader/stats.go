@@ -0,0 +1,130 @@
+package reader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// Stats is a snapshot of reader counters. It is meant to be embedded into
+// application health endpoints.
+type Stats struct {
+	// Events contains the number of events received, by type.
+	Events map[binlog.EventType]uint64
+	// Bytes is the total size of events received.
+	Bytes uint64
+	// RowsDecoded is the number of rows decoded from rows events.
+	RowsDecoded uint64
+	// DecodeErrors is the number of events that failed to decode.
+	DecodeErrors uint64
+	// Position is the current position in the binary log.
+	Position binlog.Position
+	// Uptime is the time elapsed since the connection was established.
+	Uptime time.Duration
+	// LastEventTime is the timestamp of the last received event. It is zero
+	// if no events were received yet or the last event was artificial.
+	LastEventTime time.Time
+	// Lag is the time elapsed since the last received event was written to
+	// the binary log. It is zero once a heartbeat is received, because the
+	// master only sends heartbeats when there are no more events to send, so
+	// lag is only accurate on idle streams with a heartbeat period set.
+	Lag time.Duration
+}
+
+// stats collects reader counters. It is safe for concurrent use.
+type stats struct {
+	mu            sync.Mutex
+	events        map[binlog.EventType]uint64
+	bytes         uint64
+	rows          uint64
+	decodeErrors  uint64
+	position      binlog.Position
+	connectedAt   time.Time
+	lastEventTime time.Time
+	lastPacketAt  time.Time
+	// idle is set when a heartbeat is received after the last event
+	idle bool
+}
+
+func newStats() *stats {
+	return &stats{
+		events:      make(map[binlog.EventType]uint64),
+		connectedAt: time.Now(),
+	}
+}
+
+func (s *stats) connected() {
+	s.mu.Lock()
+	s.connectedAt = time.Now()
+	s.lastPacketAt = s.connectedAt
+	s.mu.Unlock()
+}
+
+func (s *stats) eventReceived(h binlog.EventHeader, size int) {
+	s.mu.Lock()
+	s.events[h.Type]++
+	s.bytes += uint64(size)
+	s.lastPacketAt = time.Now()
+	if h.Type == binlog.EventTypeHeartbeet || h.Type == binlog.EventTypeHeartbeatV2 {
+		s.idle = true
+	} else if h.Timestamp > 0 {
+		s.lastEventTime = time.Unix(int64(h.Timestamp), 0)
+		s.idle = false
+	}
+	s.mu.Unlock()
+}
+
+func (s *stats) rowsDecoded(n int) {
+	s.mu.Lock()
+	s.rows += uint64(n)
+	s.mu.Unlock()
+}
+
+func (s *stats) decodeError() {
+	s.mu.Lock()
+	s.decodeErrors++
+	s.mu.Unlock()
+}
+
+func (s *stats) setPosition(pos binlog.Position) {
+	s.mu.Lock()
+	s.position = pos
+	s.mu.Unlock()
+}
+
+// sinceLastPacket returns the time elapsed since the last event, including
+// heartbeats, was received or since the connection was established.
+func (s *stats) sinceLastPacket() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastPacketAt)
+}
+
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make(map[binlog.EventType]uint64, len(s.events))
+	for et, n := range s.events {
+		events[et] = n
+	}
+	var lag time.Duration
+	if !s.idle && !s.lastEventTime.IsZero() {
+		lag = time.Since(s.lastEventTime)
+	}
+	if lag < 0 {
+		// Clocks of the master and the reader are out of sync
+		lag = 0
+	}
+	return Stats{
+		Events:        events,
+		Bytes:         s.bytes,
+		RowsDecoded:   s.rows,
+		DecodeErrors:  s.decodeErrors,
+		Position:      s.position,
+		Uptime:        time.Since(s.connectedAt),
+		LastEventTime: s.lastEventTime,
+		Lag:           lag,
+	}
+}
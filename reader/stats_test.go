@@ -0,0 +1,74 @@
+package reader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+)
+
+func TestStats(t *testing.T) {
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.Query("shop", "BEGIN")
+	g.XID(1)
+
+	r := openGenerated(t, g)
+	for {
+		if _, err := r.ReadEvent(context.Background()); err == ErrEndOfLog {
+			break
+		} else if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+	}
+
+	st := r.Stats()
+	for _, et := range []binlog.EventType{binlog.EventTypeFormatDescription, binlog.EventTypeQuery, binlog.EventTypeXID} {
+		if st.Events[et] != 1 {
+			t.Errorf("Expected one %s, got %d", et, st.Events[et])
+		}
+	}
+	if exp := uint64(len(g.Bytes()) - len(binlog.FileMagic)); st.Bytes != exp {
+		t.Errorf("Expected %d bytes, got %d", exp, st.Bytes)
+	}
+	if st.Position != g.Position() {
+		t.Errorf("Expected position %v, got %v", g.Position(), st.Position)
+	}
+	// Snapshots are copies
+	st.Events[binlog.EventTypeXID] = 10
+	if n := r.Stats().Events[binlog.EventTypeXID]; n != 1 {
+		t.Errorf("Expected snapshot to be a copy, got %d events", n)
+	}
+}
+
+func TestStatsLag(t *testing.T) {
+	s := newStats()
+	if lag := s.snapshot().Lag; lag != 0 {
+		t.Errorf("Expected no lag before events, got %v", lag)
+	}
+
+	ts := time.Now().Add(-time.Minute)
+	s.eventReceived(binlog.EventHeader{Type: binlog.EventTypeXID, Timestamp: uint32(ts.Unix())}, 31)
+	if lag := s.snapshot().Lag; lag < time.Minute-time.Second || lag > 2*time.Minute {
+		t.Errorf("Expected lag of a minute, got %v", lag)
+	}
+	if st := s.snapshot(); !st.LastEventTime.Equal(time.Unix(ts.Unix(), 0)) {
+		t.Errorf("Expected last event time %v, got %v", ts, st.LastEventTime)
+	}
+
+	// Heartbeats mean the reader has caught up
+	s.eventReceived(binlog.EventHeader{Type: binlog.EventTypeHeartbeet}, 39)
+	if lag := s.snapshot().Lag; lag != 0 {
+		t.Errorf("Expected no lag after heartbeat, got %v", lag)
+	}
+	// Events from the future don't make lag negative
+	s.eventReceived(binlog.EventHeader{Type: binlog.EventTypeXID, Timestamp: uint32(time.Now().Add(time.Hour).Unix())}, 31)
+	if lag := s.snapshot().Lag; lag != 0 {
+		t.Errorf("Expected no lag with clock skew, got %v", lag)
+	}
+	if st := s.snapshot(); st.Bytes != 101 || st.Events[binlog.EventTypeXID] != 2 {
+		t.Errorf("Unexpected counters %+v", st)
+	}
+}
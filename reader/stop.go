@@ -0,0 +1,100 @@
+package reader
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/juju/errors"
+)
+
+// ErrStopReached is returned by ReadEvent once a stop condition is reached. It
+// means the stream has ended cleanly, just like io.EOF.
+var ErrStopReached = errors.New("Stop condition reached")
+
+// stopConditions define when the stream should end.
+type stopConditions struct {
+	pos  *binlog.Position
+	gtid *binlog.GTID
+	time time.Time
+}
+
+// WithStopAtPosition makes the stream end before the first event that starts
+// at or after the given position.
+func WithStopAtPosition(pos binlog.Position) Option {
+	return func(r *Reader) {
+		r.stop.pos = &pos
+	}
+}
+
+// WithStopAtGTID makes the stream end after the transaction with the given
+// GTID is committed. All events of that transaction are delivered.
+func WithStopAtGTID(gtid binlog.GTID) Option {
+	return func(r *Reader) {
+		r.stop.gtid = &gtid
+	}
+}
+
+// WithStopAtTime makes the stream end before the first event with a timestamp
+// later than the given time.
+func WithStopAtTime(t time.Time) Option {
+	return func(r *Reader) {
+		r.stop.time = t
+	}
+}
+
+// reachedPosition returns true if the next event would start at or after the
+// stop position.
+func (c stopConditions) reachedPosition(state binlog.Position) bool {
+	if c.pos == nil || state.File == "" {
+		return false
+	}
+	if state.File == c.pos.File {
+		return state.Offset >= c.pos.Offset
+	}
+	return compareFileNames(state.File, c.pos.File) > 0
+}
+
+// reachedTime returns true if the event is later than the stop time.
+// Artificial events have zero timestamps and are never considered late.
+func (c stopConditions) reachedTime(h binlog.EventHeader) bool {
+	return !c.time.IsZero() && h.Timestamp > 0 && int64(h.Timestamp) > c.time.Unix()
+}
+
+// reachedGTID returns true if the event ends the stop transaction.
+func (c stopConditions) reachedGTID(et binlog.EventType, body []byte, gtid binlog.GTID) bool {
+	return c.gtid != nil && gtid == *c.gtid && endsTransaction(et, body)
+}
+
+// endsTransaction returns true for events that commit a transaction: XID
+// events and query events other than BEGIN (COMMIT or a DDL statement that is a
+// transaction of its own).
+func endsTransaction(et binlog.EventType, body []byte) bool {
+	switch et {
+	case binlog.EventTypeXID:
+		return true
+	case binlog.EventTypeQuery:
+		var qe binlog.QueryEvent
+		if qe.Decode(body) != nil {
+			return false
+		}
+		return !strings.EqualFold(strings.TrimSpace(string(qe.Query)), "BEGIN")
+	default:
+		return false
+	}
+}
+
+// compareFileNames compares binary log file names. Names share the same base
+// and differ by a numeric extension which could grow in length.
+func compareFileNames(a, b string) int {
+	if len(a) != len(b) {
+		ai, bi := strings.LastIndexByte(a, '.'), strings.LastIndexByte(b, '.')
+		if ai >= 0 && bi >= 0 && a[:ai] == b[:bi] {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,91 @@
+package reader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+)
+
+func TestCompareFileNames(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		exp  int
+	}{
+		{"mysql-bin.000001", "mysql-bin.000001", 0},
+		{"mysql-bin.000001", "mysql-bin.000002", -1},
+		{"mysql-bin.000010", "mysql-bin.000009", 1},
+		// Extensions grow in length once they overflow
+		{"mysql-bin.999999", "mysql-bin.1000000", -1},
+		{"mysql-bin.1000000", "mysql-bin.999999", 1},
+	} {
+		if c := compareFileNames(test.a, test.b); c != test.exp {
+			t.Errorf("Expected %q compared to %q to be %d, got %d", test.a, test.b, test.exp, c)
+		}
+	}
+
+	stop := stopConditions{pos: &binlog.Position{File: "mysql-bin.999999", Offset: 100}}
+	for pos, exp := range map[binlog.Position]bool{
+		{File: "mysql-bin.999999", Offset: 99}:  false,
+		{File: "mysql-bin.999999", Offset: 100}: true,
+		{File: "mysql-bin.1000000", Offset: 4}:  true,
+		{File: "mysql-bin.999998", Offset: 200}: false,
+	} {
+		if stop.reachedPosition(pos) != exp {
+			t.Errorf("Expected reached position at %v to be %v", pos, exp)
+		}
+	}
+}
+
+func TestStopConditions(t *testing.T) {
+	sid := binlog.SID{1}
+	start := time.Unix(1500000000, 0)
+	g := binlogtest.New()
+	g.FormatDescription()
+	var ends []binlog.Position
+	for i := 1; i <= 3; i++ {
+		g.Timestamp = uint32(start.Add(time.Duration(i) * time.Minute).Unix())
+		g.GTID(binlog.GTID{SID: sid, GNO: uint64(i)})
+		g.Query("shop", "BEGIN")
+		g.XID(uint64(i))
+		ends = append(ends, g.Position())
+	}
+	path := writeGenerated(t, g)
+
+	for name, test := range map[string]struct {
+		opt  Option
+		xids int
+	}{
+		"position": {WithStopAtPosition(ends[0]), 1},
+		"gtid":     {WithStopAtGTID(binlog.GTID{SID: sid, GNO: 2}), 2},
+		"time":     {WithStopAtTime(start.Add(2 * time.Minute)), 2},
+	} {
+		r, err := NewFile(path, 0, test.opt)
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		xids := 0
+		for {
+			evt, err := r.ReadEvent(context.Background())
+			if err == ErrStopReached {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: failed to read event: %v", name, err)
+			}
+			if evt.Header.Type == binlog.EventTypeXID {
+				xids++
+			}
+		}
+		if xids != test.xids {
+			t.Errorf("%s: expected %d transactions before stopping, got %d", name, test.xids, xids)
+		}
+		// The stream stays ended
+		if _, err := r.ReadEvent(context.Background()); err != ErrStopReached {
+			t.Errorf("%s: expected stop error, got %v", name, err)
+		}
+		r.Close(context.Background())
+	}
+}
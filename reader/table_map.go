@@ -0,0 +1,86 @@
+package reader
+
+import (
+	"container/list"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+const defaultTableMapSize = 100
+
+// tableMap is an LRU cache of table descriptions indexed by table ID. Tables
+// referenced by the current statement are never evicted, the cache could
+// temporarily exceed its capacity because of that.
+type tableMap struct {
+	capacity int
+	items    map[uint64]*list.Element
+	order    *list.List
+	pinned   map[uint64]struct{}
+}
+
+type tableMapEntry struct {
+	id uint64
+	td binlog.TableDescription
+}
+
+func newTableMap(capacity int) *tableMap {
+	if capacity <= 0 {
+		capacity = defaultTableMapSize
+	}
+	return &tableMap{
+		capacity: capacity,
+		items:    make(map[uint64]*list.Element),
+		order:    list.New(),
+		pinned:   make(map[uint64]struct{}),
+	}
+}
+
+// add adds or replaces a table description and pins it until the end of the
+// current statement.
+func (m *tableMap) add(id uint64, td binlog.TableDescription) {
+	if el, ok := m.items[id]; ok {
+		el.Value.(*tableMapEntry).td = td
+		m.order.MoveToFront(el)
+	} else {
+		m.items[id] = m.order.PushFront(&tableMapEntry{id: id, td: td})
+	}
+	m.pinned[id] = struct{}{}
+}
+
+// get returns a table description for the given ID and marks it as recently
+// used.
+func (m *tableMap) get(id uint64) (binlog.TableDescription, bool) {
+	el, ok := m.items[id]
+	if !ok {
+		return binlog.TableDescription{}, false
+	}
+	m.order.MoveToFront(el)
+	m.pinned[id] = struct{}{}
+	return el.Value.(*tableMapEntry).td, true
+}
+
+// endStatement unpins tables used by the statement and evicts least recently
+// used tables that exceed the capacity.
+func (m *tableMap) endStatement() {
+	for id := range m.pinned {
+		delete(m.pinned, id)
+	}
+	for m.order.Len() > m.capacity {
+		el := m.order.Back()
+		m.order.Remove(el)
+		delete(m.items, el.Value.(*tableMapEntry).id)
+	}
+}
+
+// snapshot returns a copy of all cached table descriptions.
+func (m *tableMap) snapshot() map[uint64]binlog.TableDescription {
+	tables := make(map[uint64]binlog.TableDescription, len(m.items))
+	for id, el := range m.items {
+		tables[id] = el.Value.(*tableMapEntry).td
+	}
+	return tables
+}
+
+func (m *tableMap) len() int {
+	return m.order.Len()
+}
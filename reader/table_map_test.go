@@ -0,0 +1,97 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader/schema"
+)
+
+func TestTableMapEviction(t *testing.T) {
+	m := newTableMap(2)
+	m.add(1, binlog.TableDescription{TableName: "a"})
+	m.add(2, binlog.TableDescription{TableName: "b"})
+	m.add(3, binlog.TableDescription{TableName: "c"})
+
+	// All tables are used by the current statement
+	if m.len() != 3 {
+		t.Fatalf("Expected 3 tables before the end of statement, got %d", m.len())
+	}
+
+	m.endStatement()
+	if _, ok := m.get(1); ok {
+		t.Error("Expected least recently used table to be evicted")
+	}
+	for _, id := range []uint64{2, 3} {
+		if _, ok := m.get(id); !ok {
+			t.Errorf("Expected table %d to be retained", id)
+		}
+	}
+
+	// Table 2 is used again by this statement, table 3 is least recently used
+	m.endStatement()
+	m.get(2)
+	m.add(4, binlog.TableDescription{TableName: "d"})
+	m.endStatement()
+	if _, ok := m.get(3); ok {
+		t.Error("Expected table 3 to be evicted")
+	}
+	if td, ok := m.get(2); !ok || td.TableName != "b" {
+		t.Error("Expected table 2 to be retained")
+	}
+}
+
+func TestUnknownTableIDError(t *testing.T) {
+	r := &Reader{}
+	_, err := r.unknownTableID(context.Background(), 42)
+	var uerr *UnknownTableIDError
+	if !errors.As(err, &uerr) || uerr.TableID != 42 {
+		t.Fatalf("Expected unknown table ID 42 error, got %v", err)
+	}
+	if !errors.Is(err, ErrUnknownTableID) {
+		t.Errorf("Expected %v to match ErrUnknownTableID", err)
+	}
+}
+
+type stubDescriptions map[string]binlog.TableDescription
+
+func (s stubDescriptions) FetchDescription(database, table string) (*binlog.TableDescription, error) {
+	td, ok := s[database+"."+table]
+	if !ok {
+		return nil, nil
+	}
+	return &td, nil
+}
+
+func TestUnknownTableFetch(t *testing.T) {
+	r := &Reader{
+		tableMap: newTableMap(0),
+		fetchUnknown: stubDescriptions{
+			"shop.orders": {SchemaName: "shop", TableName: "orders", ColumnCount: 1},
+		},
+		tableNames: map[uint64]schema.TableName{
+			42: {Database: "shop", Table: "orders"},
+			43: {Database: "shop", Table: "dropped"},
+		},
+	}
+	td, err := r.unknownTableID(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if td == nil || td.TableName != "orders" {
+		t.Fatalf("Expected fetched description of shop.orders, got %+v", td)
+	}
+	if _, ok := r.tableMap.get(42); !ok {
+		t.Error("Expected fetched description to be added to the table map")
+	}
+
+	// Tables that no longer exist and tables with unknown names are left to
+	// the strategy
+	for _, id := range []uint64{43, 44} {
+		if _, err := r.unknownTableID(context.Background(), id); !errors.Is(err, ErrUnknownTableID) {
+			t.Errorf("Expected unknown table ID error for table %d, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package reader
+
+import (
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader/schema"
+	"github.com/juju/errors"
+)
+
+// WithSchemaTracker makes the reader complete table descriptions with column
+// names, signedness, enum and set members, default values and the primary key
+// known to the tracker. It's meant for servers that don't log full table
+// metadata, which is the case for MySQL before 8.0.1 and with
+// binlog_row_metadata=MINIMAL. Metadata logged by the server takes precedence. Table definition statements
+// from query events are passed to the tracker to keep it up to date. Tracked
+// tables with a column count different from the table map event are ignored.
+func WithSchemaTracker(t *schema.Tracker) Option {
+	return func(r *Reader) {
+		r.tableSchemas = append(r.tableSchemas, t)
+	}
+}
+
+// WithSchemaRegistry makes the reader complete table descriptions with table
+// schemas registered by the user, just like WithSchemaTracker does. When both
+// options are used the source passed first takes precedence.
+func WithSchemaRegistry(reg *schema.Registry) Option {
+	return func(r *Reader) {
+		r.tableSchemas = append(r.tableSchemas, reg)
+	}
+}
+
+// WithSchemaFetcher makes the reader complete table descriptions with table
+// schemas fetched from INFORMATION_SCHEMA, just like WithSchemaTracker does.
+// Tables are fetched the first time they appear in the stream, failing to
+// fetch a table fails reading its table map event.
+func WithSchemaFetcher(f *schema.Fetcher) Option {
+	return func(r *Reader) {
+		r.tableSchemas = append(r.tableSchemas, f)
+	}
+}
+
+// tableFetcher is a schema source that could fail to provide a table.
+type tableFetcher interface {
+	FetchTable(database, table string) (*schema.Table, error)
+}
+
+// processQuery passes a query event to the schema sources.
+func (r *Reader) processQuery(evt *Event) error {
+	var qe binlog.QueryEvent
+	if err := qe.Decode(evt.Body()); err != nil {
+		r.stats.decodeError()
+		return r.deadLetterEvent(evt, errors.Annotate(err, "decode query event"))
+	}
+	for _, src := range r.tableSchemas {
+		if err := src.ProcessQuery(string(qe.Schema), string(qe.Query)); err != nil {
+			return errors.Annotate(err, "process query")
+		}
+	}
+	return nil
+}
+
+// completeTable fills in table metadata missing in a table map event.
+func (r *Reader) completeTable(td *binlog.TableDescription) error {
+	for _, src := range r.tableSchemas {
+		var tbl *schema.Table
+		if f, ok := src.(tableFetcher); ok {
+			var err error
+			if tbl, err = f.FetchTable(td.SchemaName, td.TableName); err != nil {
+				return errors.Annotatef(err, "fetch schema of table %s.%s", td.SchemaName, td.TableName)
+			}
+		} else {
+			tbl = src.Table(td.SchemaName, td.TableName)
+		}
+		if tbl == nil || uint64(len(tbl.Columns())) != td.ColumnCount {
+			continue
+		}
+		fillDescription(td, tbl.Columns(), tbl.PrimaryKey())
+	}
+	return nil
+}
+
+// fillDescription fills in fields of a table description that are not set yet.
+func fillDescription(td *binlog.TableDescription, cols []schema.Column, pk []int) {
+	if td.ColumnNames == nil {
+		td.ColumnNames = make([]string, len(cols))
+		for i, col := range cols {
+			td.ColumnNames[i] = col.Name
+		}
+	}
+	if td.Unsigned == nil {
+		td.Unsigned = make([]bool, len(cols))
+		for i, col := range cols {
+			td.Unsigned[i] = col.Unsigned
+		}
+	}
+	if td.PrimaryKey == nil {
+		td.PrimaryKey = pk
+	}
+	if td.EnumValues == nil {
+		for i, col := range cols {
+			vals := col.EnumValues()
+			if vals == nil {
+				continue
+			}
+			if td.EnumValues == nil {
+				td.EnumValues = make([][]string, len(cols))
+			}
+			td.EnumValues[i] = vals
+		}
+	}
+	if td.Defaults == nil {
+		for i, col := range cols {
+			if col.Default == nil {
+				continue
+			}
+			if td.Defaults == nil {
+				td.Defaults = make([]*string, len(cols))
+			}
+			td.Defaults[i] = col.Default
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/juju/errors"
+)
+
+// ChangeType is the type of a row change.
+type ChangeType = binlog.ChangeType
+
+// Row change types.
+const (
+	ChangeInsert = binlog.ChangeInsert
+	ChangeUpdate = binlog.ChangeUpdate
+	ChangeDelete = binlog.ChangeDelete
+)
+
+// RowChange is a change of a single row.
+type RowChange struct {
+	Type  ChangeType
+	Table binlog.TableDescription
+	// Before is the row image before the change, it's nil for inserts.
+	Before []interface{}
+	// After is the row image after the change, it's nil for deletes.
+	After []interface{}
+	// BeforeColumns and AfterColumns are bitmaps of columns present in the
+	// row images. Images only contain some of the columns unless the server
+	// logs full row images (binlog_row_image=FULL), values of absent columns
+	// are nil just like NULL values.
+	BeforeColumns []byte
+	AfterColumns  []byte
+}
+
+// Key returns values of primary key columns keyed by column names. The image
+// after the change is used for inserts and updates, so the key of an update
+// that changes the primary key identifies the row after the update. Integer
+// values are signed unless the column is known to be unsigned. Nil is
+// returned if the primary key is unknown, which requires either full table
+// metadata logged by the server or a schema source, see WithSchemaTracker and
+// WithSchemaRegistry.
+func (c RowChange) Key() map[string]interface{} {
+	td := c.Table
+	if len(td.PrimaryKey) == 0 || len(td.ColumnNames) == 0 {
+		return nil
+	}
+	row := c.After
+	if row == nil {
+		row = c.Before
+	}
+	key := make(map[string]interface{}, len(td.PrimaryKey))
+	for _, i := range td.PrimaryKey {
+		if i >= len(row) || i >= len(td.ColumnNames) {
+			return nil
+		}
+		key[td.ColumnNames[i]] = columnValue(td, i, row[i])
+	}
+	return key
+}
+
+// ChangedColumns returns indexes of columns changed by an update. A column is
+// changed if it's present in the image after the update and its value differs
+// from the image before the update, or if it's absent from the image before
+// the update so that the change can't be ruled out. All columns present after
+// the change are returned for other change types.
+func (c RowChange) ChangedColumns() []int {
+	var cols []int
+	for i := range c.After {
+		if !isBitSet(c.AfterColumns, i) {
+			continue
+		}
+		if c.Type == ChangeUpdate && isBitSet(c.BeforeColumns, i) && i < len(c.Before) &&
+			valuesEqual(c.Before[i], c.After[i]) {
+			continue
+		}
+		cols = append(cols, i)
+	}
+	return cols
+}
+
+// Patch returns values of changed columns after the change keyed by column
+// names, see ChangedColumns. Integer values are signed unless the column is
+// known to be unsigned. Nil is returned if column names are unknown.
+func (c RowChange) Patch() map[string]interface{} {
+	td := c.Table
+	if len(td.ColumnNames) < len(c.After) {
+		return nil
+	}
+	cols := c.ChangedColumns()
+	patch := make(map[string]interface{}, len(cols))
+	for _, i := range cols {
+		patch[td.ColumnNames[i]] = columnValue(td, i, c.After[i])
+	}
+	return patch
+}
+
+// valuesEqual compares decoded values.
+func valuesEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func isBitSet(bm []byte, i int) bool {
+	return i/8 < len(bm) && bm[i/8]&(1<<uint(i%8)) != 0
+}
+
+// columnValue returns a value with integers signed according to the column
+// signedness. Values of columns with unknown signedness are signed.
+func columnValue(td binlog.TableDescription, i int, val interface{}) interface{} {
+	if i < len(td.Unsigned) && td.Unsigned[i] {
+		return val
+	}
+	switch ct := td.ColumnType(i); ct {
+	case mysql.ColumnTypeTiny, mysql.ColumnTypeShort, mysql.ColumnTypeInt24,
+		mysql.ColumnTypeLong, mysql.ColumnTypeLonglong:
+		return signNumber(val, ct)
+	default:
+		return val
+	}
+}
+
+// Transaction contains all changes of a committed transaction.
+type Transaction struct {
+	// GTID identifies the transaction, it's empty when GTIDs are disabled.
+	GTID binlog.GTID
+	// Position is the position right after the transaction. Resuming from
+	// this position starts with the next transaction.
+	Position binlog.Position
+	// Timestamp is the timestamp of the event that committed the transaction.
+	Timestamp uint32
+	// LastCommitted and SequenceNumber form the logical clock of the
+	// transaction, see binlog.GTIDEvent.
+	LastCommitted  int64
+	SequenceNumber int64
+	// ImmediateCommitTimestamp and OriginalCommitTimestamp are the commit
+	// times on the server the transaction is read from and on its original
+	// source, in microseconds since Unix epoch, see binlog.GTIDEvent.
+	ImmediateCommitTimestamp uint64
+	OriginalCommitTimestamp  uint64
+	Changes                  []RowChange
+	// Queries contains statements logged as queries, such as table definition
+	// statements, excluding BEGIN and COMMIT.
+	Queries []string
+
+	// spill keeps rows events that exceeded the memory budget
+	spill *spill
+}
+
+// TransactionReader assembles events into transactions. A transaction is only
+// returned once its commit event is read, so it is either delivered as a
+// whole or not at all.
+type TransactionReader struct {
+	reader *Reader
+	txn    *Transaction
+	// begun is set once a BEGIN query is read, statements that follow belong
+	// to the same transaction until it's committed
+	begun bool
+
+	spillBudget int
+	spillDir    string
+	// size is the size of rows events of the current transaction
+	size int
+}
+
+// NewTransactionReader creates a transaction reader that reads events from
+// the given reader.
+func NewTransactionReader(r *Reader, opts ...TransactionOption) *TransactionReader {
+	t := &TransactionReader{reader: r}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// ReadTransaction reads events until a transaction is committed and returns
+// it. If reading fails changes of the transaction read so far are discarded,
+// the transaction is assembled again from the beginning once the reader is
+// positioned at the end of the last returned transaction.
+func (t *TransactionReader) ReadTransaction(ctx context.Context) (*Transaction, error) {
+	for {
+		evt, err := t.reader.ReadEvent(ctx)
+		if err != nil {
+			t.discard()
+			return nil, err
+		}
+		txn, err := t.add(evt)
+		if err != nil {
+			t.discard()
+			return nil, err
+		}
+		if txn != nil {
+			return txn, nil
+		}
+	}
+}
+
+// add adds an event to the current transaction, which is returned once the
+// event commits it.
+func (t *TransactionReader) add(evt *Event) (*Transaction, error) {
+	switch evt.Header.Type {
+	case binlog.EventTypeGTID, binlog.EventTypeGTIDTagged:
+		// GTID event is followed by either a BEGIN query or a single
+		// statement that is a transaction on its own
+		ge, err := binlog.DecodeGTIDEvent(evt.Header.Type, evt.Body())
+		if err != nil {
+			return nil, errors.Annotate(err, "decode gtid event")
+		}
+		t.discard()
+		t.txn, t.begun = &Transaction{
+			LastCommitted:            ge.LastCommitted,
+			SequenceNumber:           ge.SequenceNumber,
+			ImmediateCommitTimestamp: ge.ImmediateCommitTimestamp,
+			OriginalCommitTimestamp:  ge.OriginalCommitTimestamp,
+		}, false
+		return nil, nil
+
+	case binlog.EventTypeQuery:
+		var qe binlog.QueryEvent
+		if err := qe.Decode(evt.Body()); err != nil {
+			return nil, errors.Annotate(err, "decode query event")
+		}
+		query := strings.TrimSpace(string(qe.Query))
+		switch {
+		case strings.EqualFold(query, "BEGIN"):
+			if t.txn == nil || t.begun {
+				t.discard()
+				t.txn = &Transaction{}
+			}
+			t.begun = true
+			return nil, nil
+		case strings.EqualFold(query, "COMMIT"), strings.EqualFold(query, "ROLLBACK"):
+			return t.commit(evt), nil
+		}
+		if t.txn == nil {
+			t.txn = &Transaction{}
+		}
+		t.txn.Queries = append(t.txn.Queries, query)
+		if t.begun {
+			return nil, nil
+		}
+		return t.commit(evt), nil
+
+	case binlog.EventTypeXID:
+		return t.commit(evt), nil
+
+	default:
+		if evt.Table == nil || binlog.RowsEventVersion(evt.Header.Type) < 0 {
+			return nil, nil
+		}
+		if t.txn == nil {
+			t.txn = &Transaction{}
+		}
+		if t.spillBudget > 0 {
+			t.size += len(evt.Buffer)
+			if t.txn.spill == nil && t.size > t.spillBudget {
+				s, err := newSpill(t.spillDir)
+				if err != nil {
+					return nil, err
+				}
+				t.txn.spill = s
+			}
+			if t.txn.spill != nil {
+				return nil, t.txn.spill.add(evt)
+			}
+		}
+		if evt.zeroCopy {
+			// Values would reference the connection buffer, which is reused
+			// on the next read
+			evt.detach()
+		}
+		re, err := evt.DecodeRows()
+		if err != nil {
+			return nil, errors.Annotate(err, "decode rows event")
+		}
+		t.txn.Changes = append(t.txn.Changes, rowChanges(*evt.Table, re)...)
+		return nil, nil
+	}
+}
+
+// commit returns the current transaction completed by the given event.
+func (t *TransactionReader) commit(evt *Event) *Transaction {
+	txn := t.txn
+	if txn == nil {
+		txn = &Transaction{}
+	}
+	txn.GTID = evt.GTID
+	txn.Position = evt.EndPosition
+	txn.Timestamp = evt.Header.Timestamp
+	t.txn, t.begun, t.size = nil, false, 0
+	return txn
+}
+
+// discard drops the current transaction along with its spilled events.
+func (t *TransactionReader) discard() {
+	if t.txn != nil {
+		t.txn.Close()
+	}
+	t.txn, t.begun, t.size = nil, false, 0
+}
+
+// rowChanges splits a rows event into changes of individual rows.
+func rowChanges(td binlog.TableDescription, re binlog.RowsEvent) []RowChange {
+	// Bitmaps reference the event buffer
+	re.ColumnBitmap1 = copyBytes(re.ColumnBitmap1)
+	re.ColumnBitmap2 = copyBytes(re.ColumnBitmap2)
+	rcs := re.Changes()
+	changes := make([]RowChange, len(rcs))
+	for i, rc := range rcs {
+		changes[i] = RowChange{
+			Type:          rc.Type,
+			Table:         td,
+			Before:        rc.Before,
+			After:         rc.After,
+			BeforeColumns: rc.BeforeColumns,
+			AfterColumns:  rc.AfterColumns,
+		}
+	}
+	return changes
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,282 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/Vivino/bocadillo/reader/schema"
+)
+
+func TestReadTransaction(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+		{Name: "status", Type: mysql.ColumnTypeVarchar},
+	}}
+	sid := binlog.SID{1}
+	g := binlogtest.New()
+	g.FormatDescription()
+
+	mustRows := func(b []byte, err error) {
+		if err != nil {
+			t.Fatalf("Failed to build rows event: %v", err)
+		}
+	}
+	g.GTID(binlog.GTID{SID: sid, GNO: 1})
+	g.Query("shop", "BEGIN")
+	g.TableMap(table)
+	mustRows(g.Insert(table, []interface{}{1, "pending"}, []interface{}{2, "pending"}))
+	g.TableMap(table)
+	mustRows(g.Update(table, []interface{}{1, "pending"}, []interface{}{1, "shipped"}))
+	g.XID(10)
+	first := g.Position()
+
+	// Transaction replicated from another source
+	g.GTIDCommit(binlog.GTID{SID: sid, GNO: 2}, 1700000000000002, 1700000000000001)
+	g.Query("shop", "ALTER TABLE orders ADD COLUMN note TEXT")
+	second := g.Position()
+
+	g.GTID(binlog.GTID{SID: sid, GNO: 3})
+	g.Query("shop", "BEGIN")
+	g.TableMap(table)
+	mustRows(g.Delete(table, []interface{}{2, "pending"}))
+	g.Query("shop", "COMMIT")
+	third := g.Position()
+
+	r := openGenerated(t, g)
+
+	type change struct {
+		typ           ChangeType
+		before, after []interface{}
+	}
+	tests := []struct {
+		gno     uint64
+		pos     binlog.Position
+		changes []change
+		queries []string
+		// Commit timestamps
+		immediate, original uint64
+	}{
+		{1, first, []change{
+			{ChangeInsert, nil, []interface{}{uint32(1), "pending"}},
+			{ChangeInsert, nil, []interface{}{uint32(2), "pending"}},
+			{ChangeUpdate, []interface{}{uint32(1), "pending"}, []interface{}{uint32(1), "shipped"}},
+		}, nil, 0, 0},
+		{2, second, nil, []string{"ALTER TABLE orders ADD COLUMN note TEXT"}, 1700000000000002, 1700000000000001},
+		{3, third, []change{
+			{ChangeDelete, []interface{}{uint32(2), "pending"}, nil},
+		}, nil, 0, 0},
+	}
+
+	tr := NewTransactionReader(r)
+	for _, test := range tests {
+		txn, err := tr.ReadTransaction(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read transaction %d: %v", test.gno, err)
+		}
+		if txn.GTID.GNO != test.gno || txn.Position != test.pos {
+			t.Errorf("Expected transaction %d at %v, got %d at %v", test.gno, test.pos, txn.GTID.GNO, txn.Position)
+		}
+		if txn.ImmediateCommitTimestamp != test.immediate || txn.OriginalCommitTimestamp != test.original {
+			t.Errorf("Transaction %d: expected commit timestamps %d and %d, got %d and %d", test.gno,
+				test.immediate, test.original, txn.ImmediateCommitTimestamp, txn.OriginalCommitTimestamp)
+		}
+		if !reflect.DeepEqual(txn.Queries, test.queries) {
+			t.Errorf("Transaction %d: expected queries %q, got %q", test.gno, test.queries, txn.Queries)
+		}
+		if len(txn.Changes) != len(test.changes) {
+			t.Fatalf("Transaction %d: expected %d changes, got %d", test.gno, len(test.changes), len(txn.Changes))
+		}
+		for i, c := range txn.Changes {
+			exp := test.changes[i]
+			if c.Type != exp.typ || !reflect.DeepEqual(c.Before, exp.before) || !reflect.DeepEqual(c.After, exp.after) {
+				t.Errorf("Transaction %d: expected %s %v -> %v, got %s %v -> %v",
+					test.gno, exp.typ.String(), exp.before, exp.after, c.Type.String(), c.Before, c.After)
+			}
+			if c.Table.TableName != "orders" {
+				t.Errorf("Unexpected table %q", c.Table.TableName)
+			}
+		}
+	}
+	if _, err := tr.ReadTransaction(context.Background()); err != ErrEndOfLog {
+		t.Errorf("Expected end of log, got %v", err)
+	}
+}
+
+func TestRowChangeKey(t *testing.T) {
+	td := binlog.TableDescription{
+		ColumnCount: 3,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeTiny), byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar)},
+		ColumnMeta:  []uint16{0, 0, 255},
+		ColumnNames: []string{"shard", "id", "status"},
+		Unsigned:    []bool{false, true, false},
+		PrimaryKey:  []int{0, 1},
+	}
+	tests := []struct {
+		change RowChange
+		key    map[string]interface{}
+	}{
+		{
+			RowChange{Type: ChangeInsert, Table: td, After: []interface{}{uint8(0xFF), uint32(0xFFFFFFFF), "new"}},
+			map[string]interface{}{"shard": int8(-1), "id": uint32(0xFFFFFFFF)},
+		},
+		{
+			RowChange{Type: ChangeUpdate, Table: td, Before: []interface{}{uint8(1), uint32(1), "new"}, After: []interface{}{uint8(1), uint32(2), "new"}},
+			map[string]interface{}{"shard": int8(1), "id": uint32(2)},
+		},
+		{
+			RowChange{Type: ChangeDelete, Table: td, Before: []interface{}{uint8(1), uint32(3), "old"}},
+			map[string]interface{}{"shard": int8(1), "id": uint32(3)},
+		},
+		{
+			RowChange{Type: ChangeInsert, Table: binlog.TableDescription{ColumnCount: 1}, After: []interface{}{uint32(1)}},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		if key := test.change.Key(); !reflect.DeepEqual(key, test.key) {
+			t.Errorf("Expected %s key %v, got %v", test.change.Type.String(), test.key, key)
+		}
+	}
+}
+
+func TestSchemaTrackerKey(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+		{Name: "status", Type: mysql.ColumnTypeVarchar},
+	}}
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.Query("shop", "CREATE TABLE orders (id INT NOT NULL, status VARCHAR(255), PRIMARY KEY (id))")
+	g.Query("shop", "BEGIN")
+	g.TableMap(table)
+	if _, err := g.Insert(table, []interface{}{-5, "pending"}); err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+	g.XID(1)
+
+	r := openGenerated(t, g, WithSchemaTracker(schema.NewTracker()))
+
+	tr := NewTransactionReader(r)
+	txn, err := tr.ReadTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read transaction: %v", err)
+	}
+	if len(txn.Queries) != 1 {
+		t.Fatalf("Expected table definition transaction, got %v", txn.Queries)
+	}
+	if txn, err = tr.ReadTransaction(context.Background()); err != nil {
+		t.Fatalf("Failed to read transaction: %v", err)
+	}
+	if len(txn.Changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(txn.Changes))
+	}
+	if key := txn.Changes[0].Key(); !reflect.DeepEqual(key, map[string]interface{}{"id": int32(-5)}) {
+		t.Errorf("Unexpected key %v", key)
+	}
+}
+
+func TestChangedColumns(t *testing.T) {
+	td := binlog.TableDescription{
+		ColumnCount: 3,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar), byte(mysql.ColumnTypeBlob)},
+		ColumnMeta:  []uint16{0, 255, 2},
+		ColumnNames: []string{"id", "status", "data"},
+	}
+	tests := []struct {
+		name    string
+		change  RowChange
+		changed []int
+		patch   map[string]interface{}
+	}{
+		{
+			name: "full image",
+			change: RowChange{Type: ChangeUpdate, Table: td,
+				Before: []interface{}{uint32(1), "pending", []byte{1}}, BeforeColumns: []byte{0x07},
+				After: []interface{}{uint32(1), "shipped", []byte{1}}, AfterColumns: []byte{0x07}},
+			changed: []int{1},
+			patch:   map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name: "set to null",
+			change: RowChange{Type: ChangeUpdate, Table: td,
+				Before: []interface{}{uint32(1), "pending", []byte{1}}, BeforeColumns: []byte{0x07},
+				After: []interface{}{uint32(1), "pending", nil}, AfterColumns: []byte{0x07}},
+			changed: []int{2},
+			patch:   map[string]interface{}{"data": nil},
+		},
+		{
+			name: "minimal image",
+			change: RowChange{Type: ChangeUpdate, Table: td,
+				Before: []interface{}{uint32(1), nil, nil}, BeforeColumns: []byte{0x01},
+				After: []interface{}{nil, "shipped", nil}, AfterColumns: []byte{0x02}},
+			changed: []int{1},
+			patch:   map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name: "insert",
+			change: RowChange{Type: ChangeInsert, Table: td,
+				After: []interface{}{uint32(0xFFFFFFFF), "new", nil}, AfterColumns: []byte{0x07}},
+			changed: []int{0, 1, 2},
+			patch:   map[string]interface{}{"id": int32(-1), "status": "new", "data": nil},
+		},
+		{
+			name: "delete",
+			change: RowChange{Type: ChangeDelete, Table: td,
+				Before: []interface{}{uint32(1), "old", nil}, BeforeColumns: []byte{0x07}},
+			patch: map[string]interface{}{},
+		},
+	}
+	for _, test := range tests {
+		if changed := test.change.ChangedColumns(); !reflect.DeepEqual(changed, test.changed) {
+			t.Errorf("%s: expected changed columns %v, got %v", test.name, test.changed, changed)
+		}
+		if patch := test.change.Patch(); !reflect.DeepEqual(patch, test.patch) {
+			t.Errorf("%s: expected patch %v, got %v", test.name, test.patch, patch)
+		}
+	}
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	table := binlogtest.Table{ID: 1, Schema: "shop", Name: "orders", Columns: []binlogtest.Column{
+		{Name: "id", Type: mysql.ColumnTypeLong},
+		{Name: "status", Type: mysql.ColumnTypeEnum},
+	}}
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.Query("shop", "BEGIN")
+	g.TableMap(table)
+	if _, err := g.Insert(table, []interface{}{uint32(0xFFFFFFFF), 2}); err != nil {
+		t.Fatalf("Failed to build rows event: %v", err)
+	}
+	g.XID(1)
+
+	pending := "pending"
+	reg := schema.NewRegistry()
+	reg.Register("shop", "orders", []schema.Column{
+		{Name: "id", Type: "int(10) unsigned", Unsigned: true, PrimaryKey: true},
+		{Name: "status", Type: "enum('pending','shipped')", NotNull: true, Default: &pending},
+	})
+	r := openGenerated(t, g, WithSchemaRegistry(reg))
+
+	txn, err := NewTransactionReader(r).ReadTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read transaction: %v", err)
+	}
+	if len(txn.Changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(txn.Changes))
+	}
+	c := txn.Changes[0]
+	if vals := c.Table.EnumValues; !reflect.DeepEqual(vals, [][]string{nil, {"pending", "shipped"}}) {
+		t.Errorf("Unexpected enum values %q", vals)
+	}
+	if defs := c.Table.Defaults; len(defs) != 2 || defs[0] != nil || defs[1] == nil || *defs[1] != pending {
+		t.Errorf("Unexpected defaults %v", defs)
+	}
+	if key := c.Key(); !reflect.DeepEqual(key, map[string]interface{}{"id": uint32(0xFFFFFFFF)}) {
+		t.Errorf("Unexpected key %v", key)
+	}
+}
@@ -0,0 +1,124 @@
+package reader
+
+import (
+	"context"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader/schema"
+	"github.com/juju/errors"
+)
+
+// UnknownTableStrategy defines how rows events referring to table IDs missing
+// in the table map are handled. This happens when reading starts in the middle
+// of a transaction, after its table map events.
+type UnknownTableStrategy int
+
+const (
+	// UnknownTableFail makes ReadEvent return UnknownTableIDError. This is the
+	// default strategy.
+	UnknownTableFail UnknownTableStrategy = iota
+	// UnknownTableSkip makes the reader silently skip such events.
+	UnknownTableSkip
+	// UnknownTableRestart makes the reader restart the dump from the
+	// beginning of the current transaction to receive its table map events.
+	// Events of the transaction that were already read are delivered again.
+	UnknownTableRestart
+)
+
+// TableResolver returns the description of a table with the given ID. It
+// should return false if the table is unknown.
+type TableResolver func(tableID uint64) (binlog.TableDescription, bool, error)
+
+// WithUnknownTableStrategy sets the strategy for handling rows events that
+// refer to unknown table IDs.
+func WithUnknownTableStrategy(s UnknownTableStrategy) Option {
+	return func(r *Reader) {
+		r.unknownTable = s
+	}
+}
+
+// WithTableResolver sets a function that is consulted before applying the
+// unknown table strategy. Table IDs are assigned by the server internally and
+// are not exposed with INFORMATION_SCHEMA, so resolver would usually rely on
+// table descriptions kept from a previous run.
+func WithTableResolver(fn TableResolver) Option {
+	return func(r *Reader) {
+		r.resolveTable = fn
+	}
+}
+
+// WithUnknownTableFetcher makes the reader fetch definitions of unknown tables
+// from INFORMATION_SCHEMA over the side connection of the fetcher before
+// applying the unknown table strategy. Since table IDs are not exposed with
+// INFORMATION_SCHEMA, the reader remembers names of the tables from every
+// table map event it receives, including the ones received before restarting
+// the dump. Tables which names are not known are left to the strategy.
+func WithUnknownTableFetcher(f *schema.Fetcher) Option {
+	return func(r *Reader) {
+		r.fetchUnknown = f
+		r.tableNames = make(map[uint64]schema.TableName)
+	}
+}
+
+// descriptionFetcher fetches table descriptions by table name.
+type descriptionFetcher interface {
+	FetchDescription(database, table string) (*binlog.TableDescription, error)
+}
+
+// unknownTableID handles a rows event referring to an unknown table ID. It
+// returns the table description if it was resolved.
+func (r *Reader) unknownTableID(ctx context.Context, tableID uint64) (*binlog.TableDescription, error) {
+	if r.resolveTable != nil {
+		td, ok, err := r.resolveTable(tableID)
+		if err != nil {
+			return nil, errors.Annotate(err, "resolve table")
+		}
+		if ok {
+			r.tableMap.add(tableID, td)
+			return &td, nil
+		}
+	}
+	if name, ok := r.tableNames[tableID]; ok {
+		td, err := r.fetchUnknown.FetchDescription(name.Database, name.Table)
+		if err != nil {
+			return nil, errors.Annotatef(err, "fetch description of table %s.%s", name.Database, name.Table)
+		}
+		if td != nil {
+			if len(r.tableSchemas) > 0 {
+				if err := r.completeTable(td); err != nil {
+					return nil, err
+				}
+			}
+			r.tableMap.add(tableID, *td)
+			return td, nil
+		}
+	}
+
+	switch r.unknownTable {
+	case UnknownTableSkip:
+		return nil, nil
+	case UnknownTableRestart:
+		// Only restart once per transaction to avoid looping if table map
+		// is missing at the beginning of the transaction too
+		if r.rewoundAt == nil || *r.rewoundAt != r.commitPos {
+			pos := r.commitPos
+			if err := r.rewind(ctx); err != nil {
+				return nil, err
+			}
+			r.rewoundAt = &pos
+			return nil, errRewound
+		}
+	}
+	return nil, &UnknownTableIDError{TableID: tableID}
+}
+
+// errRewound signals that the dump was restarted and reading should continue.
+var errRewound = errors.New("Rewound")
+
+// rewind restarts the dump from the end of the last committed transaction.
+func (r *Reader) rewind(ctx context.Context) error {
+	if r.gtidMode {
+		return r.restart(ctx, binlog.Position{Offset: 4})
+	}
+	return r.restart(ctx, r.commitPos)
+}
@@ -0,0 +1,147 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// ValidationReport describes whether server configuration is suitable for
+// reading the binary log.
+type ValidationReport struct {
+	// ServerVersion is the version reported by the server.
+	ServerVersion string
+	// Platform is the platform the server runs on.
+	Platform driver.Platform
+	// Checks contains results of individual checks.
+	Checks []Check
+}
+
+// Check is a result of a single server configuration check.
+type Check struct {
+	// Name is the name of the checked server variable.
+	Name string
+	// Value is the actual value of the variable.
+	Value string
+	// Expected describes the expected value.
+	Expected string
+	// OK is true if the value is acceptable.
+	OK bool
+}
+
+// minServerVersion is the oldest server version that supports binlog
+// checksums, support for which is required to read the binary log.
+var minServerVersion = [2]int{5, 6}
+
+// Validate connects to the server and checks that it is configured to write a
+// binary log that could be consumed by the reader: binary logging must be
+// enabled, events must be logged in row based format with full row images and
+// server must be recent enough to support checksums. On RDS and Aurora binary
+// logs must also be retained for some time, by default they are purged as
+// soon as possible.
+func Validate(dsn string) (*ValidationReport, error) {
+	return ValidateContext(context.Background(), dsn, driver.Config{})
+}
+
+// ValidateContext is like Validate but connects with the given configuration,
+// so that credentials, timeouts and connection attributes apply, and gives up
+// once the context is done.
+func ValidateContext(ctx context.Context, dsn string, conf driver.Config) (*ValidationReport, error) {
+	conn, err := driver.ConnectContext(ctx, dsn, conf)
+	if err != nil {
+		return nil, errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+
+	vars, err := conn.GetVarsContext(ctx, "version", "log_bin", "binlog_format", "binlog_row_image", "binlog_checksum")
+	if err != nil {
+		return nil, errors.Annotate(err, "get server variables")
+	}
+	platform, err := conn.Platform(ctx)
+	if err != nil {
+		return nil, errors.Annotate(err, "detect platform")
+	}
+	var retention time.Duration
+	if platform.Managed() {
+		if retention, err = conn.BinlogRetention(ctx, platform); err != nil {
+			return nil, errors.Annotate(err, "get binary log retention")
+		}
+	}
+	return validate(vars, platform, retention), nil
+}
+
+// WithValidation makes the reader validate server configuration before
+// starting a binary log dump. If any of the checks fail New returns an error
+// describing the problems.
+func WithValidation() Option {
+	return func(r *Reader) {
+		r.validate = true
+	}
+}
+
+func validate(vars map[string]string, platform driver.Platform, retention time.Duration) *ValidationReport {
+	rep := &ValidationReport{ServerVersion: vars["version"], Platform: platform}
+	rep.add("version", vars["version"], fmt.Sprintf("%d.%d or newer", minServerVersion[0], minServerVersion[1]),
+		versionAtLeast(vars["version"], minServerVersion))
+	rep.add("log_bin", vars["log_bin"], "ON",
+		vars["log_bin"] == "1" || strings.EqualFold(vars["log_bin"], "ON"))
+	rep.add("binlog_format", vars["binlog_format"], "ROW",
+		strings.EqualFold(vars["binlog_format"], "ROW"))
+	rep.add("binlog_row_image", vars["binlog_row_image"], "FULL",
+		strings.EqualFold(vars["binlog_row_image"], "FULL"))
+	checksum, ok := vars["binlog_checksum"]
+	// Some managed servers hide the variable, they always support checksums
+	rep.add("binlog_checksum", checksum, "NONE or CRC32",
+		strings.EqualFold(checksum, "NONE") || strings.EqualFold(checksum, "CRC32") || !ok && platform.Managed())
+	if platform.Managed() {
+		rep.add("binlog retention hours", retention.String(), "at least 1h", retention >= time.Hour)
+	}
+	return rep
+}
+
+func (rep *ValidationReport) add(name, val, exp string, ok bool) {
+	rep.Checks = append(rep.Checks, Check{Name: name, Value: val, Expected: exp, OK: ok})
+}
+
+// OK returns true if all checks have passed.
+func (rep *ValidationReport) OK() bool {
+	return rep.Err() == nil
+}
+
+// Err returns an error describing failed checks or nil if all checks have
+// passed.
+func (rep *ValidationReport) Err() error {
+	var problems []string
+	for _, c := range rep.Checks {
+		if !c.OK {
+			problems = append(problems, fmt.Sprintf("%s is %q, expected %s", c.Name, c.Value, c.Expected))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Errorf("server misconfigured: %s", strings.Join(problems, "; "))
+}
+
+// versionAtLeast returns true if the given server version, e.g.
+// "5.7.22-log", is not older than the given major and minor version.
+func versionAtLeast(version string, min [2]int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return false
+	}
+	return major > min[0] || major == min[0] && minor >= min[1]
+}
@@ -0,0 +1,88 @@
+package reader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql/driver"
+)
+
+func TestValidate(t *testing.T) {
+	inputs := []struct {
+		vars      map[string]string
+		platform  driver.Platform
+		retention time.Duration
+		ok        bool
+	}{
+		{
+			vars: map[string]string{"version": "5.7.22-log", "log_bin": "1", "binlog_format": "ROW", "binlog_row_image": "FULL", "binlog_checksum": "CRC32"},
+			ok:   true,
+		},
+		{
+			vars: map[string]string{"version": "8.0.13", "log_bin": "1", "binlog_format": "MIXED", "binlog_row_image": "FULL", "binlog_checksum": "NONE"},
+			ok:   false,
+		},
+		{
+			vars: map[string]string{"version": "5.5.62", "log_bin": "1", "binlog_format": "ROW", "binlog_row_image": "FULL", "binlog_checksum": "NONE"},
+			ok:   false,
+		},
+		{
+			vars: map[string]string{"version": "10.3.9-MariaDB", "log_bin": "0", "binlog_format": "ROW", "binlog_row_image": "FULL", "binlog_checksum": "NONE"},
+			ok:   false,
+		},
+		{
+			vars:      map[string]string{"version": "8.0.mysql_aurora.3.02.0", "log_bin": "1", "binlog_format": "ROW", "binlog_row_image": "FULL"},
+			platform:  driver.PlatformAurora,
+			retention: 24 * time.Hour,
+			ok:        true,
+		},
+		{
+			vars:     map[string]string{"version": "8.0.28", "log_bin": "1", "binlog_format": "ROW", "binlog_row_image": "FULL", "binlog_checksum": "CRC32"},
+			platform: driver.PlatformRDS,
+			ok:       false,
+		},
+		{
+			vars:     map[string]string{"version": "8.0.28", "log_bin": "1", "binlog_format": "ROW", "binlog_row_image": "FULL"},
+			platform: driver.PlatformSelfManaged,
+			ok:       false,
+		},
+	}
+
+	for _, in := range inputs {
+		rep := validate(in.vars, in.platform, in.retention)
+		if rep.OK() != in.ok {
+			t.Errorf("Expected OK=%t for %v, got error: %v", in.ok, in.vars, rep.Err())
+		}
+	}
+}
+
+func TestValidateContext(t *testing.T) {
+	srv, _ := startTestServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rep, err := ValidateContext(ctx, srv.DSN(), driver.Config{ServerID: 1000})
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if !rep.OK() {
+		t.Errorf("Expected server to pass validation, got %v", rep.Err())
+	}
+
+	cancel()
+	if _, err := ValidateContext(ctx, srv.DSN(), driver.Config{ServerID: 1000}); err == nil {
+		t.Error("Expected validation to fail once the context is done")
+	}
+
+	mixed := &binlogtest.Server{Vars: map[string]string{"binlog_format": "MIXED"}}
+	if err := mixed.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer mixed.Close()
+	if _, err := New(mixed.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4}, WithValidation()); err == nil {
+		t.Error("Expected validation to fail")
+	}
+}
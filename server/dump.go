@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/buffer"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/server/protocol"
+	"github.com/juju/errors"
+)
+
+// dump serves a COM_BINLOG_DUMP command. Events of the source are sent
+// starting at the requested position, beginning with an artificial rotate
+// event followed by the format description event of the file. Once the end of
+// the binary log is reached the dump waits for more events, sending
+// heartbeats if the replica asked for them, or sends an EOF packet if the
+// replica asked not to block. It returns when either side closes the
+// connection.
+// Spec: https://dev.mysql.com/doc/internals/en/com-binlog-dump.html
+func (s *Server) dump(c *protocol.Conn, sess *session, pkt []byte) error {
+	buf := buffer.NewChecked(pkt[1:])
+	offset := uint64(buf.ReadUint32())
+	flags := driver.DumpFlags(buf.ReadUint16())
+	buf.Skip(4) // Server ID
+	file := string(buf.ReadStringEOF())
+	if buf.Err() != nil {
+		return c.WriteError(errCodeParse, "Malformed binlog dump command")
+	}
+	if offset < uint64(len(binlog.FileMagic)) {
+		offset = uint64(len(binlog.FileMagic))
+	}
+
+	st, err := s.Source.Open(binlog.Position{File: file, Offset: offset})
+	if err != nil {
+		c.WriteError(errCodeMasterFatalReading, err.Error())
+		return errors.Annotate(err, "open binary log")
+	}
+	defer st.Close()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	go func() {
+		// Replicas don't send anything during a dump but a QUIT command
+		// before closing the connection
+		c.Discard()
+		cancel()
+	}()
+
+	d := &dump{
+		conn:      c,
+		serverID:  s.ServerID,
+		checksum:  sess.checksum(),
+		heartbeat: sess.heartbeatPeriod(),
+	}
+	if err := d.sendRotate(st.Position().File, offset); err != nil {
+		return d.connErr(ctx, err)
+	}
+	first := true
+	for {
+		evt, err := st.Next()
+		if err != nil {
+			c.WriteError(errCodeMasterFatalReading, err.Error())
+			return errors.Annotate(err, "read binary log")
+		}
+		if evt == nil {
+			if flags&driver.DumpFlagNonBlock != 0 {
+				return d.connErr(ctx, c.WriteEOF())
+			}
+			if err := d.wait(ctx, st); err != nil {
+				return d.connErr(ctx, err)
+			}
+			continue
+		}
+		if first && offset > uint64(len(binlog.FileMagic)) {
+			// Events are skipped up to the offset, the format description
+			// is sent as an artificial event
+			evt = append([]byte(nil), evt...)
+			binary.LittleEndian.PutUint32(evt[nextPosOffset:], 0)
+			if formatChecksum(evt) {
+				putChecksum(evt)
+			}
+		}
+		first = false
+		if err := d.send(evt); err != nil {
+			return d.connErr(ctx, err)
+		}
+	}
+}
+
+// checksum returns true if the replica accepts events with checksums, which
+// it declares by setting the @master_binlog_checksum user variable.
+func (sess *session) checksum() bool {
+	for _, name := range []string{"source_binlog_checksum", "master_binlog_checksum"} {
+		if v, ok := sess.vars[name]; ok {
+			return v != "" && !strings.EqualFold(v, "NONE")
+		}
+	}
+	return false
+}
+
+// heartbeatPeriod returns the heartbeat period requested by the replica with
+// the @master_heartbeat_period user variable.
+func (sess *session) heartbeatPeriod() time.Duration {
+	for _, name := range []string{"source_heartbeat_period", "master_heartbeat_period"} {
+		if v, ok := sess.vars[name]; ok {
+			ns, _ := strconv.ParseInt(v, 10, 64)
+			return time.Duration(ns)
+		}
+	}
+	return 0
+}
+
+// dump contains the state of a binary log dump.
+type dump struct {
+	conn     *protocol.Conn
+	serverID uint32
+	// checksum is set if the replica accepts events with checksums
+	checksum bool
+	// eventChecksum is set if events being sent have checksums
+	eventChecksum bool
+	heartbeat     time.Duration
+}
+
+// connErr returns nil if the dump ended because the replica disconnected or
+// the server was closed.
+func (d *dump) connErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// wait waits for more events, sending heartbeats while the binary log is idle.
+func (d *dump) wait(ctx context.Context, st Stream) error {
+	wctx := ctx
+	if d.heartbeat > 0 {
+		var cancel context.CancelFunc
+		wctx, cancel = context.WithTimeout(ctx, d.heartbeat)
+		defer cancel()
+	}
+	err := st.Wait(wctx)
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		pos := st.Position()
+		return d.sendHeartbeat(pos.File, pos.Offset)
+	}
+	return err
+}
+
+// send sends an event. Checksums are removed if the replica doesn't accept
+// them, which is detected from the last format description event sent.
+func (d *dump) send(evt []byte) error {
+	if binlog.EventType(evt[eventTypeOffset]) == binlog.EventTypeFormatDescription {
+		d.eventChecksum = formatChecksum(evt)
+		if d.eventChecksum && !d.checksum {
+			evt = append([]byte(nil), evt...)
+			evt[len(evt)-5] = byte(binlog.ChecksumAlgorithmNone)
+		}
+	} else if d.eventChecksum && !d.checksum && len(evt) >= headerLen+4 {
+		evt = append([]byte(nil), evt[:len(evt)-4]...)
+		binary.LittleEndian.PutUint32(evt[eventLenOffset:], uint32(len(evt)))
+	}
+	return d.conn.WritePacket(append([]byte{0}, evt...))
+}
+
+// sendRotate sends an artificial rotate event, which tells the replica the
+// position of the following events.
+func (d *dump) sendRotate(file string, offset uint64) error {
+	body := make([]byte, 8, 8+len(file))
+	binary.LittleEndian.PutUint64(body, offset)
+	body = append(body, file...)
+	return d.conn.WritePacket(append([]byte{0}, d.artificialEvent(binlog.EventTypeRotate, 0, body, false)...))
+}
+
+// sendHeartbeat sends a heartbeat event with the given position.
+func (d *dump) sendHeartbeat(file string, offset uint64) error {
+	evt := d.artificialEvent(binlog.EventTypeHeartbeet, uint32(offset), []byte(file), d.checksum && d.eventChecksum)
+	return d.conn.WritePacket(append([]byte{0}, evt...))
+}
+
+// formatChecksum returns true if the format description event declares that
+// events have CRC32 checksums.
+func formatChecksum(fde []byte) bool {
+	return len(fde) >= headerLen+5 &&
+		binlog.ChecksumAlgorithm(fde[len(fde)-5]) == binlog.ChecksumAlgorithmCRC32
+}
+
+// putChecksum replaces the checksum the event ends with by the checksum of
+// its current contents.
+func putChecksum(evt []byte) {
+	n := len(evt) - 4
+	binary.LittleEndian.PutUint32(evt[n:], crc32.ChecksumIEEE(evt[:n]))
+}
+
+func (d *dump) artificialEvent(et binlog.EventType, nextPos uint32, body []byte, checksum bool) []byte {
+	n := headerLen + len(body)
+	if checksum {
+		n += 4
+	}
+	evt := make([]byte, headerLen, n)
+	evt[eventTypeOffset] = byte(et)
+	binary.LittleEndian.PutUint32(evt[5:], d.serverID)
+	binary.LittleEndian.PutUint32(evt[eventLenOffset:], uint32(n))
+	binary.LittleEndian.PutUint32(evt[nextPosOffset:], nextPos)
+	binary.LittleEndian.PutUint16(evt[flagsOffset:], logEventArtificial)
+	evt = append(evt, body...)
+	if checksum {
+		var sum [4]byte
+		binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(evt))
+		evt = append(evt, sum[:]...)
+	}
+	return evt
+}
@@ -0,0 +1,145 @@
+// Package protocol implements the server side of the MySQL client/server
+// protocol packets, shared by the replication server and the test server of
+// the binlogtest package.
+package protocol
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// Conn reads and writes protocol packets on the server side.
+type Conn struct {
+	// WriteTimeout limits the time spent writing each packet.
+	WriteTimeout time.Duration
+
+	nc  net.Conn
+	rd  *bufio.Reader
+	seq uint8
+}
+
+// NewConn creates a server side connection.
+func NewConn(nc net.Conn) *Conn {
+	return &Conn{nc: nc, rd: bufio.NewReader(nc)}
+}
+
+// RemoteAddr returns the address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.nc.RemoteAddr()
+}
+
+// Discard reads and drops everything the client sends until the connection
+// is closed.
+func (c *Conn) Discard() {
+	io.Copy(ioutil.Discard, c.rd)
+}
+
+// ReadPacket reads a command packet, responses continue its sequence.
+func (c *Conn) ReadPacket() ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(c.rd, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+	c.seq = hdr[3] + 1
+	pkt := make([]byte, n)
+	if _, err := io.ReadFull(c.rd, pkt); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+// WritePacket writes a payload, splitting it into multiple packets if needed.
+func (c *Conn) WritePacket(payload []byte) error {
+	const maxPacketSize = 1<<24 - 1
+	if c.WriteTimeout > 0 {
+		if err := c.nc.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	for {
+		n := len(payload)
+		if n > maxPacketSize {
+			n = maxPacketSize
+		}
+		hdr := []byte{byte(n), byte(n >> 8), byte(n >> 16), c.seq}
+		bufs := net.Buffers{hdr, payload[:n]}
+		if _, err := bufs.WriteTo(c.nc); err != nil {
+			return err
+		}
+		c.seq++
+		payload = payload[n:]
+		if n < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// WriteOK writes an OK packet.
+func (c *Conn) WriteOK() error {
+	return c.WritePacket([]byte{0x00, 0, 0, 2, 0, 0, 0})
+}
+
+// WriteEOF writes an EOF packet.
+func (c *Conn) WriteEOF() error {
+	return c.WritePacket([]byte{0xFE, 0, 0, 2, 0})
+}
+
+// WriteError writes an error packet.
+func (c *Conn) WriteError(code uint16, message string) error {
+	pkt := []byte{0xFF, byte(code), byte(code >> 8)}
+	pkt = append(pkt, "#HY000"...)
+	return c.WritePacket(append(pkt, message...))
+}
+
+// WriteResult writes a result set of string columns.
+func (c *Conn) WriteResult(cols []string, rows [][]string) error {
+	if err := c.WritePacket(AppendUintLenEnc(nil, uint64(len(cols)))); err != nil {
+		return err
+	}
+	for _, col := range cols {
+		def := AppendStrLenEnc(nil, "def")
+		def = append(def, 0, 0, 0) // Schema, table and original table
+		def = AppendStrLenEnc(def, col)
+		def = append(def, 0, 0x0C, 33, 0, 0, 1, 0, 0, 0xFD, 0, 0, 0, 0, 0)
+		if err := c.WritePacket(def); err != nil {
+			return err
+		}
+	}
+	if err := c.WriteEOF(); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var pkt []byte
+		for _, val := range row {
+			pkt = AppendStrLenEnc(pkt, val)
+		}
+		if err := c.WritePacket(pkt); err != nil {
+			return err
+		}
+	}
+	return c.WriteEOF()
+}
+
+// AppendStrLenEnc appends a length encoded string.
+func AppendStrLenEnc(b []byte, s string) []byte {
+	return append(AppendUintLenEnc(b, uint64(len(s))), s...)
+}
+
+// AppendUintLenEnc appends a length encoded integer.
+func AppendUintLenEnc(b []byte, v uint64) []byte {
+	switch {
+	case v < 251:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return append(b, 0xFC, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(b, 0xFD, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		return append(b, 0xFE, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+			byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+	}
+}
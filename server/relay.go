@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// Relay copies the binary log of an upstream server into relay log files in a
+// directory, which are then served to replicas. Relay logs keep names, offsets
+// and checksums of the upstream binary log files, so replicas could switch
+// between the upstream server and the relay without changing positions.
+type Relay struct {
+	// Dir is the directory relay logs are written to. Streams of the
+	// directory receive relayed events right away.
+	Dir *Dir
+	// DSN is the data source name of the upstream server.
+	DSN string
+	// Config configures the upstream connection. Relaying starts at the
+	// beginning of File if the directory has no relay logs, or the first
+	// binary log of the upstream server if File is empty. Otherwise it
+	// resumes at the end of the last relay log. Offset is ignored.
+	Config driver.Config
+}
+
+// Run relays events until the context is done, the connection fails or the
+// upstream server reports the end of the binary log in non-blocking mode. It
+// could be called again to resume relaying, a partially written event at the
+// end of the last relay log is discarded then.
+func (r *Relay) Run(ctx context.Context) error {
+	pos, err := r.resumePosition()
+	if err != nil {
+		return err
+	}
+	conf := r.Config
+	if pos.File == "" {
+		pos.File, err = r.firstUpstreamLog(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	conf.File, conf.Offset = pos.File, uint32(pos.Offset)
+
+	conn, err := driver.ConnectContext(ctx, r.DSN, conf)
+	if err != nil {
+		return errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+	// Events are relayed with checksums, the server strips them for
+	// replicas that don't accept them
+	if err := conn.EnableChecksumContext(ctx); err != nil {
+		return errors.Annotate(err, "configure binlog checksum")
+	}
+	if err := conn.RegisterSlaveContext(ctx); err != nil {
+		return errors.Annotate(err, "register replica server")
+	}
+	if err := conn.StartBinlogDumpContext(ctx); err != nil {
+		return errors.Annotate(err, "start binlog dump")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Abort()
+		case <-stop:
+		}
+	}()
+
+	w := &relayWriter{dir: r.Dir}
+	defer w.close()
+	for {
+		evt, err := conn.ReadPacket(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Annotate(err, "read event")
+		}
+		if evt == nil {
+			return nil
+		}
+		if err := w.write(evt); err != nil {
+			return err
+		}
+	}
+}
+
+// resumePosition returns the end of the last complete event of the last relay
+// log, discarding anything after it. The beginning of Config.File is returned
+// if there are no relay logs.
+func (r *Relay) resumePosition() (binlog.Position, error) {
+	logs, err := r.Dir.Logs()
+	if err != nil {
+		return binlog.Position{}, err
+	}
+	if len(logs) == 0 {
+		return binlog.Position{File: r.Config.File, Offset: uint64(len(binlog.FileMagic))}, nil
+	}
+	last := logs[len(logs)-1]
+	f, err := os.OpenFile(filepath.Join(r.Dir.path, last.Name), os.O_RDWR, 0)
+	if err != nil {
+		return binlog.Position{}, errors.Annotate(err, "open relay log")
+	}
+	defer f.Close()
+
+	end := int64(len(binlog.FileMagic))
+	var hdr [headerLen]byte
+	for {
+		if n, _ := f.ReadAt(hdr[:], end); n < headerLen {
+			break
+		}
+		n := int64(binary.LittleEndian.Uint32(hdr[eventLenOffset:]))
+		if n < headerLen || end+n > int64(last.Size) {
+			break
+		}
+		end += n
+	}
+	if int64(last.Size) != end {
+		if err := f.Truncate(end); err != nil {
+			return binlog.Position{}, errors.Annotate(err, "truncate relay log")
+		}
+	}
+	return binlog.Position{File: last.Name, Offset: uint64(end)}, nil
+}
+
+func (r *Relay) firstUpstreamLog(ctx context.Context) (string, error) {
+	conn, err := driver.ConnectContext(ctx, r.DSN, r.Config)
+	if err != nil {
+		return "", errors.Annotate(err, "establish connection")
+	}
+	defer conn.Close()
+	logs, err := conn.BinaryLogsContext(ctx)
+	if err != nil {
+		return "", errors.Annotate(err, "list binary logs")
+	}
+	if len(logs) == 0 {
+		return "", errors.New("Binary logging is disabled upstream")
+	}
+	return logs[0].Name, nil
+}
+
+// relayWriter writes events to relay logs.
+type relayWriter struct {
+	dir  *Dir
+	f    *os.File
+	file string
+	// checksum is set if events have checksums
+	checksum bool
+}
+
+// write writes an event received from the upstream server. Artificial events
+// only switch files, heartbeats are dropped.
+func (w *relayWriter) write(evt []byte) error {
+	if len(evt) < headerLen {
+		return errors.Annotatef(ErrCorruptEvent, "event of %d bytes received", len(evt))
+	}
+	et := binlog.EventType(evt[eventTypeOffset])
+	artificial := binary.LittleEndian.Uint16(evt[flagsOffset:])&logEventArtificial != 0
+	if et == binlog.EventTypeFormatDescription {
+		var fde binlog.FormatDescriptionEvent
+		if err := fde.Decode(evt[headerLen:]); err != nil {
+			return errors.Annotate(err, "decode format description event")
+		}
+		w.checksum = fde.ServerDetails.ChecksumAlgorithm == binlog.ChecksumAlgorithmCRC32
+	}
+	switch {
+	case et == binlog.EventTypeHeartbeet, et == binlog.EventTypeHeartbeatV2:
+		return nil
+	case et == binlog.EventTypeRotate && artificial:
+		if len(evt) < headerLen+8 {
+			return errors.Annotate(ErrCorruptEvent, "rotate event is too short")
+		}
+		// Artificial rotate events have no checksum
+		return w.open(string(evt[headerLen+8:]), binary.LittleEndian.Uint64(evt[headerLen:]))
+	case et == binlog.EventTypeFormatDescription && binary.LittleEndian.Uint32(evt[nextPosOffset:]) == 0:
+		// Format description event is sent again when a dump starts in the
+		// middle of a file
+		return nil
+	}
+	if w.f == nil {
+		return errors.New("Event received before rotate event")
+	}
+	if _, err := w.f.Write(evt); err != nil {
+		return errors.Annotate(err, "write relay log")
+	}
+	if et == binlog.EventTypeRotate {
+		// The next file is created right away, so that streams reaching the
+		// rotate event could open it
+		var re binlog.RotateEvent
+		body := evt[headerLen:]
+		if w.checksum && len(body) >= 4 {
+			body = body[:len(body)-4]
+		}
+		if err := re.Decode(body, binlog.FormatDescription{Version: 4}); err != nil {
+			return errors.Annotate(err, "decode rotate event")
+		}
+		if err := w.open(re.NextFile.File, uint64(len(binlog.FileMagic))); err != nil {
+			return err
+		}
+	}
+	w.dir.notify()
+	return nil
+}
+
+// open switches to the given relay log, which must end at the given offset.
+// The file is created if it doesn't exist.
+func (w *relayWriter) open(name string, offset uint64) error {
+	if name == w.file {
+		return nil
+	}
+	if name != filepath.Base(name) || !isLogName(name) {
+		return errors.Errorf("Invalid binary log file name %q", name)
+	}
+	w.close()
+	f, err := os.OpenFile(filepath.Join(w.dir.path, name), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Annotate(err, "open relay log")
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Annotate(err, "open relay log")
+	}
+	if fi.Size() == 0 {
+		if _, err := f.Write(binlog.FileMagic); err != nil {
+			f.Close()
+			return errors.Annotate(err, "write relay log")
+		}
+		fi, err = f.Stat()
+		if err != nil {
+			f.Close()
+			return errors.Annotate(err, "open relay log")
+		}
+	}
+	if uint64(fi.Size()) != offset {
+		f.Close()
+		return errors.Errorf("Relay log %s has %d bytes, upstream continues at %d", name, fi.Size(), offset)
+	}
+	w.f, w.file = f, name
+	w.dir.notify()
+	return nil
+}
+
+func (w *relayWriter) close() {
+	if w.f != nil {
+		w.f.Close()
+		w.f, w.file = nil, ""
+	}
+}
@@ -0,0 +1,563 @@
+// Package server implements the master side of the replication protocol, so
+// that one upstream binary log could be fanned out to many downstream
+// replicas: MySQL servers, bocadillo readers or any other replication clients.
+// Binary log files are served from a Source, which is usually a directory of
+// relay logs written by a Relay reading from the upstream server.
+//
+// Example:
+//
+//	dir := server.NewDir("/var/lib/bocadillo/relay")
+//	relay := &server.Relay{Dir: dir, DSN: upstreamDSN, Config: driver.Config{ServerID: 1000}}
+//	go relay.Run(ctx)
+//	srv := &server.Server{Source: dir, ServerID: 1000}
+//	log.Fatal(srv.ListenAndServe(":3307"))
+//
+// The server speaks just enough of the protocol for replicas to connect,
+// configure their sessions, register and start binary log dumps. Dumps are
+// position based, GTID based dumps are not supported.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vivino/bocadillo/buffer"
+	"github.com/Vivino/bocadillo/server/protocol"
+	"github.com/juju/errors"
+)
+
+// Commands.
+const (
+	comQuit           byte = 1
+	comQuery          byte = 3
+	comPing           byte = 14
+	comBinlogDump     byte = 18
+	comRegisterSlave  byte = 21
+	comBinlogDumpGTID byte = 30
+)
+
+// Error codes sent to replicas.
+const (
+	errCodeAccessDenied          uint16 = 1045
+	errCodeUnknownCommand        uint16 = 1047
+	errCodeParse                 uint16 = 1064
+	errCodeUnknownSystemVariable uint16 = 1193
+	errCodeMasterFatalReading    uint16 = 1236
+)
+
+// Capability flags.
+const (
+	clientLongPassword               uint32 = 1 << 0
+	clientLongFlag                   uint32 = 1 << 2
+	clientConnectWithDB              uint32 = 1 << 3
+	clientProtocol41                 uint32 = 1 << 9
+	clientTransactions               uint32 = 1 << 13
+	clientSecureConn                 uint32 = 1 << 15
+	clientPluginAuth                 uint32 = 1 << 19
+	clientConnectAttrs               uint32 = 1 << 20
+	clientPluginAuthLenEncClientData uint32 = 1 << 21
+
+	serverCapabilities = clientLongPassword | clientLongFlag | clientConnectWithDB |
+		clientProtocol41 | clientTransactions | clientSecureConn | clientPluginAuth |
+		clientConnectAttrs | clientPluginAuthLenEncClientData
+)
+
+const (
+	defaultVersion      = "8.0.36-bocadillo"
+	defaultWriteTimeout = time.Minute
+)
+
+var (
+	// ErrServerClosed is returned by Serve once the server is closed.
+	ErrServerClosed = errors.New("Server closed")
+	// ErrAccessDenied is returned when a replica fails to authenticate.
+	ErrAccessDenied = errors.New("Access denied")
+)
+
+// Server serves binary log dumps to replicas. Fields must not be changed once
+// the server is started.
+type Server struct {
+	// Source provides binary log files, see Dir.
+	Source Source
+	// ServerID is the server ID of the server, it must differ from the IDs
+	// of the upstream server and the replicas.
+	ServerID uint32
+	// Version is reported in the handshake and as @@version,
+	// 8.0.36-bocadillo by default.
+	Version string
+	// UUID is reported as @@server_uuid.
+	UUID string
+	// Users maps user names to passwords, which replicas authenticate with
+	// using mysql_native_password. Any credentials are accepted if it's
+	// empty.
+	Users map[string]string
+	// Vars contains global variables returned by SELECT @@name and SHOW
+	// VARIABLES queries, in addition to a few defaults describing a server
+	// with row based binary logging.
+	Vars map[string]string
+	// WriteTimeout limits the time spent sending each packet, so that stuck
+	// replicas are disconnected. Default timeout is one minute.
+	WriteTimeout time.Duration
+	// ErrorLog is called with errors that end replica connections, except
+	// for replicas disconnecting.
+	ErrorLog func(err error)
+
+	mu       sync.Mutex
+	ln       net.Listener
+	conns    map[net.Conn]struct{}
+	replicas map[uint32]Replica
+	lastID   uint32
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Replica is a replica registered with the server.
+type Replica struct {
+	ServerID uint32
+	// Host and Port are reported by the replica, they are usually set with
+	// the report_host and report_port options.
+	Host string
+	Port uint16
+	// UUID is the server UUID of the replica, MySQL replicas set it with
+	// the @slave_uuid or @replica_uuid user variable.
+	UUID string
+	// Addr is the network address the replica is connected from.
+	Addr string
+	// ConnectionID is the identifier of the connection.
+	ConnectionID uint32
+}
+
+// ListenAndServe listens on the given TCP address and serves replicas. It
+// returns ErrServerClosed once the server is closed.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on the listener and serves replicas until the
+// server is closed, then ErrServerClosed is returned. The listener is closed
+// on return.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	s.ln = ln
+	s.conns = make(map[net.Conn]struct{})
+	s.replicas = make(map[uint32]Replica)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.mu.Unlock()
+	defer ln.Close()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return ErrServerClosed
+		}
+		s.conns[nc] = struct{}{}
+		s.lastID++
+		id := s.lastID
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, nc)
+				delete(s.replicas, id)
+				s.mu.Unlock()
+				nc.Close()
+			}()
+			c := protocol.NewConn(nc)
+			c.WriteTimeout = s.writeTimeout()
+			if err := s.serve(c, id); err != nil && s.ErrorLog != nil {
+				s.ErrorLog(errors.Annotatef(err, "replica %s", nc.RemoteAddr()))
+			}
+		}()
+	}
+}
+
+// Addr returns the address the server listens on, or nil if it's not started.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Replicas returns replicas registered with the server that are connected.
+func (s *Server) Replicas() []Replica {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replicas := make([]Replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		replicas = append(replicas, r)
+	}
+	return replicas
+}
+
+// Close stops the server, closes all connections and waits for them to be
+// served.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+		s.cancel()
+	}
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) writeTimeout() time.Duration {
+	if s.WriteTimeout > 0 {
+		return s.WriteTimeout
+	}
+	return defaultWriteTimeout
+}
+
+func (s *Server) version() string {
+	if s.Version != "" {
+		return s.Version
+	}
+	return defaultVersion
+}
+
+// session contains the state of a replica session.
+type session struct {
+	id uint32
+	// vars contains user variables
+	vars map[string]string
+}
+
+func (s *Server) serve(c *protocol.Conn, id uint32) error {
+	if err := s.handshake(c, id); err != nil {
+		return err
+	}
+	sess := &session{id: id, vars: make(map[string]string)}
+	for {
+		pkt, err := c.ReadPacket()
+		if err != nil || len(pkt) == 0 {
+			// Replicas disconnecting are not worth reporting
+			return nil
+		}
+		switch pkt[0] {
+		case comQuit:
+			return nil
+		case comPing:
+			err = c.WriteOK()
+		case comRegisterSlave:
+			err = s.registerReplica(c, sess, pkt)
+		case comQuery:
+			err = s.query(c, sess, string(pkt[1:]))
+		case comBinlogDump:
+			return s.dump(c, sess, pkt)
+		case comBinlogDumpGTID:
+			return c.WriteError(errCodeMasterFatalReading, "GTID based dumps are not supported")
+		default:
+			err = c.WriteError(errCodeUnknownCommand, "Unknown command")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handshake sends the initial handshake packet and authenticates the replica.
+// Spec: https://dev.mysql.com/doc/internals/en/connection-phase-packets.html
+func (s *Server) handshake(c *protocol.Conn, id uint32) error {
+	scramble, err := newScramble()
+	if err != nil {
+		return err
+	}
+	hs := []byte{10}
+	hs = append(append(hs, s.version()...), 0)
+	hs = append(hs, byte(id), byte(id>>8), byte(id>>16), byte(id>>24))
+	hs = append(hs, scramble[:8]...)
+	hs = append(hs, 0)
+	caps := serverCapabilities
+	hs = append(hs, byte(caps), byte(caps>>8), 33, 2, 0, byte(caps>>16), byte(caps>>24), byte(len(scramble)+1))
+	hs = append(hs, make([]byte, 10)...)
+	hs = append(append(hs, scramble[8:]...), 0)
+	hs = append(append(hs, "mysql_native_password"...), 0)
+	if err := c.WritePacket(hs); err != nil {
+		return err
+	}
+
+	pkt, err := c.ReadPacket()
+	if err != nil {
+		return err
+	}
+	user, authResp, err := parseHandshakeResponse(pkt)
+	if err != nil {
+		c.WriteError(errCodeParse, "Malformed handshake response")
+		return err
+	}
+	if !s.authenticate(user, scramble, authResp) {
+		c.WriteError(errCodeAccessDenied, fmt.Sprintf("Access denied for user '%s'", user))
+		return errors.Annotatef(ErrAccessDenied, "user %q", user)
+	}
+	return c.WriteOK()
+}
+
+// newScramble returns a random scramble of printable characters, as it's sent
+// as a null terminated string.
+func newScramble() ([]byte, error) {
+	scramble := make([]byte, 20)
+	if _, err := rand.Read(scramble); err != nil {
+		return nil, err
+	}
+	for i, b := range scramble {
+		scramble[i] = '!' + b%('~'-'!')
+	}
+	return scramble, nil
+}
+
+// parseHandshakeResponse returns the user name and authentication response
+// sent by the replica.
+func parseHandshakeResponse(pkt []byte) (string, []byte, error) {
+	buf := buffer.NewChecked(pkt)
+	caps := buf.ReadUint32()
+	if caps&clientProtocol41 == 0 {
+		return "", nil, errors.New("Protocol 4.1 is required")
+	}
+	buf.Skip(4 + 1 + 23) // Max packet size, collation and filler
+	user := string(readStringNullTerm(buf))
+	var authResp []byte
+	switch {
+	case caps&clientPluginAuthLenEncClientData != 0:
+		authResp, _ = buf.ReadStringLenEnc()
+	case caps&clientSecureConn != 0:
+		authResp = buf.ReadStringVarLen(int(buf.ReadUint8()))
+	default:
+		authResp = readStringNullTerm(buf)
+	}
+	return user, authResp, buf.Err()
+}
+
+// readStringNullTerm reads a null terminated string, a missing terminator is
+// a short buffer error.
+func readStringNullTerm(buf *buffer.Buffer) []byte {
+	cur := buf.Cur()
+	i := bytes.IndexByte(cur, 0)
+	if i < 0 {
+		buf.Skip(len(cur) + 1)
+		return nil
+	}
+	buf.Skip(i + 1)
+	return cur[:i]
+}
+
+// authenticate checks a mysql_native_password response:
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+func (s *Server) authenticate(user string, scramble, authResp []byte) bool {
+	if len(s.Users) == 0 {
+		return true
+	}
+	password, ok := s.Users[user]
+	if !ok {
+		return false
+	}
+	if password == "" {
+		return len(authResp) == 0
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	exp := h.Sum(nil)
+	for i := range exp {
+		exp[i] ^= stage1[i]
+	}
+	return bytes.Equal(exp, authResp)
+}
+
+// registerReplica handles a REGISTER_SLAVE command.
+// Spec: https://dev.mysql.com/doc/internals/en/com-register-slave.html
+func (s *Server) registerReplica(c *protocol.Conn, sess *session, pkt []byte) error {
+	buf := buffer.NewChecked(pkt[1:])
+	r := Replica{ServerID: buf.ReadUint32(), Addr: c.RemoteAddr().String(), ConnectionID: sess.id}
+	r.Host = string(buf.ReadStringVarLen(int(buf.ReadUint8())))
+	r.UUID = sess.vars["replica_uuid"]
+	if r.UUID == "" {
+		r.UUID = sess.vars["slave_uuid"]
+	}
+	if buf.Err() != nil {
+		return c.WriteError(errCodeParse, "Malformed register replica command")
+	}
+	// User name and password are not used, the port is optional
+	buf.ReadStringVarLen(int(buf.ReadUint8()))
+	buf.ReadStringVarLen(int(buf.ReadUint8()))
+	if port := buf.ReadUint16(); buf.Err() == nil {
+		r.Port = port
+	}
+	s.mu.Lock()
+	s.replicas[sess.id] = r
+	s.mu.Unlock()
+	return c.WriteOK()
+}
+
+// vars returns global variables.
+func (s *Server) vars() map[string]string {
+	vars := map[string]string{
+		"version":          s.version(),
+		"version_comment":  "bocadillo",
+		"server_id":        strconv.FormatUint(uint64(s.ServerID), 10),
+		"server_uuid":      s.UUID,
+		"log_bin":          "ON",
+		"binlog_format":    "ROW",
+		"binlog_row_image": "FULL",
+		"binlog_checksum":  "CRC32",
+		"gtid_mode":        "OFF",
+	}
+	for k, v := range s.Vars {
+		vars[strings.ToLower(k)] = v
+	}
+	return vars
+}
+
+var (
+	quotedRe = regexp.MustCompile(`'([^']*)'`)
+	assignRe = regexp.MustCompile(`^@(\w+)\s*:?=\s*(.+)$`)
+)
+
+// query responds to queries replicas run while configuring their sessions.
+func (s *Server) query(c *protocol.Conn, sess *session, q string) error {
+	q = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(q), ";"))
+	uq := strings.ToUpper(q)
+	switch {
+	case strings.HasPrefix(uq, "SET "):
+		// Only user variables are kept, session settings don't apply
+		vars := s.vars()
+		for _, expr := range strings.Split(q[len("SET "):], ",") {
+			m := assignRe.FindStringSubmatch(strings.TrimSpace(expr))
+			if m == nil {
+				continue
+			}
+			val := strings.TrimSpace(m[2])
+			if name := strings.ToLower(val); strings.HasPrefix(name, "@@") {
+				name = strings.TrimPrefix(name, "@@")
+				val = vars[strings.TrimPrefix(strings.TrimPrefix(name, "global."), "session.")]
+			} else {
+				val = strings.Trim(val, `'"`)
+			}
+			sess.vars[strings.ToLower(m[1])] = val
+		}
+		return c.WriteOK()
+
+	case strings.HasPrefix(uq, "SELECT "):
+		vars := s.vars()
+		var cols, row []string
+		for _, expr := range strings.Split(q[len("SELECT "):], ",") {
+			expr = strings.TrimSpace(expr)
+			name := strings.ToLower(expr)
+			switch {
+			case name == "unix_timestamp()":
+				cols, row = append(cols, expr), append(row, strconv.FormatInt(time.Now().Unix(), 10))
+			case strings.HasPrefix(name, "@@"):
+				name = strings.TrimPrefix(name, "@@")
+				name = strings.TrimPrefix(strings.TrimPrefix(name, "global."), "session.")
+				val, ok := vars[name]
+				if !ok {
+					return c.WriteError(errCodeUnknownSystemVariable, "Unknown system variable '"+name+"'")
+				}
+				cols, row = append(cols, expr), append(row, val)
+			case strings.HasPrefix(name, "@"):
+				cols, row = append(cols, expr), append(row, sess.vars[name[1:]])
+			default:
+				return c.WriteError(errCodeParse, "Unsupported query: "+q)
+			}
+		}
+		return c.WriteResult(cols, [][]string{row})
+
+	case strings.HasPrefix(uq, "SHOW GLOBAL VARIABLES"), strings.HasPrefix(uq, "SHOW VARIABLES"):
+		vars := s.vars()
+		var rows [][]string
+		for _, m := range quotedRe.FindAllStringSubmatch(q, -1) {
+			if val, ok := vars[strings.ToLower(m[1])]; ok {
+				rows = append(rows, []string{m[1], val})
+			}
+		}
+		return c.WriteResult([]string{"Variable_name", "Value"}, rows)
+
+	case uq == "SHOW BINARY LOGS", uq == "SHOW MASTER LOGS":
+		logs, err := s.Source.Logs()
+		if err != nil {
+			return c.WriteError(errCodeMasterFatalReading, err.Error())
+		}
+		var rows [][]string
+		for _, l := range logs {
+			rows = append(rows, []string{l.Name, strconv.FormatUint(l.Size, 10)})
+		}
+		return c.WriteResult([]string{"Log_name", "File_size"}, rows)
+
+	case uq == "SHOW MASTER STATUS", uq == "SHOW BINARY LOG STATUS":
+		logs, err := s.Source.Logs()
+		if err != nil {
+			return c.WriteError(errCodeMasterFatalReading, err.Error())
+		}
+		var rows [][]string
+		if n := len(logs); n > 0 {
+			rows = append(rows, []string{logs[n-1].Name, strconv.FormatUint(logs[n-1].Size, 10), "", "", ""})
+		}
+		return c.WriteResult([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, rows)
+
+	case uq == "SHOW SLAVE HOSTS", uq == "SHOW REPLICAS":
+		var rows [][]string
+		for _, r := range s.Replicas() {
+			rows = append(rows, []string{
+				strconv.FormatUint(uint64(r.ServerID), 10), r.Host, strconv.Itoa(int(r.Port)),
+				strconv.FormatUint(uint64(s.ServerID), 10), r.UUID,
+			})
+		}
+		return c.WriteResult([]string{"Server_id", "Host", "Port", "Master_id", "Slave_UUID"}, rows)
+
+	default:
+		return c.WriteError(errCodeParse, "Unsupported query: "+q)
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bocadillo")
+	if err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	return dir
+}
+
+func startServer(t *testing.T, srv *Server) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go srv.Serve(ln)
+	return ln.Addr().String()
+}
+
+// readXIDs reads events until n transactions are committed and returns their
+// XIDs.
+func readXIDs(ctx context.Context, t *testing.T, r *reader.Reader, n int) []uint64 {
+	var xids []uint64
+	for len(xids) < n {
+		evt, err := r.ReadEvent(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if evt.Header.Type == binlog.EventTypeXID {
+			var xe binlog.XIDEvent
+			if err := xe.Decode(evt.Buffer); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+			xids = append(xids, xe.XID)
+		}
+	}
+	return xids
+}
+
+func TestServeDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	write := func(name string, data []byte) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.XID(1)
+	write("mysql-bin.000001", append(g.Bytes(), g.Rotate("mysql-bin.000002")...))
+	g.FormatDescription()
+	g.XID(2)
+	write("mysql-bin.000002", g.Bytes())
+
+	src := NewDir(dir)
+	src.PollInterval = 10 * time.Millisecond
+	srv := &Server{Source: src, ServerID: 2, Users: map[string]string{"repl": "secret"}}
+	addr := startServer(t, srv)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := reader.New("repl:wrong@tcp("+addr+")/", driver.Config{ServerID: 1000}); err == nil {
+		t.Errorf("Expected authentication to fail")
+	}
+	r, err := reader.New("repl:secret@tcp("+addr+")/", driver.Config{ServerID: 1000, File: "mysql-bin.000001"})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	if xids := readXIDs(ctx, t, r, 2); xids[0] != 1 || xids[1] != 2 {
+		t.Errorf("Expected transactions [1 2], got %v", xids)
+	}
+	g.XID(3)
+	write("mysql-bin.000002", g.Bytes())
+	if xids := readXIDs(ctx, t, r, 1); xids[0] != 3 {
+		t.Errorf("Expected transaction 3, got %v", xids)
+	}
+	if pos := r.State(); pos != g.Position() {
+		t.Errorf("Expected position %v, got %v", g.Position(), pos)
+	}
+	if reps := srv.Replicas(); len(reps) != 1 || reps[0].ServerID != 1000 {
+		t.Errorf("Expected replica 1000 to be registered, got %+v", reps)
+	}
+}
+
+func TestServeDirOffsetChecksum(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.XID(1)
+	offset := g.Position().Offset
+	g.XID(2)
+	if err := ioutil.WriteFile(filepath.Join(dir, "mysql-bin.000001"), g.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	srv := &Server{Source: NewDir(dir), ServerID: 2}
+	addr := startServer(t, srv)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := reader.New("repl@tcp("+addr+")/",
+		driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: uint32(offset)}, reader.WithChecksums())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+
+	// The format description is sent as an artificial event with the
+	// position cleared
+	for {
+		evt, err := r.ReadEvent(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if evt.Header.Type != binlog.EventTypeFormatDescription {
+			continue
+		}
+		if evt.Header.NextOffset != 0 {
+			t.Errorf("Expected artificial format description, got next offset %d", evt.Header.NextOffset)
+		}
+		if sum := crc32.ChecksumIEEE(evt.Raw[:len(evt.Raw)-4]); !evt.HasChecksum || evt.Checksum != sum {
+			t.Errorf("Expected checksum %08x, got %08x", sum, evt.Checksum)
+		}
+		break
+	}
+	if xids := readXIDs(ctx, t, r, 1); xids[0] != 2 {
+		t.Errorf("Expected transaction 2, got %v", xids)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	g := binlogtest.New()
+	g.FormatDescription()
+	g.XID(1)
+	upstream := &binlogtest.Server{}
+	upstream.AddFile(g.Position().File, g.Bytes())
+	if err := upstream.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer upstream.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	relay := &Relay{
+		Dir:    NewDir(dir),
+		DSN:    upstream.DSN(),
+		Config: driver.Config{ServerID: 1000, DumpFlags: driver.DumpFlagNonBlock},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("Failed to relay: %v", err)
+	}
+
+	// Relaying resumes at the end of the last relay log
+	first := append(g.Bytes(), g.Rotate("mysql-bin.000002")...)
+	upstream.AddFile("mysql-bin.000001", first)
+	upstream.Append("mysql-bin.000002", g.FormatDescription(), g.XID(2))
+	if err := relay.Run(ctx); err != nil {
+		t.Fatalf("Failed to relay: %v", err)
+	}
+	for name, exp := range map[string][]byte{"mysql-bin.000001": first, "mysql-bin.000002": g.Bytes()} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read relay log: %v", err)
+		}
+		if !bytes.Equal(data, exp) {
+			t.Errorf("Expected relay log %s to match the binary log", name)
+		}
+	}
+
+	srv := &Server{Source: relay.Dir, ServerID: 2}
+	addr := startServer(t, srv)
+	defer srv.Close()
+	r, err := reader.New("repl@tcp("+addr+")/", driver.Config{ServerID: 1001, File: "mysql-bin.000001", Offset: 4})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer r.Close(ctx)
+	if xids := readXIDs(ctx, t, r, 2); xids[0] != 1 || xids[1] != 2 {
+		t.Errorf("Expected transactions [1 2], got %v", xids)
+	}
+}
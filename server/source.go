@@ -0,0 +1,359 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/juju/errors"
+)
+
+// Source provides binary log files served to replicas.
+type Source interface {
+	// Open returns a stream of events starting with the format description
+	// event of the file followed by events at the given offset. An empty
+	// file name means the first file. ErrLogNotFound is returned if the file
+	// doesn't exist.
+	Open(pos binlog.Position) (Stream, error)
+	// Logs returns binary log files, oldest first. The last one is the file
+	// being written.
+	Logs() ([]driver.BinaryLog, error)
+}
+
+// Stream is a stream of events of the binary log, it follows rotate events
+// to the next files.
+type Stream interface {
+	// Next returns the next event including the header and, if the binary
+	// log has them, the checksum. The event is only valid until the next
+	// call. Nil is returned once the end of the binary log is reached.
+	Next() ([]byte, error)
+	// Wait blocks until more events could be available or the context is
+	// done.
+	Wait(ctx context.Context) error
+	// Position returns the position of the next event.
+	Position() binlog.Position
+	// Close releases resources held by the stream.
+	Close() error
+}
+
+var (
+	// ErrLogNotFound is returned when a requested binary log file doesn't
+	// exist.
+	ErrLogNotFound = errors.New("Could not find first log file name in binary log index file")
+	// ErrInvalidFile is returned when a file is not a binary log file.
+	ErrInvalidFile = errors.New("Not a binary log file")
+	// ErrCorruptEvent is returned when an event header declares an invalid
+	// length.
+	ErrCorruptEvent = errors.New("Event is corrupt")
+)
+
+// Offsets of event header fields.
+const (
+	eventTypeOffset = 4
+	eventLenOffset  = 9
+	nextPosOffset   = 13
+	flagsOffset     = 17
+	headerLen       = 19
+)
+
+// logEventArtificial is set in headers of events that are not written to the
+// binary log.
+const logEventArtificial = 0x20
+
+const defaultPollInterval = time.Second
+
+// Dir is a source of binary log files stored in a directory, such as relay
+// logs written by a Relay or files copied from a server. Files are ordered by
+// name, the way MySQL names them, and only files with numeric extensions are
+// served. Files may grow while being served, streams pick up new events.
+type Dir struct {
+	// PollInterval is how often streams at the end of the binary log check
+	// files for new events, one second by default. Events written by a Relay
+	// are picked up right away.
+	PollInterval time.Duration
+
+	path    string
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// NewDir creates a source of binary log files in the given directory.
+func NewDir(path string) *Dir {
+	return &Dir{path: path, changed: make(chan struct{})}
+}
+
+// Path returns the path of the directory.
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// Logs returns binary log files in the directory, oldest first.
+func (d *Dir) Logs() ([]driver.BinaryLog, error) {
+	fis, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return nil, errors.Annotate(err, "list binary log files")
+	}
+	var logs []driver.BinaryLog
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && isLogName(fi.Name()) {
+			logs = append(logs, driver.BinaryLog{Name: fi.Name(), Size: uint64(fi.Size())})
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Name < logs[j].Name })
+	return logs, nil
+}
+
+// isLogName returns true if the name looks like a binary log file name, e.g.
+// mysql-bin.000042.
+func isLogName(name string) bool {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 || i == len(name)-1 {
+		return false
+	}
+	for _, c := range name[i+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// nextLog returns the name of the file following the given one, or an empty
+// string if there's none yet.
+func (d *Dir) nextLog(name string) (string, error) {
+	logs, err := d.Logs()
+	if err != nil {
+		return "", err
+	}
+	for _, l := range logs {
+		if l.Name > name {
+			return l.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// Open opens a stream of events starting at the given position.
+func (d *Dir) Open(pos binlog.Position) (Stream, error) {
+	if pos.File == "" {
+		logs, err := d.Logs()
+		if err != nil {
+			return nil, err
+		}
+		if len(logs) == 0 {
+			return nil, ErrLogNotFound
+		}
+		pos.File = logs[0].Name
+	}
+	if pos.File != filepath.Base(pos.File) || !isLogName(pos.File) {
+		return nil, ErrLogNotFound
+	}
+	s := &dirStream{dir: d}
+	ok, err := s.open(pos.File)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLogNotFound
+	}
+	if pos.Offset > uint64(len(binlog.FileMagic)) {
+		s.seek = pos.Offset
+	}
+	return s, nil
+}
+
+// notify wakes up streams waiting for new events.
+func (d *Dir) notify() {
+	d.mu.Lock()
+	close(d.changed)
+	d.changed = make(chan struct{})
+	d.mu.Unlock()
+}
+
+// waitChan returns a channel that is closed once notify is called.
+func (d *Dir) waitChan() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.changed
+}
+
+func (d *Dir) pollInterval() time.Duration {
+	if d.PollInterval > 0 {
+		return d.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// dirStream reads events of files in a directory.
+type dirStream struct {
+	dir  *Dir
+	f    *os.File
+	file string
+	// offset is the position of buf[0] in the file
+	offset uint64
+	buf    []byte
+	start  int
+	// seek is the offset events are skipped to once the format description
+	// event is read
+	seek uint64
+	// checksum is set if events of the file have checksums
+	checksum bool
+	// next is the file to continue with once the current one ends
+	next    string
+	changed <-chan struct{}
+}
+
+// open opens a file, false is returned if it doesn't exist yet.
+func (s *dirStream) open(name string) (bool, error) {
+	f, err := os.Open(filepath.Join(s.dir.path, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Annotate(err, "open binary log file")
+	}
+	magic := make([]byte, len(binlog.FileMagic))
+	if n, err := f.ReadAt(magic, 0); n < len(magic) {
+		f.Close()
+		if err == io.EOF {
+			// The file is being created
+			return false, nil
+		}
+		return false, errors.Annotate(err, "read binary log file")
+	}
+	if !bytes.Equal(magic, binlog.FileMagic) {
+		f.Close()
+		return false, ErrInvalidFile
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f, s.file = f, name
+	s.offset, s.buf, s.start = uint64(len(binlog.FileMagic)), s.buf[:0], 0
+	s.next, s.seek = "", 0
+	return true, nil
+}
+
+func (s *dirStream) Next() ([]byte, error) {
+	s.changed = s.dir.waitChan()
+	for {
+		if evt, err := s.event(); evt != nil || err != nil {
+			return evt, err
+		}
+		n, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			continue
+		}
+		if s.next == "" {
+			// The file could be left without a rotate event if the server
+			// crashed, the next one is used if it exists
+			if s.next, err = s.dir.nextLog(s.file); err != nil || s.next == "" {
+				return nil, err
+			}
+			// Events could have been appended before the file was created
+			continue
+		}
+		ok, err := s.open(s.next)
+		if err != nil || !ok {
+			return nil, err
+		}
+	}
+}
+
+// event returns the next buffered event, if it's read completely.
+func (s *dirStream) event() ([]byte, error) {
+	data := s.buf[s.start:]
+	if len(data) < headerLen {
+		return nil, nil
+	}
+	n := int(binary.LittleEndian.Uint32(data[eventLenOffset:]))
+	if n < headerLen {
+		return nil, errors.Annotatef(ErrCorruptEvent, "event at %s:%d has length %d", s.file, s.offset+uint64(s.start), n)
+	}
+	if len(data) < n {
+		return nil, nil
+	}
+	evt := data[:n]
+	s.start += n
+
+	switch binlog.EventType(evt[eventTypeOffset]) {
+	case binlog.EventTypeFormatDescription:
+		var fde binlog.FormatDescriptionEvent
+		if err := fde.Decode(evt[headerLen:]); err != nil {
+			return nil, errors.Annotate(err, "decode format description event")
+		}
+		s.checksum = fde.ServerDetails.ChecksumAlgorithm == binlog.ChecksumAlgorithmCRC32
+		if s.seek > s.offset+uint64(s.start) {
+			s.offset, s.buf, s.start = s.seek, s.buf[:0], 0
+		}
+		s.seek = 0
+	case binlog.EventTypeRotate:
+		body := evt[headerLen:]
+		if s.checksum && len(body) >= 4 {
+			body = body[:len(body)-4]
+		}
+		if len(body) < 8 {
+			return nil, errors.Annotatef(ErrCorruptEvent, "rotate event at %s:%d is too short", s.file, s.offset+uint64(s.start-n))
+		}
+		s.next = string(body[8:])
+	}
+	return evt, nil
+}
+
+// read reads more data into the buffer.
+func (s *dirStream) read() (int, error) {
+	const minRead = 64 << 10
+	n := copy(s.buf, s.buf[s.start:])
+	s.offset += uint64(s.start)
+	s.buf, s.start = s.buf[:n], 0
+	need := minRead
+	if n >= headerLen {
+		if evtLen := int(binary.LittleEndian.Uint32(s.buf[eventLenOffset:])); evtLen-n > need {
+			need = evtLen - n
+		}
+	}
+	if cap(s.buf)-n < need {
+		buf := make([]byte, n, n+need)
+		copy(buf, s.buf)
+		s.buf = buf
+	}
+	m, err := s.f.ReadAt(s.buf[n:cap(s.buf)], int64(s.offset)+int64(n))
+	s.buf = s.buf[:n+m]
+	if err == io.EOF {
+		err = nil
+	}
+	return m, errors.Annotate(err, "read binary log file")
+}
+
+func (s *dirStream) Wait(ctx context.Context) error {
+	t := time.NewTimer(s.dir.pollInterval())
+	defer t.Stop()
+	select {
+	case <-s.changed:
+	case <-t.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (s *dirStream) Position() binlog.Position {
+	return binlog.Position{File: s.file, Offset: s.offset + uint64(s.start)}
+}
+
+func (s *dirStream) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,130 @@
+// Package kafka publishes change events to Kafka. It doesn't depend on a
+// particular Kafka client, messages are handed over to a Producer which wraps
+// the client of choice.
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/encode/debezium"
+	"github.com/Vivino/bocadillo/reader"
+	"github.com/Vivino/bocadillo/sink"
+)
+
+// Message is a message to be published.
+type Message struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	// Partition is the partition to publish to, -1 lets the producer decide.
+	Partition int32
+}
+
+// Producer publishes messages. Produce must only return once all messages are
+// acknowledged by the brokers, which guarantees that a checkpoint is never
+// saved ahead of published messages.
+type Producer interface {
+	Produce(ctx context.Context, msgs []Message) error
+}
+
+// Record is a serialized change event.
+type Record struct {
+	Key   []byte
+	Value []byte
+}
+
+// Encoder serializes row changes of a rows event.
+type Encoder func(evt *reader.Event) ([]Record, error)
+
+// Config configures the sink.
+type Config struct {
+	// Topic returns the topic for a table. By default the topic is named
+	// after the schema and the table, e.g. "shop.orders".
+	Topic func(schema, table string) string
+	// Encoder serializes row changes. Debezium envelopes keyed by primary key
+	// are produced by default.
+	Encoder Encoder
+	// Partitions is the number of partitions of each topic. When set, the
+	// partition is chosen by hashing the message key, so that changes of the
+	// same row always end up in the same partition. Messages without keys and
+	// all messages when partitions is zero are partitioned by the producer.
+	Partitions int
+}
+
+// Sink publishes row changes to Kafka. It implements sink.Sink and is meant to
+// be used with sink.Runner, which checkpoints the position once messages are
+// published.
+type Sink struct {
+	producer Producer
+	conf     Config
+	batch    []Message
+}
+
+var _ sink.Sink = &Sink{}
+
+// New creates a new sink.
+func New(p Producer, conf Config) *Sink {
+	if conf.Topic == nil {
+		conf.Topic = func(schema, table string) string { return schema + "." + table }
+	}
+	if conf.Encoder == nil {
+		conf.Encoder = encodeDebezium
+	}
+	return &Sink{producer: p, conf: conf}
+}
+
+// Write encodes row changes and publishes them. Other events are ignored.
+func (s *Sink) Write(ctx context.Context, batch []*reader.Event) error {
+	s.batch = s.batch[:0]
+	for _, evt := range batch {
+		if evt.Table == nil || binlog.RowsEventVersion(evt.Header.Type) < 0 {
+			continue
+		}
+		recs, err := s.conf.Encoder(evt)
+		if err != nil {
+			return err
+		}
+		topic := s.conf.Topic(evt.Table.SchemaName, evt.Table.TableName)
+		for _, rec := range recs {
+			s.batch = append(s.batch, Message{
+				Topic:     topic,
+				Key:       rec.Key,
+				Value:     rec.Value,
+				Partition: s.partition(rec.Key),
+			})
+		}
+	}
+	if len(s.batch) == 0 {
+		return nil
+	}
+	return s.producer.Produce(ctx, s.batch)
+}
+
+// Flush does nothing since messages are published synchronously by Write.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *Sink) partition(key []byte) int32 {
+	if s.conf.Partitions <= 0 || key == nil {
+		return -1
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int32(h.Sum32() % uint32(s.conf.Partitions))
+}
+
+// encodeDebezium is the default encoder that produces Debezium envelopes.
+func encodeDebezium(evt *reader.Event) ([]Record, error) {
+	msgs, err := debezium.Formatter{}.Format(evt)
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]Record, len(msgs))
+	for i, msg := range msgs {
+		recs[i] = Record{Key: msg.Key, Value: msg.Value}
+	}
+	return recs, nil
+}
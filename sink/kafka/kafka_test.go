@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+type testProducer struct {
+	batches [][]Message
+}
+
+func (p *testProducer) Produce(ctx context.Context, msgs []Message) error {
+	p.batches = append(p.batches, append([]Message(nil), msgs...))
+	return nil
+}
+
+func TestSinkWrite(t *testing.T) {
+	p := &testProducer{}
+	s := New(p, Config{
+		Partitions: 4,
+		Encoder: func(evt *reader.Event) ([]Record, error) {
+			return []Record{{Key: []byte("1"), Value: []byte("a")}, {Value: []byte("b")}}, nil
+		},
+	})
+
+	td := &binlog.TableDescription{SchemaName: "shop", TableName: "orders"}
+	pos := binlog.Position{File: "mysql-bin.000001", Offset: 100}
+	batch := []*reader.Event{
+		{Header: binlog.EventHeader{Type: binlog.EventTypeWriteRowsV2}, Table: td, EndPosition: pos},
+		{Header: binlog.EventHeader{Type: binlog.EventTypeXID}, EndPosition: pos, CommitPosition: pos},
+	}
+	if err := s.Write(context.Background(), batch); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(p.batches) != 1 || len(p.batches[0]) != 2 {
+		t.Fatalf("Expected a batch of 2 messages, got %v", p.batches)
+	}
+	msgs := p.batches[0]
+	if msgs[0].Topic != "shop.orders" {
+		t.Errorf("Expected topic shop.orders, got %s", msgs[0].Topic)
+	}
+	if msgs[0].Partition < 0 || msgs[0].Partition >= 4 {
+		t.Errorf("Expected partition to be chosen by key, got %d", msgs[0].Partition)
+	}
+	if msgs[1].Partition != -1 {
+		t.Errorf("Expected message without a key to be partitioned by producer, got %d", msgs[1].Partition)
+	}
+}
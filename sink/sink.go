@@ -0,0 +1,73 @@
+// Package sink defines a common interface for destinations of change events
+// and a runner that delivers events from a reader to a sink with at-least-once
+// guarantees.
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/Vivino/bocadillo/reader"
+)
+
+// Sink is a destination of change events.
+type Sink interface {
+	// Write delivers a batch of events. Events remain valid until Flush
+	// returns. Sink may buffer events, they're only considered delivered once
+	// Flush returns successfully.
+	Write(ctx context.Context, batch []*reader.Event) error
+	// Flush blocks until all written events are delivered.
+	Flush(ctx context.Context) error
+}
+
+// Runner reads events from a reader and writes them to a sink. The position
+// is checkpointed only after events are flushed, so every event is delivered
+// at least once: events that were delivered but not checkpointed are delivered
+// again after a restart. Reader should be configured with a checkpoint store.
+type Runner struct {
+	Reader *reader.Reader
+	Sink   Sink
+	// MaxBatch limits the number of events written at once. Default is 1000.
+	MaxBatch int
+	// MaxWait limits the time spent collecting a batch. Default is one
+	// second.
+	MaxWait time.Duration
+}
+
+// Run delivers events until the context is done or an error occurs.
+func (r *Runner) Run(ctx context.Context) error {
+	maxBatch := r.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = 1000
+	}
+	maxWait := r.MaxWait
+	if maxWait <= 0 {
+		maxWait = time.Second
+	}
+
+	for {
+		batch, err := r.Reader.ReadBatch(ctx, maxBatch, maxWait)
+		if err != nil {
+			// Events read so far would be read again after restart
+			return err
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		if err := r.Sink.Write(ctx, batch); err != nil {
+			return err
+		}
+		if err := r.Sink.Flush(ctx); err != nil {
+			return err
+		}
+		// Commit position of the last event covers all transactions that
+		// were committed within the batch
+		r.Reader.Checkpoint(batch[len(batch)-1].CommitPosition)
+		if err := r.Reader.Flush(ctx); err != nil {
+			return err
+		}
+		for _, evt := range batch {
+			evt.Release()
+		}
+	}
+}
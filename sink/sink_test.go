@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/binlogtest"
+	"github.com/Vivino/bocadillo/mysql/driver"
+	"github.com/Vivino/bocadillo/reader"
+)
+
+type memStore struct {
+	mu  sync.Mutex
+	pos *binlog.Position
+}
+
+func (s *memStore) Load(_ context.Context) (binlog.Position, bool, error) {
+	return binlog.Position{}, false, nil
+}
+
+func (s *memStore) Save(_ context.Context, pos binlog.Position) error {
+	s.mu.Lock()
+	s.pos = &pos
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memStore) saved() *binlog.Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pos
+}
+
+type fakeSink struct {
+	store    *memStore
+	flushErr error
+	cancel   context.CancelFunc
+
+	written int
+	// savedOnFlush is the position saved by the time Flush was called
+	savedOnFlush *binlog.Position
+}
+
+func (s *fakeSink) Write(_ context.Context, batch []*reader.Event) error {
+	s.written += len(batch)
+	return nil
+}
+
+func (s *fakeSink) Flush(_ context.Context) error {
+	s.savedOnFlush = s.store.saved()
+	s.cancel()
+	return s.flushErr
+}
+
+func TestRunner(t *testing.T) {
+	errFlush := errors.New("flush failed")
+	for _, flushErr := range []error{nil, errFlush} {
+		g := binlogtest.New()
+		g.FormatDescription()
+		srv := &binlogtest.Server{}
+		srv.AddFile(g.Position().File, g.Bytes())
+		if err := srv.Start(); err != nil {
+			t.Fatalf("Failed to start server: %v", err)
+		}
+		defer srv.Close()
+		srv.Append(g.Position().File, g.XID(1))
+
+		store := &memStore{}
+		r, err := reader.New(srv.DSN(), driver.Config{ServerID: 1000, File: "mysql-bin.000001", Offset: 4},
+			reader.WithCheckpointStore(store))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		sink := &fakeSink{store: store, flushErr: flushErr, cancel: cancel}
+		runner := &Runner{Reader: r, Sink: sink, MaxWait: 100 * time.Millisecond}
+		err = runner.Run(ctx)
+		cancel()
+
+		if sink.written == 0 {
+			t.Fatal("Expected events to be written")
+		}
+		if sink.savedOnFlush != nil {
+			t.Errorf("Expected nothing to be committed before flushing, got %v", *sink.savedOnFlush)
+		}
+		saved := store.saved()
+		if flushErr != nil {
+			if err != errFlush {
+				t.Errorf("Expected flush error, got %v", err)
+			}
+			if saved != nil {
+				t.Errorf("Expected nothing to be committed after failed flush, got %v", *saved)
+			}
+		} else if saved == nil || *saved != g.Position() {
+			t.Errorf("Expected position %v to be committed, got %v", g.Position(), saved)
+		}
+		r.Close(context.Background())
+	}
+}
@@ -0,0 +1,258 @@
+// Package sqlgen reconstructs SQL statements from row changes, so that changes
+// read from the binary log could be applied to another database. Statements
+// use placeholders for values and are meant to be executed with database/sql.
+package sqlgen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+)
+
+// ErrNoColumnNames is returned when table metadata lacks column names, which
+// are only logged by servers with binlog_row_metadata set to FULL.
+var ErrNoColumnNames = errors.New("Column names are unknown")
+
+// Statement is a query with arguments for its placeholders.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Insert returns an INSERT statement for a row.
+func Insert(td binlog.TableDescription, row []interface{}) (Statement, error) {
+	return insert(td, binlog.RowChange{Type: binlog.ChangeInsert, After: row})
+}
+
+// insert returns an INSERT statement for the columns present in the image
+// after the change, absent columns get their default values.
+func insert(td binlog.TableDescription, c binlog.RowChange) (Statement, error) {
+	if err := checkColumns(td, c.After); err != nil {
+		return Statement{}, err
+	}
+	cols := presentColumns(c.After, c.AfterHas)
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	writeTable(&b, td)
+	b.WriteString(" (")
+	for n, i := range cols {
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(QuoteIdent(td.ColumnNames[i]))
+	}
+	b.WriteString(") VALUES (")
+	args := make([]interface{}, len(cols))
+	for n, i := range cols {
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('?')
+		args[n] = value(td, i, c.After[i])
+	}
+	b.WriteByte(')')
+	return Statement{Query: b.String(), Args: args}, nil
+}
+
+// Update returns an UPDATE statement that changes a row from its before image
+// to its after image.
+func Update(td binlog.TableDescription, before, after []interface{}) (Statement, error) {
+	return update(td, binlog.RowChange{Type: binlog.ChangeUpdate, Before: before, After: after})
+}
+
+// update returns an UPDATE statement that sets the columns present in the
+// image after the change of the row identified by the image before it.
+func update(td binlog.TableDescription, c binlog.RowChange) (Statement, error) {
+	if err := checkColumns(td, c.Before); err != nil {
+		return Statement{}, err
+	}
+	if err := checkColumns(td, c.After); err != nil {
+		return Statement{}, err
+	}
+	if err := checkKey(td, c.BeforeHas); err != nil {
+		return Statement{}, err
+	}
+	cols := presentColumns(c.After, c.AfterHas)
+	if len(cols) == 0 {
+		return Statement{}, fmt.Errorf("no columns are present in the image after the update")
+	}
+	var b strings.Builder
+	b.WriteString("UPDATE ")
+	writeTable(&b, td)
+	b.WriteString(" SET ")
+	args := make([]interface{}, 0, len(c.After)+len(c.Before))
+	for n, i := range cols {
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(QuoteIdent(td.ColumnNames[i]))
+		b.WriteString(" = ?")
+		args = append(args, value(td, i, c.After[i]))
+	}
+	args = writeWhere(&b, td, c.Before, c.BeforeHas, args)
+	return Statement{Query: b.String(), Args: args}, nil
+}
+
+// Delete returns a DELETE statement for a row.
+func Delete(td binlog.TableDescription, row []interface{}) (Statement, error) {
+	return deleteRow(td, binlog.RowChange{Type: binlog.ChangeDelete, Before: row})
+}
+
+// deleteRow returns a DELETE statement for the row identified by the image
+// before the change.
+func deleteRow(td binlog.TableDescription, c binlog.RowChange) (Statement, error) {
+	if err := checkColumns(td, c.Before); err != nil {
+		return Statement{}, err
+	}
+	if err := checkKey(td, c.BeforeHas); err != nil {
+		return Statement{}, err
+	}
+	var b strings.Builder
+	b.WriteString("DELETE FROM ")
+	writeTable(&b, td)
+	args := writeWhere(&b, td, c.Before, c.BeforeHas, nil)
+	return Statement{Query: b.String(), Args: args}, nil
+}
+
+// FromRowsEvent returns statements for every row change of a rows event.
+func FromRowsEvent(td binlog.TableDescription, re binlog.RowsEvent) ([]Statement, error) {
+	if binlog.RowsEventVersion(re.Type) < 0 {
+		return nil, fmt.Errorf("not a rows event: %s", re.Type.String())
+	}
+	var stmts []Statement
+	for _, c := range re.Changes() {
+		var s Statement
+		var err error
+		switch c.Type {
+		case binlog.ChangeInsert:
+			s, err = insert(td, c)
+		case binlog.ChangeUpdate:
+			s, err = update(td, c)
+		case binlog.ChangeDelete:
+			s, err = deleteRow(td, c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+// QuoteIdent quotes an identifier with backticks.
+func QuoteIdent(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func writeTable(b *strings.Builder, td binlog.TableDescription) {
+	if td.SchemaName != "" {
+		b.WriteString(QuoteIdent(td.SchemaName))
+		b.WriteByte('.')
+	}
+	b.WriteString(QuoteIdent(td.TableName))
+}
+
+// writeWhere writes a WHERE clause that identifies a row by its primary key.
+// When the primary key is unknown all present columns are compared and the
+// statement is limited to a single row, since the table may contain
+// duplicates.
+func writeWhere(b *strings.Builder, td binlog.TableDescription, row []interface{}, has func(int) bool, args []interface{}) []interface{} {
+	cols := td.PrimaryKey
+	if len(cols) == 0 {
+		cols = presentColumns(row, has)
+	}
+	b.WriteString(" WHERE ")
+	for n, i := range cols {
+		if n > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(QuoteIdent(td.ColumnNames[i]))
+		if row[i] == nil {
+			b.WriteString(" IS NULL")
+			continue
+		}
+		b.WriteString(" = ?")
+		args = append(args, value(td, i, row[i]))
+	}
+	if len(td.PrimaryKey) == 0 {
+		b.WriteString(" LIMIT 1")
+	}
+	return args
+}
+
+// presentColumns returns indexes of the columns present in a row image.
+func presentColumns(row []interface{}, has func(int) bool) []int {
+	cols := make([]int, 0, len(row))
+	for i := range row {
+		if has(i) {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+// checkKey returns an error if primary key columns are absent from a row
+// image, so that the row could not be identified.
+func checkKey(td binlog.TableDescription, has func(int) bool) error {
+	for _, i := range td.PrimaryKey {
+		if !has(i) {
+			return fmt.Errorf("primary key column %d is absent from the row image", i)
+		}
+	}
+	return nil
+}
+
+func checkColumns(td binlog.TableDescription, row []interface{}) error {
+	if len(td.ColumnNames) < len(row) {
+		return ErrNoColumnNames
+	}
+	for _, i := range td.PrimaryKey {
+		if i >= len(row) {
+			return fmt.Errorf("primary key column %d is out of range", i)
+		}
+	}
+	return nil
+}
+
+// value converts a decoded value into a statement argument. Integers decoded
+// from the binary log are unsigned, they're converted to signed integers
+// unless the column is unsigned.
+func value(td binlog.TableDescription, i int, val interface{}) interface{} {
+	ct := td.ColumnType(i)
+	unsigned := i < len(td.Unsigned) && td.Unsigned[i]
+	switch v := val.(type) {
+	case uint8:
+		if !unsigned && ct == mysql.ColumnTypeTiny {
+			return int64(mysql.SignUint8(v))
+		}
+		return uint64(v)
+	case uint16:
+		if !unsigned && ct == mysql.ColumnTypeShort {
+			return int64(mysql.SignUint16(v))
+		}
+		return uint64(v)
+	case uint32:
+		if !unsigned && ct == mysql.ColumnTypeInt24 {
+			return int64(mysql.SignUint24(v))
+		}
+		if !unsigned && ct == mysql.ColumnTypeLong {
+			return int64(mysql.SignUint32(v))
+		}
+		return uint64(v)
+	case uint64:
+		if !unsigned && ct == mysql.ColumnTypeLonglong {
+			return mysql.SignUint64(v)
+		}
+		return v
+	case mysql.Decimal:
+		return v.String()
+	case []byte:
+		if ct == mysql.ColumnTypeJSON {
+			return string(v)
+		}
+	}
+	return val
+}
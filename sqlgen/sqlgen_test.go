@@ -0,0 +1,156 @@
+package sqlgen
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFromRowsEvent(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "order`s",
+		ColumnCount: 3,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar), byte(mysql.ColumnTypeTiny)},
+		ColumnMeta:  []uint16{0, 64, 0},
+		ColumnNames: []string{"id", "status", "flag"},
+		PrimaryKey:  []int{0},
+	}
+	noPK := td
+	noPK.PrimaryKey = nil
+
+	tests := []struct {
+		name string
+		td   binlog.TableDescription
+		re   binlog.RowsEvent
+		exp  []Statement
+	}{
+		{
+			name: "insert",
+			td:   td,
+			re: binlog.RowsEvent{
+				Type: binlog.EventTypeWriteRowsV2,
+				Rows: [][]interface{}{{uint32(1), "new", uint8(0xFF)}},
+			},
+			exp: []Statement{{
+				Query: "INSERT INTO `shop`.`order``s` (`id`, `status`, `flag`) VALUES (?, ?, ?)",
+				Args:  []interface{}{int64(1), "new", int64(-1)},
+			}},
+		},
+		{
+			name: "update",
+			td:   td,
+			re: binlog.RowsEvent{
+				Type: binlog.EventTypeUpdateRowsV2,
+				Rows: [][]interface{}{
+					{uint32(1), "new", nil},
+					{uint32(1), "paid", nil},
+				},
+			},
+			exp: []Statement{{
+				Query: "UPDATE `shop`.`order``s` SET `id` = ?, `status` = ?, `flag` = ? WHERE `id` = ?",
+				Args:  []interface{}{int64(1), "paid", nil, int64(1)},
+			}},
+		},
+		{
+			name: "delete without primary key",
+			td:   noPK,
+			re: binlog.RowsEvent{
+				Type: binlog.EventTypeDeleteRowsV2,
+				Rows: [][]interface{}{{uint32(2), "new", nil}},
+			},
+			exp: []Statement{{
+				Query: "DELETE FROM `shop`.`order``s` WHERE `id` = ? AND `status` = ? AND `flag` IS NULL LIMIT 1",
+				Args:  []interface{}{int64(2), "new"},
+			}},
+		},
+		{
+			name: "update with partial after image",
+			td:   td,
+			re: binlog.RowsEvent{
+				Type:          binlog.EventTypeUpdateRowsV2,
+				ColumnBitmap1: []byte{0x01},
+				ColumnBitmap2: []byte{0x02},
+				Rows: [][]interface{}{
+					{uint32(1), nil, nil},
+					{nil, "paid", nil},
+				},
+			},
+			exp: []Statement{{
+				Query: "UPDATE `shop`.`order``s` SET `status` = ? WHERE `id` = ?",
+				Args:  []interface{}{"paid", int64(1)},
+			}},
+		},
+		{
+			name: "insert with partial image",
+			td:   td,
+			re: binlog.RowsEvent{
+				Type:          binlog.EventTypeWriteRowsV2,
+				ColumnBitmap1: []byte{0x03},
+				Rows:          [][]interface{}{{uint32(3), "new", nil}},
+			},
+			exp: []Statement{{
+				Query: "INSERT INTO `shop`.`order``s` (`id`, `status`) VALUES (?, ?)",
+				Args:  []interface{}{int64(3), "new"},
+			}},
+		},
+		{
+			name: "delete with partial image without primary key",
+			td:   noPK,
+			re: binlog.RowsEvent{
+				Type:          binlog.EventTypeDeleteRowsV2,
+				ColumnBitmap1: []byte{0x05},
+				Rows:          [][]interface{}{{uint32(2), nil, nil}},
+			},
+			exp: []Statement{{
+				Query: "DELETE FROM `shop`.`order``s` WHERE `id` = ? AND `flag` IS NULL LIMIT 1",
+				Args:  []interface{}{int64(2)},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stmts, err := FromRowsEvent(test.td, test.re)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(test.exp, stmts); diff != "" {
+				t.Errorf("Statements mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAbsentKey(t *testing.T) {
+	td := binlog.TableDescription{
+		TableName:   "t",
+		ColumnCount: 2,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0, 0},
+		ColumnNames: []string{"id", "n"},
+		PrimaryKey:  []int{0},
+	}
+	re := binlog.RowsEvent{
+		Type:          binlog.EventTypeUpdateRowsV2,
+		ColumnBitmap1: []byte{0x02},
+		ColumnBitmap2: []byte{0x02},
+		Rows:          [][]interface{}{{nil, uint32(1)}, {nil, uint32(2)}},
+	}
+	if _, err := FromRowsEvent(td, re); err == nil {
+		t.Error("Expected an error for a row image without the primary key")
+	}
+}
+
+func TestNoColumnNames(t *testing.T) {
+	td := binlog.TableDescription{
+		TableName:   "t",
+		ColumnCount: 1,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong)},
+		ColumnMeta:  []uint16{0},
+	}
+	if _, err := Insert(td, []interface{}{uint32(1)}); err != ErrNoColumnNames {
+		t.Errorf("Expected ErrNoColumnNames, got %v", err)
+	}
+}
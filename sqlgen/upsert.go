@@ -0,0 +1,272 @@
+package sqlgen
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Vivino/bocadillo/binlog"
+)
+
+// Dialect is the SQL dialect of the database statements are executed on.
+type Dialect int
+
+// Supported dialects.
+const (
+	// MySQL quotes identifiers with backticks and uses ? placeholders.
+	MySQL Dialect = iota
+	// Postgres quotes identifiers with double quotes and uses numbered
+	// placeholders.
+	Postgres
+)
+
+// ErrNoPrimaryKey is returned when an idempotent statement is requested for a
+// table with unknown primary key, without which rows could not be identified.
+var ErrNoPrimaryKey = errors.New("Primary key is unknown")
+
+// ErrPartialImage is returned when an idempotent statement is requested for a
+// row change which images lack columns, as when the server doesn't log full
+// row images (binlog_row_image=FULL). Upserting such rows would reset absent
+// columns.
+var ErrPartialImage = errors.New("Row image lacks columns")
+
+func (d Dialect) String() string {
+	switch d {
+	case MySQL:
+		return "MySQL"
+	case Postgres:
+		return "Postgres"
+	default:
+		return "Dialect(" + strconv.Itoa(int(d)) + ")"
+	}
+}
+
+// QuoteIdent quotes an identifier in the dialect.
+func (d Dialect) QuoteIdent(name string) string {
+	if d == Postgres {
+		return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+	}
+	return QuoteIdent(name)
+}
+
+// placeholder returns the placeholder of the nth argument, counting from 1.
+func (d Dialect) placeholder(n int) string {
+	if d == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (d Dialect) writeTable(b *strings.Builder, td binlog.TableDescription) {
+	if td.SchemaName != "" {
+		b.WriteString(d.QuoteIdent(td.SchemaName))
+		b.WriteByte('.')
+	}
+	b.WriteString(d.QuoteIdent(td.TableName))
+}
+
+// Upsert returns a statement that inserts a row or overwrites the row with the
+// same primary key. Executing it any number of times leaves the table in the
+// same state, so changes that are applied again on replay converge.
+func Upsert(d Dialect, td binlog.TableDescription, row []interface{}) (Statement, error) {
+	if err := checkColumns(td, row); err != nil {
+		return Statement{}, err
+	}
+	if len(td.PrimaryKey) == 0 {
+		return Statement{}, ErrNoPrimaryKey
+	}
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	d.writeTable(&b, td)
+	b.WriteString(" (")
+	for i := range row {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(d.QuoteIdent(td.ColumnNames[i]))
+	}
+	b.WriteString(") VALUES (")
+	args := make([]interface{}, len(row))
+	for i, val := range row {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(d.placeholder(i + 1))
+		args[i] = value(td, i, val)
+	}
+	b.WriteByte(')')
+
+	// Columns other than the primary key are overwritten
+	isKey := make(map[int]bool, len(td.PrimaryKey))
+	for _, i := range td.PrimaryKey {
+		isKey[i] = true
+	}
+	var cols []string
+	for i := range row {
+		if !isKey[i] {
+			cols = append(cols, d.QuoteIdent(td.ColumnNames[i]))
+		}
+	}
+
+	switch d {
+	case Postgres:
+		b.WriteString(" ON CONFLICT (")
+		for n, i := range td.PrimaryKey {
+			if n > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(d.QuoteIdent(td.ColumnNames[i]))
+		}
+		if len(cols) == 0 {
+			b.WriteString(") DO NOTHING")
+			break
+		}
+		b.WriteString(") DO UPDATE SET ")
+		for n, col := range cols {
+			if n > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(col + " = EXCLUDED." + col)
+		}
+	default:
+		b.WriteString(" ON DUPLICATE KEY UPDATE ")
+		if len(cols) == 0 {
+			// A no-op assignment ignores the duplicate
+			col := d.QuoteIdent(td.ColumnNames[td.PrimaryKey[0]])
+			b.WriteString(col + " = " + col)
+			break
+		}
+		for n, col := range cols {
+			if n > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(col + " = VALUES(" + col + ")")
+		}
+	}
+	return Statement{Query: b.String(), Args: args}, nil
+}
+
+// DeleteKey returns a statement that deletes a row by its primary key. Deleting
+// a row that doesn't exist has no effect, so the statement is idempotent.
+func DeleteKey(d Dialect, td binlog.TableDescription, row []interface{}) (Statement, error) {
+	if err := checkColumns(td, row); err != nil {
+		return Statement{}, err
+	}
+	if len(td.PrimaryKey) == 0 {
+		return Statement{}, ErrNoPrimaryKey
+	}
+	var b strings.Builder
+	b.WriteString("DELETE FROM ")
+	d.writeTable(&b, td)
+	b.WriteString(" WHERE ")
+	args := make([]interface{}, 0, len(td.PrimaryKey))
+	for n, i := range td.PrimaryKey {
+		if n > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(d.QuoteIdent(td.ColumnNames[i]))
+		b.WriteString(" = ")
+		args = append(args, value(td, i, row[i]))
+		b.WriteString(d.placeholder(len(args)))
+	}
+	return Statement{Query: b.String(), Args: args}, nil
+}
+
+// IdempotentFromRowsEvent returns idempotent statements for every row change
+// of a rows event. Inserts and updates become upserts of the row after the
+// change, updates that change the primary key also delete the row before the
+// change. Unlike statements returned by FromRowsEvent, these could be applied
+// more than once, as in at-least-once pipelines, since the result only depends
+// on the last change of every row.
+//
+// Columns absent from the image after an update are taken from the image
+// before it, ErrPartialImage is returned if a column is absent from both.
+func IdempotentFromRowsEvent(d Dialect, td binlog.TableDescription, re binlog.RowsEvent) ([]Statement, error) {
+	if binlog.RowsEventVersion(re.Type) < 0 {
+		return nil, fmt.Errorf("not a rows event: %s", re.Type.String())
+	}
+	var stmts []Statement
+	for _, c := range re.Changes() {
+		switch c.Type {
+		case binlog.ChangeInsert:
+			row, err := fullImage(c)
+			if err != nil {
+				return nil, err
+			}
+			s, err := Upsert(d, td, row)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, s)
+		case binlog.ChangeUpdate:
+			if err := checkColumns(td, c.Before); err != nil {
+				return nil, err
+			}
+			if err := checkKey(td, c.BeforeHas); err != nil {
+				return nil, ErrPartialImage
+			}
+			row, err := fullImage(c)
+			if err != nil {
+				return nil, err
+			}
+			if keyChanged(td, c) {
+				s, err := DeleteKey(d, td, c.Before)
+				if err != nil {
+					return nil, err
+				}
+				stmts = append(stmts, s)
+			}
+			s, err := Upsert(d, td, row)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, s)
+		case binlog.ChangeDelete:
+			if err := checkKey(td, c.BeforeHas); err != nil {
+				return nil, ErrPartialImage
+			}
+			s, err := DeleteKey(d, td, c.Before)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, s)
+		}
+	}
+	return stmts, nil
+}
+
+// fullImage returns the row after a change. Columns absent from the image
+// after an update are not changed by it, they're taken from the image before
+// the update.
+func fullImage(c binlog.RowChange) ([]interface{}, error) {
+	row := make([]interface{}, len(c.After))
+	for i := range row {
+		switch {
+		case c.AfterHas(i):
+			row[i] = c.After[i]
+		case c.Type == binlog.ChangeUpdate && i < len(c.Before) && c.BeforeHas(i):
+			row[i] = c.Before[i]
+		default:
+			return nil, ErrPartialImage
+		}
+	}
+	return row, nil
+}
+
+// keyChanged returns true if primary key values of the rows differ. Key
+// columns absent from the image after the change were not changed.
+func keyChanged(td binlog.TableDescription, c binlog.RowChange) bool {
+	for _, i := range td.PrimaryKey {
+		if i >= len(c.After) {
+			return true
+		}
+		if !c.AfterHas(i) {
+			continue
+		}
+		if fmt.Sprint(c.Before[i]) != fmt.Sprint(c.After[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package sqlgen
+
+import (
+	"testing"
+
+	"github.com/Vivino/bocadillo/binlog"
+	"github.com/Vivino/bocadillo/mysql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIdempotentFromRowsEvent(t *testing.T) {
+	td := binlog.TableDescription{
+		SchemaName:  "shop",
+		TableName:   "orders",
+		ColumnCount: 2,
+		ColumnTypes: []byte{byte(mysql.ColumnTypeLong), byte(mysql.ColumnTypeVarchar)},
+		ColumnMeta:  []uint16{0, 64},
+		ColumnNames: []string{"id", "status"},
+		PrimaryKey:  []int{0},
+	}
+	insert := binlog.RowsEvent{
+		Type: binlog.EventTypeWriteRowsV2,
+		Rows: [][]interface{}{{uint32(1), "new"}},
+	}
+	moved := binlog.RowsEvent{
+		Type: binlog.EventTypeUpdateRowsV2,
+		Rows: [][]interface{}{
+			{uint32(1), "new"},
+			{uint32(2), "paid"},
+		},
+	}
+
+	// Status is the only column logged after the update, key is taken from
+	// the image before it
+	partial := binlog.RowsEvent{
+		Type:          binlog.EventTypeUpdateRowsV2,
+		ColumnBitmap1: []byte{0x03},
+		ColumnBitmap2: []byte{0x02},
+		Rows: [][]interface{}{
+			{uint32(1), "new"},
+			{nil, "paid"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		re      binlog.RowsEvent
+		exp     []Statement
+	}{
+		{
+			name:    "mysql insert",
+			dialect: MySQL,
+			re:      insert,
+			exp: []Statement{{
+				Query: "INSERT INTO `shop`.`orders` (`id`, `status`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `status` = VALUES(`status`)",
+				Args:  []interface{}{int64(1), "new"},
+			}},
+		},
+		{
+			name:    "postgres insert",
+			dialect: Postgres,
+			re:      insert,
+			exp: []Statement{{
+				Query: `INSERT INTO "shop"."orders" ("id", "status") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "status" = EXCLUDED."status"`,
+				Args:  []interface{}{int64(1), "new"},
+			}},
+		},
+		{
+			name:    "postgres primary key update",
+			dialect: Postgres,
+			re:      moved,
+			exp: []Statement{
+				{
+					Query: `DELETE FROM "shop"."orders" WHERE "id" = $1`,
+					Args:  []interface{}{int64(1)},
+				},
+				{
+					Query: `INSERT INTO "shop"."orders" ("id", "status") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "status" = EXCLUDED."status"`,
+					Args:  []interface{}{int64(2), "paid"},
+				},
+			},
+		},
+		{
+			name:    "mysql update with partial after image",
+			dialect: MySQL,
+			re:      partial,
+			exp: []Statement{{
+				Query: "INSERT INTO `shop`.`orders` (`id`, `status`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `status` = VALUES(`status`)",
+				Args:  []interface{}{int64(1), "paid"},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stmts, err := IdempotentFromRowsEvent(test.dialect, td, test.re)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(test.exp, stmts); diff != "" {
+				t.Errorf("Statements mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Columns absent from both images would be reset by an upsert
+	minimal := partial
+	minimal.ColumnBitmap1 = []byte{0x01}
+	minimal.ColumnBitmap2 = []byte{0x01}
+	partialInsert := insert
+	partialInsert.ColumnBitmap1 = []byte{0x01}
+	for _, re := range []binlog.RowsEvent{minimal, partialInsert} {
+		if _, err := IdempotentFromRowsEvent(MySQL, td, re); err != ErrPartialImage {
+			t.Errorf("Expected ErrPartialImage for %s, got %v", re.Type, err)
+		}
+	}
+
+	noPK := td
+	noPK.PrimaryKey = nil
+	if _, err := Upsert(MySQL, noPK, []interface{}{uint32(1), "new"}); err != ErrNoPrimaryKey {
+		t.Errorf("Expected ErrNoPrimaryKey, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"bytes"
 	"crypto/rand"
+	"fmt"
 	"strconv"
 	"testing"
 
@@ -37,14 +39,35 @@ func TestBlob(t *testing.T) {
 // Currently reader doesn't maintain schema and there's no way to test it.
 
 // func TestMediumblob(t *testing.T)
-// func TestLongblob(t *testing.T)
 //
-// Tried testing Mediumblob and Longblob the same way, got this error:
+// Tried testing Mediumblob the same way as other blobs, got this error:
 // Error 1105: Parameter of prepared statement which is set through
 // mysql_send_long_data() is longer than 'max_allowed_packet' bytes
 //
-// That is from the client trying to insert a massive blob. I guess that's good
-// enough and I hope these types work too.
+// That is from the client trying to insert a massive blob. Longblob test below
+// generates the value on the server instead.
+
+// TestLongblob inserts a value that doesn't fit into a single packet, so the
+// event is split into multiple packets.
+func TestLongblob(t *testing.T) {
+	const size = 20 << 20
+	var maxPacket int
+	if err := suite.conn.QueryRow("SELECT @@max_allowed_packet").Scan(&maxPacket); err != nil {
+		t.Fatal(err)
+	}
+	if maxPacket < size+1024 {
+		t.Skipf("max_allowed_packet is too small: %d", maxPacket)
+	}
+
+	tbl := suite.createTable(mysql.ColumnTypeLongblob, "", attrNone)
+	defer tbl.drop(t)
+
+	_, err := suite.conn.Exec(fmt.Sprintf("INSERT INTO %s VALUES (REPEAT('x', %d))", tbl.name, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.expectValue(t, tbl, []interface{}{bytes.Repeat([]byte("x"), size)})
+}
 
 func nRandBytes(ns ...int) [][]byte {
 	nns := make([][]byte, len(ns))